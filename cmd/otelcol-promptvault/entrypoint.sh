@@ -0,0 +1,29 @@
+#!/bin/sh
+# Container entrypoint for otelcol-promptvault. The collector takes its
+# config only from --config; this wrapper adds --config "$OTELCOL_CONFIG"
+# when the variable is set and the arguments name no config of their own,
+# so platforms that pass the config location in the environment work
+# unchanged. Set OTELCOL_BIN if the binary is not in
+# /usr/local/bin/otelcol-promptvault.
+set -e
+
+bin=${OTELCOL_BIN:-/usr/local/bin/otelcol-promptvault}
+
+for arg in "$@"; do
+	case $arg in
+	--config | --config=*) exec "$bin" "$@" ;;
+	esac
+done
+if [ -z "${OTELCOL_CONFIG:-}" ]; then
+	exec "$bin" "$@"
+fi
+
+case ${1:-} in
+# Subcommands that read no config.
+components | completion | help) exec "$bin" "$@" ;;
+validate)
+	shift
+	exec "$bin" validate --config "$OTELCOL_CONFIG" "$@"
+	;;
+*) exec "$bin" --config "$OTELCOL_CONFIG" "$@" ;;
+esac
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"context"
+	"io"
+	"os/user"
+
+	"github.com/airblackbox/otel-prompt-vault/processor/promptvaultprocessor"
+)
+
+// withAudit wraps vault so every retrieval is appended to auditLog, if set.
+func withAudit(vault promptvaultprocessor.VaultStorage, auditLog string) (promptvaultprocessor.VaultStorage, error) {
+	if auditLog == "" {
+		return vault, nil
+	}
+	sink, err := promptvaultprocessor.NewAuditSink(context.Background(), promptvaultprocessor.AuditConfig{Sink: "file", File: auditLog}, vault)
+	if err != nil {
+		return nil, err
+	}
+	return promptvaultprocessor.NewAuditingVault(vault, sink, "cli"), nil
+}
+
+// principal names the operator for audit records.
+func principal() string {
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	return "unknown"
+}
+
+func closeVault(vault promptvaultprocessor.VaultStorage) {
+	if c, ok := vault.(io.Closer); ok {
+		c.Close()
+	}
+}
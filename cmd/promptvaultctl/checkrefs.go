@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+
+	"github.com/airblackbox/otel-prompt-vault/processor/promptvaultprocessor"
+)
+
+func newCheckRefsCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "check-refs",
+		Short: "find orphaned objects and dangling span references",
+	}
+	fs := cmd.Flags()
+	configFile := fs.String("config", "", "YAML config with a promptvault section (required)")
+	spansFile := fs.String("spans", "", "OTLP JSON lines export of the retained spans; without it storage.index stands in")
+	spanRetention := fs.Duration("span-retention", 0, "how long the trace backend keeps spans, used with storage.index (0 = forever)")
+	minAge := fs.Duration("min-age", time.Hour, "never report objects younger than this as orphans")
+	deleteOrphans := fs.Bool("delete-orphans", false, "delete the orphaned objects found")
+	output := outputFlag(cmd, outputPretty, outputTable, outputJSON, outputPretty)
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		if *configFile == "" {
+			return fmt.Errorf("--config is required")
+		}
+		format, err := output()
+		if err != nil {
+			return err
+		}
+		cfg := &vaultFileConfig{
+			Vault: promptvaultprocessor.NewFactory().CreateDefaultConfig().(*promptvaultprocessor.Config),
+		}
+		if err := loadConfig(*configFile, cfg); err != nil {
+			return err
+		}
+		if err := promptvaultprocessor.CheckSecretsRedacted(cfg); err != nil {
+			return err
+		}
+		opts := promptvaultprocessor.RefCheckOptions{SpanRetention: *spanRetention, MinAge: *minAge}
+		if *spansFile != "" {
+			refs, err := readSpanRefs(*spansFile)
+			if err != nil {
+				return err
+			}
+			opts.SpanRefs = refs
+		}
+
+		logger, err := newLogger()
+		if err != nil {
+			return err
+		}
+		defer logger.Sync()
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		report, err := promptvaultprocessor.CheckReferences(ctx, logger, cfg.Vault, opts)
+		if err != nil {
+			return err
+		}
+		if format != outputTable {
+			type orphan struct {
+				Ref      string    `json:"ref"`
+				Size     int64     `json:"size"`
+				StoredAt time.Time `json:"stored_at"`
+			}
+			out := struct {
+				Objects    int      `json:"objects"`
+				Referenced int      `json:"referenced"`
+				Orphans    []orphan `json:"orphans"`
+				Dangling   []string `json:"dangling"`
+			}{Objects: report.Objects, Referenced: report.Referenced, Orphans: []orphan{}, Dangling: report.Dangling}
+			for _, o := range report.Orphans {
+				out.Orphans = append(out.Orphans, orphan{o.Ref, o.Size, o.StoredAt.UTC()})
+			}
+			if out.Dangling == nil {
+				out.Dangling = []string{}
+			}
+			if err := printJSON(out, format == outputPretty); err != nil {
+				return err
+			}
+		} else {
+			for _, o := range report.Orphans {
+				fmt.Printf("orphan\t%s\n", o.Ref)
+			}
+			for _, ref := range report.Dangling {
+				fmt.Printf("dangling\t%s\n", ref)
+			}
+		}
+		fmt.Fprintf(os.Stderr, "%d objects, %d referenced, %d orphaned, %d dangling\n",
+			report.Objects, report.Referenced, len(report.Orphans), len(report.Dangling))
+
+		if *deleteOrphans && len(report.Orphans) > 0 {
+			vault, err := promptvaultprocessor.OpenVault(ctx, logger, cfg.Vault)
+			if err != nil {
+				return err
+			}
+			defer closeVault(vault)
+			n, err := report.DeleteOrphans(ctx, vault)
+			fmt.Fprintf(os.Stderr, "deleted %d orphans\n", n)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return cmd
+}
+
+// readSpanRefs collects the vault references in the span and span event
+// attributes of an OTLP JSON lines file, as written by the collector's
+// file exporter.
+func readSpanRefs(path string) (map[string]bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	refs := make(map[string]bool)
+	collect := func(attrs pcommon.Map) {
+		attrs.Range(func(_ string, v pcommon.Value) bool {
+			if v.Type() == pcommon.ValueTypeStr && strings.HasPrefix(v.Str(), "vault://") {
+				refs[v.Str()] = true
+			}
+			return true
+		})
+	}
+	var u ptrace.JSONUnmarshaler
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 1<<20), 64<<20)
+	for line := 1; scanner.Scan(); line++ {
+		if len(strings.TrimSpace(scanner.Text())) == 0 {
+			continue
+		}
+		td, err := u.UnmarshalTraces(scanner.Bytes())
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		rss := td.ResourceSpans()
+		for i := 0; i < rss.Len(); i++ {
+			sss := rss.At(i).ScopeSpans()
+			for j := 0; j < sss.Len(); j++ {
+				spans := sss.At(j).Spans()
+				for k := 0; k < spans.Len(); k++ {
+					span := spans.At(k)
+					collect(span.Attributes())
+					for e := 0; e < span.Events().Len(); e++ {
+						collect(span.Events().At(e).Attributes())
+					}
+				}
+			}
+		}
+	}
+	return refs, scanner.Err()
+}
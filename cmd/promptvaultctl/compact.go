@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/spf13/cobra"
+
+	"github.com/airblackbox/otel-prompt-vault/processor/promptvaultprocessor"
+)
+
+func newCompactCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "compact",
+		Short: "bundle old objects into compressed archives",
+	}
+	fs := cmd.Flags()
+	configFile := fs.String("config", "", "YAML config with a promptvault section setting storage.bundles (required)")
+	once := fs.Bool("once", false, "compact once and exit instead of every storage.bundles.interval")
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		if *configFile == "" {
+			return fmt.Errorf("--config is required")
+		}
+		cfg := &vaultFileConfig{
+			Vault: promptvaultprocessor.NewFactory().CreateDefaultConfig().(*promptvaultprocessor.Config),
+		}
+		if err := loadConfig(*configFile, cfg); err != nil {
+			return err
+		}
+		if err := promptvaultprocessor.CheckSecretsRedacted(cfg); err != nil {
+			return err
+		}
+
+		logger, err := newLogger()
+		if err != nil {
+			return err
+		}
+		defer logger.Sync()
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		vault, err := promptvaultprocessor.OpenVault(ctx, logger, cfg.Vault)
+		if err != nil {
+			return err
+		}
+		defer closeVault(vault)
+		c, err := promptvaultprocessor.NewCompaction(logger, vault, cfg.Vault.Storage.Bundles)
+		if err != nil {
+			return err
+		}
+
+		if *once {
+			stats, err := c.Run(ctx)
+			fmt.Fprintf(os.Stderr, "bundled %d objects (%d bytes) into %d bundles (%d bytes), failed %d\n",
+				stats.Objects, stats.Bytes, stats.Bundles, stats.BundleBytes, stats.Failed)
+			if err != nil {
+				return err
+			}
+			if stats.Failed > 0 {
+				return fmt.Errorf("%d objects could not be bundled or removed", stats.Failed)
+			}
+			return nil
+		}
+
+		if err := c.Start(ctx); err != nil {
+			return err
+		}
+		<-ctx.Done()
+		return c.Close()
+	}
+	return cmd
+}
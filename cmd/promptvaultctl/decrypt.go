@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+
+	"github.com/spf13/cobra"
+
+	"github.com/airblackbox/otel-prompt-vault/processor/promptvaultprocessor"
+)
+
+func newDecryptCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "decrypt <file>",
+		Short: "decrypt an object file copied out of storage, without connecting to the backend",
+	}
+	fs := cmd.Flags()
+	configFile := fs.String("config", "", "YAML config with a promptvault section; only its crypto settings are used")
+	keyring := fs.String("keyring", "", "keyring file of \"<key id> <base64 key>\" lines, instead of --config")
+	ref := fs.String("ref", "", "the object's reference, to check that the file is the object it names")
+	output := outputFlag(cmd, "", outputRaw, outputJSON, outputPretty)
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		if (*configFile == "") == (*keyring == "") || len(args) != 1 {
+			return fmt.Errorf("usage: decrypt (--config <file> | --keyring <file>) <file>")
+		}
+		format, err := output()
+		if err != nil {
+			return err
+		}
+		crypto := promptvaultprocessor.CryptoConfig{
+			Provider: "local",
+			Local:    promptvaultprocessor.LocalKeysConfig{KeyringFile: *keyring},
+		}
+		if *configFile != "" {
+			cfg, logger, err := loadVaultFileConfig(*configFile)
+			if err != nil {
+				return err
+			}
+			defer logger.Sync()
+			crypto = cfg.Vault.Crypto
+		}
+		var stored []byte
+		if args[0] == "-" {
+			stored, err = io.ReadAll(os.Stdin)
+		} else {
+			stored, err = os.ReadFile(args[0])
+		}
+		if err != nil {
+			return err
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+		content, err := promptvaultprocessor.DecryptObject(ctx, crypto, *ref, stored)
+		if errors.Is(err, promptvaultprocessor.ErrChecksumMismatch) {
+			return fmt.Errorf("%s is not the object %s names", args[0], *ref)
+		}
+		if err != nil {
+			return err
+		}
+
+		name := *ref
+		if name == "" {
+			name = args[0]
+		}
+		switch format {
+		case outputRaw:
+			_, err = os.Stdout.Write(content)
+		case outputJSON:
+			err = printJSON(newContentJSON(name, content), false)
+		case outputPretty:
+			text, ok := readable(content)
+			if !ok {
+				return fmt.Errorf("%s is binary (%d bytes); use --output raw or json", name, len(content))
+			}
+			_, err = fmt.Printf("%s\n", bytes.TrimRight(text, "\n"))
+		}
+		return err
+	}
+	return cmd
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/airblackbox/otel-prompt-vault/processor/promptvaultprocessor"
+)
+
+func newDUCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "du",
+		Short: "aggregate object counts and bytes by trace, key, service, tenant, or day",
+	}
+	fs := cmd.Flags()
+	configFile := fs.String("config", "", "YAML config with a promptvault section (required)")
+	by := fs.String("by", "day", "comma-separated dimensions to group by: trace, key, service, tenant, day")
+	since := fs.Duration("since", 0, "only count objects stored within this long, e.g. 720h")
+	service := fs.String("service", "", "only count this service's objects")
+	tenant := fs.String("tenant", "", "only count this tenant's objects")
+	key := fs.String("key", "", "only count objects of this attribute key")
+	top := fs.Int("top", 0, "print only the N largest groups (0 = all)")
+	output := outputFlag(cmd, outputPretty, outputTable, outputJSON, outputPretty)
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		if *configFile == "" {
+			return fmt.Errorf("--config is required")
+		}
+		format, err := output()
+		if err != nil {
+			return err
+		}
+		cfg, logger, err := loadVaultFileConfig(*configFile)
+		if err != nil {
+			return err
+		}
+		defer logger.Sync()
+
+		ctx := context.Background()
+		vault, err := promptvaultprocessor.OpenVault(ctx, logger, cfg.Vault)
+		if err != nil {
+			return err
+		}
+		defer closeVault(vault)
+		filter := promptvaultprocessor.ObjectFilter{Service: *service, Tenant: *tenant, Key: *key}
+		if *since > 0 {
+			filter.StoredAfter = time.Now().Add(-*since)
+		}
+		dims := strings.Split(*by, ",")
+		rows, err := promptvaultprocessor.DiskUsage(ctx, vault, filter, dims...)
+		if err != nil {
+			return err
+		}
+		if *top > 0 && len(rows) > *top {
+			rows = rows[:*top]
+		}
+
+		if format != outputTable {
+			return printJSON(rows, format == outputPretty)
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintf(w, "%s\tOBJECTS\tBYTES\n", strings.ToUpper(strings.Join(dims, "\t")))
+		for _, row := range rows {
+			for _, v := range row.Group {
+				if v == "" {
+					v = "-"
+				}
+				fmt.Fprintf(w, "%s\t", v)
+			}
+			fmt.Fprintf(w, "%d\t%d\n", row.Objects, row.Bytes)
+		}
+		return w.Flush()
+	}
+	return cmd
+}
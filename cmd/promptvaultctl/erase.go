@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/spf13/cobra"
+
+	"github.com/airblackbox/otel-prompt-vault/processor/promptvaultprocessor"
+)
+
+// vaultFileConfig is the file read by `promptvaultctl erase` and `reindex`.
+type vaultFileConfig struct {
+	Vault *promptvaultprocessor.Config `mapstructure:"promptvault"`
+}
+
+func newEraseCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "erase",
+		Short: "erase a trace's or end user's objects with a signed receipt",
+	}
+	fs := cmd.Flags()
+	configFile := fs.String("config", "", "YAML config with a promptvault section (required)")
+	traceID := fs.String("trace-id", "", "erase every object stored for this trace")
+	endUser := fs.String("enduser-id", "", "erase every object stored for this end user")
+	receiptKey := fs.String("receipt-key", "", "PEM Ed25519 private key that signs the receipt (required)")
+	out := fs.String("out", "", "write the signed receipt here instead of stdout")
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		if *configFile == "" || *receiptKey == "" {
+			return fmt.Errorf("--config and --receipt-key are required")
+		}
+		req := promptvaultprocessor.ErasureRequest{TraceID: *traceID, EndUser: *endUser}
+		if (req.TraceID == "") == (req.EndUser == "") {
+			return fmt.Errorf("exactly one of --trace-id and --enduser-id is required")
+		}
+		key, err := promptvaultprocessor.LoadEd25519ManifestKey(*receiptKey)
+		if err != nil {
+			return err
+		}
+		cfg := &vaultFileConfig{
+			Vault: promptvaultprocessor.NewFactory().CreateDefaultConfig().(*promptvaultprocessor.Config),
+		}
+		if err := loadConfig(*configFile, cfg); err != nil {
+			return err
+		}
+		if err := promptvaultprocessor.CheckSecretsRedacted(cfg); err != nil {
+			return err
+		}
+
+		logger, err := newLogger()
+		if err != nil {
+			return err
+		}
+		defer logger.Sync()
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		ctx = promptvaultprocessor.WithPrincipal(ctx, principal())
+
+		vault, err := promptvaultprocessor.OpenVault(ctx, logger, cfg.Vault)
+		if err != nil {
+			return err
+		}
+		defer closeVault(vault)
+
+		receipt, err := promptvaultprocessor.Erase(ctx, vault, req, nil)
+		if err != nil {
+			return err
+		}
+		if err := receipt.Sign(key); err != nil {
+			return err
+		}
+		body, err := json.MarshalIndent(receipt, "", "  ")
+		if err != nil {
+			return err
+		}
+		body = append(body, '\n')
+		if *out == "" {
+			os.Stdout.Write(body)
+		} else if err := os.WriteFile(*out, body, 0o600); err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "deleted %d, failed %d\n", len(receipt.Deleted), len(receipt.Failed))
+		if len(receipt.Failed) > 0 {
+			return fmt.Errorf("%d matching objects could not be deleted", len(receipt.Failed))
+		}
+		return nil
+	}
+	return cmd
+}
+
+func newVerifyReceiptCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "verify-receipt <receipt>",
+		Short: "verify an erasure receipt's signature",
+	}
+	fs := cmd.Flags()
+	receiptKey := fs.String("receipt-key", "", "PEM Ed25519 public or private key (required)")
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		if *receiptKey == "" || len(args) != 1 {
+			return fmt.Errorf("usage: verify-receipt --receipt-key <pem> <receipt.json>")
+		}
+		key, err := promptvaultprocessor.LoadEd25519ManifestKey(*receiptKey)
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(args[0])
+		if err != nil {
+			return err
+		}
+		var receipt promptvaultprocessor.ErasureReceipt
+		if err := json.Unmarshal(data, &receipt); err != nil {
+			return fmt.Errorf("parse receipt: %w", err)
+		}
+		if err := promptvaultprocessor.VerifyErasureReceipt(receipt, key); err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "receipt verified: %d objects erased by %s at %s\n", len(receipt.Deleted), receipt.Principal, receipt.Time.Format("2006-01-02T15:04:05Z07:00"))
+		return nil
+	}
+	return cmd
+}
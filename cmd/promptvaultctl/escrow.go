@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+
+	"github.com/airblackbox/otel-prompt-vault/processor/promptvaultprocessor"
+)
+
+func newEscrowCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "escrow",
+		Short: "split the local encryption keys into officers' recovery shares",
+	}
+	fs := cmd.Flags()
+	configFile := fs.String("config", "", "YAML config whose promptvault.crypto.local holds the keys to escrow (required)")
+	threshold := fs.Int("threshold", 2, "number of shares needed to recover the keys")
+	holders := fs.String("holders", "", "comma-separated names of the officers receiving a share (required)")
+	outDir := fs.String("out-dir", ".", "directory for escrow.json and one share-<holder>.json per officer")
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		if *configFile == "" || *holders == "" {
+			return fmt.Errorf("--config and --holders are required")
+		}
+		cfg, logger, err := loadVaultFileConfig(*configFile)
+		if err != nil {
+			return err
+		}
+		defer logger.Sync()
+		meta, shares, err := promptvaultprocessor.EscrowKeys(cfg.Vault.Crypto.Local, *threshold, strings.Split(*holders, ","))
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(*outDir, 0o700); err != nil {
+			return err
+		}
+		if err := writeNewJSON(filepath.Join(*outDir, "escrow.json"), meta); err != nil {
+			return err
+		}
+		for _, s := range shares {
+			if err := writeNewJSON(filepath.Join(*outDir, "share-"+s.Holder+".json"), s); err != nil {
+				return err
+			}
+		}
+		fmt.Fprintf(os.Stderr, "escrow %s: %d keys split into %d shares, %d needed to recover\n",
+			meta.ID, len(meta.KeyIDs), len(shares), meta.Threshold)
+		return nil
+	}
+	return cmd
+}
+
+func newBreakGlassCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "break-glass <ref>...",
+		Short: "decrypt objects with keys recovered from escrow shares",
+	}
+	fs := cmd.Flags()
+	configFile := fs.String("config", "", "YAML config with a promptvault section describing the vault (required)")
+	escrowFile := fs.String("escrow", "escrow.json", "escrow metadata written by promptvaultctl escrow")
+	shareFiles := fs.StringArray("share", nil, "an officer's share file; repeat for each officer")
+	auditLog := fs.String("audit-log", "", "append a JSON line per object read to this audit log")
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		if *configFile == "" || len(args) == 0 {
+			return fmt.Errorf("usage: break-glass --config <file> --share <file> --share <file> <ref>...")
+		}
+		var meta promptvaultprocessor.EscrowMetadata
+		if err := readJSON(*escrowFile, &meta); err != nil {
+			return err
+		}
+		shares := make([]promptvaultprocessor.EscrowShare, len(*shareFiles))
+		for i, f := range *shareFiles {
+			if err := readJSON(f, &shares[i]); err != nil {
+				return err
+			}
+		}
+		keys, err := promptvaultprocessor.RecoverEscrowedKeys(meta, shares)
+		if err != nil {
+			return err
+		}
+		cfg, logger, err := loadVaultFileConfig(*configFile)
+		if err != nil {
+			return err
+		}
+		defer logger.Sync()
+		// The recovered keys stand in for whatever the config names, which may
+		// be unavailable in the emergency.
+		cfg.Vault.Crypto.Provider = "local"
+		cfg.Vault.Crypto.KeySource = "config"
+		cfg.Vault.Crypto.Local = promptvaultprocessor.LocalKeysConfig{Keys: keys, ActiveKey: keys[0].ID}
+
+		holders := make([]string, len(shares))
+		for i, s := range shares {
+			holders[i] = s.Holder
+		}
+		logger.Warn("break-glass decryption",
+			zap.String("escrow", meta.ID),
+			zap.Strings("holders", holders),
+			zap.String("operator", principal()),
+			zap.Strings("refs", args),
+		)
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+		ctx = promptvaultprocessor.WithPrincipal(ctx, principal())
+		opened, err := promptvaultprocessor.OpenVault(ctx, logger, cfg.Vault)
+		if err != nil {
+			return err
+		}
+		vault, err := withAudit(opened, *auditLog)
+		if err != nil {
+			closeVault(opened)
+			return err
+		}
+		defer closeVault(vault)
+		for _, ref := range args {
+			content, err := vault.Retrieve(ctx, ref)
+			if err != nil {
+				return fmt.Errorf("retrieve %s: %w", ref, err)
+			}
+			if _, err := os.Stdout.Write(content); err != nil {
+				return err
+			}
+			fmt.Println()
+		}
+		return nil
+	}
+	return cmd
+}
+
+// writeNewJSON writes v to a new file only the owner can read.
+func writeNewJSON(path string, v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func readJSON(path string, v any) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("parse %s: %w", path, err)
+	}
+	return nil
+}
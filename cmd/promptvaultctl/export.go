@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/airblackbox/otel-prompt-vault/export"
+	"github.com/airblackbox/otel-prompt-vault/processor/promptvaultprocessor"
+)
+
+// exportConfig is the file read by `promptvaultctl export`.
+type exportConfig struct {
+	Vault  *promptvaultprocessor.Config `mapstructure:"promptvault"`
+	Export export.Options               `mapstructure:"export"`
+}
+
+func newExportCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "export",
+		Short: "export vault content as a JSONL or Parquet dataset",
+	}
+	fs := cmd.Flags()
+	configFile := fs.String("config", "", "YAML config with a promptvault section and an optional export section (required)")
+	start := fs.String("start", "", "export objects stored at or after this RFC 3339 time")
+	end := fs.String("end", "", "export objects stored before this RFC 3339 time")
+	service := fs.String("service", "", "export only this service's objects")
+	tenant := fs.String("tenant", "", "export only this tenant's objects")
+	format := fs.String("format", "jsonl", "jsonl (input/output pairs per span) or parquet (a row per object)")
+	out := fs.String("out", "", "write the dataset here instead of stdout")
+	auditLog := fs.String("audit-log", "", "append a JSON line per object read to this audit log")
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		if *configFile == "" {
+			return fmt.Errorf("--config is required")
+		}
+		write := export.WriteJSONL
+		switch *format {
+		case "jsonl":
+		case "parquet":
+			write = export.WriteParquet
+		default:
+			return fmt.Errorf("unknown --format %q", *format)
+		}
+		cfg := &exportConfig{
+			Vault: promptvaultprocessor.NewFactory().CreateDefaultConfig().(*promptvaultprocessor.Config),
+		}
+		if err := loadConfig(*configFile, cfg); err != nil {
+			return err
+		}
+		if err := promptvaultprocessor.CheckSecretsRedacted(cfg); err != nil {
+			return err
+		}
+		opts := cfg.Export
+		for _, f := range []struct {
+			val string
+			dst *time.Time
+		}{{*start, &opts.Start}, {*end, &opts.End}} {
+			if f.val == "" {
+				continue
+			}
+			t, err := time.Parse(time.RFC3339, f.val)
+			if err != nil {
+				return err
+			}
+			*f.dst = t
+		}
+		if *service != "" {
+			opts.Service = *service
+		}
+		if *tenant != "" {
+			opts.Tenant = *tenant
+		}
+
+		logger, err := newLogger()
+		if err != nil {
+			return err
+		}
+		defer logger.Sync()
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		ctx = promptvaultprocessor.WithPrincipal(ctx, principal())
+
+		vault, err := promptvaultprocessor.OpenVault(ctx, logger, cfg.Vault)
+		if err != nil {
+			return err
+		}
+		if vault, err = withAudit(vault, *auditLog); err != nil {
+			return err
+		}
+		defer closeVault(vault)
+
+		var w io.Writer = os.Stdout
+		if *out != "" {
+			f, err := os.OpenFile(*out, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			w = f
+		}
+		bw := bufio.NewWriter(w)
+		stats, err := write(ctx, vault, bw, opts)
+		if ferr := bw.Flush(); err == nil {
+			err = ferr
+		}
+		if *format == "jsonl" {
+			fmt.Fprintf(os.Stderr, "wrote %d records, %d incomplete spans, %d failed\n", stats.Written, stats.Incomplete, stats.Failed)
+		} else {
+			fmt.Fprintf(os.Stderr, "wrote %d rows, %d failed\n", stats.Written, stats.Failed)
+		}
+		return err
+	}
+	return cmd
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/spf13/cobra"
+	"go.opentelemetry.io/collector/config/configopaque"
+	"go.uber.org/zap"
+
+	"github.com/airblackbox/otel-prompt-vault/processor/promptvaultprocessor"
+	"github.com/airblackbox/otel-prompt-vault/promptvaultclient"
+)
+
+func newGetCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "get (<ref>... | --trace-id <id> --span-id <id> --key <key>)",
+		Short: "print the content behind references, from storage or the retrieval API",
+	}
+	fs := cmd.Flags()
+	configFile := fs.String("config", "", "YAML config with a promptvault section; read straight from its storage")
+	endpoint := fs.String("endpoint", "", "retrieval API base URL, instead of --config")
+	sig := fs.String("sig", "", "the reference's signature, from the span's <key>.vault_sig attribute; one reference only")
+	requireSig := fs.Bool("require-signature", false, "refuse references without a signature")
+	traceID := fs.String("trace-id", "", "with --span-id and --key, look up the reference instead of passing it")
+	spanID := fs.String("span-id", "", "the span that stored the object")
+	key := fs.String("key", "", "the attribute key the object was stored for")
+	refsFile := fs.String("refs-file", "", "fetch every reference in this file, one per line, into --out-dir")
+	outDir := fs.String("out-dir", "", "directory --refs-file writes content to, a file per reference")
+	concurrency := fs.Int("concurrency", 8, "references --refs-file fetches at once")
+	output := outputFlag(cmd, "", outputRaw, outputJSON, outputPretty)
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		lookup := *traceID != "" || *spanID != "" || *key != ""
+		batch := *refsFile != ""
+		if batch && (*outDir == "" || lookup || len(args) > 0 || *sig != "") {
+			return fmt.Errorf("usage: get (--config <file> | --endpoint <url>) --refs-file <file> --out-dir <dir>")
+		}
+		if (*configFile == "") == (*endpoint == "") || (!batch && (len(args) == 0) == !lookup) {
+			return fmt.Errorf("usage: get (--config <file> | --endpoint <url>) (<ref>... | --trace-id <id> --span-id <id> --key <key>)")
+		}
+		if lookup && (*traceID == "" || *spanID == "" || *key == "") {
+			return fmt.Errorf("--trace-id, --span-id, and --key must be given together")
+		}
+		if *sig != "" && len(args) > 1 {
+			return fmt.Errorf("--sig takes a single reference")
+		}
+		format, err := output()
+		if err != nil {
+			return err
+		}
+		clientCfg := promptvaultclient.Config{Endpoint: *endpoint}
+		logger := zap.NewNop()
+		if *configFile != "" {
+			cfg, l, err := loadVaultFileConfig(*configFile)
+			if err != nil {
+				return err
+			}
+			defer l.Sync()
+			clientCfg, logger = promptvaultclient.Config{Vault: cfg.Vault}, l
+		} else {
+			// Read from the environment, not a flag, to keep it out of process
+			// listings.
+			clientCfg.APIKey = configopaque.String(os.Getenv("PROMPTVAULT_API_KEY"))
+		}
+		// Overrides the config's vault.signing_key, and is the only way to
+		// verify signatures through the retrieval API.
+		clientCfg.SigningKey = configopaque.String(os.Getenv("PROMPTVAULT_SIGNING_KEY"))
+		clientCfg.RequireSignature = *requireSig
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+		ctx = promptvaultprocessor.WithPrincipal(ctx, principal())
+		c, err := promptvaultclient.New(ctx, logger, clientCfg)
+		if err != nil {
+			return err
+		}
+		defer c.Close()
+		if batch {
+			return getBatch(ctx, c, *refsFile, *outDir, *concurrency)
+		}
+		var refs []promptvaultclient.Reference
+		if lookup {
+			r, err := c.Lookup(ctx, *traceID, *spanID, *key)
+			if err != nil {
+				return err
+			}
+			refs = append(refs, r)
+		}
+		for _, ref := range args {
+			r, err := promptvaultclient.ParseReference(ref)
+			if err != nil {
+				return err
+			}
+			refs = append(refs, r)
+		}
+		for _, r := range refs {
+			if *sig != "" {
+				r.Sig = *sig
+			}
+			content, err := getReference(ctx, c, r)
+			if err != nil {
+				return err
+			}
+			switch format {
+			case outputRaw:
+				_, err = os.Stdout.Write(content)
+			case outputJSON:
+				err = printJSON(newContentJSON(r.Ref, content), false)
+			case outputPretty:
+				text, ok := readable(content)
+				if !ok {
+					return fmt.Errorf("%s is binary (%d bytes); use --output raw or json", r.Ref, len(content))
+				}
+				_, err = fmt.Printf("%s\n", bytes.TrimRight(text, "\n"))
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return cmd
+}
+
+// getReference retrieves r, explaining signature failures.
+func getReference(ctx context.Context, c *promptvaultclient.Client, r promptvaultclient.Reference) ([]byte, error) {
+	content, err := c.GetReference(ctx, r)
+	switch {
+	case errors.Is(err, promptvaultprocessor.ErrInvalidRefSignature):
+		return nil, fmt.Errorf("%s: signature does not match; the reference or its content has been tampered with, or was signed with another key", r.Ref)
+	case errors.Is(err, promptvaultclient.ErrUnsigned):
+		return nil, fmt.Errorf("%s: reference is not signed; pass its <key>.vault_sig with --sig", r.Ref)
+	}
+	return content, err
+}
+
+// getBatch fetches each reference in refsFile, in any form get accepts, into
+// a file in outDir named after the reference, n at a time. It prints a line
+// per file written and fails if any reference could not be fetched.
+func getBatch(ctx context.Context, c *promptvaultclient.Client, refsFile, outDir string, n int) error {
+	f, err := os.Open(refsFile)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	var refs []promptvaultclient.Reference
+	seen := make(map[string]bool)
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64<<10), 1<<20)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		r, err := promptvaultclient.ParseReference(text)
+		if err != nil {
+			return fmt.Errorf("%s:%d: %w", refsFile, line, err)
+		}
+		if !seen[r.Ref] {
+			seen[r.Ref] = true
+			refs = append(refs, r)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("read %s: %w", refsFile, err)
+	}
+	if err := os.MkdirAll(outDir, 0o700); err != nil {
+		return err
+	}
+	if n < 1 {
+		n = 1
+	}
+
+	jobs := make(chan promptvaultclient.Reference)
+	var mu sync.Mutex
+	var failed int
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for r := range jobs {
+				name := filepath.Join(outDir, strings.ReplaceAll(strings.TrimPrefix(r.Ref, "vault://"), "/", "_"))
+				content, err := getReference(ctx, c, r)
+				if err == nil {
+					err = os.WriteFile(name, content, 0o600)
+				}
+				mu.Lock()
+				if err != nil {
+					failed++
+					fmt.Fprintln(os.Stderr, err)
+				} else {
+					fmt.Printf("%s\t%s\n", name, r.Ref)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, r := range refs {
+		if ctx.Err() != nil {
+			break
+		}
+		jobs <- r
+	}
+	close(jobs)
+	wg.Wait()
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	fmt.Fprintf(os.Stderr, "fetched %d of %d references\n", len(refs)-failed, len(refs))
+	if failed > 0 {
+		return fmt.Errorf("%d references could not be fetched", failed)
+	}
+	return nil
+}
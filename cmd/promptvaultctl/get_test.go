@@ -0,0 +1,161 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/airblackbox/otel-prompt-vault/promptvaultclient"
+)
+
+// writeVaultConfig writes a config storing objects under a temporary
+// directory, signed with signingKey if set, and returns its path.
+func writeVaultConfig(t *testing.T, signingKey string) string {
+	t.Helper()
+	dir := t.TempDir()
+	config := "promptvault:\n" +
+		"  storage:\n" +
+		"    backend: filesystem\n" +
+		"    filesystem:\n" +
+		"      base_path: " + filepath.Join(dir, "vault") + "\n" +
+		"      write_metadata: true\n"
+	if signingKey != "" {
+		config += "  vault:\n    signing_key: " + signingKey + "\n"
+	}
+	path := filepath.Join(dir, "vault.yaml")
+	if err := os.WriteFile(path, []byte(config), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// put stores content with promptvaultctl put and returns the printed
+// reference.
+func put(t *testing.T, config string, content []byte, args ...string) promptvaultclient.Reference {
+	t.Helper()
+	file := filepath.Join(t.TempDir(), "content")
+	if err := os.WriteFile(file, content, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	out, err := run(t, append([]string{"put", "-config", config, "-file", file}, args...)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ref promptvaultclient.Reference
+	if err := json.Unmarshal([]byte(out), &ref); err != nil {
+		t.Fatalf("put printed %q: %v", out, err)
+	}
+	return ref
+}
+
+func TestPutGetRoundTrip(t *testing.T) {
+	config := writeVaultConfig(t, "")
+	content := []byte("what is the capital of France?")
+	ref := put(t, config, content, "-key", "gen_ai.prompt", "-trace-id", "t1", "-span-id", "s1")
+	if !strings.HasPrefix(ref.Ref, "vault://") || ref.Sig != "" {
+		t.Fatalf("put printed %+v", ref)
+	}
+
+	out, err := run(t, "get", "-config", config, ref.Ref)
+	if err != nil || out != string(content) {
+		t.Errorf("get %s = %q, %v; want the stored content", ref.Ref, out, err)
+	}
+	out, err = run(t, "get", "--config", config, "--trace-id", "t1", "--span-id", "s1", "--key", "gen_ai.prompt")
+	if err != nil || out != string(content) {
+		t.Errorf("get by trace, span, and key = %q, %v; want the stored content", out, err)
+	}
+	if _, err := run(t, "get", "--config", config, "--trace-id", "t1", "--key", "gen_ai.prompt"); err == nil {
+		t.Error("expected get to require --span-id with --trace-id and --key")
+	}
+
+	refsFile := filepath.Join(t.TempDir(), "refs")
+	if err := os.WriteFile(refsFile, []byte(ref.Ref+"\n\n"+ref.Ref+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	outDir := t.TempDir()
+	if _, err := run(t, "get", "--config", config, "--refs-file", refsFile, "--out-dir", outDir); err != nil {
+		t.Fatal(err)
+	}
+	entries, _ := os.ReadDir(outDir)
+	if len(entries) != 1 {
+		t.Fatalf("--refs-file wrote %d files, want one per distinct reference", len(entries))
+	}
+	if got, _ := os.ReadFile(filepath.Join(outDir, entries[0].Name())); string(got) != string(content) {
+		t.Errorf("--refs-file wrote %q", got)
+	}
+}
+
+func TestPutGetSigned(t *testing.T) {
+	config := writeVaultConfig(t, "a-signing-key-of-at-least-32-bytes!!")
+	ref := put(t, config, []byte("a signed prompt"), "-key", "gen_ai.prompt")
+	if ref.Sig == "" {
+		t.Fatal("put printed no signature")
+	}
+
+	// The signature may start with a dash; it must reach --sig intact.
+	out, err := run(t, "get", "-config", config, "-require-signature", "-sig", ref.Sig, ref.Ref)
+	if err != nil || out != "a signed prompt" {
+		t.Errorf("get with --sig = %q, %v", out, err)
+	}
+	if _, err := run(t, "get", "-config", config, "-require-signature", ref.Ref); err == nil ||
+		!strings.Contains(err.Error(), "not signed") {
+		t.Errorf("expected an unsigned reference to be refused, got %v", err)
+	}
+	forged := "-" + ref.Sig[1:]
+	if ref.Sig[0] == '-' {
+		forged = "A" + ref.Sig[1:]
+	}
+	if _, err := run(t, "get", "-config", config, "-sig", forged, ref.Ref); err == nil ||
+		!strings.Contains(err.Error(), "signature does not match") {
+		t.Errorf("expected a forged signature to be refused, got %v", err)
+	}
+}
+
+func TestGetOutputFormats(t *testing.T) {
+	config := writeVaultConfig(t, "")
+	text := put(t, config, []byte(`{"role":"user","content":"hi"}`), "-key", "gen_ai.prompt")
+	binary := put(t, config, []byte{0xff, 0xfe, 0x00, 0x01}, "-key", "gen_ai.image")
+
+	out, err := run(t, "get", "-config", config, "-output", "pretty", text.Ref)
+	if want := "{\n  \"role\": \"user\",\n  \"content\": \"hi\"\n}\n"; err != nil || out != want {
+		t.Errorf("--output pretty = %q, %v; want %q", out, err, want)
+	}
+
+	out, err = run(t, "get", "-config", config, "-output", "json", text.Ref, binary.Ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("--output json printed %q, want a line per reference", out)
+	}
+	var got []contentJSON
+	for _, line := range lines {
+		var c contentJSON
+		if err := json.Unmarshal([]byte(line), &c); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, c)
+	}
+	if got[0].Ref != text.Ref || got[0].Content != `{"role":"user","content":"hi"}` || got[0].ContentBase64 != nil {
+		t.Errorf("text content as json: %+v", got[0])
+	}
+	if got[1].Ref != binary.Ref || got[1].Size != 4 || got[1].Content != "" || string(got[1].ContentBase64) != "\xff\xfe\x00\x01" {
+		t.Errorf("binary content as json: %+v", got[1])
+	}
+
+	out, err = run(t, "get", "-config", config, binary.Ref)
+	if err != nil || out != "\xff\xfe\x00\x01" {
+		t.Errorf("--output raw = %q, %v; want the bytes as stored", out, err)
+	}
+	if _, err := run(t, "get", "-config", config, "-output", "pretty", binary.Ref); err == nil ||
+		!strings.Contains(err.Error(), "binary") {
+		t.Errorf("expected --output pretty to refuse binary content, got %v", err)
+	}
+	if _, err := run(t, "get", "-config", config, "-output", "yaml", text.Ref); err == nil ||
+		!strings.Contains(err.Error(), "--output must be one of") {
+		t.Errorf("expected an unknown format to be refused, got %v", err)
+	}
+}
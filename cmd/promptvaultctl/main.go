@@ -0,0 +1,163 @@
+// Command promptvaultctl inspects and maintains prompt vault storage.
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// envPrefix prefixes the environment variables that stand in for flags:
+// PROMPTVAULT_CONFIG for --config, PROMPTVAULT_AUDIT_LOG for --audit-log.
+const envPrefix = "PROMPTVAULT_"
+
+// logLevel is the --log-level every command's logger uses.
+var logLevel = zapcore.InfoLevel
+
+func main() {
+	root := newRootCommand()
+	root.SetArgs(longFlags(root, os.Args[1:]))
+	if cmd, err := root.ExecuteC(); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", cmd.CommandPath(), err)
+		os.Exit(1)
+	}
+}
+
+func newRootCommand() *cobra.Command {
+	root := &cobra.Command{
+		Use:   "promptvaultctl",
+		Short: "inspect and maintain prompt vault storage",
+		Long: `promptvaultctl inspects and maintains prompt vault storage.
+
+Every flag can also be set in the environment as ` + envPrefix + `<FLAG>,
+upper-cased with dashes as underscores, e.g. ` + envPrefix + `CONFIG for
+--config. Flags on the command line win.`,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		PersistentPreRunE: func(cmd *cobra.Command, _ []string) error {
+			return bindEnv(cmd.Flags())
+		},
+	}
+	root.PersistentFlags().Var((*levelFlag)(&logLevel), "log-level", "log level: debug, info, warn, or error")
+	root.RegisterFlagCompletionFunc("log-level", cobra.FixedCompletions(
+		[]string{"debug", "info", "warn", "error"}, cobra.ShellCompDirectiveNoFileComp))
+
+	root.AddCommand(
+		newBackupCommand(),
+		newBreakGlassCommand(),
+		newCheckRefsCommand(),
+		newCompactCommand(),
+		newDecryptCommand(),
+		newDUCommand(),
+		newEraseCommand(),
+		newEscrowCommand(),
+		newExportCommand(),
+		newGetCommand(),
+		newPrintConfigCommand(),
+		newPurgeCommand(),
+		newPutCommand(),
+		newRedactCommand(),
+		newReEncryptCommand(),
+		newReindexCommand(),
+		newReplayCommand(),
+		newRestoreCommand(),
+		newRetentionCommand(),
+		newSearchCommand(),
+		newServeCommand(),
+		newStatsCommand(),
+		newSyncCommand(),
+		newTraceCommand(),
+		newVerifyManifestCommand(),
+		newVerifyReceiptCommand(),
+		newWatchCommand(),
+	)
+	for _, cmd := range root.Commands() {
+		if cmd.Flags().Lookup("config") != nil {
+			cmd.MarkFlagFilename("config", "yaml", "yml")
+		}
+	}
+	return root
+}
+
+// bindEnv sets each flag not given on the command line from its
+// environment variable, if set.
+func bindEnv(fs *pflag.FlagSet) error {
+	var err error
+	fs.VisitAll(func(f *pflag.Flag) {
+		if f.Changed || f.Name == "help" || err != nil {
+			return
+		}
+		name := envPrefix + strings.ToUpper(strings.ReplaceAll(f.Name, "-", "_"))
+		if v, ok := os.LookupEnv(name); ok {
+			if serr := fs.Set(f.Name, v); serr != nil {
+				err = fmt.Errorf("%s: %w", name, serr)
+			}
+		}
+	})
+	return err
+}
+
+// longFlags rewrites single-dash long flags, as in -config, to the
+// double-dash form, so invocations written for the CLI's earlier flag
+// parsing keep working. Only names of flags registered somewhere under
+// root are rewritten, and never a flag's value, so a value starting with a
+// dash, such as a base64url signature, is passed through. Arguments after
+// "--" are left alone.
+func longFlags(root *cobra.Command, args []string) []string {
+	flags := make(map[string]*pflag.Flag)
+	var collect func(cmd *cobra.Command)
+	collect = func(cmd *cobra.Command) {
+		add := func(f *pflag.Flag) { flags[f.Name] = f }
+		cmd.InitDefaultHelpFlag()
+		cmd.PersistentFlags().VisitAll(add)
+		cmd.Flags().VisitAll(add)
+		for _, sub := range cmd.Commands() {
+			collect(sub)
+		}
+	}
+	collect(root)
+
+	out := make([]string, len(args))
+	copy(out, args)
+	for i := 0; i < len(out); i++ {
+		a := out[i]
+		if a == "--" {
+			break
+		}
+		if !strings.HasPrefix(a, "-") {
+			continue
+		}
+		name, _, hasValue := strings.Cut(strings.TrimLeft(a, "-"), "=")
+		f, ok := flags[name]
+		if !ok {
+			continue
+		}
+		if !strings.HasPrefix(a, "--") && len(name) > 1 {
+			out[i] = "-" + a
+		}
+		if !hasValue && f.NoOptDefVal == "" {
+			// The next argument is this flag's value.
+			i++
+		}
+	}
+	return out
+}
+
+// levelFlag is a zapcore.Level usable as a pflag.Value.
+type levelFlag zapcore.Level
+
+func (l *levelFlag) String() string     { return (*zapcore.Level)(l).String() }
+func (l *levelFlag) Set(s string) error { return (*zapcore.Level)(l).Set(s) }
+func (l *levelFlag) Type() string       { return "level" }
+
+// newLogger returns the production logger at --log-level.
+func newLogger() (*zap.Logger, error) {
+	cfg := zap.NewProductionConfig()
+	cfg.Level = zap.NewAtomicLevelAt(logLevel)
+	return cfg.Build()
+}
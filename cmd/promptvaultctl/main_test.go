@@ -0,0 +1,103 @@
+package main
+
+import (
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/spf13/pflag"
+)
+
+// run executes promptvaultctl with args as main does and returns what it
+// wrote to stdout.
+func run(t *testing.T, args ...string) (string, error) {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+	out := make(chan string)
+	go func() {
+		b, _ := io.ReadAll(r)
+		out <- string(b)
+	}()
+
+	root := newRootCommand()
+	root.SetArgs(longFlags(root, args))
+	err = root.Execute()
+	w.Close()
+	return <-out, err
+}
+
+func TestBindEnv(t *testing.T) {
+	t.Setenv("PROMPTVAULT_CONFIG", "env.yaml")
+	t.Setenv("PROMPTVAULT_TRACE_ID", "from-env")
+	t.Setenv("PROMPTVAULT_CONCURRENCY", "3")
+
+	fs := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	config := fs.String("config", "", "")
+	traceID := fs.String("trace-id", "", "")
+	concurrency := fs.Int("concurrency", 8, "")
+	key := fs.String("key", "default", "")
+	if err := fs.Parse([]string{"--trace-id", "from-flag"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := bindEnv(fs); err != nil {
+		t.Fatal(err)
+	}
+	if *config != "env.yaml" || *concurrency != 3 {
+		t.Errorf("config %q, concurrency %d; want them from the environment", *config, *concurrency)
+	}
+	if *traceID != "from-flag" {
+		t.Errorf("trace-id %q; the command line should win over the environment", *traceID)
+	}
+	if *key != "default" {
+		t.Errorf("key %q; unset variables should leave the default", *key)
+	}
+
+	t.Setenv("PROMPTVAULT_CONCURRENCY", "many")
+	fs = pflag.NewFlagSet("test", pflag.ContinueOnError)
+	fs.Int("concurrency", 8, "")
+	if err := bindEnv(fs); err == nil || !strings.Contains(err.Error(), "PROMPTVAULT_CONCURRENCY") {
+		t.Errorf("expected an error naming the variable, got %v", err)
+	}
+}
+
+func TestLongFlags(t *testing.T) {
+	root := newRootCommand()
+	for _, tt := range []struct {
+		args, want []string
+	}{
+		{
+			args: []string{"get", "-config", "vault.yaml", "-span-id", "00f067aa0ba902b7"},
+			want: []string{"get", "--config", "vault.yaml", "--span-id", "00f067aa0ba902b7"},
+		},
+		{
+			args: []string{"get", "-config=vault.yaml", "--output", "json", "-require-signature", "-help"},
+			want: []string{"get", "--config=vault.yaml", "--output", "json", "--require-signature", "--help"},
+		},
+		{
+			// A value starting with a dash is the flag's, even if it looks
+			// like a flag name.
+			args: []string{"get", "-sig", "-AbC_d-e", "-config", "-key", "vault://x"},
+			want: []string{"get", "--sig", "-AbC_d-e", "--config", "-key", "vault://x"},
+		},
+		{
+			args: []string{"get", "-sig=-key", "-unknown", "-1", "-"},
+			want: []string{"get", "--sig=-key", "-unknown", "-1", "-"},
+		},
+		{
+			args: []string{"search", "--index", "i.db", "--", "-config"},
+			want: []string{"search", "--index", "i.db", "--", "-config"},
+		},
+	} {
+		if got := longFlags(root, tt.args); !slices.Equal(got, tt.want) {
+			t.Errorf("longFlags(%q) = %q, want %q", tt.args, got, tt.want)
+		}
+	}
+}
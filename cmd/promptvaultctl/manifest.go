@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"go.opentelemetry.io/collector/config/configopaque"
+
+	"github.com/airblackbox/otel-prompt-vault/processor/promptvaultprocessor"
+)
+
+func newVerifyManifestCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "verify-manifest",
+		Short: "verify the object manifest hash chain and signatures",
+	}
+	fs := cmd.Flags()
+	dir := fs.String("dir", "", "manifest directory (required)")
+	basePath := fs.String("base-path", "", "filesystem vault base path; when set, every live object is checked too")
+	layout := fs.String("layout", "date", "filesystem vault layout: date or sharded")
+	hmacKeyEnv := fs.String("hmac-key-env", "", "environment variable holding the HMAC signing key")
+	publicKey := fs.String("public-key", "", "PEM Ed25519 public key, for ed25519-signed manifests")
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		if *dir == "" {
+			return fmt.Errorf("--dir is required")
+		}
+		var key promptvaultprocessor.ManifestKey
+		var err error
+		switch {
+		case *publicKey != "":
+			key, err = promptvaultprocessor.LoadEd25519ManifestKey(*publicKey)
+		case *hmacKeyEnv != "":
+			key, err = promptvaultprocessor.NewHMACManifestKey(configopaque.String(os.Getenv(*hmacKeyEnv)))
+		default:
+			return fmt.Errorf("one of --public-key or --hmac-key-env is required")
+		}
+		if err != nil {
+			return err
+		}
+
+		var vault promptvaultprocessor.VaultStorage
+		if *basePath != "" {
+			fsVault, err := promptvaultprocessor.NewFilesystemVault(promptvaultprocessor.FilesystemConfig{BasePath: *basePath, Layout: *layout})
+			if err != nil {
+				return err
+			}
+			defer closeVault(fsVault)
+			vault = fsVault
+		}
+
+		report, err := promptvaultprocessor.VerifyManifest(context.Background(), *dir, key, vault)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%d entries in %d files verified; head seq %d %s\n", report.Entries, report.Files, report.LastSeq, report.Head)
+		for _, ref := range report.Missing {
+			fmt.Printf("missing    %s\n", ref)
+		}
+		for _, ref := range report.Mismatched {
+			fmt.Printf("mismatched %s\n", ref)
+		}
+		if n := len(report.Missing) + len(report.Mismatched); n > 0 {
+			return fmt.Errorf("%d objects missing or changed", n)
+		}
+		return nil
+	}
+	return cmd
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/spf13/cobra"
+)
+
+// Formats for a command's --output flag.
+const (
+	// outputTable prints aligned columns for people.
+	outputTable = "table"
+	// outputJSON prints compact JSON, one document or object per line, for jq.
+	outputJSON = "json"
+	// outputPretty prints indented JSON, or content formatted for reading.
+	outputPretty = "pretty"
+	// outputRaw writes content bytes exactly as retrieved.
+	outputRaw = "raw"
+)
+
+// outputFlag registers --output on cmd, accepting and completing formats
+// with the first as the default. If legacy is set, the command's older
+// --json flag is kept as an alias for that format. The returned function
+// reads the choice once the flags are parsed.
+func outputFlag(cmd *cobra.Command, legacy string, formats ...string) func() (string, error) {
+	output := cmd.Flags().String("output", formats[0], "output format: "+strings.Join(formats, ", "))
+	cmd.RegisterFlagCompletionFunc("output", cobra.FixedCompletions(formats, cobra.ShellCompDirectiveNoFileComp))
+	var asJSON *bool
+	if legacy != "" {
+		asJSON = cmd.Flags().Bool("json", false, "same as --output "+legacy)
+	}
+	return func() (string, error) {
+		if asJSON != nil && *asJSON {
+			return legacy, nil
+		}
+		if !slices.Contains(formats, *output) {
+			return "", fmt.Errorf("--output must be one of %s", strings.Join(formats, ", "))
+		}
+		return *output, nil
+	}
+}
+
+// printJSON writes v to stdout as JSON, indented if pretty.
+func printJSON(v any, pretty bool) error {
+	enc := json.NewEncoder(os.Stdout)
+	if pretty {
+		enc.SetIndent("", "  ")
+	}
+	return enc.Encode(v)
+}
+
+// readable formats content for --output pretty: JSON indented and UTF-8
+// text as is. It reports false for binary content.
+func readable(content []byte) ([]byte, bool) {
+	var buf bytes.Buffer
+	if json.Indent(&buf, content, "", "  ") == nil {
+		return buf.Bytes(), true
+	}
+	return content, utf8.Valid(content)
+}
+
+// contentJSON describes retrieved content for --output json: text as a
+// string, binary content base64-encoded.
+type contentJSON struct {
+	Ref           string `json:"ref"`
+	Size          int    `json:"size"`
+	SHA256        string `json:"sha256"`
+	Content       string `json:"content,omitempty"`
+	ContentBase64 []byte `json:"content_base64,omitempty"`
+}
+
+func newContentJSON(ref string, content []byte) contentJSON {
+	c := contentJSON{Ref: ref, Size: len(content), SHA256: fmt.Sprintf("%x", sha256.Sum256(content))}
+	if utf8.Valid(content) {
+		c.Content = string(content)
+	} else {
+		c.ContentBase64 = content
+	}
+	return c
+}
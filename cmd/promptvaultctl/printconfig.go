@@ -0,0 +1,25 @@
+package main
+
+import (
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/airblackbox/otel-prompt-vault/processor/promptvaultprocessor"
+)
+
+func newPrintConfigCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "print-config",
+		Short: "print a sample promptvault processor config with every setting's default and documentation",
+		Args:  cobra.NoArgs,
+	}
+	backends := promptvaultprocessor.SampleBackends()
+	backend := cmd.Flags().String("backend", "filesystem", "storage backend to include settings for: "+strings.Join(backends, ", "))
+	cmd.RegisterFlagCompletionFunc("backend", cobra.FixedCompletions(backends, cobra.ShellCompDirectiveNoFileComp))
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		return promptvaultprocessor.WriteSampleConfig(os.Stdout, *backend)
+	}
+	return cmd
+}
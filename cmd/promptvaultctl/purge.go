@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/airblackbox/otel-prompt-vault/processor/promptvaultprocessor"
+)
+
+func newPurgeCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "purge",
+		Short: "delete every object stored for an end user and report what was deleted",
+	}
+	fs := cmd.Flags()
+	configFile := fs.String("config", "", "YAML config with a promptvault section and storage.index (required)")
+	endUser := fs.String("enduser-id", "", "purge every object stored for this end user (required)")
+	ticket := fs.String("ticket", "", "the data subject request this purge serves, recorded in the report")
+	receiptKey := fs.String("receipt-key", "", "PEM Ed25519 private key that signs the report")
+	dryRun := fs.Bool("dry-run", false, "report what would be deleted without deleting it")
+	out := fs.String("out", "", "write the report here instead of stdout")
+	output := outputFlag(cmd, "", outputTable, outputJSON, outputPretty)
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		if *configFile == "" || *endUser == "" {
+			return fmt.Errorf("usage: purge --config <file> --enduser-id <id>")
+		}
+		format, err := output()
+		if err != nil {
+			return err
+		}
+		var key promptvaultprocessor.ManifestKey
+		if *receiptKey != "" {
+			if key, err = promptvaultprocessor.LoadEd25519ManifestKey(*receiptKey); err != nil {
+				return err
+			}
+		}
+		cfg, logger, err := loadVaultFileConfig(*configFile)
+		if err != nil {
+			return err
+		}
+		defer logger.Sync()
+		// Without an index, finding a user's objects means reading the
+		// metadata of every object; erase does that.
+		if cfg.Vault.Storage.Index.Path == "" {
+			return fmt.Errorf("purge needs storage.index; use erase to scan the backend instead")
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		ctx = promptvaultprocessor.WithPrincipal(ctx, principal())
+		// The full stack, so content search entries are deleted too.
+		vault, err := promptvaultprocessor.OpenVault(ctx, logger, cfg.Vault)
+		if err != nil {
+			return err
+		}
+		defer closeVault(vault)
+
+		req := promptvaultprocessor.ErasureRequest{EndUser: *endUser, Ticket: *ticket}
+		var receipt promptvaultprocessor.ErasureReceipt
+		if *dryRun {
+			receipt = promptvaultprocessor.ErasureReceipt{
+				Request: req, Principal: principal(), Time: time.Now().UTC(), Deleted: []promptvaultprocessor.ErasedObject{},
+			}
+			err = promptvaultprocessor.SearchObjects(ctx, vault, promptvaultprocessor.ObjectFilter{EndUser: *endUser}, func(info promptvaultprocessor.ObjectInfo) error {
+				receipt.Deleted = append(receipt.Deleted, promptvaultprocessor.ErasedObject{
+					Ref: info.Ref, Key: info.Meta.Key, TraceID: info.Meta.TraceID, SpanID: info.Meta.SpanID, Size: info.Size,
+				})
+				return nil
+			})
+		} else {
+			receipt, err = promptvaultprocessor.Erase(ctx, vault, req, nil)
+		}
+		if err != nil {
+			return err
+		}
+		// A dry run's report is not signed: it proves nothing was deleted.
+		if key != nil && !*dryRun {
+			if err := receipt.Sign(key); err != nil {
+				return err
+			}
+		}
+
+		w := io.Writer(os.Stdout)
+		if *out != "" {
+			f, err := os.OpenFile(*out, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			w = f
+		}
+		if err := writePurgeReport(w, receipt, format, *dryRun); err != nil {
+			return err
+		}
+		verb := "deleted"
+		if *dryRun {
+			verb = "would delete"
+		}
+		fmt.Fprintf(os.Stderr, "%s %d objects (%d bytes), failed %d\n", verb, len(receipt.Deleted), purgedBytes(receipt), len(receipt.Failed))
+		if len(receipt.Failed) > 0 {
+			return fmt.Errorf("%d matching objects could not be deleted", len(receipt.Failed))
+		}
+		return nil
+	}
+	return cmd
+}
+
+// writePurgeReport writes receipt as JSON, which verify-receipt accepts when
+// signed, or as a table for attaching to the request's ticket.
+func writePurgeReport(w io.Writer, receipt promptvaultprocessor.ErasureReceipt, format string, dryRun bool) error {
+	switch format {
+	case outputJSON, outputPretty:
+		var body []byte
+		var err error
+		if format == outputPretty {
+			body, err = json.MarshalIndent(receipt, "", "  ")
+		} else {
+			body, err = json.Marshal(receipt)
+		}
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(append(body, '\n'))
+		return err
+	}
+
+	traces := make(map[string]bool)
+	for _, obj := range receipt.Deleted {
+		traces[obj.TraceID] = true
+	}
+	title := "Deletion report"
+	if dryRun {
+		title += " (dry run, nothing deleted)"
+	}
+	fmt.Fprintf(w, "%s\n\n", title)
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	if receipt.Request.Ticket != "" {
+		fmt.Fprintf(tw, "Ticket:\t%s\n", receipt.Request.Ticket)
+	}
+	fmt.Fprintf(tw, "End user:\t%s\n", receipt.Request.EndUser)
+	fmt.Fprintf(tw, "Performed by:\t%s\n", receipt.Principal)
+	fmt.Fprintf(tw, "Time:\t%s\n", receipt.Time.Format(time.RFC3339))
+	fmt.Fprintf(tw, "Objects:\t%d in %d traces, %d bytes\n", len(receipt.Deleted), len(traces), purgedBytes(receipt))
+	if len(receipt.Failed) > 0 {
+		fmt.Fprintf(tw, "Failed:\t%d\n", len(receipt.Failed))
+	}
+	if receipt.Sig != "" {
+		fmt.Fprintf(tw, "Signature:\t%s\n", receipt.Sig)
+	}
+	tw.Flush()
+	if len(receipt.Deleted) > 0 {
+		fmt.Fprintln(w)
+		fmt.Fprintln(tw, "TRACE\tSPAN\tKEY\tSIZE\tREF")
+		for _, obj := range receipt.Deleted {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%s\n", obj.TraceID, obj.SpanID, obj.Key, obj.Size, obj.Ref)
+		}
+		tw.Flush()
+	}
+	for _, ref := range receipt.Failed {
+		fmt.Fprintf(w, "FAILED %s\n", ref)
+	}
+	return nil
+}
+
+func purgedBytes(receipt promptvaultprocessor.ErasureReceipt) int64 {
+	var n int64
+	for _, obj := range receipt.Deleted {
+		n += obj.Size
+	}
+	return n
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+
+	"github.com/spf13/cobra"
+
+	"github.com/airblackbox/otel-prompt-vault/processor/promptvaultprocessor"
+	"github.com/airblackbox/otel-prompt-vault/promptvaultclient"
+)
+
+func newPutCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "put",
+		Short: "store content under a trace, span, and key and print its reference",
+	}
+	fs := cmd.Flags()
+	configFile := fs.String("config", "", "YAML config with a promptvault section (required)")
+	file := fs.String("file", "-", "file holding the content; - reads stdin")
+	key := fs.String("key", "", "attribute key the content belongs to, e.g. gen_ai.prompt (required)")
+	traceID := fs.String("trace-id", "", "trace the content belongs to")
+	spanID := fs.String("span-id", "", "span the content belongs to")
+	service := fs.String("service", "", "service that emitted the span")
+	tenant := fs.String("tenant", "", "tenant the content belongs to")
+	endUser := fs.String("enduser-id", "", "end user the content was stored for")
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		if *configFile == "" || *key == "" {
+			return fmt.Errorf("usage: put --config <file> --key <attribute key> [--trace-id <id> --span-id <id>] [--file <path>]")
+		}
+		var content []byte
+		var err error
+		if *file == "-" {
+			content, err = io.ReadAll(os.Stdin)
+		} else {
+			content, err = os.ReadFile(*file)
+		}
+		if err != nil {
+			return err
+		}
+		cfg, logger, err := loadVaultFileConfig(*configFile)
+		if err != nil {
+			return err
+		}
+		defer logger.Sync()
+		signer, err := promptvaultprocessor.NewRefSigner(cfg.Vault.Vault.SigningKey)
+		if err != nil {
+			return err
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+		ctx = promptvaultprocessor.WithPrincipal(ctx, principal())
+		vault, err := promptvaultprocessor.OpenVault(ctx, logger, cfg.Vault)
+		if err != nil {
+			return err
+		}
+		defer closeVault(vault)
+		// The processor's redaction and policy hook are not applied.
+		ref, err := vault.Store(ctx, promptvaultprocessor.ObjectMeta{
+			TraceID: *traceID,
+			SpanID:  *spanID,
+			Key:     *key,
+			Service: *service,
+			Tenant:  *tenant,
+			EndUser: *endUser,
+		}, content)
+		if err != nil {
+			return err
+		}
+		out := promptvaultclient.Reference{Ref: ref}
+		if signer != nil {
+			out.Sig = signer.Sign(ref, fmt.Sprintf("%x", sha256.Sum256(content)), len(content))
+		}
+		return printJSON(out, false)
+	}
+	return cmd
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+
+	"github.com/airblackbox/otel-prompt-vault/processor/promptvaultprocessor"
+)
+
+// redactResultJSON is a line of `redact --output json`.
+type redactResultJSON struct {
+	Ref      string         `json:"ref"`
+	Matches  map[string]int `json:"matches,omitempty"`
+	Original string         `json:"original,omitempty"`
+}
+
+func newRedactCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "redact <ref>...",
+		Short: "mask sensitive data in stored objects, rewriting them in place",
+	}
+	fs := cmd.Flags()
+	configFile := fs.String("config", "", "YAML config with a promptvault section (required)")
+	detectors := fs.String("detectors", "", "comma-separated built-in detectors: email, phone, credit_card, ssn")
+	patterns := fs.StringArray("pattern", nil, "a regular expression to mask; repeat for more")
+	traceID := fs.String("trace-id", "", "redact every object stored for this trace")
+	endUser := fs.String("enduser-id", "", "redact every object stored for this end user")
+	keepOriginal := fs.Bool("keep-original", false, "keep each original under its key with .unredacted appended")
+	dryRun := fs.Bool("dry-run", false, "count matches without rewriting anything")
+	output := outputFlag(cmd, "", outputTable, outputJSON)
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		if *configFile == "" || (len(args) == 0 && *traceID == "" && *endUser == "") {
+			return fmt.Errorf("usage: redact --config <file> (--trace-id <id> | --enduser-id <id> | <ref>...)")
+		}
+		format, err := output()
+		if err != nil {
+			return err
+		}
+		cfg, logger, err := loadVaultFileConfig(*configFile)
+		if err != nil {
+			return err
+		}
+		defer logger.Sync()
+
+		// Without flags, the config's own redaction settings apply.
+		rcfg := cfg.Vault.Redaction
+		if *detectors != "" || len(*patterns) > 0 {
+			rcfg = promptvaultprocessor.RedactionConfig{Patterns: *patterns}
+			for _, d := range strings.Split(*detectors, ",") {
+				switch strings.TrimSpace(d) {
+				case "":
+				case "email":
+					rcfg.Detectors.Email = true
+				case "phone":
+					rcfg.Detectors.Phone = true
+				case "credit_card":
+					rcfg.Detectors.CreditCard = true
+				case "ssn":
+					rcfg.Detectors.SSN = true
+				default:
+					return fmt.Errorf("unknown detector %q", d)
+				}
+			}
+		}
+		redactor, err := promptvaultprocessor.NewRedactor(rcfg)
+		if err != nil {
+			return err
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		ctx = promptvaultprocessor.WithPrincipal(ctx, principal())
+		refs := args
+		if *traceID != "" || *endUser != "" {
+			if refs, err = findRefs(ctx, logger, cfg.Vault, promptvaultprocessor.ObjectFilter{TraceID: *traceID, EndUser: *endUser}); err != nil {
+				return err
+			}
+			refs = append(refs, args...)
+		}
+
+		results, err := promptvaultprocessor.RedactObjects(ctx, logger, cfg.Vault, redactor, refs,
+			promptvaultprocessor.RedactOptions{KeepOriginal: *keepOriginal, DryRun: *dryRun})
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		if format == outputTable {
+			fmt.Fprintln(w, "REF\tMATCHES\tORIGINAL")
+		}
+		redacted := 0
+		for _, res := range results {
+			if len(res.Matches) > 0 {
+				redacted++
+			}
+			if format == outputJSON {
+				if err := printJSON(redactResultJSON{Ref: res.Ref, Matches: res.Matches, Original: res.Original}, false); err != nil {
+					return err
+				}
+				continue
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\n", res.Ref, formatMatches(res.Matches), res.Original)
+		}
+		w.Flush()
+		verb := "redacted"
+		if *dryRun {
+			verb = "would redact"
+		}
+		fmt.Fprintf(os.Stderr, "%s %d of %d objects\n", verb, redacted, len(results))
+		return err
+	}
+	return cmd
+}
+
+// findRefs returns the distinct references of the objects matching f.
+func findRefs(ctx context.Context, logger *zap.Logger, cfg *promptvaultprocessor.Config, f promptvaultprocessor.ObjectFilter) ([]string, error) {
+	vault, err := promptvaultprocessor.OpenStoredVault(ctx, logger, cfg)
+	if err != nil {
+		return nil, err
+	}
+	defer closeVault(vault)
+	seen := make(map[string]bool)
+	var refs []string
+	err = promptvaultprocessor.SearchObjects(ctx, vault, f, func(info promptvaultprocessor.ObjectInfo) error {
+		if !seen[info.Ref] {
+			seen[info.Ref] = true
+			refs = append(refs, info.Ref)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(refs) == 0 {
+		return nil, fmt.Errorf("no objects match")
+	}
+	return refs, nil
+}
+
+// formatMatches renders per-detector counts as "email 2, ssn 1", or "-".
+func formatMatches(m map[string]int) string {
+	if len(m) == 0 {
+		return "-"
+	}
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	parts := make([]string, len(names))
+	for i, name := range names {
+		parts[i] = fmt.Sprintf("%s %d", name, m[name])
+	}
+	return strings.Join(parts, ", ")
+}
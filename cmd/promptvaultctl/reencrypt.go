@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+
+	"github.com/spf13/cobra"
+
+	"github.com/airblackbox/otel-prompt-vault/processor/promptvaultprocessor"
+)
+
+func newReEncryptCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "reencrypt",
+		Short: "re-encrypt every object under the current key",
+	}
+	fs := cmd.Flags()
+	basePath := fs.String("base-path", "/data/vault", "filesystem vault base path")
+	layout := fs.String("layout", "date", "filesystem vault layout: date or sharded")
+	fromKeyring := fs.String("from-keyring", "", "keyring file holding the old keys (required)")
+	toKeyring := fs.String("to-keyring", "", "keyring file holding the new key (required)")
+	toKey := fs.String("to-key", "", "key ID in --to-keyring to encrypt with (default: its first key)")
+	journal := fs.String("journal", "reencrypt.journal", "progress journal; rerun with the same journal to resume")
+	auditLog := fs.String("audit-log", "", "append a JSON line per object read to this audit log")
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		if *fromKeyring == "" || *toKeyring == "" {
+			return fmt.Errorf("--from-keyring and --to-keyring are required")
+		}
+		from, err := promptvaultprocessor.NewKeyring(promptvaultprocessor.LocalKeysConfig{KeyringFile: *fromKeyring})
+		if err != nil {
+			return err
+		}
+		to, err := promptvaultprocessor.NewKeyring(promptvaultprocessor.LocalKeysConfig{KeyringFile: *toKeyring, ActiveKey: *toKey})
+		if err != nil {
+			return err
+		}
+		fsVault, err := promptvaultprocessor.NewFilesystemVault(promptvaultprocessor.FilesystemConfig{BasePath: *basePath, Layout: *layout})
+		if err != nil {
+			return err
+		}
+		vault, err := withAudit(fsVault, *auditLog)
+		if err != nil {
+			return err
+		}
+		defer closeVault(vault)
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+		ctx = promptvaultprocessor.WithPrincipal(ctx, principal())
+
+		stats, err := promptvaultprocessor.ReEncrypt(ctx, vault, from, to, promptvaultprocessor.ReEncryptOptions{
+			JournalPath: *journal,
+			SkipKeyID:   to.ActiveKeyID(),
+			Progress: func(_ string, s promptvaultprocessor.ReEncryptStats) {
+				if s.Scanned%1000 == 0 {
+					fmt.Fprintf(os.Stderr, "scanned %d, re-encrypted %d, skipped %d, failed %d\n", s.Scanned, s.ReEncrypted, s.Skipped, s.Failed)
+				}
+			},
+		})
+		fmt.Fprintf(os.Stderr, "scanned %d, re-encrypted %d, skipped %d, failed %d\n", stats.Scanned, stats.ReEncrypted, stats.Skipped, stats.Failed)
+		if err != nil {
+			return err
+		}
+		if stats.Failed > 0 {
+			return fmt.Errorf("%d objects failed; see %s", stats.Failed, *journal)
+		}
+		return nil
+	}
+	return cmd
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/spf13/cobra"
+
+	"github.com/airblackbox/otel-prompt-vault/processor/promptvaultprocessor"
+)
+
+func newReindexCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "reindex",
+		Short: "rebuild the metadata index from the storage backend",
+	}
+	fs := cmd.Flags()
+	configFile := fs.String("config", "", "YAML config with a promptvault section setting storage.index (required)")
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		if *configFile == "" {
+			return fmt.Errorf("--config is required")
+		}
+		cfg := &vaultFileConfig{
+			Vault: promptvaultprocessor.NewFactory().CreateDefaultConfig().(*promptvaultprocessor.Config),
+		}
+		if err := loadConfig(*configFile, cfg); err != nil {
+			return err
+		}
+		if err := promptvaultprocessor.CheckSecretsRedacted(cfg); err != nil {
+			return err
+		}
+
+		logger, err := newLogger()
+		if err != nil {
+			return err
+		}
+		defer logger.Sync()
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		n, err := promptvaultprocessor.RebuildIndex(ctx, logger, cfg.Vault)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "indexed %d objects\n", n)
+		return nil
+	}
+	return cmd
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/spf13/cobra"
+	"go.opentelemetry.io/collector/consumer"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/plog/plogotlp"
+	"go.uber.org/zap"
+
+	"github.com/airblackbox/otel-prompt-vault/receiver/promptvaultreplayreceiver"
+)
+
+// replayConfig is the file read by `promptvaultctl replay`.
+type replayConfig struct {
+	Replay *promptvaultreplayreceiver.Config `mapstructure:"promptvaultreplay"`
+}
+
+func newReplayCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "replay",
+		Short: "replay vault content as OTLP logs",
+	}
+	fs := cmd.Flags()
+	configFile := fs.String("config", "", "YAML config with a promptvaultreplay section (required)")
+	endpoint := fs.String("endpoint", "", "OTLP/HTTP logs URL, e.g. http://localhost:4318/v1/logs (default: write OTLP JSON lines to stdout)")
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		if *configFile == "" {
+			return fmt.Errorf("--config is required")
+		}
+		cfg := &replayConfig{Replay: promptvaultreplayreceiver.DefaultConfig()}
+		if err := loadConfig(*configFile, cfg); err != nil {
+			return err
+		}
+
+		var next consumer.Logs
+		if *endpoint != "" {
+			next = otlpHTTPLogs(*endpoint)
+		} else {
+			next = jsonLogs(os.Stdout)
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+		r, err := promptvaultreplayreceiver.NewReceiver(ctx, zap.NewNop(), principal(), cfg.Replay, next)
+		if err != nil {
+			return err
+		}
+		defer r.Shutdown(context.Background())
+
+		stats, err := r.Replay(ctx)
+		fmt.Fprintf(os.Stderr, "replayed %d, failed %d\n", stats.Replayed, stats.Failed)
+		return err
+	}
+	return cmd
+}
+
+// jsonLogs writes each batch to w as one line of OTLP JSON.
+func jsonLogs(w io.Writer) consumer.Logs {
+	var m plog.JSONMarshaler
+	next, _ := consumer.NewLogs(func(_ context.Context, ld plog.Logs) error {
+		data, err := m.MarshalLogs(ld)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(append(data, '\n'))
+		return err
+	})
+	return next
+}
+
+// otlpHTTPLogs posts each batch to url as an OTLP/HTTP protobuf request.
+func otlpHTTPLogs(url string) consumer.Logs {
+	client := &http.Client{Timeout: 30 * time.Second}
+	next, _ := consumer.NewLogs(func(ctx context.Context, ld plog.Logs) error {
+		body, err := plogotlp.NewExportRequestFromLogs(ld).MarshalProto()
+		if err != nil {
+			return err
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/x-protobuf")
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		io.Copy(io.Discard, resp.Body)
+		if resp.StatusCode/100 != 2 {
+			return fmt.Errorf("export logs: %s", resp.Status)
+		}
+		return nil
+	})
+	return next
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/spf13/cobra"
+
+	"github.com/airblackbox/otel-prompt-vault/processor/promptvaultprocessor"
+)
+
+// retentionConfig is the file read by `promptvaultctl retention`.
+type retentionConfig struct {
+	Vault     *promptvaultprocessor.Config         `mapstructure:"promptvault"`
+	Retention promptvaultprocessor.RetentionConfig `mapstructure:"retention"`
+}
+
+func newRetentionCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "retention",
+		Short: "delete objects past their retention policy",
+	}
+	fs := cmd.Flags()
+	configFile := fs.String("config", "", "YAML config with promptvault and retention sections (required)")
+	once := fs.Bool("once", false, "sweep once and exit instead of every retention.interval")
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		if *configFile == "" {
+			return fmt.Errorf("--config is required")
+		}
+		cfg := &retentionConfig{
+			Vault: promptvaultprocessor.NewFactory().CreateDefaultConfig().(*promptvaultprocessor.Config),
+		}
+		if err := loadConfig(*configFile, cfg); err != nil {
+			return err
+		}
+		if err := promptvaultprocessor.CheckSecretsRedacted(cfg); err != nil {
+			return err
+		}
+		if len(cfg.Retention.Policies) == 0 {
+			return fmt.Errorf("retention.policies is empty")
+		}
+
+		logger, err := newLogger()
+		if err != nil {
+			return err
+		}
+		defer logger.Sync()
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		vault, err := promptvaultprocessor.OpenVault(ctx, logger, cfg.Vault)
+		if err != nil {
+			return err
+		}
+		defer closeVault(vault)
+		r, err := promptvaultprocessor.NewRetention(logger, nil, vault, cfg.Retention)
+		if err != nil {
+			return err
+		}
+
+		if *once {
+			stats, err := r.Sweep(ctx)
+			fmt.Fprintf(os.Stderr, "scanned %d, deleted %d (%d bytes), failed %d\n", stats.Scanned, stats.Deleted, stats.DeletedBytes, stats.Failed)
+			if err != nil {
+				return err
+			}
+			if stats.Failed > 0 {
+				return fmt.Errorf("%d expired objects could not be deleted", stats.Failed)
+			}
+			return nil
+		}
+
+		if err := r.Start(ctx); err != nil {
+			return err
+		}
+		<-ctx.Done()
+		return r.Close()
+	}
+	return cmd
+}
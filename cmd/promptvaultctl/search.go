@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/airblackbox/otel-prompt-vault/processor/promptvaultprocessor"
+)
+
+func newSearchCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "search <query>...",
+		Short: "search vaulted content in the local full-text index",
+	}
+	fs := cmd.Flags()
+	indexPath := fs.String("index", "", "content_search.path of the vault to search (required)")
+	limit := fs.Int("limit", 20, "maximum number of matches")
+	output := outputFlag(cmd, "", outputTable, outputJSON)
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		if *indexPath == "" || len(args) == 0 {
+			return fmt.Errorf("usage: search --index <path> <query>")
+		}
+		format, err := output()
+		if err != nil {
+			return err
+		}
+		if _, err := os.Stat(*indexPath); err != nil {
+			return err
+		}
+		ctx := context.Background()
+		index, err := promptvaultprocessor.OpenContentIndex(ctx, *indexPath)
+		if err != nil {
+			return err
+		}
+		defer index.Close()
+
+		matches, err := index.Search(ctx, strings.Join(args, " "), *limit)
+		if err != nil {
+			return err
+		}
+		if format == outputJSON {
+			for _, m := range matches {
+				err := printJSON(map[string]any{
+					"ref":       m.Ref,
+					"stored_at": m.StoredAt.UTC(),
+					"trace_id":  m.Meta.TraceID,
+					"span_id":   m.Meta.SpanID,
+					"key":       m.Meta.Key,
+					"snippet":   m.Snippet,
+				}, false)
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "STORED\tTRACE\tSPAN\tKEY\tREF\tSNIPPET")
+		for _, m := range matches {
+			snippet := strings.Join(strings.Fields(m.Snippet), " ")
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", m.StoredAt.UTC().Format(time.RFC3339), m.Meta.TraceID, m.Meta.SpanID, m.Meta.Key, m.Ref, snippet)
+		}
+		return w.Flush()
+	}
+	return cmd
+}
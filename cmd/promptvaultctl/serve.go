@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"regexp"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/go-viper/mapstructure/v2"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+
+	"github.com/airblackbox/otel-prompt-vault/processor/promptvaultprocessor"
+	"github.com/airblackbox/otel-prompt-vault/retrieval"
+)
+
+// serveConfig is the file read by `promptvaultctl serve`.
+type serveConfig struct {
+	// Vault is the processor's config block, so objects are read with the
+	// storage, encryption, and signing settings they were written with.
+	Vault     *promptvaultprocessor.Config `mapstructure:"promptvault"`
+	Retrieval retrieval.ServerConfig       `mapstructure:"retrieval"`
+}
+
+func newServeCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "serve",
+		Short: "serve the retrieval API",
+	}
+	fs := cmd.Flags()
+	configFile := fs.String("config", "", "YAML config with promptvault and retrieval sections (required)")
+	addr := fs.String("addr", "", "address to listen on, overriding retrieval.endpoint, e.g. :8080")
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		if *configFile == "" {
+			return fmt.Errorf("--config is required")
+		}
+		cfg, err := loadServeConfig(*configFile)
+		if err != nil {
+			return err
+		}
+		if err := promptvaultprocessor.CheckSecretsRedacted(cfg); err != nil {
+			return err
+		}
+		if *addr != "" {
+			cfg.Retrieval.Endpoint = *addr
+		}
+
+		logger, err := newLogger()
+		if err != nil {
+			return err
+		}
+		defer logger.Sync()
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		vault, err := promptvaultprocessor.OpenVault(ctx, logger, cfg.Vault)
+		if err != nil {
+			return err
+		}
+		signer, err := promptvaultprocessor.NewRefSigner(cfg.Vault.Vault.SigningKey)
+		if err != nil {
+			closeVault(vault)
+			return err
+		}
+		srv, err := retrieval.NewServer(ctx, logger, cfg.Retrieval, vault, signer)
+		if err != nil {
+			closeVault(vault)
+			return err
+		}
+		if err := srv.Start(ctx); err != nil {
+			srv.Shutdown(ctx)
+			return err
+		}
+
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		return srv.Shutdown(shutdownCtx)
+	}
+	return cmd
+}
+
+// loadServeConfig reads path over the processor defaults.
+func loadServeConfig(path string) (*serveConfig, error) {
+	cfg := &serveConfig{
+		Vault: promptvaultprocessor.NewFactory().CreateDefaultConfig().(*promptvaultprocessor.Config),
+	}
+	if err := loadConfig(path, cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// loadConfig decodes the YAML file at path into cfg, which holds the
+// defaults. ${env:NAME} and ${file:PATH} in string values are expanded as
+// the collector would.
+func loadConfig(path string, cfg any) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var raw map[string]any
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return fmt.Errorf("parse %s: %w", path, err)
+	}
+	if _, ok := raw["processors"]; ok {
+		if raw, err = collectorVaultSection(raw); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	expanded, err := expandProviders(raw)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	dec, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		Result:      cfg,
+		ErrorUnused: true,
+		DecodeHook: mapstructure.ComposeDecodeHookFunc(
+			mapstructure.StringToTimeDurationHookFunc(),
+			mapstructure.TextUnmarshallerHookFunc(),
+		),
+	})
+	if err != nil {
+		return err
+	}
+	if err := dec.Decode(expanded); err != nil {
+		return fmt.Errorf("decode %s: %w", path, err)
+	}
+	return nil
+}
+
+// collectorVaultSection turns a collector config into the CLI's own
+// layout, a promptvault section holding the promptvault processor's
+// settings, so the CLI reads objects exactly as the collector writes them.
+// With several promptvault processors, PROMPTVAULT_PROCESSOR names the one
+// to use, e.g. promptvault/prod.
+func collectorVaultSection(raw map[string]any) (map[string]any, error) {
+	procs, _ := raw["processors"].(map[string]any)
+	want := os.Getenv("PROMPTVAULT_PROCESSOR")
+	var ids []string
+	for id := range procs {
+		typ, _, _ := strings.Cut(id, "/")
+		if typ == "promptvault" && (want == "" || id == want) {
+			ids = append(ids, id)
+		}
+	}
+	switch {
+	case len(ids) == 0 && want != "":
+		return nil, fmt.Errorf("no processor %s in the collector config", want)
+	case len(ids) == 0:
+		return nil, fmt.Errorf("the collector config has no promptvault processor")
+	case len(ids) > 1:
+		sort.Strings(ids)
+		return nil, fmt.Errorf("the collector config has several promptvault processors (%s); set PROMPTVAULT_PROCESSOR to one", strings.Join(ids, ", "))
+	}
+	section := procs[ids[0]]
+	if section == nil {
+		// A processor configured with defaults only.
+		section = map[string]any{}
+	}
+	return map[string]any{"promptvault": section}, nil
+}
+
+var providerRef = regexp.MustCompile(`\$\{(env|file):([^}]+)\}`)
+
+// expandProviders resolves config provider references in every string.
+func expandProviders(v any) (any, error) {
+	switch v := v.(type) {
+	case string:
+		var err error
+		out := providerRef.ReplaceAllStringFunc(v, func(ref string) string {
+			m := providerRef.FindStringSubmatch(ref)
+			if m[1] == "env" {
+				return os.Getenv(m[2])
+			}
+			data, ferr := os.ReadFile(m[2])
+			if ferr != nil && err == nil {
+				err = ferr
+			}
+			return strings.TrimSpace(string(data))
+		})
+		return out, err
+	case map[string]any:
+		for k, val := range v {
+			out, err := expandProviders(val)
+			if err != nil {
+				return nil, err
+			}
+			v[k] = out
+		}
+	case []any:
+		for i, val := range v {
+			out, err := expandProviders(val)
+			if err != nil {
+				return nil, err
+			}
+			v[i] = out
+		}
+	}
+	return v, nil
+}
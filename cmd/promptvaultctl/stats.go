@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/airblackbox/otel-prompt-vault/processor/promptvaultprocessor"
+)
+
+func newStatsCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "stats",
+		Short: "summarize what the vault holds",
+	}
+	fs := cmd.Flags()
+	configFile := fs.String("config", "", "YAML config with a promptvault section (required)")
+	output := outputFlag(cmd, outputPretty, outputTable, outputJSON, outputPretty)
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		if *configFile == "" {
+			return fmt.Errorf("--config is required")
+		}
+		format, err := output()
+		if err != nil {
+			return err
+		}
+		cfg := &vaultFileConfig{
+			Vault: promptvaultprocessor.NewFactory().CreateDefaultConfig().(*promptvaultprocessor.Config),
+		}
+		if err := loadConfig(*configFile, cfg); err != nil {
+			return err
+		}
+		if err := promptvaultprocessor.CheckSecretsRedacted(cfg); err != nil {
+			return err
+		}
+
+		logger, err := newLogger()
+		if err != nil {
+			return err
+		}
+		defer logger.Sync()
+
+		ctx := context.Background()
+		vault, err := promptvaultprocessor.OpenVault(ctx, logger, cfg.Vault)
+		if err != nil {
+			return err
+		}
+		defer closeVault(vault)
+		stats, err := promptvaultprocessor.Stats(ctx, vault)
+		if err != nil {
+			return err
+		}
+
+		if format != outputTable {
+			return printJSON(stats, format == outputPretty)
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintf(w, "objects\t%d\n", stats.Objects)
+		fmt.Fprintf(w, "bytes\t%d\n", stats.Bytes)
+		if stats.Objects > 0 {
+			fmt.Fprintf(w, "encrypted\t%d objects (%.1f%%), %d bytes\n", stats.EncryptedObjects,
+				100*float64(stats.EncryptedObjects)/float64(stats.Objects), stats.EncryptedBytes)
+		}
+		if stats.Oldest != nil {
+			fmt.Fprintf(w, "oldest\t%s\n", stats.Oldest.Format(time.RFC3339))
+		}
+		for _, group := range []struct {
+			name   string
+			counts map[string]promptvaultprocessor.StatsCount
+		}{{"KEY", stats.ByKey}, {"SERVICE", stats.ByService}, {"TENANT", stats.ByTenant}} {
+			names := make([]string, 0, len(group.counts))
+			for name := range group.counts {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			fmt.Fprintf(w, "\n%s\tOBJECTS\tBYTES\n", group.name)
+			for _, name := range names {
+				c := group.counts[name]
+				if name == "" {
+					name = "-"
+				}
+				fmt.Fprintf(w, "%s\t%d\t%d\n", name, c.Objects, c.Bytes)
+			}
+		}
+		return w.Flush()
+	}
+	return cmd
+}
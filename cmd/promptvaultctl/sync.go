@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/spf13/cobra"
+
+	"github.com/airblackbox/otel-prompt-vault/processor/promptvaultprocessor"
+)
+
+// syncConfig is the file read by `promptvaultctl sync`.
+type syncConfig struct {
+	Source      *promptvaultprocessor.Config           `mapstructure:"source"`
+	Destination *promptvaultprocessor.Config           `mapstructure:"destination"`
+	Sync        promptvaultprocessor.ReplicationConfig `mapstructure:"sync"`
+}
+
+func newSyncCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "sync",
+		Short: "copy objects from one vault to another",
+	}
+	fs := cmd.Flags()
+	configFile := fs.String("config", "", "YAML config with source, destination, and sync sections (required)")
+	once := fs.Bool("once", false, "copy once and exit instead of every sync.interval")
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		if *configFile == "" {
+			return fmt.Errorf("--config is required")
+		}
+		factory := promptvaultprocessor.NewFactory()
+		cfg := &syncConfig{
+			Source:      factory.CreateDefaultConfig().(*promptvaultprocessor.Config),
+			Destination: factory.CreateDefaultConfig().(*promptvaultprocessor.Config),
+		}
+		if err := loadConfig(*configFile, cfg); err != nil {
+			return err
+		}
+		if err := promptvaultprocessor.CheckSecretsRedacted(cfg); err != nil {
+			return err
+		}
+
+		logger, err := newLogger()
+		if err != nil {
+			return err
+		}
+		defer logger.Sync()
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		src, err := promptvaultprocessor.OpenStoredVault(ctx, logger, cfg.Source)
+		if err != nil {
+			return fmt.Errorf("open source: %w", err)
+		}
+		defer closeVault(src)
+		dst, err := promptvaultprocessor.OpenStoredVault(ctx, logger, cfg.Destination)
+		if err != nil {
+			return fmt.Errorf("open destination: %w", err)
+		}
+		defer closeVault(dst)
+		r, err := promptvaultprocessor.NewReplication(logger, src, dst, cfg.Sync)
+		if err != nil {
+			return err
+		}
+
+		if *once {
+			stats, err := r.Run(ctx)
+			fmt.Fprintf(os.Stderr, "scanned %d, copied %d (%d bytes), already present %d, deleted %d, failed %d\n",
+				stats.Scanned, stats.Copied, stats.Bytes, stats.Present, stats.Deleted, stats.Failed)
+			if err != nil {
+				return err
+			}
+			if stats.Failed > 0 {
+				return fmt.Errorf("%d objects could not be copied", stats.Failed)
+			}
+			return nil
+		}
+
+		if err := r.Start(ctx); err != nil {
+			return err
+		}
+		<-ctx.Done()
+		return r.Close()
+	}
+	return cmd
+}
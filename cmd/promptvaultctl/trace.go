@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/airblackbox/otel-prompt-vault/processor/promptvaultprocessor"
+)
+
+// traceObjectJSON is a line of `trace --output json`.
+type traceObjectJSON struct {
+	Ref           string    `json:"ref"`
+	SpanID        string    `json:"span_id"`
+	Key           string    `json:"key"`
+	Size          int64     `json:"size"`
+	StoredAt      time.Time `json:"stored_at"`
+	Content       string    `json:"content,omitempty"`
+	ContentBase64 []byte    `json:"content_base64,omitempty"`
+}
+
+func newTraceCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "trace <trace-id>",
+		Short: "list, and optionally print, every object stored for a trace",
+	}
+	fs := cmd.Flags()
+	configFile := fs.String("config", "", "YAML config with a promptvault section (required)")
+	withContent := fs.Bool("content", false, "also print each object's decrypted content; implies --output pretty unless json")
+	output := outputFlag(cmd, outputJSON, outputTable, outputJSON, outputPretty)
+	auditLog := fs.String("audit-log", "", "append a JSON line per object read to this audit log")
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		if *configFile == "" || len(args) != 1 {
+			return fmt.Errorf("usage: trace --config <file> <trace-id>")
+		}
+		traceID := args[0]
+		format, err := output()
+		if err != nil {
+			return err
+		}
+		if *withContent && format == outputTable {
+			format = outputPretty
+		}
+		cfg, logger, err := loadVaultFileConfig(*configFile)
+		if err != nil {
+			return err
+		}
+		defer logger.Sync()
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+		ctx = promptvaultprocessor.WithPrincipal(ctx, principal())
+		opened, err := promptvaultprocessor.OpenVault(ctx, logger, cfg.Vault)
+		if err != nil {
+			return err
+		}
+		vault, err := withAudit(opened, *auditLog)
+		if err != nil {
+			closeVault(opened)
+			return err
+		}
+		defer closeVault(vault)
+
+		// The trace manifest or index answers directly; otherwise the backend
+		// is listed.
+		var infos []promptvaultprocessor.ObjectInfo
+		err = promptvaultprocessor.SearchObjects(ctx, vault, promptvaultprocessor.ObjectFilter{TraceID: traceID}, func(info promptvaultprocessor.ObjectInfo) error {
+			infos = append(infos, info)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		if len(infos) == 0 {
+			return fmt.Errorf("no objects stored for trace %s", traceID)
+		}
+		sort.SliceStable(infos, func(i, j int) bool { return infos[i].StoredAt.Before(infos[j].StoredAt) })
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		if format == outputTable {
+			fmt.Fprintln(w, "SPAN\tKEY\tSIZE\tSTORED\tREF")
+		}
+		for _, info := range infos {
+			var content []byte
+			if *withContent {
+				if content, err = vault.Retrieve(ctx, info.Ref); err != nil {
+					return fmt.Errorf("retrieve %s: %w", info.Ref, err)
+				}
+			}
+			switch format {
+			case outputJSON:
+				line := traceObjectJSON{
+					Ref:      info.Ref,
+					SpanID:   info.Meta.SpanID,
+					Key:      info.Meta.Key,
+					Size:     info.Size,
+					StoredAt: info.StoredAt.UTC(),
+				}
+				if content != nil {
+					c := newContentJSON(info.Ref, content)
+					line.Content, line.ContentBase64 = c.Content, c.ContentBase64
+				}
+				if err := printJSON(line, false); err != nil {
+					return err
+				}
+			case outputPretty:
+				fmt.Printf("== span %s  %s  %d bytes  %s  %s\n", info.Meta.SpanID, info.Meta.Key, info.Size,
+					info.StoredAt.UTC().Format(time.RFC3339), info.Ref)
+				if content != nil {
+					if text, ok := readable(content); ok {
+						fmt.Printf("%s\n\n", bytes.TrimRight(text, "\n"))
+					} else {
+						fmt.Printf("<binary, %d bytes>\n\n", len(content))
+					}
+				}
+			default:
+				fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%s\n", info.Meta.SpanID, info.Meta.Key, info.Size, info.StoredAt.UTC().Format(time.RFC3339), info.Ref)
+			}
+		}
+		return w.Flush()
+	}
+	return cmd
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+	"unicode/utf8"
+
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+
+	"github.com/airblackbox/otel-prompt-vault/processor/promptvaultprocessor"
+)
+
+// watchObjectJSON is a line of `watch --output json`.
+type watchObjectJSON struct {
+	Ref      string    `json:"ref"`
+	TraceID  string    `json:"trace_id"`
+	SpanID   string    `json:"span_id"`
+	Key      string    `json:"key"`
+	Service  string    `json:"service,omitempty"`
+	Tenant   string    `json:"tenant,omitempty"`
+	Size     int64     `json:"size"`
+	StoredAt time.Time `json:"stored_at"`
+	Preview  string    `json:"preview,omitempty"`
+}
+
+func newWatchCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "watch",
+		Aliases: []string{"tail"},
+		Short:   "follow the vault, printing objects as they are stored",
+	}
+	fs := cmd.Flags()
+	configFile := fs.String("config", "", "YAML config with a promptvault section (required)")
+	interval := fs.Duration("interval", 2*time.Second, "how often to poll the vault")
+	since := fs.Duration("since", 0, "also print objects stored within this long before starting")
+	traceID := fs.String("trace-id", "", "only print this trace's objects")
+	service := fs.String("service", "", "only print this service's objects")
+	tenant := fs.String("tenant", "", "only print this tenant's objects")
+	key := fs.String("key", "", "only print objects of this attribute key")
+	preview := fs.Int("preview", 0, "print the first N bytes of each object's decrypted content (0 = none)")
+	output := outputFlag(cmd, "", outputTable, outputJSON)
+	auditLog := fs.String("audit-log", "", "append a JSON line per object previewed to this audit log")
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		if *configFile == "" {
+			return fmt.Errorf("--config is required")
+		}
+		format, err := output()
+		if err != nil {
+			return err
+		}
+		cfg, logger, err := loadVaultFileConfig(*configFile)
+		if err != nil {
+			return err
+		}
+		defer logger.Sync()
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		ctx = promptvaultprocessor.WithPrincipal(ctx, principal())
+		opened, err := promptvaultprocessor.OpenVault(ctx, logger, cfg.Vault)
+		if err != nil {
+			return err
+		}
+		vault, err := withAudit(opened, *auditLog)
+		if err != nil {
+			closeVault(opened)
+			return err
+		}
+		defer closeVault(vault)
+
+		f := promptvaultprocessor.ObjectFilter{TraceID: *traceID, Service: *service, Tenant: *tenant, Key: *key}
+		if *since > 0 {
+			f.StoredAfter = time.Now().Add(-*since)
+		}
+		if format == outputTable {
+			fmt.Printf("%-20s  %-32s  %-16s  %-20s  %8s  %s\n", "STORED", "TRACE", "SPAN", "KEY", "SIZE", "REF")
+		}
+		// Polls go through storage.index when configured; without one, each
+		// poll lists the backend.
+		err = promptvaultprocessor.WatchObjects(ctx, vault, f, *interval, func(info promptvaultprocessor.ObjectInfo) error {
+			var text string
+			if *preview > 0 {
+				content, err := vault.Retrieve(ctx, info.Ref)
+				if err != nil {
+					// The object may have been evicted or erased since.
+					logger.Warn("preview failed", zap.String("ref", info.Ref), zap.Error(err))
+				} else {
+					text = previewText(content, *preview)
+				}
+			}
+			if format == outputJSON {
+				return printJSON(watchObjectJSON{
+					Ref:      info.Ref,
+					TraceID:  info.Meta.TraceID,
+					SpanID:   info.Meta.SpanID,
+					Key:      info.Meta.Key,
+					Service:  info.Meta.Service,
+					Tenant:   info.Meta.Tenant,
+					Size:     info.Size,
+					StoredAt: info.StoredAt.UTC(),
+					Preview:  text,
+				}, false)
+			}
+			fmt.Printf("%-20s  %-32s  %-16s  %-20s  %8d  %s\n", info.StoredAt.UTC().Format(time.RFC3339),
+				info.Meta.TraceID, info.Meta.SpanID, info.Meta.Key, info.Size, info.Ref)
+			if text != "" {
+				fmt.Printf("    %s\n", text)
+			}
+			return nil
+		})
+		if errors.Is(err, context.Canceled) {
+			return nil
+		}
+		return err
+	}
+	return cmd
+}
+
+// previewText returns up to n bytes of content on one line, or a
+// placeholder for binary content.
+func previewText(content []byte, n int) string {
+	if !utf8.Valid(content) {
+		return fmt.Sprintf("<binary, %d bytes>", len(content))
+	}
+	text := content
+	more := len(text) > n
+	if more {
+		text = text[:n]
+	}
+	s := strings.Join(strings.Fields(strings.ToValidUTF8(string(text), "")), " ")
+	if more {
+		s += "…"
+	}
+	return s
+}
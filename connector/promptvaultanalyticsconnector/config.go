@@ -0,0 +1,37 @@
+// Package promptvaultanalyticsconnector turns the content the promptvault
+// processor offloads into metrics: prompt and completion sizes per model and
+// service, the share of values that are vaulted, and how many kept copies
+// would be truncated. It reads traces before they reach the processor, so
+// usage dashboards keep working once the content has left the spans.
+package promptvaultanalyticsconnector
+
+import (
+	"github.com/airblackbox/otel-prompt-vault/processor/promptvaultprocessor"
+)
+
+// Config for the prompt analytics connector.
+type Config struct {
+	// Keys, Presets, and SizeThreshold select the attributes measured and
+	// which of them count as vaulted, as the settings of the same names
+	// under the processor's vault section. Match them to the processor.
+	Keys          []string                      `mapstructure:"keys"`
+	Presets       []string                      `mapstructure:"presets"`
+	SizeThreshold promptvaultprocessor.ByteSize `mapstructure:"size_threshold"`
+	// MaxLength counts values longer than this as truncated, as
+	// vault.kept_copy_transform.max_length would cut them. 0 = none.
+	MaxLength promptvaultprocessor.ByteSize `mapstructure:"max_length"`
+	// ModelAttribute names the span attribute labeling metrics with the
+	// model.
+	ModelAttribute string `mapstructure:"model_attribute"`
+}
+
+// DefaultConfig measures the processor's default keys, labeled by
+// gen_ai.request.model.
+func DefaultConfig() *Config {
+	vaultCfg := promptvaultprocessor.NewFactory().CreateDefaultConfig().(*promptvaultprocessor.Config)
+	return &Config{
+		Keys:           vaultCfg.Vault.Keys,
+		SizeThreshold:  vaultCfg.Vault.SizeThreshold,
+		ModelAttribute: "gen_ai.request.model",
+	}
+}
@@ -0,0 +1,262 @@
+package promptvaultanalyticsconnector
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/consumer"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+
+	"github.com/airblackbox/otel-prompt-vault/processor/promptvaultprocessor"
+)
+
+const scopeName = "github.com/airblackbox/otel-prompt-vault/connector/promptvaultanalyticsconnector"
+
+// bytesPerToken estimates tokens from content size.
+const bytesPerToken = 4
+
+// Histogram bucket bounds for content sizes and token estimates.
+var (
+	bytesBounds  = []float64{256, 1024, 4096, 16384, 65536, 262144, 1048576}
+	tokensBounds = []float64{64, 256, 1024, 4096, 16384, 65536, 262144}
+)
+
+// Outcomes of a matched value, labeling promptvault_content_values.
+const (
+	outcomeVaulted        = "vaulted"
+	outcomeBelowThreshold = "below_threshold"
+)
+
+type analyticsConnector struct {
+	component.StartFunc
+	component.ShutdownFunc
+
+	config *Config
+	match  func(key string) (string, bool)
+	next   consumer.Metrics
+
+	mu sync.Mutex
+	// last is the end of the interval of the previous metrics sent, and the
+	// start of the next.
+	last pcommon.Timestamp
+}
+
+func newAnalyticsConnector(cfg *Config, next consumer.Metrics) (*analyticsConnector, error) {
+	match, err := promptvaultprocessor.NewKeyMatcher(promptvaultprocessor.VaultConfig{
+		Keys:    cfg.Keys,
+		Presets: cfg.Presets,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &analyticsConnector{
+		config: cfg,
+		match:  match,
+		next:   next,
+		last:   pcommon.NewTimestampFromTime(time.Now()),
+	}, nil
+}
+
+func (c *analyticsConnector) Capabilities() consumer.Capabilities {
+	return consumer.Capabilities{MutatesData: false}
+}
+
+// seriesKey identifies the data points a matched value is counted in. key is
+// the configured key or pattern it matched.
+type seriesKey struct {
+	key, model, service string
+}
+
+type series struct {
+	bytes, tokens  histogram
+	vaulted, below int64
+	truncated      int64
+}
+
+// ConsumeTraces measures the matched values in td and sends the batch's
+// totals on as delta metrics.
+func (c *analyticsConnector) ConsumeTraces(ctx context.Context, td ptrace.Traces) error {
+	counted := make(map[seriesKey]*series)
+	rss := td.ResourceSpans()
+	for i := 0; i < rss.Len(); i++ {
+		var service string
+		if v, ok := rss.At(i).Resource().Attributes().Get("service.name"); ok {
+			service = v.Str()
+		}
+		ilss := rss.At(i).ScopeSpans()
+		for j := 0; j < ilss.Len(); j++ {
+			spans := ilss.At(j).Spans()
+			for k := 0; k < spans.Len(); k++ {
+				c.measureSpan(spans.At(k), service, counted)
+			}
+		}
+	}
+	if len(counted) == 0 {
+		return nil
+	}
+	return c.next.ConsumeMetrics(ctx, c.metrics(counted))
+}
+
+func (c *analyticsConnector) measureSpan(span ptrace.Span, service string, counted map[seriesKey]*series) {
+	attrs := span.Attributes()
+	var model string
+	if v, ok := attrs.Get(c.config.ModelAttribute); ok {
+		model = v.AsString()
+	}
+	attrs.Range(func(key string, val pcommon.Value) bool {
+		matched, ok := c.match(key)
+		if !ok || val.Type() != pcommon.ValueTypeStr {
+			return true
+		}
+		sk := seriesKey{key: matched, model: model, service: service}
+		s := counted[sk]
+		if s == nil {
+			s = &series{bytes: newHistogram(bytesBounds), tokens: newHistogram(tokensBounds)}
+			counted[sk] = s
+		}
+		size := int64(len(val.Str()))
+		s.bytes.record(size)
+		s.tokens.record((size + bytesPerToken - 1) / bytesPerToken)
+		if size < int64(c.config.SizeThreshold) {
+			s.below++
+		} else {
+			s.vaulted++
+		}
+		if c.config.MaxLength > 0 && size > int64(c.config.MaxLength) {
+			s.truncated++
+		}
+		return true
+	})
+}
+
+// metrics renders counted as metrics covering the time since the previous
+// batch.
+func (c *analyticsConnector) metrics(counted map[seriesKey]*series) pmetric.Metrics {
+	c.mu.Lock()
+	start := c.last
+	end := pcommon.NewTimestampFromTime(time.Now())
+	c.last = end
+	c.mu.Unlock()
+
+	keys := make([]seriesKey, 0, len(counted))
+	for sk := range counted {
+		keys = append(keys, sk)
+	}
+	slices.SortFunc(keys, func(a, b seriesKey) int {
+		return cmp.Or(cmp.Compare(a.service, b.service), cmp.Compare(a.model, b.model), cmp.Compare(a.key, b.key))
+	})
+
+	md := pmetric.NewMetrics()
+	sm := md.ResourceMetrics().AppendEmpty().ScopeMetrics().AppendEmpty()
+	sm.Scope().SetName(scopeName)
+	ms := sm.Metrics()
+
+	contentBytes := newHistogramMetric(ms, "promptvault_content_bytes",
+		"Size of matched attribute values, by key, model, and service.", "By")
+	contentTokens := newHistogramMetric(ms, "promptvault_content_tokens",
+		"Estimated tokens in matched attribute values (4 bytes per token), by key, model, and service.", "{tokens}")
+	values := newSumMetric(ms, "promptvault_content_values",
+		"Matched attribute values, by whether they reach size_threshold and are vaulted.", "{values}")
+	truncations := newSumMetric(ms, "promptvault_content_truncations",
+		"Matched attribute values longer than max_length.", "{values}")
+
+	for _, sk := range keys {
+		s := counted[sk]
+		s.bytes.appendTo(contentBytes, sk, start, end)
+		s.tokens.appendTo(contentTokens, sk, start, end)
+		for _, o := range []struct {
+			outcome string
+			n       int64
+		}{{outcomeVaulted, s.vaulted}, {outcomeBelowThreshold, s.below}} {
+			if o.n > 0 {
+				dp := appendSum(values, sk, start, end, o.n)
+				dp.Attributes().PutStr("outcome", o.outcome)
+			}
+		}
+		if s.truncated > 0 {
+			appendSum(truncations, sk, start, end, s.truncated)
+		}
+	}
+	return md
+}
+
+func newHistogramMetric(ms pmetric.MetricSlice, name, description, unit string) pmetric.HistogramDataPointSlice {
+	m := ms.AppendEmpty()
+	m.SetName(name)
+	m.SetDescription(description)
+	m.SetUnit(unit)
+	h := m.SetEmptyHistogram()
+	h.SetAggregationTemporality(pmetric.AggregationTemporalityDelta)
+	return h.DataPoints()
+}
+
+func newSumMetric(ms pmetric.MetricSlice, name, description, unit string) pmetric.NumberDataPointSlice {
+	m := ms.AppendEmpty()
+	m.SetName(name)
+	m.SetDescription(description)
+	m.SetUnit(unit)
+	sum := m.SetEmptySum()
+	sum.SetAggregationTemporality(pmetric.AggregationTemporalityDelta)
+	sum.SetIsMonotonic(true)
+	return sum.DataPoints()
+}
+
+func appendSum(dps pmetric.NumberDataPointSlice, sk seriesKey, start, end pcommon.Timestamp, n int64) pmetric.NumberDataPoint {
+	dp := dps.AppendEmpty()
+	putSeriesAttributes(dp.Attributes(), sk)
+	dp.SetStartTimestamp(start)
+	dp.SetTimestamp(end)
+	dp.SetIntValue(n)
+	return dp
+}
+
+func putSeriesAttributes(attrs pcommon.Map, sk seriesKey) {
+	attrs.PutStr("key", sk.key)
+	attrs.PutStr("model", sk.model)
+	attrs.PutStr("service", sk.service)
+}
+
+// histogram accumulates an explicit-bucket histogram of one series.
+type histogram struct {
+	bounds   []float64
+	counts   []uint64
+	count    uint64
+	sum      int64
+	min, max int64
+}
+
+func newHistogram(bounds []float64) histogram {
+	return histogram{bounds: bounds, counts: make([]uint64, len(bounds)+1)}
+}
+
+func (h *histogram) record(v int64) {
+	i, _ := slices.BinarySearch(h.bounds, float64(v))
+	h.counts[i]++
+	if h.count == 0 || v < h.min {
+		h.min = v
+	}
+	if h.count == 0 || v > h.max {
+		h.max = v
+	}
+	h.count++
+	h.sum += v
+}
+
+func (h *histogram) appendTo(dps pmetric.HistogramDataPointSlice, sk seriesKey, start, end pcommon.Timestamp) {
+	dp := dps.AppendEmpty()
+	putSeriesAttributes(dp.Attributes(), sk)
+	dp.SetStartTimestamp(start)
+	dp.SetTimestamp(end)
+	dp.ExplicitBounds().FromRaw(h.bounds)
+	dp.BucketCounts().FromRaw(h.counts)
+	dp.SetCount(h.count)
+	dp.SetSum(float64(h.sum))
+	dp.SetMin(float64(h.min))
+	dp.SetMax(float64(h.max))
+}
@@ -0,0 +1,122 @@
+package promptvaultanalyticsconnector
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+// seriesName renders a data point's attributes for lookup in tests.
+func seriesName(attrs pcommon.Map) string {
+	var parts []string
+	for _, k := range []string{"key", "model", "service", "outcome"} {
+		if v, ok := attrs.Get(k); ok {
+			parts = append(parts, v.Str())
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+func TestConnectorMeasuresMatchedValues(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Keys = []string{"gen_ai.prompt", "llm.input_messages.*"}
+	cfg.SizeThreshold = 8
+	cfg.MaxLength = 20
+	sink := new(consumertest.MetricsSink)
+	conn, err := newAnalyticsConnector(cfg, sink)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	td := ptrace.NewTraces()
+	rs := td.ResourceSpans().AppendEmpty()
+	rs.Resource().Attributes().PutStr("service.name", "chat-api")
+	spans := rs.ScopeSpans().AppendEmpty().Spans()
+	span := spans.AppendEmpty()
+	span.Attributes().PutStr("gen_ai.request.model", "gpt-4o")
+	span.Attributes().PutStr("gen_ai.prompt", "Summarize the quarterly report") // 30 bytes
+	span.Attributes().PutStr("llm.input_messages.0", "short")
+	span.Attributes().PutStr("llm.input_messages.1", "hello there")
+	span.Attributes().PutStr("http.method", "POST")
+	span = spans.AppendEmpty()
+	span.Attributes().PutStr("gen_ai.request.model", "gpt-4o")
+	span.Attributes().PutStr("gen_ai.prompt", "And the annual one?")
+	want := td.ResourceSpans().At(0).ScopeSpans().At(0).Spans().At(0).Attributes().AsRaw()
+
+	if err := conn.ConsumeTraces(context.Background(), td); err != nil {
+		t.Fatal(err)
+	}
+	if got := td.ResourceSpans().At(0).ScopeSpans().At(0).Spans().At(0).Attributes().AsRaw(); len(got) != len(want) {
+		t.Errorf("the connector changed the span to %v", got)
+	}
+	if len(sink.AllMetrics()) != 1 {
+		t.Fatalf("sent %d batches, want one per traces batch", len(sink.AllMetrics()))
+	}
+
+	ms := sink.AllMetrics()[0].ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics()
+	got := make(map[string]pmetric.Metric)
+	for i := 0; i < ms.Len(); i++ {
+		got[ms.At(i).Name()] = ms.At(i)
+	}
+
+	hists := make(map[string]pmetric.HistogramDataPoint)
+	dps := got["promptvault_content_bytes"].Histogram().DataPoints()
+	for i := 0; i < dps.Len(); i++ {
+		hists[seriesName(dps.At(i).Attributes())] = dps.At(i)
+	}
+	if dp := hists["gen_ai.prompt/gpt-4o/chat-api"]; dp.Count() != 2 || dp.Sum() != 49 || dp.Min() != 19 || dp.Max() != 30 {
+		t.Errorf("prompt bytes: count %d, sum %v, min %v, max %v", dp.Count(), dp.Sum(), dp.Min(), dp.Max())
+	}
+	if dp := hists["llm.input_messages.*/gpt-4o/chat-api"]; dp.Count() != 2 || dp.Sum() != 16 {
+		t.Errorf("indexed messages should share their pattern's series: %v", hists)
+	}
+	if got["promptvault_content_bytes"].Histogram().AggregationTemporality() != pmetric.AggregationTemporalityDelta {
+		t.Error("content bytes should be a delta histogram")
+	}
+	tokens := got["promptvault_content_tokens"].Histogram().DataPoints()
+	for i := 0; i < tokens.Len(); i++ {
+		if seriesName(tokens.At(i).Attributes()) == "gen_ai.prompt/gpt-4o/chat-api" && tokens.At(i).Sum() != 13 {
+			t.Errorf("prompt tokens = %v, want 8 + 5", tokens.At(i).Sum())
+		}
+	}
+
+	counts := func(name string) map[string]int64 {
+		out := make(map[string]int64)
+		dps := got[name].Sum().DataPoints()
+		for i := 0; i < dps.Len(); i++ {
+			out[seriesName(dps.At(i).Attributes())] = dps.At(i).IntValue()
+		}
+		return out
+	}
+	values := counts("promptvault_content_values")
+	if values["gen_ai.prompt/gpt-4o/chat-api/vaulted"] != 2 ||
+		values["llm.input_messages.*/gpt-4o/chat-api/vaulted"] != 1 ||
+		values["llm.input_messages.*/gpt-4o/chat-api/below_threshold"] != 1 {
+		t.Errorf("content values = %v", values)
+	}
+	if truncations := counts("promptvault_content_truncations"); len(truncations) != 1 || truncations["gen_ai.prompt/gpt-4o/chat-api"] != 1 {
+		t.Errorf("truncations = %v, want the 30-byte prompt", truncations)
+	}
+}
+
+func TestConnectorSkipsBatchesWithoutMatches(t *testing.T) {
+	sink := new(consumertest.MetricsSink)
+	conn, err := newAnalyticsConnector(DefaultConfig(), sink)
+	if err != nil {
+		t.Fatal(err)
+	}
+	td := ptrace.NewTraces()
+	span := td.ResourceSpans().AppendEmpty().ScopeSpans().AppendEmpty().Spans().AppendEmpty()
+	span.Attributes().PutStr("http.method", "POST")
+	if err := conn.ConsumeTraces(context.Background(), td); err != nil {
+		t.Fatal(err)
+	}
+	if len(sink.AllMetrics()) != 0 {
+		t.Errorf("sent %d batches for traces without matched values", len(sink.AllMetrics()))
+	}
+}
@@ -0,0 +1,32 @@
+package promptvaultanalyticsconnector
+
+import (
+	"context"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/connector"
+	"go.opentelemetry.io/collector/consumer"
+)
+
+const (
+	typeStr   = "promptvaultanalytics"
+	stability = component.StabilityLevelAlpha
+)
+
+// NewFactory creates a factory for the prompt analytics connector.
+func NewFactory() connector.Factory {
+	return connector.NewFactory(
+		component.MustNewType(typeStr),
+		func() component.Config { return DefaultConfig() },
+		connector.WithTracesToMetrics(createTracesToMetrics, stability),
+	)
+}
+
+func createTracesToMetrics(
+	_ context.Context,
+	_ connector.Settings,
+	cfg component.Config,
+	nextConsumer consumer.Metrics,
+) (connector.Traces, error) {
+	return newAnalyticsConnector(cfg.(*Config), nextConsumer)
+}
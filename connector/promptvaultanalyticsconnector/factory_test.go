@@ -0,0 +1,48 @@
+package promptvaultanalyticsconnector
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/connector/connectortest"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+
+	"github.com/airblackbox/otel-prompt-vault/internal/metadatatest"
+)
+
+func TestMetadataMatchesFactory(t *testing.T) {
+	f := NewFactory()
+	metadatatest.Check(t, "connector", f.Type(), map[string]component.StabilityLevel{
+		"traces_to_traces":   f.TracesToTracesStability(),
+		"traces_to_metrics":  f.TracesToMetricsStability(),
+		"traces_to_logs":     f.TracesToLogsStability(),
+		"metrics_to_traces":  f.MetricsToTracesStability(),
+		"metrics_to_metrics": f.MetricsToMetricsStability(),
+		"metrics_to_logs":    f.MetricsToLogsStability(),
+		"logs_to_traces":     f.LogsToTracesStability(),
+		"logs_to_metrics":    f.LogsToMetricsStability(),
+		"logs_to_logs":       f.LogsToLogsStability(),
+	})
+}
+
+func TestFactoryCreatesTracesToMetrics(t *testing.T) {
+	f := NewFactory()
+	cfg := f.CreateDefaultConfig().(*Config)
+	if !reflect.DeepEqual(cfg, DefaultConfig()) {
+		t.Fatalf("default config = %#v, want DefaultConfig()", cfg)
+	}
+	conn, err := f.CreateTracesToMetrics(context.Background(), connectortest.NewNopSettings(), cfg, new(consumertest.MetricsSink))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conn.Capabilities().MutatesData {
+		t.Error("the connector must not declare that it mutates data")
+	}
+
+	cfg.Presets = []string{"no-such-preset"}
+	if _, err := f.CreateTracesToMetrics(context.Background(), connectortest.NewNopSettings(), cfg, new(consumertest.MetricsSink)); err == nil {
+		t.Error("expected an unknown preset to be refused")
+	}
+}
@@ -0,0 +1,202 @@
+// Package export writes vault content out as datasets.
+package export
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/airblackbox/otel-prompt-vault/processor/promptvaultprocessor"
+)
+
+// Options selects the objects to export. Zero values select everything.
+type Options struct {
+	// Start and End bound the store time: objects stored at or after Start
+	// and before End.
+	Start time.Time `mapstructure:"start"`
+	End   time.Time `mapstructure:"end"`
+	// Service and Tenant select objects stored for one service or tenant.
+	Service string `mapstructure:"service"`
+	Tenant  string `mapstructure:"tenant"`
+	// InputKeys and OutputKeys name the attribute keys holding a span's
+	// input and output for a JSONL export, in order of preference.
+	// Defaults cover the GenAI semantic conventions.
+	InputKeys  []string `mapstructure:"input_keys"`
+	OutputKeys []string `mapstructure:"output_keys"`
+	// Keys limits a Parquet export to these attribute keys.
+	Keys []string `mapstructure:"keys"`
+	// Compression is the Parquet codec: "snappy" (default), "gzip",
+	// "zstd", or "none".
+	Compression string `mapstructure:"compression"`
+	// Redaction masks sensitive substrings in exported content.
+	Redaction promptvaultprocessor.RedactionConfig `mapstructure:"redaction"`
+	// Hooks run, in order, on each object's content before redaction, each
+	// naming a hook registered with promptvaultprocessor.RegisterRetrieveHook.
+	Hooks []promptvaultprocessor.RetrieveHookConfig `mapstructure:"hooks"`
+}
+
+// Default input and output keys.
+var (
+	DefaultInputKeys  = []string{"gen_ai.input.messages", "gen_ai.prompt"}
+	DefaultOutputKeys = []string{"gen_ai.output.messages", "gen_ai.completion"}
+)
+
+func (o Options) filter() promptvaultprocessor.ObjectFilter {
+	return promptvaultprocessor.ObjectFilter{
+		Service:      o.Service,
+		Tenant:       o.Tenant,
+		StoredAfter:  o.Start,
+		StoredBefore: o.End,
+	}
+}
+
+// Record is one JSONL line: a span's input paired with its output.
+type Record struct {
+	TraceID   string    `json:"trace_id"`
+	SpanID    string    `json:"span_id"`
+	Service   string    `json:"service,omitempty"`
+	Tenant    string    `json:"tenant,omitempty"`
+	Time      time.Time `json:"time"`
+	InputKey  string    `json:"input_key"`
+	Input     string    `json:"input"`
+	OutputKey string    `json:"output_key"`
+	Output    string    `json:"output"`
+}
+
+// Stats counts the records an export wrote and the ones it could not.
+type Stats struct {
+	// Written counts JSONL spans, which had both an input and an output,
+	// or Parquet objects.
+	Written int
+	// Incomplete JSONL spans had only one of them, and were not written.
+	Incomplete int
+	// Failed spans or objects had content that could not be retrieved.
+	Failed int
+}
+
+// span collects the candidate objects stored for one span.
+type span struct {
+	first   time.Time
+	objects map[string]promptvaultprocessor.ObjectInfo
+}
+
+// WriteJSONL writes a Record per span with both an input and an output,
+// oldest first. Objects are paired by their trace and span IDs, so the
+// vault must record them: with storage.index, on filesystem with
+// write_metadata, or in memory.
+func WriteJSONL(ctx context.Context, vault promptvaultprocessor.VaultStorage, w io.Writer, opts Options) (Stats, error) {
+	var stats Stats
+	if len(opts.InputKeys) == 0 {
+		opts.InputKeys = DefaultInputKeys
+	}
+	if len(opts.OutputKeys) == 0 {
+		opts.OutputKeys = DefaultOutputKeys
+	}
+	redactor, err := promptvaultprocessor.NewRedactor(opts.Redaction)
+	if err != nil {
+		return stats, err
+	}
+	hooks, err := promptvaultprocessor.NewRetrieveHooks(opts.Hooks)
+	if err != nil {
+		return stats, err
+	}
+	wanted := make(map[string]bool)
+	for _, k := range append(append([]string(nil), opts.InputKeys...), opts.OutputKeys...) {
+		wanted[k] = true
+	}
+
+	spans := make(map[[2]string]*span)
+	err = promptvaultprocessor.SearchObjects(ctx, vault, opts.filter(), func(info promptvaultprocessor.ObjectInfo) error {
+		m := info.Meta
+		if !wanted[m.Key] || m.SpanID == "" {
+			return nil
+		}
+		id := [2]string{m.TraceID, m.SpanID}
+		s, ok := spans[id]
+		if !ok {
+			s = &span{first: info.StoredAt, objects: make(map[string]promptvaultprocessor.ObjectInfo)}
+			spans[id] = s
+		}
+		if info.StoredAt.Before(s.first) {
+			s.first = info.StoredAt
+		}
+		s.objects[m.Key] = info
+		return nil
+	})
+	if err != nil {
+		return stats, err
+	}
+
+	ids := make([][2]string, 0, len(spans))
+	for id := range spans {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		a, b := spans[ids[i]], spans[ids[j]]
+		if !a.first.Equal(b.first) {
+			return a.first.Before(b.first)
+		}
+		return ids[i][0]+ids[i][1] < ids[j][0]+ids[j][1]
+	})
+
+	enc := json.NewEncoder(w)
+	for _, id := range ids {
+		s := spans[id]
+		in, inOK := pick(s.objects, opts.InputKeys)
+		out, outOK := pick(s.objects, opts.OutputKeys)
+		if !inOK || !outOK {
+			stats.Incomplete++
+			continue
+		}
+		input, err := retrieve(ctx, vault, hooks, in)
+		var output []byte
+		if err == nil {
+			output, err = retrieve(ctx, vault, hooks, out)
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return stats, ctx.Err()
+			}
+			stats.Failed++
+			continue
+		}
+		rec := Record{
+			TraceID:   id[0],
+			SpanID:    id[1],
+			Service:   in.Meta.Service,
+			Tenant:    in.Meta.Tenant,
+			Time:      s.first.UTC(),
+			InputKey:  in.Meta.Key,
+			Input:     redactor.Redact(string(input)),
+			OutputKey: out.Meta.Key,
+			Output:    redactor.Redact(string(output)),
+		}
+		if err := enc.Encode(rec); err != nil {
+			return stats, fmt.Errorf("write record: %w", err)
+		}
+		stats.Written++
+	}
+	return stats, nil
+}
+
+// retrieve reads the object info describes and runs hooks on it.
+func retrieve(ctx context.Context, vault promptvaultprocessor.VaultStorage, hooks promptvaultprocessor.RetrieveHooks, info promptvaultprocessor.ObjectInfo) ([]byte, error) {
+	content, err := vault.Retrieve(ctx, info.Ref)
+	if err != nil {
+		return nil, err
+	}
+	return hooks.Apply(ctx, info, content)
+}
+
+// pick returns the object for the first of keys the span has.
+func pick(objects map[string]promptvaultprocessor.ObjectInfo, keys []string) (promptvaultprocessor.ObjectInfo, bool) {
+	for _, k := range keys {
+		if info, ok := objects[k]; ok {
+			return info, true
+		}
+	}
+	return promptvaultprocessor.ObjectInfo{}, false
+}
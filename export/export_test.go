@@ -0,0 +1,86 @@
+package export
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/airblackbox/otel-prompt-vault/processor/promptvaultprocessor"
+)
+
+func TestWriteJSONL(t *testing.T) {
+	ctx := context.Background()
+	vault := promptvaultprocessor.NewMemoryVault(0, 0)
+	store := func(trace, span, key, service, content string) {
+		t.Helper()
+		meta := promptvaultprocessor.ObjectMeta{TraceID: trace, SpanID: span, Key: key, Service: service}
+		if _, err := vault.Store(ctx, meta, []byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	store("t1", "s1", "gen_ai.prompt", "chat", "Email me at jane@example.com")
+	store("t1", "s1", "gen_ai.completion", "chat", "Sure, Jane.")
+	store("t1", "s2", "gen_ai.input.messages", "chat", `[{"role":"user","content":"hi"}]`)
+	store("t1", "s2", "gen_ai.prompt", "chat", "hi")
+	store("t1", "s2", "gen_ai.output.messages", "chat", `[{"role":"assistant","content":"hello"}]`)
+	store("t2", "s3", "gen_ai.prompt", "chat", "no answer yet")
+	store("t3", "s4", "gen_ai.prompt", "batch", "other service")
+	store("t3", "s4", "gen_ai.completion", "batch", "ignored")
+
+	var buf bytes.Buffer
+	stats, err := WriteJSONL(ctx, vault, &buf, Options{
+		Service:   "chat",
+		Redaction: promptvaultprocessor.RedactionConfig{Detectors: promptvaultprocessor.RedactionDetectors{Email: true}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats != (Stats{Written: 2, Incomplete: 1}) {
+		t.Errorf("stats = %+v", stats)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("wrote %d lines:\n%s", len(lines), buf.String())
+	}
+	var recs []Record
+	for _, line := range lines {
+		var rec Record
+		if err := json.Unmarshal([]byte(line), &rec); err != nil {
+			t.Fatal(err)
+		}
+		recs = append(recs, rec)
+	}
+	if recs[0].SpanID != "s1" || recs[0].Input != "Email me at [REDACTED:EMAIL]" || recs[0].Output != "Sure, Jane." {
+		t.Errorf("first record = %+v", recs[0])
+	}
+	// Structured messages are preferred over the legacy prompt attribute.
+	if recs[1].SpanID != "s2" || recs[1].InputKey != "gen_ai.input.messages" || recs[1].OutputKey != "gen_ai.output.messages" {
+		t.Errorf("second record = %+v", recs[1])
+	}
+}
+
+func TestWriteJSONLTimeRange(t *testing.T) {
+	ctx := context.Background()
+	vault := promptvaultprocessor.NewMemoryVault(0, 0)
+	vault.Store(ctx, promptvaultprocessor.ObjectMeta{TraceID: "t1", SpanID: "s1", Key: "gen_ai.prompt"}, []byte("q"))
+	vault.Store(ctx, promptvaultprocessor.ObjectMeta{TraceID: "t1", SpanID: "s1", Key: "gen_ai.completion"}, []byte("a"))
+
+	for _, tc := range []struct {
+		name    string
+		opts    Options
+		written int
+	}{
+		{"inside", Options{Start: time.Now().Add(-time.Hour), End: time.Now().Add(time.Hour)}, 1},
+		{"before", Options{End: time.Now().Add(-time.Hour)}, 0},
+		{"after", Options{Start: time.Now().Add(time.Hour)}, 0},
+	} {
+		stats, err := WriteJSONL(ctx, vault, new(bytes.Buffer), tc.opts)
+		if err != nil || stats.Written != tc.written {
+			t.Errorf("%s: stats %+v, err %v; want %d written", tc.name, stats, err, tc.written)
+		}
+	}
+}
@@ -0,0 +1,454 @@
+package export
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/klauspost/compress/s2"
+	"github.com/klauspost/compress/zstd"
+
+	"github.com/airblackbox/otel-prompt-vault/processor/promptvaultprocessor"
+)
+
+// WriteParquet writes a row per object to w as a Parquet file with columns
+// trace_id, span_id, key, service, tenant, ts (UTC microseconds), size
+// (content bytes as vaulted), and content, oldest first. Objects under Keys are
+// exported, or every key but the vault's own audit records when Keys is
+// empty. Content that is not valid UTF-8 has invalid bytes replaced.
+// Stats counts objects written and those that could not be retrieved.
+func WriteParquet(ctx context.Context, vault promptvaultprocessor.VaultStorage, w io.Writer, opts Options) (Stats, error) {
+	var stats Stats
+	redactor, err := promptvaultprocessor.NewRedactor(opts.Redaction)
+	if err != nil {
+		return stats, err
+	}
+	hooks, err := promptvaultprocessor.NewRetrieveHooks(opts.Hooks)
+	if err != nil {
+		return stats, err
+	}
+	keys := make(map[string]bool)
+	for _, k := range opts.Keys {
+		keys[k] = true
+	}
+	pw, err := newParquetWriter(w, opts.Compression)
+	if err != nil {
+		return stats, err
+	}
+
+	err = promptvaultprocessor.SearchObjects(ctx, vault, opts.filter(), func(info promptvaultprocessor.ObjectInfo) error {
+		m := info.Meta
+		if (len(keys) > 0 && !keys[m.Key]) || (len(keys) == 0 && m.Key == "audit") {
+			return nil
+		}
+		content, err := retrieve(ctx, vault, hooks, info)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			stats.Failed++
+			return nil
+		}
+		text := redactor.Redact(strings.ToValidUTF8(string(content), "\uFFFD"))
+		stats.Written++
+		return pw.write(parquetRow{
+			traceID: m.TraceID,
+			spanID:  m.SpanID,
+			key:     m.Key,
+			service: m.Service,
+			tenant:  m.Tenant,
+			ts:      info.StoredAt.UnixMicro(),
+			size:    int64(len(content)),
+			content: text,
+		})
+	})
+	if err != nil {
+		return stats, err
+	}
+	return stats, pw.close()
+}
+
+type parquetRow struct {
+	traceID, spanID, key, service, tenant string
+	ts, size                              int64
+	content                               string
+}
+
+// Parquet enum values, from parquet.thrift.
+const (
+	parquetInt64     = 2
+	parquetByteArray = 6
+
+	parquetRequired = 0
+
+	parquetConvertedUTF8            = 0
+	parquetConvertedTimestampMicros = 10
+
+	parquetPlain = 0
+	parquetRLE   = 3
+
+	parquetDataPage = 0
+
+	parquetUncompressed = 0
+	parquetSnappy       = 1
+	parquetGzip         = 2
+	parquetZstd         = 6
+)
+
+// parquetColumns is the file schema. Every column is required and flat, so
+// pages carry no repetition or definition levels.
+var parquetColumns = []struct {
+	name     string
+	typ      int32
+	isString bool
+}{
+	{"trace_id", parquetByteArray, true},
+	{"span_id", parquetByteArray, true},
+	{"key", parquetByteArray, true},
+	{"service", parquetByteArray, true},
+	{"tenant", parquetByteArray, true},
+	{"ts", parquetInt64, false},
+	{"size", parquetInt64, false},
+	{"content", parquetByteArray, true},
+}
+
+// Row groups are flushed at whichever limit is reached first.
+const (
+	parquetRowGroupRows  = 100_000
+	parquetRowGroupBytes = 64 << 20
+)
+
+// parquetWriter writes the schema above with PLAIN-encoded pages, one page
+// per column chunk, buffering a row group at a time.
+type parquetWriter struct {
+	w         *countingWriter
+	codec     int32
+	compress  func([]byte) ([]byte, error)
+	columns   []bytes.Buffer
+	rows      int64
+	totalRows int64
+	rowGroups []parquetRowGroup
+}
+
+type parquetRowGroup struct {
+	rows    int64
+	offset  int64
+	chunks  []parquetChunk
+	rawSize int64
+	size    int64
+}
+
+type parquetChunk struct {
+	offset           int64
+	uncompressedSize int64
+	compressedSize   int64
+}
+
+type countingWriter struct {
+	w *bufio.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+func newParquetWriter(w io.Writer, compression string) (*parquetWriter, error) {
+	pw := &parquetWriter{
+		w:       &countingWriter{w: bufio.NewWriter(w)},
+		columns: make([]bytes.Buffer, len(parquetColumns)),
+	}
+	switch compression {
+	case "", "snappy":
+		pw.codec = parquetSnappy
+		pw.compress = func(b []byte) ([]byte, error) { return s2.EncodeSnappy(nil, b), nil }
+	case "gzip":
+		pw.codec = parquetGzip
+		pw.compress = func(b []byte) ([]byte, error) {
+			var buf bytes.Buffer
+			zw := gzip.NewWriter(&buf)
+			zw.Write(b)
+			err := zw.Close()
+			return buf.Bytes(), err
+		}
+	case "zstd":
+		enc, err := zstd.NewWriter(nil)
+		if err != nil {
+			return nil, err
+		}
+		pw.codec = parquetZstd
+		pw.compress = func(b []byte) ([]byte, error) { return enc.EncodeAll(b, nil), nil }
+	case "none":
+		pw.codec = parquetUncompressed
+		pw.compress = func(b []byte) ([]byte, error) { return b, nil }
+	default:
+		return nil, fmt.Errorf("unknown parquet compression %q", compression)
+	}
+	if _, err := pw.w.Write([]byte("PAR1")); err != nil {
+		return nil, err
+	}
+	return pw, nil
+}
+
+func (pw *parquetWriter) write(r parquetRow) error {
+	for i, s := range []string{r.traceID, r.spanID, r.key, r.service, r.tenant} {
+		plainByteArray(&pw.columns[i], s)
+	}
+	binary.Write(&pw.columns[5], binary.LittleEndian, r.ts)
+	binary.Write(&pw.columns[6], binary.LittleEndian, r.size)
+	plainByteArray(&pw.columns[7], r.content)
+	pw.rows++
+
+	size := 0
+	for i := range pw.columns {
+		size += pw.columns[i].Len()
+	}
+	if pw.rows >= parquetRowGroupRows || size >= parquetRowGroupBytes {
+		return pw.flushRowGroup()
+	}
+	return nil
+}
+
+func plainByteArray(buf *bytes.Buffer, s string) {
+	binary.Write(buf, binary.LittleEndian, uint32(len(s)))
+	buf.WriteString(s)
+}
+
+func (pw *parquetWriter) flushRowGroup() error {
+	if pw.rows == 0 {
+		return nil
+	}
+	rg := parquetRowGroup{rows: pw.rows, offset: pw.w.n}
+	for i := range pw.columns {
+		raw := pw.columns[i].Bytes()
+		data, err := pw.compress(raw)
+		if err != nil {
+			return fmt.Errorf("compress parquet page: %w", err)
+		}
+		var hdr compactWriter
+		hdr.i32(1, parquetDataPage)
+		hdr.i32(2, int32(len(raw)))
+		hdr.i32(3, int32(len(data)))
+		hdr.structBegin(5)
+		hdr.i32(1, int32(pw.rows))
+		hdr.i32(2, parquetPlain)
+		hdr.i32(3, parquetRLE)
+		hdr.i32(4, parquetRLE)
+		hdr.structEnd()
+		hdr.stop()
+
+		chunk := parquetChunk{
+			offset:           pw.w.n,
+			uncompressedSize: int64(hdr.buf.Len() + len(raw)),
+			compressedSize:   int64(hdr.buf.Len() + len(data)),
+		}
+		if _, err := pw.w.Write(hdr.buf.Bytes()); err != nil {
+			return err
+		}
+		if _, err := pw.w.Write(data); err != nil {
+			return err
+		}
+		rg.chunks = append(rg.chunks, chunk)
+		rg.rawSize += chunk.uncompressedSize
+		rg.size += chunk.compressedSize
+		pw.columns[i].Reset()
+	}
+	pw.rowGroups = append(pw.rowGroups, rg)
+	pw.totalRows += pw.rows
+	pw.rows = 0
+	return nil
+}
+
+// close flushes the last row group and writes the footer: the Thrift
+// compact-encoded FileMetaData, its length, and the closing magic.
+func (pw *parquetWriter) close() error {
+	if err := pw.flushRowGroup(); err != nil {
+		return err
+	}
+	var meta compactWriter
+	meta.i32(1, 1)
+	meta.listBegin(2, compactStruct, len(parquetColumns)+1)
+	meta.elemBegin()
+	meta.binary(4, "schema")
+	meta.i32(5, int32(len(parquetColumns)))
+	meta.elemEnd()
+	for _, col := range parquetColumns {
+		meta.elemBegin()
+		meta.i32(1, col.typ)
+		meta.i32(3, parquetRequired)
+		meta.binary(4, col.name)
+		switch {
+		case col.isString:
+			meta.i32(6, parquetConvertedUTF8)
+			meta.structBegin(10) // LogicalType
+			meta.structBegin(1)  // STRING
+			meta.structEnd()
+			meta.structEnd()
+		case col.name == "ts":
+			meta.i32(6, parquetConvertedTimestampMicros)
+			meta.structBegin(10) // LogicalType
+			meta.structBegin(8)  // TIMESTAMP
+			meta.boolean(1, true)
+			meta.structBegin(2) // unit
+			meta.structBegin(2) // MICROS
+			meta.structEnd()
+			meta.structEnd()
+			meta.structEnd()
+			meta.structEnd()
+		}
+		meta.elemEnd()
+	}
+	meta.i64(3, pw.totalRows)
+	meta.listBegin(4, compactStruct, len(pw.rowGroups))
+	for _, rg := range pw.rowGroups {
+		meta.elemBegin()
+		meta.listBegin(1, compactStruct, len(rg.chunks))
+		for i, c := range rg.chunks {
+			col := parquetColumns[i]
+			meta.elemBegin()
+			meta.i64(2, c.offset)
+			meta.structBegin(3)
+			meta.i32(1, col.typ)
+			meta.listBegin(2, compactI32, 2)
+			meta.listI32(parquetPlain)
+			meta.listI32(parquetRLE)
+			meta.listBegin(3, compactBinary, 1)
+			meta.listBinary(col.name)
+			meta.i32(4, pw.codec)
+			meta.i64(5, rg.rows)
+			meta.i64(6, c.uncompressedSize)
+			meta.i64(7, c.compressedSize)
+			meta.i64(9, c.offset)
+			meta.structEnd()
+			meta.elemEnd()
+		}
+		meta.i64(2, rg.rawSize)
+		meta.i64(3, rg.rows)
+		meta.i64(5, rg.offset)
+		meta.i64(6, rg.size)
+		meta.elemEnd()
+	}
+	meta.binary(6, "otel-prompt-vault")
+	meta.stop()
+
+	footer := meta.buf.Bytes()
+	if _, err := pw.w.Write(footer); err != nil {
+		return err
+	}
+	if err := binary.Write(pw.w, binary.LittleEndian, uint32(len(footer))); err != nil {
+		return err
+	}
+	if _, err := pw.w.Write([]byte("PAR1")); err != nil {
+		return err
+	}
+	return pw.w.w.Flush()
+}
+
+// Thrift compact protocol type IDs.
+const (
+	compactTrue   = 1
+	compactFalse  = 2
+	compactI32    = 5
+	compactI64    = 6
+	compactBinary = 8
+	compactList   = 9
+	compactStruct = 12
+)
+
+// compactWriter encodes the Thrift compact protocol, just enough of it for
+// Parquet metadata. Field IDs are delta-encoded within each struct, so the
+// last ID of every enclosing struct is kept on a stack.
+type compactWriter struct {
+	buf    bytes.Buffer
+	lastID int16
+	stack  []int16
+}
+
+func (w *compactWriter) uvarint(v uint64) {
+	w.buf.Write(binary.AppendUvarint(nil, v))
+}
+
+func (w *compactWriter) field(id int16, typ byte) {
+	if d := id - w.lastID; d > 0 && d <= 15 {
+		w.buf.WriteByte(byte(d)<<4 | typ)
+	} else {
+		w.buf.WriteByte(typ)
+		w.uvarint(uint64(uint16((id << 1) ^ (id >> 15))))
+	}
+	w.lastID = id
+}
+
+func (w *compactWriter) i32(id int16, v int32) {
+	w.field(id, compactI32)
+	w.uvarint(uint64(uint32((v << 1) ^ (v >> 31))))
+}
+
+func (w *compactWriter) i64(id int16, v int64) {
+	w.field(id, compactI64)
+	w.uvarint(uint64((v << 1) ^ (v >> 63)))
+}
+
+func (w *compactWriter) binary(id int16, s string) {
+	w.field(id, compactBinary)
+	w.uvarint(uint64(len(s)))
+	w.buf.WriteString(s)
+}
+
+func (w *compactWriter) boolean(id int16, v bool) {
+	if v {
+		w.field(id, compactTrue)
+	} else {
+		w.field(id, compactFalse)
+	}
+}
+
+func (w *compactWriter) structBegin(id int16) {
+	w.field(id, compactStruct)
+	w.elemBegin()
+}
+
+func (w *compactWriter) structEnd() {
+	w.elemEnd()
+}
+
+// elemBegin and elemEnd bracket a struct that is a list element.
+func (w *compactWriter) elemBegin() {
+	w.stack = append(w.stack, w.lastID)
+	w.lastID = 0
+}
+
+func (w *compactWriter) elemEnd() {
+	w.stop()
+	w.lastID = w.stack[len(w.stack)-1]
+	w.stack = w.stack[:len(w.stack)-1]
+}
+
+func (w *compactWriter) stop() {
+	w.buf.WriteByte(0)
+}
+
+func (w *compactWriter) listBegin(id int16, elemType byte, n int) {
+	w.field(id, compactList)
+	if n < 15 {
+		w.buf.WriteByte(byte(n)<<4 | elemType)
+	} else {
+		w.buf.WriteByte(0xf0 | elemType)
+		w.uvarint(uint64(n))
+	}
+}
+
+func (w *compactWriter) listI32(v int32) {
+	w.uvarint(uint64(uint32((v << 1) ^ (v >> 31))))
+}
+
+func (w *compactWriter) listBinary(s string) {
+	w.uvarint(uint64(len(s)))
+	w.buf.WriteString(s)
+}
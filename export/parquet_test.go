@@ -0,0 +1,227 @@
+package export
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/klauspost/compress/s2"
+	"github.com/klauspost/compress/zstd"
+
+	"github.com/airblackbox/otel-prompt-vault/processor/promptvaultprocessor"
+)
+
+// compactReader decodes Thrift compact structs into maps from field ID to
+// value, enough to read back the metadata parquetWriter produces.
+type compactReader struct {
+	b   []byte
+	pos int
+}
+
+func (r *compactReader) byte() byte {
+	b := r.b[r.pos]
+	r.pos++
+	return b
+}
+
+func (r *compactReader) uvarint() uint64 {
+	v, n := binary.Uvarint(r.b[r.pos:])
+	r.pos += n
+	return v
+}
+
+func (r *compactReader) zigzag() int64 {
+	v := r.uvarint()
+	return int64(v>>1) ^ -int64(v&1)
+}
+
+func (r *compactReader) value(typ byte) any {
+	switch typ {
+	case compactTrue:
+		return true
+	case compactFalse:
+		return false
+	case compactI32, compactI64:
+		return r.zigzag()
+	case compactBinary:
+		n := int(r.uvarint())
+		s := string(r.b[r.pos : r.pos+n])
+		r.pos += n
+		return s
+	case compactList:
+		h := r.byte()
+		n, et := int(h>>4), h&0x0f
+		if n == 15 {
+			n = int(r.uvarint())
+		}
+		list := make([]any, n)
+		for i := range list {
+			list[i] = r.value(et)
+		}
+		return list
+	case compactStruct:
+		return r.structure()
+	}
+	panic(fmt.Sprintf("unexpected compact type %d", typ))
+}
+
+func (r *compactReader) structure() map[int16]any {
+	s := make(map[int16]any)
+	var last int16
+	for {
+		h := r.byte()
+		if h == 0 {
+			return s
+		}
+		id := last + int16(h>>4)
+		if h>>4 == 0 {
+			id = int16(r.zigzag())
+		}
+		s[id] = r.value(h & 0x0f)
+		last = id
+	}
+}
+
+func readParquet(t *testing.T, data []byte) (columns []string, rows [][]any) {
+	t.Helper()
+	if string(data[:4]) != "PAR1" || string(data[len(data)-4:]) != "PAR1" {
+		t.Fatal("missing PAR1 magic")
+	}
+	n := int(binary.LittleEndian.Uint32(data[len(data)-8:]))
+	meta := (&compactReader{b: data[len(data)-8-n : len(data)-8]}).structure()
+
+	schema := meta[2].([]any)
+	if schema[0].(map[int16]any)[5].(int64) != int64(len(schema)-1) {
+		t.Fatalf("root has %v children, want %d", schema[0].(map[int16]any)[5], len(schema)-1)
+	}
+	var types []int64
+	for _, el := range schema[1:] {
+		el := el.(map[int16]any)
+		columns = append(columns, el[4].(string))
+		types = append(types, el[1].(int64))
+	}
+
+	for _, rg := range meta[4].([]any) {
+		rg := rg.(map[int16]any)
+		numRows := int(rg[3].(int64))
+		group := make([][]any, numRows)
+		for ci, cc := range rg[1].([]any) {
+			cmd := cc.(map[int16]any)[3].(map[int16]any)
+			pr := &compactReader{b: data, pos: int(cmd[9].(int64))}
+			hdr := pr.structure()
+			page := data[pr.pos : pr.pos+int(hdr[3].(int64))]
+			raw := decompress(t, cmd[4].(int64), page)
+			if len(raw) != int(hdr[2].(int64)) {
+				t.Fatalf("page decompressed to %d bytes, header says %d", len(raw), hdr[2])
+			}
+			for i := 0; i < numRows; i++ {
+				if types[ci] == parquetInt64 {
+					group[i] = append(group[i], int64(binary.LittleEndian.Uint64(raw)))
+					raw = raw[8:]
+				} else {
+					l := binary.LittleEndian.Uint32(raw)
+					group[i] = append(group[i], string(raw[4:4+l]))
+					raw = raw[4+l:]
+				}
+			}
+		}
+		rows = append(rows, group...)
+	}
+	if int(meta[3].(int64)) != len(rows) {
+		t.Fatalf("num_rows %d, read %d", meta[3], len(rows))
+	}
+	return columns, rows
+}
+
+func decompress(t *testing.T, codec int64, page []byte) []byte {
+	t.Helper()
+	var out []byte
+	var err error
+	switch codec {
+	case parquetUncompressed:
+		out = page
+	case parquetSnappy:
+		out, err = s2.Decode(nil, page)
+	case parquetGzip:
+		var zr *gzip.Reader
+		if zr, err = gzip.NewReader(bytes.NewReader(page)); err == nil {
+			out, err = io.ReadAll(zr)
+		}
+	case parquetZstd:
+		var d *zstd.Decoder
+		if d, err = zstd.NewReader(nil); err == nil {
+			out, err = d.DecodeAll(page, nil)
+		}
+	default:
+		t.Fatalf("unexpected codec %d", codec)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+func TestWriteParquet(t *testing.T) {
+	ctx := context.Background()
+	vault := promptvaultprocessor.NewMemoryVault(0, 0)
+	vault.Store(ctx, promptvaultprocessor.ObjectMeta{TraceID: "t1", SpanID: "s1", Key: "gen_ai.prompt", Service: "chat", Tenant: "acme"}, []byte("call 555-867-5309"))
+	vault.Store(ctx, promptvaultprocessor.ObjectMeta{TraceID: "t1", SpanID: "s1", Key: "gen_ai.completion", Service: "chat", Tenant: "acme"}, []byte("done"))
+	vault.Store(ctx, promptvaultprocessor.ObjectMeta{Key: "audit"}, []byte("audit record"))
+
+	for _, codec := range []string{"", "gzip", "zstd", "none"} {
+		var buf bytes.Buffer
+		stats, err := WriteParquet(ctx, vault, &buf, Options{
+			Compression: codec,
+			Redaction:   promptvaultprocessor.RedactionConfig{Detectors: promptvaultprocessor.RedactionDetectors{Phone: true}},
+		})
+		if err != nil {
+			t.Fatalf("%q: %v", codec, err)
+		}
+		if stats.Written != 2 {
+			t.Errorf("%q: stats = %+v", codec, stats)
+		}
+		columns, rows := readParquet(t, buf.Bytes())
+		if fmt.Sprint(columns) != "[trace_id span_id key service tenant ts size content]" {
+			t.Fatalf("columns = %v", columns)
+		}
+		if len(rows) != 2 {
+			t.Fatalf("%q: rows = %v", codec, rows)
+		}
+		got := rows[0]
+		if got[0] != "t1" || got[2] != "gen_ai.prompt" || got[4] != "acme" || got[6] != int64(17) || got[7] != "call [REDACTED:PHONE]" {
+			t.Errorf("%q: row = %v", codec, got)
+		}
+		if ts := time.UnixMicro(got[5].(int64)); time.Since(ts) > time.Minute {
+			t.Errorf("%q: ts = %v", codec, ts)
+		}
+	}
+}
+
+func TestWriteParquetRowGroups(t *testing.T) {
+	var buf bytes.Buffer
+	pw, err := newParquetWriter(&buf, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	const n = parquetRowGroupRows + 3
+	for i := 0; i < n; i++ {
+		if err := pw.write(parquetRow{traceID: fmt.Sprint(i), size: int64(i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := pw.close(); err != nil {
+		t.Fatal(err)
+	}
+	if len(pw.rowGroups) != 2 {
+		t.Errorf("wrote %d row groups, want 2", len(pw.rowGroups))
+	}
+	_, rows := readParquet(t, buf.Bytes())
+	if len(rows) != n || rows[n-1][0] != fmt.Sprint(n-1) || rows[n-1][6] != int64(n-1) {
+		t.Errorf("read %d rows, last %v", len(rows), rows[len(rows)-1])
+	}
+}
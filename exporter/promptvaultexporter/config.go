@@ -0,0 +1,51 @@
+// Package promptvaultexporter stores the configured attributes of the spans
+// it receives in the prompt vault and leaves the spans themselves alone. In
+// a pipeline of its own it archives raw payloads while the pipelines that
+// share its receivers export the spans exactly as received.
+package promptvaultexporter
+
+import (
+	"github.com/airblackbox/otel-prompt-vault/processor/promptvaultprocessor"
+)
+
+// Config for the promptvault exporter.
+type Config struct {
+	// Storage and Crypto locate and encrypt the vault. They take the same
+	// options as the promptvault processor.
+	Storage promptvaultprocessor.StorageConfig `mapstructure:"storage"`
+	Crypto  promptvaultprocessor.CryptoConfig  `mapstructure:"crypto"`
+	// Keys, Presets, and SizeThreshold select the attributes to store, and
+	// TenantAttribute, EndUserAttribute, and RegionAttribute label them, as
+	// the settings of the same names under the processor's vault section.
+	Keys             []string                      `mapstructure:"keys"`
+	Presets          []string                      `mapstructure:"presets"`
+	SizeThreshold    promptvaultprocessor.ByteSize `mapstructure:"size_threshold"`
+	TenantAttribute  string                        `mapstructure:"tenant_attribute"`
+	EndUserAttribute string                        `mapstructure:"enduser_attribute"`
+	RegionAttribute  string                        `mapstructure:"region_attribute"`
+}
+
+// DefaultConfig stores the processor's default keys wherever the processor
+// writes by default.
+func DefaultConfig() *Config {
+	vaultCfg := promptvaultprocessor.NewFactory().CreateDefaultConfig().(*promptvaultprocessor.Config)
+	return &Config{
+		Storage: vaultCfg.Storage,
+		Crypto:  vaultCfg.Crypto,
+		Keys:    vaultCfg.Vault.Keys,
+	}
+}
+
+// vaultConfig returns the processor config the exporter archives with.
+func (cfg *Config) vaultConfig() *promptvaultprocessor.Config {
+	vaultCfg := promptvaultprocessor.NewFactory().CreateDefaultConfig().(*promptvaultprocessor.Config)
+	vaultCfg.Storage = cfg.Storage
+	vaultCfg.Crypto = cfg.Crypto
+	vaultCfg.Vault.Keys = cfg.Keys
+	vaultCfg.Vault.Presets = cfg.Presets
+	vaultCfg.Vault.SizeThreshold = cfg.SizeThreshold
+	vaultCfg.Vault.TenantAttribute = cfg.TenantAttribute
+	vaultCfg.Vault.EndUserAttribute = cfg.EndUserAttribute
+	vaultCfg.Vault.RegionAttribute = cfg.RegionAttribute
+	return vaultCfg
+}
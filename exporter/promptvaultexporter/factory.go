@@ -0,0 +1,32 @@
+package promptvaultexporter
+
+import (
+	"context"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/exporter"
+
+	"github.com/airblackbox/otel-prompt-vault/processor/promptvaultprocessor"
+)
+
+const (
+	typeStr   = "promptvault"
+	stability = component.StabilityLevelAlpha
+)
+
+// NewFactory creates a factory for the promptvault exporter.
+func NewFactory() exporter.Factory {
+	return exporter.NewFactory(
+		component.MustNewType(typeStr),
+		func() component.Config { return DefaultConfig() },
+		exporter.WithTraces(createTracesExporter, stability),
+	)
+}
+
+func createTracesExporter(
+	ctx context.Context,
+	set exporter.Settings,
+	cfg component.Config,
+) (exporter.Traces, error) {
+	return promptvaultprocessor.NewArchiver(ctx, set.TelemetrySettings, cfg.(*Config).vaultConfig())
+}
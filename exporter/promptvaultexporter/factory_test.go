@@ -0,0 +1,72 @@
+package promptvaultexporter
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/exporter/exportertest"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.uber.org/zap"
+
+	"github.com/airblackbox/otel-prompt-vault/internal/metadatatest"
+	"github.com/airblackbox/otel-prompt-vault/processor/promptvaultprocessor"
+)
+
+func TestMetadataMatchesFactory(t *testing.T) {
+	f := NewFactory()
+	metadatatest.Check(t, "exporter", f.Type(), map[string]component.StabilityLevel{
+		"traces":  f.TracesExporterStability(),
+		"metrics": f.MetricsExporterStability(),
+		"logs":    f.LogsExporterStability(),
+	})
+}
+
+func TestFactoryCreatesTracesExporter(t *testing.T) {
+	ctx := context.Background()
+	f := NewFactory()
+	cfg := f.CreateDefaultConfig().(*Config)
+	if !reflect.DeepEqual(cfg, DefaultConfig()) {
+		t.Fatalf("default config = %#v, want DefaultConfig()", cfg)
+	}
+	cfg.Storage.Filesystem.BasePath = t.TempDir()
+	exp, err := f.CreateTracesExporter(ctx, exportertest.NewNopSettings(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp.Capabilities().MutatesData {
+		t.Error("the exporter must not declare that it mutates data")
+	}
+	if err := exp.Start(ctx, componenttest.NewNopHost()); err != nil {
+		t.Fatal(err)
+	}
+
+	raw := "the full prompt, archived as-is"
+	td := ptrace.NewTraces()
+	span := td.ResourceSpans().AppendEmpty().ScopeSpans().AppendEmpty().Spans().AppendEmpty()
+	span.Attributes().PutStr("gen_ai.prompt", raw)
+	span.Attributes().PutStr("http.method", "POST")
+	want := span.Attributes().AsRaw()
+	if err := exp.ConsumeTraces(ctx, td); err != nil {
+		t.Fatal(err)
+	}
+	if err := exp.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got := span.Attributes().AsRaw(); !reflect.DeepEqual(got, want) {
+		t.Errorf("the exporter changed the span to %v", got)
+	}
+
+	vault, err := promptvaultprocessor.OpenVault(ctx, zap.NewNop(), cfg.vaultConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref := fmt.Sprintf("vault://%x", sha256.Sum256([]byte(raw)))
+	if stored, err := vault.Retrieve(ctx, ref); err != nil || string(stored) != raw {
+		t.Errorf("vault holds %q, %v; want the raw value", stored, err)
+	}
+}
@@ -0,0 +1,36 @@
+// Package promptvaultretrievalextension serves the retrieval API from a
+// collector: the HTTP(S) API, and the gRPC API, vault browser UI, and
+// erasure endpoint when enabled, for as long as the collector runs.
+package promptvaultretrievalextension
+
+import (
+	"go.opentelemetry.io/collector/config/configopaque"
+
+	"github.com/airblackbox/otel-prompt-vault/processor/promptvaultprocessor"
+	"github.com/airblackbox/otel-prompt-vault/retrieval"
+)
+
+// Config for the retrieval extension.
+type Config struct {
+	// Storage and Crypto take the same options as the promptvault
+	// processor's blocks and must match its settings. storage.extension
+	// serves the vault a promptvaultstorage extension owns.
+	Storage promptvaultprocessor.StorageConfig `mapstructure:"storage"`
+	Crypto  promptvaultprocessor.CryptoConfig  `mapstructure:"crypto"`
+	// SigningKey verifies reference signatures. It should match the
+	// processors' vault.signing_key.
+	SigningKey configopaque.String `mapstructure:"signing_key"`
+	// The server settings are those of promptvaultctl serve's retrieval
+	// section.
+	retrieval.ServerConfig `mapstructure:",squash"`
+}
+
+// DefaultConfig serves on localhost:8470 from wherever the promptvault
+// processor stores by default.
+func DefaultConfig() *Config {
+	vaultCfg := promptvaultprocessor.NewFactory().CreateDefaultConfig().(*promptvaultprocessor.Config)
+	return &Config{
+		Storage: vaultCfg.Storage,
+		Crypto:  vaultCfg.Crypto,
+	}
+}
@@ -0,0 +1,84 @@
+package promptvaultretrievalextension
+
+import (
+	"context"
+	"io"
+	"net"
+
+	"go.opentelemetry.io/collector/component"
+	"go.uber.org/zap"
+
+	"github.com/airblackbox/otel-prompt-vault/processor/promptvaultprocessor"
+	"github.com/airblackbox/otel-prompt-vault/retrieval"
+)
+
+// Extension runs a retrieval.Server for the collector's lifetime.
+type Extension struct {
+	logger *zap.Logger
+	config *Config
+	signer *promptvaultprocessor.RefSigner
+	srv    *retrieval.Server
+}
+
+// NewExtension checks cfg and, unless the vault is shared through
+// storage.extension and so resolved in Start, opens it and builds the
+// server.
+func NewExtension(ctx context.Context, set component.TelemetrySettings, cfg *Config) (*Extension, error) {
+	if err := promptvaultprocessor.CheckSecretsRedacted(cfg); err != nil {
+		return nil, err
+	}
+	signer, err := promptvaultprocessor.NewRefSigner(cfg.SigningKey)
+	if err != nil {
+		return nil, err
+	}
+	e := &Extension{logger: set.Logger, config: cfg, signer: signer}
+	if ignored := promptvaultprocessor.IgnoredWithExtension(cfg.Storage, cfg.Crypto); cfg.Storage.Extension != "" && len(ignored) > 0 {
+		e.logger.Warn("Settings are ignored because storage.extension is set",
+			zap.String("extension", cfg.Storage.Extension), zap.Strings("ignored", ignored))
+	}
+	if cfg.Storage.Extension == "" {
+		vaultCfg := &promptvaultprocessor.Config{Storage: cfg.Storage, Crypto: cfg.Crypto}
+		vault, err := promptvaultprocessor.OpenVault(ctx, e.logger, vaultCfg)
+		if err != nil {
+			return nil, err
+		}
+		if e.srv, err = retrieval.NewServer(ctx, e.logger, cfg.ServerConfig, vault, signer); err != nil {
+			if c, ok := vault.(io.Closer); ok {
+				c.Close()
+			}
+			return nil, err
+		}
+	}
+	return e, nil
+}
+
+// Start resolves a shared vault and starts serving.
+func (e *Extension) Start(ctx context.Context, host component.Host) error {
+	if e.srv == nil {
+		vault, err := promptvaultprocessor.VaultFromHost(host, e.config.Storage.Extension)
+		if err != nil {
+			return err
+		}
+		if e.srv, err = retrieval.NewServer(ctx, e.logger, e.config.ServerConfig, vault, e.signer); err != nil {
+			return err
+		}
+	}
+	return e.srv.Start(ctx)
+}
+
+// Addr returns the bound HTTP address, once started.
+func (e *Extension) Addr() net.Addr {
+	if e.srv == nil {
+		return nil
+	}
+	return e.srv.Addr()
+}
+
+// Shutdown waits for in-flight requests and closes the vault, unless an
+// extension owns it.
+func (e *Extension) Shutdown(ctx context.Context) error {
+	if e.srv == nil {
+		return nil
+	}
+	return e.srv.Shutdown(ctx)
+}
@@ -0,0 +1,122 @@
+package promptvaultretrievalextension
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.uber.org/zap"
+
+	"github.com/airblackbox/otel-prompt-vault/extension/promptvaultstorageextension"
+	"github.com/airblackbox/otel-prompt-vault/processor/promptvaultprocessor"
+	"github.com/airblackbox/otel-prompt-vault/retrieval"
+)
+
+const apiKey = "oncall-key-0123456789"
+
+type testHost struct {
+	component.Host
+	extensions map[component.ID]component.Component
+}
+
+func (h testHost) GetExtensions() map[component.ID]component.Component {
+	return h.extensions
+}
+
+// get fetches ref from the extension's HTTP API.
+func get(t *testing.T, e *Extension, ref string) (int, string) {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodGet, "http://"+e.Addr().String()+"/v1/vault/"+strings.TrimPrefix(ref, "vault://"), nil)
+	req.Header.Set("X-API-Key", apiKey)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, string(body)
+}
+
+func serverConfig() retrieval.ServerConfig {
+	return retrieval.ServerConfig{
+		Endpoint: "127.0.0.1:0",
+		Auth:     retrieval.AuthConfig{APIKeys: []retrieval.APIKeyConfig{{Name: "oncall", Key: apiKey}}},
+	}
+}
+
+func TestExtensionServesVault(t *testing.T) {
+	ctx := context.Background()
+	cfg := DefaultConfig()
+	cfg.Storage.Filesystem.BasePath = t.TempDir()
+	cfg.ServerConfig = serverConfig()
+
+	vault, err := promptvaultprocessor.OpenVault(ctx, zap.NewNop(), &promptvaultprocessor.Config{Storage: cfg.Storage, Crypto: cfg.Crypto})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref, err := vault.Store(ctx, promptvaultprocessor.ObjectMeta{Key: "gen_ai.prompt"}, []byte("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e, err := NewExtension(ctx, componenttest.NewNopTelemetrySettings(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Start(ctx, componenttest.NewNopHost()); err != nil {
+		t.Fatal(err)
+	}
+	if status, body := get(t, e, ref); status != http.StatusOK || body != "hello" {
+		t.Errorf("GET %s = %d %q", ref, status, body)
+	}
+	if err := e.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestExtensionServesSharedVault(t *testing.T) {
+	ctx := context.Background()
+	storageCfg := promptvaultstorageextension.DefaultConfig()
+	storageCfg.Storage.Backend = "memory"
+	storage, err := promptvaultstorageextension.NewExtension(ctx, componenttest.NewNopTelemetrySettings(), storageCfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	host := testHost{
+		Host:       componenttest.NewNopHost(),
+		extensions: map[component.ID]component.Component{component.MustNewIDWithName("promptvaultstorage", "shared"): storage},
+	}
+	if err := storage.Start(ctx, host); err != nil {
+		t.Fatal(err)
+	}
+	defer storage.Shutdown(ctx)
+	ref, err := storage.Vault().Store(ctx, promptvaultprocessor.ObjectMeta{Key: "gen_ai.prompt"}, []byte("shared"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := DefaultConfig()
+	cfg.Storage.Extension = "promptvaultstorage/shared"
+	cfg.ServerConfig = serverConfig()
+	e, err := NewExtension(ctx, componenttest.NewNopTelemetrySettings(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Start(ctx, host); err != nil {
+		t.Fatal(err)
+	}
+	if status, body := get(t, e, ref); status != http.StatusOK || body != "shared" {
+		t.Errorf("GET %s = %d %q", ref, status, body)
+	}
+	if err := e.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+	// The storage extension owns the vault, which outlives the server.
+	if got, err := storage.Vault().Retrieve(ctx, ref); err != nil || string(got) != "shared" {
+		t.Errorf("after shutdown, shared vault returns %q, %v", got, err)
+	}
+}
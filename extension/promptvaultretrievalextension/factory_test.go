@@ -0,0 +1,63 @@
+package promptvaultretrievalextension
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/confmap"
+	"go.opentelemetry.io/collector/extension/extensiontest"
+
+	"github.com/airblackbox/otel-prompt-vault/internal/metadatatest"
+	"github.com/airblackbox/otel-prompt-vault/retrieval"
+)
+
+func TestMetadataMatchesFactory(t *testing.T) {
+	f := NewFactory()
+	metadatatest.Check(t, "extension", f.Type(), map[string]component.StabilityLevel{"extension": f.ExtensionStability()})
+}
+
+func TestFactoryCreatesExtension(t *testing.T) {
+	f := NewFactory()
+	cfg, ok := f.CreateDefaultConfig().(*Config)
+	if !ok || !reflect.DeepEqual(cfg, DefaultConfig()) {
+		t.Fatalf("default config = %#v, want DefaultConfig()", f.CreateDefaultConfig())
+	}
+	cfg.Storage.Backend = "memory"
+	cfg.Auth.APIKeys = []retrieval.APIKeyConfig{{Name: "oncall", Key: "oncall-key-0123456789"}}
+	ext, err := f.CreateExtension(context.Background(), extensiontest.NewNopSettings(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := ext.(*Extension); !ok {
+		t.Fatalf("factory created %T", ext)
+	}
+
+	cfg.SigningKey = "too short"
+	if _, err := f.CreateExtension(context.Background(), extensiontest.NewNopSettings(), cfg); err == nil {
+		t.Error("expected a short signing key to be refused")
+	}
+}
+
+// TestConfigUnmarshal checks that the server settings sit at the top level
+// of the extension's config, as in a collector config file.
+func TestConfigUnmarshal(t *testing.T) {
+	cfg := DefaultConfig()
+	conf := confmap.NewFromStringMap(map[string]any{
+		"storage":  map[string]any{"extension": "promptvaultstorage/shared"},
+		"endpoint": "0.0.0.0:8470",
+		"ui":       true,
+		"grpc":     map[string]any{"endpoint": "0.0.0.0:8471"},
+		"auth": map[string]any{
+			"api_keys": []any{map[string]any{"name": "oncall", "key": "oncall-key-0123456789"}},
+		},
+	})
+	if err := conf.Unmarshal(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Storage.Extension != "promptvaultstorage/shared" || cfg.Endpoint != "0.0.0.0:8470" || !cfg.UI ||
+		cfg.GRPC.Endpoint != "0.0.0.0:8471" || len(cfg.Auth.APIKeys) != 1 {
+		t.Errorf("unmarshaled %+v", cfg)
+	}
+}
@@ -0,0 +1,34 @@
+// Package promptvaultstorageextension owns one vault backend, with its
+// encryption and connections, for every promptvault component in a
+// collector that names it in storage.extension.
+package promptvaultstorageextension
+
+import (
+	"github.com/airblackbox/otel-prompt-vault/processor/promptvaultprocessor"
+
+	"go.opentelemetry.io/collector/config/configopaque"
+)
+
+// Config for the storage extension.
+type Config struct {
+	// Storage and Crypto take the same options as the promptvault
+	// processor's blocks, except storage.extension.
+	Storage promptvaultprocessor.StorageConfig `mapstructure:"storage"`
+	Crypto  promptvaultprocessor.CryptoConfig  `mapstructure:"crypto"`
+	// SigningKey signs the storage.manifest with HMAC. It should match the
+	// processors' vault.signing_key.
+	SigningKey configopaque.String `mapstructure:"signing_key"`
+	// Retention deletes expired objects in the background while the
+	// extension runs. No policies = keep everything. Objects are also
+	// compacted in the background when storage.bundles is configured.
+	Retention promptvaultprocessor.RetentionConfig `mapstructure:"retention"`
+}
+
+// DefaultConfig stores where the promptvault processor does by default.
+func DefaultConfig() *Config {
+	vaultCfg := promptvaultprocessor.NewFactory().CreateDefaultConfig().(*promptvaultprocessor.Config)
+	return &Config{
+		Storage: vaultCfg.Storage,
+		Crypto:  vaultCfg.Crypto,
+	}
+}
@@ -0,0 +1,90 @@
+package promptvaultstorageextension
+
+import (
+	"context"
+	"io"
+
+	"go.opentelemetry.io/collector/component"
+	"go.uber.org/zap"
+
+	"github.com/airblackbox/otel-prompt-vault/processor/promptvaultprocessor"
+)
+
+// Extension opens the vault once and hands it to the components that
+// reference it. Collector extensions start before and shut down after
+// pipelines, so the vault outlives every user.
+type Extension struct {
+	logger     *zap.Logger
+	vault      promptvaultprocessor.VaultStorage
+	retention  *promptvaultprocessor.Retention
+	compaction *promptvaultprocessor.Compaction
+}
+
+var _ promptvaultprocessor.VaultProvider = (*Extension)(nil)
+
+// NewExtension opens the vault cfg describes.
+func NewExtension(ctx context.Context, set component.TelemetrySettings, cfg *Config) (*Extension, error) {
+	logger := set.Logger
+	if err := promptvaultprocessor.CheckSecretsRedacted(cfg); err != nil {
+		return nil, err
+	}
+	vaultCfg := &promptvaultprocessor.Config{Storage: cfg.Storage, Crypto: cfg.Crypto}
+	vaultCfg.Vault.SigningKey = cfg.SigningKey
+	vault, err := promptvaultprocessor.OpenVault(ctx, logger, vaultCfg)
+	if err != nil {
+		return nil, err
+	}
+	e := &Extension{logger: logger, vault: vault}
+	if len(cfg.Retention.Policies) > 0 {
+		if e.retention, err = promptvaultprocessor.NewRetention(logger, set.MeterProvider, vault, cfg.Retention); err != nil {
+			closeVault(vault)
+			return nil, err
+		}
+	}
+	if cfg.Storage.Bundles.Index != "" {
+		if e.compaction, err = promptvaultprocessor.NewCompaction(logger, vault, cfg.Storage.Bundles); err != nil {
+			closeVault(vault)
+			return nil, err
+		}
+	}
+	return e, nil
+}
+
+// Vault returns the shared vault.
+func (e *Extension) Vault() promptvaultprocessor.VaultStorage {
+	return e.vault
+}
+
+func (e *Extension) Start(ctx context.Context, _ component.Host) error {
+	if s, ok := e.vault.(interface{ Start(context.Context) error }); ok {
+		if err := s.Start(ctx); err != nil {
+			return err
+		}
+	}
+	if e.retention != nil {
+		if err := e.retention.Start(ctx); err != nil {
+			return err
+		}
+	}
+	if e.compaction != nil {
+		return e.compaction.Start(ctx)
+	}
+	return nil
+}
+
+func (e *Extension) Shutdown(_ context.Context) error {
+	if e.retention != nil {
+		e.retention.Close()
+	}
+	if e.compaction != nil {
+		e.compaction.Close()
+	}
+	return closeVault(e.vault)
+}
+
+func closeVault(vault promptvaultprocessor.VaultStorage) error {
+	if c, ok := vault.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
@@ -0,0 +1,155 @@
+package promptvaultstorageextension
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.opentelemetry.io/collector/processor"
+
+	"github.com/airblackbox/otel-prompt-vault/processor/promptvaultprocessor"
+	"github.com/airblackbox/otel-prompt-vault/processor/promptvaultrehydrateprocessor"
+)
+
+type testHost struct {
+	component.Host
+	extensions map[component.ID]component.Component
+}
+
+func (h testHost) GetExtensions() map[component.ID]component.Component {
+	return h.extensions
+}
+
+type nopComponent struct {
+	component.StartFunc
+	component.ShutdownFunc
+}
+
+func settings(typ string) processor.Settings {
+	return processor.Settings{
+		ID:                component.MustNewID(typ),
+		TelemetrySettings: componenttest.NewNopTelemetrySettings(),
+	}
+}
+
+func TestProcessorsShareVault(t *testing.T) {
+	ctx := context.Background()
+	cfg := DefaultConfig()
+	cfg.Storage.Backend = "memory"
+	ext, err := NewExtension(ctx, componenttest.NewNopTelemetrySettings(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	host := testHost{
+		Host:       componenttest.NewNopHost(),
+		extensions: map[component.ID]component.Component{component.MustNewIDWithName("promptvaultstorage", "shared"): ext},
+	}
+	if err := ext.Start(ctx, host); err != nil {
+		t.Fatal(err)
+	}
+
+	// The promptvault processor writes to the shared in-memory vault...
+	vaultCfg := promptvaultprocessor.NewFactory().CreateDefaultConfig().(*promptvaultprocessor.Config)
+	vaultCfg.Storage.Extension = "promptvaultstorage/shared"
+	vaulted := new(consumertest.TracesSink)
+	vaultProc, err := promptvaultprocessor.NewFactory().CreateTracesProcessor(ctx, settings("promptvault"), vaultCfg, vaulted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// ...and the rehydrate processor reads from it.
+	rehydrateCfg := promptvaultrehydrateprocessor.NewFactory().CreateDefaultConfig().(*promptvaultrehydrateprocessor.Config)
+	rehydrateCfg.Storage.Extension = "promptvaultstorage/shared"
+	rehydrated := new(consumertest.TracesSink)
+	rehydrateProc, err := promptvaultrehydrateprocessor.NewFactory().CreateTracesProcessor(ctx, settings("promptvaultrehydrate"), rehydrateCfg, rehydrated)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []component.Component{vaultProc, rehydrateProc} {
+		if err := c.Start(ctx, host); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	td := ptrace.NewTraces()
+	span := td.ResourceSpans().AppendEmpty().ScopeSpans().AppendEmpty().Spans().AppendEmpty()
+	span.Attributes().PutStr("gen_ai.prompt", "shared backend prompt")
+	if err := vaultProc.ConsumeTraces(ctx, td); err != nil {
+		t.Fatal(err)
+	}
+	v, _ := vaulted.AllTraces()[0].ResourceSpans().At(0).ScopeSpans().At(0).Spans().At(0).Attributes().Get("gen_ai.prompt")
+	ref := v.Str()
+	if !strings.HasPrefix(ref, "vault://") {
+		t.Fatalf("prompt not vaulted: %q", ref)
+	}
+	if err := rehydrateProc.ConsumeTraces(ctx, vaulted.AllTraces()[0]); err != nil {
+		t.Fatal(err)
+	}
+	got, _ := rehydrated.AllTraces()[0].ResourceSpans().At(0).ScopeSpans().At(0).Spans().At(0).Attributes().Get("gen_ai.prompt")
+	if got.Str() != "shared backend prompt" {
+		t.Errorf("rehydrated %q", got.Str())
+	}
+
+	// Processors shutting down must leave the vault to the extension.
+	for _, c := range []component.Component{vaultProc, rehydrateProc} {
+		if err := c.Shutdown(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := ext.Vault().Retrieve(ctx, ref); err != nil {
+		t.Errorf("vault unusable after processor shutdown: %v", err)
+	}
+	if err := ext.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestVaultFromHostErrors(t *testing.T) {
+	host := testHost{
+		Host:       componenttest.NewNopHost(),
+		extensions: map[component.ID]component.Component{component.MustNewID("health_check"): nopComponent{}},
+	}
+	for _, id := range []string{"promptvaultstorage", "health_check", "not a valid/id/"} {
+		if _, err := promptvaultprocessor.VaultFromHost(host, id); err == nil {
+			t.Errorf("VaultFromHost(%q): expected an error", id)
+		}
+	}
+}
+
+func TestExtensionRunsRetention(t *testing.T) {
+	ctx := context.Background()
+	cfg := DefaultConfig()
+	cfg.Storage.Backend = "memory"
+	cfg.Retention.Policies = []promptvaultprocessor.RetentionPolicy{{Keys: []string{"gen_ai.*"}, MaxAge: time.Nanosecond}}
+	ext, err := NewExtension(ctx, componenttest.NewNopTelemetrySettings(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expired, _ := ext.Vault().Store(ctx, promptvaultprocessor.ObjectMeta{Key: "gen_ai.prompt"}, []byte("old prompt"))
+	kept, _ := ext.Vault().Store(ctx, promptvaultprocessor.ObjectMeta{Key: "http.request.body"}, []byte("request"))
+	time.Sleep(time.Millisecond)
+
+	if err := ext.Start(ctx, componenttest.NewNopHost()); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, err := ext.Vault().Retrieve(ctx, expired); err != nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expired object not deleted by the retention sweep")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if _, err := ext.Vault().Retrieve(ctx, kept); err != nil {
+		t.Errorf("object outside every policy deleted: %v", err)
+	}
+	if err := ext.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,27 @@
+package promptvaultstorageextension
+
+import (
+	"context"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/extension"
+)
+
+const (
+	typeStr   = "promptvaultstorage"
+	stability = component.StabilityLevelAlpha
+)
+
+// NewFactory creates a factory for the storage extension.
+func NewFactory() extension.Factory {
+	return extension.NewFactory(
+		component.MustNewType(typeStr),
+		func() component.Config { return DefaultConfig() },
+		createExtension,
+		stability,
+	)
+}
+
+func createExtension(ctx context.Context, set extension.Settings, cfg component.Config) (extension.Extension, error) {
+	return NewExtension(ctx, set.TelemetrySettings, cfg.(*Config))
+}
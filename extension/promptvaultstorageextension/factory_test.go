@@ -0,0 +1,50 @@
+package promptvaultstorageextension
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/extension/extensiontest"
+
+	"github.com/airblackbox/otel-prompt-vault/internal/metadatatest"
+	"github.com/airblackbox/otel-prompt-vault/processor/promptvaultprocessor"
+)
+
+func TestMetadataMatchesFactory(t *testing.T) {
+	f := NewFactory()
+	metadatatest.Check(t, "extension", f.Type(), map[string]component.StabilityLevel{"extension": f.ExtensionStability()})
+}
+
+func TestFactoryCreatesExtension(t *testing.T) {
+	ctx := context.Background()
+	f := NewFactory()
+	cfg, ok := f.CreateDefaultConfig().(*Config)
+	if !ok || !reflect.DeepEqual(cfg, DefaultConfig()) {
+		t.Fatalf("default config = %#v, want DefaultConfig()", f.CreateDefaultConfig())
+	}
+	cfg.Storage.Backend = "memory"
+	ext, err := f.CreateExtension(ctx, extensiontest.NewNopSettings(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	provider, ok := ext.(promptvaultprocessor.VaultProvider)
+	if !ok {
+		t.Fatalf("extension %T does not provide a vault", ext)
+	}
+	if err := ext.Start(ctx, componenttest.NewNopHost()); err != nil {
+		t.Fatal(err)
+	}
+	ref, err := provider.Vault().Store(ctx, promptvaultprocessor.ObjectMeta{Key: "gen_ai.prompt"}, []byte("through the factory"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := provider.Vault().Retrieve(ctx, ref); err != nil || string(got) != "through the factory" {
+		t.Errorf("retrieve %s = %q, %v", ref, got, err)
+	}
+	if err := ext.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,15 @@
+// Package sdnotifyextension reports the collector's lifecycle to systemd
+// through the sd_notify protocol, so otelcol-promptvault can run as a
+// Type=notify unit: it sends READY=1 once every pipeline has started and
+// WATCHDOG=1 pings while the unit has WatchdogSec set.
+package sdnotifyextension
+
+// Config for the sd_notify extension. systemd passes everything the
+// extension needs in NOTIFY_SOCKET and WATCHDOG_USEC, so there are no
+// settings; outside systemd it does nothing.
+type Config struct{}
+
+// DefaultConfig returns the only config.
+func DefaultConfig() *Config {
+	return &Config{}
+}
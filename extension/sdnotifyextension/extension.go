@@ -0,0 +1,124 @@
+package sdnotifyextension
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/extension"
+	"go.uber.org/zap"
+)
+
+// Extension notifies systemd that the collector is ready and pings its
+// watchdog.
+type Extension struct {
+	logger *zap.Logger
+	conn   *net.UnixConn
+	// interval is how often the watchdog is pinged. Zero = the unit has
+	// no watchdog.
+	interval time.Duration
+	done     chan struct{}
+	wg       sync.WaitGroup
+}
+
+var _ extension.PipelineWatcher = (*Extension)(nil)
+
+// NewExtension returns an extension notifying the socket systemd names in
+// the environment, if any.
+func NewExtension(set component.TelemetrySettings, _ *Config) *Extension {
+	return &Extension{logger: set.Logger, done: make(chan struct{})}
+}
+
+// Start connects to NOTIFY_SOCKET and, if systemd expects watchdog pings
+// from this process, starts sending them at half the WATCHDOG_USEC timeout,
+// as sd_watchdog_enabled(3) recommends.
+func (e *Extension) Start(context.Context, component.Host) error {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		e.logger.Debug("NOTIFY_SOCKET is not set; not notifying systemd")
+		return nil
+	}
+	interval, err := watchdogInterval()
+	if err != nil {
+		return err
+	}
+	// A leading @ names a socket in the abstract namespace, which the net
+	// package resolves the same way systemd does.
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		return fmt.Errorf("NOTIFY_SOCKET %q: %w", socket, err)
+	}
+	e.conn = conn
+	e.interval = interval
+	if e.interval > 0 {
+		e.wg.Add(1)
+		go e.watchdog()
+	}
+	return nil
+}
+
+// watchdogInterval returns half of the WATCHDOG_USEC timeout, or zero if
+// the watchdog is off or meant for another process.
+func watchdogInterval() (time.Duration, error) {
+	usec := os.Getenv("WATCHDOG_USEC")
+	if usec == "" {
+		return 0, nil
+	}
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return 0, nil
+	}
+	n, err := strconv.ParseInt(usec, 10, 64)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("WATCHDOG_USEC %q: want a positive number of microseconds", usec)
+	}
+	return time.Duration(n) * time.Microsecond / 2, nil
+}
+
+func (e *Extension) watchdog() {
+	defer e.wg.Done()
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-e.done:
+			return
+		case <-ticker.C:
+			if err := e.notify("WATCHDOG=1"); err != nil {
+				e.logger.Warn("systemd watchdog ping failed", zap.Error(err))
+			}
+		}
+	}
+}
+
+// Ready tells systemd the collector has started, once all pipelines are
+// running. After a config reload it is sent again, which systemd ignores.
+func (e *Extension) Ready() error {
+	return e.notify("READY=1")
+}
+
+func (e *Extension) NotReady() error {
+	return nil
+}
+
+func (e *Extension) Shutdown(context.Context) error {
+	if e.conn == nil {
+		return nil
+	}
+	close(e.done)
+	e.wg.Wait()
+	return e.conn.Close()
+}
+
+// notify sends state to systemd, if it is listening.
+func (e *Extension) notify(state string) error {
+	if e.conn == nil {
+		return nil
+	}
+	_, err := e.conn.Write([]byte(state))
+	return err
+}
@@ -0,0 +1,116 @@
+package sdnotifyextension
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/collector/component/componenttest"
+)
+
+// listen stands in for systemd: it listens on a notify socket, names it in
+// NOTIFY_SOCKET, and returns it.
+func listen(t *testing.T) *net.UnixConn {
+	t.Helper()
+	// Socket paths are limited to about 100 bytes, which t.TempDir can
+	// exceed.
+	dir, err := os.MkdirTemp("", "sdnotify")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	path := filepath.Join(dir, "notify")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	t.Setenv("NOTIFY_SOCKET", path)
+	return conn
+}
+
+// receive returns the next state sent to conn.
+func receive(t *testing.T, conn *net.UnixConn) string {
+	t.Helper()
+	buf := make([]byte, 256)
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(buf[:n])
+}
+
+func TestExtensionNotifiesReadyAndWatchdog(t *testing.T) {
+	conn := listen(t)
+	t.Setenv("WATCHDOG_USEC", "20000")
+	t.Setenv("WATCHDOG_PID", strconv.Itoa(os.Getpid()))
+
+	e := NewExtension(componenttest.NewNopTelemetrySettings(), DefaultConfig())
+	if err := e.Start(context.Background(), componenttest.NewNopHost()); err != nil {
+		t.Fatal(err)
+	}
+	if e.interval != 10*time.Millisecond {
+		t.Errorf("watchdog interval = %v, want half of WATCHDOG_USEC", e.interval)
+	}
+	if err := e.Ready(); err != nil {
+		t.Fatal(err)
+	}
+	// Pings may arrive before READY=1; it must come, followed by more pings.
+	var ready, pings int
+	for ready == 0 || pings < 2 {
+		switch state := receive(t, conn); state {
+		case "READY=1":
+			ready++
+		case "WATCHDOG=1":
+			pings++
+		default:
+			t.Fatalf("unexpected state %q", state)
+		}
+	}
+	if err := e.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestExtensionWatchdogForAnotherProcess(t *testing.T) {
+	listen(t)
+	t.Setenv("WATCHDOG_USEC", "20000")
+	t.Setenv("WATCHDOG_PID", "1")
+
+	e := NewExtension(componenttest.NewNopTelemetrySettings(), DefaultConfig())
+	if err := e.Start(context.Background(), componenttest.NewNopHost()); err != nil {
+		t.Fatal(err)
+	}
+	if e.interval != 0 {
+		t.Errorf("pinging every %v for WATCHDOG_PID 1", e.interval)
+	}
+	if err := e.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("WATCHDOG_PID", "")
+	t.Setenv("WATCHDOG_USEC", "soon")
+	e = NewExtension(componenttest.NewNopTelemetrySettings(), DefaultConfig())
+	if err := e.Start(context.Background(), componenttest.NewNopHost()); err == nil {
+		t.Error("expected a malformed WATCHDOG_USEC to be refused")
+	}
+}
+
+func TestExtensionOutsideSystemd(t *testing.T) {
+	t.Setenv("NOTIFY_SOCKET", "")
+	e := NewExtension(componenttest.NewNopTelemetrySettings(), DefaultConfig())
+	if err := e.Start(context.Background(), componenttest.NewNopHost()); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Ready(); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
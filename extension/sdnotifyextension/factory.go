@@ -0,0 +1,27 @@
+package sdnotifyextension
+
+import (
+	"context"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/extension"
+)
+
+const (
+	typeStr   = "sdnotify"
+	stability = component.StabilityLevelAlpha
+)
+
+// NewFactory creates a factory for the sd_notify extension.
+func NewFactory() extension.Factory {
+	return extension.NewFactory(
+		component.MustNewType(typeStr),
+		func() component.Config { return DefaultConfig() },
+		createExtension,
+		stability,
+	)
+}
+
+func createExtension(_ context.Context, set extension.Settings, cfg component.Config) (extension.Extension, error) {
+	return NewExtension(set.TelemetrySettings, cfg.(*Config)), nil
+}
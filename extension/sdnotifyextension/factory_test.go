@@ -0,0 +1,28 @@
+package sdnotifyextension
+
+import (
+	"context"
+	"testing"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/extension"
+	"go.opentelemetry.io/collector/extension/extensiontest"
+
+	"github.com/airblackbox/otel-prompt-vault/internal/metadatatest"
+)
+
+func TestMetadataMatchesFactory(t *testing.T) {
+	f := NewFactory()
+	metadatatest.Check(t, "extension", f.Type(), map[string]component.StabilityLevel{"extension": f.ExtensionStability()})
+}
+
+func TestFactoryCreatesPipelineWatcher(t *testing.T) {
+	f := NewFactory()
+	ext, err := f.CreateExtension(context.Background(), extensiontest.NewNopSettings(), f.CreateDefaultConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := ext.(extension.PipelineWatcher); !ok {
+		t.Errorf("extension %T does not watch pipelines, so it cannot report readiness", ext)
+	}
+}
@@ -0,0 +1,56 @@
+// Package metadatatest checks a component's metadata.yaml, which collector
+// builds and registries read, against its factory.
+package metadatatest
+
+import (
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/collector/component"
+	"gopkg.in/yaml.v3"
+)
+
+// Check reads metadata.yaml from the test's working directory and fails t
+// unless it declares typ and class, and exactly the signals in stability
+// that the factory supports at their levels. Signals reported as
+// component.StabilityLevelUndefined are unsupported and must be absent.
+func Check(t *testing.T, class string, typ component.Type, stability map[string]component.StabilityLevel) {
+	t.Helper()
+	data, err := os.ReadFile("metadata.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var md struct {
+		Type   string `yaml:"type"`
+		Status struct {
+			Class     string              `yaml:"class"`
+			Stability map[string][]string `yaml:"stability"`
+		} `yaml:"status"`
+	}
+	if err := yaml.Unmarshal(data, &md); err != nil {
+		t.Fatal(err)
+	}
+	if md.Type != typ.String() || md.Status.Class != class {
+		t.Errorf("metadata.yaml declares %s %q, factory is %s %q", md.Status.Class, md.Type, class, typ)
+	}
+
+	want := make(map[string][]string)
+	for signal, level := range stability {
+		if level == component.StabilityLevelUndefined {
+			continue
+		}
+		key := strings.ToLower(level.String())
+		want[key] = append(want[key], signal)
+	}
+	for _, m := range []map[string][]string{want, md.Status.Stability} {
+		for _, signals := range m {
+			sort.Strings(signals)
+		}
+	}
+	if !reflect.DeepEqual(md.Status.Stability, want) {
+		t.Errorf("metadata.yaml stability = %v, want %v", md.Status.Stability, want)
+	}
+}
@@ -0,0 +1,98 @@
+package promptvaultprocessor
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"filippo.io/age"
+	"filippo.io/age/armor"
+)
+
+// AgeEncryptor stores objects in the standard age format, so they can be
+// decrypted with the age CLI during incident response.
+type AgeEncryptor struct {
+	recipients []age.Recipient
+	identities []age.Identity
+	keyID      string
+	armor      bool
+}
+
+// NewAgeEncryptor parses the configured X25519 recipients and, if set, the
+// identity file used to decrypt on retrieval.
+func NewAgeEncryptor(cfg AgeConfig) (*AgeEncryptor, error) {
+	if len(cfg.Recipients) == 0 {
+		return nil, fmt.Errorf("crypto.age.recipients is required")
+	}
+	e := &AgeEncryptor{
+		keyID: strings.Join(cfg.Recipients, ","),
+		armor: cfg.Armor,
+	}
+	for _, r := range cfg.Recipients {
+		rcpt, err := age.ParseX25519Recipient(r)
+		if err != nil {
+			return nil, fmt.Errorf("parse age recipient: %w", err)
+		}
+		e.recipients = append(e.recipients, rcpt)
+	}
+	if cfg.IdentityFile != "" {
+		f, err := os.Open(cfg.IdentityFile)
+		if err != nil {
+			return nil, fmt.Errorf("open age identity file: %w", err)
+		}
+		defer f.Close()
+		e.identities, err = age.ParseIdentities(f)
+		if err != nil {
+			return nil, fmt.Errorf("parse age identity file: %w", err)
+		}
+	}
+	return e, nil
+}
+
+// Encrypt writes plaintext as an age file to every recipient. The key ID is
+// the comma-separated recipient list. The age format has no associated data,
+// so aad is ignored and age objects are not bound to their span.
+func (e *AgeEncryptor) Encrypt(_ context.Context, plaintext, _ []byte) ([]byte, string, error) {
+	var buf bytes.Buffer
+	var out io.Writer = &buf
+	var aw io.WriteCloser
+	if e.armor {
+		aw = armor.NewWriter(&buf)
+		out = aw
+	}
+	w, err := age.Encrypt(out, e.recipients...)
+	if err != nil {
+		return nil, "", fmt.Errorf("age encrypt: %w", err)
+	}
+	if _, err := w.Write(plaintext); err != nil {
+		return nil, "", fmt.Errorf("age encrypt: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return nil, "", fmt.Errorf("age encrypt: %w", err)
+	}
+	if aw != nil {
+		if err := aw.Close(); err != nil {
+			return nil, "", fmt.Errorf("age armor: %w", err)
+		}
+	}
+	return buf.Bytes(), e.keyID, nil
+}
+
+// Decrypt opens an age file, armored or binary, with the configured identities.
+func (e *AgeEncryptor) Decrypt(_ context.Context, data, _ []byte) ([]byte, error) {
+	if len(e.identities) == 0 {
+		return nil, fmt.Errorf("crypto.age.identity_file is required to decrypt")
+	}
+	var in io.Reader = bytes.NewReader(data)
+	if bytes.HasPrefix(data, []byte(armor.Header)) {
+		in = armor.NewReader(in)
+	}
+	r, err := age.Decrypt(in, e.identities...)
+	if err != nil {
+		return nil, fmt.Errorf("age decrypt: %w", err)
+	}
+	return io.ReadAll(r)
+}
@@ -0,0 +1,76 @@
+package promptvaultprocessor
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"filippo.io/age"
+	"filippo.io/age/armor"
+)
+
+func TestAgeEncryptorInteroperates(t *testing.T) {
+	ctx := context.Background()
+	id, _ := age.GenerateX25519Identity()
+	idFile := filepath.Join(t.TempDir(), "key.txt")
+	os.WriteFile(idFile, []byte(id.String()+"\n"), 0o600)
+
+	for _, armored := range []bool{false, true} {
+		enc, err := NewAgeEncryptor(AgeConfig{
+			Recipients:   []string{id.Recipient().String()},
+			IdentityFile: idFile,
+			Armor:        armored,
+		})
+		if err != nil {
+			t.Fatalf("new age encryptor: %v", err)
+		}
+
+		sealed, keyID, err := enc.Encrypt(ctx, []byte("incident data"), nil)
+		if err != nil {
+			t.Fatalf("encrypt: %v", err)
+		}
+		if keyID != id.Recipient().String() {
+			t.Errorf("expected recipient as key id, got %q", keyID)
+		}
+		if armored != bytes.HasPrefix(sealed, []byte(armor.Header)) {
+			t.Errorf("armor=%v but output starts %q", armored, sealed[:20])
+		}
+
+		// Standard age tooling must be able to read the object directly.
+		var in io.Reader = bytes.NewReader(sealed)
+		if armored {
+			in = armor.NewReader(in)
+		}
+		r, err := age.Decrypt(in, id)
+		if err != nil {
+			t.Fatalf("age.Decrypt: %v", err)
+		}
+		plain, _ := io.ReadAll(r)
+		if string(plain) != "incident data" {
+			t.Errorf("expected plaintext via age, got %q", plain)
+		}
+
+		data, err := enc.Decrypt(ctx, sealed, nil)
+		if err != nil || string(data) != "incident data" {
+			t.Errorf("expected round trip, got %q, %v", data, err)
+		}
+	}
+}
+
+func TestAgeEncryptorRequiresIdentityToDecrypt(t *testing.T) {
+	id, _ := age.GenerateX25519Identity()
+	enc, err := NewAgeEncryptor(AgeConfig{Recipients: []string{id.Recipient().String()}})
+	if err != nil {
+		t.Fatalf("new age encryptor: %v", err)
+	}
+	sealed, _, _ := enc.Encrypt(context.Background(), []byte("x"), nil)
+	if _, err := enc.Decrypt(context.Background(), sealed, nil); err == nil {
+		t.Error("expected error without identity file")
+	}
+	if _, err := NewAgeEncryptor(AgeConfig{Recipients: []string{"not-a-recipient"}}); err == nil {
+		t.Error("expected error for invalid recipient")
+	}
+}
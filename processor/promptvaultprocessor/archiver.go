@@ -0,0 +1,28 @@
+package promptvaultprocessor
+
+import (
+	"context"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/consumer"
+)
+
+// Archiver stores the content it matches in the traces it consumes and
+// leaves them as received.
+type Archiver interface {
+	component.Component
+	consumer.Traces
+}
+
+// NewArchiver builds an Archiver that selects, labels, and stores content as
+// a promptvault processor configured with cfg would, for components that
+// tee payloads into the vault outside the spans' path. Settings that only
+// shape the span, such as vault.mode and vault.signing_key, have no effect.
+func NewArchiver(ctx context.Context, set component.TelemetrySettings, cfg *Config) (Archiver, error) {
+	p, err := newTracesProcessor(ctx, set, cfg, nil)
+	if err != nil {
+		return nil, err
+	}
+	p.archive = true
+	return p, nil
+}
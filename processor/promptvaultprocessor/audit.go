@@ -0,0 +1,320 @@
+package promptvaultprocessor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/exporters/otlp/otlplog/otlploghttp"
+	otellog "go.opentelemetry.io/otel/log"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+)
+
+// AuditRecord describes one retrieval of vault content.
+type AuditRecord struct {
+	Time time.Time `json:"time"`
+	// Principal identifies who retrieved the content; see WithPrincipal.
+	Principal string `json:"principal"`
+	// Source names the component that performed the retrieval, e.g. "cli".
+	Source  string `json:"source"`
+	Ref     string `json:"ref"`
+	Outcome string `json:"outcome"` // "ok" or "error"
+	Error   string `json:"error,omitempty"`
+}
+
+// AuditSink records retrievals. Implementations must be safe for concurrent
+// use and must not return until the record is durable.
+type AuditSink interface {
+	Record(ctx context.Context, rec AuditRecord) error
+}
+
+type principalKey struct{}
+
+// WithPrincipal attaches the identity of the party retrieving content to
+// ctx, for audit records.
+func WithPrincipal(ctx context.Context, principal string) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+func principalFrom(ctx context.Context) string {
+	p, _ := ctx.Value(principalKey{}).(string)
+	return p
+}
+
+// NewAuditSink builds the sink selected by cfg.Sink, or nil when auditing is
+// disabled. vault is the backend the "vault" sink writes records to.
+func NewAuditSink(ctx context.Context, cfg AuditConfig, vault VaultStorage) (AuditSink, error) {
+	switch cfg.Sink {
+	case "":
+		return nil, nil
+	case "file":
+		return newFileAuditSink(cfg.File)
+	case "otlp":
+		return newOTLPAuditSink(ctx, cfg.OTLP)
+	case "vault":
+		if vault == nil {
+			return nil, fmt.Errorf("audit sink vault requires a vault backend")
+		}
+		return &vaultAuditSink{vault: vault}, nil
+	default:
+		return nil, fmt.Errorf("unknown audit sink %q", cfg.Sink)
+	}
+}
+
+// NewAuditingVault records every Retrieve through vault to sink, attributed
+// to source. Retrieval fails closed: content is withheld if its record
+// cannot be written.
+func NewAuditingVault(vault VaultStorage, sink AuditSink, source string) VaultStorage {
+	if sink == nil {
+		return vault
+	}
+	return &auditingVault{VaultStorage: vault, sink: sink, source: source, now: time.Now}
+}
+
+type auditingVault struct {
+	VaultStorage
+	sink   AuditSink
+	source string
+	now    func() time.Time
+}
+
+func (v *auditingVault) Retrieve(ctx context.Context, ref string) ([]byte, error) {
+	content, err := v.VaultStorage.Retrieve(ctx, ref)
+	return v.audit(ctx, ref, content, err)
+}
+
+// RetrieveFor is Retrieve bound to meta; it is audited the same way.
+func (v *auditingVault) RetrieveFor(ctx context.Context, ref string, meta ObjectMeta) ([]byte, error) {
+	content, err := RetrieveFor(ctx, v.VaultStorage, ref, meta)
+	return v.audit(ctx, ref, content, err)
+}
+
+func (v *auditingVault) audit(ctx context.Context, ref string, content []byte, err error) ([]byte, error) {
+	rec := AuditRecord{
+		Time:      v.now().UTC(),
+		Principal: principalFrom(ctx),
+		Source:    v.source,
+		Ref:       ref,
+		Outcome:   "ok",
+	}
+	if err != nil {
+		rec.Outcome = "error"
+		rec.Error = err.Error()
+	}
+	if auditErr := v.sink.Record(ctx, rec); auditErr != nil {
+		return nil, errors.Join(err, fmt.Errorf("audit retrieval of %s: %w", ref, auditErr))
+	}
+	return content, err
+}
+
+// List, Stat, Search, Compact, Usage, Delete, Replace, Start, and Close forward to the wrapped backend;
+// Close also closes the sink.
+
+func (v *auditingVault) List(ctx context.Context, fn func(ObjectInfo) error) error {
+	if l, ok := v.VaultStorage.(VaultLister); ok {
+		return l.List(ctx, fn)
+	}
+	return fmt.Errorf("list: %w", errors.ErrUnsupported)
+}
+
+func (v *auditingVault) Stat(ctx context.Context, ref string) (ObjectInfo, error) {
+	if s, ok := v.VaultStorage.(VaultStatter); ok {
+		return s.Stat(ctx, ref)
+	}
+	return ObjectInfo{}, fmt.Errorf("stat: %w", errors.ErrUnsupported)
+}
+
+func (v *auditingVault) Search(ctx context.Context, f ObjectFilter, fn func(ObjectInfo) error) error {
+	if s, ok := v.VaultStorage.(VaultSearcher); ok {
+		return s.Search(ctx, f, fn)
+	}
+	return fmt.Errorf("search: %w", errors.ErrUnsupported)
+}
+
+func (v *auditingVault) Compact(ctx context.Context) (CompactionStats, error) {
+	if c, ok := v.VaultStorage.(VaultCompactor); ok {
+		return c.Compact(ctx)
+	}
+	return CompactionStats{}, fmt.Errorf("compact: %w", errors.ErrUnsupported)
+}
+
+func (v *auditingVault) Usage(ctx context.Context) ([]TenantUsage, error) {
+	if u, ok := v.VaultStorage.(VaultUsageReporter); ok {
+		return u.Usage(ctx)
+	}
+	return nil, fmt.Errorf("usage: %w", errors.ErrUnsupported)
+}
+
+func (v *auditingVault) Stats(ctx context.Context) (VaultStats, error) {
+	if r, ok := v.VaultStorage.(VaultStatsReporter); ok {
+		return r.Stats(ctx)
+	}
+	return VaultStats{}, fmt.Errorf("stats: %w", errors.ErrUnsupported)
+}
+
+func (v *auditingVault) Delete(ctx context.Context, ref string) error {
+	if d, ok := v.VaultStorage.(VaultDeleter); ok {
+		return d.Delete(ctx, ref)
+	}
+	return fmt.Errorf("delete: %w", errors.ErrUnsupported)
+}
+
+func (v *auditingVault) Replace(ctx context.Context, ref string, content []byte) error {
+	if r, ok := v.VaultStorage.(VaultReplacer); ok {
+		return r.Replace(ctx, ref, content)
+	}
+	return fmt.Errorf("replace: %w", errors.ErrUnsupported)
+}
+
+func (v *auditingVault) Start(ctx context.Context) error {
+	if s, ok := v.VaultStorage.(vaultStarter); ok {
+		return s.Start(ctx)
+	}
+	return nil
+}
+
+func (v *auditingVault) Close() error {
+	var errs []error
+	for _, c := range []any{v.sink, v.VaultStorage} {
+		if closer, ok := c.(io.Closer); ok {
+			errs = append(errs, closer.Close())
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// fileAuditSink appends JSON lines to a file opened append-only.
+type fileAuditSink struct {
+	mu sync.Mutex
+	f  *os.File
+}
+
+func newFileAuditSink(path string) (*fileAuditSink, error) {
+	if path == "" {
+		return nil, fmt.Errorf("audit sink file requires audit.file")
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("open audit log: %w", err)
+	}
+	return &fileAuditSink{f: f}, nil
+}
+
+func (s *fileAuditSink) Record(_ context.Context, rec AuditRecord) error {
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.f.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	return s.f.Sync()
+}
+
+func (s *fileAuditSink) Close() error {
+	return s.f.Close()
+}
+
+// otlpAuditSink exports each record as an OTLP log record. Records are
+// exported synchronously so Record reports delivery failures.
+type otlpAuditSink struct {
+	provider *sdklog.LoggerProvider
+	logger   otellog.Logger
+}
+
+// exportErrKey carries the *error that syncExportProcessor stores the
+// export result in. Logger.Emit only hands processor errors to otel.Handle,
+// so this is how Record learns whether its record was delivered.
+type exportErrKey struct{}
+
+// syncExportProcessor exports each record as it is emitted.
+type syncExportProcessor struct {
+	exporter sdklog.Exporter
+}
+
+func (p *syncExportProcessor) OnEmit(ctx context.Context, r sdklog.Record) error {
+	err := p.exporter.Export(ctx, []sdklog.Record{r})
+	if slot, ok := ctx.Value(exportErrKey{}).(*error); ok {
+		*slot = err
+		// Record returns the error; don't report it twice.
+		return nil
+	}
+	return err
+}
+
+func (p *syncExportProcessor) Enabled(context.Context, sdklog.Record) bool { return true }
+
+func (p *syncExportProcessor) Shutdown(ctx context.Context) error {
+	return p.exporter.Shutdown(ctx)
+}
+
+func (p *syncExportProcessor) ForceFlush(ctx context.Context) error {
+	return p.exporter.ForceFlush(ctx)
+}
+
+func newOTLPAuditSink(ctx context.Context, cfg AuditOTLPConfig) (*otlpAuditSink, error) {
+	if cfg.Endpoint == "" {
+		return nil, fmt.Errorf("audit sink otlp requires audit.otlp.endpoint")
+	}
+	headers := make(map[string]string, len(cfg.Headers))
+	for k, v := range cfg.Headers {
+		headers[k] = string(v)
+	}
+	exporter, err := otlploghttp.New(ctx,
+		otlploghttp.WithEndpointURL(cfg.Endpoint),
+		otlploghttp.WithHeaders(headers),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("create otlp audit exporter: %w", err)
+	}
+	provider := sdklog.NewLoggerProvider(sdklog.WithProcessor(&syncExportProcessor{exporter: exporter}))
+	return &otlpAuditSink{provider: provider, logger: provider.Logger(scopeName)}, nil
+}
+
+func (s *otlpAuditSink) Record(ctx context.Context, rec AuditRecord) error {
+	var r otellog.Record
+	r.SetTimestamp(rec.Time)
+	r.SetSeverity(otellog.SeverityInfo)
+	r.SetBody(otellog.StringValue("vault retrieval"))
+	r.AddAttributes(
+		otellog.String("vault.principal", rec.Principal),
+		otellog.String("vault.source", rec.Source),
+		otellog.String("vault.ref", rec.Ref),
+		otellog.String("vault.outcome", rec.Outcome),
+	)
+	if rec.Error != "" {
+		r.AddAttributes(otellog.String("vault.error", rec.Error))
+	}
+	var exportErr error
+	s.logger.Emit(context.WithValue(ctx, exportErrKey{}, &exportErr), r)
+	if exportErr != nil {
+		return fmt.Errorf("export audit record: %w", exportErr)
+	}
+	return nil
+}
+
+func (s *otlpAuditSink) Close() error {
+	return s.provider.Shutdown(context.Background())
+}
+
+// vaultAuditSink stores each record as an object in a vault backend, under
+// the attribute key "audit".
+type vaultAuditSink struct {
+	vault VaultStorage
+}
+
+func (s *vaultAuditSink) Record(ctx context.Context, rec AuditRecord) error {
+	body, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	_, err = s.vault.Store(ctx, ObjectMeta{Key: "audit"}, body)
+	return err
+}
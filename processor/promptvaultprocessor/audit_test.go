@@ -0,0 +1,145 @@
+package promptvaultprocessor
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/collector/config/configopaque"
+)
+
+func TestAuditingVaultFileSink(t *testing.T) {
+	ctx := WithPrincipal(context.Background(), "alice")
+	mem := NewMemoryVault(0, 0)
+	ref, _ := mem.Store(ctx, ObjectMeta{}, []byte("secret prompt"))
+
+	logPath := filepath.Join(t.TempDir(), "audit.log")
+	sink, err := NewAuditSink(ctx, AuditConfig{Sink: "file", File: logPath}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vault := NewAuditingVault(mem, sink, "cli")
+	defer vault.(*auditingVault).Close()
+
+	if got, err := vault.Retrieve(ctx, ref); err != nil || string(got) != "secret prompt" {
+		t.Fatalf("Retrieve = %q, %v", got, err)
+	}
+	if _, err := vault.Retrieve(ctx, "vault://missing"); err == nil {
+		t.Fatal("expected error for missing ref")
+	}
+
+	f, err := os.Open(logPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var recs []AuditRecord
+	for sc := bufio.NewScanner(f); sc.Scan(); {
+		var rec AuditRecord
+		if err := json.Unmarshal(sc.Bytes(), &rec); err != nil {
+			t.Fatal(err)
+		}
+		recs = append(recs, rec)
+	}
+	if len(recs) != 2 {
+		t.Fatalf("got %d records, want 2", len(recs))
+	}
+	if r := recs[0]; r.Principal != "alice" || r.Source != "cli" || r.Ref != ref || r.Outcome != "ok" || r.Time.IsZero() {
+		t.Errorf("ok record = %+v", r)
+	}
+	if r := recs[1]; r.Outcome != "error" || r.Error == "" {
+		t.Errorf("error record = %+v", r)
+	}
+}
+
+type failingSink struct{}
+
+func (failingSink) Record(context.Context, AuditRecord) error { return errors.New("disk full") }
+
+func TestAuditingVaultFailsClosed(t *testing.T) {
+	ctx := context.Background()
+	mem := NewMemoryVault(0, 0)
+	ref, _ := mem.Store(ctx, ObjectMeta{}, []byte("secret prompt"))
+
+	got, err := NewAuditingVault(mem, failingSink{}, "api").Retrieve(ctx, ref)
+	if err == nil || got != nil {
+		t.Errorf("Retrieve = %q, %v; want content withheld", got, err)
+	}
+}
+
+func TestVaultAuditSink(t *testing.T) {
+	ctx := context.Background()
+	mem := NewMemoryVault(0, 0)
+	sink, _ := NewAuditSink(ctx, AuditConfig{Sink: "vault"}, mem)
+	if err := sink.Record(ctx, AuditRecord{Ref: "vault://x", Outcome: "ok"}); err != nil {
+		t.Fatal(err)
+	}
+	var n int
+	mem.List(ctx, func(info ObjectInfo) error { n++; return nil })
+	if n != 1 {
+		t.Errorf("vault holds %d audit objects, want 1", n)
+	}
+}
+
+func TestOTLPAuditSinkExportsSynchronously(t *testing.T) {
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/logs" && r.Header.Get("Authorization") == "Bearer t" {
+			requests.Add(1)
+		}
+		w.Header().Set("Content-Type", "application/x-protobuf")
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	sink, err := NewAuditSink(ctx, AuditConfig{Sink: "otlp", OTLP: AuditOTLPConfig{
+		Endpoint: srv.URL + "/v1/logs",
+		Headers:  map[string]configopaque.String{"Authorization": "Bearer t"},
+	}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sink.(*otlpAuditSink).Close()
+
+	if err := sink.Record(ctx, AuditRecord{Principal: "bob", Ref: "vault://x", Outcome: "ok"}); err != nil {
+		t.Fatal(err)
+	}
+	if requests.Load() != 1 {
+		t.Errorf("exporter sent %d requests before Record returned, want 1", requests.Load())
+	}
+}
+
+func TestOTLPAuditSinkFailsClosed(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	sink, err := NewAuditSink(ctx, AuditConfig{Sink: "otlp", OTLP: AuditOTLPConfig{
+		Endpoint: "http://127.0.0.1:1/v1/logs",
+	}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sink.(*otlpAuditSink).Close()
+
+	mem := NewMemoryVault(0, 0)
+	ref, _ := mem.Store(ctx, ObjectMeta{}, []byte("secret prompt"))
+	got, err := NewAuditingVault(mem, sink, "api").Retrieve(ctx, ref)
+	if err == nil || got != nil {
+		t.Errorf("Retrieve = %q, %v; want content withheld while the audit endpoint is down", got, err)
+	}
+}
+
+func TestNewAuditSinkValidates(t *testing.T) {
+	for _, cfg := range []AuditConfig{{Sink: "file"}, {Sink: "otlp"}, {Sink: "vault"}, {Sink: "syslog"}} {
+		if _, err := NewAuditSink(context.Background(), cfg, nil); err == nil {
+			t.Errorf("NewAuditSink(%+v): expected error", cfg)
+		}
+	}
+}
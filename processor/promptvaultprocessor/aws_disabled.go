@@ -0,0 +1,20 @@
+//go:build promptvault_no_aws
+
+package promptvaultprocessor
+
+import (
+	"context"
+	"errors"
+)
+
+// errAWSExcluded is returned for crypto.provider aws_kms and notify.sns by
+// binaries built with the promptvault_no_aws tag.
+var errAWSExcluded = errors.New("aws support was excluded from this build (promptvault_no_aws)")
+
+func openKMSEncryptor(context.Context, AWSKMSConfig) (Encryptor, error) {
+	return nil, errAWSExcluded
+}
+
+func newSNSTarget(context.Context, NotifySNSConfig) (notifyTarget, error) {
+	return nil, errAWSExcluded
+}
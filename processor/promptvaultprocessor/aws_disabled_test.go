@@ -0,0 +1,22 @@
+//go:build promptvault_no_aws
+
+package promptvaultprocessor
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestAWSExcluded(t *testing.T) {
+	ctx := context.Background()
+	if _, err := newEncryptor(ctx, CryptoConfig{Provider: "aws_kms", AWSKMS: AWSKMSConfig{KeyID: "alias/vault"}}); !errors.Is(err, errAWSExcluded) {
+		t.Errorf("crypto.provider aws_kms: expected %v, got %v", errAWSExcluded, err)
+	}
+	cfg := NotifyConfig{SNS: NotifySNSConfig{TopicARN: "arn:aws:sns:us-east-1:123456789012:vault"}}
+	if _, err := newStoreNotifier(ctx, zap.NewNop(), cfg); !errors.Is(err, errAWSExcluded) {
+		t.Errorf("notify.sns: expected %v, got %v", errAWSExcluded, err)
+	}
+}
@@ -0,0 +1,355 @@
+package promptvaultprocessor
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// backupVersion is the layout version written to a backup's backup.json.
+const backupVersion = 1
+
+// PAX records carrying each object's reference, checksum, and metadata in
+// a backup archive.
+const (
+	paxRef    = "PROMPTVAULT.ref"
+	paxSHA256 = "PROMPTVAULT.sha256"
+	paxMeta   = "PROMPTVAULT.meta"
+)
+
+// Names of the entries in a backup archive.
+const (
+	backupInfoName         = "backup.json"
+	backupIndexName        = "index.db"
+	backupManifestDir      = "manifests/"
+	backupTraceManifestDir = "trace_manifests/"
+	backupObjectDir        = "objects/"
+)
+
+// BackupInfo is the backup.json entry at the start of a backup archive.
+type BackupInfo struct {
+	Version   int       `json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// BackupStats counts what Backup wrote or Restore read.
+type BackupStats struct {
+	Objects int
+	Bytes   int64
+	// Files counts the index and manifest files.
+	Files int
+	// Skipped counts files in the archive for which the restore config
+	// names no destination.
+	Skipped int
+	// Failed counts objects that failed checksum verification or were
+	// restored under a different reference.
+	Failed int
+}
+
+// Backup writes a tar archive of the vault described by cfg to w: a
+// snapshot of storage.index, the storage.manifest and
+// storage.trace_manifests files, and every object as stored, still
+// compressed and encrypted, with its reference, checksum, and metadata.
+// Bundled objects are written individually. The index is snapshotted
+// first, so objects stored while the backup runs may be archived without
+// an index row.
+func Backup(ctx context.Context, logger *zap.Logger, cfg *Config, w io.Writer) (BackupStats, error) {
+	var stats BackupStats
+	metrics, err := newVaultMetrics(nil)
+	if err != nil {
+		return stats, err
+	}
+	vault, err := openBackendStack(ctx, logger, metrics, cfg.Storage)
+	if err != nil {
+		return stats, err
+	}
+	defer func() {
+		if c, ok := vault.(io.Closer); ok {
+			c.Close()
+		}
+	}()
+	lister, ok := vault.(VaultLister)
+	if !ok {
+		return stats, fmt.Errorf("backup: storage backend cannot list objects")
+	}
+
+	tw := tar.NewWriter(w)
+	info, _ := json.Marshal(BackupInfo{Version: backupVersion, CreatedAt: time.Now().UTC()})
+	if err := writeTarFile(tw, backupInfoName, info, time.Now()); err != nil {
+		return stats, err
+	}
+	if cfg.Storage.Index.Path != "" {
+		snapshot, err := snapshotIndex(ctx, cfg.Storage.Index.Path)
+		if err != nil {
+			return stats, err
+		}
+		if snapshot != nil {
+			if err := writeTarFile(tw, backupIndexName, snapshot, time.Now()); err != nil {
+				return stats, err
+			}
+			stats.Files++
+		}
+	}
+	for _, d := range []struct{ prefix, dir string }{
+		{backupManifestDir, cfg.Storage.Manifest.Dir},
+		{backupTraceManifestDir, cfg.Storage.TraceManifests.Dir},
+	} {
+		n, err := backupDir(tw, d.prefix, d.dir)
+		stats.Files += n
+		if err != nil {
+			return stats, err
+		}
+	}
+
+	err = lister.List(ctx, func(info ObjectInfo) error {
+		content, err := vault.Retrieve(ctx, info.Ref)
+		if err != nil {
+			return fmt.Errorf("read %s: %w", info.Ref, err)
+		}
+		meta, _ := json.Marshal(info.Meta)
+		hdr := &tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     fmt.Sprintf("%s%08d", backupObjectDir, stats.Objects),
+			Mode:     0o600,
+			Size:     int64(len(content)),
+			ModTime:  info.StoredAt,
+			Format:   tar.FormatPAX,
+			PAXRecords: map[string]string{
+				paxRef:    info.Ref,
+				paxSHA256: contentHash(content),
+				paxMeta:   string(meta),
+			},
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if _, err := tw.Write(content); err != nil {
+			return err
+		}
+		stats.Objects++
+		stats.Bytes += int64(len(content))
+		return nil
+	})
+	if err != nil {
+		return stats, fmt.Errorf("backup: %w", err)
+	}
+	return stats, tw.Close()
+}
+
+// snapshotIndex returns a consistent copy of the index database at path,
+// or nil if there is none yet.
+func snapshotIndex(ctx context.Context, path string) ([]byte, error) {
+	if _, err := os.Stat(path); errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	db, err := openIndex(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+	dir, err := os.MkdirTemp("", "promptvault-backup-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	snapshot := filepath.Join(dir, backupIndexName)
+	if _, err := db.ExecContext(ctx, `VACUUM INTO ?`, snapshot); err != nil {
+		return nil, fmt.Errorf("snapshot index: %w", err)
+	}
+	return os.ReadFile(snapshot)
+}
+
+// backupDir archives the regular files under dir beneath prefix.
+func backupDir(tw *tar.Writer, prefix, dir string) (int, error) {
+	if dir == "" {
+		return 0, nil
+	}
+	n := 0
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if errors.Is(err, fs.ErrNotExist) && p == dir {
+			return fs.SkipDir
+		}
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		fi, err := d.Info()
+		if err != nil {
+			return err
+		}
+		n++
+		return writeTarFile(tw, prefix+filepath.ToSlash(rel), data, fi.ModTime())
+	})
+	return n, err
+}
+
+func writeTarFile(tw *tar.Writer, name string, data []byte, modTime time.Time) error {
+	hdr := &tar.Header{Typeflag: tar.TypeReg, Name: name, Mode: 0o600, Size: int64(len(data)), ModTime: modTime}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	_, err := tw.Write(data)
+	return err
+}
+
+// Restore reads a Backup archive from r into the vault described by cfg.
+// Each object's checksum is verified before it is stored; objects that
+// fail, or that the backend stores under a different reference than they
+// had, are logged and counted in Failed. The index and manifest files are
+// written to the paths cfg configures, which must not hold files already.
+func Restore(ctx context.Context, logger *zap.Logger, cfg *Config, r io.Reader) (BackupStats, error) {
+	var stats BackupStats
+	metrics, err := newVaultMetrics(nil)
+	if err != nil {
+		return stats, err
+	}
+	vault, err := openBackendStack(ctx, logger, metrics, cfg.Storage)
+	if err != nil {
+		return stats, err
+	}
+	defer func() {
+		if c, ok := vault.(io.Closer); ok {
+			c.Close()
+		}
+	}()
+
+	tr := tar.NewReader(r)
+	for first := true; ; first = false {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return stats, fmt.Errorf("read backup: %w", err)
+		}
+		if first {
+			if err := checkBackupInfo(hdr, tr); err != nil {
+				return stats, err
+			}
+			continue
+		}
+
+		var dest string
+		switch name := hdr.Name; {
+		case strings.HasPrefix(name, backupObjectDir):
+			if err := restoreObject(ctx, logger, vault, hdr, tr, &stats); err != nil {
+				return stats, err
+			}
+			continue
+		case name == backupIndexName:
+			dest = cfg.Storage.Index.Path
+		case strings.HasPrefix(name, backupManifestDir):
+			dest, err = restorePath(cfg.Storage.Manifest.Dir, strings.TrimPrefix(name, backupManifestDir))
+		case strings.HasPrefix(name, backupTraceManifestDir):
+			dest, err = restorePath(cfg.Storage.TraceManifests.Dir, strings.TrimPrefix(name, backupTraceManifestDir))
+		default:
+			return stats, fmt.Errorf("read backup: unexpected entry %q", name)
+		}
+		if err != nil {
+			return stats, err
+		}
+		if dest == "" {
+			logger.Warn("no destination configured for backup file; skipping", zap.String("name", hdr.Name))
+			stats.Skipped++
+			continue
+		}
+		if err := restoreFile(dest, hdr, tr); err != nil {
+			return stats, err
+		}
+		stats.Files++
+	}
+	return stats, nil
+}
+
+func checkBackupInfo(hdr *tar.Header, r io.Reader) error {
+	if hdr.Name != backupInfoName {
+		return fmt.Errorf("read backup: not a vault backup")
+	}
+	var info BackupInfo
+	if err := json.NewDecoder(r).Decode(&info); err != nil {
+		return fmt.Errorf("read backup: %w", err)
+	}
+	if info.Version != backupVersion {
+		return fmt.Errorf("read backup: unsupported version %d", info.Version)
+	}
+	return nil
+}
+
+func restoreObject(ctx context.Context, logger *zap.Logger, vault VaultStorage, hdr *tar.Header, r io.Reader, stats *BackupStats) error {
+	ref := hdr.PAXRecords[paxRef]
+	var meta ObjectMeta
+	if err := json.Unmarshal([]byte(hdr.PAXRecords[paxMeta]), &meta); err != nil || ref == "" {
+		return fmt.Errorf("read backup: %s has no reference or metadata", hdr.Name)
+	}
+	var buf bytes.Buffer
+	if _, err := io.Copy(&buf, r); err != nil {
+		return fmt.Errorf("read backup: %w", err)
+	}
+	content := buf.Bytes()
+	if contentHash(content) != hdr.PAXRecords[paxSHA256] {
+		logger.Error("backup object failed checksum verification", zap.String("ref", ref))
+		stats.Failed++
+		return nil
+	}
+	got, err := vault.Store(ctx, meta, content)
+	if err != nil {
+		return fmt.Errorf("restore %s: %w", ref, err)
+	}
+	if got != ref {
+		logger.Error("object restored under a different reference", zap.String("ref", ref), zap.String("restored_as", got))
+		stats.Failed++
+		return nil
+	}
+	stats.Objects++
+	stats.Bytes += int64(len(content))
+	return nil
+}
+
+// restorePath returns where the archived file rel belongs under dir, or ""
+// when dir is not configured.
+func restorePath(dir, rel string) (string, error) {
+	if dir == "" {
+		return "", nil
+	}
+	if rel == "" || path.Clean(rel) != rel || rel == ".." || strings.HasPrefix(rel, "../") || path.IsAbs(rel) {
+		return "", fmt.Errorf("read backup: invalid file name %q", rel)
+	}
+	return filepath.Join(dir, filepath.FromSlash(rel)), nil
+}
+
+func restoreFile(dest string, hdr *tar.Header, r io.Reader) error {
+	if err := os.MkdirAll(filepath.Dir(dest), 0o700); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return fmt.Errorf("restore %s: %w", hdr.Name, err)
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return fmt.Errorf("restore %s: %w", hdr.Name, err)
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Chtimes(dest, hdr.ModTime, hdr.ModTime)
+}
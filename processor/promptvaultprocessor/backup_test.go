@@ -0,0 +1,110 @@
+package promptvaultprocessor
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func backupTestConfig(dir string) *Config {
+	cfg := createDefaultConfig()
+	cfg.Vault.SigningKey = "backup-test-signing-key-0123456789abcdef"
+	cfg.Storage.Filesystem.BasePath = filepath.Join(dir, "objects")
+	cfg.Storage.Filesystem.WriteMetadata = true
+	cfg.Storage.Index.Path = filepath.Join(dir, "index.db")
+	cfg.Storage.Manifest.Dir = filepath.Join(dir, "manifest")
+	cfg.Storage.Compression = "zstd"
+	return cfg
+}
+
+func TestBackupRestore(t *testing.T) {
+	ctx := context.Background()
+	src := backupTestConfig(t.TempDir())
+	vault, err := OpenVault(ctx, zap.NewNop(), src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	meta := ObjectMeta{TraceID: "t1", SpanID: "s1", Key: "gen_ai.prompt", Tenant: "acme"}
+	ref, err := vault.Store(ctx, meta, []byte("Tell me about quantum computing"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	vault.(io.Closer).Close()
+
+	var archive bytes.Buffer
+	stats, err := Backup(ctx, zap.NewNop(), src, &archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Objects != 1 || stats.Files != 2 {
+		t.Errorf("backup stats = %+v, want 1 object, an index, and a manifest", stats)
+	}
+
+	dst := backupTestConfig(t.TempDir())
+	stats, err = Restore(ctx, zap.NewNop(), dst, bytes.NewReader(archive.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Objects != 1 || stats.Files != 2 || stats.Failed != 0 {
+		t.Errorf("restore stats = %+v", stats)
+	}
+
+	restored, err := OpenVault(ctx, zap.NewNop(), dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer restored.(io.Closer).Close()
+	got, err := restored.Retrieve(ctx, ref)
+	if err != nil || string(got) != "Tell me about quantum computing" {
+		t.Errorf("retrieve restored %s = %q, %v", ref, got, err)
+	}
+	if refs := searchRefs(t, restored, ObjectFilter{Tenant: "acme"}); len(refs) != 1 || refs[0] != ref {
+		t.Errorf("restored index lists %v", refs)
+	}
+	key, _ := NewHMACManifestKey(dst.Vault.SigningKey)
+	if report, err := VerifyManifest(ctx, dst.Storage.Manifest.Dir, key, nil); err != nil || report.Entries != 1 {
+		t.Errorf("restored manifest: %+v, %v", report, err)
+	}
+
+	// Restoring again would overwrite the index and manifest.
+	if _, err := Restore(ctx, zap.NewNop(), dst, bytes.NewReader(archive.Bytes())); !errors.Is(err, fs.ErrExist) {
+		t.Errorf("restore over existing files: %v", err)
+	}
+}
+
+func TestRestoreVerifiesChecksums(t *testing.T) {
+	var archive bytes.Buffer
+	tw := tar.NewWriter(&archive)
+	writeTarFile(tw, backupInfoName, []byte(`{"version":1}`), time.Time{})
+	content := []byte("tampered")
+	tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     "objects/00000000",
+		Size:     int64(len(content)),
+		PAXRecords: map[string]string{
+			paxRef:    refScheme + contentHash([]byte("original")),
+			paxSHA256: contentHash([]byte("original")),
+			paxMeta:   "{}",
+		},
+	})
+	tw.Write(content)
+	tw.Close()
+
+	cfg := createDefaultConfig()
+	cfg.Storage.Filesystem.BasePath = t.TempDir()
+	stats, err := Restore(context.Background(), zap.NewNop(), cfg, &archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Failed != 1 || stats.Objects != 0 {
+		t.Errorf("restore stats = %+v, want the object rejected", stats)
+	}
+}
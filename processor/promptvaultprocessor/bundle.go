@@ -0,0 +1,673 @@
+package promptvaultprocessor
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"go.uber.org/zap"
+)
+
+// BundleConfig enables compaction of old objects into bundles: zstd
+// compressed tar archives stored as single backend objects, which cuts
+// per-object request and storage overhead for long-term retention.
+// References to bundled objects keep resolving.
+type BundleConfig struct {
+	// Index is the SQLite database mapping bundled references to their
+	// bundles. Every process reading the vault needs it. Empty disables
+	// bundling.
+	Index string `mapstructure:"index"`
+	// MinAge is the age at which objects are bundled. Default 168h.
+	MinAge time.Duration `mapstructure:"min_age"`
+	// MaxBytes bounds the uncompressed size of each bundle. Default 64 MiB.
+	MaxBytes ByteSize `mapstructure:"max_bytes"`
+	// Interval between compactions run by the storage extension or
+	// promptvaultctl compact. Default 24h.
+	Interval time.Duration `mapstructure:"interval"`
+}
+
+const (
+	defaultBundleMinAge   = 7 * 24 * time.Hour
+	defaultBundleMaxBytes = 64 << 20
+	// bundleKey is the attribute key bundles are stored under.
+	bundleKey = "vault.bundle"
+	// bundleIndexMember lists a bundle's objects and their metadata, so
+	// bundles can be read without the index.
+	bundleIndexMember = "index.json"
+)
+
+// CompactionStats summarizes a compaction.
+type CompactionStats struct {
+	Bundles int
+	Objects int
+	// Bytes is the size of the bundled objects; BundleBytes of the bundles
+	// that replaced them.
+	Bytes       int64
+	BundleBytes int64
+	// Failed counts objects that could not be read or removed after
+	// bundling. Bundled objects whose originals remain are still served
+	// from their bundle.
+	Failed int
+}
+
+// VaultCompactor is implemented by vaults that bundle old objects.
+type VaultCompactor interface {
+	Compact(ctx context.Context) (CompactionStats, error)
+}
+
+// bundleVault serves objects compacted into bundles alongside the
+// backend's own. It wraps the backend directly, so bundles hold objects'
+// bytes as stored, still compressed and encrypted, and checksums are
+// verified above it as for any other read. Bundles themselves are hidden
+// from List, so retention never deletes one wholesale. Deleting or
+// replacing a bundled object rewrites its bundle.
+type bundleVault struct {
+	VaultStorage
+	db       *sql.DB
+	minAge   time.Duration
+	maxBytes int64
+	now      func() time.Time
+
+	// mu serializes bundle rewrites and guards cached.
+	mu sync.Mutex
+	// cached holds the members of the last bundle read, since objects of
+	// one trace are usually read together and bundled together.
+	cachedRef string
+	cached    map[string][]byte
+}
+
+func newBundleVault(ctx context.Context, vault VaultStorage, cfg BundleConfig) (VaultStorage, error) {
+	if cfg.Index == "" {
+		return vault, nil
+	}
+	if _, ok := vault.(VaultLister); !ok {
+		return nil, fmt.Errorf("storage.bundles: storage backend cannot list objects")
+	}
+	if _, ok := vault.(VaultDeleter); !ok {
+		return nil, fmt.Errorf("storage.bundles: storage backend cannot delete objects")
+	}
+	db, err := openBundleIndex(ctx, cfg.Index)
+	if err != nil {
+		return nil, err
+	}
+	minAge := cfg.MinAge
+	if minAge <= 0 {
+		minAge = defaultBundleMinAge
+	}
+	maxBytes := int64(cfg.MaxBytes)
+	if maxBytes <= 0 {
+		maxBytes = defaultBundleMaxBytes
+	}
+	return &bundleVault{VaultStorage: vault, db: db, minAge: minAge, maxBytes: maxBytes, now: time.Now}, nil
+}
+
+func openBundleIndex(ctx context.Context, path string) (*sql.DB, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("create bundle index dir: %w", err)
+	}
+	db, err := openSQLite(path)
+	if err != nil {
+		return nil, fmt.Errorf("open bundle index: %w", err)
+	}
+
+	_, err = db.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS bundled (
+			ref        TEXT    PRIMARY KEY,
+			bundle     TEXT    NOT NULL,
+			size       INTEGER NOT NULL,
+			stored_at  INTEGER NOT NULL,
+			trace_id   TEXT    NOT NULL,
+			span_id    TEXT    NOT NULL,
+			attr_key   TEXT    NOT NULL,
+			service    TEXT    NOT NULL,
+			tenant     TEXT    NOT NULL,
+			enduser_id TEXT    NOT NULL,
+			key_id     TEXT    NOT NULL,
+			transforms TEXT    NOT NULL
+		);
+		CREATE INDEX IF NOT EXISTS bundled_bundle_idx ON bundled (bundle);`)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("create bundle index schema: %w", err)
+	}
+	return db, nil
+}
+
+// bundleEntry is one object in a bundle, as listed in its index member.
+type bundleEntry struct {
+	Ref      string     `json:"ref"`
+	Size     int64      `json:"size"`
+	StoredAt time.Time  `json:"stored_at"`
+	Meta     ObjectMeta `json:"meta"`
+}
+
+func (e bundleEntry) info() ObjectInfo {
+	return ObjectInfo{Ref: e.Ref, Size: e.Size, StoredAt: e.StoredAt, Meta: e.Meta}
+}
+
+const bundledColumns = `ref, bundle, size, stored_at, trace_id, span_id, attr_key, service, tenant, enduser_id, key_id, transforms`
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanBundled(row rowScanner) (bundleEntry, string, error) {
+	var e bundleEntry
+	var bundle string
+	var storedAt int64
+	m := &e.Meta
+	err := row.Scan(&e.Ref, &bundle, &e.Size, &storedAt, &m.TraceID, &m.SpanID, &m.Key, &m.Service,
+		&m.Tenant, &m.EndUser, &m.KeyID, &m.Transforms)
+	e.StoredAt = time.Unix(0, storedAt)
+	return e, bundle, err
+}
+
+// lookup returns the bundle holding ref, or "" if it is not bundled.
+func (v *bundleVault) lookup(ctx context.Context, ref string) (bundleEntry, string, error) {
+	e, bundle, err := scanBundled(v.db.QueryRowContext(ctx, `SELECT `+bundledColumns+` FROM bundled WHERE ref = ?`, ref))
+	if errors.Is(err, sql.ErrNoRows) {
+		return bundleEntry{}, "", nil
+	}
+	if err != nil {
+		return bundleEntry{}, "", fmt.Errorf("query bundle index: %w", err)
+	}
+	return e, bundle, nil
+}
+
+func (v *bundleVault) Retrieve(ctx context.Context, ref string) ([]byte, error) {
+	_, bundle, err := v.lookup(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	if bundle == "" {
+		return v.VaultStorage.Retrieve(ctx, ref)
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	members, err := v.readBundle(ctx, bundle)
+	if err != nil {
+		return nil, err
+	}
+	content, ok := members[memberName(ref)]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s is missing from bundle %s", ErrObjectNotFound, ref, bundle)
+	}
+	return content, nil
+}
+
+// memberName is the tar member holding ref.
+func memberName(ref string) string {
+	return "objects/" + strings.TrimPrefix(ref, refScheme)
+}
+
+// readBundle returns the members of the bundle at ref. v.mu must be held.
+func (v *bundleVault) readBundle(ctx context.Context, ref string) (map[string][]byte, error) {
+	if v.cachedRef == ref {
+		return v.cached, nil
+	}
+	data, err := v.VaultStorage.Retrieve(ctx, ref)
+	if err != nil {
+		return nil, fmt.Errorf("read bundle %s: %w", ref, err)
+	}
+	zr, err := zstd.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	members := make(map[string][]byte)
+	tr := tar.NewReader(zr)
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read bundle %s: %w", ref, err)
+		}
+		content, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, fmt.Errorf("read bundle %s: %w", ref, err)
+		}
+		members[h.Name] = content
+	}
+	v.cachedRef, v.cached = ref, members
+	return members, nil
+}
+
+// writeBundle encodes objects as a bundle.
+func writeBundle(entries []bundleEntry, contents [][]byte) ([]byte, error) {
+	var buf bytes.Buffer
+	zw, err := zstd.NewWriter(&buf)
+	if err != nil {
+		return nil, err
+	}
+	tw := tar.NewWriter(zw)
+	add := func(name string, content []byte, modTime time.Time) error {
+		if err := tw.WriteHeader(&tar.Header{
+			Name:    name,
+			Mode:    0o600,
+			Size:    int64(len(content)),
+			ModTime: modTime,
+			Format:  tar.FormatPAX,
+		}); err != nil {
+			return err
+		}
+		_, err := tw.Write(content)
+		return err
+	}
+	for i, e := range entries {
+		if err := add(memberName(e.Ref), contents[i], e.StoredAt); err != nil {
+			return nil, err
+		}
+	}
+	index, err := json.Marshal(entries)
+	if err != nil {
+		return nil, err
+	}
+	// A fixed time keeps bundles of the same objects byte-identical.
+	if err := add(bundleIndexMember, index, time.Unix(0, 0)); err != nil {
+		return nil, err
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Compact bundles every object older than min_age, max_bytes at a time.
+// Each bundle is stored and indexed before its objects are deleted, so
+// every object stays readable throughout.
+func (v *bundleVault) Compact(ctx context.Context) (CompactionStats, error) {
+	var stats CompactionStats
+	bundles, err := v.bundleRefs(ctx)
+	if err != nil {
+		return stats, err
+	}
+	cutoff := v.now().Add(-v.minAge)
+	var candidates []bundleEntry
+	// Collect first: deleting while a backend iterates is not safe for all.
+	err = v.VaultStorage.(VaultLister).List(ctx, func(info ObjectInfo) error {
+		if bundles[info.Ref] || info.Meta.Key == bundleKey || info.StoredAt.IsZero() || !info.StoredAt.Before(cutoff) {
+			return nil
+		}
+		candidates = append(candidates, bundleEntry{Ref: info.Ref, Size: info.Size, StoredAt: info.StoredAt, Meta: info.Meta})
+		return nil
+	})
+	if err != nil {
+		return stats, err
+	}
+
+	var batch []bundleEntry
+	var size int64
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		err := v.bundle(ctx, batch, &stats)
+		batch, size = nil, 0
+		return err
+	}
+	for _, c := range candidates {
+		if size > 0 && size+c.Size > v.maxBytes {
+			if err := flush(); err != nil {
+				return stats, err
+			}
+		}
+		batch = append(batch, c)
+		size += c.Size
+	}
+	return stats, flush()
+}
+
+// bundleRefs returns the references of every bundle.
+func (v *bundleVault) bundleRefs(ctx context.Context) (map[string]bool, error) {
+	rows, err := v.db.QueryContext(ctx, `SELECT DISTINCT bundle FROM bundled`)
+	if err != nil {
+		return nil, fmt.Errorf("query bundle index: %w", err)
+	}
+	defer rows.Close()
+	refs := make(map[string]bool)
+	for rows.Next() {
+		var ref string
+		if err := rows.Scan(&ref); err != nil {
+			return nil, err
+		}
+		refs[ref] = true
+	}
+	return refs, rows.Err()
+}
+
+// bundle stores batch as one bundle, indexes it, and deletes the originals.
+func (v *bundleVault) bundle(ctx context.Context, batch []bundleEntry, stats *CompactionStats) error {
+	var entries []bundleEntry
+	var contents [][]byte
+	for _, e := range batch {
+		content, err := v.VaultStorage.Retrieve(ctx, e.Ref)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			stats.Failed++
+			continue
+		}
+		e.Size = int64(len(content))
+		entries = append(entries, e)
+		contents = append(contents, content)
+	}
+	if len(entries) == 0 {
+		return nil
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	_, n, err := v.storeBundle(ctx, entries, contents)
+	if err != nil {
+		return err
+	}
+	stats.Bundles++
+	stats.BundleBytes += int64(n)
+	for _, e := range entries {
+		stats.Objects++
+		stats.Bytes += e.Size
+		if err := v.VaultStorage.(VaultDeleter).Delete(ctx, e.Ref); err != nil && !errors.Is(err, ErrObjectNotFound) {
+			stats.Failed++
+		}
+	}
+	return nil
+}
+
+// storeBundle stores entries as a bundle and points their index rows at
+// it, returning the bundle's reference and size. v.mu must be held.
+func (v *bundleVault) storeBundle(ctx context.Context, entries []bundleEntry, contents [][]byte) (string, int, error) {
+	data, err := writeBundle(entries, contents)
+	if err != nil {
+		return "", 0, fmt.Errorf("encode bundle: %w", err)
+	}
+	bundle, err := v.VaultStorage.Store(ctx, ObjectMeta{Key: bundleKey}, data)
+	if err != nil {
+		return "", 0, fmt.Errorf("store bundle: %w", err)
+	}
+	tx, err := v.db.BeginTx(ctx, nil)
+	if err != nil {
+		return "", 0, err
+	}
+	defer tx.Rollback()
+	for _, e := range entries {
+		m := e.Meta
+		_, err := tx.ExecContext(ctx, `INSERT OR REPLACE INTO bundled (`+bundledColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			e.Ref, bundle, e.Size, e.StoredAt.UTC().UnixNano(), m.TraceID, m.SpanID, m.Key, m.Service,
+			m.Tenant, m.EndUser, m.KeyID, m.Transforms)
+		if err != nil {
+			return "", 0, fmt.Errorf("index bundle: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return "", 0, fmt.Errorf("index bundle: %w", err)
+	}
+	return bundle, len(data), nil
+}
+
+// rewrite replaces the bundle holding ref with one where ref's content is
+// replaced, or ref is dropped if content is nil. A bundle left empty is
+// deleted. The old bundle is deleted before ref's row, so a failed drop
+// can be retried until the old content is gone.
+func (v *bundleVault) rewrite(ctx context.Context, ref, bundle string, content []byte) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	members, err := v.readBundle(ctx, bundle)
+	if content == nil && errors.Is(err, ErrObjectNotFound) {
+		// A previous drop deleted the bundle but not ref's row.
+		return v.unindex(ctx, ref)
+	}
+	if err != nil {
+		return err
+	}
+	rows, err := v.db.QueryContext(ctx, `SELECT `+bundledColumns+` FROM bundled WHERE bundle = ?`, bundle)
+	if err != nil {
+		return fmt.Errorf("query bundle index: %w", err)
+	}
+	var entries []bundleEntry
+	var contents [][]byte
+	for rows.Next() {
+		e, _, err := scanBundled(rows)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+		c := members[memberName(e.Ref)]
+		if e.Ref == ref {
+			if content == nil {
+				continue
+			}
+			c = content
+			e.Size = int64(len(c))
+		}
+		entries = append(entries, e)
+		contents = append(contents, c)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	v.cachedRef, v.cached = "", nil
+	if len(entries) > 0 {
+		newBundle, _, err := v.storeBundle(ctx, entries, contents)
+		if err != nil {
+			return err
+		}
+		if newBundle == bundle {
+			return nil
+		}
+	}
+	// The old bundle still holds ref's previous content.
+	if err := v.VaultStorage.(VaultDeleter).Delete(ctx, bundle); err != nil && !errors.Is(err, ErrObjectNotFound) {
+		return fmt.Errorf("delete rewritten bundle %s: %w", bundle, err)
+	}
+	if content == nil {
+		return v.unindex(ctx, ref)
+	}
+	return nil
+}
+
+func (v *bundleVault) unindex(ctx context.Context, ref string) error {
+	if _, err := v.db.ExecContext(ctx, `DELETE FROM bundled WHERE ref = ?`, ref); err != nil {
+		return fmt.Errorf("update bundle index: %w", err)
+	}
+	return nil
+}
+
+// Delete deletes ref, rewriting its bundle without it if it is bundled.
+func (v *bundleVault) Delete(ctx context.Context, ref string) error {
+	_, bundle, err := v.lookup(ctx, ref)
+	if err != nil {
+		return err
+	}
+	err = v.VaultStorage.(VaultDeleter).Delete(ctx, ref)
+	if bundle == "" {
+		return err
+	}
+	if err != nil && !errors.Is(err, ErrObjectNotFound) {
+		return err
+	}
+	return v.rewrite(ctx, ref, bundle, nil)
+}
+
+// Replace overwrites ref, rewriting its bundle if it is bundled.
+func (v *bundleVault) Replace(ctx context.Context, ref string, content []byte) error {
+	_, bundle, err := v.lookup(ctx, ref)
+	if err != nil {
+		return err
+	}
+	if bundle == "" {
+		if r, ok := v.VaultStorage.(VaultReplacer); ok {
+			return r.Replace(ctx, ref, content)
+		}
+		return fmt.Errorf("replace: %w", errors.ErrUnsupported)
+	}
+	return v.rewrite(ctx, ref, bundle, content)
+}
+
+// Stat describes bundled objects from the bundle index.
+func (v *bundleVault) Stat(ctx context.Context, ref string) (ObjectInfo, error) {
+	e, bundle, err := v.lookup(ctx, ref)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	if bundle != "" {
+		return e.info(), nil
+	}
+	if s, ok := v.VaultStorage.(VaultStatter); ok {
+		return s.Stat(ctx, ref)
+	}
+	return ObjectInfo{}, fmt.Errorf("stat: %w", errors.ErrUnsupported)
+}
+
+// List lists the backend's objects other than bundles, then every bundled
+// object.
+func (v *bundleVault) List(ctx context.Context, fn func(ObjectInfo) error) error {
+	bundles, err := v.bundleRefs(ctx)
+	if err != nil {
+		return err
+	}
+	listed := make(map[string]bool)
+	err = v.VaultStorage.(VaultLister).List(ctx, func(info ObjectInfo) error {
+		if bundles[info.Ref] || info.Meta.Key == bundleKey {
+			return nil
+		}
+		listed[info.Ref] = true
+		return fn(info)
+	})
+	if err != nil {
+		return err
+	}
+	rows, err := v.db.QueryContext(ctx, `SELECT `+bundledColumns+` FROM bundled ORDER BY ref`)
+	if err != nil {
+		return fmt.Errorf("query bundle index: %w", err)
+	}
+	var infos []ObjectInfo
+	for rows.Next() {
+		e, _, err := scanBundled(rows)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+		// Content stored again after it was bundled is listed once.
+		if !listed[e.Ref] {
+			infos = append(infos, e.info())
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for _, info := range infos {
+		if err := fn(info); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (v *bundleVault) Start(ctx context.Context) error {
+	if s, ok := v.VaultStorage.(vaultStarter); ok {
+		return s.Start(ctx)
+	}
+	return nil
+}
+
+func (v *bundleVault) Close() error {
+	err := v.db.Close()
+	if c, ok := v.VaultStorage.(io.Closer); ok {
+		err = errors.Join(err, c.Close())
+	}
+	return err
+}
+
+// Compaction bundles old objects of a vault every interval.
+type Compaction struct {
+	logger   *zap.Logger
+	vault    VaultCompactor
+	interval time.Duration
+
+	stop   chan struct{}
+	cancel context.CancelFunc
+	done   sync.WaitGroup
+}
+
+// NewCompaction prepares compactions of vault, which must have been opened
+// with storage.bundles configured as in cfg.
+func NewCompaction(logger *zap.Logger, vault VaultStorage, cfg BundleConfig) (*Compaction, error) {
+	c, ok := vault.(VaultCompactor)
+	if !ok || cfg.Index == "" {
+		return nil, fmt.Errorf("compaction: storage.bundles.index is not set")
+	}
+	interval := cfg.Interval
+	if interval <= 0 {
+		interval = 24 * time.Hour
+	}
+	return &Compaction{logger: logger, vault: c, interval: interval, stop: make(chan struct{})}, nil
+}
+
+// Run compacts once.
+func (c *Compaction) Run(ctx context.Context) (CompactionStats, error) {
+	stats, err := c.vault.Compact(ctx)
+	if stats.Objects > 0 || stats.Failed > 0 {
+		c.logger.Info("vault compaction",
+			zap.Int("bundles", stats.Bundles),
+			zap.Int("objects", stats.Objects),
+			zap.Int64("bytes", stats.Bytes),
+			zap.Int64("bundle_bytes", stats.BundleBytes),
+			zap.Int("failed", stats.Failed),
+		)
+	}
+	return stats, err
+}
+
+// Start compacts every interval in the background, beginning immediately.
+func (c *Compaction) Start(_ context.Context) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	c.cancel = cancel
+	c.done.Add(1)
+	go func() {
+		defer c.done.Done()
+		ticker := time.NewTicker(c.interval)
+		defer ticker.Stop()
+		for {
+			if _, err := c.Run(ctx); err != nil && ctx.Err() == nil {
+				c.logger.Warn("vault compaction failed", zap.Error(err))
+			}
+			select {
+			case <-c.stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return nil
+}
+
+// Close stops the compaction loop, interrupting a compaction in progress.
+func (c *Compaction) Close() error {
+	if c.cancel == nil {
+		return nil
+	}
+	close(c.stop)
+	c.cancel()
+	c.done.Wait()
+	return nil
+}
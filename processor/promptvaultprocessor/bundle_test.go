@@ -0,0 +1,126 @@
+package promptvaultprocessor
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestBundleCompaction(t *testing.T) {
+	ctx := context.Background()
+	backend := NewMemoryVault(0, 0)
+	v, err := newBundleVault(ctx, backend, BundleConfig{Index: filepath.Join(t.TempDir(), "bundles.db"), MaxBytes: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer v.(*bundleVault).Close()
+	vault := newChecksumVault(v)
+
+	contents := []string{"first old", "second old", "third old"}
+	var refs []string
+	for i, c := range contents {
+		ref, err := vault.Store(ctx, ObjectMeta{TraceID: "t1", SpanID: "s1", Key: []string{"a", "b", "c"}[i]}, []byte(c))
+		if err != nil {
+			t.Fatal(err)
+		}
+		refs = append(refs, ref)
+	}
+	// Nothing is old enough yet.
+	if stats, err := vault.(VaultCompactor).Compact(ctx); err != nil || stats.Objects != 0 {
+		t.Fatalf("compact before min_age = %+v, %v", stats, err)
+	}
+
+	v.(*bundleVault).now = func() time.Time { return time.Now().Add(8 * 24 * time.Hour) }
+	stats, err := vault.(VaultCompactor).Compact(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// max_bytes of 10 holds one of these objects per bundle.
+	if stats.Objects != 3 || stats.Bundles != 3 || stats.Failed != 0 {
+		t.Fatalf("stats = %+v", stats)
+	}
+	for i, ref := range refs {
+		if _, err := backend.Retrieve(ctx, ref); !errors.Is(err, ErrObjectNotFound) {
+			t.Errorf("original %s still in backend: %v", ref, err)
+		}
+		got, err := vault.Retrieve(ctx, ref)
+		if err != nil || string(got) != contents[i] {
+			t.Errorf("Retrieve(%s) = %q, %v", ref, got, err)
+		}
+	}
+	if info, err := vault.(VaultStatter).Stat(ctx, refs[1]); err != nil || info.Meta.Key != "b" || info.Size != int64(len(contents[1])) {
+		t.Errorf("Stat = %+v, %v", info, err)
+	}
+
+	listed := map[string]bool{}
+	vault.(VaultLister).List(ctx, func(info ObjectInfo) error {
+		listed[info.Ref] = true
+		return nil
+	})
+	if len(listed) != 3 || !listed[refs[0]] || !listed[refs[1]] || !listed[refs[2]] {
+		t.Errorf("List = %v, want the three bundled objects and no bundles", listed)
+	}
+
+	if err := vault.(VaultDeleter).Delete(ctx, refs[0]); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := vault.Retrieve(ctx, refs[0]); err == nil {
+		t.Error("deleted bundled object is still readable")
+	}
+	var backendObjects int
+	backend.List(ctx, func(ObjectInfo) error { backendObjects++; return nil })
+	if backendObjects != 2 {
+		t.Errorf("backend holds %d objects after deleting a bundle's only object, want 2 bundles", backendObjects)
+	}
+
+	if err := vault.(VaultReplacer).Replace(ctx, refs[1], []byte("rewritten")); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := v.Retrieve(ctx, refs[1]); err != nil || string(got) != "rewritten" {
+		t.Errorf("after Replace, Retrieve = %q, %v", got, err)
+	}
+}
+
+func TestBundleDeleteRewritesSharedBundle(t *testing.T) {
+	ctx := context.Background()
+	backend := NewMemoryVault(0, 0)
+	v, err := newBundleVault(ctx, backend, BundleConfig{Index: filepath.Join(t.TempDir(), "bundles.db")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bv := v.(*bundleVault)
+	defer bv.Close()
+
+	keep, _ := v.Store(ctx, ObjectMeta{Key: "keep"}, []byte("kept content"))
+	erase, _ := v.Store(ctx, ObjectMeta{Key: "erase"}, []byte("erased content"))
+	bv.now = func() time.Time { return time.Now().Add(8 * 24 * time.Hour) }
+	if stats, err := bv.Compact(ctx); err != nil || stats.Bundles != 1 {
+		t.Fatalf("compact = %+v, %v", stats, err)
+	}
+	if err := bv.Delete(ctx, erase); err != nil {
+		t.Fatal(err)
+	}
+
+	// The only bundle left must not contain the erased content.
+	var bundles []string
+	backend.List(ctx, func(info ObjectInfo) error {
+		bundles = append(bundles, info.Ref)
+		return nil
+	})
+	if len(bundles) != 1 {
+		t.Fatalf("backend holds %v, want one bundle", bundles)
+	}
+	bv.cachedRef = ""
+	members, err := bv.readBundle(ctx, bundles[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := members[memberName(erase)]; ok {
+		t.Error("rewritten bundle still holds the erased object")
+	}
+	if got, err := v.Retrieve(ctx, keep); err != nil || string(got) != "kept content" {
+		t.Errorf("Retrieve(kept) = %q, %v", got, err)
+	}
+}
@@ -0,0 +1,94 @@
+package promptvaultprocessor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ErrChecksumMismatch is returned, wrapped, when an object's stored bytes no
+// longer hash to its content-addressed reference.
+var ErrChecksumMismatch = errors.New("content does not match ref")
+
+// checksumVault verifies on every read that the bytes returned by the
+// backend hash to the reference they were fetched by. It wraps the backend
+// directly, so the check covers the bytes as stored. References that do not
+// end in a content hash (kafka) and objects re-encrypted in place are not
+// checked.
+type checksumVault struct {
+	VaultStorage
+}
+
+func newChecksumVault(vault VaultStorage) VaultStorage {
+	return &checksumVault{VaultStorage: vault}
+}
+
+func (v *checksumVault) Retrieve(ctx context.Context, ref string) ([]byte, error) {
+	content, err := v.VaultStorage.Retrieve(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	if hash := hashFromRef(ref); isContentHash(hash) && contentHash(content) != hash && !isRebound(content) {
+		return nil, fmt.Errorf("%w: %s", ErrChecksumMismatch, ref)
+	}
+	return content, nil
+}
+
+// List, Stat, Search, Compact, Delete, Replace, Start, and Close forward to the wrapped backend.
+
+func (v *checksumVault) List(ctx context.Context, fn func(ObjectInfo) error) error {
+	if l, ok := v.VaultStorage.(VaultLister); ok {
+		return l.List(ctx, fn)
+	}
+	return fmt.Errorf("list: %w", errors.ErrUnsupported)
+}
+
+func (v *checksumVault) Stat(ctx context.Context, ref string) (ObjectInfo, error) {
+	if s, ok := v.VaultStorage.(VaultStatter); ok {
+		return s.Stat(ctx, ref)
+	}
+	return ObjectInfo{}, fmt.Errorf("stat: %w", errors.ErrUnsupported)
+}
+
+func (v *checksumVault) Search(ctx context.Context, f ObjectFilter, fn func(ObjectInfo) error) error {
+	if s, ok := v.VaultStorage.(VaultSearcher); ok {
+		return s.Search(ctx, f, fn)
+	}
+	return fmt.Errorf("search: %w", errors.ErrUnsupported)
+}
+
+func (v *checksumVault) Compact(ctx context.Context) (CompactionStats, error) {
+	if c, ok := v.VaultStorage.(VaultCompactor); ok {
+		return c.Compact(ctx)
+	}
+	return CompactionStats{}, fmt.Errorf("compact: %w", errors.ErrUnsupported)
+}
+
+func (v *checksumVault) Delete(ctx context.Context, ref string) error {
+	if d, ok := v.VaultStorage.(VaultDeleter); ok {
+		return d.Delete(ctx, ref)
+	}
+	return fmt.Errorf("delete: %w", errors.ErrUnsupported)
+}
+
+func (v *checksumVault) Replace(ctx context.Context, ref string, content []byte) error {
+	if r, ok := v.VaultStorage.(VaultReplacer); ok {
+		return r.Replace(ctx, ref, content)
+	}
+	return fmt.Errorf("replace: %w", errors.ErrUnsupported)
+}
+
+func (v *checksumVault) Start(ctx context.Context) error {
+	if s, ok := v.VaultStorage.(vaultStarter); ok {
+		return s.Start(ctx)
+	}
+	return nil
+}
+
+func (v *checksumVault) Close() error {
+	if c, ok := v.VaultStorage.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
@@ -0,0 +1,33 @@
+package promptvaultprocessor
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestChecksumVaultRejectsTamperedObjects(t *testing.T) {
+	ctx := context.Background()
+	fsVault, _ := NewFilesystemVault(FilesystemConfig{BasePath: t.TempDir(), Layout: "sharded"})
+	vault := newChecksumVault(fsVault)
+
+	ref, err := vault.Store(ctx, ObjectMeta{Key: "gen_ai.prompt"}, []byte("original"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := vault.Retrieve(ctx, ref); err != nil || string(got) != "original" {
+		t.Fatalf("Retrieve = %q, %v", got, err)
+	}
+
+	path, _ := fsVault.findPath(ref)
+	if err := os.WriteFile(path, []byte("tampered"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := vault.Retrieve(ctx, ref); !errors.Is(err, ErrChecksumMismatch) || got != nil {
+		t.Errorf("Retrieve of tampered object = %q, %v; want ErrChecksumMismatch", got, err)
+	}
+	if _, err := vault.Retrieve(ctx, refScheme+contentHash([]byte("absent"))); !errors.Is(err, ErrObjectNotFound) {
+		t.Errorf("Retrieve of missing object = %v, want ErrObjectNotFound", err)
+	}
+}
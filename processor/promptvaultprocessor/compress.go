@@ -0,0 +1,222 @@
+package promptvaultprocessor
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Compressed payloads start with this magic and the codec name, so Retrieve
+// can decompress without configuration and objects written before
+// compression was enabled pass through unchanged.
+//
+//	"\x00PVZ1" | u8 len | codec | compressed payload
+var compressMagic = []byte("\x00PVZ1")
+
+// transformEncrypt names encryption in an object's transformation list.
+const transformEncrypt = "encrypt"
+
+// compressingVault compresses content before passing it on. It always wraps
+// encryption, so when encryption is also enabled content is compressed
+// before it is encrypted and decompressed after it is decrypted.
+type compressingVault struct {
+	VaultStorage
+	codec string
+}
+
+func newCompressingVault(inner VaultStorage, codec string) (VaultStorage, error) {
+	switch codec {
+	case "":
+		return inner, nil
+	case "gzip", "zstd":
+		return &compressingVault{VaultStorage: inner, codec: codec}, nil
+	default:
+		return nil, fmt.Errorf("unknown compression %q", codec)
+	}
+}
+
+func (v *compressingVault) Store(ctx context.Context, meta ObjectMeta, content []byte) (string, error) {
+	payload, err := compress(v.codec, content)
+	if err != nil {
+		return "", fmt.Errorf("compress vault object: %w", err)
+	}
+	meta.Transforms = appendTransform(meta.Transforms, v.codec)
+	return v.VaultStorage.Store(ctx, meta, payload)
+}
+
+func (v *compressingVault) Retrieve(ctx context.Context, ref string) ([]byte, error) {
+	payload, err := v.VaultStorage.Retrieve(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	return decompress(payload)
+}
+
+func (v *compressingVault) RetrieveFor(ctx context.Context, ref string, meta ObjectMeta) ([]byte, error) {
+	payload, err := RetrieveFor(ctx, v.VaultStorage, ref, meta)
+	if err != nil {
+		return nil, err
+	}
+	return decompress(payload)
+}
+
+func (v *compressingVault) List(ctx context.Context, fn func(ObjectInfo) error) error {
+	if l, ok := v.VaultStorage.(VaultLister); ok {
+		return l.List(ctx, fn)
+	}
+	return fmt.Errorf("list: %w", errors.ErrUnsupported)
+}
+
+func (v *compressingVault) Stat(ctx context.Context, ref string) (ObjectInfo, error) {
+	if s, ok := v.VaultStorage.(VaultStatter); ok {
+		return s.Stat(ctx, ref)
+	}
+	return ObjectInfo{}, fmt.Errorf("stat: %w", errors.ErrUnsupported)
+}
+
+func (v *compressingVault) Search(ctx context.Context, f ObjectFilter, fn func(ObjectInfo) error) error {
+	if s, ok := v.VaultStorage.(VaultSearcher); ok {
+		return s.Search(ctx, f, fn)
+	}
+	return fmt.Errorf("search: %w", errors.ErrUnsupported)
+}
+
+func (v *compressingVault) Compact(ctx context.Context) (CompactionStats, error) {
+	if c, ok := v.VaultStorage.(VaultCompactor); ok {
+		return c.Compact(ctx)
+	}
+	return CompactionStats{}, fmt.Errorf("compact: %w", errors.ErrUnsupported)
+}
+
+func (v *compressingVault) Usage(ctx context.Context) ([]TenantUsage, error) {
+	if u, ok := v.VaultStorage.(VaultUsageReporter); ok {
+		return u.Usage(ctx)
+	}
+	return nil, fmt.Errorf("usage: %w", errors.ErrUnsupported)
+}
+
+func (v *compressingVault) Stats(ctx context.Context) (VaultStats, error) {
+	if r, ok := v.VaultStorage.(VaultStatsReporter); ok {
+		return r.Stats(ctx)
+	}
+	return VaultStats{}, fmt.Errorf("stats: %w", errors.ErrUnsupported)
+}
+
+// Replace compresses content and overwrites the object at ref with it.
+func (v *compressingVault) Replace(ctx context.Context, ref string, content []byte) error {
+	r, ok := v.VaultStorage.(VaultReplacer)
+	if !ok {
+		return fmt.Errorf("replace: %w", errors.ErrUnsupported)
+	}
+	payload, err := compress(v.codec, content)
+	if err != nil {
+		return fmt.Errorf("compress vault object: %w", err)
+	}
+	return r.Replace(ctx, ref, payload)
+}
+
+func (v *compressingVault) Delete(ctx context.Context, ref string) error {
+	if d, ok := v.VaultStorage.(VaultDeleter); ok {
+		return d.Delete(ctx, ref)
+	}
+	return fmt.Errorf("delete: %w", errors.ErrUnsupported)
+}
+
+func (v *compressingVault) Start(ctx context.Context) error {
+	if s, ok := v.VaultStorage.(vaultStarter); ok {
+		return s.Start(ctx)
+	}
+	return nil
+}
+
+func (v *compressingVault) Close() error {
+	if c, ok := v.VaultStorage.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// appendTransform adds step to a comma-separated transformation list.
+// Transformations are recorded in the order they were applied; retrieval
+// undoes them in reverse.
+func appendTransform(list, step string) string {
+	if list == "" {
+		return step
+	}
+	return list + "," + step
+}
+
+// hasTransform reports whether a comma-separated transformation list
+// includes step.
+func hasTransform(list, step string) bool {
+	for _, got := range strings.Split(list, ",") {
+		if got == step {
+			return true
+		}
+	}
+	return false
+}
+
+func compress(codec string, content []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.Write(compressMagic)
+	buf.WriteByte(byte(len(codec)))
+	buf.WriteString(codec)
+
+	var w io.WriteCloser
+	switch codec {
+	case "gzip":
+		w = gzip.NewWriter(&buf)
+	case "zstd":
+		zw, err := zstd.NewWriter(&buf)
+		if err != nil {
+			return nil, err
+		}
+		w = zw
+	default:
+		return nil, fmt.Errorf("unknown compression %q", codec)
+	}
+	if _, err := w.Write(content); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func decompress(payload []byte) ([]byte, error) {
+	if !bytes.HasPrefix(payload, compressMagic) {
+		return payload, nil
+	}
+	rest := payload[len(compressMagic):]
+	if len(rest) < 1 || len(rest) < 1+int(rest[0]) {
+		return nil, errors.New("malformed compression header")
+	}
+	codec := string(rest[1 : 1+int(rest[0])])
+	body := bytes.NewReader(rest[1+int(rest[0]):])
+
+	switch codec {
+	case "gzip":
+		r, err := gzip.NewReader(body)
+		if err != nil {
+			return nil, fmt.Errorf("decompress: %w", err)
+		}
+		return io.ReadAll(r)
+	case "zstd":
+		r, err := zstd.NewReader(body)
+		if err != nil {
+			return nil, fmt.Errorf("decompress: %w", err)
+		}
+		defer r.Close()
+		return io.ReadAll(r)
+	default:
+		return nil, fmt.Errorf("unknown compression %q in object header", strings.ToValidUTF8(codec, "?"))
+	}
+}
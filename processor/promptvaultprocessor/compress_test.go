@@ -0,0 +1,70 @@
+package promptvaultprocessor
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestCompressThenEncrypt(t *testing.T) {
+	ctx := context.Background()
+	ring, _ := newKeyring([]keyringEntry{{id: "k", key: bytes.Repeat([]byte{1}, 32)}}, "")
+	backend := NewMemoryVault(0, 0)
+	encrypted := &encryptingVault{VaultStorage: backend, enc: ring}
+
+	for _, codec := range []string{"gzip", "zstd"} {
+		vault, err := newCompressingVault(encrypted, codec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		content := []byte(strings.Repeat("You are a helpful assistant. ", 200))
+		meta := ObjectMeta{TraceID: "t", SpanID: codec, Key: "gen_ai.system_instructions"}
+		ref, err := vault.Store(ctx, meta, content)
+		if err != nil {
+			t.Fatalf("%s: store: %v", codec, err)
+		}
+
+		// The stored object is an envelope around compressed content.
+		sealed, _ := backend.Retrieve(ctx, ref)
+		if len(sealed) >= len(content)/4 {
+			t.Errorf("%s: expected compression before encryption, stored %d bytes for %d", codec, len(sealed), len(content))
+		}
+		inner, err := encrypted.Retrieve(ctx, ref)
+		if err != nil || !bytes.HasPrefix(inner, compressMagic) {
+			t.Errorf("%s: expected compressed payload inside the envelope, err %v", codec, err)
+		}
+
+		var transforms string
+		backend.List(ctx, func(info ObjectInfo) error {
+			if info.Ref == ref {
+				transforms = info.Meta.Transforms
+			}
+			return nil
+		})
+		if transforms != codec+",encrypt" {
+			t.Errorf("%s: expected transformations %q, got %q", codec, codec+",encrypt", transforms)
+		}
+
+		data, err := vault.Retrieve(ctx, ref)
+		if err != nil || !bytes.Equal(data, content) {
+			t.Errorf("%s: expected round trip, err %v", codec, err)
+		}
+	}
+}
+
+func TestDecompressPassesThroughUncompressedObjects(t *testing.T) {
+	ctx := context.Background()
+	backend := NewMemoryVault(0, 0)
+	ref, _ := backend.Store(ctx, ObjectMeta{}, []byte("written before compression was enabled"))
+
+	vault, _ := newCompressingVault(backend, "zstd")
+	data, err := vault.Retrieve(ctx, ref)
+	if err != nil || string(data) != "written before compression was enabled" {
+		t.Errorf("expected uncompressed object unchanged, got %q, %v", data, err)
+	}
+
+	if _, err := newCompressingVault(backend, "brotli"); err == nil {
+		t.Error("expected error for unknown codec")
+	}
+}
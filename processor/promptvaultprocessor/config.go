@@ -1,30 +1,353 @@
 package promptvaultprocessor
 
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/collector/config/configopaque"
+)
+
 // Config for the prompt vault processor.
 type Config struct {
 	Storage StorageConfig `mapstructure:"storage"`
 	Vault   VaultConfig   `mapstructure:"vault"`
+	Crypto  CryptoConfig  `mapstructure:"crypto"`
+	// Redaction masks sensitive substrings before content is vaulted.
+	Redaction RedactionConfig `mapstructure:"redaction"`
+	// ContentSearch keeps a local full-text index of vaulted content.
+	ContentSearch ContentSearchConfig `mapstructure:"content_search"`
+	// StoreEvents emits an OTLP log record per vault store when its
+	// endpoint is set.
+	StoreEvents AuditOTLPConfig `mapstructure:"store_events"`
+	// Notify publishes an event after each successful store.
+	Notify NotifyConfig `mapstructure:"notify"`
+	// Transformers run, in order, on each value between matching and
+	// storage. Each names a transformer registered with RegisterTransformer.
+	Transformers []TransformerConfig `mapstructure:"transformers"`
 }
 
 // StorageConfig defines where vaulted content is stored.
 type StorageConfig struct {
-	Backend    string           `mapstructure:"backend"` // "filesystem" or "s3"
+	// Extension names a promptvaultstorage extension, e.g.
+	// "promptvaultstorage/shared", whose vault is used instead of opening
+	// one. The other storage settings and crypto are then ignored.
+	Extension string `mapstructure:"extension"`
+
+	Backend    string           `mapstructure:"backend"` // "filesystem", "postgres", "sqlite", "kafka", "memory", "http", or "tiered"
 	Filesystem FilesystemConfig `mapstructure:"filesystem"`
+	Postgres   PostgresConfig   `mapstructure:"postgres"`
+	SQLite     SQLiteConfig     `mapstructure:"sqlite"`
+	Kafka      KafkaConfig      `mapstructure:"kafka"`
+	Memory     MemoryConfig     `mapstructure:"memory"`
+	HTTP       HTTPConfig       `mapstructure:"http"`
+	Tiered     TieredConfig     `mapstructure:"tiered"`
+
+	// PathTemplate lays out object paths for path-addressed backends
+	// (filesystem, http), e.g. "{yyyy}/{mm}/{dd}/{service}/{trace_id}/{span_id}/{key}".
+	// The content hash is always appended. Empty = backend default layout.
+	PathTemplate string `mapstructure:"path_template"`
+
+	// CheckOnStart runs a write/read/delete probe during Start and fails
+	// startup if the backend is unusable.
+	CheckOnStart bool `mapstructure:"check_on_start"`
+	// Compression: "" (none), "gzip", or "zstd". Content is always compressed
+	// before it is encrypted.
+	Compression string `mapstructure:"compression"`
+	// Manifest keeps a tamper-evident log of stored objects.
+	Manifest ManifestConfig `mapstructure:"manifest"`
+	// Index keeps a metadata index of stored objects.
+	Index IndexConfig `mapstructure:"index"`
+	// TraceManifests keeps a manifest of each trace's objects.
+	TraceManifests TraceManifestConfig `mapstructure:"trace_manifests"`
+	// Bundles compacts old objects into bundles.
+	Bundles BundleConfig `mapstructure:"bundles"`
+	// Quotas tracks and caps what each tenant stores.
+	Quotas QuotaConfig `mapstructure:"quotas"`
+	// Routing stores each object in its data-residency region's backend.
+	Routing RoutingConfig `mapstructure:"routing"`
+}
+
+// ManifestConfig maintains a hash-chained, signed, append-only manifest of
+// every object stored, replaced, or deleted, one file per UTC day.
+type ManifestConfig struct {
+	// Dir holds the manifest-YYYY-MM-DD.jsonl files. Empty disables the manifest.
+	Dir string `mapstructure:"dir"`
+	// Signing: "hmac" (default, keyed by vault.signing_key) or "ed25519".
+	Signing string `mapstructure:"signing"`
+	// PrivateKeyFile is the PEM PKCS#8 Ed25519 key for ed25519 signing.
+	PrivateKeyFile string `mapstructure:"private_key_file"`
 }
 
 // FilesystemConfig for local file-based vault storage.
 type FilesystemConfig struct {
 	BasePath string `mapstructure:"base_path"`
+	// Layout is "date" (<base>/yyyy/mm/dd/<hash>.vault) or "sharded"
+	// (<base>/<hash[0:2]>/<hash[2:4]>/<hash>.vault). Objects written under
+	// either layout remain retrievable after switching.
+	Layout string `mapstructure:"layout"`
+	// MaxBytes and MaxObjects cap the vault's size; once exceeded the oldest
+	// objects are evicted. 0 = unbounded.
+	MaxBytes   ByteSize `mapstructure:"max_bytes"`
+	MaxObjects int      `mapstructure:"max_objects"`
+	// TTL evicts objects older than this duration. 0 = keep forever.
+	TTL time.Duration `mapstructure:"ttl"`
+	// EvictionInterval is how often quota and TTL are enforced.
+	EvictionInterval time.Duration `mapstructure:"eviction_interval"`
+	// WriteMetadata writes a <hash>.vault.json sidecar next to each object
+	// with its attribute key, service, tenant, and span IDs.
+	WriteMetadata bool `mapstructure:"write_metadata"`
+	// Sync also fsyncs the directory after each rename for strict durability.
+	// Object files themselves are always fsynced before being renamed into place.
+	Sync bool `mapstructure:"sync"`
+}
+
+// PostgresConfig for Postgres-backed vault storage.
+type PostgresConfig struct {
+	// DSN is a libpq-style connection string or postgres:// URL.
+	DSN configopaque.String `mapstructure:"dsn"`
+	// Table holds vaulted content; created on startup if missing.
+	Table string `mapstructure:"table"`
+	// MaxConns caps the connection pool size. 0 = pgx default.
+	MaxConns int32 `mapstructure:"max_conns"`
+}
+
+// SQLiteConfig for embedded single-node vault storage.
+type SQLiteConfig struct {
+	Path string `mapstructure:"path"`
+}
+
+// KafkaConfig for append-only vault storage on a Kafka topic.
+type KafkaConfig struct {
+	Brokers []string `mapstructure:"brokers"`
+	Topic   string   `mapstructure:"topic"`
+	// Timeout bounds each Retrieve, which otherwise waits for a record that
+	// may never be fetched.
+	Timeout time.Duration `mapstructure:"timeout"`
+}
+
+// MemoryConfig for the non-persistent in-memory vault.
+type MemoryConfig struct {
+	// MaxBytes bounds total stored content; oldest objects are evicted first. 0 = unbounded.
+	MaxBytes ByteSize `mapstructure:"max_bytes"`
+	// TTL expires objects after this duration. 0 = never expire.
+	TTL time.Duration `mapstructure:"ttl"`
+}
+
+// HTTPConfig for WebDAV or generic HTTP blob storage.
+type HTTPConfig struct {
+	// URLTemplate is the object URL; {hash} is replaced with the content sha256
+	// and {path} with the object path (see StorageConfig.PathTemplate).
+	URLTemplate string `mapstructure:"url_template"`
+	// Method used to upload objects. Defaults to PUT.
+	Method string `mapstructure:"method"`
+	// Headers are sent with every request, e.g. Authorization.
+	Headers map[string]configopaque.String `mapstructure:"headers"`
+	Timeout time.Duration                  `mapstructure:"timeout"`
+}
+
+// TieredConfig composes two of the backends above into hot/cold tiers.
+// Each tier is configured through its own backend section.
+type TieredConfig struct {
+	// Hot receives new objects; must support listing and deletion ("filesystem" or "memory").
+	Hot string `mapstructure:"hot"`
+	// Cold receives objects once they are older than MigrateAfter.
+	Cold string `mapstructure:"cold"`
+	// MigrateAfter is the age at which objects move from hot to cold.
+	MigrateAfter time.Duration `mapstructure:"migrate_after"`
+	// MigrateInterval is how often the hot tier is scanned.
+	MigrateInterval time.Duration `mapstructure:"migrate_interval"`
+}
+
+// CryptoConfig controls encryption of objects before they reach the backend.
+type CryptoConfig struct {
+	// Provider: "" (no encryption), "aws_kms", "local", "age", or "pkcs11".
+	Provider string `mapstructure:"provider"`
+	// KeySource restricts where the local provider loads keys from:
+	// "config" (local.keys), "env" (local.env_prefix), or "file"
+	// (local.keyring_file, e.g. a mounted Kubernetes secret). Empty uses
+	// whichever are set.
+	KeySource string `mapstructure:"key_source"`
+	// FIPS restricts encryption to FIPS 140 validated primitives and refuses
+	// to start unless the binary runs on a FIPS crypto module.
+	FIPS   bool            `mapstructure:"fips"`
+	AWSKMS AWSKMSConfig    `mapstructure:"aws_kms"`
+	Local  LocalKeysConfig `mapstructure:"local"`
+	Age    AgeConfig       `mapstructure:"age"`
+	PKCS11 PKCS11Config    `mapstructure:"pkcs11"`
+}
+
+// AWSKMSConfig for envelope encryption with AWS KMS data keys.
+type AWSKMSConfig struct {
+	// KeyID is the KMS key ARN, key ID, or alias used to generate data keys.
+	KeyID  string `mapstructure:"key_id"`
+	Region string `mapstructure:"region"`
+	// Endpoint overrides the KMS endpoint, e.g. for VPC endpoints or localstack.
+	Endpoint string `mapstructure:"endpoint"`
+	// DataKeyReuse shares one data key across objects for this long, cutting
+	// KMS calls. 0 = a new data key per object.
+	DataKeyReuse time.Duration `mapstructure:"data_key_reuse"`
+}
+
+// LocalKeysConfig for AES-256-GCM with locally managed keys.
+type LocalKeysConfig struct {
+	// Keys lists keys in the config itself, normally resolved through
+	// collector config providers such as ${env:VAR}, ${file:/path}, or a
+	// cloud secrets manager provider.
+	Keys []KeyConfig `mapstructure:"keys"`
+	// KeyringFile holds "<key id> <base64 key>" lines.
+	KeyringFile string `mapstructure:"keyring_file"`
+	// EnvPrefix loads keys from <prefix><ID>=<base64 key> environment variables.
+	//
+	// Deprecated: use Keys with ${env:...} references.
+	EnvPrefix string `mapstructure:"env_prefix"`
+	// ActiveKey encrypts new objects. Defaults to the first key loaded.
+	ActiveKey string `mapstructure:"active_key"`
+	// ReloadInterval re-reads KeyringFile this often and swaps in its keys
+	// when the content changes, so keys rotate without a restart. 0 = never.
+	ReloadInterval time.Duration `mapstructure:"reload_interval"`
+	// Convergent derives each object's key from its content so identical
+	// plaintexts encrypt identically and still deduplicate. This reveals
+	// which objects share content to anyone who can read the backend, and
+	// drops the trace/span binding of ciphertexts.
+	Convergent bool `mapstructure:"convergent"`
+}
+
+// KeyConfig is one AES-256 key given in the config.
+type KeyConfig struct {
+	ID string `mapstructure:"id"`
+	// Key is the base64-encoded 32-byte key.
+	Key configopaque.String `mapstructure:"key"`
+}
+
+// AgeConfig for objects stored in the age file format.
+type AgeConfig struct {
+	// Recipients are X25519 public keys (age1...) that can decrypt objects.
+	Recipients []string `mapstructure:"recipients"`
+	// IdentityFile holds private keys for decryption on retrieval. Collectors
+	// that only write objects can omit it.
+	IdentityFile string `mapstructure:"identity_file"`
+	// Armor writes PEM-style ASCII armor instead of binary.
+	Armor bool `mapstructure:"armor"`
+}
+
+// PKCS11Config for envelope encryption with a master key held in an HSM.
+type PKCS11Config struct {
+	// ModulePath is the vendor's PKCS#11 library, e.g. /usr/lib/softhsm/libsofthsm2.so.
+	ModulePath string              `mapstructure:"module_path"`
+	TokenLabel string              `mapstructure:"token_label"`
+	PIN        configopaque.String `mapstructure:"pin"`
+	// KeyLabel names the AES secret key on the token that wraps data keys.
+	KeyLabel string `mapstructure:"key_label"`
+}
+
+// AuditConfig selects where retrievals are recorded by components that read
+// vault content.
+type AuditConfig struct {
+	// Sink: "" (disabled), "file", "otlp", or "vault".
+	Sink string `mapstructure:"sink"`
+	// File is the append-only JSON lines log written by the file sink.
+	File string          `mapstructure:"file"`
+	OTLP AuditOTLPConfig `mapstructure:"otlp"`
+}
+
+// AuditOTLPConfig configures the otlp audit sink and store events.
+type AuditOTLPConfig struct {
+	// Endpoint is the OTLP/HTTP logs URL, e.g. https://collector:4318/v1/logs.
+	Endpoint string                         `mapstructure:"endpoint"`
+	Headers  map[string]configopaque.String `mapstructure:"headers"`
+}
+
+// RedactionConfig selects the detectors whose matches are masked as
+// [REDACTED:<DETECTOR>] before storage.
+type RedactionConfig struct {
+	Detectors RedactionDetectors `mapstructure:"detectors"`
+	// Patterns are additional regular expressions to mask.
+	Patterns []string `mapstructure:"patterns"`
+}
+
+// RedactionDetectors enables the built-in detectors.
+type RedactionDetectors struct {
+	Email bool `mapstructure:"email"`
+	Phone bool `mapstructure:"phone"`
+	// CreditCard matches 13-19 digit numbers that pass the Luhn check.
+	CreditCard bool `mapstructure:"credit_card"`
+	SSN        bool `mapstructure:"ssn"`
 }
 
 // VaultConfig controls which attributes get vaulted.
 type VaultConfig struct {
-	// Keys lists the attribute keys whose values should be vaulted.
+	// Keys lists the attribute keys whose values should be vaulted. Keys
+	// with glob metacharacters are path.Match patterns, e.g.
+	// "llm.input_messages.*.message.content" for indexed attributes.
 	Keys []string `mapstructure:"keys"`
+	// Presets add the keys of common instrumentation libraries to Keys,
+	// e.g. "openinference". See KeyPresets.
+	Presets []string `mapstructure:"presets"`
 	// SizeThreshold: only vault values larger than this (bytes). 0 = vault everything.
-	SizeThreshold int `mapstructure:"size_threshold"`
-	// Mode: "replace_with_ref" replaces value with vault://ref, "remove" deletes the attr.
+	SizeThreshold ByteSize `mapstructure:"size_threshold"`
+	// Mode: "replace_with_ref" replaces value with vault://ref, "remove" deletes the attr,
+	// "keep_and_ref" keeps the value (see KeptCopyTransform) and adds the ref,
+	// "tokenize" replaces detected values with tokens and vaults the mapping.
 	Mode string `mapstructure:"mode"`
+	// TenantAttribute names the resource attribute identifying the tenant,
+	// recorded in object metadata. Empty = no tenant.
+	TenantAttribute string `mapstructure:"tenant_attribute"`
+	// EndUserAttribute names the span or resource attribute identifying
+	// the end user, recorded in object metadata for erasure requests.
+	// Empty = not recorded.
+	EndUserAttribute string `mapstructure:"enduser_attribute"`
+	// RegionAttribute names the resource attribute, e.g. "cloud.region",
+	// giving the region storage.routing stores the object in. Empty = not
+	// recorded.
+	RegionAttribute string `mapstructure:"region_attribute"`
+	// LinkTemplate, when set, writes a clickable link to each reference to
+	// <key>.vault_url, e.g. "https://vault.internal/view?uri={uri}". See
+	// linkTemplatePlaceholders.
+	LinkTemplate string `mapstructure:"link_template"`
+	// RefFormat is "uri" (default) to write vault:// references, or "link"
+	// to write the link_template URL in their place, for trace UIs that
+	// render URL-valued attributes as links.
+	RefFormat string `mapstructure:"ref_format"`
+	// SigningKey, when set, signs each reference with HMAC-SHA256 over the
+	// reference, content checksum, and size, written to <key>.vault_sig.
+	SigningKey configopaque.String `mapstructure:"signing_key"`
+	// Compatibility adapts the attributes written next to vaulted values to
+	// a trace backend. "datadog" names them promptvault.ref.<key>,
+	// promptvault.url.<key>, and promptvault.sig.<key>, since Datadog cannot
+	// keep both <key> and <key>.vault_ref, and leaves out values longer than
+	// Datadog's 200-character tag limit. Empty = <key>.vault_ref and so on.
+	Compatibility string `mapstructure:"compatibility"`
+	// KeptCopyTransform sanitizes the value left in the span in keep_and_ref
+	// mode. The vault still receives the original.
+	KeptCopyTransform KeptCopyTransformConfig `mapstructure:"kept_copy_transform"`
+	// Tokenization configures tokenize mode.
+	Tokenization TokenizationConfig `mapstructure:"tokenization"`
+	// Policy consults an external policy engine before each value is vaulted.
+	Policy PolicyConfig `mapstructure:"policy"`
+	// OnQuotaExceeded is what happens to a value whose tenant is over its
+	// storage.quotas limit: "reject" (default) leaves it in the span
+	// unvaulted, "hash_only" replaces it with "sha256:<hex>" of its content.
+	OnQuotaExceeded string `mapstructure:"on_quota_exceeded"`
+}
+
+// TokenizationConfig selects what tokenize mode replaces. Detectors and
+// patterns are configured as for redaction.
+type TokenizationConfig struct {
+	RedactionConfig `mapstructure:",squash"`
+	// Key derives tokens with HMAC-SHA256, so the same value always maps to
+	// the same token. At least 32 bytes.
+	Key configopaque.String `mapstructure:"key"`
+}
+
+// KeptCopyTransformConfig scrubs the kept copy: matches are masked first,
+// then the result is truncated.
+type KeptCopyTransformConfig struct {
+	Redaction RedactionConfig `mapstructure:"redaction"`
+	// MaxLength truncates the kept copy to this many bytes. 0 = no limit.
+	MaxLength ByteSize `mapstructure:"max_length"`
 }
 
 func createDefaultConfig() *Config {
@@ -32,7 +355,31 @@ func createDefaultConfig() *Config {
 		Storage: StorageConfig{
 			Backend: "filesystem",
 			Filesystem: FilesystemConfig{
-				BasePath: "/data/vault",
+				BasePath:         "/data/vault",
+				Layout:           "date",
+				EvictionInterval: time.Minute,
+			},
+			Postgres: PostgresConfig{
+				Table: "prompt_vault",
+			},
+			SQLite: SQLiteConfig{
+				Path: "/data/vault/vault.db",
+			},
+			Kafka: KafkaConfig{
+				Topic:   "prompt-vault",
+				Timeout: 10 * time.Second,
+			},
+			Memory: MemoryConfig{
+				MaxBytes: 64 << 20,
+			},
+			HTTP: HTTPConfig{
+				Method:  "PUT",
+				Timeout: 10 * time.Second,
+			},
+			Tiered: TieredConfig{
+				Hot:             "filesystem",
+				MigrateAfter:    24 * time.Hour,
+				MigrateInterval: 10 * time.Minute,
 			},
 		},
 		Vault: VaultConfig{
@@ -43,8 +390,55 @@ func createDefaultConfig() *Config {
 				"gen_ai.input.messages",
 				"gen_ai.output.messages",
 			},
-			SizeThreshold: 0,
-			Mode:          "replace_with_ref",
+			SizeThreshold:    0,
+			Mode:             "replace_with_ref",
+			TenantAttribute:  "tenant.id",
+			EndUserAttribute: "enduser.id",
 		},
 	}
-}
\ No newline at end of file
+}
+
+// ByteSize is a size in bytes. In config it is either a plain number of
+// bytes or a number with a unit, e.g. "512B", "4KiB", "1.5MB" or "2GiB".
+// Binary units (KiB, MiB, GiB, TiB) are powers of 1024 and decimal units
+// (KB, MB, GB, TB) powers of 1000.
+type ByteSize int64
+
+var byteUnits = map[string]float64{
+	"":    1,
+	"b":   1,
+	"kb":  1e3,
+	"mb":  1e6,
+	"gb":  1e9,
+	"tb":  1e12,
+	"kib": 1 << 10,
+	"mib": 1 << 20,
+	"gib": 1 << 30,
+	"tib": 1 << 40,
+}
+
+// UnmarshalText parses a size such as "4KiB". The collector and
+// promptvaultctl both decode config through it.
+func (s *ByteSize) UnmarshalText(text []byte) error {
+	str := strings.TrimSpace(string(text))
+	num := strings.TrimRightFunc(str, func(r rune) bool {
+		return r == ' ' || ('a' <= r && r <= 'z') || ('A' <= r && r <= 'Z')
+	})
+	mult, ok := byteUnits[strings.ToLower(strings.TrimSpace(str[len(num):]))]
+	if !ok {
+		return fmt.Errorf("invalid byte size %q: unknown unit, use B, KB, MB, GB, TB, KiB, MiB, GiB or TiB", str)
+	}
+	if n, err := strconv.ParseInt(num, 10, 64); err == nil && mult == 1 {
+		*s = ByteSize(n)
+		return nil
+	}
+	f, err := strconv.ParseFloat(num, 64)
+	if err != nil || f < 0 || math.IsInf(f, 0) || math.IsNaN(f) {
+		return fmt.Errorf("invalid byte size %q", str)
+	}
+	if f*mult >= math.MaxInt64 {
+		return fmt.Errorf("invalid byte size %q: too large", str)
+	}
+	*s = ByteSize(f * mult)
+	return nil
+}
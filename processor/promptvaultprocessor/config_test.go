@@ -0,0 +1,94 @@
+package promptvaultprocessor
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-viper/mapstructure/v2"
+	"go.opentelemetry.io/collector/config/configopaque"
+)
+
+func TestSecretsAreRedacted(t *testing.T) {
+	cfg := Config{Storage: StorageConfig{
+		Postgres: PostgresConfig{DSN: "postgres://vault:hunter2@db/vault"},
+		HTTP:     HTTPConfig{Headers: map[string]configopaque.String{"Authorization": "Bearer hunter2"}},
+	}}
+
+	dumped, err := json.Marshal(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, out := range []string{
+		fmt.Sprintf("%v", cfg),
+		fmt.Sprintf("%+v", cfg),
+		fmt.Sprintf("%#v", cfg),
+		string(dumped),
+	} {
+		if strings.Contains(out, "hunter2") {
+			t.Errorf("secret leaked: %s", out)
+		}
+	}
+}
+
+func TestByteSize(t *testing.T) {
+	for in, want := range map[string]ByteSize{
+		"0":       0,
+		"4096":    4096,
+		"512B":    512,
+		"4KiB":    4096,
+		"4 KiB":   4096,
+		"4kb":     4000,
+		"1.5MiB":  1572864,
+		"2GB":     2e9,
+		"1TiB":    1 << 40,
+		" 64MiB ": 64 << 20,
+	} {
+		var got ByteSize
+		if err := got.UnmarshalText([]byte(in)); err != nil {
+			t.Errorf("%q: %v", in, err)
+		} else if got != want {
+			t.Errorf("%q = %d, want %d", in, got, want)
+		}
+	}
+	for _, in := range []string{"", "KiB", "4XB", "-1KiB", "1e30TiB", "four"} {
+		var got ByteSize
+		if err := got.UnmarshalText([]byte(in)); err == nil {
+			t.Errorf("%q: parsed as %d, want error", in, got)
+		}
+	}
+}
+
+// TestConfigUnits decodes config with the hooks the collector's confmap
+// applies, so sizes and durations can be written with units.
+func TestConfigUnits(t *testing.T) {
+	cfg := createDefaultConfig()
+	dec, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		Result: cfg,
+		DecodeHook: mapstructure.ComposeDecodeHookFunc(
+			mapstructure.StringToTimeDurationHookFunc(),
+			mapstructure.TextUnmarshallerHookFunc(),
+		),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = dec.Decode(map[string]any{
+		"vault": map[string]any{"size_threshold": "4KiB"},
+		"storage": map[string]any{
+			"filesystem": map[string]any{"max_bytes": 1 << 20, "ttl": "720h"},
+			"memory":     map[string]any{"max_bytes": "64MiB"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Vault.SizeThreshold != 4096 || cfg.Storage.Filesystem.MaxBytes != 1<<20 || cfg.Storage.Memory.MaxBytes != 64<<20 {
+		t.Errorf("sizes = %d, %d, %d", cfg.Vault.SizeThreshold, cfg.Storage.Filesystem.MaxBytes, cfg.Storage.Memory.MaxBytes)
+	}
+	if cfg.Storage.Filesystem.TTL != 720*time.Hour {
+		t.Errorf("ttl = %s", cfg.Storage.Filesystem.TTL)
+	}
+}
@@ -0,0 +1,284 @@
+package promptvaultprocessor
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+	"unicode/utf8"
+)
+
+// ContentSearchConfig enables a local full-text index over vaulted content,
+// for debugging. The index holds content decrypted, so it is off unless
+// explicitly enabled and should live on an encrypted local disk.
+type ContentSearchConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// Path is the index's SQLite database file. Required when enabled.
+	Path string `mapstructure:"path"`
+}
+
+// ContentIndex is an SQLite FTS4 index over vaulted text content. Each
+// object stored for a span has a document; binary content is not indexed.
+type ContentIndex struct {
+	db *sql.DB
+}
+
+// OpenContentIndex opens (or creates) the index at path.
+func OpenContentIndex(ctx context.Context, path string) (*ContentIndex, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, fmt.Errorf("create content index dir: %w", err)
+	}
+	db, err := openSQLite(path)
+	if err != nil {
+		return nil, fmt.Errorf("open content index: %w", err)
+	}
+
+	_, err = db.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS docs (
+			docid     INTEGER PRIMARY KEY,
+			ref       TEXT    NOT NULL,
+			trace_id  TEXT    NOT NULL,
+			span_id   TEXT    NOT NULL,
+			attr_key  TEXT    NOT NULL,
+			service   TEXT    NOT NULL,
+			tenant    TEXT    NOT NULL,
+			stored_at INTEGER NOT NULL
+		);
+		CREATE INDEX IF NOT EXISTS docs_ref_idx ON docs (ref);
+		CREATE VIRTUAL TABLE IF NOT EXISTS docs_fts USING fts4(content, tokenize=unicode61);`)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("create content index schema: %w", err)
+	}
+	return &ContentIndex{db: db}, nil
+}
+
+// Add indexes content stored at ref for the span in meta. Content that is
+// not valid UTF-8 is skipped.
+func (x *ContentIndex) Add(ctx context.Context, ref string, meta ObjectMeta, content []byte) error {
+	if !utf8.Valid(content) {
+		return nil
+	}
+	tx, err := x.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	res, err := tx.ExecContext(ctx,
+		`INSERT INTO docs (ref, trace_id, span_id, attr_key, service, tenant, stored_at) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		ref, meta.TraceID, meta.SpanID, meta.Key, meta.Service, meta.Tenant, time.Now().UTC().UnixNano())
+	if err != nil {
+		return err
+	}
+	docid, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `INSERT INTO docs_fts (docid, content) VALUES (?, ?)`, docid, string(content)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Remove drops every document for ref.
+func (x *ContentIndex) Remove(ctx context.Context, ref string) error {
+	tx, err := x.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx, `DELETE FROM docs_fts WHERE docid IN (SELECT docid FROM docs WHERE ref = ?)`, ref); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM docs WHERE ref = ?`, ref); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Update replaces the indexed content of every document for ref, e.g. after
+// the object was redacted in place. Content that is not valid UTF-8 drops
+// the documents.
+func (x *ContentIndex) Update(ctx context.Context, ref string, content []byte) error {
+	if !utf8.Valid(content) {
+		return x.Remove(ctx, ref)
+	}
+	_, err := x.db.ExecContext(ctx, `UPDATE docs_fts SET content = ? WHERE docid IN (SELECT docid FROM docs WHERE ref = ?)`, string(content), ref)
+	return err
+}
+
+// ContentMatch is a document matching a search.
+type ContentMatch struct {
+	Ref      string
+	Meta     ObjectMeta
+	StoredAt time.Time
+	// Snippet is the matching text with matches in [brackets].
+	Snippet string
+}
+
+// Search returns up to limit documents matching query, newest first.
+// query uses SQLite full-text syntax: words match as tokens, ignoring
+// case, and a "quoted phrase" matches those words in order.
+func (x *ContentIndex) Search(ctx context.Context, query string, limit int) ([]ContentMatch, error) {
+	rows, err := x.db.QueryContext(ctx, `
+		SELECT d.ref, d.trace_id, d.span_id, d.attr_key, d.service, d.tenant, d.stored_at,
+			snippet(docs_fts, '[', ']', '...', -1, 24)
+		FROM docs_fts JOIN docs d ON d.docid = docs_fts.docid
+		WHERE docs_fts MATCH ?
+		ORDER BY d.stored_at DESC LIMIT ?`, query, limit)
+	if err != nil {
+		return nil, fmt.Errorf("search content index: %w", err)
+	}
+	defer rows.Close()
+	var out []ContentMatch
+	for rows.Next() {
+		var m ContentMatch
+		var storedAt int64
+		if err := rows.Scan(&m.Ref, &m.Meta.TraceID, &m.Meta.SpanID, &m.Meta.Key, &m.Meta.Service,
+			&m.Meta.Tenant, &storedAt, &m.Snippet); err != nil {
+			return nil, fmt.Errorf("scan content index: %w", err)
+		}
+		m.StoredAt = time.Unix(0, storedAt)
+		out = append(out, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("search content index: %w", err)
+	}
+	return out, nil
+}
+
+// Close closes the index.
+func (x *ContentIndex) Close() error {
+	return x.db.Close()
+}
+
+// contentSearchVault adds each stored object's plaintext to a ContentIndex.
+// It is the outermost wrapper, so it sees content after redaction and
+// before compression and encryption. Indexing errors fail the store, so a
+// retry indexes the object.
+type contentSearchVault struct {
+	VaultStorage
+	index *ContentIndex
+}
+
+func newContentSearchVault(ctx context.Context, vault VaultStorage, cfg ContentSearchConfig) (VaultStorage, error) {
+	if !cfg.Enabled {
+		return vault, nil
+	}
+	if cfg.Path == "" {
+		return nil, fmt.Errorf("content_search needs a path")
+	}
+	index, err := OpenContentIndex(ctx, cfg.Path)
+	if err != nil {
+		return nil, err
+	}
+	return &contentSearchVault{VaultStorage: vault, index: index}, nil
+}
+
+func (v *contentSearchVault) Store(ctx context.Context, meta ObjectMeta, content []byte) (string, error) {
+	ref, err := v.VaultStorage.Store(ctx, meta, content)
+	if err != nil {
+		return "", err
+	}
+	if err := v.index.Add(ctx, ref, meta, content); err != nil {
+		return "", fmt.Errorf("index content of %s: %w", ref, err)
+	}
+	return ref, nil
+}
+
+// RetrieveFor, List, Stat, Search, Compact, Usage, and Start forward to the
+// wrapped vault; Delete, Replace, and Close also update and close the index.
+
+func (v *contentSearchVault) RetrieveFor(ctx context.Context, ref string, meta ObjectMeta) ([]byte, error) {
+	return RetrieveFor(ctx, v.VaultStorage, ref, meta)
+}
+
+func (v *contentSearchVault) List(ctx context.Context, fn func(ObjectInfo) error) error {
+	if l, ok := v.VaultStorage.(VaultLister); ok {
+		return l.List(ctx, fn)
+	}
+	return fmt.Errorf("list: %w", errors.ErrUnsupported)
+}
+
+func (v *contentSearchVault) Stat(ctx context.Context, ref string) (ObjectInfo, error) {
+	if s, ok := v.VaultStorage.(VaultStatter); ok {
+		return s.Stat(ctx, ref)
+	}
+	return ObjectInfo{}, fmt.Errorf("stat: %w", errors.ErrUnsupported)
+}
+
+func (v *contentSearchVault) Search(ctx context.Context, f ObjectFilter, fn func(ObjectInfo) error) error {
+	if s, ok := v.VaultStorage.(VaultSearcher); ok {
+		return s.Search(ctx, f, fn)
+	}
+	return fmt.Errorf("search: %w", errors.ErrUnsupported)
+}
+
+func (v *contentSearchVault) Compact(ctx context.Context) (CompactionStats, error) {
+	if c, ok := v.VaultStorage.(VaultCompactor); ok {
+		return c.Compact(ctx)
+	}
+	return CompactionStats{}, fmt.Errorf("compact: %w", errors.ErrUnsupported)
+}
+
+func (v *contentSearchVault) Usage(ctx context.Context) ([]TenantUsage, error) {
+	if u, ok := v.VaultStorage.(VaultUsageReporter); ok {
+		return u.Usage(ctx)
+	}
+	return nil, fmt.Errorf("usage: %w", errors.ErrUnsupported)
+}
+
+func (v *contentSearchVault) Stats(ctx context.Context) (VaultStats, error) {
+	if r, ok := v.VaultStorage.(VaultStatsReporter); ok {
+		return r.Stats(ctx)
+	}
+	return VaultStats{}, fmt.Errorf("stats: %w", errors.ErrUnsupported)
+}
+
+func (v *contentSearchVault) Delete(ctx context.Context, ref string) error {
+	d, ok := v.VaultStorage.(VaultDeleter)
+	if !ok {
+		return fmt.Errorf("delete: %w", errors.ErrUnsupported)
+	}
+	err := d.Delete(ctx, ref)
+	if err != nil && !errors.Is(err, ErrObjectNotFound) {
+		return err
+	}
+	if ierr := v.index.Remove(ctx, ref); ierr != nil {
+		return fmt.Errorf("remove %s from content index: %w", ref, ierr)
+	}
+	return err
+}
+
+func (v *contentSearchVault) Replace(ctx context.Context, ref string, content []byte) error {
+	r, ok := v.VaultStorage.(VaultReplacer)
+	if !ok {
+		return fmt.Errorf("replace: %w", errors.ErrUnsupported)
+	}
+	if err := r.Replace(ctx, ref, content); err != nil {
+		return err
+	}
+	if err := v.index.Update(ctx, ref, content); err != nil {
+		return fmt.Errorf("reindex content of %s: %w", ref, err)
+	}
+	return nil
+}
+
+func (v *contentSearchVault) Start(ctx context.Context) error {
+	if s, ok := v.VaultStorage.(vaultStarter); ok {
+		return s.Start(ctx)
+	}
+	return nil
+}
+
+func (v *contentSearchVault) Close() error {
+	err := v.index.Close()
+	if c, ok := v.VaultStorage.(io.Closer); ok {
+		err = errors.Join(err, c.Close())
+	}
+	return err
+}
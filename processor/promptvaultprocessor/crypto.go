@@ -0,0 +1,412 @@
+package promptvaultprocessor
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// Encryptor seals objects before they reach a backend and opens them on
+// retrieval. aad is authenticated but not encrypted; Decrypt fails unless it
+// is given the same aad that Encrypt was.
+type Encryptor interface {
+	Encrypt(ctx context.Context, plaintext, aad []byte) (ciphertext []byte, keyID string, err error)
+	Decrypt(ctx context.Context, ciphertext, aad []byte) ([]byte, error)
+}
+
+// newEncryptor builds the encryptor selected by cfg.Provider, or nil when
+// encryption is disabled.
+func newEncryptor(ctx context.Context, cfg CryptoConfig) (Encryptor, error) {
+	if err := validateFIPS(cfg); err != nil {
+		return nil, err
+	}
+	if cfg.Local.Convergent && cfg.Provider != "local" {
+		return nil, fmt.Errorf("crypto.local.convergent requires crypto.provider local " +
+			"(it trades confidentiality for dedup: equal plaintexts produce equal ciphertexts)")
+	}
+	switch cfg.Provider {
+	case "":
+		return nil, nil
+	case "aws_kms":
+		return openKMSEncryptor(ctx, cfg.AWSKMS)
+	case "local":
+		local := cfg.Local
+		switch cfg.KeySource {
+		case "":
+		case "config":
+			if len(local.Keys) == 0 {
+				return nil, fmt.Errorf("crypto.key_source config requires crypto.local.keys")
+			}
+			local.KeyringFile, local.EnvPrefix = "", ""
+		case "env":
+			if local.EnvPrefix == "" {
+				return nil, fmt.Errorf("crypto.key_source env requires crypto.local.env_prefix")
+			}
+			local.Keys, local.KeyringFile = nil, ""
+		case "file":
+			if local.KeyringFile == "" {
+				return nil, fmt.Errorf("crypto.key_source file requires crypto.local.keyring_file")
+			}
+			local.Keys, local.EnvPrefix = nil, ""
+		default:
+			return nil, fmt.Errorf("unknown crypto.key_source %q", cfg.KeySource)
+		}
+		return NewKeyring(local)
+	case "age":
+		return NewAgeEncryptor(cfg.Age)
+	case "pkcs11":
+		return NewHSMEncryptor(cfg.PKCS11)
+	default:
+		return nil, fmt.Errorf("unknown crypto provider %q", cfg.Provider)
+	}
+}
+
+// encryptingVault encrypts content on Store and decrypts it on Retrieve.
+// Backend-side dedup no longer applies, since ciphertexts differ per object.
+//
+// Each ciphertext is bound to its trace ID, span ID, and attribute key, so an
+// object copied to another span's path fails to decrypt.
+type encryptingVault struct {
+	VaultStorage
+	enc          Encryptor
+	pathTemplate string
+}
+
+func (v *encryptingVault) Store(ctx context.Context, meta ObjectMeta, content []byte) (string, error) {
+	sealed, keyID, err := v.enc.Encrypt(ctx, content, bindingAAD(meta))
+	if err != nil {
+		return "", fmt.Errorf("encrypt vault object: %w", err)
+	}
+	meta.KeyID = keyID
+	meta.Transforms = appendTransform(meta.Transforms, transformEncrypt)
+	return v.VaultStorage.Store(ctx, meta, sealed)
+}
+
+// Retrieve decrypts the object at ref. The binding is taken from the path
+// template fields in ref where present, and from the envelope header
+// otherwise; content-addressed refs must also match the ciphertext hash.
+// Callers that know which span the ref belongs to should use RetrieveFor.
+func (v *encryptingVault) Retrieve(ctx context.Context, ref string) ([]byte, error) {
+	sealed, err := v.retrieveSealed(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	var bound ObjectMeta
+	if env, err := unmarshalEnvelope(sealed); err == nil {
+		bound = parseBindingAAD(env.AAD)
+	}
+	if v.pathTemplate != "" {
+		if objPath, err := objectPathFromRef(unroutedRef(ref)); err == nil {
+			fromPath := metaFromObjectPath(v.pathTemplate, objPath)
+			if fromPath.TraceID != "" {
+				bound.TraceID = fromPath.TraceID
+			}
+			if fromPath.SpanID != "" {
+				bound.SpanID = fromPath.SpanID
+			}
+			if fromPath.Key != "" {
+				bound.Key = fromPath.Key
+			}
+		}
+	}
+	return v.open(ctx, ref, sealed, bound)
+}
+
+// RetrieveFor decrypts the object at ref, requiring it to have been stored
+// for the trace, span, and attribute key in meta.
+func (v *encryptingVault) RetrieveFor(ctx context.Context, ref string, meta ObjectMeta) ([]byte, error) {
+	sealed, err := v.retrieveSealed(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	return v.open(ctx, ref, sealed, meta)
+}
+
+func (v *encryptingVault) retrieveSealed(ctx context.Context, ref string) ([]byte, error) {
+	sealed, err := v.VaultStorage.Retrieve(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	if hash := hashFromRef(ref); isContentHash(hash) && contentHash(sealed) != hash && !isRebound(sealed) {
+		return nil, fmt.Errorf("decrypt %s: content does not match ref", ref)
+	}
+	return sealed, nil
+}
+
+func (v *encryptingVault) open(ctx context.Context, ref string, sealed []byte, meta ObjectMeta) ([]byte, error) {
+	aad := bindingAAD(meta)
+	if isRebound(sealed) {
+		aad = reboundAAD(aad, hashFromRef(ref))
+	}
+	content, err := v.enc.Decrypt(ctx, sealed, aad)
+	if err != nil {
+		return nil, fmt.Errorf("decrypt %s: %w", ref, err)
+	}
+	return content, nil
+}
+
+// bindingAAD is the additional data that ties a ciphertext to its span. The
+// key goes last since it is the only field that may contain "/".
+func bindingAAD(meta ObjectMeta) []byte {
+	return []byte(meta.TraceID + "/" + meta.SpanID + "/" + meta.Key)
+}
+
+// reboundAAD extends a binding with the ref hash an object was re-encrypted
+// in place under. Re-encryption keeps existing refs valid, so the ciphertext
+// hash no longer matches the ref; binding the ref hash instead still makes a
+// copy under any other ref fail to decrypt.
+func reboundAAD(aad []byte, refHash string) []byte {
+	return append(append([]byte(nil), aad...), "\nref="+refHash...)
+}
+
+// isRebound reports whether sealed was re-encrypted with reboundAAD.
+func isRebound(sealed []byte) bool {
+	env, err := unmarshalEnvelope(sealed)
+	return err == nil && strings.Contains(string(env.AAD), "\nref=")
+}
+
+func parseBindingAAD(aad []byte) ObjectMeta {
+	binding, _, _ := strings.Cut(string(aad), "\n")
+	parts := strings.SplitN(binding, "/", 3)
+	if len(parts) != 3 {
+		return ObjectMeta{}
+	}
+	return ObjectMeta{TraceID: parts[0], SpanID: parts[1], Key: parts[2]}
+}
+
+// List, Stat, Search, Compact, Usage, Delete, Start, and Close forward to the wrapped backend so optional
+// capabilities survive wrapping; Start and Close also manage the encryptor.
+
+func (v *encryptingVault) List(ctx context.Context, fn func(ObjectInfo) error) error {
+	if l, ok := v.VaultStorage.(VaultLister); ok {
+		return l.List(ctx, fn)
+	}
+	return fmt.Errorf("list: %w", errors.ErrUnsupported)
+}
+
+func (v *encryptingVault) Stat(ctx context.Context, ref string) (ObjectInfo, error) {
+	if s, ok := v.VaultStorage.(VaultStatter); ok {
+		return s.Stat(ctx, ref)
+	}
+	return ObjectInfo{}, fmt.Errorf("stat: %w", errors.ErrUnsupported)
+}
+
+func (v *encryptingVault) Search(ctx context.Context, f ObjectFilter, fn func(ObjectInfo) error) error {
+	if s, ok := v.VaultStorage.(VaultSearcher); ok {
+		return s.Search(ctx, f, fn)
+	}
+	return fmt.Errorf("search: %w", errors.ErrUnsupported)
+}
+
+func (v *encryptingVault) Compact(ctx context.Context) (CompactionStats, error) {
+	if c, ok := v.VaultStorage.(VaultCompactor); ok {
+		return c.Compact(ctx)
+	}
+	return CompactionStats{}, fmt.Errorf("compact: %w", errors.ErrUnsupported)
+}
+
+func (v *encryptingVault) Usage(ctx context.Context) ([]TenantUsage, error) {
+	if u, ok := v.VaultStorage.(VaultUsageReporter); ok {
+		return u.Usage(ctx)
+	}
+	return nil, fmt.Errorf("usage: %w", errors.ErrUnsupported)
+}
+
+func (v *encryptingVault) Stats(ctx context.Context) (VaultStats, error) {
+	if r, ok := v.VaultStorage.(VaultStatsReporter); ok {
+		return r.Stats(ctx)
+	}
+	return VaultStats{}, fmt.Errorf("stats: %w", errors.ErrUnsupported)
+}
+
+// Replace encrypts content under the binding of the object at ref and
+// overwrites it, keeping ref valid as ReEncrypt does.
+func (v *encryptingVault) Replace(ctx context.Context, ref string, content []byte) error {
+	r, ok := v.VaultStorage.(VaultReplacer)
+	if !ok {
+		return fmt.Errorf("replace: %w", errors.ErrUnsupported)
+	}
+	sealed, err := v.retrieveSealed(ctx, ref)
+	if err != nil {
+		return err
+	}
+	env, err := unmarshalEnvelope(sealed)
+	if err != nil {
+		return fmt.Errorf("replace %s: not an encrypted vault object: %w", ref, err)
+	}
+	aad := env.AAD
+	if hash := hashFromRef(ref); isContentHash(hash) && !isRebound(sealed) {
+		aad = reboundAAD(aad, hash)
+	}
+	resealed, _, err := v.enc.Encrypt(ctx, content, aad)
+	if err != nil {
+		return fmt.Errorf("encrypt vault object: %w", err)
+	}
+	if renv, err := unmarshalEnvelope(resealed); err != nil || !bytes.Equal(renv.AAD, aad) {
+		return fmt.Errorf("replace %s: encryption does not bind refs (convergent or age) and would invalidate the ref", ref)
+	}
+	return r.Replace(ctx, ref, resealed)
+}
+
+func (v *encryptingVault) Delete(ctx context.Context, ref string) error {
+	if d, ok := v.VaultStorage.(VaultDeleter); ok {
+		return d.Delete(ctx, ref)
+	}
+	return fmt.Errorf("delete: %w", errors.ErrUnsupported)
+}
+
+func (v *encryptingVault) Start(ctx context.Context) error {
+	for _, c := range []any{v.VaultStorage, v.enc} {
+		if s, ok := c.(vaultStarter); ok {
+			if err := s.Start(ctx); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (v *encryptingVault) Close() error {
+	var errs []error
+	for _, c := range []any{v.enc, v.VaultStorage} {
+		if closer, ok := c.(io.Closer); ok {
+			errs = append(errs, closer.Close())
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Envelope layout:
+//
+//	magic "PVE1" | u16 len | key ID | u16 len | wrapped data key | u16 len | aad | 12-byte nonce | AES-256-GCM ciphertext
+var envelopeMagic = []byte("PVE1")
+
+// envelope is an object encrypted under a data key that is itself wrapped by a
+// key-management service.
+type envelope struct {
+	KeyID      string
+	WrappedKey []byte
+	AAD        []byte
+	Nonce      []byte
+	Ciphertext []byte
+}
+
+func (e envelope) marshal() []byte {
+	out := make([]byte, 0, len(envelopeMagic)+6+len(e.KeyID)+len(e.WrappedKey)+len(e.AAD)+len(e.Nonce)+len(e.Ciphertext))
+	out = append(out, envelopeMagic...)
+	out = binary.BigEndian.AppendUint16(out, uint16(len(e.KeyID)))
+	out = append(out, e.KeyID...)
+	out = binary.BigEndian.AppendUint16(out, uint16(len(e.WrappedKey)))
+	out = append(out, e.WrappedKey...)
+	out = binary.BigEndian.AppendUint16(out, uint16(len(e.AAD)))
+	out = append(out, e.AAD...)
+	out = append(out, e.Nonce...)
+	return append(out, e.Ciphertext...)
+}
+
+var errMalformedEnvelope = errors.New("malformed encryption envelope")
+
+func unmarshalEnvelope(data []byte) (envelope, error) {
+	var e envelope
+	if len(data) < len(envelopeMagic) || string(data[:len(envelopeMagic)]) != string(envelopeMagic) {
+		return e, errMalformedEnvelope
+	}
+	rest := data[len(envelopeMagic):]
+
+	readField := func() ([]byte, error) {
+		if len(rest) < 2 {
+			return nil, errMalformedEnvelope
+		}
+		n := int(binary.BigEndian.Uint16(rest))
+		if len(rest) < 2+n {
+			return nil, errMalformedEnvelope
+		}
+		field := rest[2 : 2+n]
+		rest = rest[2+n:]
+		return field, nil
+	}
+
+	keyID, err := readField()
+	if err != nil {
+		return e, err
+	}
+	wrapped, err := readField()
+	if err != nil {
+		return e, err
+	}
+	aad, err := readField()
+	if err != nil {
+		return e, err
+	}
+	if len(rest) < gcmNonceSize {
+		return e, errMalformedEnvelope
+	}
+
+	e.KeyID = string(keyID)
+	e.WrappedKey = wrapped
+	e.AAD = aad
+	e.Nonce = rest[:gcmNonceSize]
+	e.Ciphertext = rest[gcmNonceSize:]
+	return e, nil
+}
+
+const gcmNonceSize = 12
+
+func sealGCM(key, plaintext, aad []byte) (nonce, ciphertext []byte, err error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	nonce = make([]byte, gcmNonceSize)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, nil, err
+	}
+	return nonce, aead.Seal(nil, nonce, plaintext, aad), nil
+}
+
+func openGCM(key, nonce, ciphertext, aad []byte) ([]byte, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	return aead.Open(nil, nonce, ciphertext, aad)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// wrapWithEncryption applies cfg to vault, returning it unchanged when
+// encryption is disabled.
+func wrapWithEncryption(ctx context.Context, logger *zap.Logger, cfg CryptoConfig, pathTemplate string, vault VaultStorage) (VaultStorage, error) {
+	enc, err := newEncryptor(ctx, cfg)
+	if err != nil || enc == nil {
+		return vault, err
+	}
+	if k, ok := enc.(*Keyring); ok {
+		k.logger = logger
+		if k.cfg.Convergent {
+			logger.Warn("convergent encryption enabled: objects with identical content produce identical " +
+				"ciphertexts, revealing content equality to backend readers, and are not bound to their span")
+		}
+	}
+	logger.Info("vault encryption enabled",
+		zap.String("provider", cfg.Provider),
+		zap.Bool("fips_mode", cfg.FIPS),
+		zap.Bool("fips_140_module", fipsModuleEnabled()),
+	)
+	return &encryptingVault{VaultStorage: vault, enc: enc, pathTemplate: pathTemplate}, nil
+}
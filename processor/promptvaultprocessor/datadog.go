@@ -0,0 +1,71 @@
+package promptvaultprocessor
+
+import (
+	"fmt"
+	"strings"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.uber.org/zap"
+)
+
+// Datadog expands dotted attribute names into nested objects, so
+// gen_ai.prompt cannot hold a value and also be the parent of
+// gen_ai.prompt.vault_ref: one of the two is dropped. It also limits tag
+// values to 200 characters. In datadog compatibility mode the attributes
+// accompanying a vaulted value are therefore named
+// promptvault.<kind>.<key>, and values over the limit are left out.
+const (
+	compatDatadog   = "datadog"
+	datadogTagLimit = 200
+	// companionPrefix starts the names of companion attributes in datadog
+	// compatibility mode.
+	companionPrefix = "promptvault."
+)
+
+// Kinds of companion attribute written next to a vaulted value.
+var companionKinds = []string{"ref", "url", "sig"}
+
+func validateCompatibility(cfg VaultConfig) error {
+	switch cfg.Compatibility {
+	case "", compatDatadog:
+		return nil
+	default:
+		return fmt.Errorf("unknown vault.compatibility %q", cfg.Compatibility)
+	}
+}
+
+// companionKey names the attribute holding kind ("ref", "url", or "sig")
+// for key: <key>.vault_<kind>, or promptvault.<kind>.<key> in datadog
+// compatibility mode.
+func companionKey(cfg VaultConfig, key, kind string) string {
+	if cfg.Compatibility == compatDatadog {
+		return companionPrefix + kind + "." + key
+	}
+	return key + ".vault_" + kind
+}
+
+// isCompanion reports whether key names a companion attribute, in either
+// layout.
+func isCompanion(key string) bool {
+	for _, kind := range companionKinds {
+		if strings.HasSuffix(key, ".vault_"+kind) || strings.HasPrefix(key, companionPrefix+kind+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// putCompanion writes the kind companion attribute of key.
+func (p *vaultProcessor) putCompanion(attrs pcommon.Map, key, kind, value string) {
+	name := companionKey(p.config.Vault, key, kind)
+	if p.config.Vault.Compatibility == compatDatadog && len(value) > datadogTagLimit {
+		// A truncated reference or signature is useless, so it is left out.
+		p.logger.Warn("attribute left out: longer than Datadog's tag value limit",
+			zap.String("attribute", name),
+			zap.Int("length", len(value)),
+			zap.Int("limit", datadogTagLimit),
+		)
+		return
+	}
+	attrs.PutStr(name, value)
+}
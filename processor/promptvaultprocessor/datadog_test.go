@@ -0,0 +1,43 @@
+package promptvaultprocessor
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.uber.org/zap"
+)
+
+func TestDatadogCompatibility(t *testing.T) {
+	vault := NewMemoryVault(0, 0)
+	cfg := createDefaultConfig()
+	cfg.Vault.Compatibility = "datadog"
+	cfg.Vault.LinkTemplate = "https://vault.internal/objects/{ref}?pad=" + strings.Repeat("x", datadogTagLimit)
+	sink := new(consumertest.TracesSink)
+	proc := newVaultProcessor(zap.NewNop(), cfg, vault, sink)
+
+	td := ptrace.NewTraces()
+	span := td.ResourceSpans().AppendEmpty().ScopeSpans().AppendEmpty().Spans().AppendEmpty()
+	span.Attributes().PutStr("gen_ai.prompt", "what is my balance?")
+	proc.ConsumeTraces(context.Background(), td)
+
+	attrs := sink.AllTraces()[0].ResourceSpans().At(0).ScopeSpans().At(0).Spans().At(0).Attributes()
+	if v, _ := attrs.Get("promptvault.ref.gen_ai.prompt"); !strings.HasPrefix(v.Str(), refScheme) {
+		t.Errorf("promptvault.ref.gen_ai.prompt = %q, want a reference", v.Str())
+	}
+	if _, ok := attrs.Get("gen_ai.prompt.vault_ref"); ok {
+		t.Error("gen_ai.prompt.vault_ref written, which Datadog cannot keep beside gen_ai.prompt")
+	}
+	if _, ok := attrs.Get("promptvault.url.gen_ai.prompt"); ok {
+		t.Error("link over the tag limit written")
+	}
+
+	if !isCompanion("promptvault.ref.gen_ai.prompt") || newKeyMatcher([]string{"*.prompt"}).matches("promptvault.ref.gen_ai.prompt") {
+		t.Error("pattern matched a prefixed reference attribute")
+	}
+	if err := validateCompatibility(VaultConfig{Compatibility: "honeycomb"}); err == nil {
+		t.Error("unknown compatibility accepted")
+	}
+}
@@ -0,0 +1,58 @@
+package promptvaultprocessor
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"filippo.io/age/armor"
+)
+
+// DecryptObject recovers the content of an object as stored by a backend,
+// such as a file copied out of a bucket, without opening the vault. The
+// object is decrypted with the keys cfg describes and then decompressed.
+// The binding to its span is taken from the envelope header, so a copy of
+// an object is not detected unless ref, the object's reference, is given;
+// then a content-addressed object must also hash to it. Objects that were
+// never encrypted are only decompressed.
+func DecryptObject(ctx context.Context, cfg CryptoConfig, ref string, stored []byte) ([]byte, error) {
+	if hash := hashFromRef(ref); isContentHash(hash) && contentHash(stored) != hash && !isRebound(stored) {
+		return nil, fmt.Errorf("%w: %s", ErrChecksumMismatch, ref)
+	}
+	env, err := unmarshalEnvelope(stored)
+	if err != nil && !isAgeFile(stored) {
+		return decompress(stored)
+	}
+
+	enc, err := newEncryptor(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	if enc == nil {
+		return nil, errors.New("object is encrypted, but crypto.provider is not set")
+	}
+	if c, ok := enc.(io.Closer); ok {
+		defer c.Close()
+	}
+	if s, ok := enc.(vaultStarter); ok {
+		if err := s.Start(ctx); err != nil {
+			return nil, err
+		}
+	}
+	aad := env.AAD
+	if ref != "" && isRebound(stored) {
+		aad = reboundAAD(bindingAAD(parseBindingAAD(env.AAD)), hashFromRef(ref))
+	}
+	content, err := enc.Decrypt(ctx, stored, aad)
+	if err != nil {
+		return nil, fmt.Errorf("decrypt: %w", err)
+	}
+	return decompress(content)
+}
+
+// isAgeFile reports whether data is an age file, armored or binary.
+func isAgeFile(data []byte) bool {
+	return bytes.HasPrefix(data, []byte("age-encryption.org/")) || bytes.HasPrefix(data, []byte(armor.Header))
+}
@@ -0,0 +1,64 @@
+package promptvaultprocessor
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"io"
+	"path/filepath"
+	"testing"
+
+	"go.opentelemetry.io/collector/config/configopaque"
+	"go.uber.org/zap"
+)
+
+func TestDecryptObject(t *testing.T) {
+	ctx := context.Background()
+	cfg := createDefaultConfig()
+	cfg.Storage.Filesystem.BasePath = filepath.Join(t.TempDir(), "objects")
+	cfg.Storage.Compression = "zstd"
+	cfg.Crypto.Provider = "local"
+	cfg.Crypto.Local.Keys = []KeyConfig{
+		{ID: "k1", Key: configopaque.String(base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{7}, 32)))},
+	}
+	vault, err := OpenVault(ctx, zap.NewNop(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref, err := vault.Store(ctx, ObjectMeta{TraceID: "t1", SpanID: "s1", Key: "gen_ai.prompt"}, []byte("hello"))
+	vault.(io.Closer).Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored, err := OpenStoredVault(ctx, zap.NewNop(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealed, err := stored.Retrieve(ctx, ref)
+	stored.(io.Closer).Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, r := range []string{ref, ""} {
+		if got, err := DecryptObject(ctx, cfg.Crypto, r, sealed); err != nil || string(got) != "hello" {
+			t.Errorf("DecryptObject(ref %q) = %q, %v", r, got, err)
+		}
+	}
+	other := "vault://" + contentHash([]byte("other"))
+	if _, err := DecryptObject(ctx, cfg.Crypto, other, sealed); !errors.Is(err, ErrChecksumMismatch) {
+		t.Errorf("wrong ref: got %v, want ErrChecksumMismatch", err)
+	}
+	wrong := cfg.Crypto
+	wrong.Local.Keys = []KeyConfig{{ID: "k1", Key: configopaque.String(base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{8}, 32)))}}
+	if _, err := DecryptObject(ctx, wrong, ref, sealed); err == nil {
+		t.Error("decrypted with the wrong key")
+	}
+	if _, err := DecryptObject(ctx, CryptoConfig{}, ref, sealed); err == nil {
+		t.Error("decrypted without keys")
+	}
+	if got, err := DecryptObject(ctx, CryptoConfig{}, "", []byte("plain")); err != nil || string(got) != "plain" {
+		t.Errorf("unencrypted object = %q, %v", got, err)
+	}
+}
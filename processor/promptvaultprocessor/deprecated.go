@@ -0,0 +1,60 @@
+package promptvaultprocessor
+
+import (
+	"fmt"
+
+	"go.uber.org/zap"
+)
+
+// deprecatedSetting is a setting that was renamed or replaced. Its field
+// stays in the config struct, marked Deprecated, so configs written for
+// older releases still decode.
+type deprecatedSetting struct {
+	// name is the old setting, e.g. "crypto.local.env_prefix".
+	name string
+	// replacement says what to use instead.
+	replacement string
+	// set reports whether cfg uses the old setting.
+	set func(cfg *Config) bool
+	// migrate moves the old setting's value to its replacement and clears
+	// it. It fails if the replacement is also set. nil when the old
+	// setting cannot be mapped and is still honored as is.
+	migrate func(cfg *Config) error
+}
+
+// deprecatedSettings lists the settings MigrateConfig handles. When a
+// setting is renamed, keep the old field and add an entry here.
+var deprecatedSettings = []deprecatedSetting{
+	{
+		name:        "crypto.local.env_prefix",
+		replacement: "crypto.local.keys with ${env:...} or another config provider",
+		set:         func(cfg *Config) bool { return cfg.Crypto.Local.EnvPrefix != "" },
+	},
+}
+
+// MigrateConfig moves settings given under deprecated names to their
+// replacements, logging a warning for each, so existing deployments keep
+// working across renames. OpenVault, OpenStoredVault, and the processor
+// factory call it; a second call does nothing for migrated settings.
+func MigrateConfig(logger *zap.Logger, cfg *Config) error {
+	return migrateConfig(logger, cfg, deprecatedSettings)
+}
+
+func migrateConfig(logger *zap.Logger, cfg *Config, settings []deprecatedSetting) error {
+	for _, s := range settings {
+		if !s.set(cfg) {
+			continue
+		}
+		if s.migrate == nil {
+			logger.Warn(s.name+" is deprecated; use "+s.replacement,
+				zap.String("setting", s.name))
+			continue
+		}
+		if err := s.migrate(cfg); err != nil {
+			return fmt.Errorf("%s is deprecated: %w", s.name, err)
+		}
+		logger.Warn(s.name+" is deprecated and was mapped to "+s.replacement+"; update the config",
+			zap.String("setting", s.name))
+	}
+	return nil
+}
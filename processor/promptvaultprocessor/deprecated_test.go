@@ -0,0 +1,61 @@
+package promptvaultprocessor
+
+import (
+	"errors"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestMigrateConfig(t *testing.T) {
+	// Layout stands in for the field of a renamed setting.
+	renamed := deprecatedSetting{
+		name:        "storage.filesystem.layout",
+		replacement: "storage.filesystem.base_path",
+		set:         func(cfg *Config) bool { return cfg.Storage.Filesystem.Layout != "" },
+		migrate: func(cfg *Config) error {
+			if cfg.Storage.Filesystem.BasePath != "" {
+				return errors.New("storage.filesystem.base_path is also set")
+			}
+			cfg.Storage.Filesystem.BasePath, cfg.Storage.Filesystem.Layout = cfg.Storage.Filesystem.Layout, ""
+			return nil
+		},
+	}
+	core, logs := observer.New(zapcore.WarnLevel)
+	logger := zap.New(core)
+
+	cfg := &Config{}
+	cfg.Storage.Filesystem.Layout = "/srv/vault"
+	if err := migrateConfig(logger, cfg, []deprecatedSetting{renamed}); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Storage.Filesystem.BasePath != "/srv/vault" || cfg.Storage.Filesystem.Layout != "" {
+		t.Errorf("not migrated: %+v", cfg.Storage.Filesystem)
+	}
+	if logs.Len() != 1 {
+		t.Fatalf("%d warnings, want 1", logs.Len())
+	}
+	// Migrated settings are cleared, so opening the vault again is quiet.
+	if err := migrateConfig(logger, cfg, []deprecatedSetting{renamed}); err != nil || logs.Len() != 1 {
+		t.Errorf("second migration: err %v, %d warnings", err, logs.Len())
+	}
+
+	cfg.Storage.Filesystem.Layout = "/srv/other"
+	if err := migrateConfig(logger, cfg, []deprecatedSetting{renamed}); err == nil {
+		t.Error("old and new settings both set, want error")
+	}
+
+	cfg = &Config{}
+	cfg.Crypto.Local.EnvPrefix = "VAULT_KEY_"
+	if err := MigrateConfig(logger, cfg); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Crypto.Local.EnvPrefix != "VAULT_KEY_" {
+		t.Error("env_prefix has no replacement to map to and must be kept")
+	}
+	if got := logs.FilterField(zap.String("setting", "crypto.local.env_prefix")).Len(); got != 1 {
+		t.Errorf("%d env_prefix warnings, want 1", got)
+	}
+}
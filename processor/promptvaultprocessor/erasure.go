@@ -0,0 +1,122 @@
+package promptvaultprocessor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// ErasureRequest selects the objects to erase: those stored for a trace, or
+// for an end user across all traces. Exactly one of TraceID and EndUser
+// must be set.
+type ErasureRequest struct {
+	TraceID string `json:"trace_id,omitempty"`
+	EndUser string `json:"enduser_id,omitempty"`
+	// Ticket optionally names the data subject request being served, so the
+	// receipt can be filed against it.
+	Ticket string `json:"ticket,omitempty"`
+}
+
+func (r ErasureRequest) validate() error {
+	if (r.TraceID == "") == (r.EndUser == "") {
+		return fmt.Errorf("erasure request needs exactly one of trace_id and enduser_id")
+	}
+	return nil
+}
+
+// ErasedObject identifies one object deleted by an erasure.
+type ErasedObject struct {
+	Ref     string `json:"ref"`
+	Key     string `json:"key,omitempty"`
+	TraceID string `json:"trace_id,omitempty"`
+	SpanID  string `json:"span_id,omitempty"`
+	// Size is the object's size as stored.
+	Size int64 `json:"size,omitempty"`
+}
+
+// ErasureReceipt records what an erasure deleted. Sig signs the receipt with
+// Sig empty, so the receipt can be shown to the requester and later proven
+// unaltered.
+type ErasureReceipt struct {
+	Request   ErasureRequest `json:"request"`
+	Principal string         `json:"principal,omitempty"`
+	Time      time.Time      `json:"time"`
+	Deleted   []ErasedObject `json:"deleted"`
+	// Failed lists refs that matched but could not be deleted; the erasure
+	// is incomplete while any remain.
+	Failed []string `json:"failed,omitempty"`
+	// Skipped counts matching objects the caller was not allowed to delete.
+	Skipped int    `json:"skipped,omitempty"`
+	Sig     string `json:"sig,omitempty"`
+}
+
+// Erase deletes every object matching req that allow permits, attributing
+// the receipt to the principal in ctx. The vault must support deletion and
+// either an index or listing, in which case every object is scanned. Only
+// objects whose backend or index records their trace or end user are
+// found. Deduplicated content is matched through any of its owners, and
+// deleting it removes it for all of them. A nil allow permits everything.
+func Erase(ctx context.Context, vault VaultStorage, req ErasureRequest, allow func(ObjectMeta) bool) (ErasureReceipt, error) {
+	receipt := ErasureReceipt{Request: req, Principal: principalFrom(ctx), Deleted: []ErasedObject{}}
+	if err := req.validate(); err != nil {
+		return receipt, err
+	}
+	deleter, ok := vault.(VaultDeleter)
+	if !ok {
+		return receipt, fmt.Errorf("erasure: storage backend cannot delete objects")
+	}
+
+	var matched []ObjectInfo
+	err := SearchObjects(ctx, vault, ObjectFilter{TraceID: req.TraceID, EndUser: req.EndUser}, func(info ObjectInfo) error {
+		matched = append(matched, info)
+		return nil
+	})
+	if err != nil {
+		return receipt, err
+	}
+	for _, info := range matched {
+		if allow != nil && !allow(info.Meta) {
+			receipt.Skipped++
+			continue
+		}
+		if err := deleter.Delete(ctx, info.Ref); err != nil {
+			if ctx.Err() != nil {
+				return receipt, ctx.Err()
+			}
+			receipt.Failed = append(receipt.Failed, info.Ref)
+			continue
+		}
+		receipt.Deleted = append(receipt.Deleted, ErasedObject{
+			Ref:     info.Ref,
+			Key:     info.Meta.Key,
+			TraceID: info.Meta.TraceID,
+			SpanID:  info.Meta.SpanID,
+			Size:    info.Size,
+		})
+	}
+	receipt.Time = time.Now().UTC()
+	return receipt, nil
+}
+
+// Sign sets the receipt's signature.
+func (r *ErasureReceipt) Sign(key ManifestKey) error {
+	r.Sig = ""
+	body, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	r.Sig, err = key.Sign(body)
+	return err
+}
+
+// VerifyErasureReceipt checks a receipt's signature.
+func VerifyErasureReceipt(r ErasureReceipt, key ManifestKey) error {
+	sig := r.Sig
+	r.Sig = ""
+	body, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	return key.Verify(body, sig)
+}
@@ -0,0 +1,162 @@
+package promptvaultprocessor
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.uber.org/zap"
+)
+
+func TestProcessorRecordsEndUser(t *testing.T) {
+	vault := NewMemoryVault(0, 0)
+	proc := newVaultProcessor(zap.NewNop(), createDefaultConfig(), vault, new(consumertest.TracesSink))
+
+	td := ptrace.NewTraces()
+	rs := td.ResourceSpans().AppendEmpty()
+	rs.Resource().Attributes().PutStr("enduser.id", "resource-user")
+	spans := rs.ScopeSpans().AppendEmpty().Spans()
+	inherited := spans.AppendEmpty()
+	inherited.Attributes().PutStr("gen_ai.prompt", "a prompt from the resource's user, long enough to vault")
+	own := spans.AppendEmpty()
+	own.Attributes().PutStr("enduser.id", "span-user")
+	own.Attributes().PutStr("gen_ai.prompt", "a prompt from the span's own user, long enough to vault")
+	if err := proc.ConsumeTraces(context.Background(), td); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, user := range []string{"resource-user", "span-user"} {
+		receipt, err := Erase(context.Background(), vault, ErasureRequest{EndUser: user}, nil)
+		if err != nil || len(receipt.Deleted) != 1 {
+			t.Errorf("erasing %s: %+v, %v; want one object", user, receipt, err)
+		}
+	}
+}
+
+func TestEraseByEndUser(t *testing.T) {
+	ctx := WithPrincipal(context.Background(), "privacy-officer")
+	vault := NewMemoryVault(0, 0)
+	refs := make(map[string]string)
+	for name, meta := range map[string]ObjectMeta{
+		"u1 prompt":      {Key: "gen_ai.prompt", TraceID: "t1", EndUser: "u1"},
+		"u1 completion":  {Key: "gen_ai.completion", TraceID: "t2", EndUser: "u1"},
+		"u1 audit-held":  {Key: "legal.hold", TraceID: "t2", EndUser: "u1"},
+		"u2 prompt":      {Key: "gen_ai.prompt", TraceID: "t1", EndUser: "u2"},
+		"anonymous body": {Key: "http.request.body", TraceID: "t3"},
+	} {
+		refs[name], _ = vault.Store(ctx, meta, []byte(name))
+	}
+
+	receipt, err := Erase(ctx, vault, ErasureRequest{EndUser: "u1", Ticket: "DSAR-7"}, func(meta ObjectMeta) bool {
+		return meta.Key != "legal.hold"
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(receipt.Deleted) != 2 || receipt.Skipped != 1 || len(receipt.Failed) != 0 {
+		t.Errorf("receipt = %+v, want 2 deleted and 1 skipped", receipt)
+	}
+	if receipt.Principal != "privacy-officer" || receipt.Request.Ticket != "DSAR-7" {
+		t.Errorf("principal = %q, ticket = %q", receipt.Principal, receipt.Request.Ticket)
+	}
+	for _, obj := range receipt.Deleted {
+		if obj.Size == 0 {
+			t.Errorf("deleted %s has no size", obj.Ref)
+		}
+	}
+	for name, ref := range refs {
+		_, err := vault.Retrieve(ctx, ref)
+		wantGone := name == "u1 prompt" || name == "u1 completion"
+		if gone := err != nil; gone != wantGone {
+			t.Errorf("%s: deleted = %v, want %v", name, gone, wantGone)
+		}
+	}
+
+	receipt, err = Erase(ctx, vault, ErasureRequest{TraceID: "t1"}, nil)
+	if err != nil || len(receipt.Deleted) != 1 || receipt.Deleted[0].Ref != refs["u2 prompt"] {
+		t.Errorf("trace erasure = %+v, %v; want the remaining t1 object", receipt, err)
+	}
+
+	for _, req := range []ErasureRequest{{}, {TraceID: "t1", EndUser: "u1"}} {
+		if _, err := Erase(ctx, vault, req, nil); err == nil {
+			t.Errorf("Erase(%+v): expected an error", req)
+		}
+	}
+}
+
+func TestErasureReceiptSignature(t *testing.T) {
+	key, err := NewHMACManifestKey("0123456789abcdef0123456789abcdef")
+	if err != nil {
+		t.Fatal(err)
+	}
+	vault := NewMemoryVault(0, 0)
+	vault.Store(context.Background(), ObjectMeta{Key: "gen_ai.prompt", EndUser: "u1"}, []byte("prompt"))
+	receipt, err := Erase(context.Background(), vault, ErasureRequest{EndUser: "u1"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := receipt.Sign(key); err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyErasureReceipt(receipt, key); err != nil {
+		t.Fatalf("valid receipt rejected: %v", err)
+	}
+	receipt.Deleted = nil
+	if err := VerifyErasureReceipt(receipt, key); err == nil {
+		t.Error("altered receipt verified")
+	}
+}
+
+// TestEraseSharedContent stores identical content for two users in two
+// traces, which backends keep as one object, and erases it through either.
+func TestEraseSharedContent(t *testing.T) {
+	ctx := context.Background()
+	backends := map[string]func(t *testing.T) VaultStorage{
+		"memory": func(*testing.T) VaultStorage { return NewMemoryVault(0, 0) },
+		"filesystem": func(t *testing.T) VaultStorage {
+			cfg := createDefaultConfig()
+			cfg.Storage.Filesystem.BasePath = t.TempDir()
+			cfg.Storage.Filesystem.WriteMetadata = true
+			v, err := OpenVault(ctx, zap.NewNop(), cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return v
+		},
+		"index": func(t *testing.T) VaultStorage {
+			cfg := createDefaultConfig()
+			cfg.Storage.Filesystem.BasePath = t.TempDir()
+			cfg.Storage.Index.Path = filepath.Join(t.TempDir(), "index.db")
+			v, err := OpenVault(ctx, zap.NewNop(), cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return v
+		},
+	}
+	for name, open := range backends {
+		for _, req := range []ErasureRequest{{EndUser: "bob"}, {TraceID: "t2"}} {
+			t.Run(name, func(t *testing.T) {
+				vault := open(t)
+				alice, _ := vault.Store(ctx, ObjectMeta{Key: "gen_ai.prompt", TraceID: "t1", SpanID: "s1", EndUser: "alice"}, []byte("hello"))
+				bob, err := vault.Store(ctx, ObjectMeta{Key: "gen_ai.prompt", TraceID: "t2", SpanID: "s2", EndUser: "bob"}, []byte("hello"))
+				if err != nil || bob != alice {
+					t.Fatalf("refs %q and %q, %v; want one shared object", alice, bob, err)
+				}
+
+				receipt, err := Erase(ctx, vault, req, nil)
+				if err != nil || len(receipt.Deleted) != 1 {
+					t.Fatalf("Erase(%+v) = %+v, %v; want the shared object", req, receipt, err)
+				}
+				if got := receipt.Deleted[0]; got.TraceID != "t2" || got.SpanID != "s2" {
+					t.Errorf("receipt describes %+v, want bob's span", got)
+				}
+				if _, err := vault.Retrieve(ctx, bob); err == nil {
+					t.Error("erased content still retrievable")
+				}
+			})
+		}
+	}
+}
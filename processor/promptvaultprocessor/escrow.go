@@ -0,0 +1,246 @@
+package promptvaultprocessor
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"go.opentelemetry.io/collector/config/configopaque"
+)
+
+// escrowVersion is the format version of escrow metadata and shares.
+const escrowVersion = 1
+
+// ErrEscrowShares is returned, wrapped, when escrow shares cannot recover
+// the keys: too few, from different escrows, or tampered with.
+var ErrEscrowShares = errors.New("escrow shares do not recover the keys")
+
+// EscrowMetadata describes a key escrow without revealing the keys. It is
+// kept alongside the vault so auditors can see who holds a share.
+type EscrowMetadata struct {
+	Version int    `json:"version"`
+	ID      string `json:"id"`
+	// Threshold shares out of len(Holders) recover the keys.
+	Threshold int      `json:"threshold"`
+	Holders   []string `json:"holders"`
+	// KeyIDs are the escrowed keys, in keyring order.
+	KeyIDs []string `json:"key_ids"`
+	// Checksum is the hex SHA-256 of the escrowed keyring, checked on
+	// recovery.
+	Checksum  string    `json:"checksum"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// EscrowShare is one officer's share of an escrow.
+type EscrowShare struct {
+	Version   int    `json:"version"`
+	EscrowID  string `json:"escrow_id"`
+	Holder    string `json:"holder"`
+	Threshold int    `json:"threshold"`
+	// X is the share's evaluation point, 1 to 255.
+	X byte `json:"x"`
+	// Share is the base64 share of the keyring.
+	Share string `json:"share"`
+}
+
+// EscrowKeys splits the local keys cfg loads into one share per holder,
+// any threshold of which recover them with RecoverEscrowedKeys (Shamir's
+// secret sharing over GF(256)). A threshold of 2 with 2 holders is dual
+// control. Only the local provider's keys can be escrowed; KMS and HSM
+// keys never leave their service.
+func EscrowKeys(cfg LocalKeysConfig, threshold int, holders []string) (EscrowMetadata, []EscrowShare, error) {
+	var meta EscrowMetadata
+	if threshold < 2 || threshold > len(holders) {
+		return meta, nil, fmt.Errorf("escrow: threshold must be between 2 and the number of holders (%d)", len(holders))
+	}
+	if len(holders) > 255 {
+		return meta, nil, fmt.Errorf("escrow: at most 255 holders")
+	}
+	for i, h := range holders {
+		if h == "" || slices.Contains(holders[:i], h) {
+			return meta, nil, fmt.Errorf("escrow: holder names must be unique and non-empty")
+		}
+	}
+	keys, _, err := loadKeys(cfg)
+	if err != nil {
+		return meta, nil, err
+	}
+	// Validates the keys and puts the active one first.
+	keyring, err := newKeyring(keys, cfg.ActiveKey)
+	if err != nil {
+		return meta, nil, err
+	}
+
+	// The keyring file format.
+	var secret bytes.Buffer
+	for _, k := range keyring.keys {
+		fmt.Fprintf(&secret, "%s %s\n", k.id, base64.StdEncoding.EncodeToString(k.key))
+		meta.KeyIDs = append(meta.KeyIDs, k.id)
+	}
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return meta, nil, err
+	}
+	sum := sha256.Sum256(secret.Bytes())
+	meta = EscrowMetadata{
+		Version:   escrowVersion,
+		ID:        hex.EncodeToString(id),
+		Threshold: threshold,
+		Holders:   holders,
+		KeyIDs:    meta.KeyIDs,
+		Checksum:  hex.EncodeToString(sum[:]),
+		CreatedAt: time.Now().UTC(),
+	}
+
+	ys, err := shamirSplit(secret.Bytes(), len(holders), threshold)
+	if err != nil {
+		return meta, nil, err
+	}
+	shares := make([]EscrowShare, len(holders))
+	for i, h := range holders {
+		shares[i] = EscrowShare{
+			Version:   escrowVersion,
+			EscrowID:  meta.ID,
+			Holder:    h,
+			Threshold: threshold,
+			X:         byte(i + 1),
+			Share:     base64.StdEncoding.EncodeToString(ys[i]),
+		}
+	}
+	return meta, shares, nil
+}
+
+// RecoverEscrowedKeys combines shares of the escrow meta describes back
+// into its keys, the active key first, for crypto.local.keys.
+func RecoverEscrowedKeys(meta EscrowMetadata, shares []EscrowShare) ([]KeyConfig, error) {
+	if meta.Version != escrowVersion {
+		return nil, fmt.Errorf("escrow: unsupported version %d", meta.Version)
+	}
+	var xs []byte
+	var ys [][]byte
+	for _, s := range shares {
+		if s.EscrowID != meta.ID {
+			return nil, fmt.Errorf("%w: %s's share is from escrow %s, not %s", ErrEscrowShares, s.Holder, s.EscrowID, meta.ID)
+		}
+		if slices.Contains(xs, s.X) {
+			continue
+		}
+		y, err := base64.StdEncoding.DecodeString(s.Share)
+		if err != nil || s.X == 0 {
+			return nil, fmt.Errorf("%w: %s's share is malformed", ErrEscrowShares, s.Holder)
+		}
+		xs, ys = append(xs, s.X), append(ys, y)
+	}
+	if len(xs) < meta.Threshold {
+		return nil, fmt.Errorf("%w: %d of %d shares", ErrEscrowShares, len(xs), meta.Threshold)
+	}
+	secret, err := shamirCombine(xs, ys)
+	if err != nil {
+		return nil, err
+	}
+	if sum := sha256.Sum256(secret); hex.EncodeToString(sum[:]) != meta.Checksum {
+		return nil, fmt.Errorf("%w: checksum mismatch", ErrEscrowShares)
+	}
+	entries, err := parseKeyring(secret)
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]KeyConfig, len(entries))
+	for i, e := range entries {
+		keys[i] = KeyConfig{ID: e.id, Key: configopaque.String(base64.StdEncoding.EncodeToString(e.key))}
+	}
+	return keys, nil
+}
+
+// shamirSplit splits secret into n shares, evaluated at x = 1..n, any k of
+// which recover it.
+func shamirSplit(secret []byte, n, k int) ([][]byte, error) {
+	shares := make([][]byte, n)
+	for i := range shares {
+		shares[i] = make([]byte, len(secret))
+	}
+	coeffs := make([]byte, k)
+	for b, s := range secret {
+		coeffs[0] = s
+		if _, err := rand.Read(coeffs[1:]); err != nil {
+			return nil, err
+		}
+		for i := range shares {
+			shares[i][b] = gfEval(coeffs, byte(i+1))
+		}
+	}
+	return shares, nil
+}
+
+// shamirCombine interpolates the shares ys, taken at xs, at zero.
+func shamirCombine(xs []byte, ys [][]byte) ([]byte, error) {
+	for _, y := range ys {
+		if len(y) != len(ys[0]) {
+			return nil, fmt.Errorf("%w: shares differ in length", ErrEscrowShares)
+		}
+	}
+	secret := make([]byte, len(ys[0]))
+	for b := range secret {
+		var s byte
+		for i, xi := range xs {
+			// Lagrange basis polynomial i at zero: prod xj / (xj - xi).
+			basis := byte(1)
+			for j, xj := range xs {
+				if i != j {
+					basis = gfMul(basis, gfDiv(xj, xj^xi))
+				}
+			}
+			s ^= gfMul(ys[i][b], basis)
+		}
+		secret[b] = s
+	}
+	return secret, nil
+}
+
+// gfEval evaluates the polynomial with coeffs, lowest degree first, at x.
+func gfEval(coeffs []byte, x byte) byte {
+	var y byte
+	for i := len(coeffs) - 1; i >= 0; i-- {
+		y = gfMul(y, x) ^ coeffs[i]
+	}
+	return y
+}
+
+// GF(256) with the AES polynomial, by log and exp tables over generator 3.
+var gfExp, gfLog = func() ([510]byte, [256]byte) {
+	var exp [510]byte
+	var log [256]byte
+	x := byte(1)
+	for i := 0; i < 255; i++ {
+		exp[i], exp[i+255] = x, x
+		log[x] = byte(i)
+		// x *= 3
+		hi := x & 0x80
+		x2 := x << 1
+		if hi != 0 {
+			x2 ^= 0x1b
+		}
+		x ^= x2
+	}
+	return exp, log
+}()
+
+func gfMul(a, b byte) byte {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return gfExp[int(gfLog[a])+int(gfLog[b])]
+}
+
+func gfDiv(a, b byte) byte {
+	if a == 0 {
+		return 0
+	}
+	return gfExp[int(gfLog[a])+255-int(gfLog[b])]
+}
@@ -0,0 +1,84 @@
+package promptvaultprocessor
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.opentelemetry.io/collector/config/configopaque"
+)
+
+func TestEscrowRecoversKeysFromThresholdShares(t *testing.T) {
+	ctx := context.Background()
+	cfg := LocalKeysConfig{
+		Keys:      []KeyConfig{{ID: "old", Key: configopaque.String(testKey(1))}, {ID: "current", Key: configopaque.String(testKey(2))}},
+		ActiveKey: "current",
+	}
+	keyring, err := NewKeyring(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealed, _, err := keyring.Encrypt(ctx, []byte("break glass"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	meta, shares, err := EscrowKeys(cfg, 2, []string{"alice", "bob", "carol"})
+	if err != nil {
+		t.Fatalf("escrow: %v", err)
+	}
+	if len(shares) != 3 || meta.Threshold != 2 || len(meta.KeyIDs) != 2 || meta.KeyIDs[0] != "current" {
+		t.Fatalf("unexpected escrow %+v with %d shares", meta, len(shares))
+	}
+
+	keys, err := RecoverEscrowedKeys(meta, []EscrowShare{shares[2], shares[0]})
+	if err != nil {
+		t.Fatalf("recover: %v", err)
+	}
+	recovered, err := NewKeyring(LocalKeysConfig{Keys: keys})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if recovered.ActiveKeyID() != "current" {
+		t.Errorf("active key %q, want current", recovered.ActiveKeyID())
+	}
+	plain, err := recovered.Decrypt(ctx, sealed, nil)
+	if err != nil || string(plain) != "break glass" {
+		t.Fatalf("decrypt with recovered keys: %q, %v", plain, err)
+	}
+}
+
+func TestEscrowRejectsInsufficientShares(t *testing.T) {
+	cfg := LocalKeysConfig{Keys: []KeyConfig{{ID: "k1", Key: configopaque.String(testKey(1))}}}
+	meta, shares, err := EscrowKeys(cfg, 2, []string{"alice", "bob"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The same officer twice does not make two.
+	if _, err := RecoverEscrowedKeys(meta, []EscrowShare{shares[0], shares[0]}); !errors.Is(err, ErrEscrowShares) {
+		t.Errorf("one officer's share: got %v, want ErrEscrowShares", err)
+	}
+
+	other, otherShares, err := EscrowKeys(cfg, 2, []string{"alice", "bob"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := RecoverEscrowedKeys(meta, []EscrowShare{shares[0], otherShares[1]}); !errors.Is(err, ErrEscrowShares) {
+		t.Errorf("shares of different escrows: got %v, want ErrEscrowShares", err)
+	}
+
+	tampered := shares[1]
+	tampered.Share = otherShares[1].Share
+	tampered.EscrowID = meta.ID
+	if _, err := RecoverEscrowedKeys(meta, []EscrowShare{shares[0], tampered}); !errors.Is(err, ErrEscrowShares) {
+		t.Errorf("tampered share: got %v, want ErrEscrowShares", err)
+	}
+	if _, err := RecoverEscrowedKeys(other, otherShares); err != nil {
+		t.Errorf("recover other escrow: %v", err)
+	}
+
+	if _, _, err := EscrowKeys(cfg, 1, []string{"alice", "bob"}); err == nil {
+		t.Error("expected a threshold of 1 to be rejected")
+	}
+}
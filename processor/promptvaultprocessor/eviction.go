@@ -0,0 +1,120 @@
+package promptvaultprocessor
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Start checks that base_path is writable, so a read-only mount fails
+// startup instead of every store, and launches the eviction loop when a
+// quota or TTL is configured.
+func (v *FilesystemVault) Start(_ context.Context) error {
+	probe, err := os.CreateTemp(v.basePath, ".write-probe-*")
+	if err != nil {
+		return baseDirError(v.basePath, err)
+	}
+	probe.Close()
+	os.Remove(probe.Name())
+
+	if v.maxBytes <= 0 && v.maxObjects <= 0 && v.ttl <= 0 {
+		return nil
+	}
+	interval := v.evictInterval
+	if interval <= 0 {
+		interval = time.Minute
+	}
+
+	v.done.Add(1)
+	go func() {
+		defer v.done.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-v.stop:
+				return
+			case <-ticker.C:
+				if err := v.Evict(context.Background()); err != nil {
+					v.logger.Warn("vault eviction failed", zap.Error(err))
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+// Close stops the eviction loop.
+func (v *FilesystemVault) Close() error {
+	select {
+	case <-v.stop:
+	default:
+		close(v.stop)
+	}
+	v.done.Wait()
+	return nil
+}
+
+type vaultFile struct {
+	path    string
+	size    int64
+	modTime time.Time
+}
+
+// Evict removes objects older than the TTL, then the oldest objects until the
+// vault is within max_bytes and max_objects.
+func (v *FilesystemVault) Evict(ctx context.Context) error {
+	var files []vaultFile
+	var total int64
+	err := filepath.Walk(v.basePath, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil // skip errors
+		}
+		if info.IsDir() || !strings.HasSuffix(info.Name(), ".vault") {
+			return nil
+		}
+		files = append(files, vaultFile{path: path, size: info.Size(), modTime: info.ModTime()})
+		total += info.Size()
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	sort.Slice(files, func(i, j int) bool { return files[i].modTime.Before(files[j].modTime) })
+
+	cutoff := time.Now().Add(-v.ttl)
+	var evicted int
+	var evictedBytes int64
+	for _, f := range files {
+		count := len(files) - evicted
+		expired := v.ttl > 0 && f.modTime.Before(cutoff)
+		overBytes := v.maxBytes > 0 && total > v.maxBytes
+		overCount := v.maxObjects > 0 && count > v.maxObjects
+		if !expired && !overBytes && !overCount {
+			break
+		}
+		if err := os.Remove(f.path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		os.Remove(f.path + metadataSuffix)
+		total -= f.size
+		evicted++
+		evictedBytes += f.size
+	}
+
+	if evicted > 0 {
+		v.metrics.recordEviction(ctx, evicted, evictedBytes)
+		v.logger.Info("evicted vault objects",
+			zap.Int("objects", evicted),
+			zap.Int64("bytes", evictedBytes),
+			zap.Int64("remaining_bytes", total),
+		)
+	}
+	return nil
+}
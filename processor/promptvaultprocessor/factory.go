@@ -2,10 +2,12 @@ package promptvaultprocessor
 
 import (
 	"context"
+	"fmt"
 
 	"go.opentelemetry.io/collector/component"
 	"go.opentelemetry.io/collector/consumer"
 	"go.opentelemetry.io/collector/processor"
+	"go.uber.org/zap"
 )
 
 const (
@@ -28,12 +30,122 @@ func createTracesProcessor(
 	cfg component.Config,
 	nextConsumer consumer.Traces,
 ) (processor.Traces, error) {
-	pCfg := cfg.(*Config)
+	proc, err := newTracesProcessor(ctx, set.TelemetrySettings, cfg.(*Config), nextConsumer)
+	if err != nil {
+		return nil, err
+	}
+	return proc, nil
+}
+
+// newTracesProcessor validates pCfg and builds a processor for it, handing
+// traces on to nextConsumer.
+func newTracesProcessor(
+	ctx context.Context,
+	set component.TelemetrySettings,
+	pCfg *Config,
+	nextConsumer consumer.Traces,
+) (*vaultProcessor, error) {
+	if err := MigrateConfig(set.Logger, pCfg); err != nil {
+		return nil, err
+	}
+	if err := CheckSecretsRedacted(pCfg); err != nil {
+		return nil, err
+	}
+	if err := validateLinkTemplate(pCfg.Vault.LinkTemplate); err != nil {
+		return nil, err
+	}
+	if err := validateRefFormat(pCfg.Vault); err != nil {
+		return nil, err
+	}
+	if err := validateCompatibility(pCfg.Vault); err != nil {
+		return nil, err
+	}
+	if err := validateOnQuotaExceeded(pCfg.Vault.OnQuotaExceeded); err != nil {
+		return nil, err
+	}
+	if _, err := vaultKeys(pCfg.Vault); err != nil {
+		return nil, err
+	}
+
+	metrics, err := newVaultMetrics(set.MeterProvider)
+	if err != nil {
+		return nil, err
+	}
 
-	vault, err := NewFilesystemVault(pCfg.Storage.Filesystem.BasePath)
+	signer, err := NewRefSigner(pCfg.Vault.SigningKey)
 	if err != nil {
 		return nil, err
 	}
 
-	return newVaultProcessor(set.Logger, pCfg, vault, nextConsumer), nil
+	redactor, err := newPIIScanner(pCfg.Redaction)
+	if err != nil {
+		return nil, err
+	}
+	keptRedactor, err := newPIIScanner(pCfg.Vault.KeptCopyTransform.Redaction)
+	if err != nil {
+		return nil, fmt.Errorf("vault.kept_copy_transform: %w", err)
+	}
+
+	var tok *tokenizer
+	if pCfg.Vault.Mode == "tokenize" {
+		if tok, err = newTokenizer(pCfg.Vault.Tokenization); err != nil {
+			return nil, err
+		}
+	}
+
+	policy, err := newPolicyClient(pCfg.Vault.Policy)
+	if err != nil {
+		return nil, err
+	}
+	transforms, err := newTransformChain(pCfg.Transformers)
+	if err != nil {
+		return nil, err
+	}
+
+	// Store events, notifications, and the vault hold connections and
+	// background work, so they are opened last and closed again if a later
+	// one fails.
+	events, err := newStoreEvents(ctx, pCfg)
+	if err != nil {
+		return nil, err
+	}
+	notifier, err := newStoreNotifier(ctx, set.Logger, pCfg.Notify)
+	if err != nil {
+		if events != nil {
+			events.Close(ctx)
+		}
+		return nil, err
+	}
+	// A vault shared through an extension is resolved in Start.
+	var vault VaultStorage
+	if ignored := IgnoredWithExtension(pCfg.Storage, pCfg.Crypto); pCfg.Storage.Extension != "" && len(ignored) > 0 {
+		set.Logger.Warn("Settings are ignored because storage.extension is set",
+			zap.String("extension", pCfg.Storage.Extension), zap.Strings("ignored", ignored))
+	}
+	if pCfg.Storage.Extension == "" {
+		if vault, err = openVault(ctx, set.Logger, metrics, pCfg); err != nil {
+			if events != nil {
+				events.Close(ctx)
+			}
+			if notifier != nil {
+				notifier.Close(ctx)
+			}
+			return nil, err
+		}
+	}
+
+	proc := newVaultProcessor(set.Logger, pCfg, vault, nextConsumer)
+	proc.signer = signer
+	proc.redactor = redactor
+	proc.keptRedactor = keptRedactor
+	proc.tokenizer = tok
+	proc.metrics = metrics
+	proc.storeEvents = events
+	proc.notifier = notifier
+	proc.policy = policy
+	proc.transforms = transforms
+	if set.ReportStatus != nil {
+		proc.reportStatus = set.ReportStatus
+	}
+	return proc, nil
 }
\ No newline at end of file
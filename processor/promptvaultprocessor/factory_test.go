@@ -0,0 +1,54 @@
+package promptvaultprocessor
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+
+	"github.com/airblackbox/otel-prompt-vault/internal/metadatatest"
+)
+
+func TestMetadataMatchesFactory(t *testing.T) {
+	f := NewFactory()
+	metadatatest.Check(t, "processor", f.Type(), map[string]component.StabilityLevel{
+		"traces":  f.TracesProcessorStability(),
+		"metrics": f.MetricsProcessorStability(),
+		"logs":    f.LogsProcessorStability(),
+	})
+}
+
+func TestIgnoredWithExtension(t *testing.T) {
+	cfg := createDefaultConfig()
+	cfg.Storage.Extension = "promptvaultstorage/shared"
+	if got := IgnoredWithExtension(cfg.Storage, cfg.Crypto); len(got) != 0 {
+		t.Errorf("defaults reported as ignored: %v", got)
+	}
+	cfg.Storage.Backend = "postgres"
+	cfg.Storage.Filesystem.BasePath = "/srv/vault"
+	cfg.Crypto.Provider = "local"
+	got := strings.Join(IgnoredWithExtension(cfg.Storage, cfg.Crypto), ",")
+	if want := "storage.backend,storage.filesystem,crypto"; got != want {
+		t.Errorf("ignored = %s, want %s", got, want)
+	}
+}
+
+func TestFailedCreateLeavesNothingOpen(t *testing.T) {
+	dir := t.TempDir()
+	cfg := createDefaultConfig()
+	cfg.Storage.Filesystem.BasePath = filepath.Join(dir, "vault")
+	cfg.Storage.Index.Path = filepath.Join(dir, "index.db")
+	// Kafka notifications without brokers are rejected.
+	cfg.Notify.Kafka.Topic = "vault"
+
+	if _, err := newTracesProcessor(context.Background(), componenttest.NewNopTelemetrySettings(), cfg, new(consumertest.TracesSink)); err == nil {
+		t.Fatal("expected kafka notifications without brokers to fail")
+	}
+	if open := openFiles(t, dir); len(open) > 0 {
+		t.Errorf("failed create left %v open", open)
+	}
+}
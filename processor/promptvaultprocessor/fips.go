@@ -0,0 +1,23 @@
+package promptvaultprocessor
+
+import "fmt"
+
+// validateFIPS rejects crypto settings that use primitives outside FIPS 140:
+// age (X25519, ChaCha20-Poly1305) and convergent mode (deterministic GCM
+// nonces). It also requires that the binary actually runs on a FIPS module.
+func validateFIPS(cfg CryptoConfig) error {
+	if !cfg.FIPS {
+		return nil
+	}
+	if !fipsModuleEnabled() {
+		return fmt.Errorf("crypto.fips requires a FIPS 140 crypto module: build with GOEXPERIMENT=boringcrypto " +
+			"or run a Go 1.24+ binary with GODEBUG=fips140=on")
+	}
+	switch {
+	case cfg.Provider == "age":
+		return fmt.Errorf("crypto.provider age is not allowed with crypto.fips: age uses X25519 and ChaCha20-Poly1305")
+	case cfg.Local.Convergent:
+		return fmt.Errorf("crypto.local.convergent is not allowed with crypto.fips: it uses deterministic GCM nonces")
+	}
+	return nil
+}
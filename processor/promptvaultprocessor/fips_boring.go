@@ -0,0 +1,7 @@
+//go:build goexperiment.boringcrypto
+
+package promptvaultprocessor
+
+import "crypto/boring"
+
+func fipsModuleEnabled() bool { return boring.Enabled() }
@@ -0,0 +1,7 @@
+//go:build go1.24 && !goexperiment.boringcrypto
+
+package promptvaultprocessor
+
+import "crypto/fips140"
+
+func fipsModuleEnabled() bool { return fips140.Enabled() }
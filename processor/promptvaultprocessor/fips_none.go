@@ -0,0 +1,5 @@
+//go:build !go1.24 && !goexperiment.boringcrypto
+
+package promptvaultprocessor
+
+func fipsModuleEnabled() bool { return false }
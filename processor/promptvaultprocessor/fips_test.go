@@ -0,0 +1,22 @@
+package promptvaultprocessor
+
+import "testing"
+
+func TestFIPSModeRejectsNonCompliantConfig(t *testing.T) {
+	if !fipsModuleEnabled() {
+		if err := validateFIPS(CryptoConfig{FIPS: true, Provider: "local"}); err == nil {
+			t.Error("expected FIPS mode to fail without a FIPS crypto module")
+		}
+		t.Skip("no FIPS 140 module in this build; run with GODEBUG=fips140=on to cover the rest")
+	}
+
+	if err := validateFIPS(CryptoConfig{FIPS: true, Provider: "local"}); err != nil {
+		t.Errorf("expected AES-GCM keyring to be allowed: %v", err)
+	}
+	if err := validateFIPS(CryptoConfig{FIPS: true, Provider: "age"}); err == nil {
+		t.Error("expected age to be rejected in FIPS mode")
+	}
+	if err := validateFIPS(CryptoConfig{FIPS: true, Provider: "local", Local: LocalKeysConfig{Convergent: true}}); err == nil {
+		t.Error("expected convergent mode to be rejected in FIPS mode")
+	}
+}
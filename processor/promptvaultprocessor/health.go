@@ -0,0 +1,54 @@
+package promptvaultprocessor
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.opentelemetry.io/collector/component"
+)
+
+const healthCheckKey = "promptvault.healthcheck"
+
+// probeVault performs a write/read/delete round trip against the backend.
+// Backends without delete support keep the small probe object.
+func probeVault(ctx context.Context, vault VaultStorage) error {
+	content := []byte("promptvault health check " + time.Now().UTC().Format(time.RFC3339Nano))
+
+	ref, err := vault.Store(ctx, ObjectMeta{Key: healthCheckKey}, content)
+	if err != nil {
+		return fmt.Errorf("vault health check write: %w", err)
+	}
+
+	got, err := vault.Retrieve(ctx, ref)
+	if err != nil {
+		return fmt.Errorf("vault health check read: %w", err)
+	}
+	if !bytes.Equal(got, content) {
+		return fmt.Errorf("vault health check read: content mismatch for %s", ref)
+	}
+
+	if d, ok := vault.(VaultDeleter); ok {
+		if err := d.Delete(ctx, ref); err != nil && !errors.Is(err, errors.ErrUnsupported) {
+			return fmt.Errorf("vault health check delete: %w", err)
+		}
+	}
+	return nil
+}
+
+// reportStoreResult reports a status change when the backend transitions
+// between healthy and failing, so the collector's health check reflects
+// vault availability without an event per span.
+func (p *vaultProcessor) reportStoreResult(err error) {
+	if err != nil {
+		if p.healthy.CompareAndSwap(true, false) {
+			p.reportStatus(component.NewRecoverableErrorEvent(err))
+		}
+		return
+	}
+	if p.healthy.CompareAndSwap(false, true) {
+		p.reportStatus(component.NewStatusEvent(component.StatusOK))
+	}
+}
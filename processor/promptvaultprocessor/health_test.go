@@ -0,0 +1,100 @@
+package promptvaultprocessor
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.uber.org/zap"
+)
+
+type failingVault struct{ VaultStorage }
+
+func (failingVault) Store(context.Context, ObjectMeta, []byte) (string, error) {
+	return "", errors.New("backend unavailable")
+}
+
+func TestCheckOnStartProbesBackend(t *testing.T) {
+	cfg := createDefaultConfig()
+	cfg.Storage.CheckOnStart = true
+
+	vault := NewMemoryVault(0, 0)
+	proc := newVaultProcessor(zap.NewNop(), cfg, vault, new(consumertest.TracesSink))
+	if err := proc.Start(context.Background(), componenttest.NewNopHost()); err != nil {
+		t.Fatalf("expected probe to pass: %v", err)
+	}
+	if vault.Len() != 0 {
+		t.Errorf("expected probe object to be deleted, have %d objects", vault.Len())
+	}
+
+	var events []*component.StatusEvent
+	proc = newVaultProcessor(zap.NewNop(), cfg, failingVault{vault}, new(consumertest.TracesSink))
+	proc.reportStatus = func(ev *component.StatusEvent) { events = append(events, ev) }
+	if err := proc.Start(context.Background(), componenttest.NewNopHost()); err == nil {
+		t.Error("expected start to fail when the probe fails")
+	}
+	if len(events) != 1 || events[0].Status() != component.StatusPermanentError {
+		t.Errorf("expected a permanent error status event, got %v", events)
+	}
+}
+
+func TestStoreFailuresReportStatus(t *testing.T) {
+	var events []*component.StatusEvent
+	cfg := createDefaultConfig()
+	proc := newVaultProcessor(zap.NewNop(), cfg, failingVault{}, new(consumertest.TracesSink))
+	proc.reportStatus = func(ev *component.StatusEvent) { events = append(events, ev) }
+
+	for i := 0; i < 2; i++ {
+		td := ptrace.NewTraces()
+		span := td.ResourceSpans().AppendEmpty().ScopeSpans().AppendEmpty().Spans().AppendEmpty()
+		span.Attributes().PutStr("gen_ai.prompt", "hello")
+		proc.ConsumeTraces(context.Background(), td)
+	}
+
+	if len(events) != 1 || events[0].Status() != component.StatusRecoverableError {
+		t.Errorf("expected a single recoverable error event, got %v", events)
+	}
+}
+
+func TestFilesystemVaultUnwritableBasePath(t *testing.T) {
+	for _, tc := range []struct {
+		err  error
+		want string
+	}{
+		{&fs.PathError{Op: "mkdir", Path: "/data", Err: syscall.EROFS}, "read-only filesystem"},
+		{&fs.PathError{Op: "open", Path: "/data/vault/x", Err: syscall.EACCES}, "not writable by uid"},
+	} {
+		err := baseDirError("/data/vault", tc.err)
+		if !errors.Is(err, tc.err) || !strings.Contains(err.Error(), tc.want) || !strings.Contains(err.Error(), "${env:VAULT_DIR}") {
+			t.Errorf("baseDirError(%v) = %v, want %q with a fix", tc.err, err, tc.want)
+		}
+	}
+
+	if os.Geteuid() == 0 {
+		t.Skip("root can write to read-only directories")
+	}
+	dir := filepath.Join(t.TempDir(), "vault")
+	v, err := NewFilesystemVault(FilesystemConfig{BasePath: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(dir, 0o555); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chmod(dir, 0o755)
+	if err := v.Start(context.Background()); err == nil || !strings.Contains(err.Error(), "not writable") {
+		t.Errorf("Start = %v, want a not writable error", err)
+	}
+	if _, err := NewFilesystemVault(FilesystemConfig{BasePath: filepath.Join(dir, "sub")}); err == nil || !strings.Contains(err.Error(), "not writable") {
+		t.Errorf("NewFilesystemVault = %v, want a not writable error", err)
+	}
+}
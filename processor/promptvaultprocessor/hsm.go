@@ -0,0 +1,75 @@
+package promptvaultprocessor
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+)
+
+// keyWrapper wraps and unwraps data keys under a master key it never exposes.
+type keyWrapper interface {
+	WrapKey(dataKey []byte) ([]byte, error)
+	UnwrapKey(wrapped []byte) ([]byte, error)
+}
+
+// HSMEncryptor implements envelope encryption with a master key held in an
+// HSM: each object is sealed under a fresh data key, and only the
+// token-wrapped data key is stored. The master key never enters process memory.
+type HSMEncryptor struct {
+	token keyWrapper
+	keyID string
+}
+
+// NewHSMEncryptor opens the PKCS#11 token described by cfg.
+func NewHSMEncryptor(cfg PKCS11Config) (*HSMEncryptor, error) {
+	if cfg.ModulePath == "" || cfg.TokenLabel == "" || cfg.KeyLabel == "" {
+		return nil, fmt.Errorf("crypto.pkcs11 requires module_path, token_label, and key_label")
+	}
+	token, err := openPKCS11Token(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &HSMEncryptor{token: token, keyID: "pkcs11:" + cfg.KeyLabel}, nil
+}
+
+// Encrypt seals plaintext under a new data key wrapped by the token.
+func (e *HSMEncryptor) Encrypt(_ context.Context, plaintext, aad []byte) ([]byte, string, error) {
+	dataKey := make([]byte, 32)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, "", err
+	}
+	defer clear(dataKey)
+
+	wrapped, err := e.token.WrapKey(dataKey)
+	if err != nil {
+		return nil, "", fmt.Errorf("hsm wrap data key: %w", err)
+	}
+	nonce, ciphertext, err := sealGCM(dataKey, plaintext, aad)
+	if err != nil {
+		return nil, "", err
+	}
+	env := envelope{KeyID: e.keyID, WrappedKey: wrapped, AAD: aad, Nonce: nonce, Ciphertext: ciphertext}
+	return env.marshal(), e.keyID, nil
+}
+
+// Decrypt unwraps the data key through the token and opens the ciphertext.
+func (e *HSMEncryptor) Decrypt(_ context.Context, data, aad []byte) ([]byte, error) {
+	env, err := unmarshalEnvelope(data)
+	if err != nil {
+		return nil, err
+	}
+	dataKey, err := e.token.UnwrapKey(env.WrappedKey)
+	if err != nil {
+		return nil, fmt.Errorf("hsm unwrap data key: %w", err)
+	}
+	defer clear(dataKey)
+	return openGCM(dataKey, env.Nonce, env.Ciphertext, aad)
+}
+
+// Close logs out of and releases the token.
+func (e *HSMEncryptor) Close() error {
+	if t, ok := e.token.(interface{ close() error }); ok {
+		return t.close()
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package promptvaultprocessor
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"testing"
+)
+
+// fakeToken keeps its master key private, as an HSM would.
+type fakeToken struct {
+	master []byte
+	wraps  int
+}
+
+func (f *fakeToken) WrapKey(dataKey []byte) ([]byte, error) {
+	f.wraps++
+	nonce, ct, err := sealGCM(f.master, dataKey, nil)
+	return append(nonce, ct...), err
+}
+
+func (f *fakeToken) UnwrapKey(wrapped []byte) ([]byte, error) {
+	return openGCM(f.master, wrapped[:gcmNonceSize], wrapped[gcmNonceSize:], nil)
+}
+
+func TestHSMEncryptorWrapsDataKeysThroughToken(t *testing.T) {
+	ctx := context.Background()
+	master := make([]byte, 32)
+	rand.Read(master)
+	token := &fakeToken{master: master}
+	enc := &HSMEncryptor{token: token, keyID: "pkcs11:vault-master"}
+
+	aad := bindingAAD(ObjectMeta{TraceID: "t", SpanID: "s", Key: "k"})
+	sealed, keyID, err := enc.Encrypt(ctx, []byte("hsm protected"), aad)
+	if err != nil || keyID != "pkcs11:vault-master" {
+		t.Fatalf("encrypt: key %q, err %v", keyID, err)
+	}
+	if token.wraps != 1 {
+		t.Errorf("expected the token to wrap the data key, got %d wraps", token.wraps)
+	}
+
+	env, _ := unmarshalEnvelope(sealed)
+	if bytes.Contains(env.WrappedKey, master) || bytes.Contains(sealed, []byte("hsm protected")) {
+		t.Error("expected only wrapped key material and ciphertext in the object")
+	}
+
+	data, err := enc.Decrypt(ctx, sealed, aad)
+	if err != nil || string(data) != "hsm protected" {
+		t.Errorf("expected round trip, got %q, %v", data, err)
+	}
+
+	other := &HSMEncryptor{token: &fakeToken{master: make([]byte, 32)}}
+	if _, err := other.Decrypt(ctx, sealed, aad); err == nil {
+		t.Error("expected a different token to fail to unwrap")
+	}
+}
+
+func TestPKCS11ConfigRequiresToken(t *testing.T) {
+	if _, err := NewHSMEncryptor(PKCS11Config{ModulePath: "/nonexistent.so"}); err == nil {
+		t.Error("expected error for incomplete pkcs11 config")
+	}
+}
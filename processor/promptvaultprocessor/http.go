@@ -0,0 +1,115 @@
+package promptvaultprocessor
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/collector/config/configopaque"
+)
+
+// HTTPVault stores content on a WebDAV server or any endpoint that accepts
+// an authenticated PUT and serves the object back on GET.
+type HTTPVault struct {
+	client       *http.Client
+	urlTemplate  string
+	method       string
+	headers      map[string]configopaque.String
+	pathTemplate string
+}
+
+// NewHTTPVault creates a vault backed by a generic HTTP endpoint.
+func NewHTTPVault(cfg HTTPConfig) (*HTTPVault, error) {
+	if !strings.Contains(cfg.URLTemplate, "{hash}") && !strings.Contains(cfg.URLTemplate, "{path}") {
+		return nil, fmt.Errorf("http url_template must contain {hash} or {path}")
+	}
+	method := cfg.Method
+	if method == "" {
+		method = http.MethodPut
+	}
+	return &HTTPVault{
+		client:      &http.Client{Timeout: cfg.Timeout},
+		urlTemplate: cfg.URLTemplate,
+		method:      method,
+		headers:     cfg.Headers,
+	}, nil
+}
+
+// Store uploads content and returns a vault reference.
+func (v *HTTPVault) Store(ctx context.Context, meta ObjectMeta, content []byte) (string, error) {
+	hexHash := contentHash(content)
+	now := time.Now()
+	objPath := objectPath(v.pathTemplate, meta, hexHash, now)
+
+	req, err := v.newRequest(ctx, v.method, objPath, bytes.NewReader(content))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	for k, val := range newObjectMetadata(meta, hexHash, len(content), now).headers() {
+		req.Header.Set(k, val)
+	}
+
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("upload vault object: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return "", fmt.Errorf("upload vault object: unexpected status %s", resp.Status)
+	}
+
+	return refScheme + objPath, nil
+}
+
+// Retrieve downloads content for ref.
+func (v *HTTPVault) Retrieve(ctx context.Context, ref string) ([]byte, error) {
+	objPath, err := objectPathFromRef(ref)
+	if err != nil {
+		return nil, err
+	}
+	req, err := v.newRequest(ctx, http.MethodGet, objPath, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("download vault object: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("%w: %s", ErrObjectNotFound, ref)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("download vault object: unexpected status %s", resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+func (v *HTTPVault) newRequest(ctx context.Context, method, objPath string, body io.Reader) (*http.Request, error) {
+	// Object paths may contain percent-encoded segments; escape them again so
+	// the server stores the encoded name rather than decoding it.
+	segs := strings.Split(objPath, "/")
+	for i, seg := range segs {
+		segs[i] = url.PathEscape(seg)
+	}
+	target := strings.NewReplacer(
+		"{path}", strings.Join(segs, "/"),
+		"{hash}", path.Base(objPath),
+	).Replace(v.urlTemplate)
+	req, err := http.NewRequestWithContext(ctx, method, target, body)
+	if err != nil {
+		return nil, fmt.Errorf("build vault request: %w", err)
+	}
+	for k, val := range v.headers {
+		req.Header.Set(k, string(val))
+	}
+	return req, nil
+}
@@ -0,0 +1,88 @@
+package promptvaultprocessor
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"go.opentelemetry.io/collector/config/configopaque"
+)
+
+func newTestBlobServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	var mu sync.Mutex
+	blobs := map[string][]byte{}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		switch r.Method {
+		case http.MethodPut:
+			body, _ := io.ReadAll(r.Body)
+			blobs[r.URL.Path] = body
+			w.WriteHeader(http.StatusCreated)
+		case http.MethodGet:
+			body, ok := blobs[r.URL.Path]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Write(body)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestHTTPVaultStoreAndRetrieve(t *testing.T) {
+	srv := newTestBlobServer(t)
+	vault, err := NewHTTPVault(HTTPConfig{
+		URLTemplate: srv.URL + "/vault/{hash}",
+		Headers:     map[string]configopaque.String{"Authorization": "Bearer token"},
+	})
+	if err != nil {
+		t.Fatalf("failed to create vault: %v", err)
+	}
+
+	ctx := context.Background()
+	ref, err := vault.Store(ctx, ObjectMeta{}, []byte("over http"))
+	if err != nil {
+		t.Fatalf("store failed: %v", err)
+	}
+
+	data, err := vault.Retrieve(ctx, ref)
+	if err != nil {
+		t.Fatalf("retrieve failed: %v", err)
+	}
+	if string(data) != "over http" {
+		t.Errorf("expected %q, got %q", "over http", string(data))
+	}
+
+	if _, err := vault.Retrieve(ctx, "vault://missing"); err == nil {
+		t.Error("expected error for missing ref")
+	}
+}
+
+func TestHTTPVaultRejectsBadStatus(t *testing.T) {
+	srv := newTestBlobServer(t)
+	vault, _ := NewHTTPVault(HTTPConfig{URLTemplate: srv.URL + "/vault/{hash}"})
+
+	if _, err := vault.Store(context.Background(), ObjectMeta{}, []byte("no auth")); err == nil {
+		t.Error("expected error when the server rejects the upload")
+	}
+}
+
+func TestHTTPVaultRequiresHashPlaceholder(t *testing.T) {
+	if _, err := NewHTTPVault(HTTPConfig{URLTemplate: "https://example.com/vault"}); err == nil {
+		t.Error("expected error for url_template without {hash}")
+	}
+}
@@ -0,0 +1,67 @@
+package promptvaultprocessor
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"go.opentelemetry.io/collector/config/configopaque"
+)
+
+// minCheckedSecretLen skips secrets too short to search for without false
+// positives; every key this module accepts is longer.
+const minCheckedSecretLen = 8
+
+// CheckSecretsRedacted refuses a configuration whose rendered forms would
+// reveal one of its secrets: the text a collector prints when it logs or
+// exposes its resolved config (fmt verbs and text/JSON marshaling). It
+// guards against a secret field losing its configopaque.String type.
+func CheckSecretsRedacted(cfg any) error {
+	var secrets []string
+	collectSecrets(reflect.ValueOf(cfg), &secrets)
+	if len(secrets) == 0 {
+		return nil
+	}
+
+	renders := []string{fmt.Sprintf("%v", cfg), fmt.Sprintf("%+v", cfg), fmt.Sprintf("%#v", cfg)}
+	if j, err := json.Marshal(cfg); err == nil {
+		renders = append(renders, string(j))
+	}
+	for _, s := range secrets {
+		for _, r := range renders {
+			if strings.Contains(r, s) {
+				return fmt.Errorf("refusing to start: a configured secret appears in the rendered configuration")
+			}
+		}
+	}
+	return nil
+}
+
+var secretType = reflect.TypeOf(configopaque.String(""))
+
+func collectSecrets(v reflect.Value, out *[]string) {
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if !v.IsNil() {
+			collectSecrets(v.Elem(), out)
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			collectSecrets(v.Field(i), out)
+		}
+	case reflect.Map:
+		iter := v.MapRange()
+		for iter.Next() {
+			collectSecrets(iter.Value(), out)
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			collectSecrets(v.Index(i), out)
+		}
+	case reflect.String:
+		if v.Type() == secretType && v.Len() >= minCheckedSecretLen {
+			*out = append(*out, v.String())
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package promptvaultprocessor
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"go.opentelemetry.io/collector/config/configopaque"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.uber.org/zap"
+)
+
+func TestCheckSecretsRedacted(t *testing.T) {
+	cfg := createDefaultConfig()
+	cfg.Vault.SigningKey = "signing-key-0123456789abcdef0123456789"
+	cfg.Storage.HTTP.Headers = map[string]configopaque.String{"Authorization": "Bearer header-token-0123"}
+	if err := CheckSecretsRedacted(cfg); err != nil {
+		t.Errorf("redacted config rejected: %v", err)
+	}
+
+	// A secret copied into a plain string field leaks into config dumps.
+	leaky := struct {
+		Key   configopaque.String
+		Debug string
+	}{Key: "static-key-0123456789", Debug: "static-key-0123456789"}
+	if err := CheckSecretsRedacted(leaky); err == nil {
+		t.Error("expected a config exposing its secret to be refused")
+	}
+}
+
+func TestKeyringCloseZeroesKeys(t *testing.T) {
+	key := bytes.Repeat([]byte{7}, 32)
+	k, _ := newKeyring([]keyringEntry{{id: "k1", key: key}}, "")
+	k.Close()
+
+	if !bytes.Equal(key, make([]byte, 32)) {
+		t.Error("key material not zeroed on Close")
+	}
+	if _, _, err := k.Encrypt(context.Background(), []byte("x"), nil); err == nil {
+		t.Error("expected Encrypt to fail after Close")
+	}
+}
+
+func TestShutdownZeroesProcessorKeys(t *testing.T) {
+	proc := newVaultProcessor(zap.NewNop(), createDefaultConfig(), NewMemoryVault(0, 0), new(consumertest.TracesSink))
+	proc.signer, _ = NewRefSigner("signing-key-0123456789abcdef0123456789")
+	key := proc.signer.key
+
+	if err := proc.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(key, make([]byte, len(key))) || proc.signer != nil {
+		t.Error("signing key not zeroed on Shutdown")
+	}
+}
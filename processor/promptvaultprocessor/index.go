@@ -0,0 +1,370 @@
+package promptvaultprocessor
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// IndexConfig keeps a metadata index of stored objects so listing,
+// searching, and retention do not enumerate the backend.
+type IndexConfig struct {
+	// Path is the index's SQLite database file. Empty disables the index.
+	Path string `mapstructure:"path"`
+}
+
+// indexVault records each object's metadata in an embedded SQLite index as
+// it is stored, and serves List, Stat, and Search from the index. It wraps
+// the backend directly, so sizes and checksums are of the bytes as stored.
+// An object stored for several spans has a row per span; List and Stat
+// report its most recent one. Objects removed behind the index's back, by
+// quota or TTL eviction, remain listed until RebuildIndex.
+type indexVault struct {
+	VaultStorage
+	db *sql.DB
+}
+
+func newIndexVault(ctx context.Context, vault VaultStorage, cfg IndexConfig) (VaultStorage, error) {
+	if cfg.Path == "" {
+		return vault, nil
+	}
+	db, err := openIndex(ctx, cfg.Path)
+	if err != nil {
+		return nil, err
+	}
+	return &indexVault{VaultStorage: vault, db: db}, nil
+}
+
+func openIndex(ctx context.Context, path string) (*sql.DB, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("create index dir: %w", err)
+	}
+	db, err := openSQLite(path)
+	if err != nil {
+		return nil, fmt.Errorf("open index: %w", err)
+	}
+
+	_, err = db.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS objects (
+			ref        TEXT    NOT NULL,
+			trace_id   TEXT    NOT NULL,
+			span_id    TEXT    NOT NULL,
+			attr_key   TEXT    NOT NULL,
+			service    TEXT    NOT NULL,
+			tenant     TEXT    NOT NULL,
+			enduser_id TEXT    NOT NULL,
+			key_id     TEXT    NOT NULL,
+			transforms TEXT    NOT NULL,
+			size       INTEGER NOT NULL,
+			sha256     TEXT    NOT NULL,
+			stored_at  INTEGER NOT NULL,
+			PRIMARY KEY (ref, trace_id, span_id, attr_key)
+		);
+		CREATE INDEX IF NOT EXISTS objects_trace_id_idx ON objects (trace_id);
+		CREATE INDEX IF NOT EXISTS objects_enduser_id_idx ON objects (enduser_id);
+		CREATE INDEX IF NOT EXISTS objects_tenant_idx ON objects (tenant);
+		CREATE INDEX IF NOT EXISTS objects_stored_at_idx ON objects (stored_at);`)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("create index schema: %w", err)
+	}
+	return db, nil
+}
+
+const upsertIndexRow = `
+	INSERT INTO objects (ref, trace_id, span_id, attr_key, service, tenant, enduser_id, key_id, transforms, size, sha256, stored_at)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	ON CONFLICT (ref, trace_id, span_id, attr_key)
+	DO UPDATE SET service = excluded.service, tenant = excluded.tenant, enduser_id = excluded.enduser_id,
+		key_id = excluded.key_id, transforms = excluded.transforms, size = excluded.size,
+		sha256 = excluded.sha256, stored_at = excluded.stored_at`
+
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+func indexObject(ctx context.Context, db execer, info ObjectInfo, sha string) error {
+	m := info.Meta
+	// Backends that do not record store times are indexed as of now, so
+	// retention counts their age from the rebuild rather than the epoch.
+	storedAt := info.StoredAt
+	if storedAt.IsZero() {
+		storedAt = time.Now()
+	}
+	_, err := db.ExecContext(ctx, upsertIndexRow,
+		info.Ref, m.TraceID, m.SpanID, m.Key, m.Service, m.Tenant, m.EndUser, m.KeyID, m.Transforms,
+		info.Size, sha, storedAt.UTC().UnixNano())
+	return err
+}
+
+// Store stores content, then indexes it. If indexing fails the object is
+// stored but unlisted; the error lets the caller retry, which re-indexes it.
+func (v *indexVault) Store(ctx context.Context, meta ObjectMeta, content []byte) (string, error) {
+	ref, err := v.VaultStorage.Store(ctx, meta, content)
+	if err != nil {
+		return "", err
+	}
+	info := ObjectInfo{Ref: ref, Size: int64(len(content)), StoredAt: time.Now(), Meta: meta}
+	if err := indexObject(ctx, v.db, info, contentHash(content)); err != nil {
+		return "", fmt.Errorf("index %s: %w", ref, err)
+	}
+	return ref, nil
+}
+
+// indexPageSize bounds the rows read per index query, so fn can run, and
+// delete through this vault, between pages rather than under an open cursor.
+const indexPageSize = 1000
+
+// List calls fn for every indexed object, oldest first.
+func (v *indexVault) List(ctx context.Context, fn func(ObjectInfo) error) error {
+	return v.query(ctx, "", nil, fn)
+}
+
+// Search calls fn for every indexed object with a row matching f, oldest
+// first. The object is described by its matching row.
+func (v *indexVault) Search(ctx context.Context, f ObjectFilter, fn func(ObjectInfo) error) error {
+	var where []string
+	var args []any
+	for _, c := range []struct{ col, val string }{
+		{"trace_id", f.TraceID},
+		{"enduser_id", f.EndUser},
+		{"service", f.Service},
+		{"tenant", f.Tenant},
+		{"attr_key", f.Key},
+	} {
+		if c.val != "" {
+			where = append(where, c.col+" = ?")
+			args = append(args, c.val)
+		}
+	}
+	if !f.StoredAfter.IsZero() {
+		where = append(where, "stored_at >= ?")
+		args = append(args, f.StoredAfter.UTC().UnixNano())
+	}
+	if !f.StoredBefore.IsZero() {
+		where = append(where, "stored_at < ?")
+		args = append(args, f.StoredBefore.UTC().UnixNano())
+	}
+	return v.query(ctx, strings.Join(where, " AND "), args, fn)
+}
+
+func (v *indexVault) Compact(ctx context.Context) (CompactionStats, error) {
+	if c, ok := v.VaultStorage.(VaultCompactor); ok {
+		return c.Compact(ctx)
+	}
+	return CompactionStats{}, fmt.Errorf("compact: %w", errors.ErrUnsupported)
+}
+
+// Stats aggregates the index, counting each object once as described by its
+// most recent row, like List.
+func (v *indexVault) Stats(ctx context.Context) (VaultStats, error) {
+	rows, err := v.db.QueryContext(ctx, `SELECT attr_key, service, tenant, transforms, COUNT(*), SUM(size), MIN(stored_at)
+		FROM (SELECT attr_key, service, tenant, transforms, size, MAX(stored_at) AS stored_at FROM objects GROUP BY ref)
+		GROUP BY attr_key, service, tenant, transforms`)
+	if err != nil {
+		return VaultStats{}, fmt.Errorf("query index: %w", err)
+	}
+	defer rows.Close()
+	stats := newVaultStats()
+	for rows.Next() {
+		var m ObjectMeta
+		var objects, bytes, oldest int64
+		if err := rows.Scan(&m.Key, &m.Service, &m.Tenant, &m.Transforms, &objects, &bytes, &oldest); err != nil {
+			return VaultStats{}, fmt.Errorf("scan index: %w", err)
+		}
+		stats.add(m, objects, bytes, time.Unix(0, oldest))
+	}
+	if err := rows.Err(); err != nil {
+		return VaultStats{}, fmt.Errorf("query index: %w", err)
+	}
+	return stats, nil
+}
+
+// Stat describes ref from the index, falling back to the backend for
+// objects stored before the index existed.
+func (v *indexVault) Stat(ctx context.Context, ref string) (ObjectInfo, error) {
+	var info ObjectInfo
+	found := false
+	err := v.query(ctx, "ref = ?", []any{ref}, func(i ObjectInfo) error {
+		info, found = i, true
+		return nil
+	})
+	if err != nil || found {
+		return info, err
+	}
+	if s, ok := v.VaultStorage.(VaultStatter); ok {
+		return s.Stat(ctx, ref)
+	}
+	return ObjectInfo{}, fmt.Errorf("%w: %s", ErrObjectNotFound, ref)
+}
+
+// query calls fn, oldest first, for each ref with rows matching where,
+// described by the most recent of them. SQLite returns the bare columns of
+// the row holding MAX(stored_at). Pages are keyed on (stored_at, ref).
+func (v *indexVault) query(ctx context.Context, where string, args []any, fn func(ObjectInfo) error) error {
+	q := `SELECT ref, trace_id, span_id, attr_key, service, tenant, enduser_id, key_id, transforms, size, MAX(stored_at)
+		FROM objects`
+	if where != "" {
+		q += " WHERE " + where
+	}
+	q += ` GROUP BY ref HAVING MAX(stored_at) > ? OR (MAX(stored_at) = ? AND ref > ?)
+		ORDER BY MAX(stored_at), ref LIMIT ?`
+
+	var afterTime int64 = -1 << 63
+	afterRef := ""
+	for {
+		page, err := v.page(ctx, q, append(args, afterTime, afterTime, afterRef, indexPageSize))
+		if err != nil {
+			return err
+		}
+		for _, info := range page {
+			if err := fn(info); err != nil {
+				return err
+			}
+		}
+		if len(page) < indexPageSize {
+			return nil
+		}
+		last := page[len(page)-1]
+		afterTime, afterRef = last.StoredAt.UnixNano(), last.Ref
+	}
+}
+
+func (v *indexVault) page(ctx context.Context, q string, args []any) ([]ObjectInfo, error) {
+	rows, err := v.db.QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query index: %w", err)
+	}
+	defer rows.Close()
+	var infos []ObjectInfo
+	for rows.Next() {
+		var info ObjectInfo
+		var storedAt int64
+		m := &info.Meta
+		if err := rows.Scan(&info.Ref, &m.TraceID, &m.SpanID, &m.Key, &m.Service, &m.Tenant, &m.EndUser,
+			&m.KeyID, &m.Transforms, &info.Size, &storedAt); err != nil {
+			return nil, fmt.Errorf("scan index: %w", err)
+		}
+		info.StoredAt = time.Unix(0, storedAt)
+		infos = append(infos, info)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("query index: %w", err)
+	}
+	return infos, nil
+}
+
+// Delete deletes ref from the backend and the index. An object the backend
+// no longer has is dropped from the index too.
+func (v *indexVault) Delete(ctx context.Context, ref string) error {
+	d, ok := v.VaultStorage.(VaultDeleter)
+	if !ok {
+		return fmt.Errorf("delete: %w", errors.ErrUnsupported)
+	}
+	err := d.Delete(ctx, ref)
+	if err != nil && !errors.Is(err, ErrObjectNotFound) {
+		return err
+	}
+	if _, ierr := v.db.ExecContext(ctx, `DELETE FROM objects WHERE ref = ?`, ref); ierr != nil {
+		return fmt.Errorf("unindex %s: %w", ref, ierr)
+	}
+	return err
+}
+
+// Replace overwrites ref in the backend and updates its indexed size and
+// checksum.
+func (v *indexVault) Replace(ctx context.Context, ref string, content []byte) error {
+	r, ok := v.VaultStorage.(VaultReplacer)
+	if !ok {
+		return fmt.Errorf("replace: %w", errors.ErrUnsupported)
+	}
+	if err := r.Replace(ctx, ref, content); err != nil {
+		return err
+	}
+	_, err := v.db.ExecContext(ctx, `UPDATE objects SET size = ?, sha256 = ? WHERE ref = ?`,
+		len(content), contentHash(content), ref)
+	if err != nil {
+		return fmt.Errorf("index %s: %w", ref, err)
+	}
+	return nil
+}
+
+func (v *indexVault) Start(ctx context.Context) error {
+	if s, ok := v.VaultStorage.(vaultStarter); ok {
+		return s.Start(ctx)
+	}
+	return nil
+}
+
+func (v *indexVault) Close() error {
+	err := v.db.Close()
+	if c, ok := v.VaultStorage.(io.Closer); ok {
+		err = errors.Join(err, c.Close())
+	}
+	return err
+}
+
+// RebuildIndex replaces the contents of cfg.Storage.Index with a listing of
+// the backend, for indexes added to an existing vault or left stale by
+// eviction. Metadata is whatever the backend keeps. It returns the number
+// of objects indexed.
+func RebuildIndex(ctx context.Context, logger *zap.Logger, cfg *Config) (int, error) {
+	if cfg.Storage.Index.Path == "" {
+		return 0, fmt.Errorf("storage.index.path is not set")
+	}
+	metrics, err := newVaultMetrics(nil)
+	if err != nil {
+		return 0, err
+	}
+	// Bundled objects are listed from the bundle index.
+	backend, err := openBackendStack(ctx, logger, metrics, cfg.Storage)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if c, ok := backend.(io.Closer); ok {
+			c.Close()
+		}
+	}()
+	lister, ok := backend.(VaultLister)
+	if !ok {
+		return 0, fmt.Errorf("rebuild index: storage backend cannot list objects")
+	}
+	db, err := openIndex(ctx, cfg.Storage.Index.Path)
+	if err != nil {
+		return 0, err
+	}
+	defer db.Close()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx, `DELETE FROM objects`); err != nil {
+		return 0, err
+	}
+	n := 0
+	err = lister.List(ctx, func(info ObjectInfo) error {
+		n++
+		for _, owner := range append([]ObjectMeta{info.Meta}, info.Owners...) {
+			info.Meta = owner
+			if err := indexObject(ctx, tx, info, hashFromRef(info.Ref)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("rebuild index: %w", err)
+	}
+	return n, tx.Commit()
+}
@@ -0,0 +1,145 @@
+package promptvaultprocessor
+
+import (
+	"context"
+	"errors"
+	"io"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func newTestIndexVault(t *testing.T, backend VaultStorage) *indexVault {
+	t.Helper()
+	v, err := newIndexVault(context.Background(), backend, IndexConfig{Path: filepath.Join(t.TempDir(), "index.db")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { v.(*indexVault).Close() })
+	return v.(*indexVault)
+}
+
+func searchRefs(t *testing.T, v VaultStorage, f ObjectFilter) []string {
+	t.Helper()
+	var refs []string
+	if err := SearchObjects(context.Background(), v, f, func(info ObjectInfo) error {
+		refs = append(refs, info.Ref)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return refs
+}
+
+func TestIndexVaultSearch(t *testing.T) {
+	ctx := context.Background()
+	v := newTestIndexVault(t, NewMemoryVault(0, 0))
+
+	a, _ := v.Store(ctx, ObjectMeta{TraceID: "t1", SpanID: "s1", Key: "gen_ai.prompt", Tenant: "acme", EndUser: "u1"}, []byte("a"))
+	b, _ := v.Store(ctx, ObjectMeta{TraceID: "t1", SpanID: "s2", Key: "gen_ai.completion", Tenant: "acme"}, []byte("b"))
+	c, _ := v.Store(ctx, ObjectMeta{TraceID: "t2", SpanID: "s3", Key: "gen_ai.prompt", Tenant: "globex", EndUser: "u1"}, []byte("c"))
+	// The same content stored for another span is one object with two rows.
+	v.Store(ctx, ObjectMeta{TraceID: "t3", SpanID: "s4", Key: "gen_ai.prompt", Tenant: "acme"}, []byte("a"))
+
+	for _, tc := range []struct {
+		name   string
+		filter ObjectFilter
+		want   []string
+	}{
+		{"trace", ObjectFilter{TraceID: "t1"}, []string{b, a}},
+		{"end user", ObjectFilter{EndUser: "u1"}, []string{a, c}},
+		{"tenant and key", ObjectFilter{Tenant: "acme", Key: "gen_ai.prompt"}, []string{a}},
+		{"older than now", ObjectFilter{StoredBefore: time.Now().Add(time.Minute)}, []string{b, c, a}},
+		{"no match", ObjectFilter{TraceID: "t9"}, nil},
+	} {
+		got := searchRefs(t, v, tc.filter)
+		if len(got) != len(tc.want) {
+			t.Errorf("%s: got %v, want %v", tc.name, got, tc.want)
+			continue
+		}
+		// Rows within one test may share a timestamp, so compare as sets.
+		seen := make(map[string]bool)
+		for _, ref := range got {
+			seen[ref] = true
+		}
+		for _, ref := range tc.want {
+			if !seen[ref] {
+				t.Errorf("%s: got %v, want %v", tc.name, got, tc.want)
+				break
+			}
+		}
+	}
+
+	info, err := v.Stat(ctx, a)
+	if err != nil || info.Meta.TraceID != "t3" || info.Size != 1 {
+		t.Errorf("Stat = %+v, %v; want the most recent row", info, err)
+	}
+
+	if err := v.Delete(ctx, a); err != nil {
+		t.Fatal(err)
+	}
+	if refs := searchRefs(t, v, ObjectFilter{}); len(refs) != 2 {
+		t.Errorf("after delete, listed %v", refs)
+	}
+	if _, err := v.Stat(ctx, a); !errors.Is(err, ErrObjectNotFound) {
+		t.Errorf("Stat after delete: %v", err)
+	}
+}
+
+func TestIndexVaultPages(t *testing.T) {
+	ctx := context.Background()
+	v := newTestIndexVault(t, NewMemoryVault(0, 0))
+	const n = indexPageSize + 10
+	for i := 0; i < n; i++ {
+		if _, err := v.Store(ctx, ObjectMeta{TraceID: "t1", Key: "k"}, []byte{byte(i), byte(i >> 8)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Deleting as objects are listed must neither skip nor repeat any.
+	seen := make(map[string]bool)
+	err := v.List(ctx, func(info ObjectInfo) error {
+		if seen[info.Ref] {
+			t.Fatalf("%s listed twice", info.Ref)
+		}
+		seen[info.Ref] = true
+		return v.Delete(ctx, info.Ref)
+	})
+	if err != nil || len(seen) != n {
+		t.Errorf("listed %d of %d objects, err %v", len(seen), n, err)
+	}
+}
+
+func TestRebuildIndex(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	cfg := createDefaultConfig()
+	cfg.Storage.Filesystem.BasePath = filepath.Join(dir, "vault")
+	cfg.Storage.Filesystem.WriteMetadata = true
+
+	// Objects stored before the index was configured.
+	vault, err := OpenVault(ctx, zap.NewNop(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref, err := vault.Store(ctx, ObjectMeta{TraceID: "t1", Key: "gen_ai.prompt", EndUser: "u1"}, []byte("before the index"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	vault.(io.Closer).Close()
+
+	cfg.Storage.Index.Path = filepath.Join(dir, "index.db")
+	n, err := RebuildIndex(ctx, zap.NewNop(), cfg)
+	if err != nil || n != 1 {
+		t.Fatalf("RebuildIndex = %d, %v; want 1 object", n, err)
+	}
+	vault, err = OpenVault(ctx, zap.NewNop(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer vault.(io.Closer).Close()
+	if refs := searchRefs(t, vault, ObjectFilter{EndUser: "u1"}); len(refs) != 1 || refs[0] != ref {
+		t.Errorf("indexed %v, want [%s]", refs, ref)
+	}
+}
@@ -0,0 +1,186 @@
+//go:build !promptvault_no_kafka
+
+package promptvaultprocessor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kerr"
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+const kafkaRefPrefix = refScheme + "kafka/"
+
+// KafkaVault appends content to a Kafka topic. References encode the
+// topic/partition/offset of the produced record, so retention and indexing
+// are left to downstream consumers of the topic.
+type KafkaVault struct {
+	client  *kgo.Client
+	brokers []string
+	topic   string
+	timeout time.Duration
+}
+
+// NewKafkaVault creates a producer for the configured topic.
+func NewKafkaVault(cfg KafkaConfig) (*KafkaVault, error) {
+	if len(cfg.Brokers) == 0 {
+		return nil, fmt.Errorf("kafka backend requires at least one broker")
+	}
+	client, err := kgo.NewClient(
+		kgo.SeedBrokers(cfg.Brokers...),
+		kgo.DefaultProduceTopic(cfg.Topic),
+		kgo.RequiredAcks(kgo.AllISRAcks()),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("create kafka client: %w", err)
+	}
+	return &KafkaVault{client: client, brokers: cfg.Brokers, topic: cfg.Topic, timeout: cfg.Timeout}, nil
+}
+
+// openKafkaVault backs storage.backend kafka; builds tagged
+// promptvault_no_kafka leave franz-go out and replace it with a stub.
+func openKafkaVault(cfg KafkaConfig) (VaultStorage, error) {
+	return NewKafkaVault(cfg)
+}
+
+// Store produces content keyed by trace/span and returns a reference of the
+// form vault://kafka/<topic>/<partition>/<offset>.
+func (v *KafkaVault) Store(ctx context.Context, meta ObjectMeta, content []byte) (string, error) {
+	// A nil value would be produced as a tombstone.
+	if content == nil {
+		content = []byte{}
+	}
+	record := &kgo.Record{
+		Key:   []byte(meta.TraceID + "/" + meta.SpanID),
+		Value: content,
+		Headers: []kgo.RecordHeader{
+			{Key: "attr_key", Value: []byte(meta.Key)},
+			{Key: "sha256", Value: []byte(contentHash(content))},
+			{Key: "service_name", Value: []byte(meta.Service)},
+			{Key: "tenant", Value: []byte(meta.Tenant)},
+			{Key: "encryption_key_id", Value: []byte(meta.KeyID)},
+			{Key: "transformations", Value: []byte(meta.Transforms)},
+		},
+	}
+	res := v.client.ProduceSync(ctx, record)
+	if err := res.FirstErr(); err != nil {
+		return "", fmt.Errorf("produce vault record: %w", err)
+	}
+	r := res[0].Record
+	return formatKafkaRef(r.Topic, r.Partition, r.Offset), nil
+}
+
+// Retrieve fetches the single record addressed by ref. A record that was
+// deleted by retention or compaction, replaced by a tombstone, or never
+// written is reported as ErrObjectNotFound; the fetch gives up after the
+// configured timeout. A record with an empty value is empty content.
+func (v *KafkaVault) Retrieve(ctx context.Context, ref string) ([]byte, error) {
+	topic, partition, offset, err := parseKafkaRef(ref)
+	if err != nil {
+		return nil, err
+	}
+	if v.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, v.timeout)
+		defer cancel()
+	}
+
+	consumer, err := kgo.NewClient(
+		kgo.SeedBrokers(v.brokers...),
+		kgo.ConsumePartitions(map[string]map[int32]kgo.Offset{
+			topic: {partition: kgo.NewOffset().At(offset)},
+		}),
+		// Out-of-range offsets surface as errors instead of silently
+		// restarting from the beginning or end of the partition.
+		kgo.ConsumeResetOffset(kgo.NoResetOffset()),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("create kafka consumer: %w", err)
+	}
+	defer consumer.Close()
+
+	for {
+		fetches := consumer.PollFetches(ctx)
+		if err := ctx.Err(); err != nil {
+			return nil, fmt.Errorf("fetch vault record: %w", err)
+		}
+		if errs := fetches.Errors(); len(errs) > 0 {
+			if errors.Is(errs[0].Err, kerr.OffsetOutOfRange) {
+				return nil, fmt.Errorf("%w: %s", ErrObjectNotFound, ref)
+			}
+			return nil, fmt.Errorf("fetch vault record: %w", errs[0].Err)
+		}
+		var found *kgo.Record
+		passed := false
+		fetches.EachPartition(func(p kgo.FetchTopicPartition) {
+			r, past := findKafkaRecord(p, offset)
+			if r != nil {
+				found = r
+			}
+			passed = passed || past
+		})
+		if found != nil {
+			return kafkaRecordContent(found, ref)
+		}
+		if passed {
+			return nil, fmt.Errorf("%w: %s", ErrObjectNotFound, ref)
+		}
+	}
+}
+
+// findKafkaRecord returns the record at offset among p's records, if
+// fetched, and whether p has moved past offset without it.
+func findKafkaRecord(p kgo.FetchTopicPartition, offset int64) (record *kgo.Record, passed bool) {
+	for _, r := range p.Records {
+		switch {
+		case r.Offset == offset:
+			record = r
+		case r.Offset > offset:
+			passed = true
+		}
+	}
+	if p.HighWatermark >= 0 && p.HighWatermark <= offset {
+		passed = true
+	}
+	return record, passed
+}
+
+// kafkaRecordContent returns the content of a fetched record. A null value
+// is a tombstone, while an empty one is content that happened to be empty.
+func kafkaRecordContent(r *kgo.Record, ref string) ([]byte, error) {
+	if r.Value == nil {
+		return nil, fmt.Errorf("%w: %s", ErrObjectNotFound, ref)
+	}
+	return r.Value, nil
+}
+
+// Close flushes pending records and closes the producer.
+func (v *KafkaVault) Close() error {
+	v.client.Close()
+	return nil
+}
+
+func formatKafkaRef(topic string, partition int32, offset int64) string {
+	return fmt.Sprintf("%s%s/%d/%d", kafkaRefPrefix, topic, partition, offset)
+}
+
+func parseKafkaRef(ref string) (topic string, partition int32, offset int64, err error) {
+	parts := strings.Split(strings.TrimPrefix(ref, kafkaRefPrefix), "/")
+	if !strings.HasPrefix(ref, kafkaRefPrefix) || len(parts) != 3 {
+		return "", 0, 0, fmt.Errorf("not a kafka vault ref: %s", ref)
+	}
+	p, err := strconv.ParseInt(parts[1], 10, 32)
+	if err != nil {
+		return "", 0, 0, fmt.Errorf("invalid partition in ref %s: %w", ref, err)
+	}
+	o, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil {
+		return "", 0, 0, fmt.Errorf("invalid offset in ref %s: %w", ref, err)
+	}
+	return parts[0], int32(p), o, nil
+}
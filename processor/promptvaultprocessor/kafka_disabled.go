@@ -0,0 +1,17 @@
+//go:build promptvault_no_kafka
+
+package promptvaultprocessor
+
+import "errors"
+
+// errKafkaExcluded is returned for storage.backend kafka and notify.kafka
+// by binaries built with the promptvault_no_kafka tag.
+var errKafkaExcluded = errors.New("kafka support was excluded from this build (promptvault_no_kafka)")
+
+func openKafkaVault(KafkaConfig) (VaultStorage, error) {
+	return nil, errKafkaExcluded
+}
+
+func newKafkaTarget(KafkaConfig) (notifyTarget, error) {
+	return nil, errKafkaExcluded
+}
@@ -0,0 +1,22 @@
+//go:build promptvault_no_kafka
+
+package promptvaultprocessor
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestKafkaExcluded(t *testing.T) {
+	ctx := context.Background()
+	if _, err := newVaultStorage(ctx, zap.NewNop(), nil, StorageConfig{Backend: "kafka"}); !errors.Is(err, errKafkaExcluded) {
+		t.Errorf("storage.backend kafka: expected %v, got %v", errKafkaExcluded, err)
+	}
+	cfg := NotifyConfig{Kafka: KafkaConfig{Brokers: []string{"localhost:9092"}, Topic: "vault"}}
+	if _, err := newStoreNotifier(ctx, zap.NewNop(), cfg); !errors.Is(err, errKafkaExcluded) {
+		t.Errorf("notify.kafka: expected %v, got %v", errKafkaExcluded, err)
+	}
+}
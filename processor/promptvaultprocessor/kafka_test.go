@@ -0,0 +1,91 @@
+//go:build !promptvault_no_kafka
+
+package promptvaultprocessor
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+func TestKafkaRefRoundTrip(t *testing.T) {
+	ref := formatKafkaRef("prompt-vault", 3, 12345)
+	if ref != "vault://kafka/prompt-vault/3/12345" {
+		t.Fatalf("unexpected ref: %s", ref)
+	}
+
+	topic, partition, offset, err := parseKafkaRef(ref)
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	if topic != "prompt-vault" || partition != 3 || offset != 12345 {
+		t.Errorf("unexpected parse result: %s %d %d", topic, partition, offset)
+	}
+}
+
+func TestKafkaRefRejectsContentRef(t *testing.T) {
+	if _, _, _, err := parseKafkaRef("vault://abc123"); err == nil {
+		t.Error("expected error for non-kafka ref")
+	}
+}
+
+func TestKafkaRetrieveTimesOut(t *testing.T) {
+	cfg := createDefaultConfig().Storage.Kafka
+	cfg.Brokers = []string{"127.0.0.1:1"}
+	cfg.Timeout = 200 * time.Millisecond
+	v, err := NewKafkaVault(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer v.Close()
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := v.Retrieve(context.Background(), formatKafkaRef(cfg.Topic, 0, 7))
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Error("Retrieve from an unreachable broker succeeded")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Retrieve did not honor the timeout")
+	}
+}
+
+func TestKafkaRecordAtOffset(t *testing.T) {
+	ref := formatKafkaRef("prompt-vault", 0, 5)
+	fetched := func(hwm int64, records ...*kgo.Record) kgo.FetchTopicPartition {
+		return kgo.FetchTopicPartition{Topic: "prompt-vault", FetchPartition: kgo.FetchPartition{HighWatermark: hwm, Records: records}}
+	}
+
+	// An empty value is stored content that happened to be empty.
+	r, passed := findKafkaRecord(fetched(6, &kgo.Record{Offset: 5, Value: []byte{}}), 5)
+	if r == nil || passed {
+		t.Fatalf("record at offset not found: %v, passed %v", r, passed)
+	}
+	if content, err := kafkaRecordContent(r, ref); err != nil || content == nil || len(content) != 0 {
+		t.Errorf("empty value = %q, %v; want empty content", content, err)
+	}
+
+	// A null value is a tombstone.
+	r, _ = findKafkaRecord(fetched(6, &kgo.Record{Offset: 5}), 5)
+	if _, err := kafkaRecordContent(r, ref); !errors.Is(err, ErrObjectNotFound) {
+		t.Errorf("tombstone = %v, want ErrObjectNotFound", err)
+	}
+
+	// Earlier records keep the fetch going; later ones mean it is gone.
+	if r, passed := findKafkaRecord(fetched(9, &kgo.Record{Offset: 4}), 5); r != nil || passed {
+		t.Errorf("offset 4 of 9: record %v, passed %v; want neither", r, passed)
+	}
+	if r, passed := findKafkaRecord(fetched(9, &kgo.Record{Offset: 7}), 5); r != nil || !passed {
+		t.Errorf("compacted offset: record %v, passed %v; want passed", r, passed)
+	}
+	if r, passed := findKafkaRecord(fetched(5), 5); r != nil || !passed {
+		t.Errorf("offset at the high watermark: record %v, passed %v; want passed", r, passed)
+	}
+}
@@ -0,0 +1,393 @@
+package promptvaultprocessor
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"golang.org/x/crypto/hkdf"
+)
+
+// keyringEntry is one named AES-256 key.
+type keyringEntry struct {
+	id  string
+	key []byte
+}
+
+// Keyring holds locally managed AES-256 master keys. The first key encrypts
+// new objects; every key stays available for decryption, so vaults written
+// across several key generations remain readable during and after rotation.
+//
+// Master keys never touch GCM directly: each object is sealed under a sub-key
+// derived with HKDF from the master key and the object's trace ID, span ID,
+// and attribute key, which bounds random-nonce reuse risk to a single span.
+type Keyring struct {
+	cfg    LocalKeysConfig
+	logger *zap.Logger
+
+	mu      sync.RWMutex
+	keys    []keyringEntry
+	fileSum [sha256.Size]byte
+
+	stop chan struct{}
+	done sync.WaitGroup
+}
+
+// NewKeyring loads keys from cfg.Keys, cfg.KeyringFile, and environment
+// variables prefixed with cfg.EnvPrefix. cfg.ActiveKey selects the
+// encryption key; otherwise the first key loaded, in that order, is used
+// (env keys are ordered by name).
+func NewKeyring(cfg LocalKeysConfig) (*Keyring, error) {
+	keys, sum, err := loadKeys(cfg)
+	if err != nil {
+		return nil, err
+	}
+	k, err := newKeyring(keys, cfg.ActiveKey)
+	if err != nil {
+		return nil, err
+	}
+	k.cfg = cfg
+	k.fileSum = sum
+	return k, nil
+}
+
+func loadKeys(cfg LocalKeysConfig) ([]keyringEntry, [sha256.Size]byte, error) {
+	var keys []keyringEntry
+	var sum [sha256.Size]byte
+	for i, kc := range cfg.Keys {
+		if kc.ID == "" {
+			return nil, sum, fmt.Errorf("crypto.local.keys[%d]: id is required", i)
+		}
+		// File and secrets-manager providers often keep a trailing newline.
+		key, err := decodeAESKey(strings.TrimSpace(string(kc.Key)))
+		if err != nil {
+			return nil, sum, fmt.Errorf("crypto.local.keys[%d] (%s): %w", i, kc.ID, err)
+		}
+		keys = append(keys, keyringEntry{id: kc.ID, key: key})
+	}
+	if cfg.KeyringFile != "" {
+		data, err := os.ReadFile(cfg.KeyringFile)
+		if err != nil {
+			return nil, sum, fmt.Errorf("read keyring: %w", err)
+		}
+		fileKeys, err := parseKeyring(data)
+		if err != nil {
+			return nil, sum, fmt.Errorf("parse keyring %s: %w", cfg.KeyringFile, err)
+		}
+		keys = append(keys, fileKeys...)
+		sum = sha256.Sum256(data)
+	}
+	if cfg.EnvPrefix != "" {
+		envKeys, err := keysFromEnv(cfg.EnvPrefix, os.Environ())
+		if err != nil {
+			return nil, sum, err
+		}
+		keys = append(keys, envKeys...)
+	}
+	return keys, sum, nil
+}
+
+// Reload re-reads the keyring file and swaps in its keys if the content
+// changed. A file that fails to parse leaves the current keys in place.
+func (k *Keyring) Reload() (bool, error) {
+	if k.cfg.KeyringFile == "" {
+		return false, nil
+	}
+	data, err := os.ReadFile(k.cfg.KeyringFile)
+	if err != nil {
+		return false, fmt.Errorf("read keyring: %w", err)
+	}
+	k.mu.RLock()
+	unchanged := sha256.Sum256(data) == k.fileSum
+	k.mu.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	keys, sum, err := loadKeys(k.cfg)
+	if err != nil {
+		return false, err
+	}
+	next, err := newKeyring(keys, k.cfg.ActiveKey)
+	if err != nil {
+		return false, err
+	}
+	k.mu.Lock()
+	k.keys = next.keys
+	k.fileSum = sum
+	k.mu.Unlock()
+	return true, nil
+}
+
+// Start launches periodic reloading when cfg.ReloadInterval is set.
+func (k *Keyring) Start(context.Context) error {
+	if k.cfg.ReloadInterval <= 0 || k.cfg.KeyringFile == "" {
+		return nil
+	}
+	k.stop = make(chan struct{})
+	k.done.Add(1)
+	go k.reloadLoop()
+	return nil
+}
+
+func (k *Keyring) reloadLoop() {
+	defer k.done.Done()
+
+	ticker := time.NewTicker(k.cfg.ReloadInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-k.stop:
+			return
+		case <-ticker.C:
+			changed, err := k.Reload()
+			if err != nil {
+				k.logger.Warn("keyring reload failed; keeping current keys", zap.Error(err))
+			} else if changed {
+				k.logger.Info("keyring reloaded", zap.String("active_key", k.activeKey().id))
+			}
+		}
+	}
+}
+
+// Close stops periodic reloading and zeroes the keys.
+func (k *Keyring) Close() error {
+	if k.stop != nil {
+		select {
+		case <-k.stop:
+		default:
+			close(k.stop)
+		}
+		k.done.Wait()
+	}
+	// Zero the current keys. Keys replaced by Reload are left to the garbage
+	// collector, since in-flight operations may still hold them.
+	k.mu.Lock()
+	for _, e := range k.keys {
+		clear(e.key)
+	}
+	k.keys = nil
+	k.mu.Unlock()
+	return nil
+}
+
+// ActiveKeyID returns the ID of the key new objects are encrypted with.
+func (k *Keyring) ActiveKeyID() string {
+	return k.activeKey().id
+}
+
+// activeKey returns the encryption key, or a zero entry once Close has run.
+func (k *Keyring) activeKey() keyringEntry {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	if len(k.keys) == 0 {
+		return keyringEntry{}
+	}
+	return k.keys[0]
+}
+
+var errKeyringClosed = errors.New("keyring closed")
+
+func newKeyring(keys []keyringEntry, active string) (*Keyring, error) {
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("keyring contains no keys")
+	}
+	seen := make(map[string]bool, len(keys))
+	for _, k := range keys {
+		if seen[k.id] {
+			return nil, fmt.Errorf("duplicate key id %q in keyring", k.id)
+		}
+		seen[k.id] = true
+	}
+	if active != "" {
+		i := slices.IndexFunc(keys, func(k keyringEntry) bool { return k.id == active })
+		if i < 0 {
+			return nil, fmt.Errorf("active key %q not found in keyring", active)
+		}
+		keys[0], keys[i] = keys[i], keys[0]
+	}
+	return &Keyring{keys: keys, logger: zap.NewNop()}, nil
+}
+
+// parseKeyring reads "<key id> <base64 key>" lines; blank lines and lines
+// starting with # are ignored.
+func parseKeyring(data []byte) ([]keyringEntry, error) {
+	var keys []keyringEntry
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("line %d: expected \"<key id> <base64 key>\"", n)
+		}
+		key, err := decodeAESKey(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		keys = append(keys, keyringEntry{id: fields[0], key: key})
+	}
+	return keys, sc.Err()
+}
+
+// keysFromEnv collects <prefix><ID>=<base64 key> variables, ordered by ID.
+func keysFromEnv(prefix string, environ []string) ([]keyringEntry, error) {
+	var keys []keyringEntry
+	for _, kv := range environ {
+		name, value, _ := strings.Cut(kv, "=")
+		id, ok := strings.CutPrefix(name, prefix)
+		if !ok || id == "" {
+			continue
+		}
+		key, err := decodeAESKey(value)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		keys = append(keys, keyringEntry{id: id, key: key})
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].id < keys[j].id })
+	return keys, nil
+}
+
+func decodeAESKey(s string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid base64 key: %w", err)
+	}
+	if len(key) != 32 {
+		return nil, fmt.Errorf("key must be 32 bytes, got %d", len(key))
+	}
+	return key, nil
+}
+
+// Encrypt seals plaintext under the active key.
+func (k *Keyring) Encrypt(_ context.Context, plaintext, aad []byte) ([]byte, string, error) {
+	active := k.activeKey()
+	if active.key == nil {
+		return nil, "", errKeyringClosed
+	}
+	if k.cfg.Convergent {
+		sealed, err := sealConvergent(active, plaintext)
+		return sealed, active.id, err
+	}
+	subKey, err := deriveObjectKey(active.key, aad)
+	if err != nil {
+		return nil, "", err
+	}
+	defer clear(subKey)
+	nonce, ciphertext, err := sealGCM(subKey, plaintext, aad)
+	if err != nil {
+		return nil, "", err
+	}
+	env := envelope{KeyID: active.id, AAD: aad, Nonce: nonce, Ciphertext: ciphertext}
+	return env.marshal(), active.id, nil
+}
+
+// Decrypt opens data with the key named in its envelope. If that key is not
+// in the keyring, every key is tried in order.
+func (k *Keyring) Decrypt(_ context.Context, data, aad []byte) ([]byte, error) {
+	env, err := unmarshalEnvelope(data)
+	if err != nil {
+		return nil, err
+	}
+	k.mu.RLock()
+	keys := k.keys
+	k.mu.RUnlock()
+	for _, e := range keys {
+		if e.id == env.KeyID {
+			return openWithMasterKey(e.key, env, aad)
+		}
+	}
+	for _, e := range keys {
+		if content, err := openWithMasterKey(e.key, env, aad); err == nil {
+			return content, nil
+		}
+	}
+	return nil, fmt.Errorf("no key in keyring decrypts object encrypted with key %q", env.KeyID)
+}
+
+// deriveObjectKey derives the per-object AES-256 key for the object bound to
+// aad (its trace ID, span ID, and attribute key).
+func deriveObjectKey(master, aad []byte) ([]byte, error) {
+	key := make([]byte, 32)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, master, nil, append([]byte("promptvault object key v1|"), aad...)), key); err != nil {
+		return nil, fmt.Errorf("derive object key: %w", err)
+	}
+	return key, nil
+}
+
+func openWithMasterKey(master []byte, env envelope, aad []byte) ([]byte, error) {
+	if len(env.WrappedKey) > 0 {
+		return openConvergent(master, env)
+	}
+	subKey, err := deriveObjectKey(master, aad)
+	if err != nil {
+		return nil, err
+	}
+	defer clear(subKey)
+	return openGCM(subKey, env.Nonce, env.Ciphertext, aad)
+}
+
+// sealConvergent encrypts deterministically: the content key is an HMAC of
+// the plaintext under the master key, and nonces are derived rather than
+// random, so equal plaintexts yield equal ciphertexts and deduplicate. The
+// content key is stored wrapped under the master key. Convergent objects
+// carry no span binding, since binding would make ciphertexts differ.
+func sealConvergent(master keyringEntry, plaintext []byte) ([]byte, error) {
+	contentKey := hmacSHA256(master.key, []byte("promptvault convergent key v1|"), plaintext)
+	defer clear(contentKey)
+	wrapNonce := hmacSHA256(master.key, []byte("promptvault convergent wrap v1|"), contentKey)[:gcmNonceSize]
+	wrapAEAD, err := newGCM(master.key)
+	if err != nil {
+		return nil, err
+	}
+	contentAEAD, err := newGCM(contentKey)
+	if err != nil {
+		return nil, err
+	}
+	// Each content key encrypts exactly one plaintext, so a fixed nonce is safe.
+	nonce := make([]byte, gcmNonceSize)
+	env := envelope{
+		KeyID:      master.id,
+		WrappedKey: wrapAEAD.Seal(wrapNonce, wrapNonce, contentKey, nil),
+		Nonce:      nonce,
+		Ciphertext: contentAEAD.Seal(nil, nonce, plaintext, nil),
+	}
+	return env.marshal(), nil
+}
+
+func openConvergent(master []byte, env envelope) ([]byte, error) {
+	if len(env.WrappedKey) < gcmNonceSize {
+		return nil, errMalformedEnvelope
+	}
+	contentKey, err := openGCM(master, env.WrappedKey[:gcmNonceSize], env.WrappedKey[gcmNonceSize:], nil)
+	if err != nil {
+		return nil, err
+	}
+	defer clear(contentKey)
+	return openGCM(contentKey, env.Nonce, env.Ciphertext, nil)
+}
+
+func hmacSHA256(key []byte, parts ...[]byte) []byte {
+	m := hmac.New(sha256.New, key)
+	for _, p := range parts {
+		m.Write(p)
+	}
+	return m.Sum(nil)
+}
@@ -0,0 +1,234 @@
+package promptvaultprocessor
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go.opentelemetry.io/collector/config/configopaque"
+)
+
+func testKey(b byte) string {
+	return base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{b}, 32))
+}
+
+func TestKeyringRotation(t *testing.T) {
+	ctx := context.Background()
+	file := filepath.Join(t.TempDir(), "keyring")
+	os.WriteFile(file, []byte("# current key first\n2024-q1 "+testKey(1)+"\n"), 0o600)
+
+	old, err := NewKeyring(LocalKeysConfig{KeyringFile: file})
+	if err != nil {
+		t.Fatalf("load keyring: %v", err)
+	}
+	sealed, keyID, err := old.Encrypt(ctx, []byte("written before rotation"), nil)
+	if err != nil || keyID != "2024-q1" {
+		t.Fatalf("encrypt: key %q, err %v", keyID, err)
+	}
+
+	// Rotate: a new active key from the environment, the old one kept for reads.
+	t.Setenv("PV_KEY_2024Q2", testKey(2))
+	rotated, err := NewKeyring(LocalKeysConfig{KeyringFile: file, EnvPrefix: "PV_KEY_", ActiveKey: "2024Q2"})
+	if err != nil {
+		t.Fatalf("load rotated keyring: %v", err)
+	}
+	if _, keyID, _ := rotated.Encrypt(ctx, []byte("new"), nil); keyID != "2024Q2" {
+		t.Errorf("expected active key 2024Q2, got %q", keyID)
+	}
+	data, err := rotated.Decrypt(ctx, sealed, nil)
+	if err != nil || string(data) != "written before rotation" {
+		t.Errorf("expected old object to decrypt, got %q, %v", data, err)
+	}
+}
+
+func TestKeyringTriesKeysInOrder(t *testing.T) {
+	ctx := context.Background()
+	// The key ID in the envelope is unknown to the reader, e.g. a key renamed
+	// in the keyring file; the matching key is still found by trial.
+	writer, _ := newKeyring([]keyringEntry{{id: "old-name", key: bytes.Repeat([]byte{7}, 32)}}, "")
+	reader, _ := newKeyring([]keyringEntry{
+		{id: "a", key: bytes.Repeat([]byte{1}, 32)},
+		{id: "new-name", key: bytes.Repeat([]byte{7}, 32)},
+	}, "")
+
+	sealed, _, _ := writer.Encrypt(ctx, []byte("secret"), nil)
+	data, err := reader.Decrypt(ctx, sealed, nil)
+	if err != nil || string(data) != "secret" {
+		t.Errorf("expected fallback decryption, got %q, %v", data, err)
+	}
+
+	other, _ := newKeyring([]keyringEntry{{id: "x", key: bytes.Repeat([]byte{9}, 32)}}, "")
+	if _, err := other.Decrypt(ctx, sealed, nil); err == nil {
+		t.Error("expected error when no key matches")
+	}
+}
+
+func TestKeyringRejectsBadKeys(t *testing.T) {
+	if _, err := parseKeyring([]byte("k1 " + base64.StdEncoding.EncodeToString([]byte("short")))); err == nil {
+		t.Error("expected error for short key")
+	}
+	if _, err := newKeyring(nil, ""); err == nil {
+		t.Error("expected error for empty keyring")
+	}
+	if _, err := newKeyring([]keyringEntry{{id: "a", key: make([]byte, 32)}}, "missing"); err == nil {
+		t.Error("expected error for unknown active key")
+	}
+}
+
+func TestKeyringDerivesPerObjectKeys(t *testing.T) {
+	master := bytes.Repeat([]byte{3}, 32)
+	a, _ := deriveObjectKey(master, bindingAAD(ObjectMeta{TraceID: "t1", SpanID: "s1", Key: "k"}))
+	b, _ := deriveObjectKey(master, bindingAAD(ObjectMeta{TraceID: "t1", SpanID: "s2", Key: "k"}))
+	if bytes.Equal(a, b) || bytes.Equal(a, master) {
+		t.Error("expected distinct sub-keys per object, never the master key")
+	}
+
+	// The master key alone must not open the object.
+	ring, _ := newKeyring([]keyringEntry{{id: "k1", key: master}}, "")
+	aad := bindingAAD(ObjectMeta{TraceID: "t1", SpanID: "s1", Key: "k"})
+	sealed, _, _ := ring.Encrypt(context.Background(), []byte("data"), aad)
+	env, _ := unmarshalEnvelope(sealed)
+	if _, err := openGCM(master, env.Nonce, env.Ciphertext, aad); err == nil {
+		t.Error("expected ciphertext to be sealed under a derived key")
+	}
+}
+
+func TestKeyringReloadsChangedFile(t *testing.T) {
+	ctx := context.Background()
+	file := filepath.Join(t.TempDir(), "keyring")
+	os.WriteFile(file, []byte("k1 "+testKey(1)+"\n"), 0o600)
+
+	ring, err := NewKeyring(LocalKeysConfig{KeyringFile: file})
+	if err != nil {
+		t.Fatalf("load keyring: %v", err)
+	}
+	if changed, err := ring.Reload(); changed || err != nil {
+		t.Errorf("expected no reload for unchanged file, got %v, %v", changed, err)
+	}
+	sealed, _, _ := ring.Encrypt(ctx, []byte("before"), nil)
+
+	// A mounted secret is updated in place with a new key first.
+	os.WriteFile(file, []byte("k2 "+testKey(2)+"\nk1 "+testKey(1)+"\n"), 0o600)
+	if changed, err := ring.Reload(); !changed || err != nil {
+		t.Fatalf("expected reload, got %v, %v", changed, err)
+	}
+	if _, keyID, _ := ring.Encrypt(ctx, []byte("after"), nil); keyID != "k2" {
+		t.Errorf("expected new active key k2, got %q", keyID)
+	}
+	if data, err := ring.Decrypt(ctx, sealed, nil); err != nil || string(data) != "before" {
+		t.Errorf("expected old object to stay readable, got %q, %v", data, err)
+	}
+
+	// A broken file keeps the current keys.
+	os.WriteFile(file, []byte("garbage"), 0o600)
+	if _, err := ring.Reload(); err == nil {
+		t.Error("expected error for invalid keyring")
+	}
+	if _, keyID, _ := ring.Encrypt(ctx, []byte("still"), nil); keyID != "k2" {
+		t.Errorf("expected keys to be kept after failed reload, got %q", keyID)
+	}
+}
+
+func TestKeySourceSelectsLocalKeys(t *testing.T) {
+	ctx := context.Background()
+	file := filepath.Join(t.TempDir(), "keyring")
+	os.WriteFile(file, []byte("from-file "+testKey(1)+"\n"), 0o600)
+	t.Setenv("PV_TEST_KEY_fromenv", testKey(2))
+	local := LocalKeysConfig{KeyringFile: file, EnvPrefix: "PV_TEST_KEY_"}
+
+	enc, err := newEncryptor(ctx, CryptoConfig{Provider: "local", KeySource: "env", Local: local})
+	if err != nil {
+		t.Fatalf("env key source: %v", err)
+	}
+	if _, keyID, _ := enc.Encrypt(ctx, nil, nil); keyID != "fromenv" {
+		t.Errorf("expected env key, got %q", keyID)
+	}
+
+	enc, err = newEncryptor(ctx, CryptoConfig{Provider: "local", KeySource: "file", Local: local})
+	if err != nil {
+		t.Fatalf("file key source: %v", err)
+	}
+	if _, keyID, _ := enc.Encrypt(ctx, nil, nil); keyID != "from-file" {
+		t.Errorf("expected file key, got %q", keyID)
+	}
+
+	if _, err := newEncryptor(ctx, CryptoConfig{Provider: "local", KeySource: "file", Local: LocalKeysConfig{EnvPrefix: "X_"}}); err == nil {
+		t.Error("expected error for file key source without keyring_file")
+	}
+}
+
+func TestKeyringLoadsConfigKeys(t *testing.T) {
+	ctx := context.Background()
+	// Values as a ${file:...} provider would resolve them, trailing newline included.
+	local := LocalKeysConfig{
+		Keys: []KeyConfig{
+			{ID: "k2", Key: configopaque.String(testKey(2) + "\n")},
+			{ID: "k1", Key: configopaque.String(testKey(1))},
+		},
+		EnvPrefix: "PV_TEST_UNUSED_",
+	}
+	t.Setenv("PV_TEST_UNUSED_env", testKey(3))
+
+	enc, err := newEncryptor(ctx, CryptoConfig{Provider: "local", KeySource: "config", Local: local})
+	if err != nil {
+		t.Fatal(err)
+	}
+	k := enc.(*Keyring)
+	if k.ActiveKeyID() != "k2" || len(k.keys) != 2 {
+		t.Errorf("active %q with %d keys, want k2 of 2 config keys", k.ActiveKeyID(), len(k.keys))
+	}
+
+	bad := LocalKeysConfig{Keys: []KeyConfig{{ID: "k", Key: "bm90LWEta2V5LXNlY3JldA=="}}}
+	if _, err := NewKeyring(bad); err == nil {
+		t.Error("expected error for a key of the wrong length")
+	}
+	if _, err := NewKeyring(LocalKeysConfig{Keys: []KeyConfig{{Key: configopaque.String(testKey(1))}}}); err == nil {
+		t.Error("expected error for a key without an id")
+	}
+	if _, err := newEncryptor(ctx, CryptoConfig{Provider: "local", KeySource: "config"}); err == nil {
+		t.Error("expected error for config key source without keys")
+	}
+}
+
+func TestKeyringConvergentDeduplicates(t *testing.T) {
+	ctx := context.Background()
+	file := filepath.Join(t.TempDir(), "keyring")
+	os.WriteFile(file, []byte("k1 "+testKey(1)+"\n"), 0o600)
+	ring, err := NewKeyring(LocalKeysConfig{KeyringFile: file, Convergent: true})
+	if err != nil {
+		t.Fatalf("load keyring: %v", err)
+	}
+
+	backend := NewMemoryVault(0, 0)
+	vault := &encryptingVault{VaultStorage: backend, enc: ring}
+	ref1, _ := vault.Store(ctx, ObjectMeta{TraceID: "t1", SpanID: "s1"}, []byte("same system prompt"))
+	ref2, _ := vault.Store(ctx, ObjectMeta{TraceID: "t2", SpanID: "s2"}, []byte("same system prompt"))
+	ref3, _ := vault.Store(ctx, ObjectMeta{TraceID: "t3", SpanID: "s3"}, []byte("different prompt"))
+	if ref1 != ref2 || ref1 == ref3 {
+		t.Errorf("expected identical plaintexts to share a ref: %s %s %s", ref1, ref2, ref3)
+	}
+	if backend.Len() != 2 {
+		t.Errorf("expected 2 stored objects, got %d", backend.Len())
+	}
+
+	sealed, _ := backend.Retrieve(ctx, ref1)
+	if bytes.Contains(sealed, []byte("same system prompt")) {
+		t.Error("expected convergent object to be encrypted")
+	}
+	if data, err := vault.Retrieve(ctx, ref2); err != nil || string(data) != "same system prompt" {
+		t.Errorf("expected round trip, got %q, %v", data, err)
+	}
+
+	// A keyring with a different master key cannot open the object.
+	other, _ := newKeyring([]keyringEntry{{id: "k1", key: bytes.Repeat([]byte{2}, 32)}}, "")
+	if _, err := other.Decrypt(ctx, sealed, nil); err == nil {
+		t.Error("expected decryption with the wrong master key to fail")
+	}
+
+	if _, err := newEncryptor(ctx, CryptoConfig{Provider: "age", Local: LocalKeysConfig{Convergent: true}}); err == nil {
+		t.Error("expected convergent mode to be rejected for other providers")
+	}
+}
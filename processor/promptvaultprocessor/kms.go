@@ -0,0 +1,168 @@
+//go:build !promptvault_no_aws
+
+package promptvaultprocessor
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	"github.com/aws/aws-sdk-go-v2/service/kms/types"
+)
+
+// kmsAPI is the subset of the KMS client used for envelope encryption.
+type kmsAPI interface {
+	GenerateDataKey(ctx context.Context, in *kms.GenerateDataKeyInput, optFns ...func(*kms.Options)) (*kms.GenerateDataKeyOutput, error)
+	Decrypt(ctx context.Context, in *kms.DecryptInput, optFns ...func(*kms.Options)) (*kms.DecryptOutput, error)
+}
+
+// dataKeyMaxUses bounds how many objects share a cached data key, keeping
+// random-nonce collision probability negligible.
+const dataKeyMaxUses = 1 << 24
+
+// KMSEncryptor implements envelope encryption with AWS KMS: each object is
+// sealed with AES-256-GCM under a data key from GenerateDataKey, and the
+// KMS-wrapped data key plus the KMS key ARN are stored in the object header.
+type KMSEncryptor struct {
+	client kmsAPI
+	keyID  string
+	reuse  time.Duration
+	now    func() time.Time
+
+	mu     sync.Mutex
+	cached *cachedDataKey
+}
+
+type cachedDataKey struct {
+	plaintext []byte
+	wrapped   []byte
+	keyARN    string
+	expires   time.Time
+	uses      int
+}
+
+// NewKMSEncryptor creates a KMS client from the default AWS credential chain.
+func NewKMSEncryptor(ctx context.Context, cfg AWSKMSConfig) (*KMSEncryptor, error) {
+	if cfg.KeyID == "" {
+		return nil, fmt.Errorf("crypto.aws_kms.key_id is required")
+	}
+
+	var opts []func(*awsconfig.LoadOptions) error
+	if cfg.Region != "" {
+		opts = append(opts, awsconfig.WithRegion(cfg.Region))
+	}
+	awsCfg, err := awsconfig.LoadDefaultConfig(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("load aws config: %w", err)
+	}
+	client := kms.NewFromConfig(awsCfg, func(o *kms.Options) {
+		if cfg.Endpoint != "" {
+			o.BaseEndpoint = aws.String(cfg.Endpoint)
+		}
+	})
+
+	return newKMSEncryptor(client, cfg), nil
+}
+
+// openKMSEncryptor backs crypto.provider aws_kms; builds tagged
+// promptvault_no_aws leave the AWS SDK out and replace it with a stub.
+func openKMSEncryptor(ctx context.Context, cfg AWSKMSConfig) (Encryptor, error) {
+	return NewKMSEncryptor(ctx, cfg)
+}
+
+func newKMSEncryptor(client kmsAPI, cfg AWSKMSConfig) *KMSEncryptor {
+	return &KMSEncryptor{
+		client: client,
+		keyID:  cfg.KeyID,
+		reuse:  cfg.DataKeyReuse,
+		now:    time.Now,
+	}
+}
+
+// Encrypt seals plaintext under a fresh (or cached) data key.
+func (e *KMSEncryptor) Encrypt(ctx context.Context, plaintext, aad []byte) ([]byte, string, error) {
+	dk, err := e.dataKey(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+	if e.reuse <= 0 {
+		defer clear(dk.plaintext)
+	}
+	nonce, ciphertext, err := sealGCM(dk.plaintext, plaintext, aad)
+	if err != nil {
+		return nil, "", err
+	}
+	env := envelope{
+		KeyID:      dk.keyARN,
+		WrappedKey: dk.wrapped,
+		AAD:        aad,
+		Nonce:      nonce,
+		Ciphertext: ciphertext,
+	}
+	return env.marshal(), dk.keyARN, nil
+}
+
+// Decrypt unwraps the data key through KMS and opens the ciphertext.
+func (e *KMSEncryptor) Decrypt(ctx context.Context, data, aad []byte) ([]byte, error) {
+	env, err := unmarshalEnvelope(data)
+	if err != nil {
+		return nil, err
+	}
+	out, err := e.client.Decrypt(ctx, &kms.DecryptInput{
+		CiphertextBlob: env.WrappedKey,
+		KeyId:          aws.String(env.KeyID),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("kms decrypt data key: %w", err)
+	}
+	defer clear(out.Plaintext)
+	return openGCM(out.Plaintext, env.Nonce, env.Ciphertext, aad)
+}
+
+// Close zeroes the cached data key.
+func (e *KMSEncryptor) Close() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.cached != nil {
+		clear(e.cached.plaintext)
+		e.cached = nil
+	}
+	return nil
+}
+
+// dataKey returns a data key for one object. With reuse disabled every call
+// asks KMS for a new key; otherwise a key is shared until it expires or hits
+// dataKeyMaxUses.
+func (e *KMSEncryptor) dataKey(ctx context.Context) (*cachedDataKey, error) {
+	if e.reuse > 0 {
+		e.mu.Lock()
+		defer e.mu.Unlock()
+		if c := e.cached; c != nil && e.now().Before(c.expires) && c.uses < dataKeyMaxUses {
+			c.uses++
+			return c, nil
+		}
+	}
+
+	out, err := e.client.GenerateDataKey(ctx, &kms.GenerateDataKeyInput{
+		KeyId:   aws.String(e.keyID),
+		KeySpec: types.DataKeySpecAes256,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("kms generate data key: %w", err)
+	}
+	dk := &cachedDataKey{
+		plaintext: out.Plaintext,
+		wrapped:   out.CiphertextBlob,
+		keyARN:    aws.ToString(out.KeyId),
+		expires:   e.now().Add(e.reuse),
+		uses:      1,
+	}
+	if e.reuse > 0 {
+		e.cached = dk
+	}
+	return dk, nil
+}
@@ -0,0 +1,181 @@
+//go:build !promptvault_no_aws
+
+package promptvaultprocessor
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+)
+
+const testKeyARN = "arn:aws:kms:us-east-1:111122223333:key/test"
+
+// fakeKMS "wraps" data keys by remembering them under a random blob. Like
+// the real client it returns fresh buffers, which the encryptor zeroes.
+type fakeKMS struct {
+	keys      map[string][]byte
+	generated int
+}
+
+func (f *fakeKMS) GenerateDataKey(_ context.Context, in *kms.GenerateDataKeyInput, _ ...func(*kms.Options)) (*kms.GenerateDataKeyOutput, error) {
+	f.generated++
+	key := make([]byte, 32)
+	blob := make([]byte, 16)
+	rand.Read(key)
+	rand.Read(blob)
+	f.keys[string(blob)] = key
+	return &kms.GenerateDataKeyOutput{Plaintext: bytes.Clone(key), CiphertextBlob: blob, KeyId: aws.String(testKeyARN)}, nil
+}
+
+func (f *fakeKMS) Decrypt(_ context.Context, in *kms.DecryptInput, _ ...func(*kms.Options)) (*kms.DecryptOutput, error) {
+	key, ok := f.keys[string(in.CiphertextBlob)]
+	if !ok || aws.ToString(in.KeyId) != testKeyARN {
+		return nil, errors.New("invalid ciphertext")
+	}
+	return &kms.DecryptOutput{Plaintext: bytes.Clone(key)}, nil
+}
+
+func TestKMSEnvelopeEncryption(t *testing.T) {
+	ctx := context.Background()
+	fake := &fakeKMS{keys: map[string][]byte{}}
+	backend := NewMemoryVault(0, 0)
+	vault := &encryptingVault{VaultStorage: backend, enc: newKMSEncryptor(fake, AWSKMSConfig{KeyID: "alias/vault"})}
+
+	plaintext := []byte("my SSN is 123-45-6789")
+	ref, err := vault.Store(ctx, ObjectMeta{}, plaintext)
+	if err != nil {
+		t.Fatalf("store failed: %v", err)
+	}
+
+	stored, _ := backend.Retrieve(ctx, ref)
+	if bytes.Contains(stored, plaintext) {
+		t.Error("expected backend to hold ciphertext only")
+	}
+	env, err := unmarshalEnvelope(stored)
+	if err != nil {
+		t.Fatalf("expected envelope header: %v", err)
+	}
+	if env.KeyID != testKeyARN {
+		t.Errorf("expected key ARN in envelope, got %q", env.KeyID)
+	}
+
+	data, err := vault.Retrieve(ctx, ref)
+	if err != nil {
+		t.Fatalf("retrieve failed: %v", err)
+	}
+	if !bytes.Equal(data, plaintext) {
+		t.Errorf("expected %q, got %q", plaintext, data)
+	}
+
+	vault.Store(ctx, ObjectMeta{}, []byte("second object"))
+	if fake.generated != 2 {
+		t.Errorf("expected a data key per object, got %d GenerateDataKey calls", fake.generated)
+	}
+}
+
+func TestKMSDataKeyReuse(t *testing.T) {
+	ctx := context.Background()
+	fake := &fakeKMS{keys: map[string][]byte{}}
+	enc := newKMSEncryptor(fake, AWSKMSConfig{KeyID: "alias/vault", DataKeyReuse: 5 * time.Minute})
+
+	for i := 0; i < 3; i++ {
+		if _, _, err := enc.Encrypt(ctx, []byte("cached key"), nil); err != nil {
+			t.Fatalf("encrypt failed: %v", err)
+		}
+	}
+	if fake.generated != 1 {
+		t.Errorf("expected cached data key to be reused, got %d GenerateDataKey calls", fake.generated)
+	}
+}
+
+func TestEnvelopeRejectsGarbage(t *testing.T) {
+	for _, data := range [][]byte{nil, []byte("PVE1"), []byte("plain text content")} {
+		if _, err := unmarshalEnvelope(data); err == nil {
+			t.Errorf("expected error for %q", data)
+		}
+	}
+}
+
+func TestEncryptionBindsObjectToSpan(t *testing.T) {
+	ctx := context.Background()
+	keyring, _ := newKeyring([]keyringEntry{{id: "k1", key: bytes.Repeat([]byte{1}, 32)}}, "")
+	backend := NewMemoryVault(0, 0)
+	vault := &encryptingVault{VaultStorage: backend, enc: keyring}
+
+	meta := ObjectMeta{TraceID: "t1", SpanID: "s1", Key: "gen_ai.prompt"}
+	ref, err := vault.Store(ctx, meta, []byte("prompt for span s1"))
+	if err != nil {
+		t.Fatalf("store failed: %v", err)
+	}
+	if data, err := vault.RetrieveFor(ctx, ref, meta); err != nil || string(data) != "prompt for span s1" {
+		t.Fatalf("expected bound retrieve to succeed, got %q, %v", data, err)
+	}
+	if _, err := vault.Retrieve(ctx, ref); err != nil {
+		t.Fatalf("expected retrieve with header binding to succeed: %v", err)
+	}
+
+	other := meta
+	other.SpanID = "s2"
+	if _, err := vault.RetrieveFor(ctx, ref, other); err == nil {
+		t.Error("expected ciphertext to be rejected for a different span")
+	}
+
+	// Tampering with the binding in the header breaks authentication.
+	sealed, _ := backend.Retrieve(ctx, ref)
+	env, _ := unmarshalEnvelope(sealed)
+	env.AAD = bindingAAD(other)
+	if _, err := keyring.Decrypt(ctx, env.marshal(), env.AAD); err == nil {
+		t.Error("expected rewritten binding to fail authentication")
+	}
+}
+
+func TestEncryptionRejectsObjectCopiedToAnotherPath(t *testing.T) {
+	ctx := context.Background()
+	tmpl := "{trace_id}/{span_id}"
+	keyring, _ := newKeyring([]keyringEntry{{id: "k1", key: bytes.Repeat([]byte{1}, 32)}}, "")
+	fs, err := NewFilesystemVault(FilesystemConfig{BasePath: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fs.pathTemplate = tmpl
+	vault := &encryptingVault{VaultStorage: fs, enc: keyring, pathTemplate: tmpl}
+
+	ref, err := vault.Store(ctx, ObjectMeta{TraceID: "t1", SpanID: "s1"}, []byte("victim"))
+	if err != nil {
+		t.Fatalf("store failed: %v", err)
+	}
+	sealed, _ := fs.Retrieve(ctx, ref)
+
+	// Copy the ciphertext under another span's directory, keeping its hash.
+	hash := hashFromRef(ref)
+	dst := filepath.Join(fs.basePath, "t1", "s2")
+	os.MkdirAll(dst, 0o755)
+	os.WriteFile(filepath.Join(dst, hash), sealed, 0o600)
+
+	if _, err := vault.Retrieve(ctx, refScheme+"t1/s2/"+hash); err == nil {
+		t.Error("expected copied object to fail authentication")
+	}
+	if _, err := vault.Retrieve(ctx, ref); err != nil {
+		t.Errorf("expected original object to decrypt: %v", err)
+	}
+}
+
+func TestKMSCloseZeroesCachedDataKey(t *testing.T) {
+	enc := newKMSEncryptor(&fakeKMS{keys: map[string][]byte{}}, AWSKMSConfig{KeyID: "alias/vault", DataKeyReuse: time.Minute})
+	if _, _, err := enc.Encrypt(context.Background(), []byte("x"), nil); err != nil {
+		t.Fatal(err)
+	}
+	cached := enc.cached.plaintext
+	enc.Close()
+	if !bytes.Equal(cached, make([]byte, len(cached))) || enc.cached != nil {
+		t.Error("cached data key not zeroed on Close")
+	}
+}
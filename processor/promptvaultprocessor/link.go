@@ -0,0 +1,51 @@
+package promptvaultprocessor
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// linkTemplatePlaceholders lists what vault.link_template may reference.
+// Values are query-escaped, except {ref}, which is inserted as is.
+var linkTemplatePlaceholders = []string{"{uri}", "{ref}", "{trace_id}", "{span_id}", "{key}"}
+
+// validateLinkTemplate rejects templates with unknown placeholders.
+func validateLinkTemplate(tmpl string) error {
+	rest := tmpl
+	for _, p := range linkTemplatePlaceholders {
+		rest = strings.ReplaceAll(rest, p, "")
+	}
+	if strings.ContainsAny(rest, "{}") {
+		return fmt.Errorf("vault.link_template %q has an unknown placeholder; supported: %s",
+			tmpl, strings.Join(linkTemplatePlaceholders, " "))
+	}
+	return nil
+}
+
+// validateRefFormat checks vault.ref_format against vault.link_template.
+// Links written as references must carry the reference.
+func validateRefFormat(cfg VaultConfig) error {
+	switch cfg.RefFormat {
+	case "", "uri":
+		return nil
+	case "link":
+		if !strings.Contains(cfg.LinkTemplate, "{uri}") && !strings.Contains(cfg.LinkTemplate, "{ref}") {
+			return fmt.Errorf("vault.ref_format link needs a link_template containing {uri} or {ref}")
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown vault.ref_format %q", cfg.RefFormat)
+	}
+}
+
+// expandLinkTemplate returns the link to ref, stored for meta.
+func expandLinkTemplate(tmpl, ref string, meta ObjectMeta) string {
+	return strings.NewReplacer(
+		"{uri}", url.QueryEscape(ref),
+		"{ref}", ref,
+		"{trace_id}", url.QueryEscape(meta.TraceID),
+		"{span_id}", url.QueryEscape(meta.SpanID),
+		"{key}", url.QueryEscape(meta.Key),
+	).Replace(tmpl)
+}
@@ -0,0 +1,85 @@
+package promptvaultprocessor
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.uber.org/zap"
+)
+
+func TestProcessorWritesVaultURL(t *testing.T) {
+	cfg := createDefaultConfig()
+	cfg.Vault.LinkTemplate = "https://vault.internal/view?uri={uri}&trace={trace_id}"
+	proc := newVaultProcessor(zap.NewNop(), cfg, NewMemoryVault(0, 0), new(consumertest.TracesSink))
+
+	td := ptrace.NewTraces()
+	span := td.ResourceSpans().AppendEmpty().ScopeSpans().AppendEmpty().Spans().AppendEmpty()
+	span.SetTraceID([16]byte{1})
+	span.Attributes().PutStr("gen_ai.prompt", "Tell me about quantum computing")
+	if err := proc.ConsumeTraces(context.Background(), td); err != nil {
+		t.Fatal(err)
+	}
+
+	ref, _ := span.Attributes().Get("gen_ai.prompt.vault_ref")
+	link, ok := span.Attributes().Get("gen_ai.prompt.vault_url")
+	want := "https://vault.internal/view?uri=vault%3A%2F%2F" + ref.Str()[len("vault://"):] +
+		"&trace=01000000000000000000000000000000"
+	if !ok || link.Str() != want {
+		t.Errorf("vault_url = %q, want %q", link.Str(), want)
+	}
+}
+
+func TestValidateLinkTemplate(t *testing.T) {
+	if err := validateLinkTemplate("https://vault.internal/ui/?trace_id={trace_id}#{ref}"); err != nil {
+		t.Error(err)
+	}
+	if err := validateLinkTemplate("https://vault.internal/view?uri={url}"); err == nil {
+		t.Error("expected error for unknown placeholder")
+	}
+}
+
+func TestProcessorWritesLinkRefs(t *testing.T) {
+	cfg := createDefaultConfig()
+	cfg.Vault.LinkTemplate = "https://vault.internal/view?uri={uri}"
+	cfg.Vault.RefFormat = "link"
+	vault := NewMemoryVault(0, 0)
+	proc := newVaultProcessor(zap.NewNop(), cfg, vault, new(consumertest.TracesSink))
+
+	td := ptrace.NewTraces()
+	span := td.ResourceSpans().AppendEmpty().ScopeSpans().AppendEmpty().Spans().AppendEmpty()
+	span.Attributes().PutStr("gen_ai.prompt", "Tell me about quantum computing")
+	if err := proc.ConsumeTraces(context.Background(), td); err != nil {
+		t.Fatal(err)
+	}
+
+	ref, _ := span.Attributes().Get("gen_ai.prompt.vault_ref")
+	if !strings.HasPrefix(ref.Str(), "https://vault.internal/view?uri=vault%3A%2F%2F") {
+		t.Fatalf("vault_ref = %q, want a link", ref.Str())
+	}
+	if v, _ := span.Attributes().Get("gen_ai.prompt"); v.Str() != ref.Str() {
+		t.Errorf("gen_ai.prompt = %q, want the link", v.Str())
+	}
+	if _, ok := span.Attributes().Get("gen_ai.prompt.vault_url"); ok {
+		t.Error("vault_url written alongside a link reference")
+	}
+	u, _ := url.Parse(ref.Str())
+	if got, err := vault.Retrieve(context.Background(), u.Query().Get("uri")); err != nil || string(got) != "Tell me about quantum computing" {
+		t.Errorf("retrieve = %q, %v", got, err)
+	}
+}
+
+func TestValidateRefFormat(t *testing.T) {
+	if err := validateRefFormat(VaultConfig{RefFormat: "link", LinkTemplate: "https://vault.internal/objects/{ref}"}); err != nil {
+		t.Error(err)
+	}
+	if err := validateRefFormat(VaultConfig{RefFormat: "link", LinkTemplate: "https://vault.internal/ui/?trace_id={trace_id}"}); err == nil {
+		t.Error("expected error for a template without the reference")
+	}
+	if err := validateRefFormat(VaultConfig{RefFormat: "url"}); err == nil {
+		t.Error("expected error for unknown format")
+	}
+}
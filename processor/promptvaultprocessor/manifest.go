@@ -0,0 +1,488 @@
+package promptvaultprocessor
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/collector/config/configopaque"
+)
+
+// Manifest operations.
+const (
+	manifestStore   = "store"
+	manifestReplace = "replace"
+	manifestDelete  = "delete"
+)
+
+// ManifestEntry is one line of a manifest file. Prev is the SHA-256 of the
+// previous entry's line, chaining entries across days, and Sig signs the
+// entry with Sig empty. Removing, reordering, or editing an entry breaks
+// the chain.
+type ManifestEntry struct {
+	Seq      uint64    `json:"seq"`
+	Time     time.Time `json:"time"`
+	Op       string    `json:"op"`
+	Ref      string    `json:"ref"`
+	Checksum string    `json:"sha256,omitempty"`
+	Size     int       `json:"size,omitempty"`
+	Prev     string    `json:"prev"`
+	Sig      string    `json:"sig,omitempty"`
+}
+
+// ManifestKey signs and verifies manifest entries.
+type ManifestKey interface {
+	Sign(msg []byte) (string, error)
+	Verify(msg []byte, sig string) error
+}
+
+var errManifestSignature = errors.New("invalid manifest signature")
+
+// NewHMACManifestKey signs entries with HMAC-SHA256 under secret.
+func NewHMACManifestKey(secret configopaque.String) (ManifestKey, error) {
+	if len(secret) < minSigningKeyLen {
+		return nil, fmt.Errorf("manifest hmac key must be at least %d bytes", minSigningKeyLen)
+	}
+	return hmacManifestKey(secret), nil
+}
+
+type hmacManifestKey []byte
+
+func (k hmacManifestKey) Sign(msg []byte) (string, error) {
+	return base64.RawURLEncoding.EncodeToString(hmacSHA256(k, msg)), nil
+}
+
+func (k hmacManifestKey) Verify(msg []byte, sig string) error {
+	got, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil || !hmac.Equal(got, hmacSHA256(k, msg)) {
+		return errManifestSignature
+	}
+	return nil
+}
+
+// LoadEd25519ManifestKey reads a PEM PKCS#8 private key (to sign) or PKIX
+// public key (to verify only).
+func LoadEd25519ManifestKey(path string) (ManifestKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read manifest key: %w", err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("manifest key %s: no PEM block", path)
+	}
+	switch block.Type {
+	case "PRIVATE KEY":
+		k, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if priv, ok := k.(ed25519.PrivateKey); err == nil && ok {
+			return ed25519ManifestKey{priv: priv, pub: priv.Public().(ed25519.PublicKey)}, nil
+		}
+	case "PUBLIC KEY":
+		k, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if pub, ok := k.(ed25519.PublicKey); err == nil && ok {
+			return ed25519ManifestKey{pub: pub}, nil
+		}
+	}
+	return nil, fmt.Errorf("manifest key %s is not an Ed25519 PKCS#8 private or PKIX public key", path)
+}
+
+type ed25519ManifestKey struct {
+	priv ed25519.PrivateKey
+	pub  ed25519.PublicKey
+}
+
+func (k ed25519ManifestKey) Sign(msg []byte) (string, error) {
+	if k.priv == nil {
+		return "", fmt.Errorf("manifest key has no private key")
+	}
+	return base64.RawURLEncoding.EncodeToString(ed25519.Sign(k.priv, msg)), nil
+}
+
+func (k ed25519ManifestKey) Verify(msg []byte, sig string) error {
+	raw, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil || !ed25519.Verify(k.pub, msg, raw) {
+		return errManifestSignature
+	}
+	return nil
+}
+
+// newManifestKey selects the key for cfg.Signing.
+func newManifestKey(cfg ManifestConfig, hmacSecret configopaque.String) (ManifestKey, error) {
+	switch cfg.Signing {
+	case "", "hmac":
+		if hmacSecret == "" {
+			return nil, fmt.Errorf("storage.manifest signing hmac requires vault.signing_key")
+		}
+		return NewHMACManifestKey(hmacSecret)
+	case "ed25519":
+		return LoadEd25519ManifestKey(cfg.PrivateKeyFile)
+	default:
+		return nil, fmt.Errorf("unknown storage.manifest.signing %q", cfg.Signing)
+	}
+}
+
+// manifestVault records every change to the wrapped backend in a manifest.
+// It wraps the backend directly, so checksums cover the bytes as stored.
+type manifestVault struct {
+	VaultStorage
+	dir string
+	key ManifestKey
+	now func() time.Time
+
+	mu   sync.Mutex
+	seq  uint64
+	prev string
+}
+
+// newManifestVault wraps vault when cfg.Dir is set, resuming the chain from
+// the newest manifest file.
+func newManifestVault(vault VaultStorage, cfg ManifestConfig, hmacSecret configopaque.String) (VaultStorage, error) {
+	if cfg.Dir == "" {
+		return vault, nil
+	}
+	key, err := newManifestKey(cfg, hmacSecret)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(cfg.Dir, 0o700); err != nil {
+		return nil, fmt.Errorf("create manifest dir: %w", err)
+	}
+	v := &manifestVault{VaultStorage: vault, dir: cfg.Dir, key: key, now: time.Now}
+	files, err := manifestFiles(cfg.Dir)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) > 0 {
+		line, err := lastLine(files[len(files)-1])
+		if err != nil {
+			return nil, err
+		}
+		if line != nil {
+			var last ManifestEntry
+			if err := json.Unmarshal(line, &last); err != nil {
+				return nil, fmt.Errorf("resume manifest: %w", err)
+			}
+			v.seq, v.prev = last.Seq, lineHash(line)
+		}
+	}
+	return v, nil
+}
+
+func (v *manifestVault) Store(ctx context.Context, meta ObjectMeta, content []byte) (string, error) {
+	ref, err := v.VaultStorage.Store(ctx, meta, content)
+	if err != nil {
+		return "", err
+	}
+	if err := v.append(manifestStore, ref, content); err != nil {
+		return "", err
+	}
+	return ref, nil
+}
+
+func (v *manifestVault) Replace(ctx context.Context, ref string, content []byte) error {
+	r, ok := v.VaultStorage.(VaultReplacer)
+	if !ok {
+		return fmt.Errorf("replace: %w", errors.ErrUnsupported)
+	}
+	if err := r.Replace(ctx, ref, content); err != nil {
+		return err
+	}
+	return v.append(manifestReplace, ref, content)
+}
+
+func (v *manifestVault) Delete(ctx context.Context, ref string) error {
+	d, ok := v.VaultStorage.(VaultDeleter)
+	if !ok {
+		return fmt.Errorf("delete: %w", errors.ErrUnsupported)
+	}
+	if err := d.Delete(ctx, ref); err != nil {
+		return err
+	}
+	return v.append(manifestDelete, ref, nil)
+}
+
+func (v *manifestVault) List(ctx context.Context, fn func(ObjectInfo) error) error {
+	if l, ok := v.VaultStorage.(VaultLister); ok {
+		return l.List(ctx, fn)
+	}
+	return fmt.Errorf("list: %w", errors.ErrUnsupported)
+}
+
+func (v *manifestVault) Stat(ctx context.Context, ref string) (ObjectInfo, error) {
+	if s, ok := v.VaultStorage.(VaultStatter); ok {
+		return s.Stat(ctx, ref)
+	}
+	return ObjectInfo{}, fmt.Errorf("stat: %w", errors.ErrUnsupported)
+}
+
+func (v *manifestVault) Search(ctx context.Context, f ObjectFilter, fn func(ObjectInfo) error) error {
+	if s, ok := v.VaultStorage.(VaultSearcher); ok {
+		return s.Search(ctx, f, fn)
+	}
+	return fmt.Errorf("search: %w", errors.ErrUnsupported)
+}
+
+func (v *manifestVault) Compact(ctx context.Context) (CompactionStats, error) {
+	if c, ok := v.VaultStorage.(VaultCompactor); ok {
+		return c.Compact(ctx)
+	}
+	return CompactionStats{}, fmt.Errorf("compact: %w", errors.ErrUnsupported)
+}
+
+func (v *manifestVault) Usage(ctx context.Context) ([]TenantUsage, error) {
+	if u, ok := v.VaultStorage.(VaultUsageReporter); ok {
+		return u.Usage(ctx)
+	}
+	return nil, fmt.Errorf("usage: %w", errors.ErrUnsupported)
+}
+
+func (v *manifestVault) Stats(ctx context.Context) (VaultStats, error) {
+	if r, ok := v.VaultStorage.(VaultStatsReporter); ok {
+		return r.Stats(ctx)
+	}
+	return VaultStats{}, fmt.Errorf("stats: %w", errors.ErrUnsupported)
+}
+
+func (v *manifestVault) Start(ctx context.Context) error {
+	if s, ok := v.VaultStorage.(vaultStarter); ok {
+		return s.Start(ctx)
+	}
+	return nil
+}
+
+func (v *manifestVault) Close() error {
+	switch k := v.key.(type) {
+	case hmacManifestKey:
+		clear(k)
+	case ed25519ManifestKey:
+		clear(k.priv)
+	}
+	if c, ok := v.VaultStorage.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+func (v *manifestVault) append(op, ref string, content []byte) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	e := ManifestEntry{Seq: v.seq + 1, Time: v.now().UTC(), Op: op, Ref: ref, Prev: v.prev}
+	if content != nil {
+		e.Checksum, e.Size = contentHash(content), len(content)
+	}
+	line, err := signManifestEntry(e, v.key)
+	if err != nil {
+		return err
+	}
+
+	path := filepath.Join(v.dir, "manifest-"+e.Time.Format("2006-01-02")+".jsonl")
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return fmt.Errorf("open manifest: %w", err)
+	}
+	defer f.Close()
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("append manifest: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		return fmt.Errorf("append manifest: %w", err)
+	}
+	v.seq, v.prev = e.Seq, lineHash(line)
+	return nil
+}
+
+func signManifestEntry(e ManifestEntry, key ManifestKey) ([]byte, error) {
+	e.Sig = ""
+	body, err := json.Marshal(e)
+	if err != nil {
+		return nil, err
+	}
+	if e.Sig, err = key.Sign(body); err != nil {
+		return nil, err
+	}
+	return json.Marshal(e)
+}
+
+// ManifestReport summarizes a manifest verification.
+type ManifestReport struct {
+	Entries int
+	Files   int
+	// LastSeq and Head identify the newest entry. The chain cannot show
+	// that trailing entries were dropped, so auditors should record Head
+	// and check later reports still contain it.
+	LastSeq uint64
+	Head    string
+	// Missing lists refs the manifest says are live that the backend no
+	// longer returns; Mismatched lists refs whose content has changed.
+	Missing    []string
+	Mismatched []string
+}
+
+// VerifyManifest checks every manifest file in dir: signatures, sequence
+// numbers, and the hash chain, failing at the first break. If vault is not
+// nil, each live object is also retrieved and compared with its last
+// recorded checksum. vault must be the raw backend, without decryption or
+// decompression.
+func VerifyManifest(ctx context.Context, dir string, key ManifestKey, vault VaultStorage) (ManifestReport, error) {
+	var report ManifestReport
+	files, err := manifestFiles(dir)
+	if err != nil {
+		return report, err
+	}
+	report.Files = len(files)
+
+	live := make(map[string]string) // ref -> checksum
+	var order []string
+	var seq uint64
+	var prev string
+	for _, path := range files {
+		f, err := os.Open(path)
+		if err != nil {
+			return report, err
+		}
+		sc := bufio.NewScanner(f)
+		sc.Buffer(nil, 1<<20)
+		for sc.Scan() {
+			line := sc.Bytes()
+			var e ManifestEntry
+			if err := json.Unmarshal(line, &e); err != nil {
+				f.Close()
+				return report, fmt.Errorf("%s: entry after seq %d: %w", path, seq, err)
+			}
+			if e.Seq != seq+1 {
+				f.Close()
+				return report, fmt.Errorf("%s: seq %d follows %d: entries missing", path, e.Seq, seq)
+			}
+			if e.Prev != prev {
+				f.Close()
+				return report, fmt.Errorf("%s: seq %d: hash chain broken", path, e.Seq)
+			}
+			sig := e.Sig
+			e.Sig = ""
+			body, _ := json.Marshal(e)
+			if err := key.Verify(body, sig); err != nil {
+				f.Close()
+				return report, fmt.Errorf("%s: seq %d: %w", path, e.Seq, err)
+			}
+
+			switch e.Op {
+			case manifestStore, manifestReplace:
+				if _, ok := live[e.Ref]; !ok {
+					order = append(order, e.Ref)
+				}
+				live[e.Ref] = e.Checksum
+			case manifestDelete:
+				delete(live, e.Ref)
+			}
+			seq, prev = e.Seq, lineHash(line)
+			report.Entries++
+		}
+		err = sc.Err()
+		f.Close()
+		if err != nil {
+			return report, err
+		}
+	}
+
+	report.LastSeq, report.Head = seq, prev
+
+	if vault == nil {
+		return report, nil
+	}
+	for _, ref := range order {
+		sum, ok := live[ref]
+		if !ok {
+			continue
+		}
+		content, err := vault.Retrieve(ctx, ref)
+		switch {
+		case err != nil:
+			report.Missing = append(report.Missing, ref)
+		case contentHash(content) != sum:
+			report.Mismatched = append(report.Mismatched, ref)
+		}
+	}
+	return report, nil
+}
+
+// ReadManifest calls fn for every entry of the manifest files in dir, oldest
+// first. Entries are parsed but not verified; see VerifyManifest.
+func ReadManifest(dir string, fn func(ManifestEntry) error) error {
+	files, err := manifestFiles(dir)
+	if err != nil {
+		return err
+	}
+	for _, path := range files {
+		if err := readManifestFile(path, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func readManifestFile(path string, fn func(ManifestEntry) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	sc.Buffer(nil, 1<<20)
+	for sc.Scan() {
+		var e ManifestEntry
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		if err := fn(e); err != nil {
+			return err
+		}
+	}
+	return sc.Err()
+}
+
+// manifestFiles returns dir's manifest files, oldest first.
+func manifestFiles(dir string) ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "manifest-*.jsonl"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+func lastLine(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("resume manifest: %w", err)
+	}
+	data = bytes.TrimRight(data, "\n")
+	if len(data) == 0 {
+		return nil, nil
+	}
+	if i := bytes.LastIndexByte(data, '\n'); i >= 0 {
+		data = data[i+1:]
+	}
+	return data, nil
+}
+
+func lineHash(line []byte) string {
+	sum := sha256.Sum256(line)
+	return hex.EncodeToString(sum[:])
+}
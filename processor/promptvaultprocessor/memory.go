@@ -0,0 +1,206 @@
+package promptvaultprocessor
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// MemoryVault keeps content in process memory. It is bounded by total bytes,
+// evicting the oldest objects first, and optionally expires objects after a TTL.
+// Intended for unit tests, demos, and ephemeral pipelines.
+type MemoryVault struct {
+	mu       sync.Mutex
+	maxBytes int64
+	ttl      time.Duration
+	size     int64
+	order    *list.List // of *memoryEntry, oldest at the front
+	objects  map[string]*list.Element
+	now      func() time.Time
+}
+
+type memoryEntry struct {
+	hash string
+	meta ObjectMeta
+	// owners are the other spans identical content was stored for.
+	owners   []ObjectMeta
+	content  []byte
+	storedAt time.Time
+}
+
+// NewMemoryVault creates an in-memory vault. maxBytes <= 0 means unbounded and
+// ttl <= 0 means objects never expire.
+func NewMemoryVault(maxBytes int64, ttl time.Duration) *MemoryVault {
+	return &MemoryVault{
+		maxBytes: maxBytes,
+		ttl:      ttl,
+		order:    list.New(),
+		objects:  make(map[string]*list.Element),
+		now:      time.Now,
+	}
+}
+
+// Store keeps a copy of content and returns a vault reference.
+func (v *MemoryVault) Store(_ context.Context, meta ObjectMeta, content []byte) (string, error) {
+	hexHash := contentHash(content)
+	size := int64(len(content))
+	if v.maxBytes > 0 && size > v.maxBytes {
+		return "", fmt.Errorf("content of %d bytes exceeds memory vault capacity %d", size, v.maxBytes)
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	now := v.now()
+	v.expireLocked(now)
+
+	if el, ok := v.objects[hexHash]; ok {
+		// Refresh so deduplicated content isn't evicted as the oldest entry.
+		entry := el.Value.(*memoryEntry)
+		entry.storedAt = now
+		entry.owners = addOwner(entry.meta, entry.owners, meta)
+		v.order.MoveToBack(el)
+		return refScheme + hexHash, nil
+	}
+
+	for v.maxBytes > 0 && v.size+size > v.maxBytes {
+		v.removeLocked(v.order.Front())
+	}
+
+	entry := &memoryEntry{hash: hexHash, meta: meta, content: append([]byte(nil), content...), storedAt: now}
+	v.objects[hexHash] = v.order.PushBack(entry)
+	v.size += size
+
+	return refScheme + hexHash, nil
+}
+
+// Retrieve returns a copy of the content for ref.
+func (v *MemoryVault) Retrieve(_ context.Context, ref string) ([]byte, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	v.expireLocked(v.now())
+
+	el, ok := v.objects[hashFromRef(ref)]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrObjectNotFound, ref)
+	}
+	return append([]byte(nil), el.Value.(*memoryEntry).content...), nil
+}
+
+// Stat describes the object at ref.
+func (v *MemoryVault) Stat(_ context.Context, ref string) (ObjectInfo, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	v.expireLocked(v.now())
+
+	el, ok := v.objects[hashFromRef(ref)]
+	if !ok {
+		return ObjectInfo{}, fmt.Errorf("%w: %s", ErrObjectNotFound, ref)
+	}
+	entry := el.Value.(*memoryEntry)
+	return ObjectInfo{
+		Ref:      refScheme + entry.hash,
+		Size:     int64(len(entry.content)),
+		StoredAt: entry.storedAt,
+		Meta:     entry.meta,
+		Owners:   entry.owners,
+	}, nil
+}
+
+// Replace overwrites the content stored under ref. Growing content is held
+// to the same budget as Store, evicting the oldest other objects; the
+// replaced object keeps its place in the eviction order.
+func (v *MemoryVault) Replace(_ context.Context, ref string, content []byte) error {
+	size := int64(len(content))
+	if v.maxBytes > 0 && size > v.maxBytes {
+		return fmt.Errorf("content of %d bytes exceeds memory vault capacity %d", size, v.maxBytes)
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	v.expireLocked(v.now())
+
+	el, ok := v.objects[hashFromRef(ref)]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrObjectNotFound, ref)
+	}
+	entry := el.Value.(*memoryEntry)
+	grow := size - int64(len(entry.content))
+	for v.maxBytes > 0 && v.size+grow > v.maxBytes {
+		oldest := v.order.Front()
+		if oldest == el {
+			oldest = el.Next()
+		}
+		v.removeLocked(oldest)
+	}
+	v.size += grow
+	entry.content = append([]byte(nil), content...)
+	return nil
+}
+
+// Delete removes the object for ref.
+func (v *MemoryVault) Delete(_ context.Context, ref string) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	el, ok := v.objects[hashFromRef(ref)]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrObjectNotFound, ref)
+	}
+	v.removeLocked(el)
+	return nil
+}
+
+// List calls fn for every object, oldest first.
+func (v *MemoryVault) List(_ context.Context, fn func(ObjectInfo) error) error {
+	v.mu.Lock()
+	infos := make([]ObjectInfo, 0, v.order.Len())
+	for el := v.order.Front(); el != nil; el = el.Next() {
+		entry := el.Value.(*memoryEntry)
+		infos = append(infos, ObjectInfo{
+			Ref:      refScheme + entry.hash,
+			Size:     int64(len(entry.content)),
+			StoredAt: entry.storedAt,
+			Meta:     entry.meta,
+			Owners:   entry.owners,
+		})
+	}
+	v.mu.Unlock()
+
+	for _, info := range infos {
+		if err := fn(info); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Len returns the number of objects currently held.
+func (v *MemoryVault) Len() int {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.order.Len()
+}
+
+func (v *MemoryVault) expireLocked(now time.Time) {
+	if v.ttl <= 0 {
+		return
+	}
+	for el := v.order.Front(); el != nil; el = v.order.Front() {
+		if now.Sub(el.Value.(*memoryEntry).storedAt) < v.ttl {
+			return
+		}
+		v.removeLocked(el)
+	}
+}
+
+func (v *MemoryVault) removeLocked(el *list.Element) {
+	entry := v.order.Remove(el).(*memoryEntry)
+	delete(v.objects, entry.hash)
+	v.size -= int64(len(entry.content))
+}
@@ -0,0 +1,91 @@
+package promptvaultprocessor
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMemoryVaultRetrieve(t *testing.T) {
+	ctx := context.Background()
+	vault := NewMemoryVault(0, 0)
+
+	ref, err := vault.Store(ctx, ObjectMeta{}, []byte("in memory"))
+	if err != nil {
+		t.Fatalf("store failed: %v", err)
+	}
+
+	data, err := vault.Retrieve(ctx, ref)
+	if err != nil {
+		t.Fatalf("retrieve failed: %v", err)
+	}
+	if string(data) != "in memory" {
+		t.Errorf("expected %q, got %q", "in memory", string(data))
+	}
+}
+
+func TestMemoryVaultEvictsOldest(t *testing.T) {
+	ctx := context.Background()
+	vault := NewMemoryVault(10, 0)
+
+	first, _ := vault.Store(ctx, ObjectMeta{}, []byte("aaaaaa"))
+	second, _ := vault.Store(ctx, ObjectMeta{}, []byte("bbbbbb"))
+
+	if _, err := vault.Retrieve(ctx, first); err == nil {
+		t.Error("expected oldest object to be evicted")
+	}
+	if _, err := vault.Retrieve(ctx, second); err != nil {
+		t.Errorf("expected newest object to be kept: %v", err)
+	}
+
+	if _, err := vault.Store(ctx, ObjectMeta{}, []byte("this is far too large")); err == nil {
+		t.Error("expected error for content larger than capacity")
+	}
+}
+
+func TestMemoryVaultReplaceEvictsOldest(t *testing.T) {
+	ctx := context.Background()
+	vault := NewMemoryVault(10, 0)
+
+	first, _ := vault.Store(ctx, ObjectMeta{}, []byte("aaaa"))
+	second, _ := vault.Store(ctx, ObjectMeta{}, []byte("bbbb"))
+
+	// Growing the oldest object evicts the other one, not itself.
+	if err := vault.Replace(ctx, first, []byte("aaaaaaa")); err != nil {
+		t.Fatalf("replace failed: %v", err)
+	}
+	if _, err := vault.Retrieve(ctx, second); err == nil {
+		t.Error("expected other object to be evicted")
+	}
+	data, err := vault.Retrieve(ctx, first)
+	if err != nil || string(data) != "aaaaaaa" {
+		t.Errorf("expected replaced object to be kept, got %q, %v", data, err)
+	}
+	if vault.size != 7 {
+		t.Errorf("expected size 7, have %d", vault.size)
+	}
+
+	if err := vault.Replace(ctx, first, []byte("this is far too large")); err == nil {
+		t.Error("expected error for content larger than capacity")
+	}
+	if vault.size != 7 {
+		t.Errorf("expected rejected replace to leave size 7, have %d", vault.size)
+	}
+}
+
+func TestMemoryVaultTTL(t *testing.T) {
+	ctx := context.Background()
+	vault := NewMemoryVault(0, time.Minute)
+	now := time.Now()
+	vault.now = func() time.Time { return now }
+
+	ref, _ := vault.Store(ctx, ObjectMeta{}, []byte("short lived"))
+
+	now = now.Add(2 * time.Minute)
+	if _, err := vault.Retrieve(ctx, ref); err == nil {
+		t.Error("expected object to expire after ttl")
+	}
+	if vault.Len() != 0 {
+		t.Errorf("expected expired object to be dropped, have %d", vault.Len())
+	}
+}
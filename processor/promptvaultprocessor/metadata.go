@@ -0,0 +1,152 @@
+package promptvaultprocessor
+
+import (
+	"encoding/json"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+const metadataSuffix = ".json"
+
+// objectMetadata is the backend-native description of a stored object, for
+// lifecycle and audit tooling that shouldn't have to parse vault references.
+type objectMetadata struct {
+	AttrKey  string    `json:"attr_key"`
+	Service  string    `json:"service_name,omitempty"`
+	Tenant   string    `json:"tenant,omitempty"`
+	EndUser  string    `json:"enduser_id,omitempty"`
+	KeyID    string    `json:"encryption_key_id,omitempty"`
+	TraceID  string    `json:"trace_id,omitempty"`
+	SpanID   string    `json:"span_id,omitempty"`
+	SHA256   string    `json:"sha256"`
+	Size     int       `json:"size"`
+	StoredAt time.Time `json:"stored_at"`
+	// Transformations lists the codecs applied before storage, in order.
+	Transformations []string `json:"transformations,omitempty"`
+	// Owners lists the other spans identical content was later stored for;
+	// the fields above describe the first.
+	Owners []objectOwner `json:"owners,omitempty"`
+}
+
+// objectOwner is a span an object was stored for.
+type objectOwner struct {
+	AttrKey string `json:"attr_key"`
+	Service string `json:"service_name,omitempty"`
+	Tenant  string `json:"tenant,omitempty"`
+	EndUser string `json:"enduser_id,omitempty"`
+	TraceID string `json:"trace_id,omitempty"`
+	SpanID  string `json:"span_id,omitempty"`
+}
+
+func newObjectMetadata(meta ObjectMeta, hexHash string, size int, now time.Time) objectMetadata {
+	return objectMetadata{
+		AttrKey:         meta.Key,
+		Service:         meta.Service,
+		Tenant:          meta.Tenant,
+		EndUser:         meta.EndUser,
+		KeyID:           meta.KeyID,
+		TraceID:         meta.TraceID,
+		SpanID:          meta.SpanID,
+		SHA256:          hexHash,
+		Size:            size,
+		StoredAt:        now.UTC(),
+		Transformations: splitTransforms(meta.Transforms),
+	}
+}
+
+func (m objectMetadata) objectMeta() ObjectMeta {
+	return ObjectMeta{
+		TraceID:    m.TraceID,
+		SpanID:     m.SpanID,
+		Key:        m.AttrKey,
+		Service:    m.Service,
+		Tenant:     m.Tenant,
+		EndUser:    m.EndUser,
+		KeyID:      m.KeyID,
+		Transforms: strings.Join(m.Transformations, ","),
+	}
+}
+
+// owners returns the spans other than the first the object was stored for.
+func (m objectMetadata) owners() []ObjectMeta {
+	var owners []ObjectMeta
+	for _, o := range m.Owners {
+		meta := m.objectMeta()
+		meta.TraceID, meta.SpanID, meta.Key = o.TraceID, o.SpanID, o.AttrKey
+		meta.Service, meta.Tenant, meta.EndUser = o.Service, o.Tenant, o.EndUser
+		owners = append(owners, meta)
+	}
+	return owners
+}
+
+// addOwner records that the object was also stored for meta, unless it
+// already was.
+func (m *objectMetadata) addOwner(meta ObjectMeta) bool {
+	first := m.objectMeta()
+	if len(addOwner(first, m.owners(), meta)) == len(m.Owners) {
+		return false
+	}
+	m.Owners = append(m.Owners, objectOwner{
+		AttrKey: meta.Key,
+		Service: meta.Service,
+		Tenant:  meta.Tenant,
+		EndUser: meta.EndUser,
+		TraceID: meta.TraceID,
+		SpanID:  meta.SpanID,
+	})
+	return true
+}
+
+// addOwner appends meta to the owners of an object first stored for first,
+// unless either already describes the same span, attribute, and end user.
+// Backends that store identical content once keep every owner, so erasure
+// by trace or end user finds the object through any of them.
+func addOwner(first ObjectMeta, owners []ObjectMeta, meta ObjectMeta) []ObjectMeta {
+	for _, o := range append([]ObjectMeta{first}, owners...) {
+		if o.TraceID == meta.TraceID && o.SpanID == meta.SpanID && o.Key == meta.Key && o.EndUser == meta.EndUser {
+			return owners
+		}
+	}
+	return append(owners, meta)
+}
+
+// headers renders the metadata as HTTP headers. Values are query-escaped so
+// arbitrary attribute keys stay within the header character set.
+func (m objectMetadata) headers() map[string]string {
+	h := map[string]string{
+		"X-Vault-Attr-Key": url.QueryEscape(m.AttrKey),
+		"X-Vault-Sha256":   m.SHA256,
+	}
+	for name, val := range map[string]string{
+		"X-Vault-Service":         m.Service,
+		"X-Vault-Tenant":          m.Tenant,
+		"X-Vault-Key-Id":          m.KeyID,
+		"X-Vault-Trace-Id":        m.TraceID,
+		"X-Vault-Span-Id":         m.SpanID,
+		"X-Vault-Transformations": strings.Join(m.Transformations, ","),
+	} {
+		if val != "" {
+			h[name] = url.QueryEscape(val)
+		}
+	}
+	return h
+}
+
+func readObjectMetadata(path string) (objectMetadata, error) {
+	var m objectMetadata
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return m, err
+	}
+	err = json.Unmarshal(data, &m)
+	return m, err
+}
+
+func splitTransforms(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, ",")
+}
@@ -0,0 +1,71 @@
+package promptvaultprocessor
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestFilesystemVaultWritesMetadataSidecar(t *testing.T) {
+	ctx := context.Background()
+	vault, _ := NewFilesystemVault(FilesystemConfig{BasePath: t.TempDir(), Layout: "sharded", WriteMetadata: true})
+
+	meta := ObjectMeta{TraceID: "t1", SpanID: "s1", Key: "gen_ai.prompt", Service: "checkout", Tenant: "acme"}
+	ref, err := vault.Store(ctx, meta, []byte("tagged content"))
+	if err != nil {
+		t.Fatalf("store failed: %v", err)
+	}
+
+	path, _ := vault.findPath(ref)
+	om, err := readObjectMetadata(path + metadataSuffix)
+	if err != nil {
+		t.Fatalf("expected metadata sidecar: %v", err)
+	}
+	if om.AttrKey != "gen_ai.prompt" || om.Service != "checkout" || om.Tenant != "acme" || om.SHA256 != hashFromRef(ref) {
+		t.Errorf("unexpected metadata: %+v", om)
+	}
+
+	var listed ObjectInfo
+	vault.List(ctx, func(info ObjectInfo) error {
+		listed = info
+		return nil
+	})
+	if listed.Meta != meta {
+		t.Errorf("expected listing to carry sidecar metadata, got %+v", listed.Meta)
+	}
+	info, err := vault.Stat(ctx, ref)
+	if err != nil || info.Meta != meta || info.Size != int64(len("tagged content")) {
+		t.Errorf("Stat = %+v, %v", info, err)
+	}
+
+	if err := vault.Delete(ctx, ref); err != nil {
+		t.Fatalf("delete failed: %v", err)
+	}
+	if _, err := readObjectMetadata(path + metadataSuffix); err == nil {
+		t.Error("expected sidecar to be deleted with the object")
+	}
+	if _, err := vault.Stat(ctx, ref); !errors.Is(err, ErrObjectNotFound) {
+		t.Errorf("Stat after delete = %v, want ErrObjectNotFound", err)
+	}
+}
+
+func TestHTTPVaultSendsMetadataHeaders(t *testing.T) {
+	var got http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer srv.Close()
+
+	vault, _ := NewHTTPVault(HTTPConfig{URLTemplate: srv.URL + "/{hash}"})
+	_, err := vault.Store(context.Background(), ObjectMeta{Key: "gen_ai.prompt", Tenant: "acme corp"}, []byte("x"))
+	if err != nil {
+		t.Fatalf("store failed: %v", err)
+	}
+
+	if got.Get("X-Vault-Attr-Key") != "gen_ai.prompt" || got.Get("X-Vault-Tenant") != "acme+corp" {
+		t.Errorf("unexpected metadata headers: %v", got)
+	}
+}
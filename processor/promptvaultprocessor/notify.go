@@ -0,0 +1,203 @@
+package promptvaultprocessor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/collector/config/configopaque"
+	"go.uber.org/zap"
+)
+
+// NotifyConfig publishes a StoreNotification after each successful store to
+// every configured destination.
+type NotifyConfig struct {
+	Webhook NotifyWebhookConfig `mapstructure:"webhook"`
+	// Kafka produces each notification to a topic.
+	Kafka KafkaConfig     `mapstructure:"kafka"`
+	SNS   NotifySNSConfig `mapstructure:"sns"`
+	// QueueSize bounds notifications waiting to be published; further ones
+	// are dropped. Defaults to 1000.
+	QueueSize int `mapstructure:"queue_size"`
+}
+
+// NotifyWebhookConfig posts each notification as JSON to URL.
+type NotifyWebhookConfig struct {
+	URL     string                         `mapstructure:"url"`
+	Headers map[string]configopaque.String `mapstructure:"headers"`
+	// Timeout bounds each request. Defaults to 10s.
+	Timeout time.Duration `mapstructure:"timeout"`
+}
+
+// NotifySNSConfig publishes each notification to an SNS topic, with
+// credentials from the default AWS credential chain.
+type NotifySNSConfig struct {
+	TopicARN string `mapstructure:"topic_arn"`
+	Region   string `mapstructure:"region"`
+	// Endpoint overrides the SNS endpoint, e.g. for VPC endpoints or localstack.
+	Endpoint string `mapstructure:"endpoint"`
+}
+
+// StoreNotification announces newly vaulted content. It carries the
+// reference and metadata, never the content itself.
+type StoreNotification struct {
+	Ref      string    `json:"ref"`
+	TraceID  string    `json:"trace_id"`
+	SpanID   string    `json:"span_id"`
+	Key      string    `json:"key"`
+	Service  string    `json:"service,omitempty"`
+	Tenant   string    `json:"tenant,omitempty"`
+	Size     int       `json:"size"`
+	StoredAt time.Time `json:"stored_at"`
+}
+
+// notifyTarget delivers one encoded notification.
+type notifyTarget interface {
+	publish(ctx context.Context, n StoreNotification, body []byte) error
+	Close() error
+}
+
+const defaultNotifyQueueSize = 1000
+
+// storeNotifier publishes notifications from a bounded queue on its own
+// goroutine, so slow destinations never hold up the pipeline. Delivery is
+// at most once: failures are logged and not retried, and notifications are
+// dropped while the queue is full.
+type storeNotifier struct {
+	logger  *zap.Logger
+	targets []notifyTarget
+	queue   chan StoreNotification
+	done    chan struct{}
+	once    sync.Once
+}
+
+func newStoreNotifier(ctx context.Context, logger *zap.Logger, cfg NotifyConfig) (*storeNotifier, error) {
+	var targets []notifyTarget
+	if cfg.Webhook.URL != "" {
+		targets = append(targets, newWebhookTarget(cfg.Webhook))
+	}
+	if cfg.Kafka.Topic != "" || len(cfg.Kafka.Brokers) > 0 {
+		t, err := newKafkaTarget(cfg.Kafka)
+		if err != nil {
+			return nil, err
+		}
+		targets = append(targets, t)
+	}
+	if cfg.SNS.TopicARN != "" {
+		t, err := newSNSTarget(ctx, cfg.SNS)
+		if err != nil {
+			return nil, err
+		}
+		targets = append(targets, t)
+	}
+	if len(targets) == 0 {
+		return nil, nil
+	}
+	size := cfg.QueueSize
+	if size <= 0 {
+		size = defaultNotifyQueueSize
+	}
+	n := &storeNotifier{
+		logger:  logger,
+		targets: targets,
+		queue:   make(chan StoreNotification, size),
+		done:    make(chan struct{}),
+	}
+	go n.run()
+	return n, nil
+}
+
+// notify queues a notification for content stored at ref.
+func (n *storeNotifier) notify(meta ObjectMeta, ref string, size int) {
+	select {
+	case n.queue <- StoreNotification{
+		Ref:      ref,
+		TraceID:  meta.TraceID,
+		SpanID:   meta.SpanID,
+		Key:      meta.Key,
+		Service:  meta.Service,
+		Tenant:   meta.Tenant,
+		Size:     size,
+		StoredAt: time.Now().UTC(),
+	}:
+	default:
+		n.logger.Warn("store notification queue full; dropping notification", zap.String("ref", ref))
+	}
+}
+
+func (n *storeNotifier) run() {
+	defer close(n.done)
+	for sn := range n.queue {
+		body, err := json.Marshal(sn)
+		if err != nil {
+			continue
+		}
+		for _, t := range n.targets {
+			if err := t.publish(context.Background(), sn, body); err != nil {
+				n.logger.Warn("store notification failed", zap.String("ref", sn.Ref), zap.Error(err))
+			}
+		}
+	}
+}
+
+// Close publishes queued notifications, waiting until ctx is done, and
+// closes the destinations.
+func (n *storeNotifier) Close(ctx context.Context) error {
+	n.once.Do(func() { close(n.queue) })
+	var errs []error
+	select {
+	case <-n.done:
+	case <-ctx.Done():
+		errs = append(errs, fmt.Errorf("flush store notifications: %w", ctx.Err()))
+	}
+	for _, t := range n.targets {
+		errs = append(errs, t.Close())
+	}
+	return errors.Join(errs...)
+}
+
+type webhookTarget struct {
+	url     string
+	headers map[string]configopaque.String
+	client  *http.Client
+}
+
+func newWebhookTarget(cfg NotifyWebhookConfig) *webhookTarget {
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	return &webhookTarget{url: cfg.URL, headers: cfg.Headers, client: &http.Client{Timeout: timeout}}
+}
+
+func (t *webhookTarget) publish(ctx context.Context, _ StoreNotification, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range t.headers {
+		req.Header.Set(k, string(v))
+	}
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("post webhook: %w", err)
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("post webhook: %s", resp.Status)
+	}
+	return nil
+}
+
+func (t *webhookTarget) Close() error {
+	t.client.CloseIdleConnections()
+	return nil
+}
@@ -0,0 +1,43 @@
+//go:build !promptvault_no_kafka
+
+package promptvaultprocessor
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+type kafkaTarget struct {
+	client *kgo.Client
+}
+
+func newKafkaTarget(cfg KafkaConfig) (*kafkaTarget, error) {
+	if len(cfg.Brokers) == 0 || cfg.Topic == "" {
+		return nil, fmt.Errorf("notify.kafka requires brokers and a topic")
+	}
+	client, err := kgo.NewClient(
+		kgo.SeedBrokers(cfg.Brokers...),
+		kgo.DefaultProduceTopic(cfg.Topic),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("create kafka notification client: %w", err)
+	}
+	return &kafkaTarget{client: client}, nil
+}
+
+// publish produces the notification keyed by trace ID, so a trace's
+// notifications stay in order on one partition.
+func (t *kafkaTarget) publish(ctx context.Context, n StoreNotification, body []byte) error {
+	res := t.client.ProduceSync(ctx, &kgo.Record{Key: []byte(n.TraceID), Value: body})
+	if err := res.FirstErr(); err != nil {
+		return fmt.Errorf("produce notification: %w", err)
+	}
+	return nil
+}
+
+func (t *kafkaTarget) Close() error {
+	t.client.Close()
+	return nil
+}
@@ -0,0 +1,16 @@
+//go:build !promptvault_no_kafka
+
+package promptvaultprocessor
+
+import (
+	"context"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestNotifyKafkaValidates(t *testing.T) {
+	if _, err := newStoreNotifier(context.Background(), zap.NewNop(), NotifyConfig{Kafka: KafkaConfig{Topic: "t"}}); err == nil {
+		t.Error("expected error for kafka notifications without brokers")
+	}
+}
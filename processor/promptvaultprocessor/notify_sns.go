@@ -0,0 +1,93 @@
+//go:build !promptvault_no_aws
+
+package promptvaultprocessor
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+)
+
+// snsTarget calls the SNS Publish action over its query API, signed with
+// Signature Version 4.
+type snsTarget struct {
+	topicARN string
+	endpoint string
+	region   string
+	creds    aws.CredentialsProvider
+	signer   *v4.Signer
+	client   *http.Client
+}
+
+func newSNSTarget(ctx context.Context, cfg NotifySNSConfig) (*snsTarget, error) {
+	var opts []func(*awsconfig.LoadOptions) error
+	if cfg.Region != "" {
+		opts = append(opts, awsconfig.WithRegion(cfg.Region))
+	}
+	awsCfg, err := awsconfig.LoadDefaultConfig(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("load aws config: %w", err)
+	}
+	if awsCfg.Region == "" {
+		return nil, fmt.Errorf("notify.sns needs a region")
+	}
+	endpoint := cfg.Endpoint
+	if endpoint == "" {
+		endpoint = "https://sns." + awsCfg.Region + ".amazonaws.com/"
+	}
+	return &snsTarget{
+		topicARN: cfg.TopicARN,
+		endpoint: endpoint,
+		region:   awsCfg.Region,
+		creds:    awsCfg.Credentials,
+		signer:   v4.NewSigner(),
+		client:   &http.Client{Timeout: 10 * time.Second},
+	}, nil
+}
+
+func (t *snsTarget) publish(ctx context.Context, _ StoreNotification, body []byte) error {
+	form := url.Values{
+		"Action":   {"Publish"},
+		"Version":  {"2010-03-31"},
+		"TopicArn": {t.topicARN},
+		"Message":  {string(body)},
+	}.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.endpoint, strings.NewReader(form))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+	creds, err := t.creds.Retrieve(ctx)
+	if err != nil {
+		return fmt.Errorf("retrieve aws credentials: %w", err)
+	}
+	sum := sha256.Sum256([]byte(form))
+	if err := t.signer.SignHTTP(ctx, creds, req, hex.EncodeToString(sum[:]), "sns", t.region, time.Now()); err != nil {
+		return fmt.Errorf("sign sns request: %w", err)
+	}
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("publish to sns: %w", err)
+	}
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("publish to sns: %s: %s", resp.Status, msg)
+	}
+	return nil
+}
+
+func (t *snsTarget) Close() error {
+	t.client.CloseIdleConnections()
+	return nil
+}
@@ -0,0 +1,99 @@
+//go:build !promptvault_no_aws
+
+package promptvaultprocessor
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+
+	"go.opentelemetry.io/collector/config/configopaque"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.uber.org/zap"
+)
+
+func TestProcessorNotifiesWebhookAndSNS(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIDEXAMPLE")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	t.Setenv("AWS_CONFIG_FILE", "/dev/null")
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", "/dev/null")
+
+	var mu sync.Mutex
+	var webhook, sns []StoreNotification
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		defer mu.Unlock()
+		var n StoreNotification
+		switch r.URL.Path {
+		case "/hook":
+			if r.Header.Get("Authorization") != "Bearer t" {
+				t.Errorf("webhook Authorization = %q", r.Header.Get("Authorization"))
+			}
+			if err := json.Unmarshal(body, &n); err != nil {
+				t.Error(err)
+			}
+			webhook = append(webhook, n)
+		case "/sns":
+			if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/") {
+				t.Errorf("sns request not signed: %q", r.Header.Get("Authorization"))
+			}
+			form, _ := url.ParseQuery(string(body))
+			if form.Get("Action") != "Publish" || form.Get("TopicArn") != "arn:aws:sns:us-east-1:123456789012:vault" {
+				t.Errorf("sns form = %v", form)
+			}
+			if err := json.Unmarshal([]byte(form.Get("Message")), &n); err != nil {
+				t.Error(err)
+			}
+			sns = append(sns, n)
+		}
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	cfg := createDefaultConfig()
+	cfg.Notify = NotifyConfig{
+		Webhook: NotifyWebhookConfig{URL: srv.URL + "/hook", Headers: map[string]configopaque.String{"Authorization": "Bearer t"}},
+		SNS:     NotifySNSConfig{TopicARN: "arn:aws:sns:us-east-1:123456789012:vault", Region: "us-east-1", Endpoint: srv.URL + "/sns"},
+	}
+	notifier, err := newStoreNotifier(ctx, zap.NewNop(), cfg.Notify)
+	if err != nil {
+		t.Fatal(err)
+	}
+	proc := newVaultProcessor(zap.NewNop(), cfg, NewMemoryVault(0, 0), new(consumertest.TracesSink))
+	proc.notifier = notifier
+
+	td := ptrace.NewTraces()
+	rs := td.ResourceSpans().AppendEmpty()
+	rs.Resource().Attributes().PutStr("service.name", "chat")
+	span := rs.ScopeSpans().AppendEmpty().Spans().AppendEmpty()
+	span.Attributes().PutStr("gen_ai.prompt", "Tell me about quantum computing")
+	if err := proc.ConsumeTraces(ctx, td); err != nil {
+		t.Fatal(err)
+	}
+	// Shutdown publishes queued notifications.
+	if err := proc.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	ref, _ := span.Attributes().Get("gen_ai.prompt.vault_ref")
+	mu.Lock()
+	defer mu.Unlock()
+	for name, got := range map[string][]StoreNotification{"webhook": webhook, "sns": sns} {
+		if len(got) != 1 {
+			t.Errorf("%s received %d notifications, want 1", name, len(got))
+			continue
+		}
+		n := got[0]
+		if n.Ref != ref.Str() || n.Key != "gen_ai.prompt" || n.Service != "chat" || n.Size != len("Tell me about quantum computing") {
+			t.Errorf("%s notification = %+v", name, n)
+		}
+	}
+}
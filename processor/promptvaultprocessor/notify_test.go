@@ -0,0 +1,35 @@
+package promptvaultprocessor
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestNotifierDropsWhenQueueFull(t *testing.T) {
+	block := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-block
+	}))
+	defer srv.Close()
+	defer close(block)
+
+	n, err := newStoreNotifier(context.Background(), zap.NewNop(), NotifyConfig{
+		Webhook:   NotifyWebhookConfig{URL: srv.URL},
+		QueueSize: 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The worker holds at most one notification and the queue one more;
+	// the rest must be dropped rather than block.
+	for i := 0; i < 10; i++ {
+		n.notify(ObjectMeta{}, "vault://x", 1)
+	}
+	if len(n.queue) > 1 {
+		t.Errorf("queue holds %d notifications, want at most 1", len(n.queue))
+	}
+}
@@ -0,0 +1,123 @@
+package promptvaultprocessor
+
+import (
+	"context"
+	"fmt"
+
+	"go.uber.org/zap"
+)
+
+// OpenVault builds the storage stack described by cfg (backend, bundles,
+// checksum verification, manifest, encryption, and compression) exactly as the
+// processor does, so other components read objects the way they were
+// written. The caller must call Start, if implemented, and Close.
+func OpenVault(ctx context.Context, logger *zap.Logger, cfg *Config) (VaultStorage, error) {
+	if cfg.Storage.Extension != "" {
+		return nil, fmt.Errorf("storage.extension %q can only be resolved by a collector component", cfg.Storage.Extension)
+	}
+	if err := MigrateConfig(logger, cfg); err != nil {
+		return nil, err
+	}
+	metrics, err := newVaultMetrics(nil)
+	if err != nil {
+		return nil, err
+	}
+	return openVault(ctx, logger, metrics, cfg)
+}
+
+// OpenStoredVault builds the part of the storage stack cfg describes that
+// sits below encryption and compression, so objects are read and written
+// as stored. Stores still update the index, quotas, and manifests. Used to
+// copy objects between vaults without their keys. The caller must call
+// Start, if implemented, and Close.
+func OpenStoredVault(ctx context.Context, logger *zap.Logger, cfg *Config) (VaultStorage, error) {
+	if cfg.Storage.Extension != "" {
+		return nil, fmt.Errorf("storage.extension %q can only be resolved by a collector component", cfg.Storage.Extension)
+	}
+	if err := MigrateConfig(logger, cfg); err != nil {
+		return nil, err
+	}
+	metrics, err := newVaultMetrics(nil)
+	if err != nil {
+		return nil, err
+	}
+	return openStoredVault(ctx, logger, metrics, cfg)
+}
+
+func openVault(ctx context.Context, logger *zap.Logger, metrics *vaultMetrics, cfg *Config) (VaultStorage, error) {
+	vault, err := openStoredVault(ctx, logger, metrics, cfg)
+	if err != nil {
+		return nil, err
+	}
+	// A layer that fails leaves the stack below it open; close it, since
+	// no caller will see it.
+	wrapped, err := wrapWithEncryption(ctx, logger, cfg.Crypto, cfg.Storage.PathTemplate, vault)
+	if err != nil {
+		closeVaults(vault)
+		return nil, err
+	}
+	vault = wrapped
+	// Compression wraps encryption so content is compressed first.
+	if wrapped, err = newCompressingVault(vault, cfg.Storage.Compression); err != nil {
+		closeVaults(vault)
+		return nil, err
+	}
+	vault = wrapped
+	if wrapped, err = newContentSearchVault(ctx, vault, cfg.ContentSearch); err != nil {
+		closeVaults(vault)
+		return nil, err
+	}
+	return wrapped, nil
+}
+
+func openStoredVault(ctx context.Context, logger *zap.Logger, metrics *vaultMetrics, cfg *Config) (VaultStorage, error) {
+	vault, err := openBackendStack(ctx, logger, metrics, cfg.Storage)
+	if err != nil {
+		return nil, err
+	}
+	wrapped, err := newIndexVault(ctx, vault, cfg.Storage.Index)
+	if err != nil {
+		closeVaults(vault)
+		return nil, err
+	}
+	vault = wrapped
+	// Quotas sit above the index, which answers recounts, and below
+	// compression and encryption, so usage is in bytes as stored.
+	if wrapped, err = newQuotaVault(logger, metrics, vault, cfg.Storage.Quotas); err != nil {
+		closeVaults(vault)
+		return nil, err
+	}
+	vault = wrapped
+	if wrapped, err = newTraceManifestVault(vault, cfg.Storage.TraceManifests); err != nil {
+		closeVaults(vault)
+		return nil, err
+	}
+	vault = wrapped
+	if wrapped, err = newManifestVault(vault, cfg.Storage.Manifest, cfg.Vault.SigningKey); err != nil {
+		closeVaults(vault)
+		return nil, err
+	}
+	return wrapped, nil
+}
+
+// openBackendStack builds the bottom of the storage stack, which holds
+// objects' bytes as stored: the backend, regional routing, bundles, and
+// checksum verification. It lists every object, bundled or not, without
+// consulting the index.
+func openBackendStack(ctx context.Context, logger *zap.Logger, metrics *vaultMetrics, cfg StorageConfig) (VaultStorage, error) {
+	vault, err := newVaultStorage(ctx, logger, metrics, cfg)
+	if err != nil {
+		return nil, err
+	}
+	wrapped, err := newRoutingVault(ctx, logger, metrics, vault, cfg)
+	if err != nil {
+		closeVaults(vault)
+		return nil, err
+	}
+	vault = wrapped
+	if wrapped, err = newBundleVault(ctx, vault, cfg.Bundles); err != nil {
+		closeVaults(vault)
+		return nil, err
+	}
+	return newChecksumVault(wrapped), nil
+}
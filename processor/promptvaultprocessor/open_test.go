@@ -0,0 +1,47 @@
+package promptvaultprocessor
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+// openFiles lists the files under dir this process holds open.
+func openFiles(t *testing.T, dir string) []string {
+	t.Helper()
+	fds, err := os.ReadDir("/proc/self/fd")
+	if err != nil {
+		t.Skip("open files are listed through /proc/self/fd")
+	}
+	var open []string
+	for _, fd := range fds {
+		if target, err := os.Readlink(filepath.Join("/proc/self/fd", fd.Name())); err == nil && strings.HasPrefix(target, dir) {
+			open = append(open, target)
+		}
+	}
+	return open
+}
+
+func TestOpenVaultClosesStackOnError(t *testing.T) {
+	dir := t.TempDir()
+	cfg := createDefaultConfig()
+	cfg.Storage.Filesystem.BasePath = filepath.Join(dir, "vault")
+	cfg.Storage.Index.Path = filepath.Join(dir, "index.db")
+	// Compression is applied after the index has been opened.
+	cfg.Storage.Compression = "lz4"
+
+	metrics, err := newVaultMetrics(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := openVault(context.Background(), zap.NewNop(), metrics, cfg); err == nil {
+		t.Fatal("expected an unknown compression to fail")
+	}
+	if open := openFiles(t, dir); len(open) > 0 {
+		t.Errorf("failed open left %v open", open)
+	}
+}
@@ -0,0 +1,132 @@
+package promptvaultprocessor
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+)
+
+// pathTemplatePlaceholders lists what storage.path_template may reference.
+var pathTemplatePlaceholders = []string{
+	"{yyyy}", "{mm}", "{dd}", "{hh}", "{service}", "{trace_id}", "{span_id}", "{key}",
+}
+
+// validatePathTemplate rejects templates with unknown placeholders.
+func validatePathTemplate(tmpl string) error {
+	rest := tmpl
+	for _, p := range pathTemplatePlaceholders {
+		rest = strings.ReplaceAll(rest, p, "")
+	}
+	if strings.ContainsAny(rest, "{}") {
+		return fmt.Errorf("path_template %q has an unknown placeholder; supported: %s",
+			tmpl, strings.Join(pathTemplatePlaceholders, " "))
+	}
+	return nil
+}
+
+// objectPath expands tmpl for meta and appends the content hash, e.g.
+// "2026/01/31/checkout/<trace>/<span>/gen_ai.prompt/<sha256>". Without a
+// template the object path is just the hash.
+func objectPath(tmpl string, meta ObjectMeta, hexHash string, now time.Time) string {
+	if tmpl == "" {
+		return hexHash
+	}
+	now = now.UTC()
+	r := strings.NewReplacer(
+		"{yyyy}", now.Format("2006"),
+		"{mm}", now.Format("01"),
+		"{dd}", now.Format("02"),
+		"{hh}", now.Format("15"),
+		"{service}", encodePathSegment(meta.Service),
+		"{trace_id}", encodePathSegment(meta.TraceID),
+		"{span_id}", encodePathSegment(meta.SpanID),
+		"{key}", encodePathSegment(meta.Key),
+	)
+	return path.Join(r.Replace(tmpl), hexHash)
+}
+
+// encodePathSegment makes an arbitrary string safe to use as a single path
+// segment on every backend: bytes outside [A-Za-z0-9._-] are percent-encoded,
+// so slashes, unicode, and shell/Windows-hostile characters never change the
+// layout. Empty values become "_" and the dot segments "." and ".." are encoded.
+func encodePathSegment(s string) string {
+	switch s {
+	case "":
+		return "_"
+	case "_":
+		return "%5F"
+	case ".":
+		return "%2E"
+	case "..":
+		return "%2E%2E"
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if isPathSafe(c) {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", c)
+	}
+	return b.String()
+}
+
+// decodePathSegment reverses encodePathSegment.
+func decodePathSegment(seg string) (string, error) {
+	if seg == "_" {
+		return "", nil
+	}
+	return url.PathUnescape(seg)
+}
+
+func isPathSafe(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' ||
+		c == '.' || c == '_' || c == '-'
+}
+
+// metaFromObjectPath recovers the span metadata encoded in objPath by tmpl.
+// Only placeholders that occupy a whole path segment can be recovered.
+func metaFromObjectPath(tmpl, objPath string) ObjectMeta {
+	var meta ObjectMeta
+	tmplSegs := strings.Split(tmpl, "/")
+	pathSegs := strings.Split(path.Dir(objPath), "/")
+	if len(tmplSegs) != len(pathSegs) {
+		return meta
+	}
+	for i, seg := range tmplSegs {
+		val, err := decodePathSegment(pathSegs[i])
+		if err != nil {
+			continue
+		}
+		switch seg {
+		case "{service}":
+			meta.Service = val
+		case "{trace_id}":
+			meta.TraceID = val
+		case "{span_id}":
+			meta.SpanID = val
+		case "{key}":
+			meta.Key = val
+		}
+	}
+	return meta
+}
+
+// objectPathFromRef returns the object path a reference points at, rejecting
+// paths that would escape the vault root.
+func objectPathFromRef(ref string) (string, error) {
+	p := strings.TrimPrefix(ref, refScheme)
+	if p == "" || strings.HasPrefix(p, "/") || path.Clean(p) != p {
+		return "", fmt.Errorf("invalid vault ref: %s", ref)
+	}
+	for _, seg := range strings.Split(p, "/") {
+		if seg == ".." || seg == "." {
+			return "", fmt.Errorf("invalid vault ref: %s", ref)
+		}
+	}
+	return p, nil
+}
@@ -0,0 +1,106 @@
+package promptvaultprocessor
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestObjectPathExpandsTemplate(t *testing.T) {
+	meta := ObjectMeta{TraceID: "t1", SpanID: "s1", Key: "gen_ai.prompt", Service: "checkout"}
+	now := time.Date(2026, 1, 31, 12, 0, 0, 0, time.UTC)
+
+	got := objectPath("{yyyy}/{mm}/{dd}/{service}/{trace_id}/{span_id}/{key}", meta, "abc", now)
+	if got != "2026/01/31/checkout/t1/s1/gen_ai.prompt/abc" {
+		t.Errorf("unexpected object path: %s", got)
+	}
+
+	if got := objectPath("", meta, "abc", now); got != "abc" {
+		t.Errorf("expected bare hash without template, got: %s", got)
+	}
+}
+
+func TestValidatePathTemplate(t *testing.T) {
+	if err := validatePathTemplate("{yyyy}/{service}/{key}"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := validatePathTemplate("{year}/{key}"); err == nil {
+		t.Error("expected error for unknown placeholder")
+	}
+}
+
+func TestFilesystemVaultPathTemplate(t *testing.T) {
+	tmpDir := t.TempDir()
+	ctx := context.Background()
+	vault, _ := NewFilesystemVault(FilesystemConfig{BasePath: tmpDir})
+	vault.pathTemplate = "{service}/{trace_id}/{key}"
+
+	ref, err := vault.Store(ctx, ObjectMeta{TraceID: "t1", Key: "gen_ai.prompt", Service: "checkout"}, []byte("templated"))
+	if err != nil {
+		t.Fatalf("store failed: %v", err)
+	}
+	if !strings.HasPrefix(ref, "vault://checkout/t1/gen_ai.prompt/") {
+		t.Errorf("unexpected ref: %s", ref)
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, "checkout", "t1", "gen_ai.prompt", hashFromRef(ref)+".vault")); err != nil {
+		t.Errorf("expected object under templated path: %v", err)
+	}
+
+	data, err := vault.Retrieve(ctx, ref)
+	if err != nil {
+		t.Fatalf("retrieve failed: %v", err)
+	}
+	if string(data) != "templated" {
+		t.Errorf("expected %q, got %q", "templated", string(data))
+	}
+
+	if _, err := vault.Retrieve(ctx, "vault://../outside/"+hashFromRef(ref)); err == nil {
+		t.Error("expected error for ref escaping the vault root")
+	}
+}
+
+func TestEncodePathSegmentRoundTrip(t *testing.T) {
+	for _, in := range []string{"gen_ai.prompt", "span/event_0/key", "llm.input_messages.0", "préférence", "..", "", "_", "a b:c%d"} {
+		enc := encodePathSegment(in)
+		if strings.ContainsAny(enc, "/ :") || enc == "." || enc == ".." || enc == "" {
+			t.Errorf("encoded %q to unsafe segment %q", in, enc)
+		}
+		dec, err := decodePathSegment(enc)
+		if err != nil {
+			t.Fatalf("decode %q failed: %v", enc, err)
+		}
+		if dec != in {
+			t.Errorf("round trip of %q gave %q", in, dec)
+		}
+	}
+}
+
+func TestFilesystemVaultListRecoversMeta(t *testing.T) {
+	ctx := context.Background()
+	vault, _ := NewFilesystemVault(FilesystemConfig{BasePath: t.TempDir()})
+	vault.pathTemplate = "{yyyy}/{service}/{trace_id}/{span_id}/{key}"
+
+	meta := ObjectMeta{TraceID: "t1", SpanID: "s1", Key: "span/event_0/gen_ai.prompt", Service: "chat svc"}
+	ref, err := vault.Store(ctx, meta, []byte("encoded path"))
+	if err != nil {
+		t.Fatalf("store failed: %v", err)
+	}
+	if strings.Count(strings.TrimPrefix(ref, "vault://"), "/") != 5 {
+		t.Errorf("expected slashes in the key to be encoded, got ref: %s", ref)
+	}
+	if _, err := vault.Retrieve(ctx, ref); err != nil {
+		t.Fatalf("retrieve failed: %v", err)
+	}
+
+	var listed []ObjectInfo
+	vault.List(ctx, func(info ObjectInfo) error {
+		listed = append(listed, info)
+		return nil
+	})
+	if len(listed) != 1 || listed[0].Ref != ref || listed[0].Meta != meta {
+		t.Errorf("unexpected listing: %+v", listed)
+	}
+}
@@ -0,0 +1,130 @@
+//go:build cgo
+
+package promptvaultprocessor
+
+import (
+	"crypto/rand"
+	"fmt"
+	"sync"
+
+	"github.com/miekg/pkcs11"
+)
+
+// pkcs11Token wraps data keys with CKM_AES_GCM under a secret key on the
+// token. PKCS#11 sessions are not safe for concurrent use, so operations are
+// serialized on one logged-in session.
+type pkcs11Token struct {
+	mu      sync.Mutex
+	ctx     *pkcs11.Ctx
+	session pkcs11.SessionHandle
+	key     pkcs11.ObjectHandle
+}
+
+func openPKCS11Token(cfg PKCS11Config) (*pkcs11Token, error) {
+	p := pkcs11.New(cfg.ModulePath)
+	if p == nil {
+		return nil, fmt.Errorf("load pkcs11 module %s", cfg.ModulePath)
+	}
+	if err := p.Initialize(); err != nil {
+		p.Destroy()
+		return nil, fmt.Errorf("initialize pkcs11 module: %w", err)
+	}
+	t := &pkcs11Token{ctx: p}
+	if err := t.open(cfg); err != nil {
+		p.Finalize()
+		p.Destroy()
+		return nil, err
+	}
+	return t, nil
+}
+
+func (t *pkcs11Token) open(cfg PKCS11Config) error {
+	slots, err := t.ctx.GetSlotList(true)
+	if err != nil {
+		return fmt.Errorf("list pkcs11 slots: %w", err)
+	}
+	slot, found := uint(0), false
+	for _, s := range slots {
+		info, err := t.ctx.GetTokenInfo(s)
+		if err == nil && info.Label == cfg.TokenLabel {
+			slot, found = s, true
+			break
+		}
+	}
+	if !found {
+		return fmt.Errorf("pkcs11 token %q not found", cfg.TokenLabel)
+	}
+
+	t.session, err = t.ctx.OpenSession(slot, pkcs11.CKF_SERIAL_SESSION|pkcs11.CKF_RW_SESSION)
+	if err != nil {
+		return fmt.Errorf("open pkcs11 session: %w", err)
+	}
+	if err := t.ctx.Login(t.session, pkcs11.CKU_USER, string(cfg.PIN)); err != nil {
+		return fmt.Errorf("pkcs11 login: %w", err)
+	}
+
+	template := []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_SECRET_KEY),
+		pkcs11.NewAttribute(pkcs11.CKA_LABEL, cfg.KeyLabel),
+	}
+	if err := t.ctx.FindObjectsInit(t.session, template); err != nil {
+		return fmt.Errorf("find pkcs11 key: %w", err)
+	}
+	objs, _, err := t.ctx.FindObjects(t.session, 1)
+	t.ctx.FindObjectsFinal(t.session)
+	if err != nil {
+		return fmt.Errorf("find pkcs11 key: %w", err)
+	}
+	if len(objs) == 0 {
+		return fmt.Errorf("pkcs11 key %q not found on token %q", cfg.KeyLabel, cfg.TokenLabel)
+	}
+	t.key = objs[0]
+	return nil
+}
+
+// WrapKey returns nonce || AES-GCM(master, dataKey).
+func (t *pkcs11Token) WrapKey(dataKey []byte) ([]byte, error) {
+	nonce := make([]byte, gcmNonceSize)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	params := pkcs11.NewGCMParams(nonce, nil, 128)
+	defer params.Free()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if err := t.ctx.EncryptInit(t.session, []*pkcs11.Mechanism{pkcs11.NewMechanism(pkcs11.CKM_AES_GCM, params)}, t.key); err != nil {
+		return nil, err
+	}
+	wrapped, err := t.ctx.Encrypt(t.session, dataKey)
+	if err != nil {
+		return nil, err
+	}
+	return append(nonce, wrapped...), nil
+}
+
+// UnwrapKey reverses WrapKey.
+func (t *pkcs11Token) UnwrapKey(wrapped []byte) ([]byte, error) {
+	if len(wrapped) < gcmNonceSize {
+		return nil, errMalformedEnvelope
+	}
+	params := pkcs11.NewGCMParams(wrapped[:gcmNonceSize], nil, 128)
+	defer params.Free()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if err := t.ctx.DecryptInit(t.session, []*pkcs11.Mechanism{pkcs11.NewMechanism(pkcs11.CKM_AES_GCM, params)}, t.key); err != nil {
+		return nil, err
+	}
+	return t.ctx.Decrypt(t.session, wrapped[gcmNonceSize:])
+}
+
+func (t *pkcs11Token) close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.ctx.Logout(t.session)
+	t.ctx.CloseSession(t.session)
+	err := t.ctx.Finalize()
+	t.ctx.Destroy()
+	return err
+}
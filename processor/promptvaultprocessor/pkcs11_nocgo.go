@@ -0,0 +1,13 @@
+//go:build !cgo
+
+package promptvaultprocessor
+
+import "errors"
+
+// errPKCS11NoCgo is returned for crypto.pkcs11 by binaries built without
+// cgo, which cannot load a PKCS#11 module.
+var errPKCS11NoCgo = errors.New("pkcs11 requires a cgo build")
+
+func openPKCS11Token(PKCS11Config) (keyWrapper, error) {
+	return nil, errPKCS11NoCgo
+}
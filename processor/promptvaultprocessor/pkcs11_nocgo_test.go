@@ -0,0 +1,15 @@
+//go:build !cgo
+
+package promptvaultprocessor
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestPKCS11RequiresCgo(t *testing.T) {
+	_, err := NewHSMEncryptor(PKCS11Config{ModulePath: "/usr/lib/softhsm/libsofthsm2.so", TokenLabel: "vault", KeyLabel: "vault-master"})
+	if !errors.Is(err, errPKCS11NoCgo) {
+		t.Errorf("expected %v, got %v", errPKCS11NoCgo, err)
+	}
+}
@@ -0,0 +1,15 @@
+//go:build cgo
+
+package promptvaultprocessor
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPKCS11LoadsModule(t *testing.T) {
+	_, err := NewHSMEncryptor(PKCS11Config{ModulePath: "/nonexistent/libsofthsm2.so", TokenLabel: "vault", KeyLabel: "vault-master"})
+	if err == nil || !strings.Contains(err.Error(), "load pkcs11 module") {
+		t.Errorf("expected the missing module to fail to load, got %v", err)
+	}
+}
@@ -0,0 +1,161 @@
+package promptvaultprocessor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"go.opentelemetry.io/collector/config/configopaque"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+)
+
+// PolicyConfig delegates offload decisions to an external policy engine,
+// such as an OPA server, so data-handling policy can change without
+// editing collector configuration.
+type PolicyConfig struct {
+	// URL receives a POST of {"input": PolicyInput} for each value that
+	// would be vaulted, e.g. http://localhost:8181/v1/data/promptvault/decision.
+	// The response's "result" is a decision string or an object with a
+	// "decision" field. Empty disables the hook.
+	URL     string                         `mapstructure:"url"`
+	Headers map[string]configopaque.String `mapstructure:"headers"`
+	// Timeout bounds each decision. Defaults to 1s.
+	Timeout time.Duration `mapstructure:"timeout"`
+	// OnError is the decision used when the engine fails or returns an
+	// unknown decision. Defaults to "offload".
+	OnError string `mapstructure:"on_error"`
+}
+
+// Policy decisions.
+const (
+	// PolicyOffload vaults the value as configured by the mode.
+	PolicyOffload = "offload"
+	// PolicyKeep leaves the value in the span and stores nothing.
+	PolicyKeep = "keep"
+	// PolicyDrop removes the attribute and stores nothing.
+	PolicyDrop = "drop"
+	// PolicyRedact replaces the value with its redacted form and stores
+	// nothing.
+	PolicyRedact = "redact"
+)
+
+func validPolicyDecision(d string) bool {
+	switch d {
+	case PolicyOffload, PolicyKeep, PolicyDrop, PolicyRedact:
+		return true
+	}
+	return false
+}
+
+// PolicyInput describes a value the processor is about to vault. It never
+// includes the value itself.
+type PolicyInput struct {
+	Service            string            `json:"service"`
+	Tenant             string            `json:"tenant,omitempty"`
+	TraceID            string            `json:"trace_id"`
+	SpanID             string            `json:"span_id"`
+	SpanName           string            `json:"span_name"`
+	Key                string            `json:"key"`
+	Size               int               `json:"size"`
+	ResourceAttributes map[string]string `json:"resource_attributes"`
+	// SpanAttributes holds the span's attributes other than those listed
+	// in vault.keys.
+	SpanAttributes map[string]string `json:"span_attributes"`
+}
+
+type policyClient struct {
+	url     string
+	headers map[string]configopaque.String
+	onError string
+	client  *http.Client
+}
+
+func newPolicyClient(cfg PolicyConfig) (*policyClient, error) {
+	if cfg.URL == "" {
+		return nil, nil
+	}
+	onError := cfg.OnError
+	if onError == "" {
+		onError = PolicyOffload
+	}
+	if !validPolicyDecision(onError) {
+		return nil, fmt.Errorf("vault.policy.on_error: unknown decision %q", onError)
+	}
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = time.Second
+	}
+	return &policyClient{url: cfg.URL, headers: cfg.Headers, onError: onError, client: &http.Client{Timeout: timeout}}, nil
+}
+
+// decide returns the engine's decision for in. On failure it returns the
+// on_error decision along with the error.
+func (c *policyClient) decide(ctx context.Context, in PolicyInput) (string, error) {
+	d, err := c.query(ctx, in)
+	if err != nil {
+		return c.onError, err
+	}
+	return d, nil
+}
+
+func (c *policyClient) query(ctx context.Context, in PolicyInput) (string, error) {
+	body, err := json.Marshal(struct {
+		Input PolicyInput `json:"input"`
+	}{in})
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range c.headers {
+		req.Header.Set(k, string(v))
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("query policy: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("query policy: %s", resp.Status)
+	}
+	var out struct {
+		Result json.RawMessage `json:"result"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&out); err != nil {
+		return "", fmt.Errorf("decode policy response: %w", err)
+	}
+	var d string
+	if err := json.Unmarshal(out.Result, &d); err != nil {
+		var obj struct {
+			Decision string `json:"decision"`
+		}
+		if err := json.Unmarshal(out.Result, &obj); err != nil {
+			return "", fmt.Errorf("decode policy result: %w", err)
+		}
+		d = obj.Decision
+	}
+	if !validPolicyDecision(d) {
+		// Covers an undefined result, which OPA reports by omitting it.
+		return "", fmt.Errorf("policy returned unknown decision %q", d)
+	}
+	return d, nil
+}
+
+// attributeStrings renders attrs as strings, skipping keys skip reports.
+func attributeStrings(attrs pcommon.Map, skip func(string) bool) map[string]string {
+	out := make(map[string]string, attrs.Len())
+	attrs.Range(func(k string, v pcommon.Value) bool {
+		if skip == nil || !skip(k) {
+			out[k] = v.AsString()
+		}
+		return true
+	})
+	return out
+}
@@ -0,0 +1,107 @@
+package promptvaultprocessor
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.uber.org/zap"
+)
+
+func TestProcessorAppliesPolicyDecisions(t *testing.T) {
+	var inputs []PolicyInput
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Input PolicyInput `json:"input"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Error(err)
+		}
+		inputs = append(inputs, req.Input)
+		// Answer in both shapes OPA policies commonly use.
+		switch req.Input.Key {
+		case "gen_ai.prompt":
+			w.Write([]byte(`{"result":"offload"}`))
+		case "gen_ai.completion":
+			w.Write([]byte(`{"result":{"decision":"keep"}}`))
+		case "gen_ai.system":
+			w.Write([]byte(`{"result":"drop"}`))
+		case "gen_ai.tool":
+			w.Write([]byte(`{"result":"redact"}`))
+		default:
+			w.Write([]byte(`{}`))
+		}
+	}))
+	defer srv.Close()
+
+	cfg := createDefaultConfig()
+	cfg.Vault.Keys = []string{"gen_ai.prompt", "gen_ai.completion", "gen_ai.system", "gen_ai.tool", "gen_ai.other"}
+	cfg.Vault.Policy = PolicyConfig{URL: srv.URL, OnError: "drop"}
+	policy, err := newPolicyClient(cfg.Vault.Policy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vault := NewMemoryVault(0, 0)
+	proc := newVaultProcessor(zap.NewNop(), cfg, vault, new(consumertest.TracesSink))
+	proc.policy = policy
+	proc.redactor, _ = newPIIScanner(RedactionConfig{Detectors: RedactionDetectors{Email: true}})
+
+	td := ptrace.NewTraces()
+	rs := td.ResourceSpans().AppendEmpty()
+	rs.Resource().Attributes().PutStr("service.name", "chat")
+	rs.Resource().Attributes().PutStr("deployment.environment", "prod")
+	span := rs.ScopeSpans().AppendEmpty().Spans().AppendEmpty()
+	span.SetName("llm.call")
+	attrs := span.Attributes()
+	attrs.PutStr("gen_ai.request.model", "gpt-4")
+	attrs.PutStr("gen_ai.prompt", "offloaded prompt")
+	attrs.PutStr("gen_ai.completion", "kept completion")
+	attrs.PutStr("gen_ai.system", "dropped system prompt")
+	attrs.PutStr("gen_ai.tool", "mail bob@example.com")
+	attrs.PutStr("gen_ai.other", "undecided value")
+	if err := proc.ConsumeTraces(context.Background(), td); err != nil {
+		t.Fatal(err)
+	}
+
+	if v, _ := attrs.Get("gen_ai.prompt"); !strings.HasPrefix(v.Str(), "vault://") {
+		t.Errorf("offloaded prompt = %q", v.Str())
+	}
+	if v, _ := attrs.Get("gen_ai.completion"); v.Str() != "kept completion" {
+		t.Errorf("kept completion = %q", v.Str())
+	}
+	if _, ok := attrs.Get("gen_ai.system"); ok {
+		t.Error("dropped attribute is still present")
+	}
+	if v, _ := attrs.Get("gen_ai.tool"); v.Str() != "mail [REDACTED:EMAIL]" {
+		t.Errorf("redacted tool = %q", v.Str())
+	}
+	// An unknown decision falls back to on_error.
+	if _, ok := attrs.Get("gen_ai.other"); ok {
+		t.Error("on_error drop was not applied")
+	}
+	var stored int
+	vault.List(context.Background(), func(ObjectInfo) error { stored++; return nil })
+	if stored != 1 {
+		t.Errorf("vault holds %d objects, want 1", stored)
+	}
+
+	in := inputs[0]
+	if in.Service != "chat" || in.SpanName != "llm.call" || in.Size == 0 ||
+		in.ResourceAttributes["deployment.environment"] != "prod" || in.SpanAttributes["gen_ai.request.model"] != "gpt-4" {
+		t.Errorf("policy input = %+v", in)
+	}
+	if _, ok := in.SpanAttributes["gen_ai.completion"]; ok {
+		t.Error("policy input includes vaulted attribute values")
+	}
+}
+
+func TestNewPolicyClientValidatesOnError(t *testing.T) {
+	if _, err := newPolicyClient(PolicyConfig{URL: "http://opa", OnError: "allow"}); err == nil {
+		t.Error("expected error for unknown on_error decision")
+	}
+}
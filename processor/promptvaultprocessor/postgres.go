@@ -0,0 +1,230 @@
+//go:build !promptvault_no_postgres
+
+package promptvaultprocessor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// PostgresVault stores content in a bytea table keyed by trace/span/attribute.
+type PostgresVault struct {
+	pool  *pgxpool.Pool
+	table string
+}
+
+// NewPostgresVault connects to Postgres and creates the vault table if needed.
+func NewPostgresVault(ctx context.Context, cfg PostgresConfig) (*PostgresVault, error) {
+	poolCfg, err := pgxpool.ParseConfig(string(cfg.DSN))
+	if err != nil {
+		return nil, fmt.Errorf("parse postgres dsn: %w", err)
+	}
+	if cfg.MaxConns > 0 {
+		poolCfg.MaxConns = cfg.MaxConns
+	}
+
+	pool, err := pgxpool.NewWithConfig(ctx, poolCfg)
+	if err != nil {
+		return nil, fmt.Errorf("connect postgres: %w", err)
+	}
+
+	v := &PostgresVault{
+		pool:  pool,
+		table: pgx.Identifier{cfg.Table}.Sanitize(),
+	}
+	if err := v.migrate(ctx, cfg.Table); err != nil {
+		pool.Close()
+		return nil, err
+	}
+	return v, nil
+}
+
+// openPostgresVault backs storage.backend postgres; builds tagged
+// promptvault_no_postgres leave pgx out and replace it with a stub.
+func openPostgresVault(ctx context.Context, cfg PostgresConfig) (VaultStorage, error) {
+	return NewPostgresVault(ctx, cfg)
+}
+
+func (v *PostgresVault) migrate(ctx context.Context, table string) error {
+	stmts := []string{
+		fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+			trace_id  TEXT        NOT NULL,
+			span_id   TEXT        NOT NULL,
+			attr_key  TEXT        NOT NULL,
+			version   INTEGER     NOT NULL DEFAULT 1,
+			sha256    TEXT        NOT NULL,
+			content   BYTEA       NOT NULL,
+			stored_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+			PRIMARY KEY (trace_id, span_id, attr_key, version)
+		)`, v.table),
+		fmt.Sprintf(`CREATE INDEX IF NOT EXISTS %s ON %s (trace_id)`,
+			pgx.Identifier{table + "_trace_id_idx"}.Sanitize(), v.table),
+		fmt.Sprintf(`CREATE INDEX IF NOT EXISTS %s ON %s (stored_at)`,
+			pgx.Identifier{table + "_stored_at_idx"}.Sanitize(), v.table),
+		fmt.Sprintf(`CREATE INDEX IF NOT EXISTS %s ON %s (sha256)`,
+			pgx.Identifier{table + "_sha256_idx"}.Sanitize(), v.table),
+	}
+	for _, stmt := range stmts {
+		if _, err := v.pool.Exec(ctx, stmt); err != nil {
+			return fmt.Errorf("create postgres schema: %w", err)
+		}
+	}
+	return v.migrateVersions(ctx, table)
+}
+
+// migrateVersions adds the version column to a table created before object
+// versioning, making its rows version 1, and moves the primary key onto the
+// versioned columns. The old key is looked up by type rather than assumed to
+// be <table>_pkey, which renamed tables and long names do not keep.
+func (v *PostgresVault) migrateVersions(ctx context.Context, table string) error {
+	var versioned bool
+	err := v.pool.QueryRow(ctx, `
+		SELECT EXISTS (SELECT 1 FROM information_schema.columns
+			WHERE table_schema = current_schema() AND table_name = $1 AND column_name = 'version')`,
+		table,
+	).Scan(&versioned)
+	if err != nil {
+		return fmt.Errorf("inspect postgres schema: %w", err)
+	}
+	if versioned {
+		return nil
+	}
+
+	var pkey string
+	err = v.pool.QueryRow(ctx, `
+		SELECT constraint_name FROM information_schema.table_constraints
+			WHERE table_schema = current_schema() AND table_name = $1 AND constraint_type = 'PRIMARY KEY'`,
+		table,
+	).Scan(&pkey)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("inspect postgres schema: %w", err)
+	}
+	dropKey := ""
+	if pkey != "" {
+		dropKey = fmt.Sprintf("DROP CONSTRAINT %s,", pgx.Identifier{pkey}.Sanitize())
+	}
+	_, err = v.pool.Exec(ctx, fmt.Sprintf(`
+		ALTER TABLE %s
+			ADD COLUMN version INTEGER NOT NULL DEFAULT 1,
+			%s
+			ADD PRIMARY KEY (trace_id, span_id, attr_key, version)`,
+		v.table, dropKey))
+	if err != nil {
+		return fmt.Errorf("migrate postgres schema: %w", err)
+	}
+	return nil
+}
+
+// Store records content as a new version of the span attribute and returns
+// a reference pinning that version. Storing the attribute's latest content
+// again, as SDK retries do, returns the existing reference.
+func (v *PostgresVault) Store(ctx context.Context, meta ObjectMeta, content []byte) (string, error) {
+	hexHash := contentHash(content)
+
+	// Collectors sharing the table can race for the next version; the loser
+	// hits the primary key (unique_violation) and tries again.
+	for attempt := 0; ; attempt++ {
+		ref, err := v.storeVersion(ctx, meta, hexHash, content)
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" && attempt < 3 {
+			continue
+		}
+		if err != nil {
+			return "", fmt.Errorf("insert vault row: %w", err)
+		}
+		return ref, nil
+	}
+}
+
+func (v *PostgresVault) storeVersion(ctx context.Context, meta ObjectMeta, hexHash string, content []byte) (string, error) {
+	var latestHash string
+	var latest int
+	err := v.pool.QueryRow(ctx, fmt.Sprintf(`
+		SELECT sha256, version FROM %s
+		WHERE trace_id = $1 AND span_id = $2 AND attr_key = $3
+		ORDER BY version DESC LIMIT 1`, v.table),
+		meta.TraceID, meta.SpanID, meta.Key,
+	).Scan(&latestHash, &latest)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return "", err
+	}
+	if latest > 0 && latestHash == hexHash {
+		return versionedRef(hexHash, latest), nil
+	}
+
+	_, err = v.pool.Exec(ctx, fmt.Sprintf(`
+		INSERT INTO %s (trace_id, span_id, attr_key, version, sha256, content)
+		VALUES ($1, $2, $3, $4, $5, $6)`, v.table),
+		meta.TraceID, meta.SpanID, meta.Key, latest+1, hexHash, content,
+	)
+	if err != nil {
+		return "", err
+	}
+	return versionedRef(hexHash, latest+1), nil
+}
+
+// refFilter returns the WHERE clause and arguments selecting the rows ref
+// names. A reference without a version matches every version with the same
+// content.
+func refFilter(ref string) (string, []any) {
+	if _, version := splitRefVersion(ref); version > 0 {
+		return `sha256 = $1 AND version = $2`, []any{hashFromRef(ref), version}
+	}
+	return `sha256 = $1`, []any{hashFromRef(ref)}
+}
+
+// Retrieve reads content back from the vault by reference.
+func (v *PostgresVault) Retrieve(ctx context.Context, ref string) ([]byte, error) {
+	var content []byte
+	where, args := refFilter(ref)
+	err := v.pool.QueryRow(ctx, fmt.Sprintf(`SELECT content FROM %s WHERE %s LIMIT 1`, v.table, where), args...).Scan(&content)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("%w: %s", ErrObjectNotFound, ref)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("query vault row: %w", err)
+	}
+	return content, nil
+}
+
+// Stat reports the size, store time, and span of the first row ref names.
+func (v *PostgresVault) Stat(ctx context.Context, ref string) (ObjectInfo, error) {
+	info := ObjectInfo{Ref: ref}
+	where, args := refFilter(ref)
+	err := v.pool.QueryRow(ctx, fmt.Sprintf(`
+		SELECT trace_id, span_id, attr_key, octet_length(content), stored_at FROM %s
+		WHERE %s ORDER BY stored_at, version LIMIT 1`, v.table, where), args...,
+	).Scan(&info.Meta.TraceID, &info.Meta.SpanID, &info.Meta.Key, &info.Size, &info.StoredAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ObjectInfo{}, fmt.Errorf("%w: %s", ErrObjectNotFound, ref)
+	}
+	if err != nil {
+		return ObjectInfo{}, fmt.Errorf("query vault row: %w", err)
+	}
+	return info, nil
+}
+
+// Delete removes the rows ref names: one version when ref pins it, and
+// every span's copy of the content otherwise.
+func (v *PostgresVault) Delete(ctx context.Context, ref string) error {
+	where, args := refFilter(ref)
+	tag, err := v.pool.Exec(ctx, fmt.Sprintf(`DELETE FROM %s WHERE %s`, v.table, where), args...)
+	if err != nil {
+		return fmt.Errorf("delete vault rows: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("%w: %s", ErrObjectNotFound, ref)
+	}
+	return nil
+}
+
+// Close releases the connection pool.
+func (v *PostgresVault) Close() error {
+	v.pool.Close()
+	return nil
+}
@@ -0,0 +1,16 @@
+//go:build promptvault_no_postgres
+
+package promptvaultprocessor
+
+import (
+	"context"
+	"errors"
+)
+
+// errPostgresExcluded is returned for storage.backend postgres by binaries
+// built with the promptvault_no_postgres tag.
+var errPostgresExcluded = errors.New("the postgres backend was excluded from this build (promptvault_no_postgres)")
+
+func openPostgresVault(context.Context, PostgresConfig) (VaultStorage, error) {
+	return nil, errPostgresExcluded
+}
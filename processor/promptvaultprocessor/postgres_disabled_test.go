@@ -0,0 +1,18 @@
+//go:build promptvault_no_postgres
+
+package promptvaultprocessor
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestPostgresExcluded(t *testing.T) {
+	_, err := newVaultStorage(context.Background(), zap.NewNop(), nil, StorageConfig{Backend: "postgres"})
+	if !errors.Is(err, errPostgresExcluded) {
+		t.Errorf("expected %v, got %v", errPostgresExcluded, err)
+	}
+}
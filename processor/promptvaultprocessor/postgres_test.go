@@ -0,0 +1,257 @@
+//go:build !promptvault_no_postgres
+
+package promptvaultprocessor
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"go.opentelemetry.io/collector/config/configopaque"
+)
+
+// testPostgresConfig points a vault at a fresh table in the database named
+// by PROMPTVAULT_TEST_POSTGRES_DSN, which is dropped after the test. Tests
+// that call it are skipped when the variable is unset.
+func testPostgresConfig(t *testing.T) PostgresConfig {
+	t.Helper()
+	dsn := os.Getenv("PROMPTVAULT_TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("PROMPTVAULT_TEST_POSTGRES_DSN is not set")
+	}
+	suffix := make([]byte, 6)
+	rand.Read(suffix)
+	table := "promptvault_test_" + hex.EncodeToString(suffix)
+	t.Cleanup(func() {
+		execPostgres(t, dsn, "DROP TABLE IF EXISTS "+pgx.Identifier{table}.Sanitize())
+	})
+	return PostgresConfig{DSN: configopaque.String(dsn), Table: table}
+}
+
+// execPostgres runs stmt on its own connection, outside any vault.
+func execPostgres(t *testing.T, dsn, stmt string) {
+	t.Helper()
+	ctx := context.Background()
+	conn, err := pgx.Connect(ctx, dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close(ctx)
+	if _, err := conn.Exec(ctx, stmt); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPostgresStoreAndRetrieve(t *testing.T) {
+	ctx := context.Background()
+	vault, err := openPostgresVault(ctx, testPostgresConfig(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer vault.(*PostgresVault).Close()
+
+	meta := ObjectMeta{TraceID: "trace1", SpanID: "span1", Key: "gen_ai.prompt"}
+	content := []byte("What is the capital of France?")
+	ref, err := vault.Store(ctx, meta, content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ref != refScheme+contentHash(content) {
+		t.Errorf("ref = %s, want the content hash", ref)
+	}
+	got, err := vault.Retrieve(ctx, ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != string(content) {
+		t.Errorf("retrieved %q, want %q", got, content)
+	}
+
+	// SDK retries store the same span attribute again.
+	again, err := vault.Store(ctx, meta, content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again != ref {
+		t.Errorf("restoring returned %s, want %s", again, ref)
+	}
+
+	missing := refScheme + contentHash([]byte("never stored"))
+	if _, err := vault.Retrieve(ctx, missing); !errors.Is(err, ErrObjectNotFound) {
+		t.Errorf("Retrieve(missing) = %v, want ErrObjectNotFound", err)
+	}
+}
+
+func TestPostgresStat(t *testing.T) {
+	ctx := context.Background()
+	vault, err := NewPostgresVault(ctx, testPostgresConfig(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer vault.Close()
+
+	before := time.Now().Add(-time.Minute)
+	meta := ObjectMeta{TraceID: "trace1", SpanID: "span1", Key: "gen_ai.completion"}
+	content := []byte("Paris.")
+	ref, err := vault.Store(ctx, meta, content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := vault.Stat(ctx, ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Ref != ref || info.Size != int64(len(content)) || info.Meta != meta {
+		t.Errorf("Stat = %+v, want ref %s, size %d, meta %+v", info, ref, len(content), meta)
+	}
+	if info.StoredAt.Before(before) {
+		t.Errorf("StoredAt = %v, want the store time", info.StoredAt)
+	}
+
+	if _, err := vault.Stat(ctx, refScheme+contentHash([]byte("never stored"))); !errors.Is(err, ErrObjectNotFound) {
+		t.Errorf("Stat(missing) = %v, want ErrObjectNotFound", err)
+	}
+}
+
+func TestPostgresDelete(t *testing.T) {
+	ctx := context.Background()
+	vault, err := NewPostgresVault(ctx, testPostgresConfig(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer vault.Close()
+
+	content := []byte("shared system prompt")
+	ref, err := vault.Store(ctx, ObjectMeta{TraceID: "trace1", SpanID: "span1", Key: "gen_ai.prompt"}, content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := vault.Store(ctx, ObjectMeta{TraceID: "trace2", SpanID: "span1", Key: "gen_ai.prompt"}, content); err != nil {
+		t.Fatal(err)
+	}
+	kept, err := vault.Store(ctx, ObjectMeta{TraceID: "trace1", SpanID: "span1", Key: "gen_ai.completion"}, []byte("answer"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// An unversioned ref deletes every span's copy of the content.
+	if err := vault.Delete(ctx, ref); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := vault.Retrieve(ctx, ref); !errors.Is(err, ErrObjectNotFound) {
+		t.Errorf("Retrieve after Delete = %v, want ErrObjectNotFound", err)
+	}
+	if _, err := vault.Stat(ctx, ref); !errors.Is(err, ErrObjectNotFound) {
+		t.Errorf("Stat after Delete = %v, want ErrObjectNotFound", err)
+	}
+	if err := vault.Delete(ctx, ref); !errors.Is(err, ErrObjectNotFound) {
+		t.Errorf("second Delete = %v, want ErrObjectNotFound", err)
+	}
+	if _, err := vault.Retrieve(ctx, kept); err != nil {
+		t.Errorf("other content deleted: %v", err)
+	}
+}
+
+func TestPostgresClose(t *testing.T) {
+	ctx := context.Background()
+	vault, err := NewPostgresVault(ctx, testPostgresConfig(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref, err := vault.Store(ctx, ObjectMeta{TraceID: "trace1", SpanID: "span1", Key: "gen_ai.prompt"}, []byte("prompt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := vault.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := vault.Retrieve(ctx, ref); err == nil {
+		t.Error("Retrieve succeeded on a closed vault")
+	}
+}
+
+func TestPostgresMigratesUnversionedTable(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		key  func(table string) string
+	}{
+		{"default key name", func(string) string {
+			return ", PRIMARY KEY (trace_id, span_id, attr_key)"
+		}},
+		{"renamed key", func(table string) string {
+			return ", CONSTRAINT " + pgx.Identifier{table + "_legacy"}.Sanitize() + " PRIMARY KEY (trace_id, span_id, attr_key)"
+		}},
+		{"no key", func(string) string { return "" }},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			cfg := testPostgresConfig(t)
+			table := pgx.Identifier{cfg.Table}.Sanitize()
+			// The schema releases before object versioning created.
+			execPostgres(t, string(cfg.DSN), `CREATE TABLE `+table+` (
+				trace_id  TEXT        NOT NULL,
+				span_id   TEXT        NOT NULL,
+				attr_key  TEXT        NOT NULL,
+				sha256    TEXT        NOT NULL,
+				content   BYTEA       NOT NULL,
+				stored_at TIMESTAMPTZ NOT NULL DEFAULT now()`+tc.key(cfg.Table)+`)`)
+			old := []byte("stored before versioning")
+			execPostgres(t, string(cfg.DSN), `INSERT INTO `+table+` (trace_id, span_id, attr_key, sha256, content)
+				VALUES ('trace1', 'span1', 'gen_ai.prompt', '`+contentHash(old)+`', '\x`+hex.EncodeToString(old)+`')`)
+
+			vault, err := NewPostgresVault(ctx, cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer vault.Close()
+
+			var keyColumns int
+			err = vault.pool.QueryRow(ctx, `SELECT array_length(indkey::int2[], 1) FROM pg_index
+				WHERE indrelid = $1::text::regclass AND indisprimary`, table).Scan(&keyColumns)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if keyColumns != 4 {
+				t.Errorf("primary key has %d columns, want trace_id, span_id, attr_key, version", keyColumns)
+			}
+
+			// Existing rows become version 1, so their references still resolve.
+			oldRef := refScheme + contentHash(old)
+			if got, err := vault.Retrieve(ctx, oldRef); err != nil || string(got) != string(old) {
+				t.Fatalf("Retrieve(%s) = %q, %v", oldRef, got, err)
+			}
+			meta := ObjectMeta{TraceID: "trace1", SpanID: "span1", Key: "gen_ai.prompt"}
+			if ref, err := vault.Store(ctx, meta, old); err != nil || ref != oldRef {
+				t.Errorf("restoring the migrated row = %s, %v; want %s", ref, err, oldRef)
+			}
+			next := []byte("re-exported with new content")
+			ref, err := vault.Store(ctx, meta, next)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ref != versionedRef(contentHash(next), 2) {
+				t.Errorf("second version ref = %s, want @2", ref)
+			}
+
+			// Later starts find the table migrated and leave it alone.
+			if err := vault.migrateVersions(ctx, cfg.Table); err != nil {
+				t.Errorf("migrating a migrated table: %v", err)
+			}
+			reopened, err := NewPostgresVault(ctx, cfg)
+			if err != nil {
+				t.Fatalf("reopening a migrated table: %v", err)
+			}
+			defer reopened.Close()
+			for _, r := range []string{oldRef, ref} {
+				if _, err := reopened.Retrieve(ctx, r); err != nil {
+					t.Errorf("Retrieve(%s) after reopening: %v", r, err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,145 @@
+package promptvaultprocessor
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+)
+
+// keyPresets are the content-bearing attribute keys of LLM instrumentation
+// libraries, added to vault.keys by vault.presets. Libraries that flatten
+// lists into indexed keys are matched with "*" in place of the index.
+var keyPresets = map[string][]string{
+	// OpenInference (Arize Phoenix) records messages as
+	// llm.input_messages.<i>.message.content, multimodal parts under
+	// message.contents.<j>, and whole inputs and outputs, often JSON, as
+	// input.value and output.value.
+	"openinference": {
+		"input.value",
+		"output.value",
+		"llm.input_messages.*.message.content",
+		"llm.input_messages.*.message.contents.*.message_content.text",
+		"llm.input_messages.*.message.tool_calls.*.tool_call.function.arguments",
+		"llm.output_messages.*.message.content",
+		"llm.output_messages.*.message.contents.*.message_content.text",
+		"llm.output_messages.*.message.tool_calls.*.tool_call.function.arguments",
+		"llm.prompt_template.template",
+		"llm.prompt_template.variables",
+		"retrieval.documents.*.document.content",
+		"reranker.input_documents.*.document.content",
+		"reranker.output_documents.*.document.content",
+		"embedding.embeddings.*.embedding.text",
+	},
+	// OpenLLMetry (Traceloop) records messages as gen_ai.prompt.<i>.content
+	// and gen_ai.completion.<i>.content, and workflow and task inputs and
+	// outputs as JSON in traceloop.entity.input and traceloop.entity.output.
+	"openllmetry": {
+		"gen_ai.prompt.*.content",
+		"gen_ai.prompt.*.tool_calls.*.arguments",
+		"gen_ai.completion.*.content",
+		"gen_ai.completion.*.tool_calls.*.arguments",
+		"traceloop.entity.input",
+		"traceloop.entity.output",
+	},
+	// LangChain tracing records run inputs and outputs, and the serialized
+	// chain, often over 100 KB, at the top level or per run type, e.g.
+	// langchain.chain.inputs.
+	"langchain": {
+		"langchain.inputs",
+		"langchain.outputs",
+		"langchain.serialized",
+		"langchain.*.inputs",
+		"langchain.*.outputs",
+		"langchain.*.serialized",
+	},
+}
+
+// KeyPresets returns the names vault.presets accepts.
+func KeyPresets() []string {
+	names := make([]string, 0, len(keyPresets))
+	for name := range keyPresets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// vaultKeys returns cfg's keys with its presets' keys added, rejecting
+// unknown presets and malformed patterns.
+func vaultKeys(cfg VaultConfig) ([]string, error) {
+	keys := append([]string(nil), cfg.Keys...)
+	for _, name := range cfg.Presets {
+		preset, ok := keyPresets[name]
+		if !ok {
+			return nil, fmt.Errorf("vault.presets: unknown preset %q, want one of %s", name, strings.Join(KeyPresets(), ", "))
+		}
+		keys = append(keys, preset...)
+	}
+	for _, key := range keys {
+		if _, err := path.Match(key, ""); err != nil {
+			return nil, fmt.Errorf("vault.keys: pattern %q: %w", key, err)
+		}
+	}
+	return keys, nil
+}
+
+// NewKeyMatcher returns a function reporting whether the processor would
+// vault an attribute key under cfg's keys and presets, and the configured
+// key or pattern it matched.
+func NewKeyMatcher(cfg VaultConfig) (func(key string) (string, bool), error) {
+	keys, err := vaultKeys(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return newKeyMatcher(keys).match, nil
+}
+
+// keyMatcher matches attribute keys against vault.keys. Keys containing
+// glob metacharacters are path.Match patterns; the rest match exactly.
+type keyMatcher struct {
+	exact    map[string]bool
+	patterns []string
+}
+
+func newKeyMatcher(keys []string) *keyMatcher {
+	m := &keyMatcher{exact: make(map[string]bool, len(keys))}
+	for _, k := range keys {
+		if strings.ContainsAny(k, `*?[\`) {
+			m.patterns = append(m.patterns, k)
+		} else {
+			m.exact[k] = true
+		}
+	}
+	return m
+}
+
+// match reports whether key is vaulted, and the configured key or pattern
+// it matched. Metrics are labeled with the latter, so indexed keys do not
+// each become a series.
+func (m *keyMatcher) match(key string) (string, bool) {
+	if m.exact[key] {
+		return key, true
+	}
+	// Patterns never match the attributes vaulting itself adds, such as
+	// gen_ai.prompt.vault_ref for "gen_ai.prompt.*".
+	if isCompanion(key) {
+		return "", false
+	}
+	for _, pattern := range m.patterns {
+		if ok, _ := path.Match(pattern, key); ok {
+			return pattern, true
+		}
+	}
+	return "", false
+}
+
+// matches is match without the matched pattern.
+func (m *keyMatcher) matches(key string) bool {
+	_, ok := m.match(key)
+	return ok
+}
+
+func (m *keyMatcher) len() int {
+	return len(m.exact) + len(m.patterns)
+}
@@ -0,0 +1,121 @@
+package promptvaultprocessor
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.uber.org/zap"
+)
+
+func TestOpenInferencePreset(t *testing.T) {
+	vault := NewMemoryVault(0, 0)
+	cfg := createDefaultConfig()
+	cfg.Vault.Presets = []string{"openinference"}
+	sink := new(consumertest.TracesSink)
+	proc := newVaultProcessor(zap.NewNop(), cfg, vault, sink)
+
+	td := ptrace.NewTraces()
+	span := td.ResourceSpans().AppendEmpty().ScopeSpans().AppendEmpty().Spans().AppendEmpty()
+	span.Attributes().PutStr("llm.input_messages.0.message.role", "user")
+	span.Attributes().PutStr("llm.input_messages.0.message.content", "what is my balance?")
+	span.Attributes().PutStr("llm.input_messages.12.message.contents.1.message_content.text", "see attached")
+	span.Attributes().PutStr("input.value", `{"messages":[{"role":"user","content":"what is my balance?"}]}`)
+	span.Attributes().PutStr("gen_ai.prompt", "default keys still apply")
+	proc.ConsumeTraces(context.Background(), td)
+
+	attrs := sink.AllTraces()[0].ResourceSpans().At(0).ScopeSpans().At(0).Spans().At(0).Attributes()
+	for _, key := range []string{
+		"llm.input_messages.0.message.content",
+		"llm.input_messages.12.message.contents.1.message_content.text",
+		"input.value",
+		"gen_ai.prompt",
+	} {
+		if v, _ := attrs.Get(key + ".vault_ref"); !strings.HasPrefix(v.Str(), refScheme) {
+			t.Errorf("%s not vaulted", key)
+		}
+	}
+	if role, _ := attrs.Get("llm.input_messages.0.message.role"); role.Str() != "user" {
+		t.Errorf("role = %q, want it left alone", role.Str())
+	}
+	if _, ok := attrs.Get("llm.input_messages.0.message.role.vault_ref"); ok {
+		t.Error("role vaulted")
+	}
+}
+
+func TestOpenLLMetryPreset(t *testing.T) {
+	vault := NewMemoryVault(0, 0)
+	cfg := createDefaultConfig()
+	cfg.Vault.Presets = []string{"openllmetry"}
+	sink := new(consumertest.TracesSink)
+	proc := newVaultProcessor(zap.NewNop(), cfg, vault, sink)
+
+	td := ptrace.NewTraces()
+	span := td.ResourceSpans().AppendEmpty().ScopeSpans().AppendEmpty().Spans().AppendEmpty()
+	span.Attributes().PutStr("gen_ai.prompt.0.role", "system")
+	span.Attributes().PutStr("gen_ai.prompt.0.content", "You are a helpful assistant.")
+	span.Attributes().PutStr("gen_ai.prompt.1.content", "Summarize my last order.")
+	span.Attributes().PutStr("gen_ai.completion.0.content", "You ordered two books.")
+	span.Attributes().PutStr("gen_ai.completion.0.tool_calls.0.arguments", `{"order_id":42}`)
+	span.Attributes().PutStr("traceloop.entity.input", `{"inputs":{"question":"my last order?"}}`)
+	proc.ConsumeTraces(context.Background(), td)
+
+	attrs := sink.AllTraces()[0].ResourceSpans().At(0).ScopeSpans().At(0).Spans().At(0).Attributes()
+	for _, key := range []string{
+		"gen_ai.prompt.0.content",
+		"gen_ai.prompt.1.content",
+		"gen_ai.completion.0.content",
+		"gen_ai.completion.0.tool_calls.0.arguments",
+		"traceloop.entity.input",
+	} {
+		if v, _ := attrs.Get(key + ".vault_ref"); !strings.HasPrefix(v.Str(), refScheme) {
+			t.Errorf("%s not vaulted", key)
+		}
+	}
+	if _, ok := attrs.Get("gen_ai.prompt.0.role.vault_ref"); ok {
+		t.Error("role vaulted")
+	}
+	if vault.Len() != 5 {
+		t.Errorf("vault holds %d objects, want 5", vault.Len())
+	}
+}
+
+func TestLangChainPreset(t *testing.T) {
+	keys, err := vaultKeys(VaultConfig{Presets: []string{"langchain"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := newKeyMatcher(keys)
+	for _, key := range []string{"langchain.inputs", "langchain.chain.outputs", "langchain.llm.serialized"} {
+		if !m.matches(key) {
+			t.Errorf("%s not matched", key)
+		}
+	}
+	for _, key := range []string{"langchain.run_type", "langchain.chain.name", "gen_ai.prompt"} {
+		if m.matches(key) {
+			t.Errorf("%s matched", key)
+		}
+	}
+}
+
+func TestVaultKeysValidation(t *testing.T) {
+	if _, err := vaultKeys(VaultConfig{Presets: []string{"nope"}}); err == nil {
+		t.Error("unknown preset accepted")
+	}
+	if _, err := vaultKeys(VaultConfig{Keys: []string{"llm.[input"}}); err == nil {
+		t.Error("malformed pattern accepted")
+	}
+
+	m := newKeyMatcher([]string{"gen_ai.prompt", "llm.output_messages.*.message.content"})
+	if got, ok := m.match("llm.output_messages.3.message.content"); !ok || got != "llm.output_messages.*.message.content" {
+		t.Errorf("match = %q, %v", got, ok)
+	}
+	if m.matches("llm.output_messages.3.message.content.vault_ref") || m.matches("gen_ai.prompt.0") {
+		t.Error("pattern matched beyond its end")
+	}
+	if newKeyMatcher([]string{"gen_ai.prompt.*"}).matches("gen_ai.prompt.vault_ref") {
+		t.Error("pattern matched a reference attribute")
+	}
+}
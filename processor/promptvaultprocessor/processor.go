@@ -2,6 +2,12 @@ package promptvaultprocessor
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
 
 	"go.opentelemetry.io/collector/component"
 	"go.opentelemetry.io/collector/consumer"
@@ -15,7 +21,21 @@ type vaultProcessor struct {
 	config       *Config
 	vault        VaultStorage
 	nextConsumer consumer.Traces
-	keysSet      map[string]bool
+	keys         *keyMatcher
+	signer       *RefSigner
+	redactor     *piiScanner
+	keptRedactor *piiScanner
+	tokenizer    *tokenizer
+	metrics      *vaultMetrics
+	storeEvents  *storeEvents
+	notifier     *storeNotifier
+	policy       *policyClient
+	transforms   transformChain
+	reportStatus func(*component.StatusEvent)
+	healthy      atomic.Bool
+	// archive stores content without touching the spans or passing them
+	// on; see NewArchiver.
+	archive bool
 }
 
 func newVaultProcessor(
@@ -24,98 +44,328 @@ func newVaultProcessor(
 	vault VaultStorage,
 	next consumer.Traces,
 ) *vaultProcessor {
-	keysSet := make(map[string]bool, len(cfg.Vault.Keys))
-	for _, k := range cfg.Vault.Keys {
-		keysSet[k] = true
-	}
+	// The factory has already rejected invalid presets and patterns.
+	keys, _ := vaultKeys(cfg.Vault)
 
-	return &vaultProcessor{
+	p := &vaultProcessor{
 		logger:       logger,
 		config:       cfg,
 		vault:        vault,
 		nextConsumer: next,
-		keysSet:      keysSet,
+		keys:         newKeyMatcher(keys),
+		reportStatus: func(*component.StatusEvent) {},
 	}
+	p.healthy.Store(true)
+	return p
 }
 
-func (p *vaultProcessor) Start(_ context.Context, _ component.Host) error {
+func (p *vaultProcessor) Start(ctx context.Context, host component.Host) error {
+	if p.config.Storage.Extension != "" {
+		vault, err := VaultFromHost(host, p.config.Storage.Extension)
+		if err != nil {
+			return err
+		}
+		p.vault = vault
+	}
+	if s, ok := p.vault.(vaultStarter); ok {
+		if err := s.Start(ctx); err != nil {
+			return err
+		}
+	}
+
+	if p.config.Storage.CheckOnStart {
+		if err := probeVault(ctx, p.vault); err != nil {
+			p.reportStatus(component.NewPermanentErrorEvent(err))
+			return err
+		}
+		p.logger.Info("vault backend health check passed")
+	}
+
 	p.logger.Info("promptvault processor started",
-		zap.Int("vault_keys", len(p.keysSet)),
+		zap.Int("vault_keys", p.keys.len()),
 		zap.String("mode", p.config.Vault.Mode),
 		zap.String("backend", p.config.Storage.Backend),
 	)
 	return nil
 }
 
-func (p *vaultProcessor) Shutdown(_ context.Context) error {
-	return nil
+func (p *vaultProcessor) Shutdown(ctx context.Context) error {
+	// Zero the HMAC keys held here; backends and encryptors zero their own
+	// key material on Close.
+	if p.signer != nil {
+		clear(p.signer.key)
+		p.signer = nil
+	}
+	if p.tokenizer != nil {
+		clear(p.tokenizer.key)
+		p.tokenizer = nil
+	}
+	var errs []error
+	if p.storeEvents != nil {
+		errs = append(errs, p.storeEvents.Close(ctx))
+	}
+	if p.notifier != nil {
+		errs = append(errs, p.notifier.Close(ctx))
+	}
+	if c, ok := p.vault.(io.Closer); ok {
+		errs = append(errs, c.Close())
+	}
+	return errors.Join(errs...)
 }
 
 func (p *vaultProcessor) Capabilities() consumer.Capabilities {
-	return consumer.Capabilities{MutatesData: true}
+	return consumer.Capabilities{MutatesData: !p.archive}
 }
 
 func (p *vaultProcessor) ConsumeTraces(ctx context.Context, td ptrace.Traces) error {
 	rss := td.ResourceSpans()
 	for i := 0; i < rss.Len(); i++ {
+		res := rss.At(i).Resource()
+		resMeta := p.resourceMeta(res)
+		var resAttrs map[string]string
+		if p.policy != nil {
+			resAttrs = attributeStrings(res.Attributes(), nil)
+		}
 		ilss := rss.At(i).ScopeSpans()
 		for j := 0; j < ilss.Len(); j++ {
 			spans := ilss.At(j).Spans()
 			for k := 0; k < spans.Len(); k++ {
-				p.vaultSpan(spans.At(k))
+				p.vaultSpan(ctx, resMeta, resAttrs, spans.At(k))
 			}
 		}
 	}
+	if p.archive {
+		return nil
+	}
 	return p.nextConsumer.ConsumeTraces(ctx, td)
 }
 
-func (p *vaultProcessor) vaultSpan(span ptrace.Span) {
+// resourceMeta extracts the object metadata shared by all spans of a resource.
+func (p *vaultProcessor) resourceMeta(res pcommon.Resource) ObjectMeta {
+	var meta ObjectMeta
+	attrs := res.Attributes()
+	if v, ok := attrs.Get("service.name"); ok {
+		meta.Service = v.Str()
+	}
+	if p.config.Vault.TenantAttribute != "" {
+		if v, ok := attrs.Get(p.config.Vault.TenantAttribute); ok {
+			meta.Tenant = v.AsString()
+		}
+	}
+	if p.config.Vault.EndUserAttribute != "" {
+		if v, ok := attrs.Get(p.config.Vault.EndUserAttribute); ok {
+			meta.EndUser = v.AsString()
+		}
+	}
+	if p.config.Vault.RegionAttribute != "" {
+		if v, ok := attrs.Get(p.config.Vault.RegionAttribute); ok {
+			meta.Region = v.AsString()
+		}
+	}
+	return meta
+}
+
+// vaultSpan vaults the span's matching attributes. resAttrs are the
+// resource's attributes, passed to the policy engine if one is configured.
+func (p *vaultProcessor) vaultSpan(ctx context.Context, resMeta ObjectMeta, resAttrs map[string]string, span ptrace.Span) {
 	attrs := span.Attributes()
 
 	// Collect keys to vault (can't modify map while iterating)
 	type vaultEntry struct {
 		key     string
 		content string
+		// matched is the vault.keys entry that matched key, labeling metrics.
+		matched string
 	}
 	var toVault []vaultEntry
 
 	attrs.Range(func(key string, val pcommon.Value) bool {
-		if !p.keysSet[key] {
+		matched, ok := p.keys.match(key)
+		if !ok {
 			return true
 		}
 
 		content := val.Str()
-		if len(content) < p.config.Vault.SizeThreshold {
+		if len(content) < int(p.config.Vault.SizeThreshold) {
+			p.metrics.recordMatched(ctx, matched, outcomeBelowThreshold)
 			return true
 		}
 
-		toVault = append(toVault, vaultEntry{key: key, content: content})
+		toVault = append(toVault, vaultEntry{key: key, content: content, matched: matched})
 		return true
 	})
 
+	var spanAttrs map[string]string
+	if p.policy != nil && len(toVault) > 0 {
+		spanAttrs = attributeStrings(attrs, p.keys.matches)
+	}
 	for _, entry := range toVault {
-		ref, err := p.vault.Store([]byte(entry.content))
+		meta := resMeta
+		meta.TraceID = span.TraceID().String()
+		meta.SpanID = span.SpanID().String()
+		meta.Key = entry.key
+		if p.config.Vault.EndUserAttribute != "" {
+			// The span's own attribute takes precedence over the resource's.
+			if v, ok := attrs.Get(p.config.Vault.EndUserAttribute); ok {
+				meta.EndUser = v.AsString()
+			}
+		}
+		if p.policy != nil {
+			decision, err := p.policy.decide(ctx, PolicyInput{
+				Service:            meta.Service,
+				Tenant:             meta.Tenant,
+				TraceID:            meta.TraceID,
+				SpanID:             meta.SpanID,
+				SpanName:           span.Name(),
+				Key:                entry.key,
+				Size:               len(entry.content),
+				ResourceAttributes: resAttrs,
+				SpanAttributes:     spanAttrs,
+			})
+			if err != nil {
+				p.logger.Warn("vault policy failed; applying on_error decision",
+					zap.String("key", entry.key),
+					zap.String("decision", decision),
+					zap.Error(err),
+				)
+			}
+			if decision != PolicyOffload {
+				p.applyPolicy(ctx, attrs, entry.key, entry.matched, entry.content, decision)
+				continue
+			}
+		}
+		kept := entry.content
+		if p.redactor != nil {
+			var counts map[string]int
+			entry.content, counts = p.redactor.redact(entry.content)
+			p.metrics.recordRedactions(ctx, counts)
+		}
+		content := []byte(entry.content)
+		var tokenized string
+		if p.config.Vault.Mode == "tokenize" && !p.archive {
+			var mapping map[string]string
+			tokenized, mapping = p.tokenizer.tokenize(entry.content)
+			if len(mapping) == 0 {
+				p.metrics.recordMatched(ctx, entry.matched, outcomeNoDetections)
+				continue
+			}
+			content, _ = json.Marshal(mapping)
+		}
+		if len(p.transforms) > 0 {
+			var err error
+			if content, meta, err = p.transforms.apply(ctx, meta, content); err != nil {
+				p.metrics.recordMatched(ctx, entry.matched, outcomeTransformFailed)
+				p.logger.Warn("vault transform failed",
+					zap.String("key", entry.key),
+					zap.Error(err),
+				)
+				continue
+			}
+		}
+		start := time.Now()
+		ref, err := p.vault.Store(ctx, meta, content)
+		if p.storeEvents != nil {
+			p.storeEvents.record(ctx, meta, ref, content, time.Since(start), err)
+		}
+		if errors.Is(err, ErrQuotaExceeded) {
+			p.metrics.recordMatched(ctx, entry.matched, outcomeQuotaExceeded)
+			p.logger.Debug("vault quota exceeded", zap.String("key", entry.key), zap.Error(err))
+			if p.config.Vault.OnQuotaExceeded == "hash_only" && !p.archive {
+				attrs.PutStr(entry.key, "sha256:"+contentHash([]byte(entry.content)))
+			}
+			continue
+		}
+		// An object with no storage for its region says nothing about the
+		// backend's health.
+		if !errors.Is(err, ErrUnroutable) {
+			p.reportStoreResult(err)
+		}
 		if err != nil {
+			p.metrics.recordMatched(ctx, entry.matched, outcomeStoreFailed)
 			p.logger.Warn("vault store failed",
 				zap.String("key", entry.key),
 				zap.Error(err),
 			)
 			continue
 		}
+		p.metrics.recordMatched(ctx, entry.matched, outcomeVaulted)
+		if p.notifier != nil {
+			p.notifier.notify(meta, ref, len(content))
+		}
+		p.logger.Debug("vaulted attribute",
+			zap.String("key", entry.key),
+			zap.String("ref", ref),
+			zap.Int("content_bytes", len(content)),
+		)
+		if p.archive {
+			continue
+		}
 
+		// In link format the span carries the link in place of the reference.
+		written := ref
+		if p.config.Vault.RefFormat == "link" {
+			written = expandLinkTemplate(p.config.Vault.LinkTemplate, ref, meta)
+		}
 		switch p.config.Vault.Mode {
 		case "replace_with_ref":
-			attrs.PutStr(entry.key, ref)
-			attrs.PutStr(entry.key+".vault_ref", ref)
+			attrs.PutStr(entry.key, written)
+			p.putCompanion(attrs, entry.key, "ref", written)
 		case "remove":
 			attrs.Remove(entry.key)
-			attrs.PutStr(entry.key+".vault_ref", ref)
+			p.putCompanion(attrs, entry.key, "ref", written)
+		case "keep_and_ref":
+			attrs.PutStr(entry.key, p.keptCopy(ctx, kept))
+			p.putCompanion(attrs, entry.key, "ref", written)
+		case "tokenize":
+			attrs.PutStr(entry.key, tokenized)
+			p.putCompanion(attrs, entry.key, "ref", written)
 		}
+		if p.config.Vault.LinkTemplate != "" && p.config.Vault.RefFormat != "link" {
+			p.putCompanion(attrs, entry.key, "url", expandLinkTemplate(p.config.Vault.LinkTemplate, ref, meta))
+		}
+		if p.signer != nil {
+			p.putCompanion(attrs, entry.key, "sig", p.signer.Sign(ref, contentHash(content), len(content)))
+		}
+	}
+}
 
-		p.logger.Debug("vaulted attribute",
-			zap.String("key", entry.key),
-			zap.String("ref", ref),
-			zap.Int("content_bytes", len(entry.content)),
-		)
+// applyPolicy carries out a policy decision other than offload for the
+// attribute key holding content.
+func (p *vaultProcessor) applyPolicy(ctx context.Context, attrs pcommon.Map, key, matched, content, decision string) {
+	switch {
+	case p.archive:
+		// Nothing is stored, and the span is left as received.
+	case decision == PolicyDrop:
+		attrs.Remove(key)
+	case decision == PolicyRedact:
+		redacted := "[REDACTED:POLICY]"
+		if p.redactor != nil {
+			var counts map[string]int
+			redacted, counts = p.redactor.redact(content)
+			p.metrics.recordRedactions(ctx, counts)
+		}
+		attrs.PutStr(key, redacted)
+	}
+	p.metrics.recordMatched(ctx, matched, outcomePolicyPrefix+decision)
+}
+
+// keptCopy applies the kept_copy_transform to the value left in the span.
+func (p *vaultProcessor) keptCopy(ctx context.Context, content string) string {
+	if p.keptRedactor != nil {
+		var counts map[string]int
+		content, counts = p.keptRedactor.redact(content)
+		p.metrics.recordRedactions(ctx, counts)
+	}
+	return truncateUTF8(content, int(p.config.Vault.KeptCopyTransform.MaxLength))
+}
+
+// truncateUTF8 cuts s to at most n bytes without splitting a rune.
+func truncateUTF8(s string, n int) string {
+	if n <= 0 || len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
 	}
+	return s[:n]
 }
\ No newline at end of file
@@ -6,6 +6,7 @@ import (
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"go.opentelemetry.io/collector/consumer/consumertest"
 	"go.opentelemetry.io/collector/pdata/ptrace"
@@ -14,7 +15,7 @@ import (
 
 func TestVaultReplacesContent(t *testing.T) {
 	tmpDir := t.TempDir()
-	vault, err := NewFilesystemVault(tmpDir)
+	vault, err := NewFilesystemVault(FilesystemConfig{BasePath: tmpDir})
 	if err != nil {
 		t.Fatalf("failed to create vault: %v", err)
 	}
@@ -59,9 +60,9 @@ func TestVaultReplacesContent(t *testing.T) {
 
 func TestVaultWritesToDisk(t *testing.T) {
 	tmpDir := t.TempDir()
-	vault, _ := NewFilesystemVault(tmpDir)
+	vault, _ := NewFilesystemVault(FilesystemConfig{BasePath: tmpDir})
 
-	ref, err := vault.Store([]byte("Hello, World!"))
+	ref, err := vault.Store(context.Background(), ObjectMeta{}, []byte("Hello, World!"))
 	if err != nil {
 		t.Fatalf("vault store failed: %v", err)
 	}
@@ -88,10 +89,10 @@ func TestVaultWritesToDisk(t *testing.T) {
 
 func TestVaultDeduplication(t *testing.T) {
 	tmpDir := t.TempDir()
-	vault, _ := NewFilesystemVault(tmpDir)
+	vault, _ := NewFilesystemVault(FilesystemConfig{BasePath: tmpDir})
 
-	ref1, _ := vault.Store([]byte("duplicate content"))
-	ref2, _ := vault.Store([]byte("duplicate content"))
+	ref1, _ := vault.Store(context.Background(), ObjectMeta{}, []byte("duplicate content"))
+	ref2, _ := vault.Store(context.Background(), ObjectMeta{}, []byte("duplicate content"))
 
 	if ref1 != ref2 {
 		t.Errorf("expected same ref for same content, got %s and %s", ref1, ref2)
@@ -100,7 +101,7 @@ func TestVaultDeduplication(t *testing.T) {
 
 func TestVaultSkipsSmallContent(t *testing.T) {
 	tmpDir := t.TempDir()
-	vault, _ := NewFilesystemVault(tmpDir)
+	vault, _ := NewFilesystemVault(FilesystemConfig{BasePath: tmpDir})
 	cfg := createDefaultConfig()
 	cfg.Vault.SizeThreshold = 1000 // Only vault content > 1000 bytes
 	sink := new(consumertest.TracesSink)
@@ -122,7 +123,7 @@ func TestVaultSkipsSmallContent(t *testing.T) {
 
 func TestVaultRemoveMode(t *testing.T) {
 	tmpDir := t.TempDir()
-	vault, _ := NewFilesystemVault(tmpDir)
+	vault, _ := NewFilesystemVault(FilesystemConfig{BasePath: tmpDir})
 	cfg := createDefaultConfig()
 	cfg.Vault.Mode = "remove"
 	sink := new(consumertest.TracesSink)
@@ -145,17 +146,86 @@ func TestVaultRemoveMode(t *testing.T) {
 	}
 }
 
+func TestVaultKeepAndRefMode(t *testing.T) {
+	vault := NewMemoryVault(0, 0)
+	cfg := createDefaultConfig()
+	cfg.Vault.Mode = "keep_and_ref"
+	cfg.Vault.KeptCopyTransform.MaxLength = 24
+	sink := new(consumertest.TracesSink)
+	proc := newVaultProcessor(zap.NewNop(), cfg, vault, sink)
+	proc.keptRedactor, _ = newPIIScanner(RedactionConfig{Detectors: RedactionDetectors{Email: true}})
+
+	raw := "mail carol@example.com about the quarterly numbers"
+	td := ptrace.NewTraces()
+	span := td.ResourceSpans().AppendEmpty().ScopeSpans().AppendEmpty().Spans().AppendEmpty()
+	span.Attributes().PutStr("gen_ai.prompt", raw)
+
+	proc.ConsumeTraces(context.Background(), td)
+
+	attrs := sink.AllTraces()[0].ResourceSpans().At(0).ScopeSpans().At(0).Spans().At(0).Attributes()
+	kept, _ := attrs.Get("gen_ai.prompt")
+	if kept.Str() != "mail [REDACTED:EMAIL] ab" {
+		t.Errorf("kept copy = %q", kept.Str())
+	}
+	ref, ok := attrs.Get("gen_ai.prompt.vault_ref")
+	if !ok {
+		t.Fatal("expected gen_ai.prompt.vault_ref to exist in keep_and_ref mode")
+	}
+	stored, err := vault.Retrieve(context.Background(), ref.Str())
+	if err != nil || string(stored) != raw {
+		t.Errorf("vault holds %q, %v; want the raw value", stored, err)
+	}
+}
+
+func TestArchiver(t *testing.T) {
+	vault := NewMemoryVault(0, 0)
+	cfg := createDefaultConfig()
+	proc := newVaultProcessor(zap.NewNop(), cfg, vault, nil)
+	proc.archive = true
+	proc.signer, _ = NewRefSigner("0123456789abcdef0123456789abcdef")
+
+	if proc.Capabilities().MutatesData {
+		t.Error("an archiver must not declare that it mutates data")
+	}
+
+	raw := "the full prompt, archived as-is"
+	td := ptrace.NewTraces()
+	span := td.ResourceSpans().AppendEmpty().ScopeSpans().AppendEmpty().Spans().AppendEmpty()
+	span.Attributes().PutStr("gen_ai.prompt", raw)
+
+	if err := proc.ConsumeTraces(context.Background(), td); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := span.Attributes().AsRaw(); len(got) != 1 || got["gen_ai.prompt"] != raw {
+		t.Errorf("the archiver changed the span: %v", got)
+	}
+	stored, err := vault.Retrieve(context.Background(), "vault://"+contentHash([]byte(raw)))
+	if err != nil || string(stored) != raw {
+		t.Errorf("vault holds %q, %v; want the raw value", stored, err)
+	}
+}
+
+func TestTruncateUTF8(t *testing.T) {
+	if got := truncateUTF8("héllo", 2); got != "h" {
+		t.Errorf("got %q, want rune-aligned cut", got)
+	}
+	if got := truncateUTF8("héllo", 0); got != "héllo" {
+		t.Errorf("got %q, want no limit", got)
+	}
+}
+
 func TestVaultRetrieve(t *testing.T) {
 	tmpDir := t.TempDir()
-	vault, _ := NewFilesystemVault(tmpDir)
+	vault, _ := NewFilesystemVault(FilesystemConfig{BasePath: tmpDir})
 
 	original := "This is the content to vault and retrieve"
-	ref, err := vault.Store([]byte(original))
+	ref, err := vault.Store(context.Background(), ObjectMeta{}, []byte(original))
 	if err != nil {
 		t.Fatalf("store failed: %v", err)
 	}
 
-	data, err := vault.Retrieve(ref)
+	data, err := vault.Retrieve(context.Background(), ref)
 	if err != nil {
 		t.Fatalf("retrieve failed: %v", err)
 	}
@@ -163,4 +233,102 @@ func TestVaultRetrieve(t *testing.T) {
 	if string(data) != original {
 		t.Errorf("expected %q, got %q", original, string(data))
 	}
-}
\ No newline at end of file
+}
+
+func TestVaultAtomicWriteLeavesNoTempFiles(t *testing.T) {
+	tmpDir := t.TempDir()
+	vault, err := NewFilesystemVault(FilesystemConfig{BasePath: tmpDir, Sync: true})
+	if err != nil {
+		t.Fatalf("failed to create vault: %v", err)
+	}
+
+	if _, err := vault.Store(context.Background(), ObjectMeta{}, []byte("durable content")); err != nil {
+		t.Fatalf("store failed: %v", err)
+	}
+
+	filepath.Walk(tmpDir, func(path string, info os.FileInfo, err error) error {
+		if err == nil && strings.HasPrefix(info.Name(), ".tmp-") {
+			t.Errorf("unexpected temp file left behind: %s", path)
+		}
+		return nil
+	})
+}
+
+func TestVaultShardedLayout(t *testing.T) {
+	tmpDir := t.TempDir()
+	ctx := context.Background()
+
+	// An object written with the date layout stays retrievable after switching.
+	dated, _ := NewFilesystemVault(FilesystemConfig{BasePath: tmpDir, Layout: "date"})
+	oldRef, _ := dated.Store(ctx, ObjectMeta{}, []byte("written before sharding"))
+
+	vault, err := NewFilesystemVault(FilesystemConfig{BasePath: tmpDir, Layout: "sharded"})
+	if err != nil {
+		t.Fatalf("failed to create vault: %v", err)
+	}
+	ref, err := vault.Store(ctx, ObjectMeta{}, []byte("sharded content"))
+	if err != nil {
+		t.Fatalf("store failed: %v", err)
+	}
+
+	hexHash := strings.TrimPrefix(ref, "vault://")
+	if _, err := os.Stat(filepath.Join(tmpDir, hexHash[0:2], hexHash[2:4], hexHash+".vault")); err != nil {
+		t.Errorf("expected object under shard directory: %v", err)
+	}
+
+	for _, r := range []string{ref, oldRef} {
+		if _, err := vault.Retrieve(ctx, r); err != nil {
+			t.Errorf("retrieve %s failed: %v", r, err)
+		}
+	}
+
+	if _, err := vault.Retrieve(ctx, "vault://../../etc/passwd"); err == nil {
+		t.Error("expected error for ref that is not a content hash")
+	}
+}
+
+func TestVaultEvictsOldestOverQuota(t *testing.T) {
+	tmpDir := t.TempDir()
+	ctx := context.Background()
+	vault, _ := NewFilesystemVault(FilesystemConfig{BasePath: tmpDir, Layout: "sharded", MaxObjects: 2})
+
+	var refs []string
+	for i, content := range []string{"first", "second", "third"} {
+		ref, _ := vault.Store(ctx, ObjectMeta{}, []byte(content))
+		path, _ := vault.findPath(ref)
+		age := time.Now().Add(-time.Duration(3-i) * time.Hour)
+		os.Chtimes(path, age, age)
+		refs = append(refs, ref)
+	}
+
+	if err := vault.Evict(ctx); err != nil {
+		t.Fatalf("evict failed: %v", err)
+	}
+
+	if _, err := vault.Retrieve(ctx, refs[0]); err == nil {
+		t.Error("expected oldest object to be evicted")
+	}
+	for _, ref := range refs[1:] {
+		if _, err := vault.Retrieve(ctx, ref); err != nil {
+			t.Errorf("expected %s to be kept: %v", ref, err)
+		}
+	}
+}
+
+func TestVaultEvictsExpired(t *testing.T) {
+	tmpDir := t.TempDir()
+	ctx := context.Background()
+	vault, _ := NewFilesystemVault(FilesystemConfig{BasePath: tmpDir, TTL: time.Hour})
+
+	ref, _ := vault.Store(ctx, ObjectMeta{}, []byte("stale"))
+	path, _ := vault.findPath(ref)
+	old := time.Now().Add(-2 * time.Hour)
+	os.Chtimes(path, old, old)
+
+	if err := vault.Evict(ctx); err != nil {
+		t.Fatalf("evict failed: %v", err)
+	}
+	if _, err := vault.Retrieve(ctx, ref); err == nil {
+		t.Error("expected expired object to be evicted")
+	}
+}
@@ -0,0 +1,375 @@
+package promptvaultprocessor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.uber.org/zap"
+)
+
+// ErrQuotaExceeded is returned, wrapped, when a store would take a tenant
+// past its quota.
+var ErrQuotaExceeded = errors.New("tenant quota exceeded")
+
+// QuotaConfig tracks stored bytes and objects per tenant and caps them, so
+// one tenant cannot consume the whole vault.
+type QuotaConfig struct {
+	// Enabled tracks usage even when no limits are set.
+	Enabled bool `mapstructure:"enabled"`
+	// Default applies to tenants without an entry in Tenants, including
+	// objects stored without a tenant.
+	Default TenantQuota `mapstructure:"default"`
+	// Tenants sets per-tenant limits.
+	Tenants map[string]TenantQuota `mapstructure:"tenants"`
+	// RecountInterval is how often usage is recounted from the vault,
+	// picking up objects removed outside this collector. Default 1h.
+	RecountInterval time.Duration `mapstructure:"recount_interval"`
+}
+
+// TenantQuota limits one tenant's stored objects. Zero means no limit.
+type TenantQuota struct {
+	MaxBytes   ByteSize `mapstructure:"max_bytes"`
+	MaxObjects int64    `mapstructure:"max_objects"`
+}
+
+func validateOnQuotaExceeded(action string) error {
+	switch action {
+	case "", "reject", "hash_only":
+		return nil
+	}
+	return fmt.Errorf("unknown vault.on_quota_exceeded %q", action)
+}
+
+func (c QuotaConfig) enabled() bool {
+	return c.Enabled || c.Default != (TenantQuota{}) || len(c.Tenants) > 0
+}
+
+// TenantUsage is what one tenant has stored, as stored after compression
+// and encryption, and its quota.
+type TenantUsage struct {
+	Tenant     string `json:"tenant"`
+	Bytes      int64  `json:"bytes"`
+	Objects    int64  `json:"objects"`
+	MaxBytes   int64  `json:"max_bytes,omitempty"`
+	MaxObjects int64  `json:"max_objects,omitempty"`
+}
+
+// VaultUsageReporter is implemented by vaults that track per-tenant usage.
+type VaultUsageReporter interface {
+	Usage(ctx context.Context) ([]TenantUsage, error)
+}
+
+// quotaVault counts each tenant's objects and rejects stores over quota.
+// Counts are kept up to date by stores and deletes through it and recounted
+// from the vault on Start and every recount interval, so between recounts
+// they are approximate: a store of content the vault already holds counts
+// again, and objects evicted by the backend are still counted.
+type quotaVault struct {
+	VaultStorage
+	cfg      QuotaConfig
+	interval time.Duration
+	logger   *zap.Logger
+	reg      metric.Registration
+
+	mu    sync.Mutex
+	usage map[string]*TenantUsage
+
+	stop   chan struct{}
+	cancel context.CancelFunc
+	done   sync.WaitGroup
+}
+
+func newQuotaVault(logger *zap.Logger, metrics *vaultMetrics, vault VaultStorage, cfg QuotaConfig) (VaultStorage, error) {
+	if !cfg.enabled() {
+		return vault, nil
+	}
+	for tenant, q := range cfg.Tenants {
+		if q.MaxBytes < 0 || q.MaxObjects < 0 {
+			return nil, fmt.Errorf("storage.quotas.tenants.%s: limits must not be negative", tenant)
+		}
+	}
+	if cfg.Default.MaxBytes < 0 || cfg.Default.MaxObjects < 0 {
+		return nil, fmt.Errorf("storage.quotas.default: limits must not be negative")
+	}
+	interval := cfg.RecountInterval
+	if interval <= 0 {
+		interval = time.Hour
+	}
+	v := &quotaVault{
+		VaultStorage: vault,
+		cfg:          cfg,
+		interval:     interval,
+		logger:       logger,
+		usage:        make(map[string]*TenantUsage),
+		stop:         make(chan struct{}),
+	}
+	reg, err := metrics.observeUsage(v.snapshot)
+	if err != nil {
+		return nil, err
+	}
+	v.reg = reg
+	return v, nil
+}
+
+func (v *quotaVault) quota(tenant string) TenantQuota {
+	if q, ok := v.cfg.Tenants[tenant]; ok {
+		return q
+	}
+	return v.cfg.Default
+}
+
+// tenantLocked returns the usage entry for tenant, creating it.
+func (v *quotaVault) tenantLocked(tenant string) *TenantUsage {
+	u, ok := v.usage[tenant]
+	if !ok {
+		u = &TenantUsage{Tenant: tenant}
+		v.usage[tenant] = u
+	}
+	return u
+}
+
+// Store rejects content that would take the tenant past its quota with
+// ErrQuotaExceeded. Usage is reserved before the store, so concurrent
+// stores cannot together overshoot it.
+func (v *quotaVault) Store(ctx context.Context, meta ObjectMeta, content []byte) (string, error) {
+	size := int64(len(content))
+	q := v.quota(meta.Tenant)
+
+	v.mu.Lock()
+	u := v.tenantLocked(meta.Tenant)
+	if q.MaxBytes > 0 && u.Bytes+size > int64(q.MaxBytes) {
+		v.mu.Unlock()
+		return "", fmt.Errorf("%w: tenant %q would store %d of %d bytes", ErrQuotaExceeded, meta.Tenant, u.Bytes+size, q.MaxBytes)
+	}
+	if q.MaxObjects > 0 && u.Objects+1 > q.MaxObjects {
+		v.mu.Unlock()
+		return "", fmt.Errorf("%w: tenant %q is at its %d object limit", ErrQuotaExceeded, meta.Tenant, q.MaxObjects)
+	}
+	u.Bytes += size
+	u.Objects++
+	v.mu.Unlock()
+
+	ref, err := v.VaultStorage.Store(ctx, meta, content)
+	if err != nil {
+		v.add(meta.Tenant, -size, -1)
+		return "", err
+	}
+	return ref, nil
+}
+
+func (v *quotaVault) add(tenant string, bytes, objects int64) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	u := v.tenantLocked(tenant)
+	u.Bytes = max(u.Bytes+bytes, 0)
+	u.Objects = max(u.Objects+objects, 0)
+}
+
+// Delete deletes ref and releases its usage, as reported by Stat.
+func (v *quotaVault) Delete(ctx context.Context, ref string) error {
+	d, ok := v.VaultStorage.(VaultDeleter)
+	if !ok {
+		return fmt.Errorf("delete: %w", errors.ErrUnsupported)
+	}
+	info, statErr := v.Stat(ctx, ref)
+	if err := d.Delete(ctx, ref); err != nil {
+		return err
+	}
+	if statErr == nil {
+		v.add(info.Meta.Tenant, -info.Size, -1)
+	}
+	return nil
+}
+
+// Replace overwrites ref, adjusting usage for the change in size.
+func (v *quotaVault) Replace(ctx context.Context, ref string, content []byte) error {
+	r, ok := v.VaultStorage.(VaultReplacer)
+	if !ok {
+		return fmt.Errorf("replace: %w", errors.ErrUnsupported)
+	}
+	info, statErr := v.Stat(ctx, ref)
+	if err := r.Replace(ctx, ref, content); err != nil {
+		return err
+	}
+	if statErr == nil {
+		v.add(info.Meta.Tenant, int64(len(content))-info.Size, 0)
+	}
+	return nil
+}
+
+// Recount replaces the tracked usage with a count of the objects in the
+// vault.
+func (v *quotaVault) Recount(ctx context.Context) error {
+	counted := make(map[string]*TenantUsage)
+	err := SearchObjects(ctx, v.VaultStorage, ObjectFilter{}, func(info ObjectInfo) error {
+		u, ok := counted[info.Meta.Tenant]
+		if !ok {
+			u = &TenantUsage{Tenant: info.Meta.Tenant}
+			counted[info.Meta.Tenant] = u
+		}
+		u.Bytes += info.Size
+		u.Objects++
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("recount tenant usage: %w", err)
+	}
+	v.mu.Lock()
+	v.usage = counted
+	v.mu.Unlock()
+	return nil
+}
+
+// Usage returns every tenant's usage and quota, sorted by tenant. Tenants
+// with a configured quota are listed even before they store anything.
+func (v *quotaVault) Usage(context.Context) ([]TenantUsage, error) {
+	return v.snapshot(), nil
+}
+
+func (v *quotaVault) snapshot() []TenantUsage {
+	v.mu.Lock()
+	seen := make(map[string]TenantUsage, len(v.usage))
+	for tenant, u := range v.usage {
+		seen[tenant] = *u
+	}
+	v.mu.Unlock()
+	for tenant := range v.cfg.Tenants {
+		if _, ok := seen[tenant]; !ok {
+			seen[tenant] = TenantUsage{Tenant: tenant}
+		}
+	}
+
+	out := make([]TenantUsage, 0, len(seen))
+	for tenant, u := range seen {
+		q := v.quota(tenant)
+		u.MaxBytes, u.MaxObjects = int64(q.MaxBytes), q.MaxObjects
+		out = append(out, u)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Tenant < out[j].Tenant })
+	return out
+}
+
+// Start starts the wrapped vault, counts usage, and recounts in the
+// background every interval.
+func (v *quotaVault) Start(ctx context.Context) error {
+	if s, ok := v.VaultStorage.(vaultStarter); ok {
+		if err := s.Start(ctx); err != nil {
+			return err
+		}
+	}
+	if err := v.Recount(ctx); err != nil {
+		return err
+	}
+	runCtx, cancel := context.WithCancel(context.Background())
+	v.cancel = cancel
+	v.done.Add(1)
+	go func() {
+		defer v.done.Done()
+		ticker := time.NewTicker(v.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-v.stop:
+				return
+			case <-ticker.C:
+				if err := v.Recount(runCtx); err != nil && runCtx.Err() == nil {
+					v.logger.Warn("tenant usage recount failed", zap.Error(err))
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+// Close stops recounting and closes the wrapped vault.
+func (v *quotaVault) Close() error {
+	if v.cancel != nil {
+		close(v.stop)
+		v.cancel()
+		v.done.Wait()
+	}
+	if v.reg != nil {
+		v.reg.Unregister()
+	}
+	if c, ok := v.VaultStorage.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// List, Stat, Search, Compact, and Stats forward to the wrapped vault.
+
+func (v *quotaVault) List(ctx context.Context, fn func(ObjectInfo) error) error {
+	if l, ok := v.VaultStorage.(VaultLister); ok {
+		return l.List(ctx, fn)
+	}
+	return fmt.Errorf("list: %w", errors.ErrUnsupported)
+}
+
+func (v *quotaVault) Stat(ctx context.Context, ref string) (ObjectInfo, error) {
+	if s, ok := v.VaultStorage.(VaultStatter); ok {
+		return s.Stat(ctx, ref)
+	}
+	return ObjectInfo{}, fmt.Errorf("stat: %w", errors.ErrUnsupported)
+}
+
+func (v *quotaVault) Search(ctx context.Context, f ObjectFilter, fn func(ObjectInfo) error) error {
+	if s, ok := v.VaultStorage.(VaultSearcher); ok {
+		return s.Search(ctx, f, fn)
+	}
+	return fmt.Errorf("search: %w", errors.ErrUnsupported)
+}
+
+func (v *quotaVault) Compact(ctx context.Context) (CompactionStats, error) {
+	if c, ok := v.VaultStorage.(VaultCompactor); ok {
+		return c.Compact(ctx)
+	}
+	return CompactionStats{}, fmt.Errorf("compact: %w", errors.ErrUnsupported)
+}
+
+func (v *quotaVault) Stats(ctx context.Context) (VaultStats, error) {
+	if r, ok := v.VaultStorage.(VaultStatsReporter); ok {
+		return r.Stats(ctx)
+	}
+	return VaultStats{}, fmt.Errorf("stats: %w", errors.ErrUnsupported)
+}
+
+// observeUsage reports tenant usage from snapshot as the
+// promptvault_tenant_stored_bytes and promptvault_tenant_stored_objects
+// gauges.
+func (m *vaultMetrics) observeUsage(snapshot func() []TenantUsage) (metric.Registration, error) {
+	if m == nil || m.meter == nil {
+		return nil, nil
+	}
+	storedBytes, err := m.meter.Int64ObservableGauge(
+		"promptvault_tenant_stored_bytes",
+		metric.WithDescription("Bytes stored per tenant, after compression and encryption."),
+		metric.WithUnit("By"),
+	)
+	if err != nil {
+		return nil, err
+	}
+	storedObjects, err := m.meter.Int64ObservableGauge(
+		"promptvault_tenant_stored_objects",
+		metric.WithDescription("Objects stored per tenant."),
+		metric.WithUnit("{objects}"),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return m.meter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		for _, u := range snapshot() {
+			attrs := metric.WithAttributes(attribute.String("tenant", u.Tenant))
+			o.ObserveInt64(storedBytes, u.Bytes, attrs)
+			o.ObserveInt64(storedObjects, u.Objects, attrs)
+		}
+		return nil
+	}, storedBytes, storedObjects)
+}
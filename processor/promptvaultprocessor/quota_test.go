@@ -0,0 +1,110 @@
+package promptvaultprocessor
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.uber.org/zap"
+)
+
+func newTestQuotaVault(t *testing.T, inner VaultStorage, cfg QuotaConfig) *quotaVault {
+	t.Helper()
+	v, err := newQuotaVault(zap.NewNop(), nil, inner, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	qv := v.(*quotaVault)
+	if err := qv.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { qv.Close() })
+	return qv
+}
+
+func TestQuotaVaultEnforcesLimits(t *testing.T) {
+	ctx := context.Background()
+	v := newTestQuotaVault(t, NewMemoryVault(0, 0), QuotaConfig{
+		Default: TenantQuota{MaxObjects: 2},
+		Tenants: map[string]TenantQuota{"acme": {MaxBytes: 10}},
+	})
+
+	acme := ObjectMeta{Key: "gen_ai.prompt", Tenant: "acme"}
+	first, err := v.Store(ctx, acme, []byte("123456"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := v.Store(ctx, acme, []byte("abcdef")); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("store past max_bytes: %v", err)
+	}
+	// Deleting releases the tenant's usage.
+	if err := v.Delete(ctx, first); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := v.Store(ctx, acme, []byte("abcdef")); err != nil {
+		t.Errorf("store after delete: %v", err)
+	}
+
+	other := ObjectMeta{Key: "gen_ai.prompt", Tenant: "globex"}
+	v.Store(ctx, other, []byte("a"))
+	v.Store(ctx, other, []byte("b"))
+	if _, err := v.Store(ctx, other, []byte("c")); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("store past the default max_objects: %v", err)
+	}
+
+	usage, _ := v.Usage(ctx)
+	want := []TenantUsage{
+		{Tenant: "acme", Bytes: 6, Objects: 1, MaxBytes: 10},
+		{Tenant: "globex", Bytes: 2, Objects: 2, MaxObjects: 2},
+	}
+	if len(usage) != len(want) || usage[0] != want[0] || usage[1] != want[1] {
+		t.Errorf("usage = %+v, want %+v", usage, want)
+	}
+}
+
+func TestQuotaVaultRecountsOnStart(t *testing.T) {
+	ctx := context.Background()
+	inner := NewMemoryVault(0, 0)
+	inner.Store(ctx, ObjectMeta{Tenant: "acme"}, []byte("already stored"))
+	v := newTestQuotaVault(t, inner, QuotaConfig{Tenants: map[string]TenantQuota{"acme": {MaxObjects: 1}, "initech": {MaxBytes: 5}}})
+
+	if _, err := v.Store(ctx, ObjectMeta{Tenant: "acme"}, []byte("new")); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("store over a quota already used before start: %v", err)
+	}
+	usage, _ := v.Usage(ctx)
+	if len(usage) != 2 || usage[0].Objects != 1 || usage[0].Bytes != int64(len("already stored")) || usage[1] != (TenantUsage{Tenant: "initech", MaxBytes: 5}) {
+		t.Errorf("usage = %+v", usage)
+	}
+}
+
+func TestProcessorOnQuotaExceeded(t *testing.T) {
+	for _, action := range []string{"reject", "hash_only"} {
+		cfg := createDefaultConfig()
+		cfg.Vault.OnQuotaExceeded = action
+		vault := newTestQuotaVault(t, NewMemoryVault(0, 0), QuotaConfig{Default: TenantQuota{MaxBytes: 4}})
+		proc := newVaultProcessor(zap.NewNop(), cfg, vault, new(consumertest.TracesSink))
+
+		td := ptrace.NewTraces()
+		span := td.ResourceSpans().AppendEmpty().ScopeSpans().AppendEmpty().Spans().AppendEmpty()
+		span.Attributes().PutStr("gen_ai.prompt", "Tell me about quantum computing")
+		if err := proc.ConsumeTraces(context.Background(), td); err != nil {
+			t.Fatal(err)
+		}
+
+		want := "Tell me about quantum computing"
+		if action == "hash_only" {
+			want = "sha256:" + contentHash([]byte(want))
+		}
+		if v, _ := span.Attributes().Get("gen_ai.prompt"); v.Str() != want {
+			t.Errorf("%s: gen_ai.prompt = %q, want %q", action, v.Str(), want)
+		}
+		if _, ok := span.Attributes().Get("gen_ai.prompt.vault_ref"); ok {
+			t.Errorf("%s: value over quota was vaulted", action)
+		}
+	}
+	if err := validateOnQuotaExceeded("drop"); err == nil {
+		t.Error("expected error for unknown on_quota_exceeded")
+	}
+}
@@ -0,0 +1,270 @@
+package promptvaultprocessor
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"go.uber.org/zap"
+)
+
+// piiDetector finds one kind of sensitive substring.
+type piiDetector struct {
+	name    string
+	pattern *regexp.Regexp
+	// valid, if set, filters regex matches (e.g. the Luhn check for cards).
+	valid func(string) bool
+}
+
+var builtinDetectors = map[string]piiDetector{
+	"email": {
+		name:    "email",
+		pattern: regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`),
+	},
+	"phone": {
+		name:    "phone",
+		pattern: regexp.MustCompile(`(?:\+\d{1,3}[ .-]?)?\(?\b\d{3}\)?[ .-]?\d{3}[ .-]?\d{4}\b`),
+	},
+	"credit_card": {
+		name:    "credit_card",
+		pattern: regexp.MustCompile(`\b(?:\d[ -]?){12,18}\d\b`),
+		valid:   luhnValid,
+	},
+	"ssn": {
+		name:    "ssn",
+		pattern: regexp.MustCompile(`\b(?:00[1-9]|0[1-9]\d|[1-578]\d{2}|6[0-57-9]\d|66[0-57-9])-(?:0[1-9]|[1-9]\d)-(?:000[1-9]|00[1-9]\d|0[1-9]\d{2}|[1-9]\d{3})\b`),
+	},
+}
+
+// piiMatch is a detected substring s[Start:End].
+type piiMatch struct {
+	Start, End int
+	Detector   string
+}
+
+// piiScanner runs the enabled detectors over text.
+type piiScanner struct {
+	detectors []piiDetector
+}
+
+func newPIIScanner(cfg RedactionConfig) (*piiScanner, error) {
+	s := &piiScanner{}
+	for name, on := range map[string]bool{
+		"email":       cfg.Detectors.Email,
+		"phone":       cfg.Detectors.Phone,
+		"credit_card": cfg.Detectors.CreditCard,
+		"ssn":         cfg.Detectors.SSN,
+	} {
+		if on {
+			s.detectors = append(s.detectors, builtinDetectors[name])
+		}
+	}
+	// Map iteration order is random; keep overlap resolution deterministic.
+	sort.Slice(s.detectors, func(i, j int) bool { return s.detectors[i].name < s.detectors[j].name })
+
+	for i, p := range cfg.Patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("redaction.patterns[%d]: %w", i, err)
+		}
+		s.detectors = append(s.detectors, piiDetector{name: "pattern", pattern: re})
+	}
+	if len(s.detectors) == 0 {
+		return nil, nil
+	}
+	return s, nil
+}
+
+// scan returns non-overlapping matches in order. Where matches overlap the
+// earliest, then longest, wins.
+func (s *piiScanner) scan(text string) []piiMatch {
+	var all []piiMatch
+	for _, d := range s.detectors {
+		for _, loc := range d.pattern.FindAllStringIndex(text, -1) {
+			if d.valid != nil && !d.valid(text[loc[0]:loc[1]]) {
+				continue
+			}
+			all = append(all, piiMatch{Start: loc[0], End: loc[1], Detector: d.name})
+		}
+	}
+	sort.SliceStable(all, func(i, j int) bool {
+		if all[i].Start != all[j].Start {
+			return all[i].Start < all[j].Start
+		}
+		return all[i].End > all[j].End
+	})
+
+	var out []piiMatch
+	end := 0
+	for _, m := range all {
+		if m.Start >= end {
+			out = append(out, m)
+			end = m.End
+		}
+	}
+	return out
+}
+
+// redact masks every match as [REDACTED:<DETECTOR>] and returns the number
+// of matches per detector.
+func (s *piiScanner) redact(text string) (string, map[string]int) {
+	matches := s.scan(text)
+	if len(matches) == 0 {
+		return text, nil
+	}
+	counts := make(map[string]int)
+	var b strings.Builder
+	prev := 0
+	for _, m := range matches {
+		b.WriteString(text[prev:m.Start])
+		b.WriteString("[REDACTED:" + strings.ToUpper(m.Detector) + "]")
+		prev = m.End
+		counts[m.Detector]++
+	}
+	b.WriteString(text[prev:])
+	return b.String(), counts
+}
+
+// Redactor masks sensitive substrings in text outside the processor, e.g.
+// when exporting content stored before redaction was enabled.
+type Redactor struct {
+	scanner *piiScanner
+}
+
+// NewRedactor builds a redactor for cfg. With no detectors or patterns
+// enabled it returns text unchanged.
+func NewRedactor(cfg RedactionConfig) (*Redactor, error) {
+	s, err := newPIIScanner(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &Redactor{scanner: s}, nil
+}
+
+// Redact masks every match as [REDACTED:<DETECTOR>].
+func (r *Redactor) Redact(text string) string {
+	if r.scanner == nil {
+		return text
+	}
+	out, _ := r.scanner.redact(text)
+	return out
+}
+
+// luhnValid reports whether the digits in s pass the Luhn checksum.
+func luhnValid(s string) bool {
+	sum, n := 0, 0
+	for i := len(s) - 1; i >= 0; i-- {
+		c := s[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		d := int(c - '0')
+		if n%2 == 1 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		n++
+	}
+	return n >= 13 && sum%10 == 0
+}
+
+// redactedOriginalSuffix is appended to an object's attribute key when
+// RedactObjects keeps its original content.
+const redactedOriginalSuffix = ".unredacted"
+
+// RedactOptions controls RedactObjects.
+type RedactOptions struct {
+	// KeepOriginal stores each object's original content again, under its
+	// attribute key with ".unredacted" appended, before rewriting it.
+	KeepOriginal bool
+	// DryRun counts matches without rewriting anything.
+	DryRun bool
+}
+
+// RedactResult describes the redaction of one object.
+type RedactResult struct {
+	Ref string
+	// Matches counts the masked substrings by detector. Empty when the
+	// object held none and was left as is.
+	Matches map[string]int
+	// Original is the reference to the kept original content.
+	Original string
+}
+
+// RedactObjects masks r's matches in each object in refs and rewrites it in
+// place, for sensitive data found in content vaulted before redaction was
+// configured. References on spans stay valid and resolve to the redacted
+// content, but signed references no longer verify, since their signature
+// covers the original content. An unencrypted object's content-addressed
+// reference is the hash of its bytes, so only encrypted objects can be
+// rewritten under one. The index, manifests, and content search record
+// each rewrite. It stops at the first failure, returning the results so
+// far.
+func RedactObjects(ctx context.Context, logger *zap.Logger, cfg *Config, r *Redactor, refs []string, opts RedactOptions) ([]RedactResult, error) {
+	if r.scanner == nil {
+		return nil, fmt.Errorf("redact: no detectors or patterns enabled")
+	}
+	vault, err := OpenVault(ctx, logger, cfg)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if c, ok := vault.(io.Closer); ok {
+			c.Close()
+		}
+	}()
+	replacer, ok := vault.(VaultReplacer)
+	if !ok {
+		return nil, fmt.Errorf("redact: storage backend cannot replace objects in place")
+	}
+
+	var results []RedactResult
+	for _, ref := range refs {
+		if cfg.Crypto.Provider == "" && isContentHash(hashFromRef(ref)) {
+			return results, fmt.Errorf("redact %s: only encrypted objects can be rewritten under a content-addressed reference", ref)
+		}
+		content, err := vault.Retrieve(ctx, ref)
+		if err != nil {
+			return results, fmt.Errorf("redact %s: %w", ref, err)
+		}
+		res := RedactResult{Ref: ref}
+		if !utf8.Valid(content) {
+			results = append(results, res)
+			continue
+		}
+		var redacted string
+		redacted, res.Matches = r.scanner.redact(string(content))
+		if len(res.Matches) == 0 || opts.DryRun {
+			results = append(results, res)
+			continue
+		}
+		if opts.KeepOriginal {
+			var meta ObjectMeta
+			if s, ok := vault.(VaultStatter); ok {
+				if info, err := s.Stat(ctx, ref); err == nil {
+					meta = info.Meta
+				}
+			}
+			meta.Key += redactedOriginalSuffix
+			meta.KeyID, meta.Transforms = "", ""
+			if res.Original, err = vault.Store(ctx, meta, content); err != nil {
+				return results, fmt.Errorf("redact %s: keep original: %w", ref, err)
+			}
+			if res.Original == ref {
+				return results, fmt.Errorf("redact %s: keep original: the vault stores it under the same reference", ref)
+			}
+		}
+		if err := replacer.Replace(ctx, ref, []byte(redacted)); err != nil {
+			return results, fmt.Errorf("redact %s: %w", ref, err)
+		}
+		results = append(results, res)
+	}
+	return results, nil
+}
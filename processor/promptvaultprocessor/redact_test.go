@@ -0,0 +1,149 @@
+package promptvaultprocessor
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"io"
+	"path/filepath"
+	"testing"
+
+	"go.opentelemetry.io/collector/config/configopaque"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.uber.org/zap"
+)
+
+func TestRedactBuiltinDetectors(t *testing.T) {
+	s, err := newPIIScanner(RedactionConfig{Detectors: RedactionDetectors{
+		Email: true, Phone: true, CreditCard: true, SSN: true,
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, counts := s.redact("mail bob@example.com or call 415-555-0132; card 4111 1111 1111 1111, ssn 123-45-6789")
+	want := "mail [REDACTED:EMAIL] or call [REDACTED:PHONE]; card [REDACTED:CREDIT_CARD], ssn [REDACTED:SSN]"
+	if got != want {
+		t.Errorf("redact:\n got %q\nwant %q", got, want)
+	}
+	for _, d := range []string{"email", "phone", "credit_card", "ssn"} {
+		if counts[d] != 1 {
+			t.Errorf("counts[%s] = %d, want 1", d, counts[d])
+		}
+	}
+}
+
+func TestRedactSkipsInvalidCardsAndSSNs(t *testing.T) {
+	s, _ := newPIIScanner(RedactionConfig{Detectors: RedactionDetectors{CreditCard: true, SSN: true}})
+
+	for _, in := range []string{"order 4111 1111 1111 1112", "id 000-12-3456", "id 666-12-3456"} {
+		if got, _ := s.redact(in); got != in {
+			t.Errorf("redact(%q) = %q, want unchanged", in, got)
+		}
+	}
+}
+
+func TestRedactCustomPattern(t *testing.T) {
+	s, err := newPIIScanner(RedactionConfig{Patterns: []string{`sk-[A-Za-z0-9]{8,}`}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := s.redact("key sk-abcdef123456 here"); got != "key [REDACTED:PATTERN] here" {
+		t.Errorf("got %q", got)
+	}
+
+	if _, err := newPIIScanner(RedactionConfig{Patterns: []string{"("}}); err == nil {
+		t.Error("expected error for invalid pattern")
+	}
+	if s, _ := newPIIScanner(RedactionConfig{}); s != nil {
+		t.Error("expected nil scanner when nothing is enabled")
+	}
+}
+
+func TestProcessorRedactsBeforeStore(t *testing.T) {
+	vault := NewMemoryVault(0, 0)
+	cfg := createDefaultConfig()
+	cfg.Vault.SizeThreshold = 0
+	sink := new(consumertest.TracesSink)
+	proc := newVaultProcessor(zap.NewNop(), cfg, vault, sink)
+	proc.redactor, _ = newPIIScanner(RedactionConfig{Detectors: RedactionDetectors{Email: true}})
+
+	td := ptrace.NewTraces()
+	span := td.ResourceSpans().AppendEmpty().ScopeSpans().AppendEmpty().Spans().AppendEmpty()
+	span.Attributes().PutStr("gen_ai.prompt", "reply to alice@example.org please")
+
+	if err := proc.ConsumeTraces(context.Background(), td); err != nil {
+		t.Fatal(err)
+	}
+	ref, _ := span.Attributes().Get("gen_ai.prompt.vault_ref")
+	content, err := vault.Retrieve(context.Background(), ref.Str())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "reply to [REDACTED:EMAIL] please" {
+		t.Errorf("stored %q", content)
+	}
+}
+
+func TestRedactObjects(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	cfg := createDefaultConfig()
+	cfg.Storage.Filesystem.BasePath = filepath.Join(dir, "objects")
+	cfg.Storage.Index.Path = filepath.Join(dir, "index.db")
+	cfg.Storage.Compression = "zstd"
+	cfg.ContentSearch = ContentSearchConfig{Enabled: true, Path: filepath.Join(dir, "search.db")}
+	cfg.Crypto.Provider = "local"
+	cfg.Crypto.Local.Keys = []KeyConfig{{ID: "k1", Key: configopaque.String(base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{7}, 32)))}}
+
+	vault, err := OpenVault(ctx, zap.NewNop(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	meta := ObjectMeta{TraceID: "t1", SpanID: "s1", Key: "gen_ai.prompt"}
+	ref, err := vault.Store(ctx, meta, []byte("mail jane@example.com today"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	clean, _ := vault.Store(ctx, meta, []byte("nothing to see"))
+	vault.(io.Closer).Close()
+
+	r, _ := NewRedactor(RedactionConfig{Detectors: RedactionDetectors{Email: true}})
+	results, err := RedactObjects(ctx, zap.NewNop(), cfg, r, []string{ref, clean}, RedactOptions{KeepOriginal: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 || results[0].Matches["email"] != 1 || results[0].Original == "" || len(results[1].Matches) != 0 {
+		t.Fatalf("results = %+v", results)
+	}
+
+	vault, err = OpenVault(ctx, zap.NewNop(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer vault.(io.Closer).Close()
+	if got, err := vault.Retrieve(ctx, ref); err != nil || string(got) != "mail [REDACTED:EMAIL] today" {
+		t.Errorf("redacted object = %q, %v", got, err)
+	}
+	if got, err := vault.Retrieve(ctx, results[0].Original); err != nil || string(got) != "mail jane@example.com today" {
+		t.Errorf("kept original = %q, %v", got, err)
+	}
+	index, err := OpenContentIndex(ctx, cfg.ContentSearch.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer index.Close()
+	matches, _ := index.Search(ctx, "jane", 10)
+	for _, m := range matches {
+		if m.Ref == ref {
+			t.Error("content search still finds the redacted text")
+		}
+	}
+
+	// Without encryption, rewriting would break the content-hash reference.
+	cfg.Crypto.Provider = ""
+	if _, err := RedactObjects(ctx, zap.NewNop(), cfg, r, []string{ref}, RedactOptions{}); err == nil {
+		t.Error("redacting under a content-hash reference without encryption: want error")
+	}
+}
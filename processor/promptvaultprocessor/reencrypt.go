@@ -0,0 +1,185 @@
+package promptvaultprocessor
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// ReEncryptOptions controls a ReEncrypt run.
+type ReEncryptOptions struct {
+	// JournalPath records each finished object so an interrupted run resumes
+	// where it stopped. Empty = no journal.
+	JournalPath string
+	// SkipKeyID leaves objects already encrypted under this key untouched.
+	SkipKeyID string
+	// Progress, if set, is called after each object.
+	Progress func(ref string, stats ReEncryptStats)
+}
+
+// ReEncryptStats summarizes a ReEncrypt run.
+type ReEncryptStats struct {
+	Scanned     int
+	ReEncrypted int
+	Skipped     int
+	Failed      int
+}
+
+// reEncryptJournalEntry is one JSON line of the journal.
+type reEncryptJournalEntry struct {
+	Ref    string `json:"ref"`
+	SHA256 string `json:"sha256,omitempty"`
+	KeyID  string `json:"key_id,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// ReEncrypt walks vault, decrypting each object with from and re-encrypting it
+// with to, e.g. after a key compromise. Objects are rewritten in place so
+// existing references stay valid, and every re-encrypted object is decrypted
+// again and checked against the original plaintext checksum before it
+// replaces the old ciphertext. Per-object failures are journaled and counted;
+// the run continues.
+func ReEncrypt(ctx context.Context, vault VaultStorage, from, to Encryptor, opts ReEncryptOptions) (ReEncryptStats, error) {
+	var stats ReEncryptStats
+	lister, ok := vault.(VaultLister)
+	if !ok {
+		return stats, fmt.Errorf("re-encrypt: backend cannot list objects")
+	}
+	replacer, ok := vault.(VaultReplacer)
+	if !ok {
+		return stats, fmt.Errorf("re-encrypt: backend cannot replace objects in place")
+	}
+
+	done, err := readReEncryptJournal(opts.JournalPath)
+	if err != nil {
+		return stats, err
+	}
+	var journal *json.Encoder
+	if opts.JournalPath != "" {
+		f, err := os.OpenFile(opts.JournalPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+		if err != nil {
+			return stats, fmt.Errorf("open journal: %w", err)
+		}
+		defer f.Close()
+		journal = json.NewEncoder(f)
+	}
+
+	// Collect refs first: replacing objects while listing would disturb
+	// backends that list by walking storage.
+	var refs []string
+	err = lister.List(ctx, func(info ObjectInfo) error {
+		refs = append(refs, info.Ref)
+		return nil
+	})
+	if err != nil {
+		return stats, fmt.Errorf("list vault: %w", err)
+	}
+
+	for _, ref := range refs {
+		if err := ctx.Err(); err != nil {
+			return stats, err
+		}
+		stats.Scanned++
+		if done[ref] {
+			stats.Skipped++
+			continue
+		}
+
+		entry, skipped, err := reEncryptOne(ctx, vault, replacer, from, to, ref, opts.SkipKeyID)
+		switch {
+		case err != nil:
+			stats.Failed++
+			entry.Error = err.Error()
+		case skipped:
+			stats.Skipped++
+		default:
+			stats.ReEncrypted++
+		}
+		if journal != nil && !skipped {
+			if err := journal.Encode(entry); err != nil {
+				return stats, fmt.Errorf("write journal: %w", err)
+			}
+		}
+		if opts.Progress != nil {
+			opts.Progress(ref, stats)
+		}
+	}
+	return stats, nil
+}
+
+func reEncryptOne(ctx context.Context, vault VaultStorage, replacer VaultReplacer, from, to Encryptor, ref, skipKeyID string) (reEncryptJournalEntry, bool, error) {
+	entry := reEncryptJournalEntry{Ref: ref}
+	sealed, err := vault.Retrieve(ctx, ref)
+	if err != nil {
+		return entry, false, err
+	}
+	env, err := unmarshalEnvelope(sealed)
+	if err != nil {
+		return entry, false, fmt.Errorf("not an encrypted vault object: %w", err)
+	}
+	if skipKeyID != "" && env.KeyID == skipKeyID {
+		return entry, true, nil
+	}
+
+	// The header binding is authenticated by the decryption below.
+	plaintext, err := from.Decrypt(ctx, sealed, env.AAD)
+	if err != nil {
+		return entry, false, fmt.Errorf("decrypt: %w", err)
+	}
+	entry.SHA256 = contentHash(plaintext)
+
+	aad := env.AAD
+	if hash := hashFromRef(ref); isContentHash(hash) && !isRebound(sealed) {
+		aad = reboundAAD(aad, hash)
+	}
+	resealed, keyID, err := to.Encrypt(ctx, plaintext, aad)
+	if err != nil {
+		return entry, false, fmt.Errorf("encrypt: %w", err)
+	}
+	entry.KeyID = keyID
+	if renv, err := unmarshalEnvelope(resealed); err != nil || !bytes.Equal(renv.AAD, aad) {
+		return entry, false, errors.New("target encryption does not bind refs (convergent or age) and would invalidate the ref")
+	}
+
+	check, err := to.Decrypt(ctx, resealed, aad)
+	if err != nil {
+		return entry, false, fmt.Errorf("verify: %w", err)
+	}
+	if contentHash(check) != entry.SHA256 {
+		return entry, false, errors.New("verify: checksum mismatch after re-encryption")
+	}
+
+	if err := replacer.Replace(ctx, ref, resealed); err != nil {
+		return entry, false, fmt.Errorf("replace: %w", err)
+	}
+	return entry, false, nil
+}
+
+// readReEncryptJournal returns the refs a previous run finished successfully.
+func readReEncryptJournal(path string) (map[string]bool, error) {
+	done := map[string]bool{}
+	if path == "" {
+		return done, nil
+	}
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return done, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("open journal: %w", err)
+	}
+	defer f.Close()
+
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		var e reEncryptJournalEntry
+		if json.Unmarshal(sc.Bytes(), &e) == nil && e.Error == "" {
+			done[e.Ref] = true
+		}
+	}
+	return done, sc.Err()
+}
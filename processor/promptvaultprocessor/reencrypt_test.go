@@ -0,0 +1,83 @@
+package promptvaultprocessor
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReEncryptRotatesKeysInPlace(t *testing.T) {
+	ctx := context.Background()
+	oldKey := keyringEntry{id: "old", key: bytes.Repeat([]byte{1}, 32)}
+	newKey := keyringEntry{id: "new", key: bytes.Repeat([]byte{2}, 32)}
+	oldRing, _ := newKeyring([]keyringEntry{oldKey}, "")
+	newRing, _ := newKeyring([]keyringEntry{newKey}, "")
+
+	backend, err := NewFilesystemVault(FilesystemConfig{BasePath: t.TempDir(), WriteMetadata: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	before := &encryptingVault{VaultStorage: backend, enc: oldRing}
+	refs := map[string]string{}
+	for i, text := range []string{"first prompt", "second prompt", "third prompt"} {
+		meta := ObjectMeta{TraceID: "t", SpanID: string(rune('a' + i)), Key: "gen_ai.prompt"}
+		ref, err := before.Store(ctx, meta, []byte(text))
+		if err != nil {
+			t.Fatalf("store: %v", err)
+		}
+		refs[ref] = text
+	}
+
+	journal := filepath.Join(t.TempDir(), "journal.jsonl")
+	stats, err := ReEncrypt(ctx, backend, oldRing, newRing, ReEncryptOptions{JournalPath: journal, SkipKeyID: "new"})
+	if err != nil {
+		t.Fatalf("re-encrypt: %v", err)
+	}
+	if stats.ReEncrypted != 3 || stats.Failed != 0 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+
+	after := &encryptingVault{VaultStorage: backend, enc: newRing}
+	for ref, text := range refs {
+		data, err := after.Retrieve(ctx, ref)
+		if err != nil || string(data) != text {
+			t.Errorf("expected %s to decrypt with the new key, got %q, %v", ref, data, err)
+		}
+		if _, err := before.Retrieve(ctx, ref); err == nil {
+			t.Errorf("expected %s to be unreadable with the old key", ref)
+		}
+	}
+
+	// Resuming skips everything the journal recorded.
+	stats, err = ReEncrypt(ctx, backend, oldRing, newRing, ReEncryptOptions{JournalPath: journal})
+	if err != nil || stats.Skipped != 3 || stats.ReEncrypted != 0 {
+		t.Errorf("expected resumed run to skip all objects, got %+v, %v", stats, err)
+	}
+	lines, _ := os.ReadFile(journal)
+	if n := strings.Count(string(lines), "\n"); n != 3 {
+		t.Errorf("expected 3 journal entries, got %d", n)
+	}
+}
+
+func TestReEncryptedObjectCannotBeMoved(t *testing.T) {
+	ctx := context.Background()
+	ring, _ := newKeyring([]keyringEntry{{id: "k", key: bytes.Repeat([]byte{1}, 32)}}, "")
+	backend := NewMemoryVault(0, 0)
+	vault := &encryptingVault{VaultStorage: backend, enc: ring}
+
+	refA, _ := vault.Store(ctx, ObjectMeta{TraceID: "t", SpanID: "a"}, []byte("secret A"))
+	refB, _ := vault.Store(ctx, ObjectMeta{TraceID: "t", SpanID: "b"}, []byte("secret B"))
+	if _, err := ReEncrypt(ctx, backend, ring, ring, ReEncryptOptions{}); err != nil {
+		t.Fatalf("re-encrypt: %v", err)
+	}
+
+	// Copy A's re-encrypted ciphertext over B.
+	sealedA, _ := backend.Retrieve(ctx, refA)
+	backend.Replace(ctx, refB, sealedA)
+	if _, err := vault.Retrieve(ctx, refB); err == nil {
+		t.Error("expected a re-encrypted object moved to another ref to fail")
+	}
+}
@@ -0,0 +1,146 @@
+package promptvaultprocessor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// RefCheckOptions says which references spans still hold.
+type RefCheckOptions struct {
+	// SpanRefs are the references held by retained spans, e.g. read from
+	// an export of the trace backend. If nil, storage.index stands in:
+	// every indexed object stored within SpanRetention counts as
+	// referenced.
+	SpanRefs map[string]bool
+	// SpanRetention is how long the trace backend keeps spans, used with
+	// the index. 0 = forever.
+	SpanRetention time.Duration
+	// MinAge spares objects younger than this from being reported as
+	// orphans, since their spans may not have been exported yet.
+	MinAge time.Duration
+}
+
+// RefCheckReport lists the inconsistencies between a vault and the spans
+// referencing it.
+type RefCheckReport struct {
+	// Objects counts the objects in the backend.
+	Objects int
+	// Referenced counts the distinct references spans hold.
+	Referenced int
+	// Orphans are objects no retained span references.
+	Orphans []ObjectInfo
+	// Dangling are references spans hold to objects the backend no longer
+	// has, sorted.
+	Dangling []string
+}
+
+// CheckReferences compares the objects in the backend cfg describes with
+// the references spans hold, given by opts.SpanRefs or, failing that,
+// storage.index. Bundled objects are included.
+func CheckReferences(ctx context.Context, logger *zap.Logger, cfg *Config, opts RefCheckOptions) (RefCheckReport, error) {
+	var report RefCheckReport
+	if opts.SpanRefs == nil && cfg.Storage.Index.Path == "" {
+		return report, fmt.Errorf("check references: need span references or storage.index")
+	}
+	metrics, err := newVaultMetrics(nil)
+	if err != nil {
+		return report, err
+	}
+	backend, err := openBackendStack(ctx, logger, metrics, cfg.Storage)
+	if err != nil {
+		return report, err
+	}
+	defer func() {
+		if c, ok := backend.(io.Closer); ok {
+			c.Close()
+		}
+	}()
+	lister, ok := backend.(VaultLister)
+	if !ok {
+		return report, fmt.Errorf("check references: storage backend cannot list objects")
+	}
+
+	referenced := opts.SpanRefs
+	if referenced == nil {
+		if referenced, err = indexedRefs(ctx, cfg.Storage.Index, opts.SpanRetention); err != nil {
+			return report, err
+		}
+	}
+	report.Referenced = len(referenced)
+
+	present := make(map[string]bool)
+	cutoff := time.Now().Add(-opts.MinAge)
+	err = lister.List(ctx, func(info ObjectInfo) error {
+		report.Objects++
+		present[info.Ref] = true
+		if !referenced[info.Ref] && info.StoredAt.Before(cutoff) {
+			report.Orphans = append(report.Orphans, info)
+		}
+		return nil
+	})
+	if err != nil {
+		return report, fmt.Errorf("check references: %w", err)
+	}
+	for ref := range referenced {
+		if !present[ref] {
+			report.Dangling = append(report.Dangling, ref)
+		}
+	}
+	sort.Strings(report.Dangling)
+	return report, nil
+}
+
+// indexedRefs returns the references in the index at cfg whose latest
+// store is within retention.
+func indexedRefs(ctx context.Context, cfg IndexConfig, retention time.Duration) (map[string]bool, error) {
+	db, err := openIndex(ctx, cfg.Path)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+	var after int64 = -1 << 63
+	if retention > 0 {
+		after = time.Now().Add(-retention).UnixNano()
+	}
+	rows, err := db.QueryContext(ctx, `SELECT ref FROM objects GROUP BY ref HAVING MAX(stored_at) >= ?`, after)
+	if err != nil {
+		return nil, fmt.Errorf("query index: %w", err)
+	}
+	defer rows.Close()
+	refs := make(map[string]bool)
+	for rows.Next() {
+		var ref string
+		if err := rows.Scan(&ref); err != nil {
+			return nil, fmt.Errorf("scan index: %w", err)
+		}
+		refs[ref] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("query index: %w", err)
+	}
+	return refs, nil
+}
+
+// DeleteOrphans deletes the report's orphans from vault, which should be
+// opened with OpenVault so the index and manifests record the deletions.
+// It returns how many were deleted.
+func (r RefCheckReport) DeleteOrphans(ctx context.Context, vault VaultStorage) (int, error) {
+	d, ok := vault.(VaultDeleter)
+	if !ok {
+		return 0, fmt.Errorf("delete: %w", errors.ErrUnsupported)
+	}
+	n := 0
+	for _, info := range r.Orphans {
+		if err := d.Delete(ctx, info.Ref); err != nil && !errors.Is(err, ErrObjectNotFound) {
+			return n, fmt.Errorf("delete %s: %w", info.Ref, err)
+		}
+		n++
+	}
+	return n, nil
+}
@@ -0,0 +1,67 @@
+package promptvaultprocessor
+
+import (
+	"context"
+	"io"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestCheckReferences(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	cfg := createDefaultConfig()
+	cfg.Storage.Filesystem.BasePath = filepath.Join(dir, "objects")
+	cfg.Storage.Index.Path = filepath.Join(dir, "index.db")
+	vault, err := OpenVault(ctx, zap.NewNop(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer vault.(io.Closer).Close()
+	a, _ := vault.Store(ctx, ObjectMeta{TraceID: "t1", Key: "gen_ai.prompt"}, []byte("a"))
+	b, _ := vault.Store(ctx, ObjectMeta{TraceID: "t2", Key: "gen_ai.prompt"}, []byte("b"))
+	gone, _ := vault.Store(ctx, ObjectMeta{TraceID: "t3", Key: "gen_ai.prompt"}, []byte("c"))
+	// Removed behind the index's back, as eviction does.
+	fs, _ := NewFilesystemVault(cfg.Storage.Filesystem)
+	if err := fs.Delete(ctx, gone); err != nil {
+		t.Fatal(err)
+	}
+
+	// With the index, every indexed object counts as referenced.
+	report, err := CheckReferences(ctx, zap.NewNop(), cfg, RefCheckOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Objects != 2 || len(report.Orphans) != 0 || len(report.Dangling) != 1 || report.Dangling[0] != gone {
+		t.Errorf("index report = %+v", report)
+	}
+
+	// Exported spans only reference a, and one missing object.
+	spanRefs := map[string]bool{a: true, "vault://" + contentHash([]byte("never stored")): true}
+	report, err = CheckReferences(ctx, zap.NewNop(), cfg, RefCheckOptions{SpanRefs: spanRefs})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Orphans) != 1 || report.Orphans[0].Ref != b || len(report.Dangling) != 1 {
+		t.Errorf("span report = %+v", report)
+	}
+	// Young objects are spared.
+	young, err := CheckReferences(ctx, zap.NewNop(), cfg, RefCheckOptions{SpanRefs: spanRefs, MinAge: time.Hour})
+	if err != nil || len(young.Orphans) != 0 {
+		t.Errorf("report with min age = %+v, %v", young, err)
+	}
+
+	n, err := report.DeleteOrphans(ctx, vault)
+	if err != nil || n != 1 {
+		t.Fatalf("deleted %d orphans: %v", n, err)
+	}
+	if _, err := vault.Retrieve(ctx, b); err == nil {
+		t.Error("orphan still retrievable")
+	}
+	if refs := searchRefs(t, vault, ObjectFilter{TraceID: "t2"}); len(refs) != 0 {
+		t.Errorf("orphan still indexed: %v", refs)
+	}
+}
@@ -0,0 +1,286 @@
+package promptvaultprocessor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// ReplicationConfig copies objects from one vault to another, e.g. from an
+// edge collector's local buffer to central storage.
+type ReplicationConfig struct {
+	// Interval between passes. Default 5m.
+	Interval time.Duration `mapstructure:"interval"`
+	// BytesPerSecond caps the copy rate. 0 = unlimited.
+	BytesPerSecond ByteSize `mapstructure:"bytes_per_second"`
+	// StatePath records when the last complete pass began, so the next one
+	// only considers objects stored since, found through the source's
+	// storage.index when it has one. Empty = every pass considers every
+	// object.
+	StatePath string `mapstructure:"state_path"`
+	// DeleteSource deletes each object from the source once the
+	// destination holds it.
+	DeleteSource bool `mapstructure:"delete_source"`
+}
+
+// ReplicationStats summarizes a pass.
+type ReplicationStats struct {
+	Scanned int
+	Copied  int
+	Bytes   int64
+	// Present counts objects the destination already held.
+	Present int
+	// Deleted counts objects removed from the source.
+	Deleted int
+	// Failed counts objects that could not be copied or removed.
+	Failed int
+}
+
+// replicationState is the content of ReplicationConfig.StatePath.
+type replicationState struct {
+	// Since is when the last pass without failures began.
+	Since time.Time `json:"since"`
+}
+
+// Replication copies objects as stored, still compressed and encrypted,
+// from a source vault to a destination vault every interval. Both should
+// be opened with OpenStoredVault. References are kept, so the destination
+// must be content-addressed or use the source's path template.
+type Replication struct {
+	logger   *zap.Logger
+	src      VaultStorage
+	dst      VaultStorage
+	cfg      ReplicationConfig
+	interval time.Duration
+	now      func() time.Time
+
+	stop   chan struct{}
+	cancel context.CancelFunc
+	done   sync.WaitGroup
+}
+
+// NewReplication validates cfg and prepares copies from src to dst.
+func NewReplication(logger *zap.Logger, src, dst VaultStorage, cfg ReplicationConfig) (*Replication, error) {
+	if _, ok := src.(VaultLister); !ok {
+		return nil, fmt.Errorf("replication: source backend cannot list objects")
+	}
+	if _, ok := src.(VaultDeleter); !ok && cfg.DeleteSource {
+		return nil, fmt.Errorf("replication: delete_source is set but the source backend cannot delete objects")
+	}
+	if cfg.BytesPerSecond < 0 {
+		return nil, fmt.Errorf("replication: bytes_per_second must not be negative")
+	}
+	interval := cfg.Interval
+	if interval <= 0 {
+		interval = 5 * time.Minute
+	}
+	return &Replication{
+		logger:   logger,
+		src:      src,
+		dst:      dst,
+		cfg:      cfg,
+		interval: interval,
+		now:      time.Now,
+		stop:     make(chan struct{}),
+	}, nil
+}
+
+// Run copies every object stored since the last complete pass once.
+func (r *Replication) Run(ctx context.Context) (ReplicationStats, error) {
+	var stats ReplicationStats
+	state, err := r.loadState()
+	if err != nil {
+		return stats, err
+	}
+	started := r.now()
+
+	// Collect first: writing while a backend iterates is not safe for all.
+	var pending []ObjectInfo
+	err = SearchObjects(ctx, r.src, ObjectFilter{StoredAfter: state.Since}, func(info ObjectInfo) error {
+		stats.Scanned++
+		pending = append(pending, info)
+		return nil
+	})
+	if err != nil {
+		return stats, fmt.Errorf("list source: %w", err)
+	}
+
+	limit := newByteRateLimiter(int64(r.cfg.BytesPerSecond), started)
+	for _, info := range pending {
+		if err := r.copyOne(ctx, info, limit, &stats); err != nil {
+			if ctx.Err() != nil {
+				return stats, ctx.Err()
+			}
+			r.logger.Warn("replication failed", zap.String("ref", info.Ref), zap.Error(err))
+			stats.Failed++
+		}
+	}
+	if stats.Copied > 0 || stats.Failed > 0 {
+		r.logger.Info("vault replication",
+			zap.Int("scanned", stats.Scanned),
+			zap.Int("copied", stats.Copied),
+			zap.Int64("bytes", stats.Bytes),
+			zap.Int("deleted", stats.Deleted),
+			zap.Int("failed", stats.Failed),
+		)
+	}
+	if stats.Failed > 0 {
+		// Retry the failures next pass.
+		return stats, nil
+	}
+	return stats, r.saveState(replicationState{Since: started})
+}
+
+func (r *Replication) copyOne(ctx context.Context, info ObjectInfo, limit *byteRateLimiter, stats *ReplicationStats) error {
+	present := false
+	if s, ok := r.dst.(VaultStatter); ok {
+		_, err := s.Stat(ctx, info.Ref)
+		switch {
+		case err == nil:
+			present = true
+		case !errors.Is(err, ErrObjectNotFound) && !errors.Is(err, errors.ErrUnsupported):
+			return fmt.Errorf("stat destination: %w", err)
+		}
+	}
+	if present {
+		stats.Present++
+	} else {
+		content, err := r.src.Retrieve(ctx, info.Ref)
+		if err != nil {
+			return fmt.Errorf("read source: %w", err)
+		}
+		if err := limit.wait(ctx, len(content)); err != nil {
+			return err
+		}
+		ref, err := r.dst.Store(ctx, info.Meta, content)
+		if err != nil {
+			return fmt.Errorf("write destination: %w", err)
+		}
+		if ref != info.Ref {
+			return fmt.Errorf("destination stored the object as %s; it must keep references", ref)
+		}
+		stats.Copied++
+		stats.Bytes += int64(len(content))
+	}
+	if r.cfg.DeleteSource {
+		if err := r.src.(VaultDeleter).Delete(ctx, info.Ref); err != nil && !errors.Is(err, ErrObjectNotFound) {
+			return fmt.Errorf("delete from source: %w", err)
+		}
+		stats.Deleted++
+	}
+	return nil
+}
+
+func (r *Replication) loadState() (replicationState, error) {
+	var state replicationState
+	if r.cfg.StatePath == "" {
+		return state, nil
+	}
+	data, err := os.ReadFile(r.cfg.StatePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return state, nil
+	}
+	if err != nil {
+		return state, fmt.Errorf("read replication state: %w", err)
+	}
+	if err := json.Unmarshal(data, &state); err != nil {
+		return state, fmt.Errorf("read replication state: %w", err)
+	}
+	return state, nil
+}
+
+// saveState replaces the state file atomically.
+func (r *Replication) saveState(state replicationState) error {
+	if r.cfg.StatePath == "" {
+		return nil
+	}
+	data, _ := json.Marshal(state)
+	if err := os.MkdirAll(filepath.Dir(r.cfg.StatePath), 0o700); err != nil {
+		return err
+	}
+	tmp := r.cfg.StatePath + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("write replication state: %w", err)
+	}
+	if err := os.Rename(tmp, r.cfg.StatePath); err != nil {
+		return fmt.Errorf("write replication state: %w", err)
+	}
+	return nil
+}
+
+// Start replicates every interval in the background, beginning immediately.
+func (r *Replication) Start(_ context.Context) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	r.cancel = cancel
+	r.done.Add(1)
+	go func() {
+		defer r.done.Done()
+		ticker := time.NewTicker(r.interval)
+		defer ticker.Stop()
+		for {
+			if _, err := r.Run(ctx); err != nil && ctx.Err() == nil {
+				r.logger.Warn("vault replication failed", zap.Error(err))
+			}
+			select {
+			case <-r.stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return nil
+}
+
+// Close stops the replication loop, interrupting a pass in progress.
+func (r *Replication) Close() error {
+	select {
+	case <-r.stop:
+	default:
+		close(r.stop)
+	}
+	if r.cancel != nil {
+		r.cancel()
+	}
+	r.done.Wait()
+	return nil
+}
+
+// byteRateLimiter paces writes to an average of rate bytes per second
+// since start. A zero rate never waits.
+type byteRateLimiter struct {
+	rate  int64
+	start time.Time
+	sent  int64
+}
+
+func newByteRateLimiter(rate int64, start time.Time) *byteRateLimiter {
+	return &byteRateLimiter{rate: rate, start: start}
+}
+
+// wait blocks until n more bytes may be sent.
+func (l *byteRateLimiter) wait(ctx context.Context, n int) error {
+	if l.rate <= 0 {
+		return nil
+	}
+	l.sent += int64(n)
+	due := l.start.Add(time.Duration(float64(l.sent) / float64(l.rate) * float64(time.Second)))
+	d := time.Until(due)
+	if d <= 0 {
+		return nil
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
@@ -0,0 +1,91 @@
+package promptvaultprocessor
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestReplicationCopiesIncrementally(t *testing.T) {
+	ctx := context.Background()
+	src := NewMemoryVault(0, 0)
+	dst, err := NewFilesystemVault(FilesystemConfig{BasePath: t.TempDir(), WriteMetadata: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := NewReplication(zap.NewNop(), src, dst, ReplicationConfig{StatePath: filepath.Join(t.TempDir(), "state.json")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock := time.Now()
+	r.now = func() time.Time { return clock }
+	src.now = func() time.Time { return clock.Add(-time.Second) }
+
+	first, _ := src.Store(ctx, ObjectMeta{Key: "gen_ai.prompt", Tenant: "acme"}, []byte("first"))
+	stats, err := r.Run(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Copied != 1 || stats.Failed != 0 {
+		t.Errorf("first pass = %+v", stats)
+	}
+	if got, err := dst.Retrieve(ctx, first); err != nil || string(got) != "first" {
+		t.Errorf("destination has %q, %v", got, err)
+	}
+	if info, _ := dst.Stat(ctx, first); info.Meta.Tenant != "acme" {
+		t.Errorf("destination metadata = %+v", info.Meta)
+	}
+
+	// The next pass only considers objects stored since the first began.
+	clock = clock.Add(time.Minute)
+	second, _ := src.Store(ctx, ObjectMeta{Key: "gen_ai.prompt"}, []byte("second"))
+	if stats, err = r.Run(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if stats.Scanned != 1 || stats.Copied != 1 {
+		t.Errorf("second pass = %+v, want only the new object", stats)
+	}
+	if _, err := dst.Retrieve(ctx, second); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestReplicationDeletesSource(t *testing.T) {
+	ctx := context.Background()
+	src, dst := NewMemoryVault(0, 0), NewMemoryVault(0, 0)
+	ref, _ := src.Store(ctx, ObjectMeta{Key: "gen_ai.prompt"}, []byte("buffered"))
+	// Objects the destination already holds are not copied again.
+	dst.Store(ctx, ObjectMeta{Key: "gen_ai.prompt"}, []byte("buffered"))
+	src.Store(ctx, ObjectMeta{Key: "gen_ai.prompt"}, []byte("new"))
+
+	r, err := NewReplication(zap.NewNop(), src, dst, ReplicationConfig{DeleteSource: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stats, err := r.Run(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Copied != 1 || stats.Present != 1 || stats.Deleted != 2 {
+		t.Errorf("stats = %+v", stats)
+	}
+	if _, err := src.Retrieve(ctx, ref); !errors.Is(err, ErrObjectNotFound) {
+		t.Errorf("source still holds %s: %v", ref, err)
+	}
+}
+
+func TestByteRateLimiter(t *testing.T) {
+	l := newByteRateLimiter(1000, time.Now().Add(-time.Second))
+	if err := l.wait(context.Background(), 500); err != nil {
+		t.Errorf("within the rate: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := l.wait(ctx, 10000); !errors.Is(err, context.Canceled) {
+		t.Errorf("over the rate: %v, want to wait", err)
+	}
+}
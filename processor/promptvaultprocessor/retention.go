@@ -0,0 +1,211 @@
+package promptvaultprocessor
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"slices"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/metric"
+	"go.uber.org/zap"
+)
+
+// RetentionConfig deletes objects once they outlive their policy.
+type RetentionConfig struct {
+	// Policies are matched in order; the first whose keys and tenants
+	// match an object sets its maximum age. Objects matching no policy are
+	// kept.
+	Policies []RetentionPolicy `mapstructure:"policies"`
+	// Interval between sweeps. Default 1h.
+	Interval time.Duration `mapstructure:"interval"`
+}
+
+// RetentionPolicy is the maximum age of a class of objects.
+type RetentionPolicy struct {
+	// Name labels the policy in logs and metrics. Default: its position.
+	Name string `mapstructure:"name"`
+	// Keys are glob patterns for the attribute key the object was vaulted
+	// from, e.g. "gen_ai.input.*". Empty matches any key.
+	Keys []string `mapstructure:"keys"`
+	// Tenants restricts the policy to these tenants. Empty matches any.
+	Tenants []string      `mapstructure:"tenants"`
+	MaxAge  time.Duration `mapstructure:"max_age"`
+}
+
+func (p RetentionPolicy) matches(meta ObjectMeta) bool {
+	if len(p.Tenants) > 0 && !slices.Contains(p.Tenants, meta.Tenant) {
+		return false
+	}
+	if len(p.Keys) == 0 {
+		return true
+	}
+	for _, pattern := range p.Keys {
+		if ok, _ := path.Match(pattern, meta.Key); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// RetentionStats summarizes a sweep.
+type RetentionStats struct {
+	Scanned      int
+	Deleted      int
+	DeletedBytes int64
+	// Failed counts expired objects that could not be deleted.
+	Failed int
+}
+
+// Retention sweeps a vault, deleting objects older than their policy's
+// max_age. The vault must support listing and deletion.
+type Retention struct {
+	logger   *zap.Logger
+	vault    VaultStorage
+	policies []RetentionPolicy
+	interval time.Duration
+	metrics  *vaultMetrics
+	now      func() time.Time
+
+	stop   chan struct{}
+	cancel context.CancelFunc
+	done   sync.WaitGroup
+}
+
+// NewRetention validates cfg and prepares sweeps of vault, reporting
+// reclaimed objects and bytes to mp.
+func NewRetention(logger *zap.Logger, mp metric.MeterProvider, vault VaultStorage, cfg RetentionConfig) (*Retention, error) {
+	if _, ok := vault.(VaultLister); !ok {
+		return nil, fmt.Errorf("retention: storage backend cannot list objects")
+	}
+	if _, ok := vault.(VaultDeleter); !ok {
+		return nil, fmt.Errorf("retention: storage backend cannot delete objects")
+	}
+	policies := make([]RetentionPolicy, len(cfg.Policies))
+	for i, p := range cfg.Policies {
+		if p.Name == "" {
+			p.Name = fmt.Sprint(i)
+		}
+		if p.MaxAge <= 0 {
+			return nil, fmt.Errorf("retention policy %s: max_age must be positive", p.Name)
+		}
+		for _, pattern := range p.Keys {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("retention policy %s: key pattern %q: %w", p.Name, pattern, err)
+			}
+		}
+		policies[i] = p
+	}
+	metrics, err := newVaultMetrics(mp)
+	if err != nil {
+		return nil, err
+	}
+	interval := cfg.Interval
+	if interval <= 0 {
+		interval = time.Hour
+	}
+	return &Retention{
+		logger:   logger,
+		vault:    vault,
+		policies: policies,
+		interval: interval,
+		metrics:  metrics,
+		now:      time.Now,
+		stop:     make(chan struct{}),
+	}, nil
+}
+
+// Sweep deletes every expired object once.
+func (r *Retention) Sweep(ctx context.Context) (RetentionStats, error) {
+	type expired struct {
+		info   ObjectInfo
+		policy string
+	}
+	var stats RetentionStats
+	var victims []expired
+	now := r.now()
+	// Collect first: deleting while a backend iterates is not safe for all.
+	err := r.vault.(VaultLister).List(ctx, func(info ObjectInfo) error {
+		stats.Scanned++
+		for _, p := range r.policies {
+			if p.matches(info.Meta) {
+				if now.Sub(info.StoredAt) > p.MaxAge {
+					victims = append(victims, expired{info, p.Name})
+				}
+				break
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return stats, err
+	}
+
+	deleter := r.vault.(VaultDeleter)
+	deleted := make(map[string]int)
+	deletedBytes := make(map[string]int64)
+	for _, v := range victims {
+		if err := deleter.Delete(ctx, v.info.Ref); err != nil {
+			if ctx.Err() != nil {
+				return stats, ctx.Err()
+			}
+			r.logger.Warn("retention delete failed", zap.String("ref", v.info.Ref), zap.Error(err))
+			stats.Failed++
+			continue
+		}
+		stats.Deleted++
+		stats.DeletedBytes += v.info.Size
+		deleted[v.policy]++
+		deletedBytes[v.policy] += v.info.Size
+	}
+	for policy, n := range deleted {
+		r.metrics.recordRetention(ctx, policy, n, deletedBytes[policy])
+	}
+	if stats.Deleted > 0 || stats.Failed > 0 {
+		r.logger.Info("retention sweep",
+			zap.Int("scanned", stats.Scanned),
+			zap.Int("deleted", stats.Deleted),
+			zap.Int64("deleted_bytes", stats.DeletedBytes),
+			zap.Int("failed", stats.Failed),
+		)
+	}
+	return stats, nil
+}
+
+// Start sweeps every interval in the background, beginning immediately.
+func (r *Retention) Start(_ context.Context) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	r.cancel = cancel
+	r.done.Add(1)
+	go func() {
+		defer r.done.Done()
+		ticker := time.NewTicker(r.interval)
+		defer ticker.Stop()
+		for {
+			if _, err := r.Sweep(ctx); err != nil && ctx.Err() == nil {
+				r.logger.Warn("retention sweep failed", zap.Error(err))
+			}
+			select {
+			case <-r.stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return nil
+}
+
+// Close stops the sweep loop, interrupting a sweep in progress.
+func (r *Retention) Close() error {
+	select {
+	case <-r.stop:
+	default:
+		close(r.stop)
+	}
+	if r.cancel != nil {
+		r.cancel()
+	}
+	r.done.Wait()
+	return nil
+}
@@ -0,0 +1,71 @@
+package promptvaultprocessor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestRetentionSweep(t *testing.T) {
+	ctx := context.Background()
+	vault := NewMemoryVault(0, 0)
+	refs := make(map[string]string)
+	for name, meta := range map[string]ObjectMeta{
+		"input":        {Key: "gen_ai.input.messages"},
+		"output":       {Key: "gen_ai.output.messages"},
+		"acme output":  {Key: "gen_ai.output.messages", Tenant: "acme"},
+		"other":        {Key: "http.request.body"},
+		"recent input": {Key: "gen_ai.input.messages", Tenant: "recent"},
+	} {
+		refs[name], _ = vault.Store(ctx, meta, []byte(name))
+	}
+
+	r, err := NewRetention(zap.NewNop(), nil, vault, RetentionConfig{Policies: []RetentionPolicy{
+		{Name: "acme", Tenants: []string{"acme"}, MaxAge: 90 * 24 * time.Hour},
+		{Name: "inputs", Keys: []string{"gen_ai.input.*"}, MaxAge: 30 * 24 * time.Hour},
+		{Name: "outputs", Keys: []string{"gen_ai.output.*"}, MaxAge: 7 * 24 * time.Hour},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.now = func() time.Time { return time.Now().Add(10 * 24 * time.Hour) }
+
+	stats, err := r.Sweep(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Scanned != 5 || stats.Deleted != 1 || stats.DeletedBytes != int64(len("output")) {
+		t.Errorf("stats = %+v, want only the 10-day-old output deleted", stats)
+	}
+	for name, ref := range refs {
+		_, err := vault.Retrieve(ctx, ref)
+		if gone := err != nil; gone != (name == "output") {
+			t.Errorf("%s: deleted = %v", name, gone)
+		}
+	}
+
+	// Past every max_age, the acme policy still keeps its output and
+	// objects matching no policy are never deleted.
+	r.now = func() time.Time { return time.Now().Add(60 * 24 * time.Hour) }
+	if stats, _ := r.Sweep(ctx); stats.Deleted != 2 {
+		t.Errorf("second sweep deleted %d, want both inputs", stats.Deleted)
+	}
+	for _, name := range []string{"acme output", "other"} {
+		if _, err := vault.Retrieve(ctx, refs[name]); err != nil {
+			t.Errorf("%s deleted: %v", name, err)
+		}
+	}
+}
+
+func TestRetentionRejectsBadPolicies(t *testing.T) {
+	for name, cfg := range map[string]RetentionConfig{
+		"no max_age":  {Policies: []RetentionPolicy{{Keys: []string{"gen_ai.*"}}}},
+		"bad pattern": {Policies: []RetentionPolicy{{Keys: []string{"gen_ai.["}, MaxAge: time.Hour}}},
+	} {
+		if _, err := NewRetention(zap.NewNop(), nil, NewMemoryVault(0, 0), cfg); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
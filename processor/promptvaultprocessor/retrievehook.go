@@ -0,0 +1,132 @@
+package promptvaultprocessor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// RetrieveHook runs on content read back from the vault before it is
+// returned to a caller of the retrieval API, the rehydrate processor, or a
+// promptvaultctl export, e.g. to re-redact fields the requester may not see
+// or to inject a watermark. It runs after signature verification. In the
+// retrieval API the requester is available from ctx through
+// retrieval.PrincipalFrom.
+type RetrieveHook interface {
+	AfterRetrieve(ctx context.Context, info ObjectInfo, data []byte) ([]byte, error)
+}
+
+// RetrieveHookFunc adapts a function to the RetrieveHook interface.
+type RetrieveHookFunc func(ctx context.Context, info ObjectInfo, data []byte) ([]byte, error)
+
+// AfterRetrieve calls f.
+func (f RetrieveHookFunc) AfterRetrieve(ctx context.Context, info ObjectInfo, data []byte) ([]byte, error) {
+	return f(ctx, info, data)
+}
+
+// RetrieveHookFactory builds a RetrieveHook from the settings of one hooks
+// entry.
+type RetrieveHookFactory func(settings map[string]any) (RetrieveHook, error)
+
+// RetrieveHookConfig selects a registered retrieve hook.
+type RetrieveHookConfig struct {
+	// Name is the name the hook was registered under.
+	Name string `mapstructure:"name"`
+	// Settings are passed to the hook's factory.
+	Settings map[string]any `mapstructure:"settings"`
+}
+
+var (
+	retrieveHooksMu sync.RWMutex
+	retrieveHooks   = make(map[string]RetrieveHookFactory)
+)
+
+// RegisterRetrieveHook makes a hook available to the hooks settings under
+// name. It is meant to be called from an init function of a package built
+// into a custom distribution, and panics if name is empty or already
+// registered.
+func RegisterRetrieveHook(name string, factory RetrieveHookFactory) {
+	retrieveHooksMu.Lock()
+	defer retrieveHooksMu.Unlock()
+	if name == "" || factory == nil {
+		panic("promptvault: RegisterRetrieveHook needs a name and a factory")
+	}
+	if _, dup := retrieveHooks[name]; dup {
+		panic("promptvault: RegisterRetrieveHook called twice for " + name)
+	}
+	retrieveHooks[name] = factory
+}
+
+// RegisteredRetrieveHooks returns the names of registered hooks, sorted.
+func RegisteredRetrieveHooks() []string {
+	retrieveHooksMu.RLock()
+	defer retrieveHooksMu.RUnlock()
+	names := make([]string, 0, len(retrieveHooks))
+	for name := range retrieveHooks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// RetrieveHooks is a chain of retrieve hooks, run in order. The zero value
+// runs none.
+type RetrieveHooks []namedRetrieveHook
+
+type namedRetrieveHook struct {
+	name string
+	RetrieveHook
+}
+
+// NewRetrieveHooks builds the chain configured by cfgs.
+func NewRetrieveHooks(cfgs []RetrieveHookConfig) (RetrieveHooks, error) {
+	retrieveHooksMu.RLock()
+	defer retrieveHooksMu.RUnlock()
+	var hooks RetrieveHooks
+	for i, cfg := range cfgs {
+		factory, ok := retrieveHooks[cfg.Name]
+		if !ok {
+			return nil, fmt.Errorf("hooks[%d]: unknown retrieve hook %q", i, cfg.Name)
+		}
+		h, err := factory(cfg.Settings)
+		if err != nil {
+			return nil, fmt.Errorf("hooks[%d] (%s): %w", i, cfg.Name, err)
+		}
+		hooks = append(hooks, namedRetrieveHook{name: cfg.Name, RetrieveHook: h})
+	}
+	return hooks, nil
+}
+
+// Apply passes data, read from the object info describes, through each hook
+// in turn.
+func (h RetrieveHooks) Apply(ctx context.Context, info ObjectInfo, data []byte) ([]byte, error) {
+	for _, hook := range h {
+		var err error
+		if data, err = hook.AfterRetrieve(ctx, info, data); err != nil {
+			return nil, fmt.Errorf("retrieve hook %s: %w", hook.name, err)
+		}
+	}
+	return data, nil
+}
+
+// StatForHooks describes ref for the hooks, falling back to just the
+// reference when the vault cannot describe it. It stats only when there
+// are hooks to run.
+func (h RetrieveHooks) StatForHooks(ctx context.Context, vault VaultStorage, ref string) (ObjectInfo, error) {
+	info := ObjectInfo{Ref: ref}
+	if len(h) == 0 {
+		return info, nil
+	}
+	if s, ok := vault.(VaultStatter); ok {
+		got, err := s.Stat(ctx, ref)
+		switch {
+		case err == nil:
+			info = got
+		case !errors.Is(err, errors.ErrUnsupported):
+			return info, err
+		}
+	}
+	return info, nil
+}
@@ -0,0 +1,65 @@
+package promptvaultprocessor
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func init() {
+	RegisterRetrieveHook("test_suffix", func(settings map[string]any) (RetrieveHook, error) {
+		suffix, _ := settings["suffix"].(string)
+		return RetrieveHookFunc(func(_ context.Context, _ ObjectInfo, data []byte) ([]byte, error) {
+			return append(data, suffix...), nil
+		}), nil
+	})
+	RegisterRetrieveHook("test_deny_tenant", func(settings map[string]any) (RetrieveHook, error) {
+		tenant, _ := settings["tenant"].(string)
+		return RetrieveHookFunc(func(_ context.Context, info ObjectInfo, data []byte) ([]byte, error) {
+			if info.Meta.Tenant == tenant {
+				return nil, errors.New("tenant withheld")
+			}
+			return data, nil
+		}), nil
+	})
+}
+
+func TestRetrieveHooksApply(t *testing.T) {
+	ctx := context.Background()
+	hooks, err := NewRetrieveHooks([]RetrieveHookConfig{
+		{Name: "test_deny_tenant", Settings: map[string]any{"tenant": "acme"}},
+		{Name: "test_suffix", Settings: map[string]any{"suffix": " [1]"}},
+		{Name: "test_suffix", Settings: map[string]any{"suffix": " [2]"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := hooks.Apply(ctx, ObjectInfo{Meta: ObjectMeta{Tenant: "globex"}}, []byte("content"))
+	if err != nil || string(got) != "content [1] [2]" {
+		t.Errorf("Apply = %q, %v", got, err)
+	}
+	if _, err := hooks.Apply(ctx, ObjectInfo{Meta: ObjectMeta{Tenant: "acme"}}, []byte("content")); err == nil || !strings.Contains(err.Error(), "test_deny_tenant") {
+		t.Errorf("Apply for a withheld tenant: %v", err)
+	}
+
+	// Without hooks content is returned unchanged.
+	var none RetrieveHooks
+	if got, err := none.Apply(ctx, ObjectInfo{}, []byte("content")); err != nil || string(got) != "content" {
+		t.Errorf("Apply without hooks = %q, %v", got, err)
+	}
+	if _, err := NewRetrieveHooks([]RetrieveHookConfig{{Name: "missing"}}); err == nil {
+		t.Error("expected error for an unknown hook")
+	}
+}
+
+func TestRetrieveHooksStat(t *testing.T) {
+	ctx := context.Background()
+	vault := NewMemoryVault(0, 0)
+	ref, _ := vault.Store(ctx, ObjectMeta{Key: "gen_ai.prompt", Tenant: "acme"}, []byte("content"))
+	hooks, _ := NewRetrieveHooks([]RetrieveHookConfig{{Name: "test_suffix"}})
+	info, err := hooks.StatForHooks(ctx, vault, ref)
+	if err != nil || info.Meta.Tenant != "acme" {
+		t.Errorf("StatForHooks = %+v, %v", info, err)
+	}
+}
@@ -0,0 +1,327 @@
+package promptvaultprocessor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// ErrUnroutable is returned, wrapped, when an object's region has no
+// storage, or it has no region and routing requires one.
+var ErrUnroutable = errors.New("no storage for the object's region")
+
+// regionRefPrefix marks references to objects stored in a regional backend:
+// vault://region/<name>/<the backend's reference without its scheme>.
+const regionRefPrefix = refScheme + "region/"
+
+var regionNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_-]*$`)
+
+// RoutingConfig sends each object to the backend of its region, so content
+// from, e.g., EU customers is only ever written to EU storage. An object's
+// region is its tenant's entry in Tenants or, failing that, the resource
+// attribute named by vault.region_attribute. Objects without a region go
+// to the top-level backend unless RequireRegion is set; objects whose
+// region has no backend are never stored.
+type RoutingConfig struct {
+	// Regions configures the backend of each region.
+	Regions map[string]RegionStorageConfig `mapstructure:"regions"`
+	// Tenants maps tenant IDs to regions, taking precedence over the
+	// region attribute.
+	Tenants map[string]string `mapstructure:"tenants"`
+	// RequireRegion rejects objects without a region instead of storing
+	// them in the top-level backend.
+	RequireRegion bool `mapstructure:"require_region"`
+}
+
+// RegionStorageConfig selects one region's backend. Its settings mean the
+// same as the top-level ones; storage.path_template applies to every region.
+type RegionStorageConfig struct {
+	Backend    string           `mapstructure:"backend"`
+	Filesystem FilesystemConfig `mapstructure:"filesystem"`
+	Postgres   PostgresConfig   `mapstructure:"postgres"`
+	SQLite     SQLiteConfig     `mapstructure:"sqlite"`
+	Kafka      KafkaConfig      `mapstructure:"kafka"`
+	Memory     MemoryConfig     `mapstructure:"memory"`
+	HTTP       HTTPConfig       `mapstructure:"http"`
+	Tiered     TieredConfig     `mapstructure:"tiered"`
+}
+
+func (c RoutingConfig) enabled() bool {
+	return len(c.Regions) > 0
+}
+
+func (c RoutingConfig) validate() error {
+	if !c.enabled() {
+		if len(c.Tenants) > 0 || c.RequireRegion {
+			return fmt.Errorf("storage.routing needs at least one region")
+		}
+		return nil
+	}
+	for name := range c.Regions {
+		if !regionNamePattern.MatchString(name) {
+			return fmt.Errorf("storage.routing: invalid region name %q", name)
+		}
+	}
+	for tenant, region := range c.Tenants {
+		if _, ok := c.Regions[region]; !ok {
+			return fmt.Errorf("storage.routing: tenant %q is mapped to unknown region %q", tenant, region)
+		}
+	}
+	return nil
+}
+
+// routingVault stores each object in its region's backend and prefixes the
+// backend's reference with the region, so reads go straight to it.
+type routingVault struct {
+	VaultStorage // the top-level backend, for objects without a region
+	regions      map[string]VaultStorage
+	tenants      map[string]string
+	require      bool
+}
+
+// newRoutingVault builds the regional backends configured by cfg.Storage.Routing
+// around def, the top-level backend. It returns def unchanged when routing
+// is off.
+func newRoutingVault(ctx context.Context, logger *zap.Logger, metrics *vaultMetrics, def VaultStorage, cfg StorageConfig) (VaultStorage, error) {
+	if err := cfg.Routing.validate(); err != nil {
+		return nil, err
+	}
+	if !cfg.Routing.enabled() {
+		return def, nil
+	}
+	if cfg.Bundles.Index != "" {
+		// Bundles mix objects regardless of region and are written to the
+		// top-level backend.
+		return nil, fmt.Errorf("storage.routing cannot be combined with storage.bundles")
+	}
+	v := &routingVault{
+		VaultStorage: def,
+		regions:      make(map[string]VaultStorage, len(cfg.Routing.Regions)),
+		tenants:      cfg.Routing.Tenants,
+		require:      cfg.Routing.RequireRegion,
+	}
+	for name, rc := range cfg.Routing.Regions {
+		regionCfg := StorageConfig{
+			Backend:      rc.Backend,
+			Filesystem:   rc.Filesystem,
+			Postgres:     rc.Postgres,
+			SQLite:       rc.SQLite,
+			Kafka:        rc.Kafka,
+			Memory:       rc.Memory,
+			HTTP:         rc.HTTP,
+			Tiered:       rc.Tiered,
+			PathTemplate: cfg.PathTemplate,
+		}
+		backend, err := newVaultStorage(ctx, logger, metrics, regionCfg)
+		if err != nil {
+			for _, created := range v.regions {
+				if c, ok := created.(io.Closer); ok {
+					c.Close()
+				}
+			}
+			return nil, fmt.Errorf("create storage for region %s: %w", name, err)
+		}
+		v.regions[name] = backend
+	}
+	return v, nil
+}
+
+// regionOf returns the region meta is routed to, "" for none.
+func (v *routingVault) regionOf(meta ObjectMeta) string {
+	if region, ok := v.tenants[meta.Tenant]; ok && meta.Tenant != "" {
+		return region
+	}
+	return meta.Region
+}
+
+// route returns the backend holding ref and the backend's own reference.
+func (v *routingVault) route(ref string) (string, VaultStorage, string, error) {
+	rest, ok := strings.CutPrefix(ref, regionRefPrefix)
+	if !ok {
+		return "", v.VaultStorage, ref, nil
+	}
+	name, inner, _ := strings.Cut(rest, "/")
+	backend, ok := v.regions[name]
+	if !ok || inner == "" {
+		return "", nil, "", fmt.Errorf("%w: no storage for region of %s", ErrObjectNotFound, ref)
+	}
+	return name, backend, refScheme + inner, nil
+}
+
+func regionRef(region, ref string) string {
+	if region == "" {
+		return ref
+	}
+	return regionRefPrefix + region + "/" + strings.TrimPrefix(ref, refScheme)
+}
+
+// unroutedRef strips any region prefix from ref.
+func unroutedRef(ref string) string {
+	rest, ok := strings.CutPrefix(ref, regionRefPrefix)
+	if !ok {
+		return ref
+	}
+	if _, inner, ok := strings.Cut(rest, "/"); ok {
+		return refScheme + inner
+	}
+	return ref
+}
+
+// Store writes content to its region's backend.
+func (v *routingVault) Store(ctx context.Context, meta ObjectMeta, content []byte) (string, error) {
+	region := v.regionOf(meta)
+	if region == "" {
+		if v.require {
+			return "", fmt.Errorf("%w: object has no region", ErrUnroutable)
+		}
+		return v.VaultStorage.Store(ctx, meta, content)
+	}
+	backend, ok := v.regions[region]
+	if !ok {
+		return "", fmt.Errorf("%w: region %q", ErrUnroutable, region)
+	}
+	ref, err := backend.Store(ctx, meta, content)
+	if err != nil {
+		return "", err
+	}
+	return regionRef(region, ref), nil
+}
+
+// Retrieve reads ref from the backend that holds it.
+func (v *routingVault) Retrieve(ctx context.Context, ref string) ([]byte, error) {
+	_, backend, inner, err := v.route(ref)
+	if err != nil {
+		return nil, err
+	}
+	return backend.Retrieve(ctx, inner)
+}
+
+// Stat describes ref from the backend that holds it.
+func (v *routingVault) Stat(ctx context.Context, ref string) (ObjectInfo, error) {
+	region, backend, inner, err := v.route(ref)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	s, ok := backend.(VaultStatter)
+	if !ok {
+		return ObjectInfo{}, fmt.Errorf("stat: %w", errors.ErrUnsupported)
+	}
+	info, err := s.Stat(ctx, inner)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	info.Ref = regionRef(region, info.Ref)
+	if region != "" {
+		info.Meta.Region = region
+	}
+	return info, nil
+}
+
+// Delete removes ref from the backend that holds it.
+func (v *routingVault) Delete(ctx context.Context, ref string) error {
+	_, backend, inner, err := v.route(ref)
+	if err != nil {
+		return err
+	}
+	d, ok := backend.(VaultDeleter)
+	if !ok {
+		return fmt.Errorf("delete: %w", errors.ErrUnsupported)
+	}
+	return d.Delete(ctx, inner)
+}
+
+// Replace overwrites ref in the backend that holds it.
+func (v *routingVault) Replace(ctx context.Context, ref string, content []byte) error {
+	_, backend, inner, err := v.route(ref)
+	if err != nil {
+		return err
+	}
+	r, ok := backend.(VaultReplacer)
+	if !ok {
+		return fmt.Errorf("replace: %w", errors.ErrUnsupported)
+	}
+	return r.Replace(ctx, inner, content)
+}
+
+// backends returns the top-level backend, under "", and each region's, in
+// a stable order.
+func (v *routingVault) backends() ([]string, []VaultStorage) {
+	names := []string{""}
+	for name := range v.regions {
+		names = append(names, name)
+	}
+	sort.Strings(names[1:])
+	backends := make([]VaultStorage, len(names))
+	for i, name := range names {
+		if name == "" {
+			backends[i] = v.VaultStorage
+		} else {
+			backends[i] = v.regions[name]
+		}
+	}
+	return names, backends
+}
+
+// List enumerates every region's objects. Backends that cannot list are
+// skipped, unless none can.
+func (v *routingVault) List(ctx context.Context, fn func(ObjectInfo) error) error {
+	return v.Search(ctx, ObjectFilter{}, fn)
+}
+
+// Search finds matching objects in every region.
+func (v *routingVault) Search(ctx context.Context, f ObjectFilter, fn func(ObjectInfo) error) error {
+	names, backends := v.backends()
+	searched := false
+	for i, backend := range backends {
+		region := names[i]
+		err := SearchObjects(ctx, backend, f, func(info ObjectInfo) error {
+			info.Ref = regionRef(region, info.Ref)
+			if region != "" {
+				info.Meta.Region = region
+			}
+			return fn(info)
+		})
+		if errors.Is(err, errors.ErrUnsupported) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		searched = true
+	}
+	if !searched {
+		return fmt.Errorf("list: %w", errors.ErrUnsupported)
+	}
+	return nil
+}
+
+// Start starts every region's background work.
+func (v *routingVault) Start(ctx context.Context) error {
+	_, backends := v.backends()
+	for _, backend := range backends {
+		if s, ok := backend.(vaultStarter); ok {
+			if err := s.Start(ctx); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Close closes every region's backend.
+func (v *routingVault) Close() error {
+	_, backends := v.backends()
+	var errs []error
+	for _, backend := range backends {
+		if c, ok := backend.(io.Closer); ok {
+			errs = append(errs, c.Close())
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,176 @@
+package promptvaultprocessor
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.uber.org/zap"
+)
+
+func newTestRoutingVault(t *testing.T, cfg StorageConfig) *routingVault {
+	t.Helper()
+	v, err := newRoutingVault(context.Background(), zap.NewNop(), nil, NewMemoryVault(0, 0), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rv := v.(*routingVault)
+	t.Cleanup(func() { rv.Close() })
+	return rv
+}
+
+func TestRoutingVaultStoresInRegion(t *testing.T) {
+	ctx := context.Background()
+	eu, us := t.TempDir(), t.TempDir()
+	v := newTestRoutingVault(t, StorageConfig{Routing: RoutingConfig{
+		Regions: map[string]RegionStorageConfig{
+			"eu": {Filesystem: FilesystemConfig{BasePath: eu}},
+			"us": {Filesystem: FilesystemConfig{BasePath: us}},
+		},
+		Tenants: map[string]string{"acme-eu": "eu"},
+	}})
+
+	// The tenant map wins over the region attribute.
+	ref, err := v.Store(ctx, ObjectMeta{Key: "gen_ai.prompt", Tenant: "acme-eu", Region: "us-east-1"}, []byte("bonjour"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "vault://region/eu/" + contentHash([]byte("bonjour")); ref != want {
+		t.Errorf("ref = %s, want %s", ref, want)
+	}
+	if got, err := v.Retrieve(ctx, ref); err != nil || string(got) != "bonjour" {
+		t.Errorf("retrieve = %q, %v", got, err)
+	}
+	if n := countFiles(t, eu); n != 1 {
+		t.Errorf("eu holds %d objects, want 1", n)
+	}
+	if n := countFiles(t, us); n != 0 {
+		t.Errorf("us holds %d objects, want 0", n)
+	}
+
+	ref, err = v.Store(ctx, ObjectMeta{Key: "gen_ai.prompt", Region: "us"}, []byte("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(ref, "vault://region/us/") {
+		t.Errorf("ref = %s, want it in us", ref)
+	}
+	info, err := v.Stat(ctx, ref)
+	if err != nil || info.Ref != ref || info.Meta.Region != "us" {
+		t.Errorf("stat = %+v, %v", info, err)
+	}
+
+	// Objects without a region go to the top-level backend.
+	plain, err := v.Store(ctx, ObjectMeta{Key: "gen_ai.prompt"}, []byte("hi"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.HasPrefix(plain, regionRefPrefix) {
+		t.Errorf("ref = %s, want the top-level backend's", plain)
+	}
+
+	var refs []string
+	if err := v.List(ctx, func(info ObjectInfo) error {
+		refs = append(refs, info.Ref)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if len(refs) != 3 {
+		t.Errorf("listed %v, want 3 refs", refs)
+	}
+
+	if err := v.Delete(ctx, ref); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := v.Retrieve(ctx, ref); err == nil {
+		t.Error("retrieve after delete succeeded")
+	}
+}
+
+func TestRoutingVaultNeverFallsBack(t *testing.T) {
+	ctx := context.Background()
+	v := newTestRoutingVault(t, StorageConfig{Routing: RoutingConfig{
+		Regions:       map[string]RegionStorageConfig{"eu": {Backend: "memory"}},
+		RequireRegion: true,
+	}})
+
+	if _, err := v.Store(ctx, ObjectMeta{Key: "k", Region: "ap"}, []byte("x")); !errors.Is(err, ErrUnroutable) {
+		t.Errorf("store to a region without storage: %v", err)
+	}
+	if _, err := v.Store(ctx, ObjectMeta{Key: "k"}, []byte("x")); !errors.Is(err, ErrUnroutable) {
+		t.Errorf("store without a region: %v", err)
+	}
+	if _, err := v.Retrieve(ctx, "vault://region/ap/abc"); !errors.Is(err, ErrObjectNotFound) {
+		t.Errorf("retrieve from an unknown region: %v", err)
+	}
+}
+
+func TestProcessorRoutesByRegionAttribute(t *testing.T) {
+	cfg := createDefaultConfig()
+	cfg.Vault.RegionAttribute = "cloud.region"
+	vault := newTestRoutingVault(t, StorageConfig{Routing: RoutingConfig{
+		Regions: map[string]RegionStorageConfig{"eu-west-1": {Backend: "memory"}},
+	}})
+	proc := newVaultProcessor(zap.NewNop(), cfg, vault, new(consumertest.TracesSink))
+
+	td := ptrace.NewTraces()
+	rs := td.ResourceSpans().AppendEmpty()
+	rs.Resource().Attributes().PutStr("cloud.region", "eu-west-1")
+	span := rs.ScopeSpans().AppendEmpty().Spans().AppendEmpty()
+	span.Attributes().PutStr("gen_ai.prompt", "Tell me about quantum computing")
+	if err := proc.ConsumeTraces(context.Background(), td); err != nil {
+		t.Fatal(err)
+	}
+
+	ref, _ := span.Attributes().Get("gen_ai.prompt.vault_ref")
+	if !strings.HasPrefix(ref.Str(), "vault://region/eu-west-1/") {
+		t.Errorf("vault_ref = %q, want it in eu-west-1", ref.Str())
+	}
+}
+
+func TestRoutingConfigValidate(t *testing.T) {
+	regions := map[string]RegionStorageConfig{"eu": {Backend: "memory"}}
+	for name, cfg := range map[string]StorageConfig{
+		"tenants without regions": {Routing: RoutingConfig{Tenants: map[string]string{"a": "eu"}}},
+		"unknown tenant region":   {Routing: RoutingConfig{Regions: regions, Tenants: map[string]string{"a": "us"}}},
+		"bad region name":         {Routing: RoutingConfig{Regions: map[string]RegionStorageConfig{"e/u": {}}}},
+		"with bundles":            {Routing: RoutingConfig{Regions: regions}, Bundles: BundleConfig{Index: "x"}},
+	} {
+		if _, err := newRoutingVault(context.Background(), zap.NewNop(), nil, NewMemoryVault(0, 0), cfg); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestUnroutedRef(t *testing.T) {
+	for ref, want := range map[string]string{
+		"vault://region/eu/2024/01/svc/abc": "vault://2024/01/svc/abc",
+		"vault://abc":                       "vault://abc",
+		"vault://region/eu":                 "vault://region/eu",
+	} {
+		if got := unroutedRef(ref); got != want {
+			t.Errorf("unroutedRef(%s) = %s, want %s", ref, got, want)
+		}
+	}
+}
+
+func countFiles(t *testing.T, dir string) int {
+	t.Helper()
+	n := 0
+	err := filepath.WalkDir(dir, func(_ string, d os.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			n++
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
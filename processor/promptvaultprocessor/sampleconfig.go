@@ -0,0 +1,251 @@
+package promptvaultprocessor
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// configSources are the files declaring the config structs. Their doc
+// comments become the comments of the sample config, so it documents
+// exactly what the code accepts.
+//
+//go:embed bundle.go config.go contentsearch.go index.go notify.go policy.go quota.go replicate.go
+//go:embed retention.go retrievehook.go routing.go tracemanifest.go transform.go
+var configSources embed.FS
+
+// configDocs maps "Type" and "Type.Field" to doc comments, for every struct
+// type declared in configSources.
+var configDocs = sync.OnceValue(func() map[string]string {
+	docs := make(map[string]string)
+	entries, _ := configSources.ReadDir(".")
+	fset := token.NewFileSet()
+	for _, e := range entries {
+		src, _ := configSources.ReadFile(e.Name())
+		f, err := parser.ParseFile(fset, e.Name(), src, parser.ParseComments)
+		if err != nil {
+			continue
+		}
+		for _, decl := range f.Decls {
+			gen, ok := decl.(*ast.GenDecl)
+			if !ok || gen.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range gen.Specs {
+				ts := spec.(*ast.TypeSpec)
+				st, ok := ts.Type.(*ast.StructType)
+				if !ok {
+					continue
+				}
+				doc := ts.Doc
+				if doc == nil {
+					doc = gen.Doc
+				}
+				docs[ts.Name.Name] = doc.Text()
+				for _, field := range st.Fields.List {
+					text := field.Doc.Text() + field.Comment.Text()
+					for _, name := range field.Names {
+						docs[ts.Name.Name+"."+name.Name] = text
+					}
+				}
+			}
+		}
+	}
+	return docs
+})
+
+// SampleBackends returns the storage backends WriteSampleConfig accepts.
+func SampleBackends() []string {
+	var names []string
+	t := reflect.TypeOf(RegionStorageConfig{})
+	for i := 0; i < t.NumField(); i++ {
+		if name := yamlName(t.Field(i)); name != "backend" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// WriteSampleConfig writes a collector config holding a promptvault
+// processor with every setting at its default, each preceded by its doc
+// comment. Of the storage backends, only the settings of backend (and, for
+// "tiered", of its hot tier) are included. The sample is built from Config
+// itself, so it always matches what the processor accepts.
+func WriteSampleConfig(w io.Writer, backend string) error {
+	include := map[string]bool{backend: true}
+	known := false
+	for _, name := range SampleBackends() {
+		known = known || name == backend
+	}
+	if !known {
+		return fmt.Errorf("unknown backend %q, want one of %s", backend, strings.Join(SampleBackends(), ", "))
+	}
+	cfg := createDefaultConfig()
+	cfg.Storage.Backend = backend
+	if backend == "tiered" {
+		include[cfg.Storage.Tiered.Hot] = true
+	}
+
+	s := &sampleWriter{docs: configDocs(), include: include}
+	s.printf("# promptvault processor settings, each shown with its default.\n")
+	s.printf("# Generated by `promptvaultctl print-config -backend %s`.\n", backend)
+	s.printf("processors:\n  promptvault:\n")
+	s.writeStruct(reflect.ValueOf(*cfg), "    ")
+	_, err := w.Write(s.buf.Bytes())
+	return err
+}
+
+type sampleWriter struct {
+	buf     bytes.Buffer
+	docs    map[string]string
+	include map[string]bool
+	// noDocs leaves out comments, inside commented-out examples.
+	noDocs bool
+}
+
+func (s *sampleWriter) printf(format string, args ...any) {
+	fmt.Fprintf(&s.buf, format, args...)
+}
+
+func (s *sampleWriter) writeStruct(v reflect.Value, indent string) {
+	t := v.Type()
+	var backends map[string]bool
+	if t == reflect.TypeOf(StorageConfig{}) || t == reflect.TypeOf(RegionStorageConfig{}) {
+		backends = make(map[string]bool)
+		for _, name := range SampleBackends() {
+			backends[name] = true
+		}
+	}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		if strings.HasSuffix(field.Tag.Get("mapstructure"), ",squash") {
+			s.writeStruct(v.Field(i), indent)
+			continue
+		}
+		name := yamlName(field)
+		if name == "" || name == "-" {
+			continue
+		}
+		fv := v.Field(i)
+		if backends[name] && !s.include[name] {
+			continue
+		}
+		doc, ok := s.docs[t.Name()+"."+field.Name]
+		if strings.Contains(doc, "Deprecated:") {
+			continue
+		}
+		if (!ok || doc == "") && fv.Kind() == reflect.Struct {
+			// Fall back to the doc of the field's type, e.g. StorageConfig.
+			doc = s.docs[fv.Type().Name()]
+			field.Name = fv.Type().Name()
+		}
+		s.comment(indent, renameDoc(doc, field.Name, name))
+		s.writeField(indent, name, fv)
+	}
+}
+
+func (s *sampleWriter) comment(indent, doc string) {
+	if s.noDocs || doc == "" {
+		return
+	}
+	for _, line := range strings.Split(strings.TrimRight(doc, "\n"), "\n") {
+		s.printf("%s# %s\n", indent, line)
+	}
+}
+
+func (s *sampleWriter) writeField(indent, name string, v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Struct:
+		s.printf("%s%s:\n", indent, name)
+		s.writeStruct(v, indent+"  ")
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Struct {
+			s.printf("%s%s: []\n", indent, name)
+			s.example(indent, "- ", "  ", v.Type().Elem())
+			return
+		}
+		if v.Len() == 0 {
+			s.printf("%s%s: []\n", indent, name)
+			return
+		}
+		s.printf("%s%s:\n", indent, name)
+		for i := 0; i < v.Len(); i++ {
+			s.printf("%s  - %s\n", indent, sampleScalar(v.Index(i)))
+		}
+	case reflect.Map:
+		if v.Len() == 0 {
+			s.printf("%s%s: {}\n", indent, name)
+			if v.Type().Elem().Kind() == reflect.Struct {
+				s.example(indent, "<name>:\n  ", "  ", v.Type().Elem())
+			}
+			return
+		}
+		s.printf("%s%s:\n", indent, name)
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+		for _, k := range keys {
+			s.writeField(indent+"  ", k.String(), v.MapIndex(k))
+		}
+	default:
+		s.printf("%s%s: %s\n", indent, name, sampleScalar(v))
+	}
+}
+
+// example writes an element of type t, commented out, as an example entry
+// of an empty list or map.
+func (s *sampleWriter) example(indent, first, rest string, t reflect.Type) {
+	sub := &sampleWriter{docs: s.docs, include: s.include, noDocs: true}
+	sub.writeStruct(reflect.New(t).Elem(), "")
+	lines := strings.Split(strings.TrimRight(sub.buf.String(), "\n"), "\n")
+	for i, line := range lines {
+		prefix := rest
+		if i == 0 {
+			prefix = first
+		}
+		for _, l := range strings.Split(prefix+line, "\n") {
+			s.printf("%s# %s\n", indent, l)
+		}
+	}
+}
+
+func sampleScalar(v reflect.Value) string {
+	if d, ok := v.Interface().(time.Duration); ok {
+		return d.String()
+	}
+	switch v.Kind() {
+	case reflect.String:
+		out, _ := yaml.Marshal(v.String())
+		return strings.TrimSuffix(string(out), "\n")
+	default:
+		return fmt.Sprint(v.Interface())
+	}
+}
+
+// renameDoc starts doc with the YAML name of what it documents, where Go
+// doc comments start with the Go name.
+func renameDoc(doc, goName, name string) string {
+	rest, ok := strings.CutPrefix(doc, goName)
+	if !ok || rest == "" || !strings.ContainsRune(" :,", rune(rest[0])) {
+		return doc
+	}
+	return name + rest
+}
+
+func yamlName(f reflect.StructField) string {
+	name, _, _ := strings.Cut(f.Tag.Get("mapstructure"), ",")
+	return name
+}
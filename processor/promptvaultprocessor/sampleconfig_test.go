@@ -0,0 +1,89 @@
+package promptvaultprocessor
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/go-viper/mapstructure/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// TestSampleConfigDecodes checks that each sample is a valid config that
+// decodes, with unknown keys rejected, to the defaults.
+func TestSampleConfigDecodes(t *testing.T) {
+	for _, backend := range SampleBackends() {
+		var buf bytes.Buffer
+		if err := WriteSampleConfig(&buf, backend); err != nil {
+			t.Fatal(err)
+		}
+		var raw struct {
+			Processors map[string]map[string]any `yaml:"processors"`
+		}
+		if err := yaml.Unmarshal(buf.Bytes(), &raw); err != nil {
+			t.Fatalf("%s: %v\n%s", backend, err, buf.String())
+		}
+		got := createDefaultConfig()
+		dec, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+			Result:      got,
+			ErrorUnused: true,
+			DecodeHook: mapstructure.ComposeDecodeHookFunc(
+				mapstructure.StringToTimeDurationHookFunc(),
+				mapstructure.TextUnmarshallerHookFunc(),
+			),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := dec.Decode(raw.Processors["promptvault"]); err != nil {
+			t.Fatalf("%s: %v", backend, err)
+		}
+		want := createDefaultConfig()
+		want.Storage.Backend = backend
+		// Formatted, empty lists and maps in the sample equal nil defaults.
+		if g, w := fmt.Sprintf("%+v", got), fmt.Sprintf("%+v", want); g != w {
+			t.Errorf("%s: sample decodes to\n%s\nwant\n%s", backend, g, w)
+		}
+		if backend != "filesystem" && backend != "tiered" && strings.Contains(buf.String(), "base_path:") {
+			t.Errorf("%s: sample includes filesystem settings", backend)
+		}
+	}
+	if err := WriteSampleConfig(new(bytes.Buffer), "s3"); err == nil {
+		t.Error("unknown backend accepted")
+	}
+}
+
+// TestSampleConfigDocumented keeps configSources in step with the config
+// structs: every struct type reachable from Config must be declared in one
+// of the embedded files.
+func TestSampleConfigDocumented(t *testing.T) {
+	docs := configDocs()
+	seen := make(map[reflect.Type]bool)
+	var walk func(reflect.Type)
+	walk = func(typ reflect.Type) {
+		switch typ.Kind() {
+		case reflect.Slice, reflect.Map, reflect.Pointer:
+			walk(typ.Elem())
+			return
+		case reflect.Struct:
+		default:
+			return
+		}
+		if seen[typ] || typ.PkgPath() != reflect.TypeOf(Config{}).PkgPath() {
+			return
+		}
+		seen[typ] = true
+		if _, ok := docs[typ.Name()]; !ok {
+			t.Errorf("%s is not declared in an embedded config source", typ.Name())
+		}
+		for i := 0; i < typ.NumField(); i++ {
+			walk(typ.Field(i).Type)
+		}
+	}
+	walk(reflect.TypeOf(Config{}))
+	if !strings.HasPrefix(docs["Config"], "Config for the prompt vault processor") {
+		t.Errorf("Config doc = %q", docs["Config"])
+	}
+}
@@ -0,0 +1,124 @@
+package promptvaultprocessor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"go.opentelemetry.io/collector/component"
+)
+
+// VaultProvider is implemented by extensions, such as promptvaultstorage,
+// that own a vault shared by several components.
+type VaultProvider interface {
+	Vault() VaultStorage
+}
+
+// VaultFromHost returns the vault owned by the extension with the given
+// component ID, e.g. "promptvaultstorage/shared". The extension starts and
+// closes it; Start and Close on the returned vault do nothing.
+func VaultFromHost(host component.Host, id string) (VaultStorage, error) {
+	var cid component.ID
+	if err := cid.UnmarshalText([]byte(id)); err != nil {
+		return nil, fmt.Errorf("storage.extension: %w", err)
+	}
+	ext, ok := host.GetExtensions()[cid]
+	if !ok {
+		return nil, fmt.Errorf("storage.extension: extension %q is not configured", id)
+	}
+	provider, ok := ext.(VaultProvider)
+	if !ok {
+		return nil, fmt.Errorf("storage.extension: extension %q does not provide a vault", id)
+	}
+	return &sharedVault{VaultStorage: provider.Vault()}, nil
+}
+
+// IgnoredWithExtension returns the storage and crypto settings that differ
+// from their defaults, e.g. "storage.backend" or "crypto". A component that
+// sets storage.extension ignores them, which callers report so that a vault
+// configured in the wrong place does not go unnoticed.
+func IgnoredWithExtension(storage StorageConfig, crypto CryptoConfig) []string {
+	def := createDefaultConfig()
+	var ignored []string
+	got, want := reflect.ValueOf(storage), reflect.ValueOf(def.Storage)
+	for i := 0; i < got.NumField(); i++ {
+		field := got.Type().Field(i)
+		if field.Name == "Extension" || reflect.DeepEqual(got.Field(i).Interface(), want.Field(i).Interface()) {
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("mapstructure"), ",")
+		ignored = append(ignored, "storage."+name)
+	}
+	if !reflect.DeepEqual(crypto, def.Crypto) {
+		ignored = append(ignored, "crypto")
+	}
+	return ignored
+}
+
+// sharedVault forwards to a vault owned by someone else, leaving out Start
+// and Close so that each user cannot stop it for the others.
+type sharedVault struct {
+	VaultStorage
+}
+
+func (v *sharedVault) RetrieveFor(ctx context.Context, ref string, meta ObjectMeta) ([]byte, error) {
+	return RetrieveFor(ctx, v.VaultStorage, ref, meta)
+}
+
+func (v *sharedVault) List(ctx context.Context, fn func(ObjectInfo) error) error {
+	if l, ok := v.VaultStorage.(VaultLister); ok {
+		return l.List(ctx, fn)
+	}
+	return fmt.Errorf("list: %w", errors.ErrUnsupported)
+}
+
+func (v *sharedVault) Stat(ctx context.Context, ref string) (ObjectInfo, error) {
+	if s, ok := v.VaultStorage.(VaultStatter); ok {
+		return s.Stat(ctx, ref)
+	}
+	return ObjectInfo{}, fmt.Errorf("stat: %w", errors.ErrUnsupported)
+}
+
+func (v *sharedVault) Search(ctx context.Context, f ObjectFilter, fn func(ObjectInfo) error) error {
+	if s, ok := v.VaultStorage.(VaultSearcher); ok {
+		return s.Search(ctx, f, fn)
+	}
+	return fmt.Errorf("search: %w", errors.ErrUnsupported)
+}
+
+func (v *sharedVault) Compact(ctx context.Context) (CompactionStats, error) {
+	if c, ok := v.VaultStorage.(VaultCompactor); ok {
+		return c.Compact(ctx)
+	}
+	return CompactionStats{}, fmt.Errorf("compact: %w", errors.ErrUnsupported)
+}
+
+func (v *sharedVault) Usage(ctx context.Context) ([]TenantUsage, error) {
+	if u, ok := v.VaultStorage.(VaultUsageReporter); ok {
+		return u.Usage(ctx)
+	}
+	return nil, fmt.Errorf("usage: %w", errors.ErrUnsupported)
+}
+
+func (v *sharedVault) Stats(ctx context.Context) (VaultStats, error) {
+	if r, ok := v.VaultStorage.(VaultStatsReporter); ok {
+		return r.Stats(ctx)
+	}
+	return VaultStats{}, fmt.Errorf("stats: %w", errors.ErrUnsupported)
+}
+
+func (v *sharedVault) Delete(ctx context.Context, ref string) error {
+	if d, ok := v.VaultStorage.(VaultDeleter); ok {
+		return d.Delete(ctx, ref)
+	}
+	return fmt.Errorf("delete: %w", errors.ErrUnsupported)
+}
+
+func (v *sharedVault) Replace(ctx context.Context, ref string, content []byte) error {
+	if r, ok := v.VaultStorage.(VaultReplacer); ok {
+		return r.Replace(ctx, ref, content)
+	}
+	return fmt.Errorf("replace: %w", errors.ErrUnsupported)
+}
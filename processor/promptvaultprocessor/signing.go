@@ -0,0 +1,59 @@
+package promptvaultprocessor
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"go.opentelemetry.io/collector/config/configopaque"
+)
+
+// minSigningKeyLen is the shortest HMAC-SHA256 key accepted.
+const minSigningKeyLen = 32
+
+// ErrInvalidRefSignature is returned when a reference's signature does not
+// match the reference and the content it resolves to.
+var ErrInvalidRefSignature = errors.New("invalid vault reference signature")
+
+// RefSigner signs vault references so that consumers can reject references
+// that were forged or rewritten after leaving the collector. The signature
+// covers the reference URI and the checksum and size of the original content,
+// so a valid reference pointing at swapped content also fails verification.
+type RefSigner struct {
+	key []byte
+}
+
+// NewRefSigner returns a signer for key, or nil if key is empty.
+func NewRefSigner(key configopaque.String) (*RefSigner, error) {
+	if key == "" {
+		return nil, nil
+	}
+	if len(key) < minSigningKeyLen {
+		return nil, fmt.Errorf("vault.signing_key must be at least %d bytes", minSigningKeyLen)
+	}
+	return &RefSigner{key: []byte(key)}, nil
+}
+
+// Sign returns the base64url HMAC-SHA256 of ref, checksum, and size.
+func (s *RefSigner) Sign(ref, checksum string, size int) string {
+	return base64.RawURLEncoding.EncodeToString(s.mac(ref, checksum, size))
+}
+
+// Verify checks sig against ref and the content it was retrieved as.
+func (s *RefSigner) Verify(ref, sig string, content []byte) error {
+	got, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil || !hmac.Equal(got, s.mac(ref, contentHash(content), len(content))) {
+		return ErrInvalidRefSignature
+	}
+	return nil
+}
+
+func (s *RefSigner) mac(ref, checksum string, size int) []byte {
+	m := hmac.New(sha256.New, s.key)
+	// Newline-separated: none of the fields can contain one.
+	m.Write([]byte(ref + "\n" + checksum + "\n" + strconv.Itoa(size)))
+	return m.Sum(nil)
+}
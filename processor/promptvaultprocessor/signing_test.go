@@ -0,0 +1,71 @@
+package promptvaultprocessor
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.opentelemetry.io/collector/config/configopaque"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.uber.org/zap"
+)
+
+const testSigningKey = configopaque.String("0123456789abcdef0123456789abcdef")
+
+func TestProcessorSignsReferences(t *testing.T) {
+	vault := NewMemoryVault(0, 0)
+	cfg := createDefaultConfig()
+	sink := new(consumertest.TracesSink)
+	proc := newVaultProcessor(zap.NewNop(), cfg, vault, sink)
+	proc.signer, _ = NewRefSigner(testSigningKey)
+
+	td := ptrace.NewTraces()
+	span := td.ResourceSpans().AppendEmpty().ScopeSpans().AppendEmpty().Spans().AppendEmpty()
+	span.Attributes().PutStr("gen_ai.prompt", "Tell me about quantum computing")
+	if err := proc.ConsumeTraces(context.Background(), td); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	attrs := sink.AllTraces()[0].ResourceSpans().At(0).ScopeSpans().At(0).Spans().At(0).Attributes()
+	ref, _ := attrs.Get("gen_ai.prompt.vault_ref")
+	sig, ok := attrs.Get("gen_ai.prompt.vault_sig")
+	if !ok {
+		t.Fatal("expected gen_ai.prompt.vault_sig to exist")
+	}
+
+	content, _ := vault.Retrieve(context.Background(), ref.Str())
+	verifier, _ := NewRefSigner(testSigningKey)
+	if err := verifier.Verify(ref.Str(), sig.Str(), content); err != nil {
+		t.Errorf("expected signature to verify: %v", err)
+	}
+}
+
+func TestRefSignerRejectsTampering(t *testing.T) {
+	signer, _ := NewRefSigner(testSigningKey)
+	content := []byte("original")
+	ref := refScheme + contentHash(content)
+	sig := signer.Sign(ref, contentHash(content), len(content))
+
+	cases := map[string]struct {
+		ref, sig string
+		content  []byte
+	}{
+		"other ref":     {refScheme + contentHash([]byte("x")), sig, content},
+		"other content": {ref, sig, []byte("swapped")},
+		"bad sig":       {ref, "not-a-signature", content},
+	}
+	for name, c := range cases {
+		if err := signer.Verify(c.ref, c.sig, c.content); !errors.Is(err, ErrInvalidRefSignature) {
+			t.Errorf("%s: expected ErrInvalidRefSignature, got %v", name, err)
+		}
+	}
+
+	other, _ := NewRefSigner("another-key-another-key-another-k")
+	if err := other.Verify(ref, sig, content); err == nil {
+		t.Error("expected signature from a different key to fail")
+	}
+	if _, err := NewRefSigner("short"); err == nil {
+		t.Error("expected error for short signing key")
+	}
+}
@@ -0,0 +1,156 @@
+package promptvaultprocessor
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// SQLiteVault stores content in an embedded SQLite database running in WAL mode.
+type SQLiteVault struct {
+	db *sql.DB
+}
+
+// NewSQLiteVault opens (or creates) the database at path and ensures the schema exists.
+func NewSQLiteVault(ctx context.Context, path string) (*SQLiteVault, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("create sqlite dir: %w", err)
+	}
+
+	db, err := openSQLite(path)
+	if err != nil {
+		return nil, fmt.Errorf("open sqlite: %w", err)
+	}
+
+	if err := migrateSQLiteVault(ctx, db); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &SQLiteVault{db: db}, nil
+}
+
+const sqliteVaultSchema = `
+	CREATE TABLE IF NOT EXISTS vault (
+		trace_id  TEXT    NOT NULL,
+		span_id   TEXT    NOT NULL,
+		attr_key  TEXT    NOT NULL,
+		version   INTEGER NOT NULL DEFAULT 1,
+		sha256    TEXT    NOT NULL,
+		size      INTEGER NOT NULL,
+		content   BLOB    NOT NULL,
+		stored_at INTEGER NOT NULL,
+		PRIMARY KEY (trace_id, span_id, attr_key, version)
+	);
+	CREATE INDEX IF NOT EXISTS vault_trace_id_idx ON vault (trace_id);
+	CREATE INDEX IF NOT EXISTS vault_stored_at_idx ON vault (stored_at);
+	CREATE INDEX IF NOT EXISTS vault_sha256_idx ON vault (sha256);`
+
+// migrateSQLiteVault creates the schema, rebuilding a table from before
+// object versioning so its rows become version 1.
+func migrateSQLiteVault(ctx context.Context, db *sql.DB) error {
+	var tables, versioned int
+	err := db.QueryRowContext(ctx, `
+		SELECT (SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'vault'),
+			(SELECT COUNT(*) FROM pragma_table_info('vault') WHERE name = 'version')`,
+	).Scan(&tables, &versioned)
+	if err != nil {
+		return fmt.Errorf("inspect sqlite schema: %w", err)
+	}
+	if tables == 0 || versioned == 1 {
+		if _, err := db.ExecContext(ctx, sqliteVaultSchema); err != nil {
+			return fmt.Errorf("create sqlite schema: %w", err)
+		}
+		return nil
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("migrate sqlite schema: %w", err)
+	}
+	defer tx.Rollback()
+	_, err = tx.ExecContext(ctx, `
+		ALTER TABLE vault RENAME TO vault_unversioned;
+		DROP INDEX IF EXISTS vault_trace_id_idx;
+		DROP INDEX IF EXISTS vault_stored_at_idx;
+		DROP INDEX IF EXISTS vault_sha256_idx;`+sqliteVaultSchema+`
+		INSERT INTO vault (trace_id, span_id, attr_key, sha256, size, content, stored_at)
+			SELECT trace_id, span_id, attr_key, sha256, size, content, stored_at FROM vault_unversioned;
+		DROP TABLE vault_unversioned;`)
+	if err != nil {
+		return fmt.Errorf("migrate sqlite schema: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("migrate sqlite schema: %w", err)
+	}
+	return nil
+}
+
+// Store records content as a new version of the span attribute and returns
+// a reference pinning that version. Storing the attribute's latest content
+// again, as SDK retries do, returns the existing reference.
+func (v *SQLiteVault) Store(ctx context.Context, meta ObjectMeta, content []byte) (string, error) {
+	hexHash := contentHash(content)
+
+	tx, err := v.db.BeginTx(ctx, nil)
+	if err != nil {
+		return "", fmt.Errorf("begin vault insert: %w", err)
+	}
+	defer tx.Rollback()
+
+	var latestHash string
+	var latest int
+	err = tx.QueryRowContext(ctx, `
+		SELECT sha256, version FROM vault
+		WHERE trace_id = ? AND span_id = ? AND attr_key = ?
+		ORDER BY version DESC LIMIT 1`,
+		meta.TraceID, meta.SpanID, meta.Key,
+	).Scan(&latestHash, &latest)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return "", fmt.Errorf("query vault version: %w", err)
+	}
+	if latest > 0 && latestHash == hexHash {
+		return versionedRef(hexHash, latest), nil
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO vault (trace_id, span_id, attr_key, version, sha256, size, content, stored_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		meta.TraceID, meta.SpanID, meta.Key, latest+1, hexHash, len(content), content, time.Now().UTC().UnixNano(),
+	)
+	if err != nil {
+		return "", fmt.Errorf("insert vault row: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return "", fmt.Errorf("insert vault row: %w", err)
+	}
+
+	return versionedRef(hexHash, latest+1), nil
+}
+
+// Retrieve reads content back from the vault by reference. A reference
+// without a version matches any version with the same content.
+func (v *SQLiteVault) Retrieve(ctx context.Context, ref string) ([]byte, error) {
+	var content []byte
+	query, args := `SELECT content FROM vault WHERE sha256 = ? LIMIT 1`, []any{hashFromRef(ref)}
+	if _, version := splitRefVersion(ref); version > 0 {
+		query, args = `SELECT content FROM vault WHERE sha256 = ? AND version = ? LIMIT 1`, append(args, version)
+	}
+	err := v.db.QueryRowContext(ctx, query, args...).Scan(&content)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: %s", ErrObjectNotFound, ref)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("query vault row: %w", err)
+	}
+	return content, nil
+}
+
+// Close closes the database.
+func (v *SQLiteVault) Close() error {
+	return v.db.Close()
+}
@@ -0,0 +1,20 @@
+//go:build cgo
+
+package promptvaultprocessor
+
+import (
+	"database/sql"
+
+	_ "github.com/mattn/go-sqlite3" // registers the "sqlite3" driver
+)
+
+// openSQLite opens the database at path in WAL mode. SQLite allows a single
+// writer, so the pool is limited to one connection.
+func openSQLite(path string) (*sql.DB, error) {
+	db, err := sql.Open("sqlite3", "file:"+path+"?_journal_mode=WAL&_synchronous=NORMAL&_busy_timeout=5000")
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(1)
+	return db, nil
+}
@@ -0,0 +1,17 @@
+//go:build !cgo
+
+package promptvaultprocessor
+
+import (
+	"database/sql"
+	"errors"
+)
+
+// errSQLiteNoCgo is returned by binaries built without cgo for everything
+// kept in SQLite: the sqlite backend, the search and content indexes, and
+// bundles.
+var errSQLiteNoCgo = errors.New("sqlite requires a cgo build")
+
+func openSQLite(string) (*sql.DB, error) {
+	return nil, errSQLiteNoCgo
+}
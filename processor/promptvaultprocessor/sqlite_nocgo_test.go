@@ -0,0 +1,17 @@
+//go:build !cgo
+
+package promptvaultprocessor
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestSQLiteRequiresCgo(t *testing.T) {
+	_, err := NewSQLiteVault(context.Background(), filepath.Join(t.TempDir(), "vault.db"))
+	if !errors.Is(err, errSQLiteNoCgo) {
+		t.Errorf("expected %v, got %v", errSQLiteNoCgo, err)
+	}
+}
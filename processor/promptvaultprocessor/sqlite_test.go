@@ -0,0 +1,158 @@
+//go:build cgo
+
+package promptvaultprocessor
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSQLiteStoreAndRetrieve(t *testing.T) {
+	ctx := context.Background()
+	vault, err := NewSQLiteVault(ctx, filepath.Join(t.TempDir(), "vault.db"))
+	if err != nil {
+		t.Fatalf("failed to create vault: %v", err)
+	}
+	defer vault.Close()
+
+	meta := ObjectMeta{TraceID: "trace1", SpanID: "span1", Key: "gen_ai.prompt"}
+	ref, err := vault.Store(ctx, meta, []byte("What is the capital of France?"))
+	if err != nil {
+		t.Fatalf("store failed: %v", err)
+	}
+	if !strings.HasPrefix(ref, "vault://") {
+		t.Errorf("expected vault:// prefix, got: %s", ref)
+	}
+
+	data, err := vault.Retrieve(ctx, ref)
+	if err != nil {
+		t.Fatalf("retrieve failed: %v", err)
+	}
+	if string(data) != "What is the capital of France?" {
+		t.Errorf("unexpected content: %q", string(data))
+	}
+
+	// Metadata columns are queryable directly.
+	var key string
+	var size int
+	err = vault.db.QueryRow(`SELECT attr_key, size FROM vault WHERE trace_id = ?`, "trace1").Scan(&key, &size)
+	if err != nil {
+		t.Fatalf("query metadata: %v", err)
+	}
+	if key != "gen_ai.prompt" || size != len("What is the capital of France?") {
+		t.Errorf("unexpected metadata: key=%s size=%d", key, size)
+	}
+}
+
+func TestSQLiteRetrieveMissing(t *testing.T) {
+	ctx := context.Background()
+	vault, err := NewSQLiteVault(ctx, filepath.Join(t.TempDir(), "vault.db"))
+	if err != nil {
+		t.Fatalf("failed to create vault: %v", err)
+	}
+	defer vault.Close()
+
+	if _, err := vault.Retrieve(ctx, "vault://deadbeef"); err == nil {
+		t.Error("expected error for missing ref")
+	}
+}
+
+func TestSQLiteVersionsRepeatedStores(t *testing.T) {
+	ctx := context.Background()
+	vault, err := NewSQLiteVault(ctx, filepath.Join(t.TempDir(), "vault.db"))
+	if err != nil {
+		t.Fatalf("failed to create vault: %v", err)
+	}
+	defer vault.Close()
+
+	meta := ObjectMeta{TraceID: "trace1", SpanID: "span1", Key: "gen_ai.prompt"}
+	first, _ := vault.Store(ctx, meta, []byte("first attempt"))
+	second, err := vault.Store(ctx, meta, []byte("second attempt"))
+	if err != nil {
+		t.Fatalf("store failed: %v", err)
+	}
+	if second != versionedRef(contentHash([]byte("second attempt")), 2) || !strings.HasSuffix(second, "@2") {
+		t.Errorf("second ref = %s, want version 2", second)
+	}
+	// A retry of the latest content keeps its reference.
+	if again, _ := vault.Store(ctx, meta, []byte("second attempt")); again != second {
+		t.Errorf("retried ref = %s, want %s", again, second)
+	}
+
+	for ref, want := range map[string]string{first: "first attempt", second: "second attempt"} {
+		data, err := vault.Retrieve(ctx, ref)
+		if err != nil || string(data) != want {
+			t.Errorf("retrieve %s = %q, %v; want %q", ref, data, err, want)
+		}
+	}
+	if _, err := vault.Retrieve(ctx, versionedRef(contentHash([]byte("first attempt")), 2)); !errors.Is(err, ErrObjectNotFound) {
+		t.Errorf("retrieve with the wrong version: %v", err)
+	}
+
+	var rows int
+	vault.db.QueryRow(`SELECT COUNT(*) FROM vault WHERE trace_id = ?`, "trace1").Scan(&rows)
+	if rows != 2 {
+		t.Errorf("%d rows, want 2", rows)
+	}
+}
+
+func TestSQLiteMigratesUnversionedTable(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "vault.db")
+	db, err := sql.Open("sqlite3", "file:"+path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Exec(`
+		CREATE TABLE vault (
+			trace_id  TEXT    NOT NULL,
+			span_id   TEXT    NOT NULL,
+			attr_key  TEXT    NOT NULL,
+			sha256    TEXT    NOT NULL,
+			size      INTEGER NOT NULL,
+			content   BLOB    NOT NULL,
+			stored_at INTEGER NOT NULL,
+			PRIMARY KEY (trace_id, span_id, attr_key)
+		);
+		CREATE INDEX vault_trace_id_idx ON vault (trace_id);
+		INSERT INTO vault VALUES ('trace1', 'span1', 'gen_ai.prompt', ?, 3, 'old', 0);`,
+		contentHash([]byte("old")))
+	db.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	vault, err := NewSQLiteVault(ctx, path)
+	if err != nil {
+		t.Fatalf("failed to open vault: %v", err)
+	}
+	defer vault.Close()
+	if data, err := vault.Retrieve(ctx, refScheme+contentHash([]byte("old"))); err != nil || string(data) != "old" {
+		t.Errorf("retrieve migrated row = %q, %v", data, err)
+	}
+	ref, err := vault.Store(ctx, ObjectMeta{TraceID: "trace1", SpanID: "span1", Key: "gen_ai.prompt"}, []byte("new"))
+	if err != nil || !strings.HasSuffix(ref, "@2") {
+		t.Errorf("store after migration = %s, %v; want version 2", ref, err)
+	}
+}
+
+func TestSplitRefVersion(t *testing.T) {
+	hash := contentHash([]byte("x"))
+	for ref, want := range map[string]int{
+		refScheme + hash:                      0,
+		refScheme + hash + "@3":               3,
+		refScheme + "acme@1/" + hash:          0,
+		refScheme + "kafka/prompt-vault/0/42": 0,
+	} {
+		if _, got := splitRefVersion(ref); got != want {
+			t.Errorf("splitRefVersion(%s) = %d, want %d", ref, got, want)
+		}
+		if want > 0 && hashFromRef(ref) != hash {
+			t.Errorf("hashFromRef(%s) = %s", ref, hashFromRef(ref))
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package promptvaultprocessor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// VaultStats aggregates what a vault holds. Bytes are as stored, after
+// compression and encryption.
+type VaultStats struct {
+	Objects int64 `json:"objects"`
+	Bytes   int64 `json:"bytes"`
+	// EncryptedObjects and EncryptedBytes count objects stored encrypted.
+	EncryptedObjects int64 `json:"encrypted_objects"`
+	EncryptedBytes   int64 `json:"encrypted_bytes"`
+	// Oldest is when the oldest object was stored; nil for an empty vault.
+	Oldest    *time.Time            `json:"oldest,omitempty"`
+	ByKey     map[string]StatsCount `json:"by_key"`
+	ByService map[string]StatsCount `json:"by_service"`
+	ByTenant  map[string]StatsCount `json:"by_tenant"`
+}
+
+// StatsCount is the objects and bytes of one key, service, or tenant.
+type StatsCount struct {
+	Objects int64 `json:"objects"`
+	Bytes   int64 `json:"bytes"`
+}
+
+// VaultStatsReporter is implemented by vaults that can aggregate their
+// contents without a full listing, such as an indexed vault.
+type VaultStatsReporter interface {
+	Stats(ctx context.Context) (VaultStats, error)
+}
+
+// Stats aggregates the contents of vault, using its index when it has one
+// and otherwise a full listing.
+func Stats(ctx context.Context, vault VaultStorage) (VaultStats, error) {
+	if r, ok := vault.(VaultStatsReporter); ok {
+		stats, err := r.Stats(ctx)
+		if !errors.Is(err, errors.ErrUnsupported) {
+			return stats, err
+		}
+	}
+	l, ok := vault.(VaultLister)
+	if !ok {
+		return VaultStats{}, fmt.Errorf("stats: %w", errors.ErrUnsupported)
+	}
+	stats := newVaultStats()
+	err := l.List(ctx, func(info ObjectInfo) error {
+		stats.add(info.Meta, 1, info.Size, info.StoredAt)
+		return nil
+	})
+	return stats, err
+}
+
+func newVaultStats() VaultStats {
+	return VaultStats{
+		ByKey:     make(map[string]StatsCount),
+		ByService: make(map[string]StatsCount),
+		ByTenant:  make(map[string]StatsCount),
+	}
+}
+
+// add counts objects stored with meta, the oldest of them at oldest.
+func (s *VaultStats) add(meta ObjectMeta, objects, bytes int64, oldest time.Time) {
+	s.Objects += objects
+	s.Bytes += bytes
+	if hasTransform(meta.Transforms, transformEncrypt) {
+		s.EncryptedObjects += objects
+		s.EncryptedBytes += bytes
+	}
+	if s.Oldest == nil || oldest.Before(*s.Oldest) {
+		t := oldest.UTC()
+		s.Oldest = &t
+	}
+	addCount(s.ByKey, meta.Key, objects, bytes)
+	addCount(s.ByService, meta.Service, objects, bytes)
+	addCount(s.ByTenant, meta.Tenant, objects, bytes)
+}
+
+func addCount(counts map[string]StatsCount, name string, objects, bytes int64) {
+	c := counts[name]
+	c.Objects += objects
+	c.Bytes += bytes
+	counts[name] = c
+}
+
+// diskUsageDimensions are the groupings DiskUsage accepts, each naming an
+// object's group.
+var diskUsageDimensions = map[string]func(ObjectInfo) string{
+	"trace":   func(info ObjectInfo) string { return info.Meta.TraceID },
+	"key":     func(info ObjectInfo) string { return info.Meta.Key },
+	"service": func(info ObjectInfo) string { return info.Meta.Service },
+	"tenant":  func(info ObjectInfo) string { return info.Meta.Tenant },
+	"day":     func(info ObjectInfo) string { return info.StoredAt.UTC().Format(time.DateOnly) },
+}
+
+// DiskUsageRow is the objects and bytes of one group.
+type DiskUsageRow struct {
+	// Group holds the group's value of each dimension, in the order given.
+	Group   []string `json:"group"`
+	Objects int64    `json:"objects"`
+	Bytes   int64    `json:"bytes"`
+}
+
+// DiskUsage aggregates the objects matching f by the dimensions in by,
+// each "trace", "key", "service", "tenant", or "day" (UTC), largest group
+// first. An object stored once for several spans counts once per group
+// those spans fall in. Bytes are as stored.
+func DiskUsage(ctx context.Context, vault VaultStorage, f ObjectFilter, by ...string) ([]DiskUsageRow, error) {
+	dims := make([]func(ObjectInfo) string, len(by))
+	for i, name := range by {
+		dim, ok := diskUsageDimensions[name]
+		if !ok {
+			return nil, fmt.Errorf("disk usage: unknown dimension %q", name)
+		}
+		dims[i] = dim
+	}
+	rows := make(map[string]*DiskUsageRow)
+	seen := make(map[string]bool)
+	err := SearchObjects(ctx, vault, f, func(info ObjectInfo) error {
+		group := make([]string, len(dims))
+		for i, dim := range dims {
+			group[i] = dim(info)
+		}
+		id := strings.Join(group, "\x00")
+		if seen[id+"\x00"+info.Ref] {
+			return nil
+		}
+		seen[id+"\x00"+info.Ref] = true
+		row, ok := rows[id]
+		if !ok {
+			row = &DiskUsageRow{Group: group}
+			rows[id] = row
+		}
+		row.Objects++
+		row.Bytes += info.Size
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	out := make([]DiskUsageRow, 0, len(rows))
+	for _, row := range rows {
+		out = append(out, *row)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Bytes != out[j].Bytes {
+			return out[i].Bytes > out[j].Bytes
+		}
+		return slices.Compare(out[i].Group, out[j].Group) < 0
+	})
+	return out, nil
+}
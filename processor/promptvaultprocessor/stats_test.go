@@ -0,0 +1,86 @@
+package promptvaultprocessor
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestStats(t *testing.T) {
+	ctx := context.Background()
+	store := func(v VaultStorage) {
+		v.Store(ctx, ObjectMeta{TraceID: "t1", SpanID: "s1", Key: "gen_ai.prompt", Service: "chat", Tenant: "acme", Transforms: "zstd,encrypt"}, []byte("aaaa"))
+		v.Store(ctx, ObjectMeta{TraceID: "t1", SpanID: "s2", Key: "gen_ai.completion", Service: "chat", Tenant: "acme"}, []byte("bb"))
+		v.Store(ctx, ObjectMeta{TraceID: "t2", SpanID: "s3", Key: "gen_ai.prompt", Service: "search", Tenant: "globex", Transforms: "encrypt"}, []byte("c"))
+	}
+	want := VaultStats{
+		Objects:          3,
+		Bytes:            7,
+		EncryptedObjects: 2,
+		EncryptedBytes:   5,
+		ByKey:            map[string]StatsCount{"gen_ai.prompt": {2, 5}, "gen_ai.completion": {1, 2}},
+		ByService:        map[string]StatsCount{"chat": {2, 6}, "search": {1, 1}},
+		ByTenant:         map[string]StatsCount{"acme": {2, 6}, "globex": {1, 1}},
+	}
+
+	indexed := newTestIndexVault(t, NewMemoryVault(0, 0))
+	store(indexed)
+	// The same content stored for another span is counted once.
+	indexed.Store(ctx, ObjectMeta{TraceID: "t3", SpanID: "s4", Key: "gen_ai.prompt", Service: "chat", Tenant: "acme", Transforms: "zstd,encrypt"}, []byte("aaaa"))
+	got, err := indexed.Stats(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Oldest == nil {
+		t.Error("oldest is unset")
+	}
+	got.Oldest = nil
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("index stats = %+v, want %+v", got, want)
+	}
+
+	// Without an index, the listing is aggregated.
+	memory := NewMemoryVault(0, 0)
+	store(memory)
+	if got, err = Stats(ctx, memory); err != nil {
+		t.Fatal(err)
+	}
+	got.Oldest = nil
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("listed stats = %+v, want %+v", got, want)
+	}
+}
+
+func TestDiskUsage(t *testing.T) {
+	ctx := context.Background()
+	indexed := newTestIndexVault(t, NewMemoryVault(0, 0))
+	indexed.Store(ctx, ObjectMeta{TraceID: "t1", SpanID: "s1", Key: "gen_ai.prompt", Service: "chat"}, []byte("aaaa"))
+	indexed.Store(ctx, ObjectMeta{TraceID: "t1", SpanID: "s2", Key: "gen_ai.completion", Service: "chat"}, []byte("bb"))
+	indexed.Store(ctx, ObjectMeta{TraceID: "t2", SpanID: "s3", Key: "gen_ai.prompt", Service: "search"}, []byte("c"))
+	// The same content for another span of t1 is counted once for t1.
+	indexed.Store(ctx, ObjectMeta{TraceID: "t1", SpanID: "s4", Key: "gen_ai.prompt", Service: "chat"}, []byte("aaaa"))
+
+	rows, err := DiskUsage(ctx, indexed, ObjectFilter{}, "trace")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []DiskUsageRow{{Group: []string{"t1"}, Objects: 2, Bytes: 6}, {Group: []string{"t2"}, Objects: 1, Bytes: 1}}
+	if !reflect.DeepEqual(rows, want) {
+		t.Errorf("by trace = %+v, want %+v", rows, want)
+	}
+
+	today := time.Now().UTC().Format(time.DateOnly)
+	rows, err = DiskUsage(ctx, indexed, ObjectFilter{Key: "gen_ai.prompt"}, "service", "day")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = []DiskUsageRow{{Group: []string{"chat", today}, Objects: 1, Bytes: 4}, {Group: []string{"search", today}, Objects: 1, Bytes: 1}}
+	if !reflect.DeepEqual(rows, want) {
+		t.Errorf("by service and day = %+v, want %+v", rows, want)
+	}
+
+	if _, err := DiskUsage(ctx, indexed, ObjectFilter{}, "bucket"); err == nil {
+		t.Error("expected an unknown dimension to be rejected")
+	}
+}
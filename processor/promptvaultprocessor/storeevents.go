@@ -0,0 +1,90 @@
+package promptvaultprocessor
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.opentelemetry.io/otel/exporters/otlp/otlplog/otlploghttp"
+	otellog "go.opentelemetry.io/otel/log"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+)
+
+// storeEvents emits an OTLP log record for each vault store, as an audit
+// trail of what was offloaded. Records are batched off the store path, so a
+// slow or unreachable logs endpoint never delays or fails stores.
+type storeEvents struct {
+	provider  *sdklog.LoggerProvider
+	logger    otellog.Logger
+	backend   string
+	encrypted bool
+}
+
+func newStoreEvents(ctx context.Context, cfg *Config) (*storeEvents, error) {
+	if cfg.StoreEvents.Endpoint == "" {
+		return nil, nil
+	}
+	headers := make(map[string]string, len(cfg.StoreEvents.Headers))
+	for k, v := range cfg.StoreEvents.Headers {
+		headers[k] = string(v)
+	}
+	exporter, err := otlploghttp.New(ctx,
+		otlploghttp.WithEndpointURL(cfg.StoreEvents.Endpoint),
+		otlploghttp.WithHeaders(headers),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("create store_events exporter: %w", err)
+	}
+	backend := cfg.Storage.Backend
+	if cfg.Storage.Extension != "" {
+		backend = cfg.Storage.Extension
+	}
+	provider := sdklog.NewLoggerProvider(sdklog.WithProcessor(sdklog.NewBatchProcessor(exporter)))
+	return &storeEvents{
+		provider:  provider,
+		logger:    provider.Logger(scopeName),
+		backend:   backend,
+		encrypted: cfg.Crypto.Provider != "",
+	}, nil
+}
+
+// record emits the event for storing content for meta at ref, which took
+// latency and failed with err if non-nil.
+func (e *storeEvents) record(ctx context.Context, meta ObjectMeta, ref string, content []byte, latency time.Duration, err error) {
+	var r otellog.Record
+	r.SetTimestamp(time.Now())
+	r.SetSeverity(otellog.SeverityInfo)
+	r.SetBody(otellog.StringValue("vault store"))
+	r.AddAttributes(
+		otellog.String("vault.key", meta.Key),
+		otellog.String("vault.trace_id", meta.TraceID),
+		otellog.String("vault.span_id", meta.SpanID),
+		otellog.String("vault.service", meta.Service),
+		otellog.Int("vault.size", len(content)),
+		otellog.String("vault.checksum", contentHash(content)),
+		otellog.String("vault.backend", e.backend),
+		otellog.Bool("vault.encrypted", e.encrypted),
+		otellog.Float64("vault.latency_ms", float64(latency)/float64(time.Millisecond)),
+	)
+	if meta.Tenant != "" {
+		r.AddAttributes(otellog.String("vault.tenant", meta.Tenant))
+	}
+	if err != nil {
+		r.SetSeverity(otellog.SeverityWarn)
+		r.AddAttributes(
+			otellog.String("vault.outcome", "error"),
+			otellog.String("vault.error", err.Error()),
+		)
+	} else {
+		r.AddAttributes(
+			otellog.String("vault.outcome", "ok"),
+			otellog.String("vault.ref", ref),
+		)
+	}
+	e.logger.Emit(ctx, r)
+}
+
+// Close flushes pending events.
+func (e *storeEvents) Close(ctx context.Context) error {
+	return e.provider.Shutdown(ctx)
+}
@@ -0,0 +1,91 @@
+package promptvaultprocessor
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/plog/plogotlp"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.uber.org/zap"
+)
+
+func TestProcessorEmitsStoreEvents(t *testing.T) {
+	var mu sync.Mutex
+	var records []plog.LogRecord
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		req := plogotlp.NewExportRequest()
+		if err := req.UnmarshalProto(body); err != nil {
+			t.Error(err)
+		}
+		mu.Lock()
+		rls := req.Logs().ResourceLogs()
+		for i := 0; i < rls.Len(); i++ {
+			sls := rls.At(i).ScopeLogs()
+			for j := 0; j < sls.Len(); j++ {
+				lrs := sls.At(j).LogRecords()
+				for k := 0; k < lrs.Len(); k++ {
+					records = append(records, lrs.At(k))
+				}
+			}
+		}
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/x-protobuf")
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	cfg := createDefaultConfig()
+	cfg.Storage.Backend = "memory"
+	cfg.StoreEvents.Endpoint = srv.URL + "/v1/logs"
+	events, err := newStoreEvents(ctx, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	proc := newVaultProcessor(zap.NewNop(), cfg, NewMemoryVault(0, 0), new(consumertest.TracesSink))
+	proc.storeEvents = events
+
+	td := ptrace.NewTraces()
+	span := td.ResourceSpans().AppendEmpty().ScopeSpans().AppendEmpty().Spans().AppendEmpty()
+	span.Attributes().PutStr("gen_ai.prompt", "Tell me about quantum computing")
+	if err := proc.ConsumeTraces(ctx, td); err != nil {
+		t.Fatal(err)
+	}
+	// Shutdown flushes the batched events.
+	if err := proc.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(records) != 1 {
+		t.Fatalf("received %d store events, want 1", len(records))
+	}
+	attrs := records[0].Attributes()
+	want := map[string]any{
+		"vault.key":       "gen_ai.prompt",
+		"vault.size":      int64(len("Tell me about quantum computing")),
+		"vault.checksum":  contentHash([]byte("Tell me about quantum computing")),
+		"vault.backend":   "memory",
+		"vault.encrypted": false,
+		"vault.outcome":   "ok",
+	}
+	for k, v := range want {
+		got, ok := attrs.Get(k)
+		if !ok || got.AsRaw() != v {
+			t.Errorf("%s = %v, want %v", k, got.AsRaw(), v)
+		}
+	}
+	if ref, _ := attrs.Get("vault.ref"); ref.Str() == "" {
+		t.Error("store event has no vault.ref")
+	}
+	if latency, ok := attrs.Get("vault.latency_ms"); !ok || latency.Double() < 0 {
+		t.Errorf("vault.latency_ms = %v", latency.AsRaw())
+	}
+}
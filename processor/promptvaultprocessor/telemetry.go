@@ -0,0 +1,140 @@
+package promptvaultprocessor
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/noop"
+)
+
+const scopeName = "github.com/airblackbox/otel-prompt-vault/processor/promptvaultprocessor"
+
+// vaultMetrics holds the instruments the processor and its backends report to.
+type vaultMetrics struct {
+	meter            metric.Meter
+	evictedObjects   metric.Int64Counter
+	evictedBytes     metric.Int64Counter
+	redactions       metric.Int64Counter
+	matchedValues    metric.Int64Counter
+	retentionObjects metric.Int64Counter
+	retentionBytes   metric.Int64Counter
+}
+
+// Outcomes of a matched attribute value, recorded on promptvault_matched_values.
+const (
+	outcomeVaulted         = "vaulted"
+	outcomeBelowThreshold  = "below_threshold"
+	outcomeNoDetections    = "no_detections"
+	outcomeStoreFailed     = "store_failed"
+	outcomeTransformFailed = "transform_failed"
+	outcomeQuotaExceeded   = "quota_exceeded"
+	// Values a policy kept, dropped, or redacted are recorded as
+	// "policy_" plus the decision.
+	outcomePolicyPrefix = "policy_"
+)
+
+func newVaultMetrics(mp metric.MeterProvider) (*vaultMetrics, error) {
+	if mp == nil {
+		mp = noop.NewMeterProvider()
+	}
+	meter := mp.Meter(scopeName)
+
+	evictedObjects, err := meter.Int64Counter(
+		"promptvault_evicted_objects",
+		metric.WithDescription("Objects evicted from the vault by quota or TTL."),
+		metric.WithUnit("{objects}"),
+	)
+	if err != nil {
+		return nil, err
+	}
+	evictedBytes, err := meter.Int64Counter(
+		"promptvault_evicted_bytes",
+		metric.WithDescription("Bytes reclaimed by vault eviction."),
+		metric.WithUnit("By"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	redactions, err := meter.Int64Counter(
+		"promptvault_redactions",
+		metric.WithDescription("Sensitive substrings masked before storage, by detector."),
+		metric.WithUnit("{matches}"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	matchedValues, err := meter.Int64Counter(
+		"promptvault_matched_values",
+		metric.WithDescription("Attribute values matching vault.keys, by outcome."),
+		metric.WithUnit("{values}"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	retentionObjects, err := meter.Int64Counter(
+		"promptvault_retention_deleted_objects",
+		metric.WithDescription("Objects deleted by retention policies, by policy."),
+		metric.WithUnit("{objects}"),
+	)
+	if err != nil {
+		return nil, err
+	}
+	retentionBytes, err := meter.Int64Counter(
+		"promptvault_retention_deleted_bytes",
+		metric.WithDescription("Stored bytes reclaimed by retention policies, by policy."),
+		metric.WithUnit("By"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &vaultMetrics{
+		meter:            meter,
+		evictedObjects:   evictedObjects,
+		evictedBytes:     evictedBytes,
+		redactions:       redactions,
+		matchedValues:    matchedValues,
+		retentionObjects: retentionObjects,
+		retentionBytes:   retentionBytes,
+	}, nil
+}
+
+func (m *vaultMetrics) recordEviction(ctx context.Context, objects int, bytes int64) {
+	if m == nil || objects == 0 {
+		return
+	}
+	m.evictedObjects.Add(ctx, int64(objects))
+	m.evictedBytes.Add(ctx, bytes)
+}
+
+func (m *vaultMetrics) recordRedactions(ctx context.Context, counts map[string]int) {
+	if m == nil {
+		return
+	}
+	for detector, n := range counts {
+		m.redactions.Add(ctx, int64(n), metric.WithAttributes(attribute.String("detector", detector)))
+	}
+}
+
+func (m *vaultMetrics) recordMatched(ctx context.Context, key, outcome string) {
+	if m == nil {
+		return
+	}
+	m.matchedValues.Add(ctx, 1, metric.WithAttributes(
+		attribute.String("key", key),
+		attribute.String("outcome", outcome),
+	))
+}
+
+func (m *vaultMetrics) recordRetention(ctx context.Context, policy string, objects int, bytes int64) {
+	if m == nil || objects == 0 {
+		return
+	}
+	attrs := metric.WithAttributes(attribute.String("policy", policy))
+	m.retentionObjects.Add(ctx, int64(objects), attrs)
+	m.retentionBytes.Add(ctx, bytes, attrs)
+}
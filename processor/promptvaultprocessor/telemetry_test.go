@@ -0,0 +1,52 @@
+package promptvaultprocessor
+
+import (
+	"context"
+	"testing"
+
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.uber.org/zap"
+)
+
+func TestVaultRecordsMatchedValues(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	metrics, err := newVaultMetrics(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := createDefaultConfig()
+	cfg.Vault.SizeThreshold = 8
+	proc := newVaultProcessor(zap.NewNop(), cfg, NewMemoryVault(0, 0), new(consumertest.TracesSink))
+	proc.metrics = metrics
+
+	td := ptrace.NewTraces()
+	span := td.ResourceSpans().AppendEmpty().ScopeSpans().AppendEmpty().Spans().AppendEmpty()
+	span.Attributes().PutStr("gen_ai.prompt", "Summarize the quarterly report")
+	span.Attributes().PutStr("gen_ai.completion", "short")
+	if err := proc.ConsumeTraces(context.Background(), td); err != nil {
+		t.Fatal(err)
+	}
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]metricdata.Aggregation)
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			got[m.Name] = m.Data
+		}
+	}
+
+	outcomes := make(map[string]int64)
+	for _, dp := range got["promptvault_matched_values"].(metricdata.Sum[int64]).DataPoints {
+		v, _ := dp.Attributes.Value("outcome")
+		outcomes[v.AsString()] += dp.Value
+	}
+	if outcomes[outcomeVaulted] != 1 || outcomes[outcomeBelowThreshold] != 1 {
+		t.Errorf("matched values = %v", outcomes)
+	}
+}
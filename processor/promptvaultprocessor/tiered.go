@@ -0,0 +1,190 @@
+package promptvaultprocessor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// hotTier is what a backend needs to serve as the hot tier: objects must be
+// enumerable by age and removable once migrated.
+type hotTier interface {
+	VaultStorage
+	VaultLister
+	VaultDeleter
+}
+
+// TieredVault writes new objects to a fast hot backend and migrates them to
+// a cold backend once they are older than migrateAfter. References are
+// content-addressed, so the same reference resolves in either tier and
+// Retrieve simply checks hot first, then cold.
+type TieredVault struct {
+	logger       *zap.Logger
+	hot          hotTier
+	cold         VaultStorage
+	migrateAfter time.Duration
+	interval     time.Duration
+
+	stop chan struct{}
+	done sync.WaitGroup
+}
+
+// NewTieredVault composes hot and cold backends. hot must support listing and deletion.
+func NewTieredVault(logger *zap.Logger, hot, cold VaultStorage, cfg TieredConfig) (*TieredVault, error) {
+	h, ok := hot.(hotTier)
+	if !ok {
+		return nil, fmt.Errorf("backend %q cannot be used as the hot tier", cfg.Hot)
+	}
+	if cfg.MigrateInterval <= 0 {
+		cfg.MigrateInterval = 10 * time.Minute
+	}
+	return &TieredVault{
+		logger:       logger,
+		hot:          h,
+		cold:         cold,
+		migrateAfter: cfg.MigrateAfter,
+		interval:     cfg.MigrateInterval,
+		stop:         make(chan struct{}),
+	}, nil
+}
+
+// Start launches the background migration loop and any background work of the tiers.
+func (v *TieredVault) Start(ctx context.Context) error {
+	for _, tier := range []VaultStorage{v.hot, v.cold} {
+		if s, ok := tier.(vaultStarter); ok {
+			if err := s.Start(ctx); err != nil {
+				return err
+			}
+		}
+	}
+
+	v.done.Add(1)
+	go v.migrateLoop()
+	return nil
+}
+
+// Store writes content to the hot tier.
+func (v *TieredVault) Store(ctx context.Context, meta ObjectMeta, content []byte) (string, error) {
+	return v.hot.Store(ctx, meta, content)
+}
+
+// Retrieve checks the hot tier, then the cold tier.
+func (v *TieredVault) Retrieve(ctx context.Context, ref string) ([]byte, error) {
+	data, hotErr := v.hot.Retrieve(ctx, ref)
+	if hotErr == nil {
+		return data, nil
+	}
+	data, coldErr := v.cold.Retrieve(ctx, ref)
+	if coldErr == nil {
+		return data, nil
+	}
+	return nil, errors.Join(hotErr, coldErr)
+}
+
+// Stat describes ref from whichever tier holds it.
+func (v *TieredVault) Stat(ctx context.Context, ref string) (ObjectInfo, error) {
+	var errs []error
+	for _, tier := range []VaultStorage{v.hot, v.cold} {
+		s, ok := tier.(VaultStatter)
+		if !ok {
+			errs = append(errs, fmt.Errorf("stat: %w", errors.ErrUnsupported))
+			continue
+		}
+		info, err := s.Stat(ctx, ref)
+		if err == nil {
+			return info, nil
+		}
+		errs = append(errs, err)
+	}
+	return ObjectInfo{}, errors.Join(errs...)
+}
+
+// Migrate moves every hot object older than migrateAfter to the cold tier.
+func (v *TieredVault) Migrate(ctx context.Context) error {
+	cutoff := time.Now().Add(-v.migrateAfter)
+
+	var due []ObjectInfo
+	err := v.hot.List(ctx, func(info ObjectInfo) error {
+		if info.StoredAt.Before(cutoff) {
+			due = append(due, info)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("list hot tier: %w", err)
+	}
+
+	for _, info := range due {
+		if err := v.migrateOne(ctx, info); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (v *TieredVault) migrateOne(ctx context.Context, info ObjectInfo) error {
+	content, err := v.hot.Retrieve(ctx, info.Ref)
+	if err != nil {
+		return fmt.Errorf("read %s from hot tier: %w", info.Ref, err)
+	}
+
+	meta := info.Meta
+	if meta == (ObjectMeta{}) {
+		// Hot backends that don't retain span metadata still need a unique
+		// row key in keyed cold stores.
+		meta.Key = hashFromRef(info.Ref)
+	}
+	ref, err := v.cold.Store(ctx, meta, content)
+	if err != nil {
+		return fmt.Errorf("write %s to cold tier: %w", info.Ref, err)
+	}
+	if hashFromRef(ref) != hashFromRef(info.Ref) {
+		return fmt.Errorf("cold tier returned %s for %s; it must be content-addressed", ref, info.Ref)
+	}
+
+	if err := v.hot.Delete(ctx, info.Ref); err != nil {
+		return fmt.Errorf("delete %s from hot tier: %w", info.Ref, err)
+	}
+	return nil
+}
+
+func (v *TieredVault) migrateLoop() {
+	defer v.done.Done()
+
+	ticker := time.NewTicker(v.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-v.stop:
+			return
+		case <-ticker.C:
+			if err := v.Migrate(context.Background()); err != nil {
+				v.logger.Warn("vault tier migration failed", zap.Error(err))
+			}
+		}
+	}
+}
+
+// Close stops migration and closes both tiers.
+func (v *TieredVault) Close() error {
+	select {
+	case <-v.stop:
+	default:
+		close(v.stop)
+	}
+	v.done.Wait()
+
+	var errs []error
+	for _, tier := range []VaultStorage{v.hot, v.cold} {
+		if c, ok := tier.(io.Closer); ok {
+			errs = append(errs, c.Close())
+		}
+	}
+	return errors.Join(errs...)
+}
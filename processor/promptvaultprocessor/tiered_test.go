@@ -0,0 +1,54 @@
+package promptvaultprocessor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestTieredVaultMigratesToCold(t *testing.T) {
+	ctx := context.Background()
+	hot := NewMemoryVault(0, 0)
+	cold := NewMemoryVault(0, 0)
+	vault, err := NewTieredVault(zap.NewNop(), hot, cold, TieredConfig{MigrateAfter: time.Hour})
+	if err != nil {
+		t.Fatalf("failed to create vault: %v", err)
+	}
+
+	hot.now = func() time.Time { return time.Now().Add(-2 * time.Hour) }
+	oldRef, _ := vault.Store(ctx, ObjectMeta{}, []byte("old prompt"))
+	hot.now = time.Now
+	newRef, _ := vault.Store(ctx, ObjectMeta{}, []byte("new prompt"))
+
+	if err := vault.Migrate(ctx); err != nil {
+		t.Fatalf("migrate failed: %v", err)
+	}
+
+	if _, err := hot.Retrieve(ctx, oldRef); err == nil {
+		t.Error("expected old object to leave the hot tier")
+	}
+	if _, err := cold.Retrieve(ctx, oldRef); err != nil {
+		t.Errorf("expected old object in the cold tier: %v", err)
+	}
+	if _, err := hot.Retrieve(ctx, newRef); err != nil {
+		t.Errorf("expected new object to stay hot: %v", err)
+	}
+
+	// The same reference resolves regardless of tier.
+	data, err := vault.Retrieve(ctx, oldRef)
+	if err != nil {
+		t.Fatalf("retrieve failed: %v", err)
+	}
+	if string(data) != "old prompt" {
+		t.Errorf("expected %q, got %q", "old prompt", string(data))
+	}
+}
+
+func TestTieredVaultRejectsUnlistableHot(t *testing.T) {
+	hot, _ := NewHTTPVault(HTTPConfig{URLTemplate: "http://localhost/{hash}"})
+	if _, err := NewTieredVault(zap.NewNop(), hot, NewMemoryVault(0, 0), TieredConfig{Hot: "http"}); err == nil {
+		t.Error("expected error for hot tier without list/delete support")
+	}
+}
@@ -0,0 +1,77 @@
+package promptvaultprocessor
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// tokenizer replaces detected substrings with stable tokens such as
+// <EMAIL_7f3a9c01>. Tokens are keyed so that guessing a value does not
+// confirm which token it maps to.
+type tokenizer struct {
+	scanner *piiScanner
+	key     []byte
+}
+
+func newTokenizer(cfg TokenizationConfig) (*tokenizer, error) {
+	if len(cfg.Key) < minSigningKeyLen {
+		return nil, fmt.Errorf("vault.tokenization.key must be at least %d bytes", minSigningKeyLen)
+	}
+	scanner, err := newPIIScanner(cfg.RedactionConfig)
+	if err != nil {
+		return nil, fmt.Errorf("vault.tokenization: %w", err)
+	}
+	if scanner == nil {
+		return nil, fmt.Errorf("vault.tokenization enables no detectors or patterns")
+	}
+	return &tokenizer{scanner: scanner, key: []byte(cfg.Key)}, nil
+}
+
+// tokenize returns text with each match replaced by its token, and the
+// token-to-value mapping needed to reverse it.
+func (t *tokenizer) tokenize(text string) (string, map[string]string) {
+	matches := t.scanner.scan(text)
+	if len(matches) == 0 {
+		return text, nil
+	}
+	mapping := make(map[string]string, len(matches))
+	var b strings.Builder
+	prev := 0
+	for _, m := range matches {
+		value := text[m.Start:m.End]
+		tok := t.token(m.Detector, value)
+		mapping[tok] = value
+		b.WriteString(text[prev:m.Start])
+		b.WriteString(tok)
+		prev = m.End
+	}
+	b.WriteString(text[prev:])
+	return b.String(), mapping
+}
+
+func (t *tokenizer) token(detector, value string) string {
+	mac := hmacSHA256(t.key, []byte(detector), []byte{0}, []byte(value))
+	return "<" + strings.ToUpper(detector) + "_" + hex.EncodeToString(mac[:4]) + ">"
+}
+
+// Detokenize restores the values in text that were tokenized when the span
+// was processed, using the mapping stored at ref (the attribute's
+// .vault_ref).
+func Detokenize(ctx context.Context, vault VaultStorage, text, ref string) (string, error) {
+	raw, err := vault.Retrieve(ctx, ref)
+	if err != nil {
+		return "", err
+	}
+	var mapping map[string]string
+	if err := json.Unmarshal(raw, &mapping); err != nil {
+		return "", fmt.Errorf("%s is not a token mapping: %w", ref, err)
+	}
+	pairs := make([]string, 0, 2*len(mapping))
+	for tok, value := range mapping {
+		pairs = append(pairs, tok, value)
+	}
+	return strings.NewReplacer(pairs...).Replace(text), nil
+}
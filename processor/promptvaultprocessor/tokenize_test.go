@@ -0,0 +1,99 @@
+package promptvaultprocessor
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	"go.opentelemetry.io/collector/config/configopaque"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.uber.org/zap"
+)
+
+var testTokenKey = configopaque.String("0123456789abcdef0123456789abcdef")
+
+func TestTokenizeIsStableAndKeyed(t *testing.T) {
+	cfg := TokenizationConfig{
+		RedactionConfig: RedactionConfig{Detectors: RedactionDetectors{Email: true}},
+		Key:             testTokenKey,
+	}
+	tok, err := newTokenizer(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a, mapping := tok.tokenize("ask dan@example.com, cc dan@example.com")
+	if !regexp.MustCompile(`^ask <EMAIL_[0-9a-f]{8}>, cc <EMAIL_[0-9a-f]{8}>$`).MatchString(a) {
+		t.Fatalf("tokenized = %q", a)
+	}
+	if len(mapping) != 1 {
+		t.Errorf("mapping = %v, want one entry for the repeated value", mapping)
+	}
+	if b, _ := tok.tokenize("ask dan@example.com, cc dan@example.com"); b != a {
+		t.Errorf("tokens not stable: %q vs %q", a, b)
+	}
+
+	cfg.Key = configopaque.String("fedcba9876543210fedcba9876543210")
+	other, _ := newTokenizer(cfg)
+	if b, _ := other.tokenize("ask dan@example.com, cc dan@example.com"); b == a {
+		t.Error("tokens do not depend on the key")
+	}
+}
+
+func TestNewTokenizerValidates(t *testing.T) {
+	if _, err := newTokenizer(TokenizationConfig{
+		RedactionConfig: RedactionConfig{Detectors: RedactionDetectors{Email: true}},
+		Key:             "short",
+	}); err == nil {
+		t.Error("expected error for short key")
+	}
+	if _, err := newTokenizer(TokenizationConfig{Key: testTokenKey}); err == nil {
+		t.Error("expected error when no detectors are enabled")
+	}
+}
+
+func TestProcessorTokenizeRoundTrip(t *testing.T) {
+	vault := NewMemoryVault(0, 0)
+	cfg := createDefaultConfig()
+	cfg.Vault.Mode = "tokenize"
+	cfg.Vault.SizeThreshold = 0
+	sink := new(consumertest.TracesSink)
+	proc := newVaultProcessor(zap.NewNop(), cfg, vault, sink)
+	proc.tokenizer, _ = newTokenizer(TokenizationConfig{
+		RedactionConfig: RedactionConfig{Detectors: RedactionDetectors{Email: true, SSN: true}},
+		Key:             testTokenKey,
+	})
+
+	raw := "customer erin@example.com, ssn 123-45-6789, wants a refund"
+	td := ptrace.NewTraces()
+	spans := td.ResourceSpans().AppendEmpty().ScopeSpans().AppendEmpty().Spans()
+	spans.AppendEmpty().Attributes().PutStr("gen_ai.prompt", raw)
+	spans.AppendEmpty().Attributes().PutStr("gen_ai.prompt", "nothing sensitive here")
+
+	if err := proc.ConsumeTraces(context.Background(), td); err != nil {
+		t.Fatal(err)
+	}
+
+	attrs := spans.At(0).Attributes()
+	value, _ := attrs.Get("gen_ai.prompt")
+	if !regexp.MustCompile(`^customer <EMAIL_\w+>, ssn <SSN_\w+>, wants a refund$`).MatchString(value.Str()) {
+		t.Fatalf("in-span value = %q", value.Str())
+	}
+	ref, _ := attrs.Get("gen_ai.prompt.vault_ref")
+	got, err := Detokenize(context.Background(), vault, value.Str(), ref.Str())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != raw {
+		t.Errorf("Detokenize = %q, want %q", got, raw)
+	}
+
+	clean := spans.At(1).Attributes()
+	if v, _ := clean.Get("gen_ai.prompt"); v.Str() != "nothing sensitive here" {
+		t.Errorf("clean value changed to %q", v.Str())
+	}
+	if _, ok := clean.Get("gen_ai.prompt.vault_ref"); ok {
+		t.Error("expected no vault ref when nothing was tokenized")
+	}
+}
@@ -0,0 +1,295 @@
+package promptvaultprocessor
+
+import (
+	"bufio"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// TraceManifestConfig keeps a manifest per trace listing its objects, so
+// trace-scoped lookups read one file instead of listing the backend.
+type TraceManifestConfig struct {
+	// Dir holds <trace_id[0:2]>/<trace_id>.jsonl files. Empty disables
+	// trace manifests.
+	Dir string `mapstructure:"dir"`
+}
+
+// TraceManifestEntry is one line of a trace manifest. Op is "store" or
+// "delete"; a delete line carries only the reference.
+type TraceManifestEntry struct {
+	Op      string    `json:"op"`
+	Ref     string    `json:"ref"`
+	Time    time.Time `json:"time"`
+	SpanID  string    `json:"span_id,omitempty"`
+	Key     string    `json:"key,omitempty"`
+	Service string    `json:"service,omitempty"`
+	Tenant  string    `json:"tenant,omitempty"`
+	EndUser string    `json:"enduser_id,omitempty"`
+	Size    int64     `json:"size,omitempty"`
+}
+
+// traceManifestVault appends a line to the trace's manifest after each
+// store and delete. Manifests are append-only, so concurrent writers to one
+// trace never lose each other's entries, and a manifest is removed once it
+// lists no live objects. An object deleted through another trace it was
+// also stored for stays listed in this one.
+type traceManifestVault struct {
+	VaultStorage
+	dir string
+	// mu keeps appends from racing the removal of an emptied manifest.
+	mu sync.Mutex
+}
+
+func newTraceManifestVault(vault VaultStorage, cfg TraceManifestConfig) (VaultStorage, error) {
+	if cfg.Dir == "" {
+		return vault, nil
+	}
+	if err := os.MkdirAll(cfg.Dir, 0o700); err != nil {
+		return nil, fmt.Errorf("create trace manifest dir: %w", err)
+	}
+	return &traceManifestVault{VaultStorage: vault, dir: cfg.Dir}, nil
+}
+
+// traceManifestPath returns the manifest file for traceID, or "" if the ID
+// is not hex and so cannot safely name a file.
+func traceManifestPath(dir, traceID string) string {
+	if len(traceID) < 2 {
+		return ""
+	}
+	if _, err := hex.DecodeString(traceID); err != nil {
+		return ""
+	}
+	return filepath.Join(dir, traceID[:2], traceID+".jsonl")
+}
+
+func (v *traceManifestVault) Store(ctx context.Context, meta ObjectMeta, content []byte) (string, error) {
+	ref, err := v.VaultStorage.Store(ctx, meta, content)
+	if err != nil {
+		return "", err
+	}
+	err = v.append(meta.TraceID, TraceManifestEntry{
+		Op:      "store",
+		Ref:     ref,
+		Time:    time.Now().UTC(),
+		SpanID:  meta.SpanID,
+		Key:     meta.Key,
+		Service: meta.Service,
+		Tenant:  meta.Tenant,
+		EndUser: meta.EndUser,
+		Size:    int64(len(content)),
+	})
+	if err != nil {
+		return "", err
+	}
+	return ref, nil
+}
+
+// Delete deletes ref and records the deletion in the manifest of the trace
+// the backend or index says it belongs to.
+func (v *traceManifestVault) Delete(ctx context.Context, ref string) error {
+	d, ok := v.VaultStorage.(VaultDeleter)
+	if !ok {
+		return fmt.Errorf("delete: %w", errors.ErrUnsupported)
+	}
+	var traceID string
+	if s, ok := v.VaultStorage.(VaultStatter); ok {
+		if info, err := s.Stat(ctx, ref); err == nil {
+			traceID = info.Meta.TraceID
+		}
+	}
+	if err := d.Delete(ctx, ref); err != nil {
+		return err
+	}
+	return v.append(traceID, TraceManifestEntry{Op: "delete", Ref: ref, Time: time.Now().UTC()})
+}
+
+func (v *traceManifestVault) append(traceID string, e TraceManifestEntry) error {
+	path := traceManifestPath(v.dir, traceID)
+	if path == "" {
+		return nil
+	}
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("create trace manifest dir: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return fmt.Errorf("open trace manifest: %w", err)
+	}
+	_, err = f.Write(append(line, '\n'))
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("append trace manifest: %w", err)
+	}
+	if e.Op != "delete" {
+		return nil
+	}
+	// Drop the manifest once nothing in it is live.
+	live, err := ReadTraceManifest(v.dir, traceID)
+	if err == nil && len(live) == 0 {
+		os.Remove(path)
+	}
+	return nil
+}
+
+// Search answers trace-scoped filters from the trace's manifest and
+// forwards the rest to the wrapped vault.
+func (v *traceManifestVault) Search(ctx context.Context, f ObjectFilter, fn func(ObjectInfo) error) error {
+	if f.TraceID == "" {
+		if s, ok := v.VaultStorage.(VaultSearcher); ok {
+			return s.Search(ctx, f, fn)
+		}
+		return fmt.Errorf("search: %w", errors.ErrUnsupported)
+	}
+	infos, err := ReadTraceManifest(v.dir, f.TraceID)
+	if err != nil {
+		return err
+	}
+	for _, info := range infos {
+		if !f.matches(info) {
+			continue
+		}
+		if err := fn(info); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (v *traceManifestVault) Compact(ctx context.Context) (CompactionStats, error) {
+	if c, ok := v.VaultStorage.(VaultCompactor); ok {
+		return c.Compact(ctx)
+	}
+	return CompactionStats{}, fmt.Errorf("compact: %w", errors.ErrUnsupported)
+}
+
+func (v *traceManifestVault) Usage(ctx context.Context) ([]TenantUsage, error) {
+	if u, ok := v.VaultStorage.(VaultUsageReporter); ok {
+		return u.Usage(ctx)
+	}
+	return nil, fmt.Errorf("usage: %w", errors.ErrUnsupported)
+}
+
+func (v *traceManifestVault) Stats(ctx context.Context) (VaultStats, error) {
+	if r, ok := v.VaultStorage.(VaultStatsReporter); ok {
+		return r.Stats(ctx)
+	}
+	return VaultStats{}, fmt.Errorf("stats: %w", errors.ErrUnsupported)
+}
+
+// ReadTraceManifest returns the live objects listed in traceID's manifest
+// under dir, in store order. A trace without a manifest has none.
+func ReadTraceManifest(dir, traceID string) ([]ObjectInfo, error) {
+	path := traceManifestPath(dir, traceID)
+	if path == "" {
+		return nil, nil
+	}
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("open trace manifest: %w", err)
+	}
+	defer f.Close()
+
+	live := make(map[string]int)
+	var infos []ObjectInfo
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		var e TraceManifestEntry
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			// A torn final line from a crashed writer is skipped.
+			continue
+		}
+		switch e.Op {
+		case "delete":
+			if i, ok := live[e.Ref]; ok {
+				infos[i].Ref = ""
+				delete(live, e.Ref)
+			}
+		case "store":
+			info := ObjectInfo{
+				Ref:      e.Ref,
+				Size:     e.Size,
+				StoredAt: e.Time,
+				Meta: ObjectMeta{
+					TraceID: traceID,
+					SpanID:  e.SpanID,
+					Key:     e.Key,
+					Service: e.Service,
+					Tenant:  e.Tenant,
+					EndUser: e.EndUser,
+				},
+			}
+			if i, ok := live[e.Ref]; ok {
+				infos[i] = info
+				continue
+			}
+			live[e.Ref] = len(infos)
+			infos = append(infos, info)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("read trace manifest: %w", err)
+	}
+	out := infos[:0]
+	for _, info := range infos {
+		if info.Ref != "" {
+			out = append(out, info)
+		}
+	}
+	return out, nil
+}
+
+// List, Stat, Replace, Start, and Close forward to the wrapped backend.
+
+func (v *traceManifestVault) List(ctx context.Context, fn func(ObjectInfo) error) error {
+	if l, ok := v.VaultStorage.(VaultLister); ok {
+		return l.List(ctx, fn)
+	}
+	return fmt.Errorf("list: %w", errors.ErrUnsupported)
+}
+
+func (v *traceManifestVault) Stat(ctx context.Context, ref string) (ObjectInfo, error) {
+	if s, ok := v.VaultStorage.(VaultStatter); ok {
+		return s.Stat(ctx, ref)
+	}
+	return ObjectInfo{}, fmt.Errorf("stat: %w", errors.ErrUnsupported)
+}
+
+func (v *traceManifestVault) Replace(ctx context.Context, ref string, content []byte) error {
+	if r, ok := v.VaultStorage.(VaultReplacer); ok {
+		return r.Replace(ctx, ref, content)
+	}
+	return fmt.Errorf("replace: %w", errors.ErrUnsupported)
+}
+
+func (v *traceManifestVault) Start(ctx context.Context) error {
+	if s, ok := v.VaultStorage.(vaultStarter); ok {
+		return s.Start(ctx)
+	}
+	return nil
+}
+
+func (v *traceManifestVault) Close() error {
+	if c, ok := v.VaultStorage.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
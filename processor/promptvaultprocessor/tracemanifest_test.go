@@ -0,0 +1,50 @@
+package promptvaultprocessor
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestTraceManifestVault(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	v, err := newTraceManifestVault(NewMemoryVault(0, 0), TraceManifestConfig{Dir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	const trace = "4bf92f3577b34da6a3ce929d0e0e4736"
+	prompt, _ := v.Store(ctx, ObjectMeta{TraceID: trace, SpanID: "00f067aa0ba902b7", Key: "gen_ai.prompt"}, []byte("prompt"))
+	completion, _ := v.Store(ctx, ObjectMeta{TraceID: trace, SpanID: "00f067aa0ba902b7", Key: "gen_ai.completion"}, []byte("completion"))
+	v.Store(ctx, ObjectMeta{TraceID: "../../etc/passwd", Key: "gen_ai.prompt"}, []byte("unsafe trace ID"))
+
+	infos, err := ReadTraceManifest(dir, trace)
+	if err != nil || len(infos) != 2 || infos[0].Ref != prompt || infos[1].Ref != completion {
+		t.Fatalf("manifest = %+v, %v", infos, err)
+	}
+	if infos[0].Meta.Key != "gen_ai.prompt" || infos[0].Size != int64(len("prompt")) {
+		t.Errorf("entry = %+v", infos[0])
+	}
+
+	var found []string
+	err = SearchObjects(ctx, v, ObjectFilter{TraceID: trace, Key: "gen_ai.completion"}, func(info ObjectInfo) error {
+		found = append(found, info.Ref)
+		return nil
+	})
+	if err != nil || len(found) != 1 || found[0] != completion {
+		t.Errorf("search = %v, %v", found, err)
+	}
+
+	receipt, err := Erase(ctx, v, ErasureRequest{TraceID: trace}, nil)
+	if err != nil || len(receipt.Deleted) != 2 {
+		t.Fatalf("erase = %+v, %v", receipt, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, trace[:2], trace+".jsonl")); !os.IsNotExist(err) {
+		t.Errorf("emptied manifest was kept: %v", err)
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Errorf("trace manifest dir holds %d entries, want only the trace's shard", len(entries))
+	}
+}
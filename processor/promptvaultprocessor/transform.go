@@ -0,0 +1,107 @@
+package promptvaultprocessor
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Transformer is a pre-store step compiled into the collector, such as
+// schema validation, a custom scrubber, or watermarking. It receives the
+// content about to be stored, after redaction and before compression and
+// encryption, and returns the content and metadata to store instead. An
+// error leaves the attribute in the span unvaulted.
+type Transformer interface {
+	Transform(ctx context.Context, meta ObjectMeta, data []byte) ([]byte, ObjectMeta, error)
+}
+
+// TransformerFunc adapts a function to the Transformer interface.
+type TransformerFunc func(ctx context.Context, meta ObjectMeta, data []byte) ([]byte, ObjectMeta, error)
+
+// Transform calls f.
+func (f TransformerFunc) Transform(ctx context.Context, meta ObjectMeta, data []byte) ([]byte, ObjectMeta, error) {
+	return f(ctx, meta, data)
+}
+
+// TransformerFactory builds a Transformer from the settings of one
+// transformers entry.
+type TransformerFactory func(settings map[string]any) (Transformer, error)
+
+// TransformerConfig selects a registered transformer.
+type TransformerConfig struct {
+	// Name is the name the transformer was registered under.
+	Name string `mapstructure:"name"`
+	// Settings are passed to the transformer's factory.
+	Settings map[string]any `mapstructure:"settings"`
+}
+
+var (
+	transformersMu sync.RWMutex
+	transformers   = make(map[string]TransformerFactory)
+)
+
+// RegisterTransformer makes a transformer available to the transformers
+// setting under name. It is meant to be called from an init function of a
+// package built into a custom collector distribution, and panics if name is
+// empty or already registered.
+func RegisterTransformer(name string, factory TransformerFactory) {
+	transformersMu.Lock()
+	defer transformersMu.Unlock()
+	if name == "" || factory == nil {
+		panic("promptvault: RegisterTransformer needs a name and a factory")
+	}
+	if _, dup := transformers[name]; dup {
+		panic("promptvault: RegisterTransformer called twice for " + name)
+	}
+	transformers[name] = factory
+}
+
+// RegisteredTransformers returns the names of registered transformers, sorted.
+func RegisteredTransformers() []string {
+	transformersMu.RLock()
+	defer transformersMu.RUnlock()
+	names := make([]string, 0, len(transformers))
+	for name := range transformers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// transformChain runs transformers in configured order.
+type transformChain []namedTransformer
+
+type namedTransformer struct {
+	name string
+	Transformer
+}
+
+func newTransformChain(cfgs []TransformerConfig) (transformChain, error) {
+	transformersMu.RLock()
+	defer transformersMu.RUnlock()
+	var chain transformChain
+	for i, cfg := range cfgs {
+		factory, ok := transformers[cfg.Name]
+		if !ok {
+			return nil, fmt.Errorf("transformers[%d]: unknown transformer %q", i, cfg.Name)
+		}
+		t, err := factory(cfg.Settings)
+		if err != nil {
+			return nil, fmt.Errorf("transformers[%d] (%s): %w", i, cfg.Name, err)
+		}
+		chain = append(chain, namedTransformer{name: cfg.Name, Transformer: t})
+	}
+	return chain, nil
+}
+
+// apply passes data and meta through each transformer in turn.
+func (c transformChain) apply(ctx context.Context, meta ObjectMeta, data []byte) ([]byte, ObjectMeta, error) {
+	for _, t := range c {
+		var err error
+		if data, meta, err = t.Transform(ctx, meta, data); err != nil {
+			return nil, meta, fmt.Errorf("transformer %s: %w", t.name, err)
+		}
+	}
+	return data, meta, nil
+}
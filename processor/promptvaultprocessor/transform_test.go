@@ -0,0 +1,91 @@
+package promptvaultprocessor
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.uber.org/zap"
+)
+
+func init() {
+	RegisterTransformer("test_watermark", func(settings map[string]any) (Transformer, error) {
+		mark, _ := settings["mark"].(string)
+		if mark == "" {
+			return nil, errors.New("mark is required")
+		}
+		return TransformerFunc(func(_ context.Context, meta ObjectMeta, data []byte) ([]byte, ObjectMeta, error) {
+			meta.Tenant = "watermarked"
+			return append(data, mark...), meta, nil
+		}), nil
+	})
+	RegisterTransformer("test_reject_json", func(map[string]any) (Transformer, error) {
+		return TransformerFunc(func(_ context.Context, meta ObjectMeta, data []byte) ([]byte, ObjectMeta, error) {
+			if strings.HasPrefix(string(data), "{") {
+				return nil, meta, errors.New("JSON not allowed")
+			}
+			return data, meta, nil
+		}), nil
+	})
+}
+
+func TestProcessorRunsTransformers(t *testing.T) {
+	cfg := createDefaultConfig()
+	cfg.Vault.Keys = []string{"gen_ai.prompt", "gen_ai.completion"}
+	cfg.Transformers = []TransformerConfig{
+		{Name: "test_reject_json"},
+		{Name: "test_watermark", Settings: map[string]any{"mark": " [wm]"}},
+	}
+	chain, err := newTransformChain(cfg.Transformers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vault := NewMemoryVault(0, 0)
+	proc := newVaultProcessor(zap.NewNop(), cfg, vault, new(consumertest.TracesSink))
+	proc.transforms = chain
+
+	td := ptrace.NewTraces()
+	span := td.ResourceSpans().AppendEmpty().ScopeSpans().AppendEmpty().Spans().AppendEmpty()
+	span.Attributes().PutStr("gen_ai.prompt", "Tell me about quantum computing")
+	span.Attributes().PutStr("gen_ai.completion", `{"answer": "qubits"}`)
+	if err := proc.ConsumeTraces(context.Background(), td); err != nil {
+		t.Fatal(err)
+	}
+
+	ref, _ := span.Attributes().Get("gen_ai.prompt.vault_ref")
+	data, err := vault.Retrieve(context.Background(), ref.Str())
+	if err != nil || string(data) != "Tell me about quantum computing [wm]" {
+		t.Errorf("stored %q, %v", data, err)
+	}
+	if info, _ := vault.Stat(context.Background(), ref.Str()); info.Meta.Tenant != "watermarked" {
+		t.Errorf("stored meta = %+v, want the transformed tenant", info.Meta)
+	}
+	// A rejected value stays in the span unvaulted.
+	if v, _ := span.Attributes().Get("gen_ai.completion"); v.Str() != `{"answer": "qubits"}` {
+		t.Errorf("gen_ai.completion = %q", v.Str())
+	}
+	if _, ok := span.Attributes().Get("gen_ai.completion.vault_ref"); ok {
+		t.Error("rejected value was vaulted")
+	}
+}
+
+func TestNewTransformChainErrors(t *testing.T) {
+	if _, err := newTransformChain([]TransformerConfig{{Name: "missing"}}); err == nil || !strings.Contains(err.Error(), "unknown transformer") {
+		t.Errorf("unknown transformer: %v", err)
+	}
+	if _, err := newTransformChain([]TransformerConfig{{Name: "test_watermark"}}); err == nil || !strings.Contains(err.Error(), "mark is required") {
+		t.Errorf("factory error: %v", err)
+	}
+}
+
+func TestRegisterTransformerTwicePanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("expected panic")
+		}
+	}()
+	RegisterTransformer("test_watermark", func(map[string]any) (Transformer, error) { return nil, nil })
+}
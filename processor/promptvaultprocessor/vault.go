@@ -1,64 +1,629 @@
 package promptvaultprocessor
 
 import (
+	"context"
 	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
 	"os"
+	"path"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
 	"time"
+
+	"go.uber.org/zap"
 )
 
+const refScheme = "vault://"
+
+// ErrObjectNotFound is returned, wrapped, when a reference has no object.
+var ErrObjectNotFound = errors.New("vault ref not found")
+
+// ObjectMeta describes the span attribute a vaulted object was taken from.
+type ObjectMeta struct {
+	TraceID string
+	SpanID  string
+	Key     string
+	Service string
+	Tenant  string
+	// EndUser is the span's end-user ID, so an erasure request can find
+	// every object stored for one person.
+	EndUser string
+	// Region is the data-residency region the object is routed to, from
+	// vault.region_attribute. See RoutingConfig.
+	Region string
+	// KeyID identifies the encryption key, set when encryption is enabled.
+	KeyID string
+	// Transforms lists, comma-separated and in order, the transformations
+	// applied to the content before storage, e.g. "zstd,encrypt".
+	Transforms string
+}
+
 // VaultStorage handles persisting content to a backend.
 type VaultStorage interface {
-	Store(content []byte) (ref string, err error)
+	Store(ctx context.Context, meta ObjectMeta, content []byte) (ref string, err error)
+	Retrieve(ctx context.Context, ref string) ([]byte, error)
+}
+
+// ObjectInfo describes a stored object when enumerating a backend.
+type ObjectInfo struct {
+	Ref      string
+	Size     int64
+	StoredAt time.Time
+	// Meta is populated by backends that retain span metadata.
+	Meta ObjectMeta
+	// Owners lists the other spans the object was stored for, by backends
+	// that store identical content once.
+	Owners []ObjectMeta
+}
+
+// VaultLister is implemented by backends that can enumerate stored objects.
+type VaultLister interface {
+	List(ctx context.Context, fn func(ObjectInfo) error) error
+}
+
+// VaultDeleter is implemented by backends that can remove stored objects.
+type VaultDeleter interface {
+	Delete(ctx context.Context, ref string) error
+}
+
+// VaultReplacer is implemented by backends that can overwrite an object in
+// place, keeping its ref valid even though the content changes. Used to
+// re-encrypt a vault without invalidating references already on spans.
+type VaultReplacer interface {
+	Replace(ctx context.Context, ref string, content []byte) error
+}
+
+// VaultBoundRetriever is implemented by vaults that can require an object
+// to have been stored for a given trace, span, and attribute key, such as an
+// encrypting vault, whose ciphertexts are bound to them.
+type VaultBoundRetriever interface {
+	RetrieveFor(ctx context.Context, ref string, meta ObjectMeta) ([]byte, error)
+}
+
+// RetrieveFor retrieves ref through vault, requiring it to belong to the
+// trace, span, and key in meta where vault can tell. Callers that found ref
+// on a span should use it rather than Retrieve, so a ref copied onto another
+// span does not resolve.
+func RetrieveFor(ctx context.Context, vault VaultStorage, ref string, meta ObjectMeta) ([]byte, error) {
+	if b, ok := vault.(VaultBoundRetriever); ok {
+		return b.RetrieveFor(ctx, ref, meta)
+	}
+	return vault.Retrieve(ctx, ref)
+}
+
+// VaultStatter is implemented by backends that can describe a stored object
+// without reading its content. Size is as stored, after any transformations.
+type VaultStatter interface {
+	Stat(ctx context.Context, ref string) (ObjectInfo, error)
+}
+
+// ObjectFilter selects objects by metadata. Empty fields match anything.
+type ObjectFilter struct {
+	TraceID string
+	EndUser string
+	Service string
+	Tenant  string
+	Key     string
+	// StoredAfter and StoredBefore, if set, match objects stored at or
+	// after, and before, these times.
+	StoredAfter  time.Time
+	StoredBefore time.Time
+}
+
+func (f ObjectFilter) matches(info ObjectInfo) bool {
+	_, ok := f.match(info)
+	return ok
+}
+
+// match reports whether info matches f, through its Meta or any of its
+// Owners, and returns info described by the first that does, like an
+// index row.
+func (f ObjectFilter) match(info ObjectInfo) (ObjectInfo, bool) {
+	if (!f.StoredAfter.IsZero() && info.StoredAt.Before(f.StoredAfter)) ||
+		(!f.StoredBefore.IsZero() && !info.StoredAt.Before(f.StoredBefore)) {
+		return info, false
+	}
+	for _, m := range append([]ObjectMeta{info.Meta}, info.Owners...) {
+		if (f.TraceID == "" || m.TraceID == f.TraceID) &&
+			(f.EndUser == "" || m.EndUser == f.EndUser) &&
+			(f.Service == "" || m.Service == f.Service) &&
+			(f.Tenant == "" || m.Tenant == f.Tenant) &&
+			(f.Key == "" || m.Key == f.Key) {
+			info.Meta = m
+			return info, true
+		}
+	}
+	return info, false
+}
+
+// VaultSearcher is implemented by backends that can find objects by
+// metadata without enumerating every object, such as an indexed vault.
+type VaultSearcher interface {
+	Search(ctx context.Context, f ObjectFilter, fn func(ObjectInfo) error) error
+}
+
+// SearchObjects calls fn for every object matching f, using the vault's
+// index when it has one and otherwise filtering a full listing.
+func SearchObjects(ctx context.Context, vault VaultStorage, f ObjectFilter, fn func(ObjectInfo) error) error {
+	if s, ok := vault.(VaultSearcher); ok {
+		err := s.Search(ctx, f, fn)
+		if !errors.Is(err, errors.ErrUnsupported) {
+			return err
+		}
+	}
+	l, ok := vault.(VaultLister)
+	if !ok {
+		return fmt.Errorf("list: %w", errors.ErrUnsupported)
+	}
+	return l.List(ctx, func(info ObjectInfo) error {
+		info, ok := f.match(info)
+		if !ok {
+			return nil
+		}
+		return fn(info)
+	})
+}
+
+// vaultStarter is implemented by backends with background work (eviction,
+// tier migration) that must begin in the component's Start.
+type vaultStarter interface {
+	Start(ctx context.Context) error
+}
+
+// newVaultStorage builds the backend selected by cfg.Backend.
+func newVaultStorage(ctx context.Context, logger *zap.Logger, metrics *vaultMetrics, cfg StorageConfig) (VaultStorage, error) {
+	if err := validatePathTemplate(cfg.PathTemplate); err != nil {
+		return nil, err
+	}
+
+	switch cfg.Backend {
+	case "", "filesystem":
+		v, err := NewFilesystemVault(cfg.Filesystem)
+		if err != nil {
+			return nil, err
+		}
+		v.logger, v.metrics = logger, metrics
+		v.pathTemplate = cfg.PathTemplate
+		return v, nil
+	case "postgres":
+		return openPostgresVault(ctx, cfg.Postgres)
+	case "sqlite":
+		return NewSQLiteVault(ctx, cfg.SQLite.Path)
+	case "kafka":
+		return openKafkaVault(cfg.Kafka)
+	case "memory":
+		return NewMemoryVault(int64(cfg.Memory.MaxBytes), cfg.Memory.TTL), nil
+	case "http":
+		v, err := NewHTTPVault(cfg.HTTP)
+		if err != nil {
+			return nil, err
+		}
+		v.pathTemplate = cfg.PathTemplate
+		return v, nil
+	case "tiered":
+		return newTieredVault(ctx, logger, metrics, cfg)
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q", cfg.Backend)
+	}
+}
+
+func newTieredVault(ctx context.Context, logger *zap.Logger, metrics *vaultMetrics, cfg StorageConfig) (VaultStorage, error) {
+	if cfg.Tiered.Hot == "tiered" || cfg.Tiered.Cold == "tiered" {
+		return nil, fmt.Errorf("tiered storage cannot be nested")
+	}
+	if cfg.Tiered.Cold == "kafka" {
+		return nil, fmt.Errorf("kafka cannot be used as the cold tier: its references are not content-addressed")
+	}
+
+	hotCfg, coldCfg := cfg, cfg
+	hotCfg.Backend, coldCfg.Backend = cfg.Tiered.Hot, cfg.Tiered.Cold
+
+	hot, err := newVaultStorage(ctx, logger, metrics, hotCfg)
+	if err != nil {
+		return nil, fmt.Errorf("create hot tier: %w", err)
+	}
+	cold, err := newVaultStorage(ctx, logger, metrics, coldCfg)
+	if err != nil {
+		closeVaults(hot)
+		return nil, fmt.Errorf("create cold tier: %w", err)
+	}
+	v, err := NewTieredVault(logger, hot, cold, cfg.Tiered)
+	if err != nil {
+		closeVaults(hot, cold)
+		return nil, err
+	}
+	return v, nil
+}
+
+// closeVaults closes the vaults that hold resources, on error paths where
+// the error being returned matters more than any from closing.
+func closeVaults(vaults ...VaultStorage) {
+	for _, v := range vaults {
+		if c, ok := v.(io.Closer); ok {
+			c.Close()
+		}
+	}
+}
+
+// contentHash returns the hex sha256 of content, used as the object identity.
+func contentHash(content []byte) string {
+	return fmt.Sprintf("%x", sha256.Sum256(content))
+}
+
+// hashFromRef returns the content hash a reference points at. References
+// written with a path template carry the hash as their last path segment.
+func hashFromRef(ref string) string {
+	ref, _ = splitRefVersion(ref)
+	return path.Base(strings.TrimPrefix(ref, refScheme))
+}
+
+// versionedRef returns the reference to version of the content with hexHash.
+// The first version keeps the plain vault://<sha256> form.
+func versionedRef(hexHash string, version int) string {
+	if version <= 1 {
+		return refScheme + hexHash
+	}
+	return refScheme + hexHash + "@" + strconv.Itoa(version)
+}
+
+// splitRefVersion splits a reference of the form vault://<sha256>@<version>
+// into the unversioned reference and the version. It returns 0 for
+// references that do not pin a version.
+func splitRefVersion(ref string) (string, int) {
+	i := strings.LastIndexByte(ref, '@')
+	if i < 0 || strings.ContainsRune(ref[i:], '/') {
+		return ref, 0
+	}
+	version, err := strconv.Atoi(ref[i+1:])
+	if err != nil || version < 1 {
+		return ref, 0
+	}
+	return ref[:i], version
+}
+
+// isContentHash reports whether s is a hex-encoded sha256, which makes it
+// safe to use as a path component.
+func isContentHash(s string) bool {
+	if len(s) != sha256.Size*2 {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil
 }
 
 // FilesystemVault stores content as files on disk.
 type FilesystemVault struct {
-	basePath string
+	basePath      string
+	sync          bool
+	sharded       bool
+	writeMetadata bool
+	pathTemplate  string
+	// metaMu serializes sidecar writes, so owners recorded for identical
+	// content stored concurrently are not lost.
+	metaMu sync.Mutex
+
+	// Quota and eviction; see eviction.go.
+	maxBytes      int64
+	maxObjects    int
+	ttl           time.Duration
+	evictInterval time.Duration
+	logger        *zap.Logger
+	metrics       *vaultMetrics
+	stop          chan struct{}
+	done          sync.WaitGroup
 }
 
 // NewFilesystemVault creates a new filesystem-based vault.
-func NewFilesystemVault(basePath string) (*FilesystemVault, error) {
-	if err := os.MkdirAll(basePath, 0o755); err != nil {
-		return nil, fmt.Errorf("create vault dir: %w", err)
+func NewFilesystemVault(cfg FilesystemConfig) (*FilesystemVault, error) {
+	if err := os.MkdirAll(cfg.BasePath, 0o755); err != nil {
+		return nil, baseDirError(cfg.BasePath, err)
+	}
+	switch cfg.Layout {
+	case "", "date", "sharded":
+	default:
+		return nil, fmt.Errorf("unknown filesystem layout %q", cfg.Layout)
+	}
+	return &FilesystemVault{
+		basePath:      cfg.BasePath,
+		sync:          cfg.Sync,
+		sharded:       cfg.Layout == "sharded",
+		writeMetadata: cfg.WriteMetadata,
+		maxBytes:      int64(cfg.MaxBytes),
+		maxObjects:    cfg.MaxObjects,
+		ttl:           cfg.TTL,
+		evictInterval: cfg.EvictionInterval,
+		logger:        zap.NewNop(),
+		stop:          make(chan struct{}),
+	}, nil
+}
+
+// baseDirError explains why base_path cannot be written and how to fix it.
+// Containers often run with a read-only root filesystem, where only mounted
+// volumes are writable.
+func baseDirError(basePath string, err error) error {
+	switch {
+	case errors.Is(err, syscall.EROFS):
+		return fmt.Errorf("filesystem.base_path %s is on a read-only filesystem; mount a writable volume there "+
+			"(an emptyDir or persistent volume in Kubernetes), or set base_path to a writable directory, "+
+			"e.g. ${env:VAULT_DIR}: %w", basePath, err)
+	case errors.Is(err, fs.ErrPermission):
+		return fmt.Errorf("filesystem.base_path %s is not writable by uid %d; grant it write access to the directory "+
+			"(fsGroup or a chown'd volume in Kubernetes), or set base_path to a writable directory, "+
+			"e.g. ${env:VAULT_DIR}: %w", basePath, os.Getuid(), err)
+	default:
+		return fmt.Errorf("filesystem.base_path %s: %w", basePath, err)
 	}
-	return &FilesystemVault{basePath: basePath}, nil
 }
 
 // Store writes content to a file and returns a vault reference.
-// The reference format is: vault://<sha256>
-func (v *FilesystemVault) Store(content []byte) (string, error) {
-	hash := sha256.Sum256(content)
-	hexHash := fmt.Sprintf("%x", hash)
-
-	// Use date-partitioned directories for organization
-	now := time.Now().UTC()
-	dir := filepath.Join(v.basePath, now.Format("2006/01/02"))
+// The reference format is: vault://<sha256>, or vault://<expanded template>/<sha256>
+// when a path template is configured.
+func (v *FilesystemVault) Store(_ context.Context, meta ObjectMeta, content []byte) (string, error) {
+	hexHash := contentHash(content)
+	ref := refScheme + hexHash
+
+	// Use the path template if configured; otherwise date-partitioned
+	// directories, or hash-prefix shards to keep directory sizes bounded on
+	// busy collectors.
+	var dir string
+	switch {
+	case v.pathTemplate != "":
+		objPath := objectPath(v.pathTemplate, meta, hexHash, time.Now())
+		ref = refScheme + objPath
+		dir = filepath.Join(v.basePath, filepath.FromSlash(path.Dir(objPath)))
+	case v.sharded:
+		dir = v.shardDir(hexHash)
+	default:
+		dir = filepath.Join(v.basePath, time.Now().UTC().Format("2006/01/02"))
+	}
 	if err := os.MkdirAll(dir, 0o755); err != nil {
-		return "", fmt.Errorf("create date dir: %w", err)
+		return "", fmt.Errorf("create vault dir: %w", err)
 	}
 
-	path := filepath.Join(dir, hexHash+".vault")
+	file := filepath.Join(dir, hexHash+".vault")
+	if v.writeMetadata {
+		v.metaMu.Lock()
+		defer v.metaMu.Unlock()
+	}
 
-	// Deduplicate: if same hash exists, skip write
-	if _, err := os.Stat(path); err == nil {
-		return fmt.Sprintf("vault://%s", hexHash), nil
+	// Deduplicate: if same hash exists, skip write, recording the new owner.
+	if _, err := os.Stat(file); err == nil {
+		if v.writeMetadata {
+			if err := v.addOwner(file, meta, hexHash, len(content)); err != nil {
+				return "", err
+			}
+		}
+		return ref, nil
 	}
 
-	if err := os.WriteFile(path, content, 0o644); err != nil {
+	if err := writeFileAtomic(file, content, v.sync); err != nil {
 		return "", fmt.Errorf("write vault file: %w", err)
 	}
 
-	return fmt.Sprintf("vault://%s", hexHash), nil
-}// Retrieve reads content back from the vault by reference.
-func (v *FilesystemVault) Retrieve(ref string) ([]byte, error) {
-	// Walk the vault looking for the hash file
-	hexHash := ref
-	if len(ref) > 8 && ref[:8] == "vault://" {
-		hexHash = ref[8:]
+	if v.writeMetadata {
+		sidecar, err := json.Marshal(newObjectMetadata(meta, hexHash, len(content), time.Now()))
+		if err != nil {
+			return "", fmt.Errorf("encode vault metadata: %w", err)
+		}
+		if err := writeFileAtomic(file+metadataSuffix, sidecar, v.sync); err != nil {
+			return "", fmt.Errorf("write vault metadata: %w", err)
+		}
+	}
+
+	return ref, nil
+}
+
+// addOwner records meta as an owner in the sidecar of the object at file.
+func (v *FilesystemVault) addOwner(file string, meta ObjectMeta, hexHash string, size int) error {
+	md, err := readObjectMetadata(file + metadataSuffix)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		// Stored before metadata was enabled.
+		md = newObjectMetadata(meta, hexHash, size, time.Now())
+	case err != nil:
+		return fmt.Errorf("read vault metadata: %w", err)
+	case !md.addOwner(meta):
+		return nil
+	}
+	sidecar, err := json.Marshal(md)
+	if err != nil {
+		return fmt.Errorf("encode vault metadata: %w", err)
+	}
+	if err := writeFileAtomic(file+metadataSuffix, sidecar, v.sync); err != nil {
+		return fmt.Errorf("write vault metadata: %w", err)
+	}
+	return nil
+}
+
+// Retrieve reads content back from the vault by reference.
+func (v *FilesystemVault) Retrieve(_ context.Context, ref string) ([]byte, error) {
+	found, err := v.findPath(ref)
+	if err != nil {
+		return nil, err
+	}
+	return os.ReadFile(found)
+}
+
+// Stat describes the object at ref from its metadata sidecar, falling back to
+// the path template fields when there is none.
+func (v *FilesystemVault) Stat(_ context.Context, ref string) (ObjectInfo, error) {
+	found, err := v.findPath(ref)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	fi, err := os.Stat(found)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	info := ObjectInfo{Ref: ref, Size: fi.Size(), StoredAt: fi.ModTime()}
+	if om, err := readObjectMetadata(found + metadataSuffix); err == nil {
+		info.Meta, info.Owners = om.objectMeta(), om.owners()
+	} else if v.pathTemplate != "" {
+		if objPath, err := objectPathFromRef(ref); err == nil {
+			info.Meta = metaFromObjectPath(v.pathTemplate, objPath)
+		}
+	}
+	return info, nil
+}
+
+// Delete removes the object for ref and its metadata sidecar, if any.
+func (v *FilesystemVault) Delete(_ context.Context, ref string) error {
+	found, err := v.findPath(ref)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(found + metadataSuffix); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return os.Remove(found)
+}
+
+// List calls fn for every object in the vault.
+func (v *FilesystemVault) List(ctx context.Context, fn func(ObjectInfo) error) error {
+	return filepath.Walk(v.basePath, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil // skip errors
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if info.IsDir() || !strings.HasSuffix(info.Name(), ".vault") {
+			return nil
+		}
+		ref := refScheme + strings.TrimSuffix(info.Name(), ".vault")
+		var meta ObjectMeta
+		if v.pathTemplate != "" {
+			rel, err := filepath.Rel(v.basePath, path)
+			if err != nil {
+				return err
+			}
+			objPath := strings.TrimSuffix(filepath.ToSlash(rel), ".vault")
+			ref = refScheme + objPath
+			meta = metaFromObjectPath(v.pathTemplate, objPath)
+		}
+		var owners []ObjectMeta
+		if om, err := readObjectMetadata(path + metadataSuffix); err == nil {
+			meta, owners = om.objectMeta(), om.owners()
+		}
+		return fn(ObjectInfo{
+			Ref:      ref,
+			Size:     info.Size(),
+			StoredAt: info.ModTime(),
+			Meta:     meta,
+			Owners:   owners,
+		})
+	})
+}
+
+// Replace atomically overwrites the object at ref, updating the size in its
+// metadata sidecar if there is one.
+func (v *FilesystemVault) Replace(_ context.Context, ref string, content []byte) error {
+	file, err := v.findPath(ref)
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(file, content, v.sync); err != nil {
+		return fmt.Errorf("replace vault file: %w", err)
+	}
+
+	md, err := readObjectMetadata(file + metadataSuffix)
+	if err != nil {
+		return nil
+	}
+	md.Size = len(content)
+	sidecar, err := json.Marshal(md)
+	if err != nil {
+		return fmt.Errorf("encode vault metadata: %w", err)
+	}
+	if err := writeFileAtomic(file+metadataSuffix, sidecar, v.sync); err != nil {
+		return fmt.Errorf("write vault metadata: %w", err)
+	}
+	return nil
+}
+
+// writeFileAtomic writes content to a temp file in the target directory,
+// fsyncs it, and renames it into place, so readers never observe a partially
+// written object. With syncDir the parent directory is fsynced as well, making
+// the rename itself durable across power loss.
+func writeFileAtomic(path string, content []byte, syncDir bool) error {
+	dir := filepath.Dir(path)
+	tmp, err := os.CreateTemp(dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+	// Clean up on any failure; after a successful rename this is a no-op.
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
 	}
 
+	if !syncDir {
+		return nil
+	}
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
+// shardDir returns <base>/<hash[0:2]>/<hash[2:4]>.
+func (v *FilesystemVault) shardDir(hexHash string) string {
+	return filepath.Join(v.basePath, hexHash[0:2], hexHash[2:4])
+}
+
+func (v *FilesystemVault) findPath(ref string) (string, error) {
+	hexHash := hashFromRef(ref)
+	if !isContentHash(hexHash) {
+		return "", fmt.Errorf("invalid vault ref: %s", ref)
+	}
+
+	// Refs written with a path template name their file directly.
+	if objPath := strings.TrimPrefix(ref, refScheme); objPath != hexHash {
+		objPath, err := objectPathFromRef(ref)
+		if err != nil {
+			return "", err
+		}
+		file := filepath.Join(v.basePath, filepath.FromSlash(objPath)+".vault")
+		if _, err := os.Stat(file); err != nil {
+			return "", fmt.Errorf("%w: %s", ErrObjectNotFound, ref)
+		}
+		return file, nil
+	}
+
+	// Objects written with the sharded layout resolve without a walk.
+	file := filepath.Join(v.shardDir(hexHash), hexHash+".vault")
+	if _, err := os.Stat(file); err == nil {
+		return file, nil
+	}
+
+	// Fall back to walking the vault, which finds objects written with the
+	// date layout.
+
 	var found string
 	err := filepath.Walk(v.basePath, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
@@ -72,8 +637,7 @@ func (v *FilesystemVault) Retrieve(ref string) ([]byte, error) {
 	})
 
 	if err != nil || found == "" {
-		return nil, fmt.Errorf("vault ref not found: %s", ref)
+		return "", fmt.Errorf("%w: %s", ErrObjectNotFound, ref)
 	}
-
-	return os.ReadFile(found)
-}
\ No newline at end of file
+	return found, nil
+}
@@ -0,0 +1,72 @@
+package promptvaultprocessor
+
+import (
+	"context"
+	"sort"
+	"time"
+)
+
+// watchSlack is how far before the newest object seen each poll looks
+// again, so objects that become visible late, because a writer's clock or
+// index insert lagged, are still reported.
+const watchSlack = 10 * time.Second
+
+// WatchObjects polls vault every interval and calls fn, oldest first, for
+// each object matching f stored since f.StoredAfter, or since the call if
+// that is unset. It returns when ctx is done or fn fails. Each object is
+// reported once, and again if its content is later stored for another span.
+func WatchObjects(ctx context.Context, vault VaultStorage, f ObjectFilter, interval time.Duration, fn func(ObjectInfo) error) error {
+	if interval <= 0 {
+		interval = 2 * time.Second
+	}
+	mark := f.StoredAfter
+	if mark.IsZero() {
+		mark = time.Now()
+	}
+	start := mark
+	type store struct{ ref, spanID, key string }
+	seen := make(map[store]time.Time)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		var found []ObjectInfo
+		f.StoredAfter = mark.Add(-watchSlack)
+		err := SearchObjects(ctx, vault, f, func(info ObjectInfo) error {
+			if _, ok := seen[store{info.Ref, info.Meta.SpanID, info.Meta.Key}]; !ok {
+				found = append(found, info)
+			}
+			return nil
+		})
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil {
+			return err
+		}
+		sort.SliceStable(found, func(i, j int) bool { return found[i].StoredAt.Before(found[j].StoredAt) })
+		for _, info := range found {
+			if info.StoredAt.Before(start) {
+				continue
+			}
+			seen[store{info.Ref, info.Meta.SpanID, info.Meta.Key}] = info.StoredAt
+			if info.StoredAt.After(mark) {
+				mark = info.StoredAt
+			}
+			if err := fn(info); err != nil {
+				return err
+			}
+		}
+		// Forget what the next poll can no longer return.
+		for s, at := range seen {
+			if at.Before(mark.Add(-watchSlack)) {
+				delete(seen, s)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package promptvaultprocessor
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestWatchObjects(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	vault := newTestIndexVault(t, NewMemoryVault(0, 0))
+	vault.Store(ctx, ObjectMeta{TraceID: "t0", SpanID: "s0", Key: "gen_ai.prompt", Service: "chat"}, []byte("before"))
+
+	got := make(chan ObjectInfo, 10)
+	done := make(chan error, 1)
+	go func() {
+		done <- WatchObjects(ctx, vault, ObjectFilter{Service: "chat"}, 10*time.Millisecond, func(info ObjectInfo) error {
+			got <- info
+			return nil
+		})
+	}()
+	time.Sleep(30 * time.Millisecond)
+	vault.Store(ctx, ObjectMeta{TraceID: "t1", SpanID: "s1", Key: "gen_ai.prompt", Service: "chat"}, []byte("a"))
+	vault.Store(ctx, ObjectMeta{TraceID: "t1", SpanID: "s2", Key: "gen_ai.prompt", Service: "search"}, []byte("b"))
+	next := func() string {
+		select {
+		case info := <-got:
+			return info.Meta.SpanID
+		case <-time.After(5 * time.Second):
+			t.Fatal("no object watched")
+			return ""
+		}
+	}
+	if span := next(); span != "s1" {
+		t.Errorf("watched %s, want s1", span)
+	}
+	// The same content stored for another span is reported again.
+	vault.Store(ctx, ObjectMeta{TraceID: "t1", SpanID: "s3", Key: "gen_ai.prompt", Service: "chat"}, []byte("a"))
+	if span := next(); span != "s3" {
+		t.Errorf("watched %s, want s3", span)
+	}
+	// Later polls report nothing again.
+	time.Sleep(50 * time.Millisecond)
+	select {
+	case info := <-got:
+		t.Errorf("%s reported twice", info.Meta.SpanID)
+	default:
+	}
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Errorf("WatchObjects = %v, want context.Canceled", err)
+	}
+}
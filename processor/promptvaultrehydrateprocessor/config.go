@@ -0,0 +1,44 @@
+// Package promptvaultrehydrateprocessor replaces vault references in spans
+// and logs with the content they point at, for replay pipelines and for
+// exporting full payloads to systems cleared to hold them.
+package promptvaultrehydrateprocessor
+
+import (
+	"github.com/airblackbox/otel-prompt-vault/processor/promptvaultprocessor"
+
+	"go.opentelemetry.io/collector/config/configopaque"
+)
+
+// Config for the rehydration processor.
+type Config struct {
+	// Storage and Crypto locate and decrypt the vault. They take the same
+	// options as the promptvault processor and must match its settings.
+	Storage promptvaultprocessor.StorageConfig `mapstructure:"storage"`
+	Crypto  promptvaultprocessor.CryptoConfig  `mapstructure:"crypto"`
+	// Keys restricts rehydration to these attribute keys. Empty rehydrates
+	// every reference found.
+	Keys []string `mapstructure:"keys"`
+	// SigningKey verifies each reference's <key>.vault_sig against the
+	// retrieved content; references that fail are left in place. Must match
+	// the promptvault processor's vault.signing_key.
+	SigningKey configopaque.String `mapstructure:"signing_key"`
+	// RequireSignature also leaves unsigned references in place.
+	RequireSignature bool `mapstructure:"require_signature"`
+	// MaxSize leaves references to content larger than this many bytes in
+	// place. 0 = no limit.
+	MaxSize promptvaultprocessor.ByteSize `mapstructure:"max_size"`
+	// Audit records every retrieval, attributed to this component's ID.
+	Audit promptvaultprocessor.AuditConfig `mapstructure:"audit"`
+	// Hooks run, in order, on retrieved content before it is written back,
+	// each naming a hook registered with RegisterRetrieveHook.
+	Hooks []promptvaultprocessor.RetrieveHookConfig `mapstructure:"hooks"`
+}
+
+func createDefaultConfig() *Config {
+	// Read from wherever the promptvault processor writes by default.
+	vaultCfg := promptvaultprocessor.NewFactory().CreateDefaultConfig().(*promptvaultprocessor.Config)
+	return &Config{
+		Storage: vaultCfg.Storage,
+		Crypto:  vaultCfg.Crypto,
+	}
+}
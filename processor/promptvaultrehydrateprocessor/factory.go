@@ -0,0 +1,105 @@
+package promptvaultrehydrateprocessor
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/consumer"
+	"go.opentelemetry.io/collector/processor"
+	"go.uber.org/zap"
+
+	"github.com/airblackbox/otel-prompt-vault/processor/promptvaultprocessor"
+)
+
+const (
+	typeStr   = "promptvaultrehydrate"
+	stability = component.StabilityLevelAlpha
+)
+
+// NewFactory creates a factory for the rehydration processor.
+func NewFactory() processor.Factory {
+	return processor.NewFactory(
+		component.MustNewType(typeStr),
+		func() component.Config { return createDefaultConfig() },
+		processor.WithTraces(createTracesProcessor, stability),
+		processor.WithLogs(createLogsProcessor, stability),
+	)
+}
+
+func createTracesProcessor(
+	ctx context.Context,
+	set processor.Settings,
+	cfg component.Config,
+	nextConsumer consumer.Traces,
+) (processor.Traces, error) {
+	proc, err := newRehydrateProcessor(ctx, set, cfg.(*Config))
+	if err != nil {
+		return nil, err
+	}
+	proc.nextTraces = nextConsumer
+	return proc, nil
+}
+
+func createLogsProcessor(
+	ctx context.Context,
+	set processor.Settings,
+	cfg component.Config,
+	nextConsumer consumer.Logs,
+) (processor.Logs, error) {
+	proc, err := newRehydrateProcessor(ctx, set, cfg.(*Config))
+	if err != nil {
+		return nil, err
+	}
+	proc.nextLogs = nextConsumer
+	return proc, nil
+}
+
+func newRehydrateProcessor(ctx context.Context, set processor.Settings, cfg *Config) (*rehydrateProcessor, error) {
+	if err := promptvaultprocessor.CheckSecretsRedacted(cfg); err != nil {
+		return nil, err
+	}
+	if cfg.MaxSize < 0 {
+		return nil, fmt.Errorf("max_size must not be negative")
+	}
+	signer, err := promptvaultprocessor.NewRefSigner(cfg.SigningKey)
+	if err != nil {
+		return nil, fmt.Errorf("signing_key: %w", err)
+	}
+	if cfg.RequireSignature && signer == nil {
+		return nil, fmt.Errorf("require_signature needs signing_key")
+	}
+	hooks, err := promptvaultprocessor.NewRetrieveHooks(cfg.Hooks)
+	if err != nil {
+		return nil, err
+	}
+
+	// A vault shared through an extension is resolved in Start.
+	var vault promptvaultprocessor.VaultStorage
+	if ignored := promptvaultprocessor.IgnoredWithExtension(cfg.Storage, cfg.Crypto); cfg.Storage.Extension != "" && len(ignored) > 0 {
+		set.Logger.Warn("Settings are ignored because storage.extension is set",
+			zap.String("extension", cfg.Storage.Extension), zap.Strings("ignored", ignored))
+	}
+	if cfg.Storage.Extension == "" {
+		vaultCfg := &promptvaultprocessor.Config{Storage: cfg.Storage, Crypto: cfg.Crypto}
+		vaultCfg.Vault.SigningKey = cfg.SigningKey
+		if vault, err = promptvaultprocessor.OpenVault(ctx, set.Logger, vaultCfg); err != nil {
+			return nil, err
+		}
+		if vault, err = withAudit(ctx, cfg.Audit, vault); err != nil {
+			return nil, err
+		}
+	}
+	proc := newProcessor(set.Logger, cfg, vault, signer, set.ID.String())
+	proc.hooks = hooks
+	return proc, nil
+}
+
+// withAudit records retrievals through vault to the configured sink.
+func withAudit(ctx context.Context, cfg promptvaultprocessor.AuditConfig, vault promptvaultprocessor.VaultStorage) (promptvaultprocessor.VaultStorage, error) {
+	sink, err := promptvaultprocessor.NewAuditSink(ctx, cfg, vault)
+	if err != nil {
+		return nil, err
+	}
+	return promptvaultprocessor.NewAuditingVault(vault, sink, typeStr), nil
+}
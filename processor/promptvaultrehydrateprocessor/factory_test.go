@@ -0,0 +1,18 @@
+package promptvaultrehydrateprocessor
+
+import (
+	"testing"
+
+	"go.opentelemetry.io/collector/component"
+
+	"github.com/airblackbox/otel-prompt-vault/internal/metadatatest"
+)
+
+func TestMetadataMatchesFactory(t *testing.T) {
+	f := NewFactory()
+	metadatatest.Check(t, "processor", f.Type(), map[string]component.StabilityLevel{
+		"traces":  f.TracesProcessorStability(),
+		"metrics": f.MetricsProcessorStability(),
+		"logs":    f.LogsProcessorStability(),
+	})
+}
@@ -0,0 +1,337 @@
+package promptvaultrehydrateprocessor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/consumer"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.uber.org/zap"
+
+	"github.com/airblackbox/otel-prompt-vault/processor/promptvaultprocessor"
+)
+
+// Attribute conventions of the promptvault processor.
+const (
+	refScheme     = "vault://"
+	refAttrSuffix = ".vault_ref"
+	sigAttrSuffix = ".vault_sig"
+	// In vault.compatibility: datadog the companions are prefixed instead.
+	refAttrPrefix = "promptvault.ref."
+	sigAttrPrefix = "promptvault.sig."
+)
+
+// refBase returns the key whose reference attribute is key, in either
+// layout.
+func refBase(key string) (string, bool) {
+	if base, ok := strings.CutSuffix(key, refAttrSuffix); ok {
+		return base, true
+	}
+	return strings.CutPrefix(key, refAttrPrefix)
+}
+
+// lookupCompanion returns key's companion attribute of the given layout
+// suffix and prefix, whichever is present.
+func lookupCompanion(attrs pcommon.Map, key, suffix, prefix string) (pcommon.Value, bool) {
+	if v, ok := attrs.Get(key + suffix); ok {
+		return v, true
+	}
+	return attrs.Get(prefix + key)
+}
+
+// tokenPattern matches the tokens the promptvault processor's tokenize mode
+// leaves in spans, e.g. <EMAIL_7f3a9c01>.
+var tokenPattern = regexp.MustCompile(`^<[A-Z0-9_]+_[0-9a-f]{8}>$`)
+
+var errUnsigned = errors.New("reference is not signed")
+
+type rehydrateProcessor struct {
+	logger     *zap.Logger
+	config     *Config
+	vault      promptvaultprocessor.VaultStorage
+	signer     *promptvaultprocessor.RefSigner
+	hooks      promptvaultprocessor.RetrieveHooks
+	keysSet    map[string]bool
+	principal  string
+	nextTraces consumer.Traces
+	nextLogs   consumer.Logs
+}
+
+func newProcessor(
+	logger *zap.Logger,
+	cfg *Config,
+	vault promptvaultprocessor.VaultStorage,
+	signer *promptvaultprocessor.RefSigner,
+	principal string,
+) *rehydrateProcessor {
+	keysSet := make(map[string]bool, len(cfg.Keys))
+	for _, k := range cfg.Keys {
+		keysSet[k] = true
+	}
+	return &rehydrateProcessor{
+		logger:    logger,
+		config:    cfg,
+		vault:     vault,
+		signer:    signer,
+		keysSet:   keysSet,
+		principal: principal,
+	}
+}
+
+func (p *rehydrateProcessor) Start(ctx context.Context, host component.Host) error {
+	if p.config.Storage.Extension != "" {
+		vault, err := promptvaultprocessor.VaultFromHost(host, p.config.Storage.Extension)
+		if err != nil {
+			return err
+		}
+		if p.vault, err = withAudit(ctx, p.config.Audit, vault); err != nil {
+			return err
+		}
+	}
+	if s, ok := p.vault.(interface{ Start(context.Context) error }); ok {
+		return s.Start(ctx)
+	}
+	return nil
+}
+
+func (p *rehydrateProcessor) Shutdown(_ context.Context) error {
+	if c, ok := p.vault.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+func (p *rehydrateProcessor) Capabilities() consumer.Capabilities {
+	return consumer.Capabilities{MutatesData: true}
+}
+
+func (p *rehydrateProcessor) ConsumeTraces(ctx context.Context, td ptrace.Traces) error {
+	ctx = promptvaultprocessor.WithPrincipal(ctx, p.principal)
+	cache := make(map[string][]byte)
+	rss := td.ResourceSpans()
+	for i := 0; i < rss.Len(); i++ {
+		ilss := rss.At(i).ScopeSpans()
+		for j := 0; j < ilss.Len(); j++ {
+			spans := ilss.At(j).Spans()
+			for k := 0; k < spans.Len(); k++ {
+				span := spans.At(k)
+				bound := &promptvaultprocessor.ObjectMeta{TraceID: span.TraceID().String(), SpanID: span.SpanID().String()}
+				p.rehydrate(ctx, span.Attributes(), bound, cache)
+			}
+		}
+	}
+	return p.nextTraces.ConsumeTraces(ctx, td)
+}
+
+func (p *rehydrateProcessor) ConsumeLogs(ctx context.Context, ld plog.Logs) error {
+	ctx = promptvaultprocessor.WithPrincipal(ctx, p.principal)
+	cache := make(map[string][]byte)
+	rls := ld.ResourceLogs()
+	for i := 0; i < rls.Len(); i++ {
+		sls := rls.At(i).ScopeLogs()
+		for j := 0; j < sls.Len(); j++ {
+			records := sls.At(j).LogRecords()
+			for k := 0; k < records.Len(); k++ {
+				record := records.At(k)
+				// References in logs were copied from the spans they
+				// were written to, so they cannot be bound to the record.
+				p.rehydrate(ctx, record.Attributes(), nil, cache)
+				p.rehydrateBody(ctx, record.Body(), cache)
+			}
+		}
+	}
+	return p.nextLogs.ConsumeLogs(ctx, ld)
+}
+
+// refFrom returns the reference s holds: s itself, or the vault://
+// reference embedded in a link the processor wrote with ref_format link.
+func refFrom(s string) (string, bool) {
+	if strings.HasPrefix(s, refScheme) {
+		return s, true
+	}
+	if !strings.HasPrefix(s, "http://") && !strings.HasPrefix(s, "https://") {
+		return "", false
+	}
+	u, err := url.Parse(s)
+	if err != nil {
+		return "", false
+	}
+	for _, vs := range u.Query() {
+		for _, v := range vs {
+			if strings.HasPrefix(v, refScheme) {
+				return v, true
+			}
+		}
+	}
+	for _, part := range []string{u.Path, u.Fragment} {
+		if i := strings.Index(part, refScheme); i >= 0 {
+			return part[i:], true
+		}
+	}
+	return "", false
+}
+
+// rehydrate restores every vaulted attribute in attrs. An attribute is
+// vaulted if it has a <key>.vault_ref or promptvault.ref.<key> companion
+// (all promptvault modes) or its own value is a reference or a link to one.
+// With span set, each reference must have been stored for that span and the
+// attribute's key. References that cannot be fetched or verified are left
+// in place.
+func (p *rehydrateProcessor) rehydrate(ctx context.Context, attrs pcommon.Map, span *promptvaultprocessor.ObjectMeta, cache map[string][]byte) {
+	type target struct {
+		key string
+		ref string
+		// written is the reference or link as it appears in the span.
+		written string
+	}
+	var targets []target
+	attrs.Range(func(key string, val pcommon.Value) bool {
+		if base, ok := refBase(key); ok {
+			ref, isRef := refFrom(val.Str())
+			if !isRef {
+				ref = val.Str()
+			}
+			targets = append(targets, target{key: base, ref: ref, written: val.Str()})
+		} else if val.Type() == pcommon.ValueTypeStr {
+			ref, isRef := refFrom(val.Str())
+			if _, companion := lookupCompanion(attrs, key, refAttrSuffix, refAttrPrefix); isRef && !companion {
+				targets = append(targets, target{key: key, ref: ref, written: val.Str()})
+			}
+		}
+		return true
+	})
+
+	for _, t := range targets {
+		if len(p.keysSet) > 0 && !p.keysSet[t.key] {
+			continue
+		}
+		var sig string
+		if v, ok := lookupCompanion(attrs, t.key, sigAttrSuffix, sigAttrPrefix); ok {
+			sig = v.Str()
+		}
+		var bound *promptvaultprocessor.ObjectMeta
+		if span != nil {
+			meta := *span
+			meta.Key = t.key
+			bound = &meta
+		}
+		content, err := p.fetch(ctx, t.ref, sig, bound, cache)
+		if err != nil {
+			p.logger.Warn("vault reference left in place",
+				zap.String("key", t.key),
+				zap.String("ref", t.ref),
+				zap.Error(err),
+			)
+			continue
+		}
+
+		value := string(content)
+		// In tokenize mode the span keeps the tokenized text and the vault
+		// holds the token mapping.
+		if cur, ok := attrs.Get(t.key); ok && cur.Str() != t.written {
+			if mapping, ok := tokenMapping(content); ok {
+				value = detokenize(cur.Str(), mapping)
+			}
+		}
+		attrs.PutStr(t.key, value)
+		attrs.Remove(t.key + refAttrSuffix)
+		attrs.Remove(t.key + sigAttrSuffix)
+		attrs.Remove(refAttrPrefix + t.key)
+		attrs.Remove(sigAttrPrefix + t.key)
+
+		p.logger.Debug("rehydrated attribute",
+			zap.String("key", t.key),
+			zap.String("ref", t.ref),
+			zap.Int("content_bytes", len(content)),
+		)
+	}
+}
+
+// rehydrateBody restores a log body that is itself a reference.
+func (p *rehydrateProcessor) rehydrateBody(ctx context.Context, body pcommon.Value, cache map[string][]byte) {
+	if body.Type() != pcommon.ValueTypeStr || !strings.HasPrefix(body.Str(), refScheme) {
+		return
+	}
+	ref := body.Str()
+	content, err := p.fetch(ctx, ref, "", nil, cache)
+	if err != nil {
+		p.logger.Warn("vault reference left in place", zap.String("ref", ref), zap.Error(err))
+		return
+	}
+	body.SetStr(string(content))
+}
+
+// fetch retrieves ref, once per batch and binding, checks it against the
+// size limit and sig, and runs the retrieve hooks. With bound set, ref must
+// have been stored for its trace, span, and key, which an encrypted vault
+// verifies.
+func (p *rehydrateProcessor) fetch(ctx context.Context, ref, sig string, bound *promptvaultprocessor.ObjectMeta, cache map[string][]byte) ([]byte, error) {
+	if sig == "" && p.config.RequireSignature {
+		return nil, errUnsigned
+	}
+	cacheKey := ref
+	if bound != nil {
+		cacheKey += "\n" + bound.TraceID + "/" + bound.SpanID + "/" + bound.Key
+	}
+	content, ok := cache[cacheKey]
+	if !ok {
+		var err error
+		if bound != nil {
+			content, err = promptvaultprocessor.RetrieveFor(ctx, p.vault, ref, *bound)
+		} else {
+			content, err = p.vault.Retrieve(ctx, ref)
+		}
+		if err != nil {
+			return nil, err
+		}
+		cache[cacheKey] = content
+	}
+	if p.config.MaxSize > 0 && len(content) > int(p.config.MaxSize) {
+		return nil, fmt.Errorf("content of %d bytes exceeds max_size %d", len(content), p.config.MaxSize)
+	}
+	if sig != "" && p.signer != nil {
+		if err := p.signer.Verify(ref, sig, content); err != nil {
+			return nil, err
+		}
+	}
+	if len(p.hooks) > 0 {
+		info, err := p.hooks.StatForHooks(ctx, p.vault, ref)
+		if err != nil {
+			return nil, err
+		}
+		return p.hooks.Apply(ctx, info, content)
+	}
+	return content, nil
+}
+
+// tokenMapping parses content as a tokenize-mode mapping of tokens to
+// original values.
+func tokenMapping(content []byte) (map[string]string, bool) {
+	var mapping map[string]string
+	if json.Unmarshal(content, &mapping) != nil || len(mapping) == 0 {
+		return nil, false
+	}
+	for tok := range mapping {
+		if !tokenPattern.MatchString(tok) {
+			return nil, false
+		}
+	}
+	return mapping, true
+}
+
+func detokenize(text string, mapping map[string]string) string {
+	pairs := make([]string, 0, 2*len(mapping))
+	for tok, value := range mapping {
+		pairs = append(pairs, tok, value)
+	}
+	return strings.NewReplacer(pairs...).Replace(text)
+}
@@ -0,0 +1,310 @@
+package promptvaultrehydrateprocessor
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/config/configopaque"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.opentelemetry.io/collector/processor"
+
+	"github.com/airblackbox/otel-prompt-vault/processor/promptvaultprocessor"
+)
+
+const testSigningKey = "0123456789abcdef0123456789abcdef"
+
+func nopSettings() processor.Settings {
+	return processor.Settings{
+		ID:                component.MustNewID(typeStr),
+		TelemetrySettings: componenttest.NewNopTelemetrySettings(),
+	}
+}
+
+// vaultSpan runs attrs through a promptvault processor in mode, writing to
+// the filesystem vault at dir, and returns the resulting span attributes.
+func vaultSpan(t *testing.T, dir, mode string, attrs map[string]string) ptrace.Traces {
+	t.Helper()
+	return vaultSpanWith(t, dir, mode, attrs, nil)
+}
+
+// vaultSpanWith is vaultSpan with the processor config adjusted by mutate.
+func vaultSpanWith(t *testing.T, dir, mode string, attrs map[string]string, mutate func(*promptvaultprocessor.Config)) ptrace.Traces {
+	t.Helper()
+	cfg := promptvaultprocessor.NewFactory().CreateDefaultConfig().(*promptvaultprocessor.Config)
+	cfg.Storage.Filesystem.BasePath = dir
+	cfg.Storage.Filesystem.Layout = "sharded"
+	cfg.Vault.Mode = mode
+	cfg.Vault.SigningKey = testSigningKey
+	cfg.Vault.Tokenization.Key = testSigningKey
+	cfg.Vault.Tokenization.Detectors.Email = true
+	cfg.Vault.KeptCopyTransform.MaxLength = 5
+	if mutate != nil {
+		mutate(cfg)
+	}
+
+	sink := new(consumertest.TracesSink)
+	proc, err := promptvaultprocessor.NewFactory().CreateTracesProcessor(context.Background(), nopSettings(), cfg, sink)
+	if err != nil {
+		t.Fatal(err)
+	}
+	td := ptrace.NewTraces()
+	span := td.ResourceSpans().AppendEmpty().ScopeSpans().AppendEmpty().Spans().AppendEmpty()
+	for k, v := range attrs {
+		span.Attributes().PutStr(k, v)
+	}
+	if err := proc.ConsumeTraces(context.Background(), td); err != nil {
+		t.Fatal(err)
+	}
+	return sink.AllTraces()[0]
+}
+
+func newTestProcessor(t *testing.T, dir string, mutate func(*Config)) (*rehydrateProcessor, *consumertest.TracesSink, *consumertest.LogsSink) {
+	t.Helper()
+	cfg := createDefaultConfig()
+	cfg.Storage.Filesystem.BasePath = dir
+	cfg.Storage.Filesystem.Layout = "sharded"
+	cfg.SigningKey = testSigningKey
+	if mutate != nil {
+		mutate(cfg)
+	}
+	traces, logs := new(consumertest.TracesSink), new(consumertest.LogsSink)
+	tp, err := NewFactory().CreateTracesProcessor(context.Background(), nopSettings(), cfg, traces)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := tp.(*rehydrateProcessor)
+	p.nextLogs = logs
+	t.Cleanup(func() { p.Shutdown(context.Background()) })
+	return p, traces, logs
+}
+
+func spanAttrs(td ptrace.Traces) pcommon.Map {
+	return td.ResourceSpans().At(0).ScopeSpans().At(0).Spans().At(0).Attributes()
+}
+
+func init() {
+	promptvaultprocessor.RegisterRetrieveHook("test_upper", func(map[string]any) (promptvaultprocessor.RetrieveHook, error) {
+		return promptvaultprocessor.RetrieveHookFunc(func(_ context.Context, _ promptvaultprocessor.ObjectInfo, data []byte) ([]byte, error) {
+			return bytes.ToUpper(data), nil
+		}), nil
+	})
+}
+
+func TestRunsRetrieveHooks(t *testing.T) {
+	dir := t.TempDir()
+	td := vaultSpan(t, dir, "replace_with_ref", map[string]string{"gen_ai.prompt": "what is a qubit?"})
+	p, sink, _ := newTestProcessor(t, dir, func(cfg *Config) {
+		cfg.Hooks = []promptvaultprocessor.RetrieveHookConfig{{Name: "test_upper"}}
+	})
+	if err := p.ConsumeTraces(context.Background(), td); err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := spanAttrs(sink.AllTraces()[0]).Get("gen_ai.prompt"); v.Str() != "WHAT IS A QUBIT?" {
+		t.Errorf("gen_ai.prompt = %q, want the hook's output", v.Str())
+	}
+}
+
+func TestRehydratesLinks(t *testing.T) {
+	prompt := "Email alice@example.com about the renewal"
+	for _, tmpl := range []string{"https://vault.internal/view?uri={uri}", "https://vault.internal/objects/{ref}"} {
+		for _, mode := range []string{"replace_with_ref", "tokenize"} {
+			dir := t.TempDir()
+			td := vaultSpanWith(t, dir, mode, map[string]string{"gen_ai.prompt": prompt}, func(cfg *promptvaultprocessor.Config) {
+				cfg.Vault.LinkTemplate = tmpl
+				cfg.Vault.RefFormat = "link"
+			})
+			if v, _ := spanAttrs(td).Get("gen_ai.prompt.vault_ref"); !strings.HasPrefix(v.Str(), "https://") {
+				t.Fatalf("%s, %s: vault_ref = %q, want a link", tmpl, mode, v.Str())
+			}
+
+			p, sink, _ := newTestProcessor(t, dir, nil)
+			if err := p.ConsumeTraces(context.Background(), td); err != nil {
+				t.Fatal(err)
+			}
+			if v, _ := spanAttrs(sink.AllTraces()[0]).Get("gen_ai.prompt"); v.Str() != prompt {
+				t.Errorf("%s, %s: gen_ai.prompt = %q, want %q", tmpl, mode, v.Str(), prompt)
+			}
+		}
+	}
+}
+
+func TestRehydratesEveryMode(t *testing.T) {
+	prompt := "Email alice@example.com about the renewal"
+	for _, mode := range []string{"replace_with_ref", "remove", "keep_and_ref", "tokenize"} {
+		t.Run(mode, func(t *testing.T) {
+			dir := t.TempDir()
+			td := vaultSpan(t, dir, mode, map[string]string{"gen_ai.prompt": prompt})
+			if v, _ := spanAttrs(td).Get("gen_ai.prompt"); v.Str() == prompt {
+				t.Fatalf("%s left the prompt in the span", mode)
+			}
+
+			p, sink, _ := newTestProcessor(t, dir, nil)
+			if err := p.ConsumeTraces(context.Background(), td); err != nil {
+				t.Fatal(err)
+			}
+			attrs := spanAttrs(sink.AllTraces()[0])
+			if v, _ := attrs.Get("gen_ai.prompt"); v.Str() != prompt {
+				t.Errorf("gen_ai.prompt = %q, want %q", v.Str(), prompt)
+			}
+			for _, k := range []string{"gen_ai.prompt.vault_ref", "gen_ai.prompt.vault_sig"} {
+				if _, ok := attrs.Get(k); ok {
+					t.Errorf("%s not removed", k)
+				}
+			}
+		})
+	}
+}
+
+func TestRehydratesDatadogCompatibility(t *testing.T) {
+	dir := t.TempDir()
+	td := vaultSpanWith(t, dir, "remove", map[string]string{"gen_ai.prompt": "prompt"}, func(cfg *promptvaultprocessor.Config) {
+		cfg.Vault.Compatibility = "datadog"
+	})
+	if _, ok := spanAttrs(td).Get("promptvault.sig.gen_ai.prompt"); !ok {
+		t.Fatal("no promptvault.sig.gen_ai.prompt")
+	}
+
+	p, sink, _ := newTestProcessor(t, dir, func(cfg *Config) { cfg.RequireSignature = true })
+	if err := p.ConsumeTraces(context.Background(), td); err != nil {
+		t.Fatal(err)
+	}
+	attrs := spanAttrs(sink.AllTraces()[0])
+	if v, _ := attrs.Get("gen_ai.prompt"); v.Str() != "prompt" {
+		t.Errorf("gen_ai.prompt = %q, want %q", v.Str(), "prompt")
+	}
+	for _, k := range []string{"promptvault.ref.gen_ai.prompt", "promptvault.sig.gen_ai.prompt"} {
+		if _, ok := attrs.Get(k); ok {
+			t.Errorf("%s not removed", k)
+		}
+	}
+}
+
+func TestRehydrationIsBoundToTheSpan(t *testing.T) {
+	keys := []promptvaultprocessor.KeyConfig{
+		{ID: "k1", Key: configopaque.String(base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{7}, 32)))},
+	}
+	dir := t.TempDir()
+	td := vaultSpanWith(t, dir, "replace_with_ref", map[string]string{"gen_ai.prompt": "prompt"}, func(cfg *promptvaultprocessor.Config) {
+		cfg.Crypto.Provider = "local"
+		cfg.Crypto.Local.Keys = keys
+	})
+	// Copy the reference and its signature onto another span.
+	spans := td.ResourceSpans().At(0).ScopeSpans().At(0).Spans()
+	copied := spans.AppendEmpty()
+	spans.At(0).CopyTo(copied)
+	copied.SetSpanID(pcommon.SpanID{2})
+	ref, _ := copied.Attributes().Get("gen_ai.prompt")
+	refStr := ref.Str()
+
+	p, sink, _ := newTestProcessor(t, dir, func(cfg *Config) {
+		cfg.Crypto.Provider = "local"
+		cfg.Crypto.Local.Keys = keys
+	})
+	if err := p.ConsumeTraces(context.Background(), td); err != nil {
+		t.Fatal(err)
+	}
+	out := sink.AllTraces()[0].ResourceSpans().At(0).ScopeSpans().At(0).Spans()
+	if v, _ := out.At(0).Attributes().Get("gen_ai.prompt"); v.Str() != "prompt" {
+		t.Errorf("gen_ai.prompt = %q on the span the reference was stored for", v.Str())
+	}
+	if v, _ := out.At(1).Attributes().Get("gen_ai.prompt"); v.Str() != refStr {
+		t.Errorf("copied reference = %q, want it left in place", v.Str())
+	}
+}
+
+func TestLeavesUnverifiedReferences(t *testing.T) {
+	dir := t.TempDir()
+	td := vaultSpan(t, dir, "replace_with_ref", map[string]string{"gen_ai.prompt": "signed", "gen_ai.completion": "forged"})
+	attrs := spanAttrs(td)
+	ref, _ := attrs.Get("gen_ai.completion")
+	attrs.PutStr("gen_ai.completion.vault_sig", "AAAA")
+
+	p, sink, _ := newTestProcessor(t, dir, nil)
+	if err := p.ConsumeTraces(context.Background(), td); err != nil {
+		t.Fatal(err)
+	}
+	out := spanAttrs(sink.AllTraces()[0])
+	if v, _ := out.Get("gen_ai.prompt"); v.Str() != "signed" {
+		t.Errorf("signed reference not rehydrated: %q", v.Str())
+	}
+	if v, _ := out.Get("gen_ai.completion"); v.Str() != ref.Str() {
+		t.Errorf("reference with a bad signature was rehydrated: %q", v.Str())
+	}
+	if _, ok := out.Get("gen_ai.completion.vault_ref"); !ok {
+		t.Error("companion attributes of an unverified reference were removed")
+	}
+}
+
+func TestRequireSignatureAndKeys(t *testing.T) {
+	dir := t.TempDir()
+	td := vaultSpan(t, dir, "replace_with_ref", map[string]string{"gen_ai.prompt": "prompt", "gen_ai.completion": "completion"})
+	attrs := spanAttrs(td)
+	attrs.Remove("gen_ai.prompt.vault_sig")
+	completionRef, _ := attrs.Get("gen_ai.completion")
+	completionRefStr := completionRef.Str()
+
+	p, sink, _ := newTestProcessor(t, dir, func(cfg *Config) {
+		cfg.RequireSignature = true
+		cfg.Keys = []string{"gen_ai.prompt"}
+	})
+	if err := p.ConsumeTraces(context.Background(), td); err != nil {
+		t.Fatal(err)
+	}
+	out := spanAttrs(sink.AllTraces()[0])
+	if v, _ := out.Get("gen_ai.prompt"); v.Str() == "prompt" {
+		t.Error("unsigned reference rehydrated with require_signature")
+	}
+	if v, _ := out.Get("gen_ai.completion"); v.Str() != completionRefStr {
+		t.Error("key outside keys rehydrated")
+	}
+}
+
+func TestRehydratesLogs(t *testing.T) {
+	dir := t.TempDir()
+	vault, err := promptvaultprocessor.NewFilesystemVault(promptvaultprocessor.FilesystemConfig{BasePath: dir, Layout: "sharded"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bodyRef, _ := vault.Store(context.Background(), promptvaultprocessor.ObjectMeta{}, []byte("full log body"))
+	attrRef, _ := vault.Store(context.Background(), promptvaultprocessor.ObjectMeta{}, []byte("request payload"))
+
+	ld := plog.NewLogs()
+	record := ld.ResourceLogs().AppendEmpty().ScopeLogs().AppendEmpty().LogRecords().AppendEmpty()
+	record.Body().SetStr(bodyRef)
+	record.Attributes().PutStr("http.request.body", attrRef)
+
+	p, _, sink := newTestProcessor(t, dir, func(cfg *Config) { cfg.SigningKey = "" })
+	if err := p.ConsumeLogs(context.Background(), ld); err != nil {
+		t.Fatal(err)
+	}
+	out := sink.AllLogs()[0].ResourceLogs().At(0).ScopeLogs().At(0).LogRecords().At(0)
+	if out.Body().Str() != "full log body" {
+		t.Errorf("body = %q", out.Body().Str())
+	}
+	if v, _ := out.Attributes().Get("http.request.body"); v.Str() != "request payload" {
+		t.Errorf("http.request.body = %q", v.Str())
+	}
+}
+
+func TestFactoryValidates(t *testing.T) {
+	for name, mutate := range map[string]func(*Config){
+		"require_signature without key": func(cfg *Config) { cfg.RequireSignature = true },
+		"short signing key":             func(cfg *Config) { cfg.SigningKey = "short" },
+		"negative max_size":             func(cfg *Config) { cfg.MaxSize = -1 },
+	} {
+		cfg := createDefaultConfig()
+		cfg.Storage.Filesystem.BasePath = t.TempDir()
+		mutate(cfg)
+		if _, err := NewFactory().CreateTracesProcessor(context.Background(), nopSettings(), cfg, new(consumertest.TracesSink)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
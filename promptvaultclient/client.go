@@ -0,0 +1,351 @@
+// Package promptvaultclient reads the content behind vault references for
+// programs that consume spans, either straight from the vault's storage or
+// through the retrieval API.
+package promptvaultclient
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/collector/config/configopaque"
+	"go.uber.org/zap"
+
+	"github.com/airblackbox/otel-prompt-vault/processor/promptvaultprocessor"
+)
+
+const refScheme = "vault://"
+
+// ErrUnsigned is returned, wrapped, for a reference without a signature
+// when Config.RequireSignature is set.
+var ErrUnsigned = errors.New("vault reference is not signed")
+
+// Reference is a vault reference and, for vaults that sign references, its
+// signature.
+type Reference struct {
+	Ref string `json:"ref"`
+	Sig string `json:"sig,omitempty"`
+}
+
+// String returns the reference's vault:// URI.
+func (r Reference) String() string {
+	return r.Ref
+}
+
+// ParseReference accepts a reference as spans and tools carry it: a
+// vault:// URI, a reference without its scheme, a link to the retrieval
+// API or web UI (vault.ref_format: link), or a JSON object with a "ref"
+// field, such as a GET /v1/objects entry or an audit record. A "sig" query
+// parameter or field is kept as the signature.
+func ParseReference(s string) (Reference, error) {
+	s = strings.TrimSpace(s)
+	var r Reference
+	switch {
+	case strings.HasPrefix(s, "{"):
+		if err := json.Unmarshal([]byte(s), &r); err != nil {
+			return r, fmt.Errorf("parse vault reference: %w", err)
+		}
+	case strings.HasPrefix(s, "http://"), strings.HasPrefix(s, "https://"):
+		u, err := url.Parse(s)
+		if err != nil {
+			return r, fmt.Errorf("parse vault reference: %w", err)
+		}
+		r.Ref, r.Sig = refFromLink(u), u.Query().Get("sig")
+		if r.Ref == "" {
+			return r, fmt.Errorf("parse vault reference: link %s carries no reference", u.Redacted())
+		}
+	default:
+		r.Ref = s
+	}
+	ref, err := normalizeRef(r.Ref)
+	if err != nil {
+		return r, err
+	}
+	r.Ref = ref
+	return r, nil
+}
+
+// refFromLink finds the reference in a link: a query parameter holding a
+// vault:// URI, or a path or fragment containing one.
+func refFromLink(u *url.URL) string {
+	for _, vs := range u.Query() {
+		for _, v := range vs {
+			if strings.HasPrefix(v, refScheme) {
+				return v
+			}
+		}
+	}
+	for _, part := range []string{u.Path, u.Fragment} {
+		if i := strings.Index(part, refScheme); i >= 0 {
+			return part[i:]
+		}
+	}
+	// The retrieval API's own paths carry the reference without its scheme.
+	if rest, ok := strings.CutPrefix(u.Path, "/v1/vault/"); ok {
+		return rest
+	}
+	return ""
+}
+
+// normalizeRef returns ref as a vault:// URI. Path cleaning may have
+// collapsed the URI's double slash.
+func normalizeRef(ref string) (string, error) {
+	s := ref
+	for _, prefix := range []string{refScheme, "vault:/"} {
+		if rest, ok := strings.CutPrefix(s, prefix); ok {
+			s = rest
+			break
+		}
+	}
+	if s == "" || strings.HasPrefix(s, "/") {
+		return "", fmt.Errorf("invalid vault reference %q", ref)
+	}
+	for _, seg := range strings.Split(s, "/") {
+		if seg == "" || seg == "." || seg == ".." {
+			return "", fmt.Errorf("invalid vault reference %q", ref)
+		}
+	}
+	return refScheme + s, nil
+}
+
+// Config selects where a Client reads from. Exactly one of Vault and
+// Endpoint must be set.
+type Config struct {
+	// Vault reads straight from storage, configured like the promptvault
+	// processor. Objects are decrypted and decompressed with its crypto and
+	// compression settings, and checksums are verified.
+	Vault *promptvaultprocessor.Config
+	// Endpoint is the base URL of the retrieval API, e.g.
+	// https://vault.internal:8443. The content's SHA-256 is checked against
+	// the X-Vault-Sha256 header.
+	Endpoint string
+	// APIKey authenticates to the retrieval API as X-API-Key.
+	APIKey configopaque.String
+	// HTTPClient makes retrieval API requests. Defaults to
+	// http.DefaultClient.
+	HTTPClient *http.Client
+	// SigningKey verifies each signed reference against the content it
+	// resolves to, so that a forged or rewritten reference fails instead of
+	// returning whatever it points at. Must match the promptvault
+	// processor's vault.signing_key; defaults to Vault's.
+	SigningKey configopaque.String
+	// RequireSignature also rejects references without a signature.
+	RequireSignature bool
+}
+
+// Client reads vault content. It is safe for concurrent use.
+type Client struct {
+	vault    promptvaultprocessor.VaultStorage
+	endpoint *url.URL
+	apiKey   string
+	http     *http.Client
+	signer   *promptvaultprocessor.RefSigner
+	require  bool
+}
+
+// New opens the vault or prepares requests to the retrieval API cfg
+// describes. The caller must Close the client.
+func New(ctx context.Context, logger *zap.Logger, cfg Config) (*Client, error) {
+	if (cfg.Vault == nil) == (cfg.Endpoint == "") {
+		return nil, fmt.Errorf("promptvaultclient: set exactly one of Vault and Endpoint")
+	}
+	key := cfg.SigningKey
+	if key == "" && cfg.Vault != nil {
+		key = cfg.Vault.Vault.SigningKey
+	}
+	signer, err := promptvaultprocessor.NewRefSigner(key)
+	if err != nil {
+		return nil, fmt.Errorf("promptvaultclient: %w", err)
+	}
+	if cfg.RequireSignature && signer == nil {
+		return nil, fmt.Errorf("promptvaultclient: RequireSignature needs a signing key")
+	}
+	if cfg.Vault != nil {
+		vault, err := promptvaultprocessor.OpenVault(ctx, logger, cfg.Vault)
+		if err != nil {
+			return nil, err
+		}
+		return &Client{vault: vault, signer: signer, require: cfg.RequireSignature}, nil
+	}
+	u, err := url.Parse(cfg.Endpoint)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("promptvaultclient: invalid endpoint %q", cfg.Endpoint)
+	}
+	hc := cfg.HTTPClient
+	if hc == nil {
+		hc = http.DefaultClient
+	}
+	return &Client{endpoint: u, apiKey: string(cfg.APIKey), http: hc, signer: signer, require: cfg.RequireSignature}, nil
+}
+
+// Get returns the content behind ref, in any form ParseReference accepts.
+// Missing objects return an error wrapping
+// promptvaultprocessor.ErrObjectNotFound, content that fails verification
+// one wrapping promptvaultprocessor.ErrChecksumMismatch, and references
+// whose signature does not match one wrapping
+// promptvaultprocessor.ErrInvalidRefSignature.
+func (c *Client) Get(ctx context.Context, ref string) ([]byte, error) {
+	r, err := ParseReference(ref)
+	if err != nil {
+		return nil, err
+	}
+	return c.GetReference(ctx, r)
+}
+
+// GetReference returns the content behind r. With a signing key, r.Sig is
+// checked against the content before it is returned; a signature that is
+// not even well-formed fails before anything is fetched. Without one,
+// reading straight from storage does not check r.Sig, and the retrieval
+// API does when it requires signatures.
+func (c *Client) GetReference(ctx context.Context, r Reference) ([]byte, error) {
+	if c.signer != nil {
+		switch sig, err := base64.RawURLEncoding.DecodeString(r.Sig); {
+		case r.Sig == "" && c.require:
+			return nil, fmt.Errorf("get %s: %w", r.Ref, ErrUnsigned)
+		case r.Sig != "" && (err != nil || len(sig) != sha256.Size):
+			return nil, fmt.Errorf("get %s: %w", r.Ref, promptvaultprocessor.ErrInvalidRefSignature)
+		}
+	}
+	var content []byte
+	var err error
+	if c.vault != nil {
+		content, err = c.vault.Retrieve(ctx, r.Ref)
+	} else {
+		content, err = c.fetch(ctx, r)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if c.signer != nil && r.Sig != "" {
+		if err := c.signer.Verify(r.Ref, r.Sig, content); err != nil {
+			return nil, fmt.Errorf("get %s: %w", r.Ref, err)
+		}
+	}
+	return content, nil
+}
+
+// Lookup returns the reference of the object stored for key on the span
+// spanID of trace traceID, for when the span's reference attribute is not
+// at hand. Reading from storage finds it through storage.index, or by
+// listing the backend; through the retrieval API, among the first 1000 of
+// the trace's objects listed by GET /v1/objects. If the span stored key
+// more than once, the latest is returned. The reference has no signature.
+func (c *Client) Lookup(ctx context.Context, traceID, spanID, key string) (Reference, error) {
+	if traceID == "" || spanID == "" || key == "" {
+		return Reference{}, fmt.Errorf("lookup: trace ID, span ID, and key are all required")
+	}
+	var found Reference
+	var latest time.Time
+	match := func(gotSpan, gotKey, ref string, storedAt time.Time) {
+		if gotSpan == spanID && gotKey == key && (found.Ref == "" || storedAt.After(latest)) {
+			found.Ref, latest = ref, storedAt
+		}
+	}
+	if c.vault != nil {
+		f := promptvaultprocessor.ObjectFilter{TraceID: traceID, Key: key}
+		err := promptvaultprocessor.SearchObjects(ctx, c.vault, f, func(info promptvaultprocessor.ObjectInfo) error {
+			match(info.Meta.SpanID, info.Meta.Key, info.Ref, info.StoredAt)
+			return nil
+		})
+		if err != nil {
+			return Reference{}, fmt.Errorf("lookup: %w", err)
+		}
+	} else {
+		objects, err := c.list(ctx, traceID)
+		if err != nil {
+			return Reference{}, err
+		}
+		for _, o := range objects {
+			match(o.SpanID, o.Key, o.Ref, o.StoredAt)
+		}
+	}
+	if found.Ref == "" {
+		return Reference{}, fmt.Errorf("lookup %s/%s/%s: %w", traceID, spanID, key, promptvaultprocessor.ErrObjectNotFound)
+	}
+	return found, nil
+}
+
+// listedObject is the part of a GET /v1/objects entry Lookup reads.
+type listedObject struct {
+	Ref      string    `json:"ref"`
+	SpanID   string    `json:"span_id"`
+	Key      string    `json:"key"`
+	StoredAt time.Time `json:"stored_at"`
+}
+
+func (c *Client) list(ctx context.Context, traceID string) ([]listedObject, error) {
+	u := c.endpoint.JoinPath("v1", "objects")
+	u.RawQuery = url.Values{"trace_id": {traceID}, "limit": {"1000"}}.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	if c.apiKey != "" {
+		req.Header.Set("X-API-Key", c.apiKey)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("lookup: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<10))
+		return nil, fmt.Errorf("lookup: %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	var listing struct {
+		Objects []listedObject `json:"objects"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&listing); err != nil {
+		return nil, fmt.Errorf("lookup: %w", err)
+	}
+	return listing.Objects, nil
+}
+
+func (c *Client) fetch(ctx context.Context, r Reference) ([]byte, error) {
+	u := c.endpoint.JoinPath("v1", "vault", strings.TrimPrefix(r.Ref, refScheme))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	if c.apiKey != "" {
+		req.Header.Set("X-API-Key", c.apiKey)
+	}
+	if r.Sig != "" {
+		req.Header.Set("X-Vault-Signature", r.Sig)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("get %s: %w", r.Ref, err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("get %s: %w", r.Ref, err)
+	}
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, fmt.Errorf("get %s: %w", r.Ref, promptvaultprocessor.ErrObjectNotFound)
+	case resp.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("get %s: %s: %s", r.Ref, resp.Status, strings.TrimSpace(string(body)))
+	}
+	if want := resp.Header.Get("X-Vault-Sha256"); want != fmt.Sprintf("%x", sha256.Sum256(body)) {
+		return nil, fmt.Errorf("get %s: %w", r.Ref, promptvaultprocessor.ErrChecksumMismatch)
+	}
+	return body, nil
+}
+
+// Close releases the client's storage connections.
+func (c *Client) Close() error {
+	if closer, ok := c.vault.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
@@ -0,0 +1,240 @@
+package promptvaultclient
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"go.opentelemetry.io/collector/config/configopaque"
+	"go.uber.org/zap"
+
+	"github.com/airblackbox/otel-prompt-vault/processor/promptvaultprocessor"
+	"github.com/airblackbox/otel-prompt-vault/retrieval"
+)
+
+func TestParseReference(t *testing.T) {
+	const ref = "vault://2024/06/01/abc123"
+	for _, tc := range []struct {
+		in       string
+		want     string
+		wantSig  string
+		wantFail bool
+	}{
+		{in: ref, want: ref},
+		{in: "  2024/06/01/abc123\n", want: ref},
+		{in: "vault:/2024/06/01/abc123", want: ref},
+		{in: `{"ref": "vault://2024/06/01/abc123", "size": 3}`, want: ref},
+		{in: `{"ref": "vault://2024/06/01/abc123", "sig": "s1"}`, want: ref, wantSig: "s1"},
+		{in: "https://vault.example.com/ui?ref=vault%3A%2F%2F2024%2F06%2F01%2Fabc123&sig=s2", want: ref, wantSig: "s2"},
+		{in: "https://vault.example.com/v1/vault/2024/06/01/abc123", want: ref},
+		{in: "https://vault.example.com/#vault://2024/06/01/abc123", want: ref},
+		{in: "https://example.com/other", wantFail: true},
+		{in: "vault://../etc/passwd", wantFail: true},
+		{in: "", wantFail: true},
+		{in: `{"ref": 1}`, wantFail: true},
+	} {
+		got, err := ParseReference(tc.in)
+		if tc.wantFail {
+			if err == nil {
+				t.Errorf("ParseReference(%q) = %+v, want error", tc.in, got)
+			}
+			continue
+		}
+		if err != nil || got.Ref != tc.want || got.Sig != tc.wantSig {
+			t.Errorf("ParseReference(%q) = %+v, %v; want %s sig %q", tc.in, got, err, tc.want, tc.wantSig)
+		}
+	}
+}
+
+func TestClientReadsFromStorage(t *testing.T) {
+	ctx := context.Background()
+	cfg := promptvaultprocessor.NewFactory().CreateDefaultConfig().(*promptvaultprocessor.Config)
+	cfg.Storage.Filesystem.BasePath = filepath.Join(t.TempDir(), "objects")
+	cfg.Crypto.Provider = "local"
+	cfg.Crypto.Local.Keys = []promptvaultprocessor.KeyConfig{
+		{ID: "k1", Key: configopaque.String(base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{7}, 32)))},
+	}
+	vault, err := promptvaultprocessor.OpenVault(ctx, zap.NewNop(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref, err := vault.Store(ctx, promptvaultprocessor.ObjectMeta{Key: "gen_ai.prompt"}, []byte("hello"))
+	vault.(io.Closer).Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := New(ctx, zap.NewNop(), Config{Vault: cfg})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	got, err := c.Get(ctx, `{"ref": "`+ref+`"}`)
+	if err != nil || string(got) != "hello" {
+		t.Fatalf("Get = %q, %v", got, err)
+	}
+	missing := fmt.Sprintf("vault://%x", sha256.Sum256([]byte("never stored")))
+	if _, err := c.Get(ctx, missing); !errors.Is(err, promptvaultprocessor.ErrObjectNotFound) {
+		t.Errorf("missing object: got %v, want ErrObjectNotFound", err)
+	}
+}
+
+func TestClientReadsFromRetrievalAPI(t *testing.T) {
+	ctx := context.Background()
+	vault := promptvaultprocessor.NewMemoryVault(0, 0)
+	ref, _ := vault.Store(ctx, promptvaultprocessor.ObjectMeta{Key: "gen_ai.prompt"}, []byte("hello"))
+	auth, err := retrieval.NewAPIKeyAuthenticator(retrieval.AuthConfig{APIKeys: []retrieval.APIKeyConfig{
+		{Name: "reader", Key: "reader-key-0123456789"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := retrieval.NewHandler(zap.NewNop(), vault, nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(retrieval.RequireAuth(auth, h))
+	defer srv.Close()
+
+	c, err := New(ctx, zap.NewNop(), Config{Endpoint: srv.URL, APIKey: "reader-key-0123456789"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := c.Get(ctx, ref)
+	if err != nil || string(got) != "hello" {
+		t.Fatalf("Get = %q, %v", got, err)
+	}
+	if _, err := c.Get(ctx, "vault://missing"); !errors.Is(err, promptvaultprocessor.ErrObjectNotFound) {
+		t.Errorf("missing object: got %v, want ErrObjectNotFound", err)
+	}
+
+	// Content altered in transit fails verification.
+	tamper := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Vault-Sha256", "0000")
+		w.Write([]byte("hello"))
+	}))
+	defer tamper.Close()
+	c, _ = New(ctx, zap.NewNop(), Config{Endpoint: tamper.URL})
+	if _, err := c.Get(ctx, ref); !errors.Is(err, promptvaultprocessor.ErrChecksumMismatch) {
+		t.Errorf("tampered content: got %v, want ErrChecksumMismatch", err)
+	}
+}
+
+func TestClientVerifiesSignatures(t *testing.T) {
+	ctx := context.Background()
+	const key = "signing-key-0123456789-0123456789"
+	vault := promptvaultprocessor.NewMemoryVault(0, 0)
+	ref, _ := vault.Store(ctx, promptvaultprocessor.ObjectMeta{Key: "gen_ai.prompt"}, []byte("hello"))
+	other, _ := vault.Store(ctx, promptvaultprocessor.ObjectMeta{Key: "gen_ai.prompt"}, []byte("other"))
+	signer, _ := promptvaultprocessor.NewRefSigner(key)
+	sig := signer.Sign(ref, fmt.Sprintf("%x", sha256.Sum256([]byte("hello"))), len("hello"))
+	auth, err := retrieval.NewAPIKeyAuthenticator(retrieval.AuthConfig{APIKeys: []retrieval.APIKeyConfig{
+		{Name: "reader", Key: "reader-key-0123456789"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := retrieval.NewHandler(zap.NewNop(), vault, nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(retrieval.RequireAuth(auth, h))
+	defer srv.Close()
+
+	c, err := New(ctx, zap.NewNop(), Config{Endpoint: srv.URL, APIKey: "reader-key-0123456789", SigningKey: key})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := c.GetReference(ctx, Reference{Ref: ref, Sig: sig}); err != nil || string(got) != "hello" {
+		t.Fatalf("signed reference: got %q, %v", got, err)
+	}
+	// A signature moved to another reference, or mangled, is rejected.
+	for _, r := range []Reference{{Ref: other, Sig: sig}, {Ref: ref, Sig: "not-a-signature"}} {
+		if _, err := c.GetReference(ctx, r); !errors.Is(err, promptvaultprocessor.ErrInvalidRefSignature) {
+			t.Errorf("%+v: got %v, want ErrInvalidRefSignature", r, err)
+		}
+	}
+	// Unsigned references are read unless signatures are required.
+	if _, err := c.Get(ctx, ref); err != nil {
+		t.Errorf("unsigned reference: %v", err)
+	}
+	c, _ = New(ctx, zap.NewNop(), Config{Endpoint: srv.URL, APIKey: "reader-key-0123456789", SigningKey: key, RequireSignature: true})
+	if _, err := c.Get(ctx, ref); !errors.Is(err, ErrUnsigned) {
+		t.Errorf("unsigned reference: got %v, want ErrUnsigned", err)
+	}
+	if _, err := New(ctx, zap.NewNop(), Config{Endpoint: srv.URL, RequireSignature: true}); err == nil {
+		t.Error("RequireSignature without a key: want error")
+	}
+}
+
+func TestClientLookup(t *testing.T) {
+	ctx := context.Background()
+	cfg := promptvaultprocessor.NewFactory().CreateDefaultConfig().(*promptvaultprocessor.Config)
+	cfg.Storage.Filesystem.BasePath = filepath.Join(t.TempDir(), "objects")
+	cfg.Storage.Index.Path = filepath.Join(t.TempDir(), "index.db")
+	vault, err := promptvaultprocessor.OpenVault(ctx, zap.NewNop(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mem := promptvaultprocessor.NewMemoryVault(0, 0)
+	var want string
+	for _, s := range []struct {
+		span, key, content string
+	}{
+		{"span1", "gen_ai.prompt", "prompt"},
+		{"span1", "gen_ai.completion", "completion"},
+		{"span2", "gen_ai.prompt", "other prompt"},
+	} {
+		meta := promptvaultprocessor.ObjectMeta{TraceID: "trace1", SpanID: s.span, Key: s.key}
+		ref, err := vault.Store(ctx, meta, []byte(s.content))
+		if err != nil {
+			t.Fatal(err)
+		}
+		mem.Store(ctx, meta, []byte(s.content))
+		if s.span == "span1" && s.key == "gen_ai.completion" {
+			want = ref
+		}
+	}
+	vault.(io.Closer).Close()
+
+	auth, err := retrieval.NewAPIKeyAuthenticator(retrieval.AuthConfig{APIKeys: []retrieval.APIKeyConfig{
+		{Name: "reader", Key: "reader-key-0123456789"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := retrieval.NewHandler(zap.NewNop(), mem, nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(retrieval.RequireAuth(auth, h))
+	defer srv.Close()
+
+	for name, cc := range map[string]Config{
+		"storage": {Vault: cfg},
+		"api":     {Endpoint: srv.URL, APIKey: "reader-key-0123456789"},
+	} {
+		c, err := New(ctx, zap.NewNop(), cc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r, err := c.Lookup(ctx, "trace1", "span1", "gen_ai.completion")
+		if err != nil || r.Ref != want {
+			t.Errorf("%s: Lookup = %+v, %v; want %s", name, r, err, want)
+		} else if got, err := c.GetReference(ctx, r); err != nil || string(got) != "completion" {
+			t.Errorf("%s: GetReference = %q, %v", name, got, err)
+		}
+		if _, err := c.Lookup(ctx, "trace1", "span2", "gen_ai.completion"); !errors.Is(err, promptvaultprocessor.ErrObjectNotFound) {
+			t.Errorf("%s: missing object: got %v, want ErrObjectNotFound", name, err)
+		}
+		c.Close()
+	}
+}
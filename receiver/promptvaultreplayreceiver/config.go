@@ -0,0 +1,44 @@
+// Package promptvaultreplayreceiver emits stored vault content as OTLP logs
+// carrying the original trace and span IDs, for backfilling a new logging
+// backend or feeding offline evaluation pipelines.
+package promptvaultreplayreceiver
+
+import (
+	"time"
+
+	"github.com/airblackbox/otel-prompt-vault/processor/promptvaultprocessor"
+)
+
+// Config for the replay receiver.
+type Config struct {
+	// Storage and Crypto locate and decrypt the vault. They take the same
+	// options as the promptvault processor and must match its settings.
+	Storage promptvaultprocessor.StorageConfig `mapstructure:"storage"`
+	Crypto  promptvaultprocessor.CryptoConfig  `mapstructure:"crypto"`
+	// StartTime and EndTime bound the objects replayed by store time, as
+	// RFC 3339 timestamps. Zero = unbounded.
+	StartTime time.Time `mapstructure:"start_time"`
+	EndTime   time.Time `mapstructure:"end_time"`
+	// ManifestDir, when set, replays the objects the manifest in this
+	// directory records as live, in the order they were stored, instead of
+	// listing the backend. Required for backends that cannot list.
+	ManifestDir string `mapstructure:"manifest_dir"`
+	// Keys restricts replay to objects stored for these attribute keys.
+	// Empty replays every object except audit records.
+	Keys []string `mapstructure:"keys"`
+	// BatchSize is the number of log records per emitted batch.
+	BatchSize int `mapstructure:"batch_size"`
+	// Audit records every retrieval, attributed to this component's ID.
+	Audit promptvaultprocessor.AuditConfig `mapstructure:"audit"`
+}
+
+// DefaultConfig reads from wherever the promptvault processor writes by
+// default, in batches of 100.
+func DefaultConfig() *Config {
+	vaultCfg := promptvaultprocessor.NewFactory().CreateDefaultConfig().(*promptvaultprocessor.Config)
+	return &Config{
+		Storage:   vaultCfg.Storage,
+		Crypto:    vaultCfg.Crypto,
+		BatchSize: 100,
+	}
+}
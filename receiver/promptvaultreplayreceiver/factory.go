@@ -0,0 +1,32 @@
+package promptvaultreplayreceiver
+
+import (
+	"context"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/consumer"
+	"go.opentelemetry.io/collector/receiver"
+)
+
+const (
+	typeStr   = "promptvaultreplay"
+	stability = component.StabilityLevelAlpha
+)
+
+// NewFactory creates a factory for the replay receiver.
+func NewFactory() receiver.Factory {
+	return receiver.NewFactory(
+		component.MustNewType(typeStr),
+		func() component.Config { return DefaultConfig() },
+		receiver.WithLogs(createLogsReceiver, stability),
+	)
+}
+
+func createLogsReceiver(
+	ctx context.Context,
+	set receiver.Settings,
+	cfg component.Config,
+	nextConsumer consumer.Logs,
+) (receiver.Logs, error) {
+	return NewReceiver(ctx, set.Logger, set.ID.String(), cfg.(*Config), nextConsumer)
+}
@@ -0,0 +1,53 @@
+package promptvaultreplayreceiver
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/receiver/receivertest"
+
+	"github.com/airblackbox/otel-prompt-vault/internal/metadatatest"
+)
+
+func TestMetadataMatchesFactory(t *testing.T) {
+	f := NewFactory()
+	metadatatest.Check(t, "receiver", f.Type(), map[string]component.StabilityLevel{
+		"traces":  f.TracesReceiverStability(),
+		"metrics": f.MetricsReceiverStability(),
+		"logs":    f.LogsReceiverStability(),
+	})
+}
+
+func TestFactoryCreatesLogsReceiver(t *testing.T) {
+	dir, _, _ := seedVault(t)
+	f := NewFactory()
+	cfg := f.CreateDefaultConfig().(*Config)
+	if !reflect.DeepEqual(cfg, DefaultConfig()) {
+		t.Fatalf("default config = %#v, want DefaultConfig()", cfg)
+	}
+	cfg.Storage.Filesystem.BasePath = dir
+	cfg.Storage.Filesystem.Layout = "sharded"
+	sink := new(consumertest.LogsSink)
+	r, err := f.CreateLogsReceiver(context.Background(), receivertest.NewNopSettings(), cfg, sink)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Start(context.Background(), componenttest.NewNopHost()); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for sink.LogRecordCount() < 2 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err := r.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := bodies(sink)["first prompt"]; !ok || sink.LogRecordCount() != 2 {
+		t.Errorf("replayed %v, want the two live non-audit objects", bodies(sink))
+	}
+}
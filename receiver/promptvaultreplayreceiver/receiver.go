@@ -0,0 +1,335 @@
+package promptvaultreplayreceiver
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+	"unicode/utf8"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/consumer"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.uber.org/zap"
+
+	"github.com/airblackbox/otel-prompt-vault/processor/promptvaultprocessor"
+)
+
+const scopeName = "github.com/airblackbox/otel-prompt-vault/receiver/promptvaultreplayreceiver"
+
+// Attributes set on replayed log records.
+const (
+	attrRef     = "vault.ref"
+	attrKey     = "vault.attr_key"
+	attrTenant  = "vault.tenant"
+	attrService = "service.name"
+)
+
+// ReplayStats counts the outcome of a replay.
+type ReplayStats struct {
+	Replayed int
+	// Failed objects could not be retrieved and were skipped.
+	Failed int
+}
+
+// Receiver replays vault content into a logs pipeline. As a collector
+// component it replays once after Start; Replay can also be called
+// directly.
+type Receiver struct {
+	logger    *zap.Logger
+	config    *Config
+	vault     promptvaultprocessor.VaultStorage
+	keysSet   map[string]bool
+	principal string
+	next      consumer.Logs
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewReceiver opens the vault cfg describes. id identifies the receiver in
+// audit records.
+func NewReceiver(ctx context.Context, logger *zap.Logger, id string, cfg *Config, next consumer.Logs) (*Receiver, error) {
+	if err := promptvaultprocessor.CheckSecretsRedacted(cfg); err != nil {
+		return nil, err
+	}
+	if cfg.BatchSize <= 0 {
+		return nil, fmt.Errorf("batch_size must be positive")
+	}
+	if !cfg.StartTime.IsZero() && !cfg.EndTime.IsZero() && !cfg.EndTime.After(cfg.StartTime) {
+		return nil, fmt.Errorf("end_time must be after start_time")
+	}
+
+	r := &Receiver{
+		logger:    logger,
+		config:    cfg,
+		principal: id,
+		next:      next,
+	}
+	// A vault shared through an extension is resolved in Start.
+	if cfg.Storage.Extension == "" {
+		// Replay only reads, so the vault's own manifest is not reopened.
+		storage := cfg.Storage
+		storage.Manifest = promptvaultprocessor.ManifestConfig{}
+		vault, err := promptvaultprocessor.OpenVault(ctx, logger, &promptvaultprocessor.Config{Storage: storage, Crypto: cfg.Crypto})
+		if err != nil {
+			return nil, err
+		}
+		if r.vault, err = withAudit(ctx, cfg.Audit, vault); err != nil {
+			closeVault(vault)
+			return nil, err
+		}
+	}
+
+	keysSet := make(map[string]bool, len(cfg.Keys))
+	for _, k := range cfg.Keys {
+		keysSet[k] = true
+	}
+	r.keysSet = keysSet
+	return r, nil
+}
+
+// withAudit records retrievals through vault to the configured sink.
+func withAudit(ctx context.Context, cfg promptvaultprocessor.AuditConfig, vault promptvaultprocessor.VaultStorage) (promptvaultprocessor.VaultStorage, error) {
+	sink, err := promptvaultprocessor.NewAuditSink(ctx, cfg, vault)
+	if err != nil {
+		return nil, err
+	}
+	return promptvaultprocessor.NewAuditingVault(vault, sink, typeStr), nil
+}
+
+// Start starts the vault and replays in the background.
+func (r *Receiver) Start(ctx context.Context, host component.Host) error {
+	if r.config.Storage.Extension != "" {
+		vault, err := promptvaultprocessor.VaultFromHost(host, r.config.Storage.Extension)
+		if err != nil {
+			return err
+		}
+		if r.vault, err = withAudit(ctx, r.config.Audit, vault); err != nil {
+			return err
+		}
+	}
+	if s, ok := r.vault.(interface{ Start(context.Context) error }); ok {
+		if err := s.Start(ctx); err != nil {
+			return err
+		}
+	}
+	replayCtx, cancel := context.WithCancel(context.Background())
+	r.cancel = cancel
+	r.done = make(chan struct{})
+	go func() {
+		defer close(r.done)
+		stats, err := r.Replay(replayCtx)
+		if err != nil {
+			r.logger.Error("vault replay stopped", zap.Int("replayed", stats.Replayed), zap.Error(err))
+			return
+		}
+		r.logger.Info("vault replay finished", zap.Int("replayed", stats.Replayed), zap.Int("failed", stats.Failed))
+	}()
+	return nil
+}
+
+// Shutdown stops a replay in progress and closes the vault.
+func (r *Receiver) Shutdown(ctx context.Context) error {
+	if r.cancel != nil {
+		r.cancel()
+		select {
+		case <-r.done:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	if c, ok := r.vault.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// Replay emits every selected object to the next consumer, BatchSize log
+// records at a time. Objects that cannot be retrieved are logged and
+// skipped; an error from the next consumer stops the replay.
+func (r *Receiver) Replay(ctx context.Context) (ReplayStats, error) {
+	if r.vault == nil {
+		return ReplayStats{}, fmt.Errorf("storage.extension %q is resolved on Start", r.config.Storage.Extension)
+	}
+	ctx = promptvaultprocessor.WithPrincipal(ctx, r.principal)
+	var stats ReplayStats
+	b := newBatch()
+	flush := func() error {
+		if b.size == 0 {
+			return nil
+		}
+		if err := r.next.ConsumeLogs(ctx, b.logs); err != nil {
+			return err
+		}
+		stats.Replayed += b.size
+		b = newBatch()
+		return nil
+	}
+
+	err := r.objects(ctx, func(info promptvaultprocessor.ObjectInfo) error {
+		if !r.selected(info) {
+			return nil
+		}
+		content, err := r.vault.Retrieve(ctx, info.Ref)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			r.logger.Warn("vault object skipped", zap.String("ref", info.Ref), zap.Error(err))
+			stats.Failed++
+			return nil
+		}
+		b.add(info, content)
+		if b.size >= r.config.BatchSize {
+			return flush()
+		}
+		return nil
+	})
+	if err != nil {
+		return stats, err
+	}
+	return stats, flush()
+}
+
+func (r *Receiver) selected(info promptvaultprocessor.ObjectInfo) bool {
+	if len(r.keysSet) > 0 {
+		if !r.keysSet[info.Meta.Key] {
+			return false
+		}
+	} else if info.Meta.Key == "audit" {
+		return false
+	}
+	if !r.config.StartTime.IsZero() && info.StoredAt.Before(r.config.StartTime) {
+		return false
+	}
+	if !r.config.EndTime.IsZero() && !info.StoredAt.Before(r.config.EndTime) {
+		return false
+	}
+	return true
+}
+
+// objects calls fn for each candidate object, from the manifest when one
+// is configured and from the backend listing otherwise.
+func (r *Receiver) objects(ctx context.Context, fn func(promptvaultprocessor.ObjectInfo) error) error {
+	if r.config.ManifestDir == "" {
+		lister, ok := r.vault.(promptvaultprocessor.VaultLister)
+		if !ok {
+			return fmt.Errorf("list: %w", errors.ErrUnsupported)
+		}
+		if err := lister.List(ctx, fn); err != nil {
+			if errors.Is(err, errors.ErrUnsupported) {
+				return fmt.Errorf("storage backend %q cannot list objects; set manifest_dir: %w", r.config.Storage.Backend, err)
+			}
+			return err
+		}
+		return nil
+	}
+
+	// Replay objects the manifest records as live, in first-store order.
+	live := make(map[string]promptvaultprocessor.ObjectInfo)
+	var order []string
+	err := promptvaultprocessor.ReadManifest(r.config.ManifestDir, func(e promptvaultprocessor.ManifestEntry) error {
+		switch e.Op {
+		case "delete":
+			delete(live, e.Ref)
+		default:
+			info, ok := live[e.Ref]
+			if !ok {
+				order = append(order, e.Ref)
+				info = promptvaultprocessor.ObjectInfo{Ref: e.Ref, StoredAt: e.Time}
+			}
+			info.Size = int64(e.Size)
+			live[e.Ref] = info
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	statter, _ := r.vault.(promptvaultprocessor.VaultStatter)
+	for _, ref := range order {
+		info, ok := live[ref]
+		if !ok {
+			continue
+		}
+		if statter != nil {
+			if st, err := statter.Stat(ctx, ref); err == nil {
+				info.Meta = st.Meta
+			}
+		}
+		if err := fn(info); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// batch groups replayed records under one resource per service.
+type batch struct {
+	logs   plog.Logs
+	scopes map[string]plog.LogRecordSlice
+	size   int
+	now    pcommon.Timestamp
+}
+
+func newBatch() *batch {
+	return &batch{
+		logs:   plog.NewLogs(),
+		scopes: make(map[string]plog.LogRecordSlice),
+		now:    pcommon.NewTimestampFromTime(time.Now()),
+	}
+}
+
+func (b *batch) add(info promptvaultprocessor.ObjectInfo, content []byte) {
+	meta := info.Meta
+	records, ok := b.scopes[meta.Service]
+	if !ok {
+		rl := b.logs.ResourceLogs().AppendEmpty()
+		if meta.Service != "" {
+			rl.Resource().Attributes().PutStr(attrService, meta.Service)
+		}
+		sl := rl.ScopeLogs().AppendEmpty()
+		sl.Scope().SetName(scopeName)
+		records = sl.LogRecords()
+		b.scopes[meta.Service] = records
+	}
+
+	lr := records.AppendEmpty()
+	lr.SetTimestamp(pcommon.NewTimestampFromTime(info.StoredAt))
+	lr.SetObservedTimestamp(b.now)
+	var traceID pcommon.TraceID
+	if id, err := hex.DecodeString(meta.TraceID); err == nil && len(id) == len(traceID) {
+		copy(traceID[:], id)
+		lr.SetTraceID(traceID)
+	}
+	var spanID pcommon.SpanID
+	if id, err := hex.DecodeString(meta.SpanID); err == nil && len(id) == len(spanID) {
+		copy(spanID[:], id)
+		lr.SetSpanID(spanID)
+	}
+	if utf8.Valid(content) {
+		lr.Body().SetStr(string(content))
+	} else {
+		lr.Body().SetEmptyBytes().FromRaw(content)
+	}
+	attrs := lr.Attributes()
+	attrs.PutStr(attrRef, info.Ref)
+	if meta.Key != "" {
+		attrs.PutStr(attrKey, meta.Key)
+	}
+	if meta.Tenant != "" {
+		attrs.PutStr(attrTenant, meta.Tenant)
+	}
+	b.size++
+}
+
+func closeVault(vault promptvaultprocessor.VaultStorage) {
+	if c, ok := vault.(io.Closer); ok {
+		c.Close()
+	}
+}
@@ -0,0 +1,166 @@
+package promptvaultreplayreceiver
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.uber.org/zap"
+
+	"github.com/airblackbox/otel-prompt-vault/processor/promptvaultprocessor"
+)
+
+const testSigningKey = "0123456789abcdef0123456789abcdef"
+
+// seedVault writes objects through the same stack the processor uses, with
+// a manifest, and deletes one of them.
+func seedVault(t *testing.T) (dir, manifestDir string, refs map[string]string) {
+	t.Helper()
+	dir, manifestDir = t.TempDir(), t.TempDir()
+	cfg := &promptvaultprocessor.Config{Storage: promptvaultprocessor.StorageConfig{
+		Backend:    "filesystem",
+		Filesystem: promptvaultprocessor.FilesystemConfig{BasePath: dir, Layout: "sharded", WriteMetadata: true},
+		Manifest:   promptvaultprocessor.ManifestConfig{Dir: manifestDir},
+	}}
+	cfg.Vault.SigningKey = testSigningKey
+	vault, err := promptvaultprocessor.OpenVault(context.Background(), zap.NewNop(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	refs = make(map[string]string)
+	for content, meta := range map[string]promptvaultprocessor.ObjectMeta{
+		"first prompt":   {Key: "gen_ai.prompt", Service: "chat", TraceID: "0102030405060708090a0b0c0d0e0f10", SpanID: "0102030405060708"},
+		"a completion":   {Key: "gen_ai.completion", Service: "chat"},
+		"deleted prompt": {Key: "gen_ai.prompt", Service: "search"},
+		`{"op":"read"}`:  {Key: "audit"},
+	} {
+		ref, err := vault.Store(context.Background(), meta, []byte(content))
+		if err != nil {
+			t.Fatal(err)
+		}
+		refs[content] = ref
+	}
+	if err := vault.(promptvaultprocessor.VaultDeleter).Delete(context.Background(), refs["deleted prompt"]); err != nil {
+		t.Fatal(err)
+	}
+	return dir, manifestDir, refs
+}
+
+func newTestReceiver(t *testing.T, dir string, mutate func(*Config)) (*Receiver, *consumertest.LogsSink) {
+	t.Helper()
+	cfg := DefaultConfig()
+	cfg.Storage.Filesystem.BasePath = dir
+	cfg.Storage.Filesystem.Layout = "sharded"
+	if mutate != nil {
+		mutate(cfg)
+	}
+	sink := new(consumertest.LogsSink)
+	r, err := NewReceiver(context.Background(), zap.NewNop(), typeStr, cfg, sink)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { r.Shutdown(context.Background()) })
+	return r, sink
+}
+
+// bodies indexes replayed records by body.
+func bodies(sink *consumertest.LogsSink) map[string]plog.LogRecord {
+	out := make(map[string]plog.LogRecord)
+	for _, ld := range sink.AllLogs() {
+		rls := ld.ResourceLogs()
+		for i := 0; i < rls.Len(); i++ {
+			records := rls.At(i).ScopeLogs().At(0).LogRecords()
+			for j := 0; j < records.Len(); j++ {
+				out[records.At(j).Body().Str()] = records.At(j)
+			}
+		}
+	}
+	return out
+}
+
+func TestReplayListsBackend(t *testing.T) {
+	dir, _, refs := seedVault(t)
+	r, sink := newTestReceiver(t, dir, nil)
+
+	stats, err := r.Replay(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Replayed != 2 || stats.Failed != 0 {
+		t.Errorf("stats = %+v, want 2 replayed", stats)
+	}
+	got := bodies(sink)
+	if _, ok := got[`{"op":"read"}`]; ok {
+		t.Error("audit records replayed")
+	}
+	lr, ok := got["first prompt"]
+	if !ok {
+		t.Fatalf("first prompt not replayed; got %v", got)
+	}
+	if lr.TraceID().String() != "0102030405060708090a0b0c0d0e0f10" || lr.SpanID().String() != "0102030405060708" {
+		t.Errorf("trace/span = %s/%s", lr.TraceID(), lr.SpanID())
+	}
+	if v, _ := lr.Attributes().Get(attrRef); v.Str() != refs["first prompt"] {
+		t.Errorf("%s = %q", attrRef, v.Str())
+	}
+	if v, _ := lr.Attributes().Get(attrKey); v.Str() != "gen_ai.prompt" {
+		t.Errorf("%s = %q", attrKey, v.Str())
+	}
+}
+
+func TestReplayFromManifest(t *testing.T) {
+	dir, manifestDir, _ := seedVault(t)
+	r, sink := newTestReceiver(t, dir, func(cfg *Config) {
+		cfg.ManifestDir = manifestDir
+		cfg.Keys = []string{"gen_ai.prompt"}
+		cfg.BatchSize = 1
+	})
+
+	stats, err := r.Replay(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Replayed != 1 {
+		t.Errorf("stats = %+v, want the one live prompt", stats)
+	}
+	if _, ok := bodies(sink)["first prompt"]; !ok || len(sink.AllLogs()) != 1 {
+		t.Errorf("replayed %v", bodies(sink))
+	}
+}
+
+func TestReplayTimeRange(t *testing.T) {
+	dir, _, _ := seedVault(t)
+	r, sink := newTestReceiver(t, dir, func(cfg *Config) {
+		cfg.EndTime = time.Now().Add(-time.Hour)
+	})
+	if err := r.Start(context.Background(), componenttest.NewNopHost()); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if n := sink.LogRecordCount(); n != 0 {
+		t.Errorf("replayed %d records stored after end_time", n)
+	}
+}
+
+func TestNewReceiverValidates(t *testing.T) {
+	for name, mutate := range map[string]func(*Config){
+		"batch size": func(cfg *Config) { cfg.BatchSize = 0 },
+		"time range": func(cfg *Config) {
+			cfg.StartTime = time.Now()
+			cfg.EndTime = cfg.StartTime.Add(-time.Minute)
+		},
+	} {
+		cfg := DefaultConfig()
+		cfg.Storage.Filesystem.BasePath = t.TempDir()
+		mutate(cfg)
+		if _, err := NewReceiver(context.Background(), zap.NewNop(), typeStr, cfg, new(consumertest.LogsSink)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
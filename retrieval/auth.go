@@ -0,0 +1,196 @@
+package retrieval
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"slices"
+
+	"github.com/airblackbox/otel-prompt-vault/processor/promptvaultprocessor"
+)
+
+// Actions a Scope can grant.
+const (
+	ActionRead   = "read"
+	ActionDelete = "delete"
+)
+
+// ErrUnauthenticated is returned when a request carries no valid credential.
+var ErrUnauthenticated = errors.New("unauthenticated")
+
+// Principal is an authenticated caller and what it may access.
+type Principal struct {
+	Name string
+	// Scopes are the grants the caller holds; any one may allow an access.
+	Scopes []Scope
+	// Tenants, if non-nil, further restricts every scope to these tenants.
+	Tenants []string
+}
+
+// Allows reports whether any of the principal's scopes grants action on an
+// object stored for tenant from attribute key.
+func (p *Principal) Allows(action, tenant, key string) bool {
+	if p.Tenants != nil && !slices.Contains(p.Tenants, tenant) {
+		return false
+	}
+	for _, s := range p.Scopes {
+		if s.Allows(action, tenant, key) {
+			return true
+		}
+	}
+	return false
+}
+
+// allowsTenant reports whether any of the principal's scopes grants action
+// on some of tenant's objects.
+func (p *Principal) allowsTenant(action, tenant string) bool {
+	if p.Tenants != nil && !slices.Contains(p.Tenants, tenant) {
+		return false
+	}
+	for _, s := range p.Scopes {
+		actions := s.Actions
+		if len(actions) == 0 {
+			actions = []string{ActionRead}
+		}
+		if slices.Contains(actions, action) && (len(s.Tenants) == 0 || slices.Contains(s.Tenants, tenant)) {
+			return true
+		}
+	}
+	return false
+}
+
+// allowsAll reports whether any of the principal's scopes grants action on
+// every object, whatever its tenant and key.
+func (p *Principal) allowsAll(action string) bool {
+	if p.Tenants != nil {
+		return false
+	}
+	for _, s := range p.Scopes {
+		actions := s.Actions
+		if len(actions) == 0 {
+			actions = []string{ActionRead}
+		}
+		if slices.Contains(actions, action) && len(s.Tenants) == 0 && len(s.Keys) == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Authenticator identifies the caller of a request. It returns
+// ErrUnauthenticated (possibly wrapped) when r carries no credential it
+// accepts.
+type Authenticator interface {
+	Authenticate(r *http.Request) (*Principal, error)
+}
+
+// Allows reports whether the scope grants action on an object stored for
+// tenant from attribute key.
+func (s Scope) Allows(action, tenant, key string) bool {
+	actions := s.Actions
+	if len(actions) == 0 {
+		actions = []string{ActionRead}
+	}
+	if !slices.Contains(actions, action) {
+		return false
+	}
+	if len(s.Tenants) > 0 && !slices.Contains(s.Tenants, tenant) {
+		return false
+	}
+	if len(s.Keys) == 0 {
+		return true
+	}
+	for _, pattern := range s.Keys {
+		if ok, _ := path.Match(pattern, key); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (s Scope) validate() error {
+	for _, a := range s.Actions {
+		if a != ActionRead && a != ActionDelete {
+			return fmt.Errorf("unknown action %q", a)
+		}
+	}
+	for _, pattern := range s.Keys {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("key pattern %q: %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+// APIKeyAuthenticator accepts the API keys in an AuthConfig.
+type APIKeyAuthenticator struct {
+	// Keys are indexed by their SHA-256 so lookups do not compare secrets
+	// byte by byte.
+	keys map[[sha256.Size]byte]*Principal
+}
+
+// NewAPIKeyAuthenticator validates cfg.APIKeys and indexes them.
+func NewAPIKeyAuthenticator(cfg AuthConfig) (*APIKeyAuthenticator, error) {
+	a := &APIKeyAuthenticator{keys: make(map[[sha256.Size]byte]*Principal, len(cfg.APIKeys))}
+	for i, k := range cfg.APIKeys {
+		if k.Name == "" {
+			return nil, fmt.Errorf("auth.api_keys[%d]: name is required", i)
+		}
+		if len(k.Key) < 16 {
+			return nil, fmt.Errorf("auth.api_keys[%d] (%s): key must be at least 16 bytes", i, k.Name)
+		}
+		if err := k.Scope.validate(); err != nil {
+			return nil, fmt.Errorf("auth.api_keys[%d] (%s): %w", i, k.Name, err)
+		}
+		sum := sha256.Sum256([]byte(k.Key))
+		if _, dup := a.keys[sum]; dup {
+			return nil, fmt.Errorf("auth.api_keys[%d] (%s): duplicate key", i, k.Name)
+		}
+		a.keys[sum] = &Principal{Name: k.Name, Scopes: []Scope{k.Scope}}
+	}
+	return a, nil
+}
+
+// Authenticate looks up the X-API-Key header.
+func (a *APIKeyAuthenticator) Authenticate(r *http.Request) (*Principal, error) {
+	key := r.Header.Get("X-API-Key")
+	if key == "" {
+		return nil, ErrUnauthenticated
+	}
+	p, ok := a.keys[sha256.Sum256([]byte(key))]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown api key", ErrUnauthenticated)
+	}
+	return p, nil
+}
+
+type principalKey struct{}
+
+// PrincipalFrom returns the principal RequireAuth attached to ctx.
+func PrincipalFrom(ctx context.Context) *Principal {
+	p, _ := ctx.Value(principalKey{}).(*Principal)
+	return p
+}
+
+// RequireAuth rejects requests that auth does not accept with 401 and passes
+// the rest to next with the principal attached to the request context,
+// including as the audit principal.
+func RequireAuth(auth Authenticator, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p, err := auth.Authenticate(r)
+		if err != nil {
+			http.Error(w, "unauthenticated", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(withPrincipal(r.Context(), p)))
+	})
+}
+
+// withPrincipal attaches p to ctx, including as the audit principal.
+func withPrincipal(ctx context.Context, p *Principal) context.Context {
+	ctx = context.WithValue(ctx, principalKey{}, p)
+	return promptvaultprocessor.WithPrincipal(ctx, p.Name)
+}
@@ -0,0 +1,82 @@
+package retrieval
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.opentelemetry.io/collector/config/configopaque"
+)
+
+func TestScopeAllows(t *testing.T) {
+	sre := Scope{Keys: []string{"http.*", "db.statement"}}
+	privacy := Scope{Actions: []string{ActionRead, ActionDelete}, Tenants: []string{"acme"}, Keys: []string{"gen_ai.input.messages"}}
+
+	for _, tc := range []struct {
+		scope               Scope
+		action, tenant, key string
+		want                bool
+	}{
+		{sre, ActionRead, "acme", "http.request.body", true},
+		{sre, ActionRead, "", "db.statement", true},
+		{sre, ActionRead, "acme", "gen_ai.input.messages", false},
+		{sre, ActionDelete, "acme", "http.request.body", false},
+		{privacy, ActionRead, "acme", "gen_ai.input.messages", true},
+		{privacy, ActionDelete, "acme", "gen_ai.input.messages", true},
+		{privacy, ActionRead, "globex", "gen_ai.input.messages", false},
+		{privacy, ActionRead, "acme", "http.request.body", false},
+		{Scope{}, ActionRead, "any", "any.key", true},
+	} {
+		if got := tc.scope.Allows(tc.action, tc.tenant, tc.key); got != tc.want {
+			t.Errorf("%+v.Allows(%s, %s, %s) = %v, want %v", tc.scope, tc.action, tc.tenant, tc.key, got, tc.want)
+		}
+	}
+}
+
+func TestAPIKeyAuth(t *testing.T) {
+	auth, err := NewAPIKeyAuthenticator(AuthConfig{APIKeys: []APIKeyConfig{
+		{Name: "sre", Key: "sre-key-0123456789", Scope: Scope{Keys: []string{"http.*"}}},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := RequireAuth(auth, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, PrincipalFrom(r.Context()).Name)
+	}))
+
+	for key, want := range map[string]int{"sre-key-0123456789": http.StatusOK, "wrong-key-0123456789": http.StatusUnauthorized, "": http.StatusUnauthorized} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if key != "" {
+			req.Header.Set("X-API-Key", key)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != want {
+			t.Errorf("key %q: status %d, want %d", key, rec.Code, want)
+		}
+		if want == http.StatusOK && rec.Body.String() != "sre" {
+			t.Errorf("principal = %q, want sre", rec.Body.String())
+		}
+	}
+
+	if _, err := auth.Authenticate(httptest.NewRequest(http.MethodGet, "/", nil)); !errors.Is(err, ErrUnauthenticated) {
+		t.Errorf("err = %v, want ErrUnauthenticated", err)
+	}
+}
+
+func TestNewAPIKeyAuthenticatorValidates(t *testing.T) {
+	long := configopaque.String("0123456789abcdef")
+	for name, keys := range map[string][]APIKeyConfig{
+		"no name":     {{Key: long}},
+		"short key":   {{Name: "a", Key: "short"}},
+		"bad action":  {{Name: "a", Key: long, Scope: Scope{Actions: []string{"write"}}}},
+		"bad pattern": {{Name: "a", Key: long, Scope: Scope{Keys: []string{"["}}}},
+		"duplicate":   {{Name: "a", Key: long}, {Name: "b", Key: long}},
+	} {
+		if _, err := NewAPIKeyAuthenticator(AuthConfig{APIKeys: keys}); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+// Package retrieval serves vault content to authorized clients.
+package retrieval
+
+import (
+	"go.opentelemetry.io/collector/config/configopaque"
+	"go.opentelemetry.io/collector/config/configtls"
+
+	"github.com/airblackbox/otel-prompt-vault/processor/promptvaultprocessor"
+)
+
+// ServerConfig configures the retrieval API server.
+type ServerConfig struct {
+	// Endpoint is the address to listen on. Default "localhost:8470".
+	Endpoint string `mapstructure:"endpoint"`
+	// TLS enables HTTPS; without it the API is served in plaintext. It
+	// takes the collector's server TLS settings and needs a certificate
+	// and key; client_ca_file requires client certificates (mTLS).
+	TLS  *configtls.ServerConfig `mapstructure:"tls"`
+	Auth AuthConfig              `mapstructure:"auth"`
+	// Audit records every retrieval served.
+	Audit promptvaultprocessor.AuditConfig `mapstructure:"audit"`
+	// RequireSignature rejects requests that do not carry the reference's
+	// vault_sig, so holding a span's signed reference is required to read
+	// its content. Needs vault.signing_key.
+	RequireSignature bool `mapstructure:"require_signature"`
+	// GRPC serves the VaultRetrieval gRPC API on a second endpoint, with
+	// the same TLS, auth, and audit settings.
+	GRPC GRPCConfig `mapstructure:"grpc"`
+	// UI serves the vault browser at /ui/ on the HTTP endpoint.
+	UI bool `mapstructure:"ui"`
+	// Erasure serves POST /v1/erasure on the HTTP endpoint.
+	Erasure ErasureConfig `mapstructure:"erasure"`
+	// Hooks run, in order, on content before it is returned, each naming a
+	// hook registered with promptvaultprocessor.RegisterRetrieveHook.
+	Hooks []promptvaultprocessor.RetrieveHookConfig `mapstructure:"hooks"`
+}
+
+// ErasureConfig configures the erasure API, which deletes a trace's or an
+// end user's objects for callers granted the "delete" action.
+type ErasureConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// ReceiptKeyFile is a PEM Ed25519 private key that signs erasure
+	// receipts. Required when enabled.
+	ReceiptKeyFile string `mapstructure:"receipt_key_file"`
+}
+
+// GRPCConfig configures the gRPC API.
+type GRPCConfig struct {
+	// Endpoint is the address to listen on. Empty disables the gRPC API.
+	Endpoint string `mapstructure:"endpoint"`
+}
+
+// AuthConfig lists the credentials accepted by the retrieval API.
+type AuthConfig struct {
+	APIKeys []APIKeyConfig `mapstructure:"api_keys"`
+	OIDC    OIDCConfig     `mapstructure:"oidc"`
+}
+
+// APIKeyConfig is one API key, presented in the X-API-Key header.
+type APIKeyConfig struct {
+	// Name identifies the key holder in audit records.
+	Name  string              `mapstructure:"name"`
+	Key   configopaque.String `mapstructure:"key"`
+	Scope Scope               `mapstructure:"scope"`
+}
+
+// Scope limits what a principal may do. Empty lists impose no restriction,
+// except Actions, which defaults to read-only.
+type Scope struct {
+	// Actions is any of "read" and "delete". Default ["read"].
+	Actions []string `mapstructure:"actions"`
+	// Tenants restricts access to objects stored for these tenants.
+	Tenants []string `mapstructure:"tenants"`
+	// Keys restricts access to objects vaulted from attribute keys matching
+	// these patterns, e.g. "http.*" or "gen_ai.input.messages".
+	Keys []string `mapstructure:"keys"`
+}
+
+// OIDCConfig accepts bearer tokens issued by an OpenID Connect provider,
+// granting scopes by group membership so access follows SSO groups.
+type OIDCConfig struct {
+	// IssuerURL is the provider's issuer; its discovery document and keys
+	// are fetched from there. Empty disables OIDC.
+	IssuerURL string `mapstructure:"issuer_url"`
+	// Audience must appear in the token's aud claim.
+	Audience string `mapstructure:"audience"`
+	// RequiredClaims must all be present in the token with these values.
+	RequiredClaims map[string]string `mapstructure:"required_claims"`
+	// UsernameClaim names the caller in audit records. Default "sub".
+	UsernameClaim string `mapstructure:"username_claim"`
+	// GroupsClaim lists the caller's groups. Default "groups".
+	GroupsClaim string `mapstructure:"groups_claim"`
+	// GroupScopes grants each group's scope to its members.
+	GroupScopes map[string]Scope `mapstructure:"group_scopes"`
+	// TenantClaim, if set, restricts the caller to the tenants listed in
+	// this claim, whatever their groups grant.
+	TenantClaim string `mapstructure:"tenant_claim"`
+}
@@ -0,0 +1,90 @@
+package retrieval
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"go.uber.org/zap"
+
+	"github.com/airblackbox/otel-prompt-vault/processor/promptvaultprocessor"
+)
+
+// erasureRoute is the erasure endpoint's pattern.
+const erasureRoute = "POST /v1/erasure"
+
+// maxErasureBody bounds POST /v1/erasure request bodies.
+const maxErasureBody = 4 << 10
+
+// erasureHandler serves POST /v1/erasure.
+type erasureHandler struct {
+	logger *zap.Logger
+	vault  promptvaultprocessor.VaultStorage
+	key    promptvaultprocessor.ManifestKey
+}
+
+// NewErasureHandler erases vault objects at POST /v1/erasure, given a JSON
+// body of {"trace_id": ...} or {"enduser_id": ...}, and responds with the
+// erasure receipt signed by key. Requests must pass through RequireAuth;
+// only objects the principal may delete are erased, and the rest are
+// counted as skipped.
+func NewErasureHandler(logger *zap.Logger, vault promptvaultprocessor.VaultStorage, key promptvaultprocessor.ManifestKey) http.Handler {
+	h := &erasureHandler{logger: logger, vault: vault, key: key}
+	mux := http.NewServeMux()
+	mux.HandleFunc(erasureRoute, h.erase)
+	return mux
+}
+
+func (h *erasureHandler) erase(w http.ResponseWriter, r *http.Request) {
+	var req promptvaultprocessor.ErasureRequest
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxErasureBody))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		http.Error(w, "invalid erasure request", http.StatusBadRequest)
+		return
+	}
+	if (req.TraceID == "") == (req.EndUser == "") {
+		http.Error(w, "exactly one of trace_id and enduser_id is required", http.StatusBadRequest)
+		return
+	}
+	p := PrincipalFrom(r.Context())
+	if p == nil {
+		http.Error(w, errForbidden.Error(), http.StatusForbidden)
+		return
+	}
+
+	receipt, err := promptvaultprocessor.Erase(r.Context(), h.vault, req, func(meta promptvaultprocessor.ObjectMeta) bool {
+		return p.Allows(ActionDelete, meta.Tenant, meta.Key)
+	})
+	if err != nil {
+		if errors.Is(err, errors.ErrUnsupported) {
+			http.Error(w, "storage backend cannot list or delete objects", http.StatusNotImplemented)
+			return
+		}
+		h.logger.Warn("vault erasure failed", zap.Error(err))
+		http.Error(w, "erasure failed", http.StatusBadGateway)
+		return
+	}
+	if err := receipt.Sign(h.key); err != nil {
+		h.logger.Error("signing erasure receipt failed", zap.Error(err))
+		http.Error(w, "erasure receipt could not be signed", http.StatusInternalServerError)
+		return
+	}
+	h.logger.Info("vault erasure",
+		zap.String("principal", receipt.Principal),
+		zap.String("trace_id", req.TraceID),
+		zap.String("ticket", req.Ticket),
+		zap.Bool("enduser", req.EndUser != ""),
+		zap.Int("deleted", len(receipt.Deleted)),
+		zap.Int("failed", len(receipt.Failed)),
+		zap.Int("skipped", receipt.Skipped))
+
+	status := http.StatusOK
+	if len(receipt.Failed) > 0 {
+		status = http.StatusMultiStatus
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(receipt)
+}
@@ -0,0 +1,115 @@
+package retrieval
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"github.com/airblackbox/otel-prompt-vault/processor/promptvaultprocessor"
+)
+
+func writeEd25519Key(t *testing.T) string {
+	t.Helper()
+	_, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "receipt.pem")
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestErasureHandler(t *testing.T) {
+	ctx := context.Background()
+	key, err := promptvaultprocessor.LoadEd25519ManifestKey(writeEd25519Key(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	auth, err := NewAPIKeyAuthenticator(AuthConfig{APIKeys: []APIKeyConfig{
+		{Name: "dpo", Key: "dpo-key-0123456789", Scope: Scope{Actions: []string{"delete"}, Tenants: []string{"acme"}}},
+		{Name: "reader", Key: "read-key-0123456789"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	vault := promptvaultprocessor.NewMemoryVault(0, 0)
+	acmeRef, _ := vault.Store(ctx, promptvaultprocessor.ObjectMeta{Key: "gen_ai.prompt", Tenant: "acme", EndUser: "u1"}, []byte("acme prompt"))
+	otherRef, _ := vault.Store(ctx, promptvaultprocessor.ObjectMeta{Key: "gen_ai.prompt", Tenant: "globex", EndUser: "u1"}, []byte("globex prompt"))
+	h := RequireAuth(auth, NewErasureHandler(zap.NewNop(), vault, key))
+
+	post := func(body, apiKey string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/v1/erasure", strings.NewReader(body))
+		req.Header.Set("X-API-Key", apiKey)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	for _, tc := range []struct {
+		body, key  string
+		wantStatus int
+	}{
+		{`{}`, "dpo-key-0123456789", http.StatusBadRequest},
+		{`{"trace_id": "t1", "enduser_id": "u1"}`, "dpo-key-0123456789", http.StatusBadRequest},
+		{`{"user": "u1"}`, "dpo-key-0123456789", http.StatusBadRequest},
+		{`{"enduser_id": "u1"}`, "", http.StatusUnauthorized},
+	} {
+		if rec := post(tc.body, tc.key); rec.Code != tc.wantStatus {
+			t.Errorf("POST %s as %q: status %d, want %d", tc.body, tc.key, rec.Code, tc.wantStatus)
+		}
+	}
+
+	// A read-only principal erases nothing.
+	rec := post(`{"enduser_id": "u1"}`, "read-key-0123456789")
+	var receipt promptvaultprocessor.ErasureReceipt
+	if err := json.Unmarshal(rec.Body.Bytes(), &receipt); err != nil {
+		t.Fatal(err)
+	}
+	if rec.Code != http.StatusOK || len(receipt.Deleted) != 0 || receipt.Skipped != 2 {
+		t.Errorf("read-only erasure: status %d, receipt %+v", rec.Code, receipt)
+	}
+
+	rec = post(`{"enduser_id": "u1"}`, "dpo-key-0123456789")
+	receipt = promptvaultprocessor.ErasureReceipt{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &receipt); err != nil {
+		t.Fatal(err)
+	}
+	if rec.Code != http.StatusOK || len(receipt.Deleted) != 1 || receipt.Deleted[0].Ref != acmeRef || receipt.Skipped != 1 {
+		t.Errorf("erasure: status %d, receipt %+v", rec.Code, receipt)
+	}
+	if receipt.Principal != "dpo" {
+		t.Errorf("principal = %q, want dpo", receipt.Principal)
+	}
+	if err := promptvaultprocessor.VerifyErasureReceipt(receipt, key); err != nil {
+		t.Errorf("receipt signature: %v", err)
+	}
+	if _, err := vault.Retrieve(ctx, acmeRef); err == nil {
+		t.Error("acme object survived erasure")
+	}
+	if _, err := vault.Retrieve(ctx, otherRef); err != nil {
+		t.Errorf("out-of-scope object was erased: %v", err)
+	}
+}
+
+func TestServerRequiresReceiptKey(t *testing.T) {
+	cfg := ServerConfig{Erasure: ErasureConfig{Enabled: true}}
+	if _, err := NewServer(context.Background(), zap.NewNop(), cfg, promptvaultprocessor.NewMemoryVault(0, 0), nil); err == nil {
+		t.Error("erasure without a receipt key was accepted")
+	}
+}
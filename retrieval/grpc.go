@@ -0,0 +1,238 @@
+package retrieval
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/airblackbox/otel-prompt-vault/processor/promptvaultprocessor"
+	"github.com/airblackbox/otel-prompt-vault/retrieval/retrievalpb"
+)
+
+const (
+	// maxBatchRefs bounds BatchGetContent requests.
+	maxBatchRefs = 100
+	// chunkSize is the largest data payload per streamed ContentChunk.
+	chunkSize = 64 << 10
+)
+
+// NewGRPCServer returns a gRPC server offering the VaultRetrieval service
+// (proto/promptvault/retrieval/v1/retrieval.proto) from vault, with the same
+// authorization and signature checks as NewHandler. Calls are authenticated
+// by auth from their metadata; opts are passed to grpc.NewServer, e.g. TLS
+// credentials.
+func NewGRPCServer(
+	logger *zap.Logger,
+	vault promptvaultprocessor.VaultStorage,
+	signer *promptvaultprocessor.RefSigner,
+	requireSignature bool,
+	auth Authenticator,
+	opts ...grpc.ServerOption,
+) (*grpc.Server, error) {
+	rd, err := newReader(vault, signer, requireSignature)
+	if err != nil {
+		return nil, err
+	}
+	return newGRPCServer(logger, rd, auth, opts...), nil
+}
+
+func newGRPCServer(logger *zap.Logger, rd *reader, auth Authenticator, opts ...grpc.ServerOption) *grpc.Server {
+	opts = append(opts,
+		grpc.ChainUnaryInterceptor(unaryAuth(auth)),
+		grpc.ChainStreamInterceptor(streamAuth(auth)),
+	)
+	srv := grpc.NewServer(opts...)
+	retrievalpb.RegisterVaultRetrievalServer(srv, &grpcService{logger: logger, reader: rd})
+	return srv
+}
+
+type grpcService struct {
+	retrievalpb.UnimplementedVaultRetrievalServer
+	logger *zap.Logger
+	reader *reader
+}
+
+func (s *grpcService) GetContent(req *retrievalpb.GetContentRequest, stream retrievalpb.VaultRetrieval_GetContentServer) error {
+	ref, err := parseRef(req.GetRef())
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	content, info, err := s.reader.read(stream.Context(), ref, req.GetSignature())
+	if err != nil {
+		return s.statusError(ref, err)
+	}
+	return sendContent(stream, ref, info, content)
+}
+
+func (s *grpcService) BatchGetContent(req *retrievalpb.BatchGetContentRequest, stream retrievalpb.VaultRetrieval_BatchGetContentServer) error {
+	if n := len(req.GetRequests()); n > maxBatchRefs {
+		return status.Errorf(codes.InvalidArgument, "%d refs requested, at most %d allowed", n, maxBatchRefs)
+	}
+	for _, r := range req.GetRequests() {
+		if err := stream.Context().Err(); err != nil {
+			return status.FromContextError(err).Err()
+		}
+		if err := s.sendBatchEntry(stream, r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sendBatchEntry streams one object of a batch, or an error chunk if it
+// cannot be read. Only stream failures are returned.
+func (s *grpcService) sendBatchEntry(stream retrievalpb.VaultRetrieval_BatchGetContentServer, r *retrievalpb.GetContentRequest) error {
+	ref, err := parseRef(r.GetRef())
+	if err != nil {
+		return sendError(stream, r.GetRef(), status.Error(codes.InvalidArgument, err.Error()))
+	}
+	content, info, err := s.reader.read(stream.Context(), ref, r.GetSignature())
+	if err != nil {
+		return sendError(stream, ref, s.statusError(ref, err))
+	}
+	return sendContent(stream, ref, info, content)
+}
+
+func (s *grpcService) StatContent(ctx context.Context, req *retrievalpb.StatContentRequest) (*retrievalpb.ObjectInfo, error) {
+	ref, err := parseRef(req.GetRef())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	info, err := s.reader.stat(ctx, ref)
+	if err != nil {
+		return nil, s.statusError(ref, err)
+	}
+	return objectInfoProto(ref, info), nil
+}
+
+// statusError maps a read error to a gRPC status. Backend details are
+// logged, not returned.
+func (s *grpcService) statusError(ref string, err error) error {
+	switch {
+	case errors.Is(err, promptvaultprocessor.ErrObjectNotFound):
+		return status.Error(codes.NotFound, "not found")
+	case errors.Is(err, errForbidden), errors.Is(err, errSignatureRequired), errors.Is(err, errInvalidSignature):
+		return status.Error(codes.PermissionDenied, err.Error())
+	case errors.Is(err, promptvaultprocessor.ErrChecksumMismatch):
+		s.logger.Error("vault object failed checksum verification", zap.String("ref", ref))
+		return status.Error(codes.DataLoss, "checksum mismatch")
+	default:
+		s.logger.Warn("vault retrieval failed", zap.String("ref", ref), zap.Error(err))
+		return status.Error(codes.Internal, "retrieval failed")
+	}
+}
+
+// sendContent streams content in chunks, describing the object on the
+// first and marking the last.
+func sendContent(stream grpc.ServerStreamingServer[retrievalpb.ContentChunk], ref string, info promptvaultprocessor.ObjectInfo, content []byte) error {
+	pbInfo := objectInfoProto(ref, info)
+	pbInfo.Sha256 = fmt.Sprintf("%x", sha256.Sum256(content))
+	pbInfo.Size = int64(len(content))
+	pbInfo.ContentType = contentType(content)
+
+	for off := 0; ; off += chunkSize {
+		end := min(off+chunkSize, len(content))
+		chunk := &retrievalpb.ContentChunk{Ref: ref, Data: content[off:end], Last: end == len(content)}
+		if off == 0 {
+			chunk.Info = pbInfo
+		}
+		if err := stream.Send(chunk); err != nil {
+			return err
+		}
+		if chunk.Last {
+			return nil
+		}
+	}
+}
+
+// sendError ends ref's part of a batch with the status of err.
+func sendError(stream grpc.ServerStreamingServer[retrievalpb.ContentChunk], ref string, err error) error {
+	st := status.Convert(err)
+	return stream.Send(&retrievalpb.ContentChunk{
+		Ref:          ref,
+		Last:         true,
+		ErrorCode:    int32(st.Code()),
+		ErrorMessage: st.Message(),
+	})
+}
+
+func objectInfoProto(ref string, info promptvaultprocessor.ObjectInfo) *retrievalpb.ObjectInfo {
+	pb := &retrievalpb.ObjectInfo{
+		Ref:        ref,
+		StoredSize: info.Size,
+		TraceId:    info.Meta.TraceID,
+		SpanId:     info.Meta.SpanID,
+		AttrKey:    info.Meta.Key,
+		Service:    info.Meta.Service,
+		Tenant:     info.Meta.Tenant,
+	}
+	if !info.StoredAt.IsZero() {
+		pb.StoredAt = timestamppb.New(info.StoredAt)
+	}
+	return pb
+}
+
+func unaryAuth(auth Authenticator) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		ctx, err := authenticateGRPC(ctx, auth)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+func streamAuth(auth Authenticator) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := authenticateGRPC(ss.Context(), auth)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &authedStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+// authedStream carries the authenticated context to stream handlers.
+type authedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *authedStream) Context() context.Context { return s.ctx }
+
+// authenticateGRPC presents the call's metadata and TLS state to auth as an
+// HTTP request, so API keys, bearer tokens, and client certificates work the
+// same as over HTTP.
+func authenticateGRPC(ctx context.Context, auth Authenticator) (context.Context, error) {
+	r, err := http.NewRequestWithContext(ctx, http.MethodPost, "/", nil)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	for name, vals := range md {
+		for _, v := range vals {
+			r.Header.Add(name, v)
+		}
+	}
+	if p, ok := peer.FromContext(ctx); ok {
+		if info, ok := p.AuthInfo.(credentials.TLSInfo); ok {
+			r.TLS = &info.State
+		}
+	}
+	p, err := auth.Authenticate(r)
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, "unauthenticated")
+	}
+	return withPrincipal(ctx, p), nil
+}
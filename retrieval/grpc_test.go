@@ -0,0 +1,180 @@
+package retrieval
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"net"
+	"testing"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/airblackbox/otel-prompt-vault/processor/promptvaultprocessor"
+	"github.com/airblackbox/otel-prompt-vault/retrieval/retrievalpb"
+)
+
+func newTestGRPCClient(t *testing.T, vault promptvaultprocessor.VaultStorage) retrievalpb.VaultRetrievalClient {
+	t.Helper()
+	auth, err := NewAPIKeyAuthenticator(AuthConfig{APIKeys: []APIKeyConfig{
+		{Name: "sre", Key: "sre-key-0123456789", Scope: Scope{Keys: []string{"http.*"}}},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv, err := NewGRPCServer(zap.NewNop(), vault, nil, false, auth)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln := bufconn.Listen(1 << 20)
+	go srv.Serve(ln)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return ln.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return retrievalpb.NewVaultRetrievalClient(conn)
+}
+
+func withAPIKey(ctx context.Context, key string) context.Context {
+	return metadata.AppendToOutgoingContext(ctx, "x-api-key", key)
+}
+
+func TestGRPCGetContentStreamsChunks(t *testing.T) {
+	ctx := context.Background()
+	vault := promptvaultprocessor.NewMemoryVault(0, 0)
+	content := bytes.Repeat([]byte("large request body "), 10000) // ~190 KiB
+	ref, _ := vault.Store(ctx, promptvaultprocessor.ObjectMeta{Key: "http.request.body", TraceID: "t1"}, content)
+	client := newTestGRPCClient(t, vault)
+
+	stream, err := client.GetContent(withAPIKey(ctx, "sre-key-0123456789"), &retrievalpb.GetContentRequest{Ref: ref})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []byte
+	var chunks int
+	var info *retrievalpb.ObjectInfo
+	for {
+		chunk, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if chunks == 0 {
+			info = chunk.GetInfo()
+		} else if chunk.GetInfo() != nil {
+			t.Error("info sent on a later chunk")
+		}
+		chunks++
+		got = append(got, chunk.GetData()...)
+	}
+	if !bytes.Equal(got, content) {
+		t.Fatalf("reassembled %d bytes, want %d", len(got), len(content))
+	}
+	if chunks < 3 {
+		t.Errorf("got %d chunks, want content split at %d bytes", chunks, chunkSize)
+	}
+	if info.GetSha256() != fmt.Sprintf("%x", sha256.Sum256(content)) || info.GetSize() != int64(len(content)) ||
+		info.GetContentType() != "text/plain; charset=utf-8" || info.GetAttrKey() != "http.request.body" || info.GetTraceId() != "t1" {
+		t.Errorf("info = %v", info)
+	}
+}
+
+func TestGRPCAuthorization(t *testing.T) {
+	ctx := context.Background()
+	vault := promptvaultprocessor.NewMemoryVault(0, 0)
+	allowed, _ := vault.Store(ctx, promptvaultprocessor.ObjectMeta{Key: "http.request.body", Tenant: "acme"}, []byte("body"))
+	denied, _ := vault.Store(ctx, promptvaultprocessor.ObjectMeta{Key: "gen_ai.prompt"}, []byte("prompt"))
+	client := newTestGRPCClient(t, vault)
+
+	if _, err := client.StatContent(ctx, &retrievalpb.StatContentRequest{Ref: allowed}); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("StatContent without credentials: %v, want Unauthenticated", err)
+	}
+	authed := withAPIKey(ctx, "sre-key-0123456789")
+	info, err := client.StatContent(authed, &retrievalpb.StatContentRequest{Ref: allowed})
+	if err != nil || info.GetTenant() != "acme" || info.GetStoredSize() != 4 || info.GetStoredAt() == nil {
+		t.Errorf("StatContent = %v, %v", info, err)
+	}
+	if _, err := client.StatContent(authed, &retrievalpb.StatContentRequest{Ref: denied}); status.Code(err) != codes.PermissionDenied {
+		t.Errorf("StatContent out of scope: %v, want PermissionDenied", err)
+	}
+
+	stream, err := client.GetContent(authed, &retrievalpb.GetContentRequest{Ref: denied})
+	if err == nil {
+		_, err = stream.Recv()
+	}
+	if status.Code(err) != codes.PermissionDenied {
+		t.Errorf("GetContent out of scope: %v, want PermissionDenied", err)
+	}
+}
+
+func TestGRPCBatchGetContent(t *testing.T) {
+	ctx := context.Background()
+	vault := promptvaultprocessor.NewMemoryVault(0, 0)
+	first, _ := vault.Store(ctx, promptvaultprocessor.ObjectMeta{Key: "http.request.body"}, []byte("first"))
+	denied, _ := vault.Store(ctx, promptvaultprocessor.ObjectMeta{Key: "gen_ai.prompt"}, []byte("prompt"))
+	second, _ := vault.Store(ctx, promptvaultprocessor.ObjectMeta{Key: "http.response.body"}, []byte("second"))
+	missing := "vault://" + fmt.Sprintf("%x", sha256.Sum256([]byte("absent")))
+	client := newTestGRPCClient(t, vault)
+	authed := withAPIKey(ctx, "sre-key-0123456789")
+
+	stream, err := client.BatchGetContent(authed, &retrievalpb.BatchGetContentRequest{Requests: []*retrievalpb.GetContentRequest{
+		{Ref: first}, {Ref: denied}, {Ref: missing}, {Ref: second}, {Ref: "vault://a/../b"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]string{}
+	for {
+		chunk, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if chunk.GetErrorCode() != 0 {
+			got[chunk.GetRef()] = codes.Code(chunk.GetErrorCode()).String()
+			continue
+		}
+		got[chunk.GetRef()] += string(chunk.GetData())
+	}
+	want := map[string]string{
+		first:            "first",
+		denied:           codes.PermissionDenied.String(),
+		missing:          codes.NotFound.String(),
+		second:           "second",
+		"vault://a/../b": codes.InvalidArgument.String(),
+	}
+	for ref, w := range want {
+		if got[ref] != w {
+			t.Errorf("%s: got %q, want %q", ref, got[ref], w)
+		}
+	}
+
+	var tooMany retrievalpb.BatchGetContentRequest
+	for i := 0; i <= maxBatchRefs; i++ {
+		tooMany.Requests = append(tooMany.Requests, &retrievalpb.GetContentRequest{Ref: first})
+	}
+	stream, err = client.BatchGetContent(authed, &tooMany)
+	if err == nil {
+		_, err = stream.Recv()
+	}
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("oversized batch: %v, want InvalidArgument", err)
+	}
+}
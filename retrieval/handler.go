@@ -0,0 +1,254 @@
+package retrieval
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"go.uber.org/zap"
+
+	"github.com/airblackbox/otel-prompt-vault/processor/promptvaultprocessor"
+)
+
+const refScheme = "vault://"
+
+// handler serves GET /v1/vault/{ref}.
+type handler struct {
+	logger *zap.Logger
+	reader *reader
+}
+
+// NewHandler serves vault content at GET /v1/vault/{ref}, where ref is a
+// vault:// URI (percent-encoded) or the reference without its scheme, e.g.
+// /v1/vault/<sha256>, lists a trace's objects at GET /v1/objects, and reports
+// tenant usage at GET /v1/usage. Requests must pass through RequireAuth; the principal
+// must be allowed to read the object's tenant and attribute key.
+//
+// When signer is set, a signature given as the sig query parameter or the
+// X-Vault-Signature header is verified against the content, and with
+// requireSignature one must be given.
+func NewHandler(
+	logger *zap.Logger,
+	vault promptvaultprocessor.VaultStorage,
+	signer *promptvaultprocessor.RefSigner,
+	requireSignature bool,
+) (http.Handler, error) {
+	rd, err := newReader(vault, signer, requireSignature)
+	if err != nil {
+		return nil, err
+	}
+	return newHandler(logger, rd), nil
+}
+
+func newHandler(logger *zap.Logger, rd *reader) http.Handler {
+	h := &handler{logger: logger, reader: rd}
+	mux := http.NewServeMux()
+	for pattern, fn := range h.routes() {
+		mux.HandleFunc(pattern, fn)
+	}
+	return mux
+}
+
+// routes maps the read endpoints' patterns to their handlers. openapi.json
+// describes each.
+func (h *handler) routes() map[string]http.HandlerFunc {
+	return map[string]http.HandlerFunc{
+		"GET /v1/vault/{ref...}": h.get,
+		"GET /v1/objects":        h.list,
+		"GET /v1/usage":          h.usage,
+		"GET /v1/stats":          h.stats,
+	}
+}
+
+func (h *handler) get(w http.ResponseWriter, r *http.Request) {
+	ref, err := parseRef(r.PathValue("ref"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	sig := r.URL.Query().Get("sig")
+	if sig == "" {
+		sig = r.Header.Get("X-Vault-Signature")
+	}
+
+	content, info, err := h.reader.read(r.Context(), ref, sig)
+	if err != nil {
+		h.fail(w, ref, err)
+		return
+	}
+
+	hdr := w.Header()
+	hdr.Set("Content-Type", contentType(content))
+	hdr.Set("Content-Length", strconv.Itoa(len(content)))
+	hdr.Set("Cache-Control", "no-store")
+	hdr.Set("X-Content-Type-Options", "nosniff")
+	hdr.Set("X-Vault-Ref", ref)
+	hdr.Set("X-Vault-Sha256", fmt.Sprintf("%x", sha256.Sum256(content)))
+	meta := info.Meta
+	for name, val := range map[string]string{
+		"X-Vault-Attr-Key": meta.Key,
+		"X-Vault-Service":  meta.Service,
+		"X-Vault-Tenant":   meta.Tenant,
+		"X-Vault-Trace-Id": meta.TraceID,
+		"X-Vault-Span-Id":  meta.SpanID,
+	} {
+		if val != "" {
+			hdr.Set(name, url.QueryEscape(val))
+		}
+	}
+	w.Write(content)
+}
+
+// Listing limits for GET /v1/objects.
+const (
+	defaultListLimit = 100
+	maxListLimit     = 1000
+)
+
+// objectJSON describes a stored object in GET /v1/objects responses.
+type objectJSON struct {
+	Ref      string    `json:"ref"`
+	Size     int64     `json:"size"`
+	StoredAt time.Time `json:"stored_at"`
+	TraceID  string    `json:"trace_id,omitempty"`
+	SpanID   string    `json:"span_id,omitempty"`
+	Key      string    `json:"key,omitempty"`
+	Service  string    `json:"service,omitempty"`
+	Tenant   string    `json:"tenant,omitempty"`
+}
+
+// list serves GET /v1/objects?trace_id=<id>[&limit=n], the objects of one
+// trace the caller may read.
+func (h *handler) list(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	traceID := q.Get("trace_id")
+	if traceID == "" {
+		http.Error(w, "trace_id is required", http.StatusBadRequest)
+		return
+	}
+	limit := defaultListLimit
+	if s := q.Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n <= 0 || n > maxListLimit {
+			http.Error(w, fmt.Sprintf("limit must be between 1 and %d", maxListLimit), http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	infos, err := h.reader.list(r.Context(), traceID, limit)
+	if err != nil {
+		if errors.Is(err, errors.ErrUnsupported) {
+			http.Error(w, "storage backend cannot list objects", http.StatusNotImplemented)
+			return
+		}
+		h.fail(w, "", err)
+		return
+	}
+	objects := make([]objectJSON, 0, len(infos))
+	for _, info := range infos {
+		objects = append(objects, objectJSON{
+			Ref:      info.Ref,
+			Size:     info.Size,
+			StoredAt: info.StoredAt.UTC(),
+			TraceID:  info.Meta.TraceID,
+			SpanID:   info.Meta.SpanID,
+			Key:      info.Meta.Key,
+			Service:  info.Meta.Service,
+			Tenant:   info.Meta.Tenant,
+		})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(map[string]any{"objects": objects})
+}
+
+// usage serves GET /v1/usage, the stored bytes and objects and quota of
+// each tenant the caller may read.
+func (h *handler) usage(w http.ResponseWriter, r *http.Request) {
+	tenants, err := h.reader.usage(r.Context())
+	if err != nil {
+		if errors.Is(err, errors.ErrUnsupported) {
+			http.Error(w, "usage is tracked only with storage.quotas", http.StatusNotImplemented)
+			return
+		}
+		h.fail(w, "", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(map[string]any{"tenants": tenants})
+}
+
+func (h *handler) stats(w http.ResponseWriter, r *http.Request) {
+	stats, err := h.reader.stats(r.Context())
+	if err != nil {
+		if errors.Is(err, errors.ErrUnsupported) {
+			http.Error(w, "the storage backend cannot list objects; configure storage.index", http.StatusNotImplemented)
+			return
+		}
+		h.fail(w, "", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(stats)
+}
+
+// fail maps a read error to a response. Backend details are logged, not
+// returned.
+func (h *handler) fail(w http.ResponseWriter, ref string, err error) {
+	switch {
+	case errors.Is(err, promptvaultprocessor.ErrObjectNotFound):
+		http.Error(w, "not found", http.StatusNotFound)
+	case errors.Is(err, errForbidden), errors.Is(err, errSignatureRequired), errors.Is(err, errInvalidSignature):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, promptvaultprocessor.ErrChecksumMismatch):
+		h.logger.Error("vault object failed checksum verification", zap.String("ref", ref))
+		http.Error(w, "checksum mismatch", http.StatusInternalServerError)
+	default:
+		h.logger.Warn("vault retrieval failed", zap.String("ref", ref), zap.Error(err))
+		http.Error(w, "retrieval failed", http.StatusBadGateway)
+	}
+}
+
+// parseRef accepts a full vault:// URI or a reference without its scheme.
+// Path cleaning may have collapsed the URI's double slash.
+func parseRef(s string) (string, error) {
+	for _, prefix := range []string{refScheme, "vault:/"} {
+		if rest, ok := strings.CutPrefix(s, prefix); ok {
+			s = rest
+			break
+		}
+	}
+	if s == "" || strings.HasPrefix(s, "/") {
+		return "", fmt.Errorf("invalid vault ref")
+	}
+	for _, seg := range strings.Split(s, "/") {
+		if seg == "" || seg == "." || seg == ".." {
+			return "", fmt.Errorf("invalid vault ref")
+		}
+	}
+	return refScheme + s, nil
+}
+
+// contentType labels content for clients: JSON documents, UTF-8 text, or
+// whatever the bytes sniff as.
+func contentType(content []byte) string {
+	trimmed := bytes.TrimSpace(content)
+	if len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '[') && json.Valid(trimmed) {
+		return "application/json"
+	}
+	if utf8.Valid(content) {
+		return "text/plain; charset=utf-8"
+	}
+	return http.DetectContentType(content)
+}
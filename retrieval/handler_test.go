@@ -0,0 +1,281 @@
+package retrieval
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"github.com/airblackbox/otel-prompt-vault/processor/promptvaultprocessor"
+)
+
+func newTestHandler(t *testing.T, vault promptvaultprocessor.VaultStorage, signer *promptvaultprocessor.RefSigner, requireSig bool) http.Handler {
+	t.Helper()
+	auth, err := NewAPIKeyAuthenticator(AuthConfig{APIKeys: []APIKeyConfig{
+		{Name: "sre", Key: "sre-key-0123456789", Scope: Scope{Keys: []string{"http.*"}}},
+		{Name: "acme", Key: "acme-key-0123456789", Scope: Scope{Tenants: []string{"acme"}}},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := NewHandler(zap.NewNop(), vault, signer, requireSig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return RequireAuth(auth, h)
+}
+
+func get(h http.Handler, target, apiKey string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	req.Header.Set("X-API-Key", apiKey)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestHandlerServesContent(t *testing.T) {
+	ctx := context.Background()
+	vault := promptvaultprocessor.NewMemoryVault(0, 0)
+	jsonRef, _ := vault.Store(ctx, promptvaultprocessor.ObjectMeta{Key: "http.request.body", Tenant: "acme", TraceID: "t1"}, []byte(`{"q": 1}`))
+	textRef, _ := vault.Store(ctx, promptvaultprocessor.ObjectMeta{Key: "gen_ai.prompt", Tenant: "acme"}, []byte("hello"))
+	binRef, _ := vault.Store(ctx, promptvaultprocessor.ObjectMeta{Key: "http.response.body"}, []byte("\x89PNG\r\n\x1a\n\xff\xfe"))
+	h := newTestHandler(t, vault, nil, false)
+
+	for _, tc := range []struct {
+		target, key, wantType string
+		wantStatus            int
+	}{
+		{"/v1/vault/" + strings.TrimPrefix(jsonRef, "vault://"), "sre-key-0123456789", "application/json", http.StatusOK},
+		{"/v1/vault/" + url.PathEscape(jsonRef), "sre-key-0123456789", "application/json", http.StatusOK},
+		{"/v1/vault/" + strings.TrimPrefix(textRef, "vault://"), "acme-key-0123456789", "text/plain; charset=utf-8", http.StatusOK},
+		{"/v1/vault/" + strings.TrimPrefix(binRef, "vault://"), "sre-key-0123456789", "image/png", http.StatusOK},
+		// sre may only read http.* keys; acme only its own tenant.
+		{"/v1/vault/" + strings.TrimPrefix(textRef, "vault://"), "sre-key-0123456789", "", http.StatusForbidden},
+		{"/v1/vault/" + strings.TrimPrefix(binRef, "vault://"), "acme-key-0123456789", "", http.StatusForbidden},
+		{"/v1/vault/" + strings.Repeat("ab", 32), "sre-key-0123456789", "", http.StatusNotFound},
+		{"/v1/vault/" + strings.TrimPrefix(textRef, "vault://"), "", "", http.StatusUnauthorized},
+	} {
+		rec := get(h, tc.target, tc.key)
+		if rec.Code != tc.wantStatus {
+			t.Errorf("GET %s as %q: status %d, want %d", tc.target, tc.key, rec.Code, tc.wantStatus)
+			continue
+		}
+		if tc.wantStatus == http.StatusOK && rec.Header().Get("Content-Type") != tc.wantType {
+			t.Errorf("GET %s: Content-Type %q, want %q", tc.target, rec.Header().Get("Content-Type"), tc.wantType)
+		}
+	}
+
+	rec := get(h, "/v1/vault/"+strings.TrimPrefix(jsonRef, "vault://"), "sre-key-0123456789")
+	if rec.Body.String() != `{"q": 1}` {
+		t.Errorf("body = %q", rec.Body.String())
+	}
+	if got := rec.Header().Get("X-Vault-Sha256"); "vault://"+got != jsonRef {
+		t.Errorf("X-Vault-Sha256 = %q, want hash of %s", got, jsonRef)
+	}
+	if rec.Header().Get("X-Vault-Attr-Key") != "http.request.body" || rec.Header().Get("X-Vault-Trace-Id") != "t1" {
+		t.Errorf("metadata headers = %v", rec.Header())
+	}
+}
+
+func TestHandlerVerifiesSignature(t *testing.T) {
+	ctx := context.Background()
+	vault := promptvaultprocessor.NewMemoryVault(0, 0)
+	content := []byte("request body")
+	ref, _ := vault.Store(ctx, promptvaultprocessor.ObjectMeta{Key: "http.request.body"}, content)
+	signer, err := promptvaultprocessor.NewRefSigner("0123456789abcdef0123456789abcdef")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig := signer.Sign(ref, fmtSHA256(content), len(content))
+	h := newTestHandler(t, vault, signer, true)
+	target := "/v1/vault/" + strings.TrimPrefix(ref, "vault://")
+
+	for q, want := range map[string]int{
+		"?sig=" + sig:  http.StatusOK,
+		"?sig=AAAA":    http.StatusForbidden,
+		"":             http.StatusForbidden,
+		"?other=value": http.StatusForbidden,
+	} {
+		if rec := get(h, target+q, "sre-key-0123456789"); rec.Code != want {
+			t.Errorf("GET %s: status %d, want %d", q, rec.Code, want)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	req.Header.Set("X-API-Key", "sre-key-0123456789")
+	req.Header.Set("X-Vault-Signature", sig)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Errorf("signature header: status %d, want 200", rec.Code)
+	}
+
+	if _, err := NewHandler(zap.NewNop(), vault, nil, true); err == nil {
+		t.Error("require_signature without a signer was accepted")
+	}
+}
+
+func TestHandlerRejectsTamperedObject(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	vault, err := promptvaultprocessor.OpenVault(ctx, zap.NewNop(), &promptvaultprocessor.Config{
+		Storage: promptvaultprocessor.StorageConfig{
+			Backend:    "filesystem",
+			Filesystem: promptvaultprocessor.FilesystemConfig{BasePath: dir, Layout: "sharded", WriteMetadata: true},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref, err := vault.Store(ctx, promptvaultprocessor.ObjectMeta{Key: "http.request.body"}, []byte("original"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash := strings.TrimPrefix(ref, "vault://")
+	if err := os.WriteFile(filepath.Join(dir, hash[0:2], hash[2:4], hash+".vault"), []byte("tampered"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	h := newTestHandler(t, vault, nil, false)
+	if rec := get(h, "/v1/vault/"+hash, "sre-key-0123456789"); rec.Code != http.StatusInternalServerError || strings.Contains(rec.Body.String(), "tampered") {
+		t.Errorf("tampered object: status %d body %q, want 500 without content", rec.Code, rec.Body.String())
+	}
+}
+
+func TestParseRef(t *testing.T) {
+	for in, want := range map[string]string{
+		"abc":                     "vault://abc",
+		"vault://abc":             "vault://abc",
+		"vault:/abc":              "vault://abc",
+		"2026/01/31/svc/abc":      "vault://2026/01/31/svc/abc",
+		"kafka/prompt-vault/0/12": "vault://kafka/prompt-vault/0/12",
+		"":                        "",
+		"vault://":                "",
+		"a/../b":                  "",
+		"a//b":                    "",
+	} {
+		got, err := parseRef(in)
+		if want == "" {
+			if err == nil {
+				t.Errorf("parseRef(%q) = %q, want error", in, got)
+			}
+			continue
+		}
+		if err != nil || got != want {
+			t.Errorf("parseRef(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+}
+
+func fmtSHA256(content []byte) string {
+	return fmt.Sprintf("%x", sha256.Sum256(content))
+}
+
+func TestHandlerListsTrace(t *testing.T) {
+	ctx := context.Background()
+	vault := promptvaultprocessor.NewMemoryVault(0, 0)
+	vault.Store(ctx, promptvaultprocessor.ObjectMeta{Key: "http.request.body", Tenant: "acme", TraceID: "t1", SpanID: "s1"}, []byte("a"))
+	vault.Store(ctx, promptvaultprocessor.ObjectMeta{Key: "http.response.body", Tenant: "globex", TraceID: "t1"}, []byte("b"))
+	vault.Store(ctx, promptvaultprocessor.ObjectMeta{Key: "http.request.body", Tenant: "acme", TraceID: "t2"}, []byte("c"))
+	h := newTestHandler(t, vault, nil, false)
+
+	list := func(query, key string) (int, []objectJSON) {
+		rec := get(h, "/v1/objects"+query, key)
+		var body struct{ Objects []objectJSON }
+		json.Unmarshal(rec.Body.Bytes(), &body)
+		return rec.Code, body.Objects
+	}
+
+	if code, objs := list("?trace_id=t1", "sre-key-0123456789"); code != http.StatusOK || len(objs) != 2 {
+		t.Errorf("sre: status %d, %d objects; want both objects of t1", code, len(objs))
+	}
+	code, objs := list("?trace_id=t1", "acme-key-0123456789")
+	if code != http.StatusOK || len(objs) != 1 || objs[0].Tenant != "acme" || objs[0].SpanID != "s1" {
+		t.Errorf("acme: status %d, objects %+v; want only its own", code, objs)
+	}
+	if _, objs := list("?trace_id=t1&limit=1", "sre-key-0123456789"); len(objs) != 1 {
+		t.Errorf("limit=1 returned %d objects", len(objs))
+	}
+	for _, q := range []string{"", "?trace_id=t1&limit=0", "?trace_id=t1&limit=x"} {
+		if code, _ := list(q, "sre-key-0123456789"); code != http.StatusBadRequest {
+			t.Errorf("GET /v1/objects%s: status %d, want 400", q, code)
+		}
+	}
+}
+
+func TestHandlerReportsUsage(t *testing.T) {
+	ctx := context.Background()
+	cfg := promptvaultprocessor.NewFactory().CreateDefaultConfig().(*promptvaultprocessor.Config)
+	cfg.Storage.Backend = "memory"
+	cfg.Storage.Quotas.Tenants = map[string]promptvaultprocessor.TenantQuota{"acme": {MaxBytes: 1000}}
+	vault, err := promptvaultprocessor.OpenVault(ctx, zap.NewNop(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vault.(interface{ Start(context.Context) error }).Start(ctx)
+	defer vault.(io.Closer).Close()
+	vault.Store(ctx, promptvaultprocessor.ObjectMeta{Key: "http.request.body", Tenant: "acme"}, []byte("abc"))
+	vault.Store(ctx, promptvaultprocessor.ObjectMeta{Key: "http.request.body", Tenant: "globex"}, []byte("de"))
+	h := newTestHandler(t, vault, nil, false)
+
+	usage := func(key string) (int, []promptvaultprocessor.TenantUsage) {
+		rec := get(h, "/v1/usage", key)
+		var body struct {
+			Tenants []promptvaultprocessor.TenantUsage
+		}
+		json.Unmarshal(rec.Body.Bytes(), &body)
+		return rec.Code, body.Tenants
+	}
+	if code, tenants := usage("sre-key-0123456789"); code != http.StatusOK || len(tenants) != 2 || tenants[1].Tenant != "globex" || tenants[1].Bytes != 2 {
+		t.Errorf("sre: status %d, tenants %+v; want both", code, tenants)
+	}
+	code, tenants := usage("acme-key-0123456789")
+	if code != http.StatusOK || len(tenants) != 1 || tenants[0] != (promptvaultprocessor.TenantUsage{Tenant: "acme", Bytes: 3, Objects: 1, MaxBytes: 1000}) {
+		t.Errorf("acme: status %d, tenants %+v; want only its own", code, tenants)
+	}
+
+	plain := newTestHandler(t, promptvaultprocessor.NewMemoryVault(0, 0), nil, false)
+	if rec := get(plain, "/v1/usage", "sre-key-0123456789"); rec.Code != http.StatusNotImplemented {
+		t.Errorf("without quotas: status %d, want 501", rec.Code)
+	}
+}
+
+func TestHandlerReportsStats(t *testing.T) {
+	ctx := context.Background()
+	vault := promptvaultprocessor.NewMemoryVault(0, 0)
+	vault.Store(ctx, promptvaultprocessor.ObjectMeta{Key: "http.request.body", Tenant: "acme"}, []byte("abc"))
+	vault.Store(ctx, promptvaultprocessor.ObjectMeta{Key: "gen_ai.prompt", Tenant: "globex"}, []byte("de"))
+	auth, err := NewAPIKeyAuthenticator(AuthConfig{APIKeys: []APIKeyConfig{
+		{Name: "admin", Key: "admin-key-0123456789"},
+		{Name: "acme", Key: "acme-key-0123456789", Scope: Scope{Tenants: []string{"acme"}}},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	inner, err := NewHandler(zap.NewNop(), vault, nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := RequireAuth(auth, inner)
+
+	rec := get(h, "/v1/stats", "admin-key-0123456789")
+	var stats promptvaultprocessor.VaultStats
+	json.Unmarshal(rec.Body.Bytes(), &stats)
+	if rec.Code != http.StatusOK || stats.Objects != 2 || stats.Bytes != 5 || stats.ByTenant["acme"].Bytes != 3 {
+		t.Errorf("admin: status %d, stats %+v", rec.Code, stats)
+	}
+	// Totals span tenants, so a tenant-scoped key may not see them.
+	if rec := get(h, "/v1/stats", "acme-key-0123456789"); rec.Code != http.StatusForbidden {
+		t.Errorf("acme: status %d, want 403", rec.Code)
+	}
+}
@@ -0,0 +1,144 @@
+package retrieval
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+)
+
+// OIDCAuthenticator accepts bearer tokens verified against an OpenID Connect
+// provider's published keys.
+type OIDCAuthenticator struct {
+	cfg      OIDCConfig
+	verifier *oidc.IDTokenVerifier
+}
+
+// NewOIDCAuthenticator fetches the provider's discovery document, so the
+// issuer must be reachable at startup. Signing keys are fetched and
+// refreshed as tokens reference them.
+func NewOIDCAuthenticator(ctx context.Context, cfg OIDCConfig) (*OIDCAuthenticator, error) {
+	if cfg.Audience == "" {
+		return nil, fmt.Errorf("auth.oidc.audience is required")
+	}
+	for group, scope := range cfg.GroupScopes {
+		if err := scope.validate(); err != nil {
+			return nil, fmt.Errorf("auth.oidc.group_scopes[%s]: %w", group, err)
+		}
+	}
+	if cfg.UsernameClaim == "" {
+		cfg.UsernameClaim = "sub"
+	}
+	if cfg.GroupsClaim == "" {
+		cfg.GroupsClaim = "groups"
+	}
+	provider, err := oidc.NewProvider(ctx, cfg.IssuerURL)
+	if err != nil {
+		return nil, fmt.Errorf("auth.oidc: %w", err)
+	}
+	return &OIDCAuthenticator{
+		cfg:      cfg,
+		verifier: provider.Verifier(&oidc.Config{ClientID: cfg.Audience}),
+	}, nil
+}
+
+// Authenticate verifies the bearer token's signature, issuer, audience,
+// expiry, and required claims, then maps its groups to scopes.
+func (a *OIDCAuthenticator) Authenticate(r *http.Request) (*Principal, error) {
+	raw, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		return nil, ErrUnauthenticated
+	}
+	token, err := a.verifier.Verify(r.Context(), raw)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnauthenticated, err)
+	}
+	var claims map[string]any
+	if err := token.Claims(&claims); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnauthenticated, err)
+	}
+	for name, want := range a.cfg.RequiredClaims {
+		if got, _ := claims[name].(string); got != want {
+			return nil, fmt.Errorf("%w: claim %s does not match", ErrUnauthenticated, name)
+		}
+	}
+
+	p := &Principal{}
+	p.Name, _ = claims[a.cfg.UsernameClaim].(string)
+	if p.Name == "" {
+		return nil, fmt.Errorf("%w: token has no %s claim", ErrUnauthenticated, a.cfg.UsernameClaim)
+	}
+	for _, group := range claimStrings(claims[a.cfg.GroupsClaim]) {
+		if scope, ok := a.cfg.GroupScopes[group]; ok {
+			p.Scopes = append(p.Scopes, scope)
+		}
+	}
+	if a.cfg.TenantClaim != "" {
+		p.Tenants = claimStrings(claims[a.cfg.TenantClaim])
+		if p.Tenants == nil {
+			p.Tenants = []string{}
+		}
+	}
+	return p, nil
+}
+
+// claimStrings reads a claim holding a string or a list of strings.
+func claimStrings(v any) []string {
+	switch v := v.(type) {
+	case string:
+		return []string{v}
+	case []any:
+		var out []string
+		for _, e := range v {
+			if s, ok := e.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+// NewAuthenticator builds an authenticator accepting every credential type
+// configured in cfg.
+func NewAuthenticator(ctx context.Context, cfg AuthConfig) (Authenticator, error) {
+	var chain authChain
+	if len(cfg.APIKeys) > 0 {
+		a, err := NewAPIKeyAuthenticator(cfg)
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, a)
+	}
+	if cfg.OIDC.IssuerURL != "" {
+		a, err := NewOIDCAuthenticator(ctx, cfg.OIDC)
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, a)
+	}
+	if len(chain) == 0 {
+		return nil, fmt.Errorf("auth requires api_keys or oidc")
+	}
+	return chain, nil
+}
+
+// authChain accepts a request if any of its authenticators does.
+type authChain []Authenticator
+
+func (c authChain) Authenticate(r *http.Request) (*Principal, error) {
+	err := ErrUnauthenticated
+	for _, a := range c {
+		p, aerr := a.Authenticate(r)
+		if aerr == nil {
+			return p, nil
+		}
+		if aerr != ErrUnauthenticated {
+			// Prefer an error about a credential that was presented.
+			err = aerr
+		}
+	}
+	return nil, err
+}
@@ -0,0 +1,154 @@
+package retrieval
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// fakeIdP serves an OIDC discovery document and JWKS, and signs RS256 tokens.
+type fakeIdP struct {
+	srv *httptest.Server
+	key *rsa.PrivateKey
+}
+
+func newFakeIdP(t *testing.T) *fakeIdP {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idp := &fakeIdP{key: key}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, _ *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{
+			"issuer":                                idp.srv.URL,
+			"jwks_uri":                              idp.srv.URL + "/keys",
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+		})
+	})
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, _ *http.Request) {
+		b64 := base64.RawURLEncoding.EncodeToString
+		json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{{
+			"kty": "RSA", "alg": "RS256", "use": "sig", "kid": "k1",
+			"n": b64(key.N.Bytes()), "e": b64(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	idp.srv = httptest.NewServer(mux)
+	t.Cleanup(idp.srv.Close)
+	return idp
+}
+
+func (idp *fakeIdP) token(t *testing.T, claims map[string]any) string {
+	t.Helper()
+	b64 := func(v any) string {
+		b, _ := json.Marshal(v)
+		return base64.RawURLEncoding.EncodeToString(b)
+	}
+	base := map[string]any{"iss": idp.srv.URL, "aud": "promptvault", "exp": time.Now().Add(time.Hour).Unix()}
+	for k, v := range claims {
+		base[k] = v
+	}
+	signing := b64(map[string]string{"alg": "RS256", "kid": "k1", "typ": "JWT"}) + "." + b64(base)
+	sum := sha256.Sum256([]byte(signing))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, idp.key, crypto.SHA256, sum[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signing + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func TestOIDCAuthenticator(t *testing.T) {
+	idp := newFakeIdP(t)
+	auth, err := NewAuthenticator(context.Background(), AuthConfig{OIDC: OIDCConfig{
+		IssuerURL:      idp.srv.URL,
+		Audience:       "promptvault",
+		RequiredClaims: map[string]string{"org": "example"},
+		UsernameClaim:  "email",
+		GroupScopes: map[string]Scope{
+			"privacy-team": {Keys: []string{"gen_ai.input.messages"}},
+			"sre":          {Keys: []string{"http.*"}},
+		},
+		TenantClaim: "tenants",
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	authenticate := func(token string) (*Principal, error) {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		return auth.Authenticate(req)
+	}
+
+	p, err := authenticate(idp.token(t, map[string]any{
+		"sub": "u1", "email": "pat@example.com", "org": "example",
+		"groups": []string{"privacy-team", "unrelated"}, "tenants": []string{"acme"},
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Name != "pat@example.com" {
+		t.Errorf("name = %q", p.Name)
+	}
+	if !p.Allows(ActionRead, "acme", "gen_ai.input.messages") {
+		t.Error("privacy-team member denied their tenant's messages")
+	}
+	if p.Allows(ActionRead, "globex", "gen_ai.input.messages") {
+		t.Error("tenant claim not enforced")
+	}
+	if p.Allows(ActionRead, "acme", "http.request.body") {
+		t.Error("granted a scope from a group the caller is not in")
+	}
+
+	for name, claims := range map[string]map[string]any{
+		"wrong audience": {"sub": "u1", "email": "x", "org": "example", "aud": "other"},
+		"expired":        {"sub": "u1", "email": "x", "org": "example", "exp": time.Now().Add(-time.Hour).Unix()},
+		"missing claim":  {"sub": "u1", "email": "x"},
+		"no username":    {"sub": "u1", "org": "example"},
+		"wrong issuer":   {"sub": "u1", "email": "x", "org": "example", "iss": "https://evil.example"},
+	} {
+		if _, err := authenticate(idp.token(t, claims)); err == nil {
+			t.Errorf("%s: expected token to be rejected", name)
+		}
+	}
+	if _, err := authenticate("not-a-jwt"); err == nil {
+		t.Error("expected malformed token to be rejected")
+	}
+}
+
+func TestAuthChainFallsThrough(t *testing.T) {
+	idp := newFakeIdP(t)
+	auth, err := NewAuthenticator(context.Background(), AuthConfig{
+		APIKeys: []APIKeyConfig{{Name: "ci", Key: "ci-key-0123456789"}},
+		OIDC:    OIDCConfig{IssuerURL: idp.srv.URL, Audience: "promptvault"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("X-API-Key", "ci-key-0123456789")
+	if p, err := auth.Authenticate(req); err != nil || p.Name != "ci" {
+		t.Errorf("api key: %v, %v", p, err)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", "Bearer "+idp.token(t, map[string]any{"sub": "u2"}))
+	if p, err := auth.Authenticate(req); err != nil || p.Name != "u2" {
+		t.Errorf("bearer: %v, %v", p, err)
+	}
+
+	if _, err := NewAuthenticator(context.Background(), AuthConfig{}); err == nil {
+		t.Error("expected error with no credentials configured")
+	}
+}
@@ -0,0 +1,25 @@
+package retrieval
+
+import (
+	_ "embed"
+	"net/http"
+	"strconv"
+)
+
+// openAPIRoute is where the API's OpenAPI document is served.
+const openAPIRoute = "GET /openapi.json"
+
+//go:embed openapi.json
+var openAPISpec []byte
+
+// NewOpenAPIHandler serves the HTTP API's OpenAPI 3 document, from which
+// clients can be generated. It needs no authentication.
+func NewOpenAPIHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hdr := w.Header()
+		hdr.Set("Content-Type", "application/json")
+		hdr.Set("Content-Length", strconv.Itoa(len(openAPISpec)))
+		hdr.Set("X-Content-Type-Options", "nosniff")
+		w.Write(openAPISpec)
+	})
+}
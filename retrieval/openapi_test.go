@@ -0,0 +1,101 @@
+package retrieval
+
+import (
+	"encoding/json"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/airblackbox/otel-prompt-vault/processor/promptvaultprocessor"
+)
+
+type openAPIDoc struct {
+	Paths      map[string]map[string]json.RawMessage `json:"paths"`
+	Components struct {
+		Schemas map[string]struct {
+			Required   []string                   `json:"required"`
+			Properties map[string]json.RawMessage `json:"properties"`
+		} `json:"schemas"`
+	} `json:"components"`
+}
+
+func loadOpenAPIDoc(t *testing.T) openAPIDoc {
+	t.Helper()
+	var doc openAPIDoc
+	if err := json.Unmarshal(openAPISpec, &doc); err != nil {
+		t.Fatalf("parse openapi.json: %v", err)
+	}
+	return doc
+}
+
+func TestOpenAPIDescribesRoutes(t *testing.T) {
+	doc := loadOpenAPIDoc(t)
+	var described []string
+	for path, ops := range doc.Paths {
+		for method := range ops {
+			described = append(described, strings.ToUpper(method)+" "+path)
+		}
+	}
+	var served []string
+	for pattern := range (&handler{}).routes() {
+		served = append(served, pattern)
+	}
+	served = append(served, erasureRoute, openAPIRoute)
+	for i, p := range served {
+		// ServeMux wildcards spanning segments are plain parameters in OpenAPI.
+		served[i] = strings.ReplaceAll(p, "...}", "}")
+	}
+	sort.Strings(described)
+	sort.Strings(served)
+	if !slices.Equal(described, served) {
+		t.Errorf("openapi.json describes %v; the server serves %v", described, served)
+	}
+}
+
+func TestOpenAPISchemasMatchResponses(t *testing.T) {
+	doc := loadOpenAPIDoc(t)
+	for name, typ := range map[string]reflect.Type{
+		"Object":         reflect.TypeOf(objectJSON{}),
+		"TenantUsage":    reflect.TypeOf(promptvaultprocessor.TenantUsage{}),
+		"StatsCount":     reflect.TypeOf(promptvaultprocessor.StatsCount{}),
+		"VaultStats":     reflect.TypeOf(promptvaultprocessor.VaultStats{}),
+		"ErasureRequest": reflect.TypeOf(promptvaultprocessor.ErasureRequest{}),
+		"ErasedObject":   reflect.TypeOf(promptvaultprocessor.ErasedObject{}),
+		"ErasureReceipt": reflect.TypeOf(promptvaultprocessor.ErasureReceipt{}),
+	} {
+		schema, ok := doc.Components.Schemas[name]
+		if !ok {
+			t.Errorf("openapi.json has no %s schema", name)
+			continue
+		}
+		var fields, required []string
+		for i := 0; i < typ.NumField(); i++ {
+			tag := typ.Field(i).Tag.Get("json")
+			field, opts, _ := strings.Cut(tag, ",")
+			if field == "" || field == "-" {
+				continue
+			}
+			fields = append(fields, field)
+			if opts != "omitempty" {
+				required = append(required, field)
+			}
+		}
+		var props []string
+		for p := range schema.Properties {
+			props = append(props, p)
+		}
+		sort.Strings(fields)
+		sort.Strings(props)
+		sort.Strings(required)
+		got := slices.Clone(schema.Required)
+		sort.Strings(got)
+		if !slices.Equal(fields, props) {
+			t.Errorf("%s: schema properties %v, %s fields %v", name, props, typ, fields)
+		}
+		if !slices.Equal(required, got) {
+			t.Errorf("%s: schema requires %v, %s always writes %v", name, got, typ, required)
+		}
+	}
+}
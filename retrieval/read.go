@@ -0,0 +1,139 @@
+package retrieval
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/airblackbox/otel-prompt-vault/processor/promptvaultprocessor"
+)
+
+// Errors returned by reader, mapped to HTTP statuses and gRPC codes.
+var (
+	errForbidden         = errors.New("forbidden")
+	errSignatureRequired = errors.New("signature required")
+	errInvalidSignature  = errors.New("invalid signature")
+)
+
+// reader reads objects on behalf of the principal in the request context,
+// enforcing its scopes and reference signatures and running the retrieve
+// hooks. It backs both the HTTP and gRPC APIs.
+type reader struct {
+	vault            promptvaultprocessor.VaultStorage
+	signer           *promptvaultprocessor.RefSigner
+	requireSignature bool
+	hooks            promptvaultprocessor.RetrieveHooks
+}
+
+func newReader(vault promptvaultprocessor.VaultStorage, signer *promptvaultprocessor.RefSigner, requireSignature bool) (*reader, error) {
+	if requireSignature && signer == nil {
+		return nil, fmt.Errorf("require_signature needs vault.signing_key")
+	}
+	return &reader{vault: vault, signer: signer, requireSignature: requireSignature}, nil
+}
+
+// stat describes ref if the caller may read it. Authorization uses the
+// object's metadata, so denied requests never touch the content or the
+// audit log. Without metadata the tenant and key are empty, which only an
+// unrestricted scope allows.
+func (rd *reader) stat(ctx context.Context, ref string) (promptvaultprocessor.ObjectInfo, error) {
+	info := promptvaultprocessor.ObjectInfo{Ref: ref}
+	if s, ok := rd.vault.(promptvaultprocessor.VaultStatter); ok {
+		got, err := s.Stat(ctx, ref)
+		switch {
+		case err == nil:
+			info = got
+		case !errors.Is(err, errors.ErrUnsupported):
+			return promptvaultprocessor.ObjectInfo{}, err
+		}
+	}
+	if p := PrincipalFrom(ctx); p == nil || !p.Allows(ActionRead, info.Meta.Tenant, info.Meta.Key) {
+		return promptvaultprocessor.ObjectInfo{}, errForbidden
+	}
+	return info, nil
+}
+
+// read returns the content of ref if the caller may read it, verifying sig
+// when the reader has a signer. Hooks run on verified content.
+func (rd *reader) read(ctx context.Context, ref, sig string) ([]byte, promptvaultprocessor.ObjectInfo, error) {
+	info, err := rd.stat(ctx, ref)
+	if err != nil {
+		return nil, info, err
+	}
+	if sig == "" && rd.requireSignature {
+		return nil, info, errSignatureRequired
+	}
+	content, err := rd.vault.Retrieve(ctx, ref)
+	if err != nil {
+		return nil, info, err
+	}
+	if sig != "" && rd.signer != nil {
+		if err := rd.signer.Verify(ref, sig, content); err != nil {
+			return nil, info, errInvalidSignature
+		}
+	}
+	if content, err = rd.hooks.Apply(ctx, info, content); err != nil {
+		return nil, info, err
+	}
+	return content, info, nil
+}
+
+// usage returns the usage of each tenant the caller may read objects of.
+func (rd *reader) usage(ctx context.Context) ([]promptvaultprocessor.TenantUsage, error) {
+	p := PrincipalFrom(ctx)
+	if p == nil {
+		return nil, errForbidden
+	}
+	u, ok := rd.vault.(promptvaultprocessor.VaultUsageReporter)
+	if !ok {
+		return nil, fmt.Errorf("usage: %w", errors.ErrUnsupported)
+	}
+	all, err := u.Usage(ctx)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]promptvaultprocessor.TenantUsage, 0, len(all))
+	for _, t := range all {
+		if p.allowsTenant(ActionRead, t.Tenant) {
+			out = append(out, t)
+		}
+	}
+	return out, nil
+}
+
+// stats aggregates the whole vault. Totals span tenants and keys, so only
+// a caller that may read everything gets them.
+func (rd *reader) stats(ctx context.Context) (promptvaultprocessor.VaultStats, error) {
+	if p := PrincipalFrom(ctx); p == nil || !p.allowsAll(ActionRead) {
+		return promptvaultprocessor.VaultStats{}, errForbidden
+	}
+	return promptvaultprocessor.Stats(ctx, rd.vault)
+}
+
+// errStopList ends a listing early once the limit is reached.
+var errStopList = errors.New("stop listing")
+
+// list returns up to limit objects stored for traceID that the caller may
+// read. Vaults without storage.index are scanned in full.
+func (rd *reader) list(ctx context.Context, traceID string, limit int) ([]promptvaultprocessor.ObjectInfo, error) {
+	p := PrincipalFrom(ctx)
+	if p == nil {
+		return nil, errForbidden
+	}
+	var out []promptvaultprocessor.ObjectInfo
+	filter := promptvaultprocessor.ObjectFilter{TraceID: traceID}
+	err := promptvaultprocessor.SearchObjects(ctx, rd.vault, filter, func(info promptvaultprocessor.ObjectInfo) error {
+		if !p.Allows(ActionRead, info.Meta.Tenant, info.Meta.Key) {
+			return nil
+		}
+		out = append(out, info)
+		if len(out) >= limit {
+			return errStopList
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errStopList) {
+		return nil, err
+	}
+	return out, nil
+}
@@ -0,0 +1,5 @@
+// Package retrievalpb holds the generated gRPC bindings for the retrieval
+// API defined in proto/promptvault/retrieval/v1/retrieval.proto.
+package retrievalpb
+
+//go:generate protoc -I ../../proto --go_out=../.. --go_opt=module=github.com/airblackbox/otel-prompt-vault --go-grpc_out=../.. --go-grpc_opt=module=github.com/airblackbox/otel-prompt-vault promptvault/retrieval/v1/retrieval.proto
@@ -0,0 +1,222 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: promptvault/retrieval/v1/retrieval.proto
+
+package retrievalpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	VaultRetrieval_GetContent_FullMethodName      = "/promptvault.retrieval.v1.VaultRetrieval/GetContent"
+	VaultRetrieval_BatchGetContent_FullMethodName = "/promptvault.retrieval.v1.VaultRetrieval/BatchGetContent"
+	VaultRetrieval_StatContent_FullMethodName     = "/promptvault.retrieval.v1.VaultRetrieval/StatContent"
+)
+
+// VaultRetrievalClient is the client API for VaultRetrieval service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// VaultRetrieval serves vaulted content to authorized clients. Callers
+// authenticate with the same credentials as the HTTP API, sent as
+// "x-api-key" or "authorization: Bearer <token>" metadata.
+type VaultRetrievalClient interface {
+	// GetContent streams one object's content in chunks.
+	GetContent(ctx context.Context, in *GetContentRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[ContentChunk], error)
+	// BatchGetContent streams several objects, one after another, each as a
+	// sequence of chunks. An object that cannot be read ends with an error
+	// chunk; the stream continues with the next object.
+	BatchGetContent(ctx context.Context, in *BatchGetContentRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[ContentChunk], error)
+	// StatContent describes an object without reading its content.
+	StatContent(ctx context.Context, in *StatContentRequest, opts ...grpc.CallOption) (*ObjectInfo, error)
+}
+
+type vaultRetrievalClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewVaultRetrievalClient(cc grpc.ClientConnInterface) VaultRetrievalClient {
+	return &vaultRetrievalClient{cc}
+}
+
+func (c *vaultRetrievalClient) GetContent(ctx context.Context, in *GetContentRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[ContentChunk], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &VaultRetrieval_ServiceDesc.Streams[0], VaultRetrieval_GetContent_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[GetContentRequest, ContentChunk]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type VaultRetrieval_GetContentClient = grpc.ServerStreamingClient[ContentChunk]
+
+func (c *vaultRetrievalClient) BatchGetContent(ctx context.Context, in *BatchGetContentRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[ContentChunk], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &VaultRetrieval_ServiceDesc.Streams[1], VaultRetrieval_BatchGetContent_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[BatchGetContentRequest, ContentChunk]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type VaultRetrieval_BatchGetContentClient = grpc.ServerStreamingClient[ContentChunk]
+
+func (c *vaultRetrievalClient) StatContent(ctx context.Context, in *StatContentRequest, opts ...grpc.CallOption) (*ObjectInfo, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ObjectInfo)
+	err := c.cc.Invoke(ctx, VaultRetrieval_StatContent_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// VaultRetrievalServer is the server API for VaultRetrieval service.
+// All implementations must embed UnimplementedVaultRetrievalServer
+// for forward compatibility.
+//
+// VaultRetrieval serves vaulted content to authorized clients. Callers
+// authenticate with the same credentials as the HTTP API, sent as
+// "x-api-key" or "authorization: Bearer <token>" metadata.
+type VaultRetrievalServer interface {
+	// GetContent streams one object's content in chunks.
+	GetContent(*GetContentRequest, grpc.ServerStreamingServer[ContentChunk]) error
+	// BatchGetContent streams several objects, one after another, each as a
+	// sequence of chunks. An object that cannot be read ends with an error
+	// chunk; the stream continues with the next object.
+	BatchGetContent(*BatchGetContentRequest, grpc.ServerStreamingServer[ContentChunk]) error
+	// StatContent describes an object without reading its content.
+	StatContent(context.Context, *StatContentRequest) (*ObjectInfo, error)
+	mustEmbedUnimplementedVaultRetrievalServer()
+}
+
+// UnimplementedVaultRetrievalServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedVaultRetrievalServer struct{}
+
+func (UnimplementedVaultRetrievalServer) GetContent(*GetContentRequest, grpc.ServerStreamingServer[ContentChunk]) error {
+	return status.Errorf(codes.Unimplemented, "method GetContent not implemented")
+}
+func (UnimplementedVaultRetrievalServer) BatchGetContent(*BatchGetContentRequest, grpc.ServerStreamingServer[ContentChunk]) error {
+	return status.Errorf(codes.Unimplemented, "method BatchGetContent not implemented")
+}
+func (UnimplementedVaultRetrievalServer) StatContent(context.Context, *StatContentRequest) (*ObjectInfo, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method StatContent not implemented")
+}
+func (UnimplementedVaultRetrievalServer) mustEmbedUnimplementedVaultRetrievalServer() {}
+func (UnimplementedVaultRetrievalServer) testEmbeddedByValue()                        {}
+
+// UnsafeVaultRetrievalServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to VaultRetrievalServer will
+// result in compilation errors.
+type UnsafeVaultRetrievalServer interface {
+	mustEmbedUnimplementedVaultRetrievalServer()
+}
+
+func RegisterVaultRetrievalServer(s grpc.ServiceRegistrar, srv VaultRetrievalServer) {
+	// If the following call pancis, it indicates UnimplementedVaultRetrievalServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&VaultRetrieval_ServiceDesc, srv)
+}
+
+func _VaultRetrieval_GetContent_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(GetContentRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(VaultRetrievalServer).GetContent(m, &grpc.GenericServerStream[GetContentRequest, ContentChunk]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type VaultRetrieval_GetContentServer = grpc.ServerStreamingServer[ContentChunk]
+
+func _VaultRetrieval_BatchGetContent_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(BatchGetContentRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(VaultRetrievalServer).BatchGetContent(m, &grpc.GenericServerStream[BatchGetContentRequest, ContentChunk]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type VaultRetrieval_BatchGetContentServer = grpc.ServerStreamingServer[ContentChunk]
+
+func _VaultRetrieval_StatContent_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StatContentRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(VaultRetrievalServer).StatContent(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: VaultRetrieval_StatContent_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(VaultRetrievalServer).StatContent(ctx, req.(*StatContentRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// VaultRetrieval_ServiceDesc is the grpc.ServiceDesc for VaultRetrieval service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var VaultRetrieval_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "promptvault.retrieval.v1.VaultRetrieval",
+	HandlerType: (*VaultRetrievalServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "StatContent",
+			Handler:    _VaultRetrieval_StatContent_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "GetContent",
+			Handler:       _VaultRetrieval_GetContent_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "BatchGetContent",
+			Handler:       _VaultRetrieval_BatchGetContent_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "promptvault/retrieval/v1/retrieval.proto",
+}
@@ -0,0 +1,198 @@
+package retrieval
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+
+	"github.com/airblackbox/otel-prompt-vault/processor/promptvaultprocessor"
+)
+
+const defaultEndpoint = "localhost:8470"
+
+// Server serves the retrieval API over HTTP(S) and, optionally, gRPC. Its
+// Start and Shutdown follow the collector component lifecycle.
+type Server struct {
+	logger   *zap.Logger
+	endpoint string
+	vault    promptvaultprocessor.VaultStorage
+	srv      *http.Server
+	ln       net.Listener
+
+	grpcEndpoint string
+	grpcSrv      *grpc.Server
+	grpcLn       net.Listener
+}
+
+// NewServer builds a server for vault, which it owns and closes on Shutdown.
+// Every retrieval is audited to cfg.Audit with source "api", and signer, if
+// set, verifies reference signatures.
+func NewServer(
+	ctx context.Context,
+	logger *zap.Logger,
+	cfg ServerConfig,
+	vault promptvaultprocessor.VaultStorage,
+	signer *promptvaultprocessor.RefSigner,
+) (*Server, error) {
+	auth, err := NewAuthenticator(ctx, cfg.Auth)
+	if err != nil {
+		return nil, err
+	}
+	sink, err := promptvaultprocessor.NewAuditSink(ctx, cfg.Audit, vault)
+	if err != nil {
+		return nil, err
+	}
+	vault = promptvaultprocessor.NewAuditingVault(vault, sink, "api")
+	rd, err := newReader(vault, signer, cfg.RequireSignature)
+	if err != nil {
+		return nil, err
+	}
+	if rd.hooks, err = promptvaultprocessor.NewRetrieveHooks(cfg.Hooks); err != nil {
+		return nil, err
+	}
+	h := newHandler(logger, rd)
+
+	if cfg.Erasure.Enabled {
+		if cfg.Erasure.ReceiptKeyFile == "" {
+			return nil, fmt.Errorf("erasure needs receipt_key_file")
+		}
+		key, err := promptvaultprocessor.LoadEd25519ManifestKey(cfg.Erasure.ReceiptKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		mux := http.NewServeMux()
+		mux.Handle(erasureRoute, NewErasureHandler(logger, vault, key))
+		mux.Handle("/", h)
+		h = mux
+	}
+
+	// The API description and the UI's static files hold no vault data and
+	// are served without authentication.
+	mux := http.NewServeMux()
+	mux.Handle(openAPIRoute, NewOpenAPIHandler())
+	if cfg.UI {
+		mux.Handle("GET /ui/", NewUIHandler())
+		mux.Handle("GET /{$}", http.RedirectHandler("/ui/", http.StatusFound))
+	}
+	mux.Handle("/", RequireAuth(auth, h))
+	handler := http.Handler(mux)
+
+	s := &Server{
+		logger:   logger,
+		endpoint: cfg.Endpoint,
+		vault:    vault,
+		srv: &http.Server{
+			Handler:           handler,
+			ReadHeaderTimeout: 10 * time.Second,
+		},
+	}
+	if s.endpoint == "" {
+		s.endpoint = defaultEndpoint
+	}
+	if cfg.TLS != nil {
+		if s.srv.TLSConfig, err = loadTLSConfig(ctx, *cfg.TLS); err != nil {
+			return nil, err
+		}
+	}
+
+	if cfg.GRPC.Endpoint != "" {
+		var opts []grpc.ServerOption
+		if s.srv.TLSConfig != nil {
+			opts = append(opts, grpc.Creds(credentials.NewTLS(s.srv.TLSConfig)))
+		}
+		s.grpcEndpoint = cfg.GRPC.Endpoint
+		s.grpcSrv = newGRPCServer(logger, rd, auth, opts...)
+	}
+	return s, nil
+}
+
+// Start starts the vault's background work, binds the endpoint, and serves
+// in the background.
+func (s *Server) Start(ctx context.Context) error {
+	if st, ok := s.vault.(interface{ Start(context.Context) error }); ok {
+		if err := st.Start(ctx); err != nil {
+			return err
+		}
+	}
+	ln, err := net.Listen("tcp", s.endpoint)
+	if err != nil {
+		return err
+	}
+	if s.srv.TLSConfig != nil {
+		ln = tls.NewListener(ln, s.srv.TLSConfig)
+	} else {
+		s.logger.Warn("retrieval API is serving without TLS")
+	}
+	s.ln = ln
+	s.logger.Info("retrieval API listening", zap.String("endpoint", ln.Addr().String()))
+	go func() {
+		if err := s.srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			s.logger.Error("retrieval API stopped", zap.Error(err))
+		}
+	}()
+
+	if s.grpcSrv == nil {
+		return nil
+	}
+	if s.grpcLn, err = net.Listen("tcp", s.grpcEndpoint); err != nil {
+		return err
+	}
+	s.logger.Info("retrieval gRPC API listening", zap.String("endpoint", s.grpcLn.Addr().String()))
+	go func() {
+		if err := s.grpcSrv.Serve(s.grpcLn); err != nil {
+			s.logger.Error("retrieval gRPC API stopped", zap.Error(err))
+		}
+	}()
+	return nil
+}
+
+// Addr returns the bound HTTP address, once started.
+func (s *Server) Addr() net.Addr {
+	if s.ln == nil {
+		return nil
+	}
+	return s.ln.Addr()
+}
+
+// GRPCAddr returns the bound gRPC address, once started.
+func (s *Server) GRPCAddr() net.Addr {
+	if s.grpcLn == nil {
+		return nil
+	}
+	return s.grpcLn.Addr()
+}
+
+// Shutdown stops accepting requests, waits for in-flight ones, and closes
+// the vault and audit sink.
+func (s *Server) Shutdown(ctx context.Context) error {
+	var errs []error
+	if s.ln != nil {
+		errs = append(errs, s.srv.Shutdown(ctx))
+	}
+	if s.grpcLn != nil {
+		stopped := make(chan struct{})
+		go func() {
+			s.grpcSrv.GracefulStop()
+			close(stopped)
+		}()
+		select {
+		case <-stopped:
+		case <-ctx.Done():
+			s.grpcSrv.Stop()
+			errs = append(errs, ctx.Err())
+		}
+	}
+	if c, ok := s.vault.(io.Closer); ok {
+		errs = append(errs, c.Close())
+	}
+	return errors.Join(errs...)
+}
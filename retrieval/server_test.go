@@ -0,0 +1,186 @@
+package retrieval
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/airblackbox/otel-prompt-vault/processor/promptvaultprocessor"
+	"github.com/airblackbox/otel-prompt-vault/retrieval/retrievalpb"
+)
+
+func TestServerAuditsRetrievals(t *testing.T) {
+	ctx := context.Background()
+	vault := promptvaultprocessor.NewMemoryVault(0, 0)
+	ref, _ := vault.Store(ctx, promptvaultprocessor.ObjectMeta{Key: "gen_ai.prompt"}, []byte("hello"))
+	auditLog := filepath.Join(t.TempDir(), "audit.jsonl")
+
+	srv, err := NewServer(ctx, zap.NewNop(), ServerConfig{
+		Endpoint: "127.0.0.1:0",
+		Auth:     AuthConfig{APIKeys: []APIKeyConfig{{Name: "oncall", Key: "oncall-key-0123456789"}}},
+		Audit:    promptvaultprocessor.AuditConfig{Sink: "file", File: auditLog},
+		GRPC:     GRPCConfig{Endpoint: "127.0.0.1:0"},
+	}, vault, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := srv.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Shutdown(ctx)
+
+	req, _ := http.NewRequest(http.MethodGet, "http://"+srv.Addr().String()+"/v1/vault/"+strings.TrimPrefix(ref, "vault://"), nil)
+	req.Header.Set("X-API-Key", "oncall-key-0123456789")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || string(body) != "hello" {
+		t.Fatalf("status %d body %q", resp.StatusCode, body)
+	}
+
+	conn, err := grpc.NewClient(srv.GRPCAddr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	stream, err := retrievalpb.NewVaultRetrievalClient(conn).GetContent(
+		metadata.AppendToOutgoingContext(ctx, "x-api-key", "oncall-key-0123456789"),
+		&retrievalpb.GetContentRequest{Ref: ref},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if chunk, err := stream.Recv(); err != nil || string(chunk.GetData()) != "hello" {
+		t.Fatalf("gRPC GetContent = %v, %v", chunk, err)
+	}
+
+	data, err := os.ReadFile(auditLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d audit records, want one per API", len(lines))
+	}
+	for _, line := range lines {
+		var rec promptvaultprocessor.AuditRecord
+		if err := json.Unmarshal([]byte(line), &rec); err != nil {
+			t.Fatal(err)
+		}
+		if rec.Principal != "oncall" || rec.Source != "api" || rec.Ref != ref || rec.Outcome != "ok" {
+			t.Errorf("audit record = %+v", rec)
+		}
+	}
+}
+
+func TestServerServesUI(t *testing.T) {
+	ctx := context.Background()
+	srv, err := NewServer(ctx, zap.NewNop(), ServerConfig{
+		Endpoint: "127.0.0.1:0",
+		Auth:     AuthConfig{APIKeys: []APIKeyConfig{{Name: "support", Key: "support-key-0123456789"}}},
+		UI:       true,
+	}, promptvaultprocessor.NewMemoryVault(0, 0), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := srv.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Shutdown(ctx)
+	base := "http://" + srv.Addr().String()
+
+	for path, want := range map[string]int{
+		"/ui/":                    http.StatusOK,
+		"/ui/app.js":              http.StatusOK,
+		"/openapi.json":           http.StatusOK,
+		"/v1/objects?trace_id=t1": http.StatusUnauthorized,
+	} {
+		resp, err := http.Get(base + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != want {
+			t.Errorf("GET %s: status %d, want %d", path, resp.StatusCode, want)
+		}
+		if strings.HasPrefix(path, "/ui/") && !strings.Contains(resp.Header.Get("Content-Security-Policy"), "default-src 'self'") {
+			t.Errorf("GET %s: no content security policy", path)
+		}
+	}
+}
+
+func init() {
+	promptvaultprocessor.RegisterRetrieveHook("test_requester_mark", func(map[string]any) (promptvaultprocessor.RetrieveHook, error) {
+		return promptvaultprocessor.RetrieveHookFunc(func(ctx context.Context, info promptvaultprocessor.ObjectInfo, data []byte) ([]byte, error) {
+			return append(data, " -- "+info.Meta.Key+" for "+PrincipalFrom(ctx).Name...), nil
+		}), nil
+	})
+}
+
+func TestServerRunsRetrieveHooks(t *testing.T) {
+	ctx := context.Background()
+	vault := promptvaultprocessor.NewMemoryVault(0, 0)
+	ref, _ := vault.Store(ctx, promptvaultprocessor.ObjectMeta{Key: "gen_ai.prompt"}, []byte("hello"))
+
+	srv, err := NewServer(ctx, zap.NewNop(), ServerConfig{
+		Endpoint: "127.0.0.1:0",
+		Auth:     AuthConfig{APIKeys: []APIKeyConfig{{Name: "oncall", Key: "oncall-key-0123456789"}}},
+		GRPC:     GRPCConfig{Endpoint: "127.0.0.1:0"},
+		Hooks:    []promptvaultprocessor.RetrieveHookConfig{{Name: "test_requester_mark"}},
+	}, vault, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := srv.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Shutdown(ctx)
+	const want = "hello -- gen_ai.prompt for oncall"
+
+	req, _ := http.NewRequest(http.MethodGet, "http://"+srv.Addr().String()+"/v1/vault/"+strings.TrimPrefix(ref, "vault://"), nil)
+	req.Header.Set("X-API-Key", "oncall-key-0123456789")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || string(body) != want {
+		t.Errorf("status %d body %q, want %q", resp.StatusCode, body, want)
+	}
+
+	conn, err := grpc.NewClient(srv.GRPCAddr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	stream, err := retrievalpb.NewVaultRetrievalClient(conn).GetContent(
+		metadata.AppendToOutgoingContext(ctx, "x-api-key", "oncall-key-0123456789"),
+		&retrievalpb.GetContentRequest{Ref: ref},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if chunk, err := stream.Recv(); err != nil || string(chunk.GetData()) != want {
+		t.Errorf("gRPC GetContent = %q, %v; want %q", chunk.GetData(), err, want)
+	}
+
+	if _, err := NewServer(ctx, zap.NewNop(), ServerConfig{
+		Hooks: []promptvaultprocessor.RetrieveHookConfig{{Name: "missing"}},
+	}, vault, nil); err == nil {
+		t.Error("expected error for an unknown hook")
+	}
+}
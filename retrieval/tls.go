@@ -0,0 +1,38 @@
+package retrieval
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net/http"
+
+	"go.opentelemetry.io/collector/config/configtls"
+)
+
+// loadTLSConfig builds the server-side tls.Config from cfg. A server needs
+// a certificate, and TLS below 1.2 is refused whatever min_version says.
+func loadTLSConfig(ctx context.Context, cfg configtls.ServerConfig) (*tls.Config, error) {
+	if (cfg.CertFile == "" && cfg.CertPem == "") || (cfg.KeyFile == "" && cfg.KeyPem == "") {
+		return nil, fmt.Errorf("tls requires a certificate and key")
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("tls: %w", err)
+	}
+	tlsCfg, err := cfg.LoadTLSConfig(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("tls: %w", err)
+	}
+	if tlsCfg.MinVersion < tls.VersionTLS12 {
+		return nil, fmt.Errorf("tls min_version %s is below 1.2", cfg.MinVersion)
+	}
+	return tlsCfg, nil
+}
+
+// ClientCertSubject returns the common name of the verified client
+// certificate on r, or "" when the connection is not mTLS.
+func ClientCertSubject(r *http.Request) string {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return ""
+	}
+	return r.TLS.VerifiedChains[0][0].Subject.CommonName
+}
@@ -0,0 +1,146 @@
+package retrieval
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/collector/config/configtls"
+)
+
+type testCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	der  []byte
+}
+
+func newTestCert(t *testing.T, cn string, parent *testCert, isCA bool) *testCert {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: cn},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  isCA,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1)},
+	}
+	signer, signerKey := tmpl, key
+	if parent != nil {
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return &testCert{cert: cert, key: key, der: der}
+}
+
+func (c *testCert) write(t *testing.T, dir, name string) (certFile, keyFile string) {
+	t.Helper()
+	certFile = filepath.Join(dir, name+".crt")
+	keyFile = filepath.Join(dir, name+".key")
+	keyDER, _ := x509.MarshalECPrivateKey(c.key)
+	os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.der}), 0o600)
+	os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600)
+	return certFile, keyFile
+}
+
+func (c *testCert) tlsCert() tls.Certificate {
+	return tls.Certificate{Certificate: [][]byte{c.der}, PrivateKey: c.key}
+}
+
+func TestMutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCert(t, "test-ca", nil, true)
+	caFile, _ := ca.write(t, dir, "ca")
+	certFile, keyFile := newTestCert(t, "vault-server", ca, false).write(t, dir, "server")
+
+	cfg, err := loadTLSConfig(context.Background(), configtls.ServerConfig{
+		Config:       configtls.Config{CertFile: certFile, KeyFile: keyFile, MinVersion: "1.3"},
+		ClientCAFile: caFile,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, ClientCertSubject(r))
+	}))
+	// Serve through our tls.Config as-is; StartTLS would install its own certificate.
+	srv.Listener = tls.NewListener(srv.Listener, cfg)
+	srv.Start()
+	defer srv.Close()
+	url := "https://" + srv.Listener.Addr().String()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	get := func(client *testCert) (string, error) {
+		tlsCfg := &tls.Config{RootCAs: roots}
+		if client != nil {
+			tlsCfg.Certificates = []tls.Certificate{client.tlsCert()}
+		}
+		c := &http.Client{Transport: &http.Transport{TLSClientConfig: tlsCfg}}
+		resp, err := c.Get(url)
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		return string(body), err
+	}
+
+	if got, err := get(newTestCert(t, "sre-oncall", ca, false)); err != nil || got != "sre-oncall" {
+		t.Errorf("trusted client: got %q, %v", got, err)
+	}
+	if _, err := get(nil); err == nil {
+		t.Error("expected handshake failure without a client certificate")
+	}
+	if _, err := get(newTestCert(t, "intruder", newTestCert(t, "other-ca", nil, true), false)); err == nil {
+		t.Error("expected handshake failure for a client signed by an untrusted CA")
+	}
+}
+
+func TestLoadTLSConfigValidates(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := newTestCert(t, "server", nil, false).write(t, dir, "server")
+
+	server := configtls.Config{CertFile: certFile, KeyFile: keyFile}
+	withVersions := func(min, max string) configtls.Config {
+		c := server
+		c.MinVersion, c.MaxVersion = min, max
+		return c
+	}
+	for name, cfg := range map[string]configtls.ServerConfig{
+		"missing key":      {Config: configtls.Config{CertFile: certFile}},
+		"old version":      {Config: withVersions("1.0", "")},
+		"bad version":      {Config: withVersions("2.0", "")},
+		"inverted range":   {Config: withVersions("1.3", "1.2")},
+		"missing ca file":  {Config: server, ClientCAFile: filepath.Join(dir, "none.pem")},
+		"ca without certs": {Config: server, ClientCAFile: keyFile},
+	} {
+		if _, err := loadTLSConfig(context.Background(), cfg); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
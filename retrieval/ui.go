@@ -0,0 +1,25 @@
+package retrieval
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+)
+
+//go:embed ui
+var uiFiles embed.FS
+
+// NewUIHandler serves the vault browser's static files under /ui/. The
+// files hold no vault data; the page calls the API with the credential
+// the user enters, so it needs no authentication of its own.
+func NewUIHandler() http.Handler {
+	sub, _ := fs.Sub(uiFiles, "ui")
+	files := http.StripPrefix("/ui/", http.FileServer(http.FS(sub)))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hdr := w.Header()
+		hdr.Set("Content-Security-Policy", "default-src 'self'; frame-ancestors 'none'")
+		hdr.Set("X-Content-Type-Options", "nosniff")
+		hdr.Set("Referrer-Policy", "no-referrer")
+		files.ServeHTTP(w, r)
+	})
+}
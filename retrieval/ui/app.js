@@ -0,0 +1,148 @@
+// Prompt Vault browser. Content is only ever inserted as text, never as
+// HTML, since vaulted payloads are untrusted.
+"use strict";
+
+const previewLimit = 256 * 1024;
+const $ = (id) => document.getElementById(id);
+
+function authHeaders() {
+  const value = sessionStorage.getItem("auth-value");
+  if (!value) return {};
+  return sessionStorage.getItem("auth-kind") === "bearer"
+    ? { Authorization: "Bearer " + value }
+    : { "X-API-Key": value };
+}
+
+function setStatus(text, isError) {
+  $("status").textContent = text;
+  $("status").className = isError ? "error" : "";
+}
+
+async function apiFetch(path) {
+  const resp = await fetch(path, { headers: authHeaders() });
+  if (!resp.ok) {
+    const msg = (await resp.text()).trim();
+    throw new Error(resp.status + " " + (msg || resp.statusText));
+  }
+  return resp;
+}
+
+function refPath(ref) {
+  return "/v1/vault/" + ref.replace(/^vault:\/\//, "").split("/").map(encodeURIComponent).join("/");
+}
+
+function cell(row, text, mono) {
+  const td = row.insertCell();
+  td.textContent = text;
+  if (mono) td.className = "mono";
+  return td;
+}
+
+async function search(traceID) {
+  history.replaceState(null, "", "?trace_id=" + encodeURIComponent(traceID));
+  $("preview").hidden = true;
+  setStatus("Searching…");
+  try {
+    const resp = await apiFetch("/v1/objects?trace_id=" + encodeURIComponent(traceID));
+    const { objects } = await resp.json();
+    const body = $("objects").tBodies[0];
+    body.replaceChildren();
+    for (const obj of objects) {
+      const row = body.insertRow();
+      cell(row, new Date(obj.stored_at).toLocaleString());
+      cell(row, obj.service || "");
+      cell(row, obj.key || "");
+      cell(row, obj.span_id || "", true);
+      cell(row, obj.tenant || "");
+      cell(row, obj.size + " B");
+      cell(row, obj.ref, true);
+      const actions = row.insertCell();
+      const view = document.createElement("button");
+      view.textContent = "Preview";
+      view.onclick = () => preview(obj, row);
+      const copy = document.createElement("button");
+      copy.textContent = "Copy ref";
+      copy.onclick = async () => {
+        await navigator.clipboard.writeText(obj.ref);
+        setStatus("Copied " + obj.ref);
+      };
+      actions.append(view, " ", copy);
+    }
+    $("objects").hidden = objects.length === 0;
+    setStatus(objects.length === 0 ? "No objects you can read for this trace." : objects.length + " object(s).");
+  } catch (err) {
+    $("objects").hidden = true;
+    setStatus(err.message, true);
+  }
+}
+
+async function preview(obj, row) {
+  for (const r of $("objects").tBodies[0].rows) r.classList.toggle("selected", r === row);
+  setStatus("Loading " + obj.ref + "…");
+  try {
+    const resp = await apiFetch(refPath(obj.ref));
+    const type = resp.headers.get("Content-Type") || "";
+    const meta = {
+      Reference: obj.ref,
+      "Attribute key": obj.key,
+      Service: obj.service,
+      Tenant: obj.tenant,
+      "Trace ID": obj.trace_id,
+      "Span ID": obj.span_id,
+      "Stored at": obj.stored_at,
+      "Content type": type,
+      SHA256: resp.headers.get("X-Vault-Sha256"),
+    };
+    const dl = $("preview-meta");
+    dl.replaceChildren();
+    for (const [k, v] of Object.entries(meta)) {
+      if (!v) continue;
+      const dt = document.createElement("dt");
+      dt.textContent = k;
+      const dd = document.createElement("dd");
+      dd.textContent = v;
+      dl.append(dt, dd);
+    }
+
+    let text;
+    if (type.startsWith("application/json")) {
+      text = JSON.stringify(await resp.json(), null, 2);
+    } else if (type.startsWith("text/")) {
+      text = await resp.text();
+    } else {
+      text = "(" + type + ", " + obj.size + " bytes; not previewed)";
+    }
+    if (text.length > previewLimit) {
+      text = text.slice(0, previewLimit) + "\n… truncated";
+    }
+    $("preview-title").textContent = obj.key || obj.ref;
+    $("preview-content").textContent = text;
+    $("preview").hidden = false;
+    setStatus("");
+  } catch (err) {
+    setStatus(err.message, true);
+  }
+}
+
+$("credentials").onsubmit = (e) => {
+  e.preventDefault();
+  sessionStorage.setItem("auth-kind", $("auth-kind").value);
+  sessionStorage.setItem("auth-value", $("auth-value").value);
+  $("auth-value").value = "";
+  $("auth-value").placeholder = "Credential set";
+  const traceID = $("trace-id").value.trim();
+  if (traceID) search(traceID);
+};
+
+$("search").onsubmit = (e) => {
+  e.preventDefault();
+  search($("trace-id").value.trim());
+};
+
+$("auth-kind").value = sessionStorage.getItem("auth-kind") || "api-key";
+if (sessionStorage.getItem("auth-value")) $("auth-value").placeholder = "Credential set";
+const initial = new URLSearchParams(location.search).get("trace_id");
+if (initial) {
+  $("trace-id").value = initial;
+  search(initial);
+}